@@ -0,0 +1,417 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+// ALTER 子句在目标数据库中能够使用的最快算法
+const (
+	alterInstant = iota // 只修改元数据
+	alterInplace        // 在线变更，不阻塞 DML（ALGORITHM=INPLACE, LOCK=NONE）
+	alterCopy           // 需要拷贝表或阻塞 DML
+)
+
+// alterPartitionSpec 分区相关的子句不能与其他子句写在同一条 ALTER 中
+var alterPartitionSpec = map[tidb.AlterTableType]bool{
+	tidb.AlterTableAddPartitions:              true,
+	tidb.AlterTableCoalescePartitions:         true,
+	tidb.AlterTableDropPartition:              true,
+	tidb.AlterTableTruncatePartition:          true,
+	tidb.AlterTablePartition:                  true,
+	tidb.AlterTableRemovePartitioning:         true,
+	tidb.AlterTableRebuildPartition:           true,
+	tidb.AlterTableReorganizePartition:        true,
+	tidb.AlterTableCheckPartitions:            true,
+	tidb.AlterTableExchangePartition:          true,
+	tidb.AlterTableOptimizePartition:          true,
+	tidb.AlterTableRepairPartition:            true,
+	tidb.AlterTableImportPartitionTablespace:  true,
+	tidb.AlterTableDiscardPartitionTablespace: true,
+	tidb.AlterTableImportTablespace:           true,
+	tidb.AlterTableDiscardTablespace:          true,
+	tidb.AlterTableWithValidation:             true,
+	tidb.AlterTableWithoutValidation:          true,
+	tidb.AlterTableSecondaryLoad:              true,
+	tidb.AlterTableSecondaryUnload:            true,
+	tidb.AlterTableSetTiFlashReplica:          true,
+}
+
+// alterConvertRe ALTER TABLE ... CONVERT TO CHARACTER SET
+var alterConvertRe = regexp.MustCompile(`(?i)\bconvert\s+to\s+(character\s+set|charset)\b`)
+
+// alterClause ALTER TABLE 中的一个子句
+type alterClause struct {
+	sql       string
+	algorithm int
+	drop      bool // DROP 子句合并后放在前面，如先删除旧索引再添加同名索引
+}
+
+// alterTable 输入中同一张表的 ALTER 请求
+type alterTable struct {
+	name    string // 原 SQL 中的表名，如 `db`.`tb`
+	lines   []int
+	clauses []alterClause
+	renames []string // RENAME TO 子句，合并后放在最后单独执行
+}
+
+// AlterChecker 收集输入中对同一张表的多条 ALTER TABLE, CREATE INDEX, DROP INDEX 请求，给出合并后的变更语句（ALT.002）
+// 与 TransactionChecker 相同，去重跳过的 SQL 也需要按顺序 Add，每个输入文件使用一个新的 AlterChecker
+type AlterChecker struct {
+	tables map[string]*alterTable
+}
+
+// NewAlterChecker 初始化 AlterChecker
+func NewAlterChecker() *AlterChecker {
+	return &AlterChecker{tables: make(map[string]*alterTable)}
+}
+
+// Add 按顺序添加一条 SQL，line 为 SQL 所在的行
+// 同一张表第二次及之后的变更请求返回 ALT.002，Case 为合并后的语句，其他情况返回的 Rule.Item 为空
+func (c *AlterChecker) Add(sql string, line int) Rule {
+	stmts, err := ast.TiParse(sql, "", "")
+	if err != nil || len(stmts) != 1 {
+		return Rule{}
+	}
+
+	var table *tidb.TableName
+	var clauses []alterClause
+	var renames []string
+	switch node := stmts[0].(type) {
+	case *tidb.AlterTableStmt:
+		table = node.Table
+		for _, spec := range node.Specs {
+			switch {
+			case spec.Tp == tidb.AlterTableLock, spec.Tp == tidb.AlterTableAlgorithm:
+				// 合并后按子句重新指定 ALGORITHM, LOCK
+				continue
+			case alterPartitionSpec[spec.Tp]:
+				return Rule{}
+			}
+			clause, err := restoreNode(spec)
+			if err != nil {
+				common.Log.Debug("AlterChecker restore error: %v", err)
+				return Rule{}
+			}
+			clause = strings.TrimSpace(clause)
+			algorithm := alterAlgorithm(spec)
+			switch {
+			case spec.Tp == tidb.AlterTableRenameTable:
+				renames = append(renames, clause)
+				continue
+			case len(spec.Options) == 1 && spec.Options[0].Tp == tidb.TableOptionCharset && alterConvertRe.MatchString(sql):
+				// 不带 COLLATE 的 CONVERT TO 与修改默认字符集的 AST 相同，还原后会丢失 CONVERT
+				clause = "CONVERT TO CHARACTER SET " + spec.Options[0].StrValue
+				algorithm = alterCopy
+			}
+			clauses = append(clauses, alterClause{sql: clause, algorithm: algorithm, drop: alterDropSpec(spec)})
+		}
+	case *tidb.CreateIndexStmt:
+		table = node.Table
+		clause, err := createIndexClause(node)
+		if err != nil {
+			common.Log.Debug("AlterChecker restore error: %v", err)
+			return Rule{}
+		}
+		clauses = append(clauses, clause)
+	case *tidb.DropIndexStmt:
+		table = node.Table
+		spec := &tidb.AlterTableSpec{Tp: tidb.AlterTableDropIndex, Name: node.IndexName, IfExists: node.IfExists}
+		clause, _ := restoreNode(spec)
+		clauses = append(clauses, alterClause{sql: clause, algorithm: alterAlgorithm(spec), drop: true})
+	case *tidb.DropTableStmt:
+		// 表删除后之前的变更不再需要合并
+		for _, tb := range node.Tables {
+			delete(c.tables, alterTableKey(tb))
+		}
+		return Rule{}
+	default:
+		return Rule{}
+	}
+	if len(clauses) == 0 && len(renames) == 0 {
+		return Rule{}
+	}
+
+	key := alterTableKey(table)
+	tb, ok := c.tables[key]
+	if !ok {
+		name, _ := restoreNode(table)
+		tb = &alterTable{name: name}
+		c.tables[key] = tb
+	}
+	tb.lines = append(tb.lines, line)
+	tb.clauses = append(tb.clauses, clauses...)
+	tb.renames = append(tb.renames, renames...)
+	if len(tb.lines) < 2 {
+		return Rule{}
+	}
+
+	var lines []string
+	for _, l := range tb.lines {
+		lines = append(lines, fmt.Sprint(l))
+	}
+	rule := HeuristicRules["ALT.002"]
+	stmtList, notes := tb.merge()
+	rule.Content = strings.Join(append([]string{rule.Content,
+		fmt.Sprintf("%s 表在第 %s 行共有 %d 条变更请求，建议合并为：%s", tb.name, strings.Join(lines, ", "), len(tb.lines), strings.Join(stmtList, " "))},
+		notes...), " ")
+	rule.Case = strings.Join(stmtList, "\n")
+	return rule
+}
+
+// alterTableKey 用于区分不同的表，库名、表名不区分大小写
+func alterTableKey(tb *tidb.TableName) string {
+	return strings.ToLower(tb.Schema.O + "." + tb.Name.O)
+}
+
+// merge 合并同一张表的所有子句，返回合并后的语句及说明
+// 存在需要拷贝表的子句时所有子句合并为一条，只拷贝一次；否则支持 INSTANT 的子句单独执行，避免随其他子句重建表
+func (tb *alterTable) merge() ([]string, []string) {
+	var groups [3][]alterClause
+	hasCopy := false
+	for _, clause := range tb.clauses {
+		if clause.algorithm == alterCopy {
+			hasCopy = true
+		}
+		groups[clause.algorithm] = append(groups[clause.algorithm], clause)
+	}
+	if hasCopy {
+		groups = [3][]alterClause{nil, nil, append([]alterClause{}, tb.clauses...)}
+	}
+
+	var stmts, notes []string
+	var order []alterClause
+	for algorithm, clauses := range groups {
+		if len(clauses) == 0 {
+			continue
+		}
+		sort.SliceStable(clauses, func(i, j int) bool {
+			return clauses[i].drop && !clauses[j].drop
+		})
+		order = append(order, clauses...)
+
+		var sqls []string
+		for _, clause := range clauses {
+			sqls = append(sqls, clause.sql)
+		}
+		switch algorithm {
+		case alterInstant:
+			sqls = append(sqls, "ALGORITHM=INSTANT")
+		case alterInplace:
+			sqls = append(sqls, "ALGORITHM=INPLACE", "LOCK=NONE")
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s %s;", tb.name, strings.Join(sqls, ", ")))
+	}
+	for _, rename := range tb.renames {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s %s;", tb.name, rename))
+	}
+
+	switch {
+	case hasCopy:
+		notes = append(notes, "其中部分子句需要拷贝表，合并后只需拷贝一次。")
+	case len(groups[alterInstant]) > 0 && len(groups[alterInplace]) > 0:
+		notes = append(notes, "支持 INSTANT 的子句单独执行，避免随其他子句重建表。")
+	}
+	for i := range order {
+		if order[i].sql != tb.clauses[i].sql {
+			notes = append(notes, "合并后子句的执行顺序有调整，请确认子句之间没有依赖。")
+			break
+		}
+	}
+	if len(tb.renames) > 0 {
+		notes = append(notes, "重命名表放在最后执行。")
+	}
+	return stmts, notes
+}
+
+// createIndexClause 将 CREATE INDEX 转换为 ALTER TABLE ADD INDEX 子句
+func createIndexClause(node *tidb.CreateIndexStmt) (alterClause, error) {
+	constraint := &tidb.Constraint{
+		Tp:     tidb.ConstraintIndex,
+		Name:   node.IndexName,
+		Keys:   node.IndexColNames,
+		Option: node.IndexOption,
+	}
+	switch node.KeyType {
+	case tidb.IndexKeyTypeUnique:
+		constraint.Tp = tidb.ConstraintUniq
+	case tidb.IndexKeyTypeFullText:
+		constraint.Tp = tidb.ConstraintFulltext
+	}
+	spec := &tidb.AlterTableSpec{Tp: tidb.AlterTableAddConstraint, Constraint: constraint}
+	sql, err := restoreNode(spec)
+	if err != nil {
+		return alterClause{}, err
+	}
+	sql = strings.TrimSpace(sql)
+	algorithm := alterAlgorithm(spec)
+	// TiDB 的 Constraint 中没有 SPATIAL 类型，与 FULLTEXT 一样不支持 LOCK=NONE
+	if node.KeyType == tidb.IndexKeyTypeSpatial {
+		sql = "ADD SPATIAL " + strings.TrimPrefix(sql, "ADD ")
+		algorithm = alterCopy
+	}
+	return alterClause{sql: sql, algorithm: algorithm}, nil
+}
+
+// alterDropSpec 是否为 DROP 子句
+func alterDropSpec(spec *tidb.AlterTableSpec) bool {
+	switch spec.Tp {
+	case tidb.AlterTableDropColumn, tidb.AlterTableDropPrimaryKey, tidb.AlterTableDropIndex,
+		tidb.AlterTableDropForeignKey, tidb.AlterTableDropCheck:
+		return true
+	}
+	return false
+}
+
+// alterAlgorithm 判断 ALTER 子句在目标数据库（-target）中能够使用的最快算法
+// 未指定目标数据库版本时不使用 INSTANT；INPLACE 中包括重建表的操作，但不阻塞 DML
+func alterAlgorithm(spec *tidb.AlterTableSpec) int {
+	target := common.TargetDB()
+	instantOr := func(mysql, mariadb int) int {
+		if target.Supports(mysql, mariadb) {
+			return alterInstant
+		}
+		return alterInplace
+	}
+
+	switch spec.Tp {
+	case tidb.AlterTableAddColumns:
+		algorithm := alterInstant
+		for _, col := range spec.NewColumns {
+			for _, opt := range col.Options {
+				switch {
+				case opt.Tp == tidb.ColumnOptionAutoIncrement, opt.Tp == tidb.ColumnOptionGenerated && opt.Stored:
+					return alterCopy
+				case opt.Tp == tidb.ColumnOptionPrimaryKey, opt.Tp == tidb.ColumnOptionUniqKey:
+					algorithm = alterInplace
+				}
+			}
+		}
+		if algorithm == alterInplace {
+			return algorithm
+		}
+		// MySQL 8.0.12 开始支持 INSTANT 添加列（只能加在最后），8.0.29 开始支持任意位置
+		if spec.Position != nil && spec.Position.Tp != tidb.ColumnPositionNone {
+			return instantOr(80029, 100400)
+		}
+		return instantOr(80012, 100300)
+	case tidb.AlterTableAddConstraint:
+		switch spec.Constraint.Tp {
+		case tidb.ConstraintPrimaryKey, tidb.ConstraintKey, tidb.ConstraintIndex,
+			tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+			return alterInplace
+		}
+		// 全文索引不支持 LOCK=NONE，外键只有关闭 foreign_key_checks 时才能 INPLACE
+		return alterCopy
+	case tidb.AlterTableDropColumn:
+		return instantOr(80029, 100400)
+	case tidb.AlterTableRenameColumn:
+		return instantOr(80028, 100300)
+	case tidb.AlterTableAlterColumn, tidb.AlterTableRenameIndex:
+		return instantOr(80012, 100300)
+	case tidb.AlterTableDropIndex, tidb.AlterTableDropForeignKey, tidb.AlterTableForce:
+		return alterInplace
+	case tidb.AlterTableOption:
+		for _, opt := range spec.Options {
+			if opt.Tp == tidb.TableOptionEngine {
+				return alterCopy
+			}
+		}
+		// CONVERT TO CHARACTER SET 需要拷贝表，只修改默认字符集时可以 INPLACE
+		if len(spec.Options) == 2 && spec.Options[0].Tp == tidb.TableOptionCharset &&
+			spec.Options[1].Tp == tidb.TableOptionCollate {
+			return alterCopy
+		}
+		return alterInplace
+	}
+	// DROP PRIMARY KEY, MODIFY, CHANGE 等修改列类型的操作
+	return alterCopy
+}
+
+// RuleAlterMetadataLock ALT.005
+// ALTER 在开始和结束时都需要获取排他的元数据锁，等待期间会阻塞之后所有访问该表的请求
+func (idxAdv *IndexAdvisor) RuleAlterMetadataLock() Rule {
+	rule := HeuristicRules["OK"]
+	if common.Config.OnlineDSN.Disable {
+		return rule
+	}
+
+	var tables []*tidb.TableName
+	for _, stmt := range idxAdv.TiStmt {
+		switch node := stmt.(type) {
+		case *tidb.AlterTableStmt:
+			tables = append(tables, node.Table)
+		case *tidb.CreateIndexStmt:
+			tables = append(tables, node.Table)
+		case *tidb.DropIndexStmt:
+			tables = append(tables, node.Table)
+		}
+	}
+
+	var fixes []string
+	for _, tb := range tables {
+		conn := idxAdv.rEnv
+		if tb.Schema.O != "" {
+			conn.Database = tb.Schema.O
+		}
+		activity, err := conn.TableActivity(tb.Name.O, common.Config.MDLLongQueryTime)
+		if err != nil {
+			common.Log.Warn("RuleAlterMetadataLock TableActivity Error: %v", err)
+			continue
+		}
+		if fix := metadataLockFix(tb.Name.O, activity); fix != "" {
+			fixes = append(fixes, fix)
+		}
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["ALT.005"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// metadataLockFix 根据表的访问情况说明元数据锁堆积的风险，没有风险时返回空
+func metadataLockFix(tb string, activity *database.TableActivity) string {
+	var risks []string
+	if activity.RowOps >= common.Config.MDLHotTableOps {
+		risks = append(risks, fmt.Sprintf("平均每秒读写 %.0f 行", activity.RowOps))
+	}
+	if activity.MDLWaits > 0 {
+		risks = append(risks, fmt.Sprintf("当前有 %d 个请求正在等待元数据锁", activity.MDLWaits))
+	}
+	if activity.LongQueries > 0 {
+		risks = append(risks, fmt.Sprintf("当前有 %d 个访问该表的请求执行超过 %d 秒", activity.LongQueries, common.Config.MDLLongQueryTime))
+	}
+	// 没有访问该表时长事务不会阻塞 DDL
+	if len(risks) == 0 {
+		return ""
+	}
+	if activity.LongTrx > 0 {
+		risks = append(risks, fmt.Sprintf("实例中有 %d 个事务执行超过 %d 秒", activity.LongTrx, common.Config.MDLLongQueryTime))
+	}
+	return fmt.Sprintf("`%s` 表%s。", tb, strings.Join(risks, "，"))
+}
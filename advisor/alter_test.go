@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+func TestAlterChecker(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	cases := []struct {
+		target string
+		sqls   []string
+		want   string // 最后一条 SQL 返回的合并语句
+	}{
+		// 未指定目标数据库时不使用 INSTANT
+		{"", []string{
+			"ALTER TABLE film ADD COLUMN score INT",
+			"CREATE INDEX idx_title ON film (title)",
+		}, "ALTER TABLE `film` ADD COLUMN `score` INT, ADD INDEX `idx_title`(`title`), ALGORITHM=INPLACE, LOCK=NONE;"},
+		// 支持 INSTANT 的子句单独执行，DROP 子句放在前面
+		{"mysql:8.0.30", []string{
+			"ALTER TABLE sakila.film ADD COLUMN score INT, ALGORITHM=INPLACE",
+			"ALTER TABLE sakila.film ADD INDEX idx_title (title)",
+			"DROP INDEX idx_old ON sakila.film",
+			"ALTER TABLE sakila.film RENAME TO sakila.movie",
+		}, "ALTER TABLE `sakila`.`film` ADD COLUMN `score` INT, ALGORITHM=INSTANT;\n" +
+			"ALTER TABLE `sakila`.`film` DROP INDEX `idx_old`, ADD INDEX `idx_title`(`title`), ALGORITHM=INPLACE, LOCK=NONE;\n" +
+			"ALTER TABLE `sakila`.`film` RENAME AS `sakila`.`movie`;"},
+		// 需要拷贝表时合并为一条
+		{"mysql:8.0.30", []string{
+			"ALTER TABLE film ADD COLUMN score INT",
+			"ALTER TABLE film MODIFY title VARCHAR(255)",
+			"ALTER TABLE film CONVERT TO CHARACTER SET utf8mb4",
+		}, "ALTER TABLE `film` ADD COLUMN `score` INT, MODIFY COLUMN `title` VARCHAR(255), CONVERT TO CHARACTER SET utf8mb4;"},
+		// 不同的表、删除后重建的表不合并
+		{"", []string{
+			"ALTER TABLE film ADD COLUMN score INT",
+			"ALTER TABLE actor ADD COLUMN score INT",
+			"DROP TABLE film",
+			"ALTER TABLE film ADD COLUMN score INT",
+		}, ""},
+	}
+	for _, c := range cases {
+		common.Config.Target = c.target
+		checker := NewAlterChecker()
+		var got string
+		for i, sql := range c.sqls {
+			rule := checker.Add(sql, i+1)
+			if i == len(c.sqls)-1 {
+				got = rule.Case
+			}
+		}
+		if got != c.want {
+			t.Errorf("SQLs: %v\nwant: %s\ngot: %s", c.sqls, c.want, got)
+		}
+	}
+
+	// 第二条 ALTER 给出 ALT.002 及行号
+	common.Config.Target = ""
+	checker := NewAlterChecker()
+	if rule := checker.Add("ALTER TABLE film ADD COLUMN score INT", 3); rule.Item != "" {
+		t.Errorf("first ALTER should not be reported: %v", rule)
+	}
+	rule := checker.Add("ALTER TABLE film DROP COLUMN rating", 7)
+	if rule.Item != "ALT.002" || !strings.Contains(rule.Content, "`film` 表在第 3, 7 行共有 2 条变更请求，建议合并为：ALTER TABLE `film` DROP COLUMN `rating`, ADD COLUMN `score` INT, ALGORITHM=INPLACE, LOCK=NONE;") ||
+		!strings.Contains(rule.Content, "合并后子句的执行顺序有调整") {
+		t.Errorf("unexpected ALT.002: %+v", rule)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestAlterAlgorithm(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	cases := []struct {
+		target string
+		sql    string
+		want   []int
+	}{
+		{"mysql:8.0.20", "ALTER TABLE t ADD COLUMN a INT, ADD COLUMN b INT AFTER a, DROP COLUMN c, RENAME COLUMN d TO e", []int{alterInstant, alterInplace, alterInplace, alterInplace}},
+		{"mysql:8.0.29", "ALTER TABLE t ADD COLUMN b INT FIRST, DROP COLUMN c, RENAME COLUMN d TO e, ALTER COLUMN f SET DEFAULT 1", []int{alterInstant, alterInstant, alterInstant, alterInstant}},
+		{"mariadb:10.4", "ALTER TABLE t ADD COLUMN id INT AUTO_INCREMENT, ADD COLUMN a INT UNIQUE, ADD FULLTEXT ft (b), ADD FOREIGN KEY (c) REFERENCES p (id)", []int{alterCopy, alterInplace, alterCopy, alterCopy}},
+		{"", "ALTER TABLE t DROP PRIMARY KEY, DROP INDEX idx, ENGINE = InnoDB, COMMENT = 'x'", []int{alterCopy, alterInplace, alterCopy, alterInplace}},
+		{"", "ALTER TABLE t DEFAULT CHARSET = utf8mb4", []int{alterInplace}},
+	}
+	for _, c := range cases {
+		common.Config.Target = c.target
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, stmt := range q.TiStmt {
+			for _, spec := range stmt.(*tidb.AlterTableStmt).Specs {
+				got = append(got, alterAlgorithm(spec))
+			}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SQL: %s, want: %v, got: %v", c.sql, c.want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestMetadataLockFix(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		activity database.TableActivity
+		want     string
+	}{
+		{database.TableActivity{RowOps: 10, LongTrx: 3}, ""},
+		{database.TableActivity{RowOps: 5000, LongTrx: 1}, "`film` 表平均每秒读写 5000 行，实例中有 1 个事务执行超过 60 秒。"},
+		{database.TableActivity{MDLWaits: 2, LongQueries: 1}, "`film` 表当前有 2 个请求正在等待元数据锁，当前有 1 个访问该表的请求执行超过 60 秒。"},
+	}
+	for _, c := range cases {
+		if got := metadataLockFix("film", &c.activity); got != c.want {
+			t.Errorf("want: %s, got: %s", c.want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
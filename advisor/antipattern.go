@@ -0,0 +1,219 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+)
+
+// eavAttributeRe EAV 表中保存属性名的列
+var eavAttributeRe = regexp.MustCompile(`(?i)^(attr|attribute|attr_name|attribute_name|attr_key|meta_key|key|property|property_name|prop|prop_name|field|field_name|param|param_name|name)$`)
+
+// eavValueRe EAV 表中保存属性值的列
+var eavValueRe = regexp.MustCompile(`(?i)^(value|val|attr_value|attribute_value|meta_value|property_value|prop_value|field_value|param_value)$`)
+
+// eavMaxColumns EAV 表的列通常很少，列数超过该值时不再按 EAV 判断
+const eavMaxColumns = 8
+
+// booleanNameRe 按命名判断为布尔含义的列
+var booleanNameRe = regexp.MustCompile(`(?i)^(is|has|can|enable|enabled|allow|need|use)_|_(flag|enabled)$`)
+
+// booleanColumnThreshold 单表中布尔列达到该数量时建议合并
+const booleanColumnThreshold = 8
+
+// columnNames 建表语句中的列，map[小写列名]*tidb.ColumnDef
+func columnNames(ct *tidb.CreateTableStmt) map[string]*tidb.ColumnDef {
+	cols := make(map[string]*tidb.ColumnDef)
+	for _, c := range ct.Cols {
+		if c.Tp != nil {
+			cols[c.Name.Name.L] = c
+		}
+	}
+	return cols
+}
+
+// foreignKeyColumns 建表语句中属于外键的列，列名为小写
+func foreignKeyColumns(ct *tidb.CreateTableStmt) map[string]bool {
+	fks := make(map[string]bool)
+	for _, cons := range ct.Constraints {
+		if cons.Tp != tidb.ConstraintForeignKey {
+			continue
+		}
+		for _, key := range cons.Keys {
+			fks[key.Column.Name.L] = true
+		}
+	}
+	return fks
+}
+
+// idColumnType 表中 id 列的类型，没有 id 列时返回占位符
+func idColumnType(cols map[string]*tidb.ColumnDef) string {
+	if c, ok := cols["id"]; ok {
+		return c.Tp.InfoSchemaStr()
+	}
+	return "<类型>"
+}
+
+// primaryKeyColumns 建表语句中的主键列，列名为小写
+func primaryKeyColumns(ct *tidb.CreateTableStmt) map[string]bool {
+	pk := make(map[string]bool)
+	for _, c := range ct.Cols {
+		for _, opt := range c.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey {
+				pk[c.Name.Name.L] = true
+			}
+		}
+	}
+	for _, cons := range ct.Constraints {
+		if cons.Tp != tidb.ConstraintPrimaryKey {
+			continue
+		}
+		for _, key := range cons.Keys {
+			pk[key.Column.Name.L] = true
+		}
+	}
+	return pk
+}
+
+// RuleEAVTable TBL.009
+// 表中只有实体 ID、属性名、属性值及少量辅助列时判断为 EAV 设计
+func (q *Query4Audit) RuleEAVTable() Rule {
+	var rule = q.RuleOK()
+	for _, node := range q.TiStmt {
+		ct, ok := node.(*tidb.CreateTableStmt)
+		if !ok || len(ct.Cols) > eavMaxColumns {
+			continue
+		}
+		pk := primaryKeyColumns(ct)
+		var entity, attr, value *tidb.ColumnDef
+		for _, c := range ct.Cols {
+			if c.Tp == nil {
+				continue
+			}
+			name := c.Name.Name.L
+			switch {
+			case eavValueRe.MatchString(name):
+				if columnTypeClass(c.Tp.InfoSchemaStr()) == "string" || c.Tp.Tp == mysql.TypeBlob {
+					value = c
+				}
+			case eavAttributeRe.MatchString(name):
+				attr = c
+			case entity == nil && strings.HasSuffix(name, "_id") && !pk[name]:
+				entity = c
+			}
+		}
+		if entity == nil || attr == nil || value == nil {
+			continue
+		}
+		parent := strings.TrimSuffix(entity.Name.Name.O, "_id")
+		rule = HeuristicRules["TBL.009"]
+		rule.Content += fmt.Sprintf(" `%s` 中的 `%s`, `%s`, `%s` 分别保存实体、属性名及属性值。", ct.Table.Name.O,
+			entity.Name.Name.O, attr.Name.Name.O, value.Name.Name.O)
+		rule.Content += fmt.Sprintf(" 属性固定时将每个属性作为 `%s` 表的一列: ALTER TABLE `%s` ADD COLUMN `<属性>` <类型> NOT NULL; "+
+			"属性确实不固定时使用 JSON 列，常用属性通过生成列添加索引: ALTER TABLE `%s` ADD COLUMN `attrs` JSON, "+
+			"ADD COLUMN `attrs_<属性>` VARCHAR(64) AS (attrs->>'$.<属性>') VIRTUAL, ADD INDEX `idx_attrs_<属性>` (`attrs_<属性>`);",
+			parent, parent, parent)
+	}
+	return rule
+}
+
+// RulePolymorphicAssociation TBL.010
+// xxx_type 与 xxx_id 成对出现且 xxx_id 没有外键约束时判断为多态关联
+func (q *Query4Audit) RulePolymorphicAssociation() Rule {
+	var rule = q.RuleOK()
+	for _, node := range q.TiStmt {
+		ct, ok := node.(*tidb.CreateTableStmt)
+		if !ok {
+			continue
+		}
+		cols := columnNames(ct)
+		fks := foreignKeyColumns(ct)
+		var pairs []string
+		var sketch []string
+		for _, c := range ct.Cols {
+			name := c.Name.Name.L
+			if c.Tp == nil || !strings.HasSuffix(name, "_type") {
+				continue
+			}
+			prefix := strings.TrimSuffix(name, "_type")
+			id, ok := cols[prefix+"_id"]
+			if !ok || fks[prefix+"_id"] {
+				continue
+			}
+			pairs = append(pairs, fmt.Sprintf("`%s`, `%s`", c.Name.Name.O, id.Name.Name.O))
+			sketch = append(sketch, fmt.Sprintf("CREATE TABLE `<类型>_%[1]s` (`<类型>_id` %[2]s NOT NULL, `%[1]s_id` %[3]s NOT NULL, "+
+				"PRIMARY KEY (`<类型>_id`, `%[1]s_id`), FOREIGN KEY (`<类型>_id`) REFERENCES `<类型>` (`id`), FOREIGN KEY (`%[1]s_id`) REFERENCES `%[1]s` (`id`));",
+				ct.Table.Name.O, id.Tp.InfoSchemaStr(), idColumnType(cols)))
+		}
+		if len(pairs) == 0 {
+			continue
+		}
+		rule = HeuristicRules["TBL.010"]
+		rule.Content += fmt.Sprintf(" `%s` 中的多态关联列: %s。 建议为每种被引用的类型建立带外键的交叉表，如: %s",
+			ct.Table.Name.O, strings.Join(pairs, "; "), strings.Join(sketch, " "))
+	}
+	return rule
+}
+
+// booleanColumn 列是否为布尔含义：BOOL, TINYINT(1), BIT(1) 或按命名为布尔含义的 TINYINT 列
+func booleanColumn(c *tidb.ColumnDef) bool {
+	switch c.Tp.Tp {
+	case mysql.TypeTiny:
+		return c.Tp.Flen == 1 || booleanNameRe.MatchString(c.Name.Name.L)
+	case mysql.TypeBit:
+		return c.Tp.Flen == 1
+	}
+	return false
+}
+
+// RuleBooleanColumns TBL.011
+func (q *Query4Audit) RuleBooleanColumns() Rule {
+	var rule = q.RuleOK()
+	for _, node := range q.TiStmt {
+		ct, ok := node.(*tidb.CreateTableStmt)
+		if !ok {
+			continue
+		}
+		var bools []string
+		for _, c := range ct.Cols {
+			if c.Tp != nil && booleanColumn(c) {
+				bools = append(bools, c.Name.Name.O)
+			}
+		}
+		if len(bools) < booleanColumnThreshold {
+			continue
+		}
+		members := make([]string, len(bools))
+		for i, b := range bools {
+			members[i] = "'" + strings.ToLower(b) + "'"
+		}
+		rule = HeuristicRules["TBL.011"]
+		rule.Content += fmt.Sprintf(" `%s` 中有 %d 个布尔列: %s。", ct.Table.Name.O, len(bools), strings.Join(bools, ", "))
+		if len(bools) <= 64 {
+			rule.Content += fmt.Sprintf(" 使用 SET 列保存: ALTER TABLE `%s` ADD COLUMN `flags` SET(%s) NOT NULL DEFAULT '', 查询时使用 FIND_IN_SET('<标记>', flags);",
+				ct.Table.Name.O, strings.Join(members, ","))
+		}
+		rule.Content += fmt.Sprintf(" 标记经常增加或需要按标记查询时使用关联表: CREATE TABLE `%[1]s_flag` (`%[1]s_id` %[2]s NOT NULL, `flag` VARCHAR(32) NOT NULL, "+
+			"PRIMARY KEY (`%[1]s_id`, `flag`), KEY `idx_flag` (`flag`), FOREIGN KEY (`%[1]s_id`) REFERENCES `%[1]s` (`id`));", ct.Table.Name.O, idColumnType(columnNames(ct)))
+	}
+	return rule
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// TBL.009
+func TestRuleEAVTable(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"CREATE TABLE product_attr (id BIGINT PRIMARY KEY, product_id BIGINT NOT NULL, attr_name VARCHAR(64) NOT NULL, attr_value VARCHAR(255))": "`product_attr` 中的 `product_id`, `attr_name`, `attr_value` 分别保存实体、属性名及属性值。 属性固定时将每个属性作为 `product` 表的一列",
+		"CREATE TABLE wp_usermeta (umeta_id BIGINT PRIMARY KEY, user_id BIGINT NOT NULL, meta_key VARCHAR(255), meta_value LONGTEXT)":            "`wp_usermeta` 中的 `user_id`, `meta_key`, `meta_value`",
+		"CREATE TABLE config (id INT PRIMARY KEY, name VARCHAR(64), value VARCHAR(255))":                                                         "",
+		"CREATE TABLE metric (id INT PRIMARY KEY, host_id INT, name VARCHAR(64), value DOUBLE)":                                                  "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleEAVTable()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "TBL.009" || !strings.Contains(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// TBL.010
+func TestRulePolymorphicAssociation(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"CREATE TABLE comment (id BIGINT PRIMARY KEY, commentable_type VARCHAR(32) NOT NULL, commentable_id INT NOT NULL, body TEXT)":             "`comment` 中的多态关联列: `commentable_type`, `commentable_id`。 建议为每种被引用的类型建立带外键的交叉表，如: CREATE TABLE `<类型>_comment` (`<类型>_id` int(11) NOT NULL, `comment_id` bigint(20) NOT NULL, PRIMARY KEY (`<类型>_id`, `comment_id`), FOREIGN KEY (`<类型>_id`) REFERENCES `<类型>` (`id`), FOREIGN KEY (`comment_id`) REFERENCES `comment` (`id`));",
+		"CREATE TABLE comment (id BIGINT PRIMARY KEY, target_type VARCHAR(32), target_id BIGINT, FOREIGN KEY (target_id) REFERENCES target (id))": "",
+		"CREATE TABLE comment (id BIGINT PRIMARY KEY, device_type VARCHAR(32), user_id BIGINT)":                                                   "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RulePolymorphicAssociation()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "TBL.010" || !strings.HasSuffix(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// TBL.011
+func TestRuleBooleanColumns(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	q, err := NewQuery4Audit(HeuristicRules["TBL.011"].Case)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := q.RuleBooleanColumns()
+	for _, want := range []string{
+		"`user` 中有 8 个布尔列: is_vip, is_admin, is_locked, is_deleted, has_avatar, has_phone, enable_sms, enable_mail。",
+		"ADD COLUMN `flags` SET('is_vip','is_admin','is_locked','is_deleted','has_avatar','has_phone','enable_sms','enable_mail') NOT NULL DEFAULT ''",
+		"CREATE TABLE `user_flag` (`user_id` bigint(20) NOT NULL,",
+	} {
+		if rule.Item != "TBL.011" || !strings.Contains(rule.Content, want) {
+			t.Errorf("want: %s, got: %s", want, rule.Content)
+		}
+	}
+
+	// BOOL, BIT(1) 及按命名判断为布尔含义的 TINYINT 列
+	q, err = NewQuery4Audit("CREATE TABLE t (id INT PRIMARY KEY, a BOOL, b BOOLEAN, c BIT(1), d TINYINT(1), is_e TINYINT, f_flag TINYINT UNSIGNED, g TINYINT(1), h TINYINT(1), status TINYINT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleBooleanColumns(); rule.Item != "TBL.011" || !strings.Contains(rule.Content, "有 8 个布尔列") {
+		t.Errorf("got: %s", rule.Content)
+	}
+	q, err = NewQuery4Audit("CREATE TABLE t (id INT PRIMARY KEY, is_a TINYINT(1), is_b TINYINT(1), status TINYINT, level TINYINT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleBooleanColumns(); rule.Item != "OK" {
+		t.Errorf("got: %s", rule.Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// ArchiveAdvisor 对超过 -archive-min-rows 或 -archive-min-size 的大表，根据业务 SQL 中的时间范围查询给出归档方案
+// 包括归档表建表语句，分批 INSERT ... SELECT / DELETE 脚本，以及 pt-archiver 命令行
+func ArchiveAdvisor(conn *database.Connector, buf string) map[string]Rule {
+	common.Log.Debug("Enter:  ArchiveAdvisor, Caller: %s", common.Caller())
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *conn
+	ruleMap := make(map[string]Rule)
+	number := 1
+
+	// 错误处理，用于汇总所有的错误
+	funcErrCheck := func(err error) {
+		if err != nil {
+			if sug, ok := ruleMap["ERR.003"]; ok {
+				sug.Content += fmt.Sprintf("; %s", err.Error())
+				ruleMap["ERR.003"] = sug
+			} else {
+				ruleMap["ERR.003"] = Rule{
+					Item:     "ERR.003",
+					Severity: "L8",
+					Content:  err.Error(),
+				}
+			}
+		}
+	}
+
+	_, queries, samples := parseWorkload(buf)
+
+	tables, err := tmpOnline.ShowTables()
+	if err != nil {
+		funcErrCheck(err)
+		return ruleMap
+	}
+	for _, tb := range tables {
+		status, err := tmpOnline.ShowTableStatus(tb)
+		if err != nil {
+			funcErrCheck(err)
+			continue
+		}
+		// 视图没有存储引擎
+		if len(status.Rows) == 0 || len(status.Rows[0].Engine) == 0 {
+			continue
+		}
+		ts := status.Rows[0]
+		size := (ts.DataLength + ts.IndexLength) / 1024 / 1024
+		if ts.Rows < common.Config.ArchiveMinRows && size < common.Config.ArchiveMinSize {
+			continue
+		}
+
+		desc, err := tmpOnline.ShowColumns(tb)
+		if err != nil {
+			funcErrCheck(err)
+			continue
+		}
+		idxInfo, err := tmpOnline.ShowIndex(tb)
+		if err != nil {
+			funcErrCheck(err)
+			continue
+		}
+
+		columns := make(map[string]bool)
+		for _, col := range desc.DescValues {
+			columns[strings.ToLower(col.Field)] = true
+		}
+		var preds []partitionPredicate
+		for i, stmt := range queries {
+			if p, ok := partitionPredicates(stmt, strings.ToLower(tb), columns); ok {
+				p.Query = samples[i]
+				preds = append(preds, p)
+			}
+		}
+
+		rule := archivePlan(tmpOnline.Database, tb, ts.Rows, size, desc, idxInfo, preds)
+		key := fmt.Sprintf("ARC.%03d", number)
+		rule.Item = key
+		ruleMap[key] = rule
+		number++
+	}
+	return ruleMap
+}
+
+// archivePlan 生成单表的归档方案，归档条件为业务 SQL 中范围查询使用最多的时间类型列
+func archivePlan(db, tb string, rows, size uint64, desc *database.TableDesc, idxInfo *database.TableIndexInfo, preds []partitionPredicate) Rule {
+	rule := Rule{
+		Severity: "L2",
+		Summary:  fmt.Sprintf("%s.%s 数据量较大，建议归档历史数据", db, tb),
+	}
+	content := []string{fmt.Sprintf("表中约 %d 行数据，数据及索引大小约 %d MB", rows, size)}
+
+	// 时间序列访问模式：业务 SQL 中对时间类型列使用了范围条件
+	var archiveCol string
+	maxCount := 0
+	for _, col := range desc.DescValues {
+		switch strings.ToLower(common.GetDataTypeBase(col.Type)) {
+		case "date", "datetime", "timestamp":
+			count := 0
+			for _, p := range preds {
+				if p.Range[strings.ToLower(col.Field)] {
+					count++
+				}
+			}
+			if count > maxCount {
+				archiveCol, maxCount = col.Field, count
+			}
+		}
+	}
+	if archiveCol == "" {
+		content = append(content, fmt.Sprintf("输入的 %d 条 SQL 中没有对时间类型列的范围查询，无法确定归档条件，请结合业务确认哪些数据可以归档", len(preds)))
+		rule.Content = strings.Join(content, "; ")
+		return rule
+	}
+	content = append(content, fmt.Sprintf("输入的 %d 条 SQL 中有 %d 条按 %s 进行范围查询，建议只在线上保留最近 %d 天的数据", len(preds), maxCount, archiveCol, common.Config.ArchiveKeepDays))
+
+	// 主键及归档列上的索引
+	var pk []string
+	indexed := false
+	if idxInfo != nil {
+		for _, row := range idxInfo.Rows {
+			if row.KeyName == "PRIMARY" {
+				pk = append(pk, row.ColumnName)
+			}
+			if row.SeqInIndex == 1 && strings.EqualFold(row.ColumnName, archiveCol) {
+				indexed = true
+			}
+		}
+	}
+	if !indexed {
+		rule.Severity = "L4"
+		content = append(content, fmt.Sprintf("%s 列上没有索引，每批次归档都会扫描全表，建议先添加索引", archiveCol))
+	}
+
+	where := fmt.Sprintf("`%s` < DATE_SUB(CURDATE(), INTERVAL %d DAY)", archiveCol, common.Config.ArchiveKeepDays)
+	source := fmt.Sprintf("`%s`.`%s`", db, tb)
+	dest := fmt.Sprintf("`%s`.`%s_archive`", db, tb)
+	script := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s;", dest, source)}
+	if len(pk) == 1 {
+		// 按主键分批，每批次先确定主键上界，再复制和删除同一批数据
+		script = append(script,
+			"-- 循环执行以下事务，直到 @archive_id 为 NULL",
+			"START TRANSACTION;",
+			fmt.Sprintf("SELECT MAX(`%s`) INTO @archive_id FROM (SELECT `%s` FROM %s WHERE %s ORDER BY `%s` LIMIT %d) AS t;",
+				pk[0], pk[0], source, where, pk[0], common.Config.ArchiveChunkSize),
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s WHERE `%s` <= @archive_id AND %s;", dest, source, pk[0], where),
+			fmt.Sprintf("DELETE FROM %s WHERE `%s` <= @archive_id AND %s;", source, pk[0], where),
+			"COMMIT;",
+		)
+	} else {
+		rule.Severity = "L4"
+		content = append(content, "表没有单列主键，无法按主键分批归档，建议使用 pt-archiver")
+	}
+
+	// pt-archiver 命令行，密码通过 --ask-pass 交互输入
+	host, port, err := net.SplitHostPort(common.Config.OnlineDSN.Addr)
+	if err != nil {
+		host, port = common.Config.OnlineDSN.Addr, "3306"
+	}
+	content = append(content, fmt.Sprintf(`也可以使用 pt-archiver 归档：pt-archiver --source h=%s,P=%s,u=%s,D=%s,t=%s --dest t=%s_archive --ask-pass --where "%s < DATE_SUB(CURDATE(), INTERVAL %d DAY)" --limit %d --txn-size %d --progress %d --statistics`,
+		host, port, common.Config.OnlineDSN.User, db, tb, tb, archiveCol, common.Config.ArchiveKeepDays,
+		common.Config.ArchiveChunkSize, common.Config.ArchiveChunkSize, common.Config.ArchiveChunkSize*10))
+
+	rule.Content = strings.Join(content, "; ")
+	rule.Case = strings.Join(script, "\n")
+	return rule
+}
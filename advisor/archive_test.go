@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestArchivePlan(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	desc := &database.TableDesc{
+		Name: "orders",
+		DescValues: []database.TableDescValue{
+			{Field: "id", Type: "bigint(20)"},
+			{Field: "uid", Type: "int(11)"},
+			{Field: "created_at", Type: "datetime"},
+		},
+	}
+	idx := &database.TableIndexInfo{
+		TableName: "orders",
+		Rows: []database.TableIndexRow{
+			{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"},
+			{KeyName: "idx_created_at", NonUnique: 1, SeqInIndex: 1, ColumnName: "created_at"},
+		},
+	}
+	_, queries, samples := parseWorkload(`SELECT * FROM orders WHERE created_at > '2019-01-01';
+SELECT * FROM orders WHERE uid = 1 AND created_at BETWEEN '2019-01-01' AND '2019-02-01';
+SELECT * FROM orders WHERE uid = 1;`)
+	columns := map[string]bool{"id": true, "uid": true, "created_at": true}
+	var preds []partitionPredicate
+	for i, stmt := range queries {
+		if p, ok := partitionPredicates(stmt, "orders", columns); ok {
+			p.Query = samples[i]
+			preds = append(preds, p)
+		}
+	}
+
+	rule := archivePlan("sakila", "orders", 20000000, 4096, desc, idx, preds)
+	if rule.Severity != "L2" {
+		t.Errorf("want severity L2, got %s", rule.Severity)
+	}
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS `sakila`.`orders_archive` LIKE `sakila`.`orders`;",
+		"SELECT MAX(`id`) INTO @archive_id FROM (SELECT `id` FROM `sakila`.`orders` WHERE `created_at` < DATE_SUB(CURDATE(), INTERVAL 180 DAY) ORDER BY `id` LIMIT 1000) AS t;",
+		"DELETE FROM `sakila`.`orders` WHERE `id` <= @archive_id AND `created_at` < DATE_SUB(CURDATE(), INTERVAL 180 DAY);",
+	} {
+		if !strings.Contains(rule.Case, want) {
+			t.Errorf("want: %s, got:\n%s", want, rule.Case)
+		}
+	}
+	if !strings.Contains(rule.Content, "有 2 条按 created_at 进行范围查询") || !strings.Contains(rule.Content, "pt-archiver --source") {
+		t.Errorf("unexpected content: %s", rule.Content)
+	}
+
+	// 没有时间范围查询时无法确定归档条件
+	rule = archivePlan("sakila", "orders", 20000000, 4096, desc, idx, preds[2:])
+	if rule.Case != "" || !strings.Contains(rule.Content, "无法确定归档条件") {
+		t.Errorf("unexpected plan: %v", rule)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
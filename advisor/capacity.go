@@ -0,0 +1,376 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// mysqlMaxPartitions MySQL 单表最多支持的分区数（包括子分区）
+const mysqlMaxPartitions = 8192
+
+// capacityHistoryHeader -capacity-history 文件的表头
+var capacityHistoryHeader = []string{"time", "database", "table", "rows", "data_length", "index_length", "auto_increment", "auto_increment_column", "auto_increment_type", "partitions"}
+
+// capacityTimeFormats 历史快照中支持的时间格式
+var capacityTimeFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// capacityForecast 单个指标的增长趋势
+type capacityForecast struct {
+	Current float64 // 最近一次快照中的值，At 为该快照的时间
+	Slope   float64 // 每天的增长量
+	Limit   float64 // 阈值
+	Days    float64 // 预计多少天后超过阈值，已超过时为 0
+	At      time.Time
+}
+
+// CapacitySnapshot 读取 -capacity-history 中的历史快照，连接线上环境时将当前 database 中所有表的快照追加至该文件
+func CapacitySnapshot(conn *database.Connector, file string) ([]database.TableSnapshot, error) {
+	var history []database.TableSnapshot
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		history, err = LoadCapacityHistory(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if common.Config.OnlineDSN.Disable {
+		return history, nil
+	}
+
+	snaps, err := conn.TableSnapshots()
+	if err != nil {
+		return history, err
+	}
+	history = append(history, snaps...)
+	if file == "" {
+		return history, nil
+	}
+
+	fd, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return history, err
+	}
+	defer fd.Close()
+	stat, err := fd.Stat()
+	if err != nil {
+		return history, err
+	}
+	_, err = fd.Write(FormatCapacityHistory(snaps, stat.Size() == 0))
+	return history, err
+}
+
+// LoadCapacityHistory 解析 CSV 格式的历史快照，每行的格式与 capacityHistoryHeader 一致，时间无法解析的行（如表头）会被忽略
+func LoadCapacityHistory(data []byte) ([]database.TableSnapshot, error) {
+	var history []database.TableSnapshot
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 6 {
+			continue
+		}
+		snap := database.TableSnapshot{
+			Database: strings.TrimSpace(record[1]),
+			Table:    strings.TrimSpace(record[2]),
+		}
+		for _, layout := range capacityTimeFormats {
+			if snap.Time, err = time.ParseInLocation(layout, strings.TrimSpace(record[0]), time.Local); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+
+		// 自增列及分区数可以省略
+		for len(record) < len(capacityHistoryHeader) {
+			record = append(record, "")
+		}
+		for i, dest := range []*uint64{&snap.Rows, &snap.DataLength, &snap.IndexLength, &snap.AutoIncrement} {
+			val := strings.TrimSpace(record[i+3])
+			if val == "" {
+				continue
+			}
+			if *dest, err = strconv.ParseUint(val, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s %q for %s.%s", capacityHistoryHeader[i+3], val, snap.Database, snap.Table)
+			}
+		}
+		snap.AutoIncrementCol = strings.TrimSpace(record[7])
+		snap.AutoIncrementType = strings.TrimSpace(record[8])
+		if val := strings.TrimSpace(record[9]); val != "" {
+			if snap.Partitions, err = strconv.Atoi(val); err != nil {
+				return nil, fmt.Errorf("invalid partitions %q for %s.%s", val, snap.Database, snap.Table)
+			}
+		}
+		history = append(history, snap)
+	}
+	return history, nil
+}
+
+// FormatCapacityHistory 将快照格式化为 CSV，header 为 true 时输出表头
+func FormatCapacityHistory(snaps []database.TableSnapshot, header bool) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if header {
+		common.LogIfWarn(w.Write(capacityHistoryHeader), "")
+	}
+	for _, s := range snaps {
+		common.LogIfWarn(w.Write([]string{
+			s.Time.Format(time.RFC3339), s.Database, s.Table,
+			strconv.FormatUint(s.Rows, 10), strconv.FormatUint(s.DataLength, 10), strconv.FormatUint(s.IndexLength, 10),
+			strconv.FormatUint(s.AutoIncrement, 10), s.AutoIncrementCol, s.AutoIncrementType, strconv.Itoa(s.Partitions),
+		}), "")
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// CapacityAdvisor 根据历史快照拟合每张表的增长趋势，对 -capacity-horizon 天内数据大小、分区数或自增值将超过阈值的表给出警告
+// 至少需要两个不同时间的快照才能拟合增长趋势
+func CapacityAdvisor(history []database.TableSnapshot) map[string]Rule {
+	common.Log.Debug("Enter:  CapacityAdvisor, Caller: %s", common.Caller())
+	ruleMap := make(map[string]Rule)
+	number := 1
+
+	tables := make(map[string][]database.TableSnapshot)
+	totals := make(map[string]map[time.Time]*database.TableSnapshot)
+	for _, snap := range history {
+		key := fmt.Sprintf("`%s`.`%s`", snap.Database, snap.Table)
+		tables[key] = append(tables[key], snap)
+
+		// 按快照时间汇总整个 database 的大小，用于与 -capacity-disk-size 比较
+		if totals[snap.Database] == nil {
+			totals[snap.Database] = make(map[time.Time]*database.TableSnapshot)
+		}
+		total, ok := totals[snap.Database][snap.Time]
+		if !ok {
+			total = &database.TableSnapshot{Time: snap.Time, Database: snap.Database}
+			totals[snap.Database][snap.Time] = total
+		}
+		total.Rows += snap.Rows
+		total.DataLength += snap.DataLength
+		total.IndexLength += snap.IndexLength
+	}
+
+	var keys []string
+	for key := range tables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var dbs []string
+	for db := range totals {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+
+	add := func(rule Rule, ok bool) {
+		if !ok {
+			return
+		}
+		key := fmt.Sprintf("CAP.%03d", number)
+		rule.Item = key
+		ruleMap[key] = rule
+		number++
+	}
+	if common.Config.CapacityDiskSize > 0 {
+		for _, db := range dbs {
+			var snaps []database.TableSnapshot
+			for _, total := range totals[db] {
+				snaps = append(snaps, *total)
+			}
+			add(databaseCapacityPlan(db, snaps))
+		}
+	}
+	for _, key := range keys {
+		add(tableCapacityPlan(key, tables[key]))
+	}
+	return ruleMap
+}
+
+// sortSnapshots 按时间排序，返回每个快照距第一个快照的天数
+func sortSnapshots(snaps []database.TableSnapshot) []float64 {
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].Time.Before(snaps[j].Time)
+	})
+	days := make([]float64, len(snaps))
+	for i, snap := range snaps {
+		days[i] = snap.Time.Sub(snaps[0].Time).Hours() / 24
+	}
+	return days
+}
+
+// linearFit 最小二乘法拟合 y = slope * x + intercept，x 的取值都相同时无法拟合
+func linearFit(xs, ys []float64) (float64, float64, bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0, 0, false
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denominator := n*sumXX - sumX*sumX
+	if math.Abs(denominator) < 1e-9 {
+		return 0, 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	return slope, (sumY - slope*sumX) / n, true
+}
+
+// forecastCapacity 根据增长趋势预测指标超过阈值的时间，-capacity-horizon 天内不会超过阈值时返回 false
+// 已超过阈值时即使只有一个快照也返回 true
+func forecastCapacity(at time.Time, days, values []float64, limit float64) (capacityForecast, bool) {
+	f := capacityForecast{Current: values[len(values)-1], Limit: limit, At: at}
+	if limit <= 0 {
+		return f, false
+	}
+	slope, _, ok := linearFit(days, values)
+	f.Slope = slope
+	if f.Current >= limit {
+		return f, true
+	}
+	if !ok || slope <= 0 {
+		return f, false
+	}
+	f.Days = (limit - f.Current) / slope
+	return f, f.Days <= float64(common.Config.CapacityHorizon)
+}
+
+// capacitySeverity 已超过阈值为 L4，-capacity-horizon 的三分之一时间内超过阈值为 L3，否则为 L2
+func capacitySeverity(days float64) string {
+	switch {
+	case days <= 0:
+		return "L4"
+	case days <= float64(common.Config.CapacityHorizon)/3:
+		return "L3"
+	default:
+		return "L2"
+	}
+}
+
+// capacityETA 超过阈值的时间描述
+func capacityETA(f capacityForecast) string {
+	if f.Days <= 0 {
+		return "已超过"
+	}
+	return fmt.Sprintf("预计 %.0f 天后（%s）超过", math.Ceil(f.Days), f.At.AddDate(0, 0, int(math.Ceil(f.Days))).Format("2006-01-02"))
+}
+
+// databaseCapacityPlan 整个 database 的数据及索引大小超过 -capacity-disk-size 的预测
+func databaseCapacityPlan(db string, snaps []database.TableSnapshot) (Rule, bool) {
+	days := sortSnapshots(snaps)
+	var sizes []float64
+	for _, snap := range snaps {
+		sizes = append(sizes, float64(snap.DataLength+snap.IndexLength)/1024/1024)
+	}
+	f, ok := forecastCapacity(snaps[len(snaps)-1].Time, days, sizes, float64(common.Config.CapacityDiskSize))
+	if !ok {
+		return Rule{}, false
+	}
+	return Rule{
+		Severity: capacitySeverity(f.Days),
+		Summary:  fmt.Sprintf("%s 库的磁盘空间即将不足", db),
+		Content: fmt.Sprintf("%s 库数据及索引大小约 %.0f MB，平均每天增长 %.1f MB，%s -capacity-disk-size %d MB; 请提前扩容磁盘，或对大表进行归档（-report-type archive）、压缩（-report-type compression）",
+			db, f.Current, f.Slope, capacityETA(f), common.Config.CapacityDiskSize),
+	}, true
+}
+
+// tableCapacityPlan 单表的数据大小、分区数及自增值超过阈值的预测，tb 为已转义的库表名
+func tableCapacityPlan(tb string, snaps []database.TableSnapshot) (Rule, bool) {
+	days := sortSnapshots(snaps)
+	last := snaps[len(snaps)-1]
+	var sizes, rows, partitions, autoIncs []float64
+	for _, snap := range snaps {
+		sizes = append(sizes, float64(snap.DataLength+snap.IndexLength)/1024/1024)
+		rows = append(rows, float64(snap.Rows))
+		partitions = append(partitions, float64(snap.Partitions))
+		autoIncs = append(autoIncs, float64(snap.AutoIncrement))
+	}
+
+	var content, script []string
+	minDays := math.MaxFloat64
+	warn := func(f capacityForecast, msg string) {
+		content = append(content, msg)
+		minDays = math.Min(minDays, f.Days)
+	}
+
+	if f, ok := forecastCapacity(last.Time, days, sizes, float64(common.Config.CapacityMaxSize)); ok {
+		warn(f, fmt.Sprintf("数据及索引大小约 %.0f MB，平均每天增长 %.1f MB，%s -capacity-max-size %d MB，建议归档历史数据（-report-type archive）或对表进行分区",
+			f.Current, f.Slope, capacityETA(f), common.Config.CapacityMaxSize))
+	}
+	if last.Partitions > 0 {
+		if f, ok := forecastCapacity(last.Time, days, partitions, mysqlMaxPartitions); ok {
+			warn(f, fmt.Sprintf("当前有 %d 个分区，平均每天增加 %.1f 个，%s单表 %d 个分区的上限，请清理或合并历史分区",
+				last.Partitions, f.Slope, capacityETA(f), mysqlMaxPartitions))
+		}
+	}
+	// 没有自增列时 AutoIncrementType 为空
+	max, isInt := uint64(0), false
+	if last.AutoIncrementCol != "" && last.AutoIncrement > 0 {
+		max, isInt = autoIncrementMax(last.AutoIncrementType)
+	}
+	if isInt {
+		limit := float64(max) * common.Config.MaxAutoIncRatio
+		if f, ok := forecastCapacity(last.Time, days, autoIncs, limit); ok {
+			warn(f, fmt.Sprintf("%s 列 AUTO_INCREMENT 当前为 %d，平均每天增长 %.0f，%s %s 最大值 %d 的 %.0f%%（-max-auto-inc-ratio），修改列类型需要重建表，请提前安排",
+				last.AutoIncrementCol, last.AutoIncrement, f.Slope, capacityETA(f), last.AutoIncrementType, max, common.Config.MaxAutoIncRatio*100))
+			// 自增列通常为主键，不允许为 NULL
+			inc := database.AutoIncrementInfo{Table: last.Table, Column: last.AutoIncrementCol, ColumnType: last.AutoIncrementType, Nullable: "NO", AutoIncrement: last.AutoIncrement}
+			if def := autoIncrementAlter(tb, inc); def != "" {
+				script = append(script, def)
+			}
+		}
+	}
+	if len(content) == 0 {
+		return Rule{}, false
+	}
+	if slope, _, ok := linearFit(days, rows); ok && last.Rows > 0 {
+		content = append(content, fmt.Sprintf("表中约 %d 行数据，平均每天增长 %.0f 行", last.Rows, slope))
+	}
+	return Rule{
+		Severity: capacitySeverity(minDays),
+		Summary:  fmt.Sprintf("%s 预计 %d 天内容量超限", tb, common.Config.CapacityHorizon),
+		Content:  strings.Join(content, "; "),
+		Case:     strings.Join(script, "\n"),
+	}, true
+}
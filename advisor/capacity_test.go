@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestLoadCapacityHistory(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	history, err := LoadCapacityHistory([]byte(`time,database,table,rows,data_length,index_length
+2019-01-01,sakila,film,1000,1048576,524288
+2019-01-02 12:00:00,sakila,film,1100,2097152,524288,1101,film_id,"smallint(5) unsigned",0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].DataLength != 1048576 || history[0].AutoIncrementType != "" ||
+		history[1].AutoIncrement != 1101 || history[1].AutoIncrementType != "smallint(5) unsigned" ||
+		history[1].Time.Hour() != 12 {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	// 写入后再读取，内容保持不变
+	data := FormatCapacityHistory(history, true)
+	if !strings.HasPrefix(string(data), "time,database,table,rows,data_length,index_length,auto_increment,auto_increment_column,auto_increment_type,partitions\n") {
+		t.Errorf("unexpected csv: %s", data)
+	}
+	loaded, err := LoadCapacityHistory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range loaded {
+		if !loaded[i].Time.Equal(history[i].Time) {
+			t.Errorf("time mismatch: %v, %v", loaded[i].Time, history[i].Time)
+		}
+		loaded[i].Time = history[i].Time
+	}
+	if !reflect.DeepEqual(loaded, history) {
+		t.Errorf("want: %+v, got: %+v", history, loaded)
+	}
+
+	if _, err = LoadCapacityHistory([]byte("2019-01-01,sakila,film,abc,0,0")); err == nil {
+		t.Error("invalid rows should return error")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestLinearFit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	slope, intercept, ok := linearFit([]float64{0, 1, 2, 3}, []float64{10, 12, 14, 16})
+	if !ok || math.Abs(slope-2) > 1e-9 || math.Abs(intercept-10) > 1e-9 {
+		t.Errorf("want slope 2, intercept 10, got %f, %f", slope, intercept)
+	}
+	if _, _, ok = linearFit([]float64{1, 1}, []float64{10, 12}); ok {
+		t.Error("same x should not fit")
+	}
+	if _, _, ok = linearFit([]float64{1}, []float64{10}); ok {
+		t.Error("single point should not fit")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCapacityAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgMaxSize, orgDiskSize := common.Config.CapacityMaxSize, common.Config.CapacityDiskSize
+	common.Config.CapacityMaxSize = 1024
+	common.Config.CapacityDiskSize = 2048
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local)
+	var history []database.TableSnapshot
+	for day := 0; day < 10; day++ {
+		at := start.AddDate(0, 0, day)
+		history = append(history,
+			// 每天增长 10MB，当前 990MB，4 天后超过 1024MB
+			database.TableSnapshot{Time: at, Database: "sakila", Table: "logs", Rows: uint64(1000000 + day*10000),
+				DataLength: uint64(900+day*10) << 20},
+			// 自增值每天增长 1000，约 24 天后达到 smallint unsigned 最大值的 80%
+			database.TableSnapshot{Time: at, Database: "sakila", Table: "film", Rows: uint64(20000 + day*1000),
+				DataLength: 10 << 20, AutoIncrement: uint64(20000 + day*1000), AutoIncrementCol: "film_id", AutoIncrementType: "smallint(5) unsigned"},
+			// 每天增加 1 个分区
+			database.TableSnapshot{Time: at, Database: "sakila", Table: "events", DataLength: 1 << 20, Partitions: 8100 + day},
+			// 没有增长
+			database.TableSnapshot{Time: at, Database: "sakila", Table: "actor", Rows: 200, DataLength: 1 << 20},
+		)
+	}
+
+	suggest := CapacityAdvisor(history)
+	if len(suggest) != 3 {
+		t.Fatalf("want 3 suggestions, got %d: %v", len(suggest), suggest)
+	}
+	// 整个 database 每天增长约 10MB，当前约 1002MB，不会在 90 天内超过 2048MB
+	if rule := suggest["CAP.001"]; !strings.Contains(rule.Summary, "`sakila`.`events`") ||
+		!strings.Contains(rule.Content, "当前有 8109 个分区，平均每天增加 1.0 个，预计 83 天后（2019-04-03）超过单表 8192 个分区的上限") {
+		t.Errorf("unexpected events suggestion: %+v", rule)
+	}
+	if rule := suggest["CAP.002"]; !strings.Contains(rule.Summary, "`sakila`.`film`") || rule.Severity != "L3" ||
+		rule.Case != "ALTER TABLE `sakila`.`film` MODIFY `film_id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT;" {
+		t.Errorf("unexpected film suggestion: %+v", rule)
+	}
+	if rule := suggest["CAP.003"]; !strings.Contains(rule.Summary, "`sakila`.`logs`") || rule.Severity != "L3" ||
+		!strings.Contains(rule.Content, "数据及索引大小约 990 MB，平均每天增长 10.0 MB，预计 4 天后（2019-01-14）超过") ||
+		!strings.Contains(rule.Content, "平均每天增长 10000 行") {
+		t.Errorf("unexpected logs suggestion: %+v", rule)
+	}
+
+	// 磁盘空间不足
+	common.Config.CapacityDiskSize = 1024
+	suggest = CapacityAdvisor(history)
+	if rule := suggest["CAP.001"]; rule.Summary != "sakila 库的磁盘空间即将不足" {
+		t.Errorf("unexpected disk suggestion: %+v", rule)
+	}
+
+	// 只有一个快照时无法预测
+	if suggest = CapacityAdvisor(history[:4]); len(suggest) != 0 {
+		t.Errorf("single snapshot should not be forecast: %v", suggest)
+	}
+	common.Config.CapacityMaxSize, common.Config.CapacityDiskSize = orgMaxSize, orgDiskSize
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// RuleCartesianProduct JOI.009
+// 根据 JOIN 关系图检查缺少关联条件的表，离线表结构中有外键时给出可能遗漏的关联条件
+func (q *Query4Audit) RuleCartesianProduct() Rule {
+	rule := q.RuleOK()
+	graph := ast.NewJoinGraph(q.Query)
+	if graph == nil || !graph.HasMissing() {
+		return rule
+	}
+
+	rule = HeuristicRules["JOI.009"]
+	fixes := cartesianFixes(graph, offlineForeignKeys, nil)
+	rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	return rule
+}
+
+// RuleCartesianProduct JOI.009
+// 结合线上的外键定义给出关联条件，并根据表的统计行数估算笛卡尔积的结果集大小
+func (idxAdv *IndexAdvisor) RuleCartesianProduct() Rule {
+	rule := HeuristicRules["OK"]
+	if common.Config.OnlineDSN.Disable || idxAdv.Ast == nil {
+		return rule
+	}
+	graph := ast.NewJoinGraph(sqlparser.String(idxAdv.Ast))
+	if graph == nil || !graph.HasMissing() {
+		return rule
+	}
+
+	fks := make(map[string][]foreignKey)
+	for tb, keys := range offlineForeignKeys {
+		fks[tb] = keys
+	}
+	rows := make(map[string]uint64)
+	for _, t := range graph.Tables {
+		if t.Name == "" {
+			continue
+		}
+		conn := idxAdv.rEnv
+		if t.Schema != "" {
+			conn.Database = t.Schema
+		}
+		status, err := conn.ShowTableStatus(t.Name)
+		if err != nil {
+			common.Log.Warn("RuleCartesianProduct ShowTableStatus Error: %v", err)
+			continue
+		}
+		// 视图没有存储引擎，统计行数没有意义
+		if len(status.Rows) > 0 && len(status.Rows[0].Engine) > 0 {
+			rows[t.Key] = status.Rows[0].Rows
+		}
+		cols, err := conn.ShowForeignKeys(t.Name)
+		if err != nil {
+			common.Log.Warn("RuleCartesianProduct ShowForeignKeys Error: %v", err)
+			continue
+		}
+		if len(cols) > 0 {
+			fks[strings.ToLower(t.Name)] = onlineForeignKeys(cols)
+		}
+	}
+
+	rule = HeuristicRules["JOI.009"]
+	fixes := cartesianFixes(graph, fks, rows)
+	rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	return rule
+}
+
+// onlineForeignKeys 将线上按列返回的外键信息合并为外键关系
+func onlineForeignKeys(cols []database.ForeignKeyColumn) []foreignKey {
+	var fks []foreignKey
+	var name string
+	for _, col := range cols {
+		if len(fks) == 0 || col.ConstraintName != name {
+			name = col.ConstraintName
+			fks = append(fks, foreignKey{
+				Table:    strings.ToLower(col.TableName),
+				RefTable: strings.ToLower(col.ReferencedTableName),
+			})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, strings.ToLower(col.ColumnName))
+		fk.RefColumns = append(fk.RefColumns, strings.ToLower(col.ReferencedColumnName))
+	}
+	return fks
+}
+
+// cartesianFixes 逐个列出缺少关联条件的表，rows 不为空时估算结果集的大小
+func cartesianFixes(graph *ast.JoinGraph, fks map[string][]foreignKey, rows map[string]uint64) []string {
+	components := graph.Components()
+	index := make(map[string]int)
+	for i, c := range components {
+		for _, t := range c {
+			index[t.Key] = i
+		}
+	}
+
+	var fixes []string
+	for _, e := range graph.Edges {
+		if !e.Missing {
+			continue
+		}
+		cond := foreignKeyCondition(components[index[e.Left]], components[index[e.Right]], fks)
+		if cond == "" {
+			fixes = append(fixes, fmt.Sprintf("`%s` 与 `%s` 之间缺少关联条件。", e.Left, e.Right))
+		} else {
+			fixes = append(fixes, fmt.Sprintf("`%s` 与 `%s` 之间缺少关联条件，根据外键定义可能遗漏了 `%s`。", e.Left, e.Right, cond))
+		}
+	}
+	if estimate := cartesianEstimate(components, rows); estimate != "" {
+		fixes = append(fixes, estimate)
+	}
+	return fixes
+}
+
+// foreignKeyCondition 两组表之间存在外键时，返回外键对应的关联条件
+func foreignKeyCondition(left, right []ast.JoinGraphTable, fks map[string][]foreignKey) string {
+	condition := func(child, parent ast.JoinGraphTable) string {
+		for _, fk := range fks[strings.ToLower(child.Name)] {
+			if fk.RefTable != strings.ToLower(parent.Name) {
+				continue
+			}
+			var conds []string
+			for i := range fk.Columns {
+				conds = append(conds, fmt.Sprintf("%s.%s = %s.%s", child.Key, fk.Columns[i], parent.Key, fk.RefColumns[i]))
+			}
+			return strings.Join(conds, " AND ")
+		}
+		return ""
+	}
+	for _, l := range left {
+		for _, r := range right {
+			if l.Name == "" || r.Name == "" {
+				continue
+			}
+			if cond := condition(l, r); cond != "" {
+				return cond
+			}
+			if cond := condition(r, l); cond != "" {
+				return cond
+			}
+		}
+	}
+	return ""
+}
+
+// cartesianEstimate 根据表的统计行数估算笛卡尔积的结果集大小
+// 组内的表有关联条件，以组内行数最多的表作为该组的行数，各组之间相乘；有任何一张表的行数未知时不做估算
+func cartesianEstimate(components [][]ast.JoinGraphTable, rows map[string]uint64) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	total := float64(1)
+	var parts []string
+	for _, c := range components {
+		var keys []string
+		var max uint64
+		for _, t := range c {
+			n, ok := rows[t.Key]
+			if !ok {
+				return ""
+			}
+			if n > max {
+				max = n
+			}
+			keys = append(keys, "`"+t.Key+"`")
+		}
+		total *= float64(max)
+		parts = append(parts, fmt.Sprintf("%s %d 行", strings.Join(keys, ", "), max))
+	}
+	return fmt.Sprintf("按表的统计行数估算，结果集约 %s 行（%s）。",
+		strconv.FormatFloat(total, 'f', 0, 64), strings.Join(parts, " × "))
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// JOI.009
+func TestRuleCartesianProduct(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSchema, orgForeignKeys := offlineSchema, offlineForeignKeys
+	defer func() { offlineSchema, offlineForeignKeys = orgSchema, orgForeignKeys }()
+	offlineSchema = make(map[string]map[string]*common.Column)
+	offlineForeignKeys = make(map[string][]foreignKey)
+
+	LoadOfflineSchema("CREATE TABLE `film_actor` (`actor_id` int NOT NULL, `film_id` int NOT NULL, " +
+		"CONSTRAINT `fk_film_actor_film` FOREIGN KEY (`film_id`) REFERENCES `film` (`film_id`));")
+
+	sqls := map[string][]string{
+		"SELECT * FROM film f, actor a WHERE f.release_year = 2006":                        {"`f` 与 `a` 之间缺少关联条件。"},
+		"SELECT * FROM actor a JOIN film_actor fa ON a.actor_id = fa.actor_id JOIN film f": {"可能遗漏了 `fa.film_id = f.film_id`"},
+		"SELECT * FROM film f, film_actor fa WHERE f.film_id = fa.film_id":                 nil,
+		"SELECT * FROM film f JOIN language l USING (language_id)":                         nil,
+		"SELECT * FROM film": nil,
+	}
+	for sql, wants := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleCartesianProduct()
+		if len(wants) == 0 {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, want OK, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "JOI.009" {
+			t.Errorf("SQL: %s, want JOI.009, got: %s", sql, rule.Item)
+			continue
+		}
+		for _, want := range wants {
+			if !strings.Contains(rule.Content, want) {
+				t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCartesianEstimate(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	graph := ast.NewJoinGraph("SELECT * FROM film f JOIN film_actor fa ON f.film_id = fa.film_id, actor a")
+	components := graph.Components()
+	rows := map[string]uint64{"f": 1000, "fa": 5462, "a": 200}
+	want := "按表的统计行数估算，结果集约 1092400 行（`f`, `fa` 5462 行 × `a` 200 行）。"
+	if got := cartesianEstimate(components, rows); got != want {
+		t.Errorf("want: %s\ngot: %s", want, got)
+	}
+
+	// 有表的行数未知时不做估算
+	delete(rows, "a")
+	if got := cartesianEstimate(components, rows); got != "" {
+		t.Errorf("want empty, got: %s", got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestOnlineForeignKeys(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	fks := onlineForeignKeys([]database.ForeignKeyColumn{
+		{ConstraintName: "fk_a", TableName: "Rental", ColumnName: "store_id", ReferencedTableName: "inventory", ReferencedColumnName: "store_id"},
+		{ConstraintName: "fk_a", TableName: "Rental", ColumnName: "film_id", ReferencedTableName: "inventory", ReferencedColumnName: "film_id"},
+		{ConstraintName: "fk_b", TableName: "Rental", ColumnName: "customer_id", ReferencedTableName: "customer", ReferencedColumnName: "customer_id"},
+	})
+	if len(fks) != 2 || fks[0].Table != "rental" || strings.Join(fks[0].Columns, ",") != "store_id,film_id" ||
+		fks[1].RefTable != "customer" || strings.Join(fks[1].RefColumns, ",") != "customer_id" {
+		t.Errorf("got: %v", fks)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
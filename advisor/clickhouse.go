@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// clickhouseTables 输入中 CREATE TABLE 定义的 ClickHouse 表，key 为小写的表名
+var clickhouseTables = make(map[string]*ast.ClickHouseTable)
+
+// ClickHouseParser ClickHouse 语法解析，-dialect=clickhouse 时替换默认的 vitess 及 TiDB 解析器
+// 去除 ClickHouse 特有的语法后交给 vitess 及 TiDB 解析以复用通用的启发式规则，建表语句只记录表定义供 CKH 类规则使用
+type ClickHouseParser struct{}
+
+// Name 解析器名称
+func (ClickHouseParser) Name() string {
+	return "clickhouse"
+}
+
+// Parse ClickHouse 的函数、类型等与 MySQL 差异较大，MySQL 解析器的错误只记录日志，不作为语法错误报告
+func (ClickHouseParser) Parse(q *Query4Audit, charset, collation string) error {
+	q.ClickHouse = ast.ParseClickHouse(q.Query)
+	if tb := q.ClickHouse.Table; tb != nil {
+		clickhouseTables[strings.ToLower(tb.Name)] = tb
+		return nil
+	}
+
+	var err error
+	q.Stmt, err = sqlparser.Parse(q.ClickHouse.SQL)
+	if err != nil {
+		common.Log.Warn("ClickHouseParser vitess parse Error: %s, Query: %s", err.Error(), q.ClickHouse.SQL)
+	}
+	q.TiStmt, err = ast.TiParse(q.ClickHouse.SQL, charset, collation)
+	if err != nil {
+		common.Log.Warn("ClickHouseParser tidb parse Error: %s, Query: %s", err.Error(), q.ClickHouse.SQL)
+	}
+	return nil
+}
+
+// clickhouseSelect 返回最外层的 SELECT 及其 FROM 中的表，key 为小写的别名或表名，输入中没有建表语句的表 value 为 nil
+func (q *Query4Audit) clickhouseSelect() (*sqlparser.Select, map[string]*ast.ClickHouseTable) {
+	sel, ok := q.Stmt.(*sqlparser.Select)
+	if q.ClickHouse == nil || !ok {
+		return nil, nil
+	}
+	tables := make(map[string]*ast.ClickHouseTable)
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.AliasedTableExpr:
+			if tb, ok := n.Expr.(sqlparser.TableName); ok {
+				name := strings.ToLower(tb.Name.String())
+				alias := name
+				if !n.As.IsEmpty() {
+					alias = strings.ToLower(n.As.String())
+				}
+				tables[alias] = clickhouseTables[name]
+			}
+		}
+		return true, nil
+	}, sel.From)
+	common.LogIfError(err, "")
+	return sel, tables
+}
+
+// RuleClickHouseSelectStar CKH.001
+// 列存引擎按列读取数据，宽表上的 SELECT * 需要读取并解压所有列
+func (q *Query4Audit) RuleClickHouseSelectStar() Rule {
+	var rule = q.RuleOK()
+	sel, tables := q.clickhouseSelect()
+	if sel == nil {
+		return rule
+	}
+	for _, expr := range sel.SelectExprs {
+		if _, ok := expr.(*sqlparser.StarExpr); !ok {
+			continue
+		}
+		for _, tb := range tables {
+			if tb != nil && strings.Contains(tb.Engine, "MergeTree") && len(tb.Columns) >= RuleThreshold("CKH.001") {
+				return HeuristicRules["CKH.001"]
+			}
+		}
+	}
+	return rule
+}
+
+// RuleClickHouseMissingPrewhere CKH.002
+// ClickHouse 默认会将 WHERE 中选择性高的条件自动移至 PREWHERE，但使用 FINAL 或多表 JOIN 时不会自动优化
+func (q *Query4Audit) RuleClickHouseMissingPrewhere() Rule {
+	var rule = q.RuleOK()
+	sel, tables := q.clickhouseSelect()
+	if sel == nil || sel.Where == nil || q.ClickHouse.Prewhere {
+		return rule
+	}
+	if q.ClickHouse.Final || len(tables) > 1 {
+		rule = HeuristicRules["CKH.002"]
+	}
+	return rule
+}
+
+// RuleClickHouseKeyPrefix CKH.003
+// MergeTree 的稀疏主键索引按排序键有序，过滤条件使用了排序键中的列却没有使用第一列时无法有效跳过数据块
+func (q *Query4Audit) RuleClickHouseKeyPrefix() Rule {
+	var rule = q.RuleOK()
+	sel, tables := q.clickhouseSelect()
+	if sel == nil || sel.Where == nil {
+		return rule
+	}
+
+	// WHERE 中使用的列，key 为小写的别名或表名，未指定表名时为空
+	cols := make(map[string]map[string]bool)
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.ColName:
+			qualifier := strings.ToLower(n.Qualifier.Name.String())
+			if cols[qualifier] == nil {
+				cols[qualifier] = make(map[string]bool)
+			}
+			cols[qualifier][strings.ToLower(n.Name.String())] = true
+		}
+		return true, nil
+	}, sel.Where)
+	common.LogIfError(err, "")
+
+	for alias, tb := range tables {
+		if tb == nil || len(tb.Key) == 0 {
+			continue
+		}
+		used := func(col string) bool {
+			col = strings.ToLower(col)
+			return cols[alias][col] || cols[""][col]
+		}
+		if used(tb.Key[0]) {
+			continue
+		}
+		for _, col := range tb.Key[1:] {
+			if used(col) {
+				return HeuristicRules["CKH.003"]
+			}
+		}
+	}
+	return rule
+}
+
+// RuleClickHouseFinal CKH.004
+// FINAL 在查询时合并数据，查询性能会大幅下降
+func (q *Query4Audit) RuleClickHouseFinal() Rule {
+	var rule = q.RuleOK()
+	if q.ClickHouse != nil && q.ClickHouse.Final {
+		rule = HeuristicRules["CKH.004"]
+	}
+	return rule
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+)
+
+// CKH.001 ~ CKH.004
+func TestRuleClickHouse(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgParsers := Parsers
+	orgMaxColCount := common.Config.MaxColCount
+	defer func() {
+		Parsers = orgParsers
+		common.Config.MaxColCount = orgMaxColCount
+		clickhouseTables = make(map[string]*ast.ClickHouseTable)
+	}()
+	Parsers = []Parser{ClickHouseParser{}}
+	common.Config.MaxColCount = 3
+
+	for _, sql := range []string{
+		"CREATE TABLE hits (event_date Date, user_id UInt64, url String, referer String) ENGINE = MergeTree ORDER BY (user_id, event_date)",
+		"CREATE TABLE users (id UInt64, name String) ENGINE = Memory",
+	} {
+		q, err := NewQuery4Audit(sql)
+		if err != nil || q.ClickHouse == nil || q.ClickHouse.Table == nil {
+			t.Fatal("CREATE TABLE not parsed:", sql, err)
+		}
+	}
+
+	cases := []struct {
+		item string
+		rule func(*Query4Audit) Rule
+		hit  []string
+		miss []string
+	}{
+		{
+			"CKH.001", (*Query4Audit).RuleClickHouseSelectStar,
+			[]string{
+				"SELECT * FROM hits PREWHERE user_id = 1",
+				"SELECT * FROM hits h JOIN users u ON h.user_id = u.id",
+			},
+			[]string{
+				"SELECT user_id, count() FROM hits GROUP BY user_id",
+				"SELECT * FROM users",
+				"SELECT * FROM unknown",
+			},
+		},
+		{
+			"CKH.002", (*Query4Audit).RuleClickHouseMissingPrewhere,
+			[]string{
+				"SELECT user_id FROM hits FINAL WHERE url LIKE '%soar%'",
+				"SELECT h.url FROM hits h JOIN users u ON h.user_id = u.id WHERE u.name = 'soar'",
+			},
+			[]string{
+				"SELECT user_id FROM hits WHERE url LIKE '%soar%'",
+				"SELECT user_id FROM hits FINAL PREWHERE url LIKE '%soar%'",
+			},
+		},
+		{
+			"CKH.003", (*Query4Audit).RuleClickHouseKeyPrefix,
+			[]string{
+				"SELECT count() FROM hits WHERE event_date = today()",
+				"SELECT count() FROM hits h JOIN users u ON h.user_id = u.id PREWHERE h.event_date = today()",
+			},
+			[]string{
+				"SELECT count() FROM hits WHERE user_id = 1 AND event_date = today()",
+				"SELECT count() FROM hits WHERE url = 'soar'",
+				"SELECT count() FROM users WHERE name = 'soar'",
+			},
+		},
+		{
+			"CKH.004", (*Query4Audit).RuleClickHouseFinal,
+			[]string{
+				"SELECT count() FROM hits FINAL WHERE user_id = 1",
+			},
+			[]string{
+				"SELECT final FROM hits",
+			},
+		},
+	}
+	for _, c := range cases {
+		for _, sql := range c.hit {
+			q, _ := NewQuery4Audit(sql)
+			if rule := c.rule(q); rule.Item != c.item {
+				t.Error("Rule not match:", rule.Item, "Expect :", c.item, "SQL:", sql)
+			}
+		}
+		for _, sql := range c.miss {
+			q, _ := NewQuery4Audit(sql)
+			if rule := c.rule(q); rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK, SQL:", sql)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
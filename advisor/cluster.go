@@ -0,0 +1,200 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// Group Replication 及 Galera 通过认证（certification）在各节点间复制事务，依赖主键检测冲突、只复制 InnoDB 表，
+// 并且不会在节点间同步表锁，CLU 类规则仅在 -cluster 配置了集群类型时检查
+
+var (
+	clusterLockTablesRe = regexp.MustCompile(`(?i)^\s*lock\s+tables?\b`)
+	clusterGetLockRe    = regexp.MustCompile(`(?i)\bget_lock\s*\(`)
+)
+
+// isCluster 是否配置了 -cluster
+func isCluster() bool {
+	return common.Config.Cluster != ""
+}
+
+// clusterWriteSetLimit 集群中限制事务大小的参数
+func clusterWriteSetLimit() string {
+	if common.Config.Cluster == "galera" {
+		return ruleMessage("CLU.002.galera", nil)
+	}
+	return ruleMessage("CLU.002.group", nil)
+}
+
+// RuleClusterNoPrimaryKey CLU.001
+// 没有主键的表无法在集群中检测写冲突，Group Replication 拒绝写入，Galera 逐行全表扫描应用，DELETE 也无法复制
+func (q *Query4Audit) RuleClusterNoPrimaryKey() Rule {
+	var rule = q.RuleOK()
+	if !isCluster() {
+		return rule
+	}
+	for _, tiStmt := range q.TiStmt {
+		switch node := tiStmt.(type) {
+		case *tidb.CreateTableStmt:
+			// LIKE 建表时主键来自源表
+			if node.ReferTable != nil || tidbHasPrimaryKey(node) {
+				continue
+			}
+			return HeuristicRules["CLU.001"]
+		case *tidb.AlterTableStmt:
+			drop, add := false, false
+			for _, spec := range node.Specs {
+				switch {
+				case spec.Tp == tidb.AlterTableDropPrimaryKey:
+					drop = true
+				case spec.Tp == tidb.AlterTableAddConstraint && spec.Constraint != nil && spec.Constraint.Tp == tidb.ConstraintPrimaryKey:
+					add = true
+				}
+			}
+			if drop && !add {
+				return HeuristicRules["CLU.001"]
+			}
+		}
+	}
+	return rule
+}
+
+// tidbHasPrimaryKey 建表语句中是否定义了主键
+func tidbHasPrimaryKey(node *tidb.CreateTableStmt) bool {
+	for _, col := range node.Cols {
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey {
+				return true
+			}
+		}
+	}
+	for _, cons := range node.Constraints {
+		if cons.Tp == tidb.ConstraintPrimaryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleClusterLargeTransaction CLU.002
+// 不带 WHERE 条件的 UPDATE, DELETE，INSERT ... SELECT 及行数超过阈值的 INSERT 可能产生超过集群限制的事务
+func (q *Query4Audit) RuleClusterLargeTransaction() Rule {
+	var rule = q.RuleOK()
+	if !isCluster() {
+		return rule
+	}
+	var reason string
+	switch s := q.Stmt.(type) {
+	case *sqlparser.Update:
+		if s.Where == nil && s.Limit == nil {
+			reason = ruleMessage("CLU.002.update", nil)
+		}
+	case *sqlparser.Delete:
+		if s.Where == nil && s.Limit == nil {
+			reason = ruleMessage("CLU.002.delete", nil)
+		}
+	case *sqlparser.Insert:
+		switch rows := s.Rows.(type) {
+		case sqlparser.Values:
+			if len(rows) > RuleThreshold("CLU.002") {
+				reason = ruleMessage("CLU.002.insert", map[string]interface{}{"Rows": len(rows), "Threshold": RuleThreshold("CLU.002")})
+			}
+		case sqlparser.SelectStatement:
+			reason = ruleMessage("CLU.002.insert_select", nil)
+		}
+	}
+	if reason != "" {
+		rule = HeuristicRules["CLU.002"]
+		rule.Content = strings.Join([]string{rule.Content, reason, clusterWriteSetLimit()}, " ")
+	}
+	return rule
+}
+
+// RuleClusterLockTables CLU.003
+// 表锁及用户锁只在当前节点生效，不会同步到集群中的其他节点
+func (q *Query4Audit) RuleClusterLockTables() Rule {
+	var rule = q.RuleOK()
+	if !isCluster() {
+		return rule
+	}
+	if clusterLockTablesRe.MatchString(q.Query) || clusterGetLockRe.MatchString(q.Query) {
+		rule = HeuristicRules["CLU.003"]
+	}
+	return rule
+}
+
+// RuleClusterNonInnoDB CLU.004
+// 建表或修改表的存储引擎为非 InnoDB，写入非 InnoDB 表的检查在 GateEngineRules 中结合线上的表结构给出
+func (q *Query4Audit) RuleClusterNonInnoDB() Rule {
+	var rule = q.RuleOK()
+	if !isCluster() {
+		return rule
+	}
+	var options []*tidb.TableOption
+	for _, tiStmt := range q.TiStmt {
+		switch node := tiStmt.(type) {
+		case *tidb.CreateTableStmt:
+			options = append(options, node.Options...)
+		case *tidb.AlterTableStmt:
+			for _, spec := range node.Specs {
+				if spec.Tp == tidb.AlterTableOption {
+					options = append(options, spec.Options...)
+				}
+			}
+		}
+	}
+	for _, opt := range options {
+		if opt.Tp == tidb.TableOptionEngine && !strings.EqualFold(opt.StrValue, "InnoDB") {
+			rule = HeuristicRules["CLU.004"]
+			rule.Content = strings.Join([]string{rule.Content, ruleMessage("CLU.004.engine", map[string]interface{}{"Engine": opt.StrValue})}, " ")
+			return rule
+		}
+	}
+	return rule
+}
+
+// clusterWriteRule 写入线上使用非 InnoDB 存储引擎的表（CLU.004），engines 为 TableEngines 的返回值
+func clusterWriteRule(q *Query4Audit, engines map[string]string) (Rule, bool) {
+	if q == nil || !isCluster() {
+		return Rule{}, false
+	}
+	switch q.Stmt.(type) {
+	case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return Rule{}, false
+	}
+	var tables []string
+	for _, name := range common.SortedKey(engines) {
+		if engines[name] != "INNODB" {
+			tables = append(tables, fmt.Sprintf("%s(%s)", name, engines[name]))
+		}
+	}
+	if len(tables) == 0 {
+		return Rule{}, false
+	}
+	rule := HeuristicRules["CLU.004"]
+	rule.Content = strings.Join([]string{rule.Content, ruleMessage("CLU.004.tables", map[string]interface{}{"Tables": tables})}, " ")
+	return rule, true
+}
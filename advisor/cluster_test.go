@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// CLU.001, CLU.002, CLU.003, CLU.004
+func TestClusterRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgCluster := common.Config.Cluster
+	defer func() { common.Config.Cluster = orgCluster }()
+
+	values := strings.TrimSuffix(strings.Repeat("(1),", RuleThreshold("CLU.002")+1), ",")
+	cases := map[string]string{
+		"CREATE TABLE tbl (a int, b int)":                            "CLU.001",
+		"CREATE TABLE tbl (a int, KEY idx_a (a))":                    "CLU.001",
+		"ALTER TABLE tbl DROP PRIMARY KEY":                           "CLU.001",
+		"DELETE FROM tbl":                                            "CLU.002",
+		"UPDATE tbl SET a = 1":                                       "CLU.002",
+		"INSERT INTO tbl (a) SELECT a FROM tbl2":                     "CLU.002",
+		"INSERT INTO tbl (a) VALUES " + values:                       "CLU.002",
+		"LOCK TABLES tbl WRITE":                                      "CLU.003",
+		"SELECT GET_LOCK('job', 10)":                                 "CLU.003",
+		"CREATE TABLE tbl (id int PRIMARY KEY) ENGINE=MyISAM":        "CLU.004",
+		"ALTER TABLE tbl ENGINE = MEMORY":                            "CLU.004",
+		"CREATE TABLE tbl (id int PRIMARY KEY, a int) ENGINE=InnoDB": "",
+		"CREATE TABLE tbl (id int, a int, PRIMARY KEY (id))":         "",
+		"CREATE TABLE tbl LIKE tbl2":                                 "",
+		"ALTER TABLE tbl DROP PRIMARY KEY, ADD PRIMARY KEY (id, a)":  "",
+		"DELETE FROM tbl WHERE id = 1":                               "",
+		"INSERT INTO tbl (a) VALUES (1), (2)":                        "",
+		"SELECT * FROM tbl WHERE id = 1 FOR UPDATE":                  "",
+	}
+	funcs := map[string]func(*Query4Audit) Rule{
+		"CLU.001": (*Query4Audit).RuleClusterNoPrimaryKey,
+		"CLU.002": (*Query4Audit).RuleClusterLargeTransaction,
+		"CLU.003": (*Query4Audit).RuleClusterLockTables,
+		"CLU.004": (*Query4Audit).RuleClusterNonInnoDB,
+	}
+	for _, cluster := range []string{"group-replication", "galera"} {
+		common.Config.Cluster = cluster
+		for sql, want := range cases {
+			q, err := NewQuery4Audit(sql)
+			if err != nil {
+				t.Error(sql, err)
+				continue
+			}
+			var got []string
+			for _, item := range common.SortedKey(funcs) {
+				if rule := funcs[item](q); rule.Item != "OK" {
+					got = append(got, rule.Item)
+				}
+			}
+			if strings.Join(got, ",") != want {
+				t.Errorf("cluster: %s, SQL: %s, want %s, got %v", cluster, sql, want, got)
+			}
+		}
+	}
+
+	// 大事务的提示中包含对应集群的限制参数
+	q, _ := NewQuery4Audit("DELETE FROM tbl")
+	common.Config.Cluster = "galera"
+	if rule := q.RuleClusterLargeTransaction(); !strings.Contains(rule.Content, "wsrep_max_ws_size") {
+		t.Errorf("want wsrep_max_ws_size in content, got %s", rule.Content)
+	}
+	common.Config.Cluster = "group-replication"
+	if rule := q.RuleClusterLargeTransaction(); !strings.Contains(rule.Content, "group_replication_transaction_size_limit") {
+		t.Errorf("want group_replication_transaction_size_limit in content, got %s", rule.Content)
+	}
+
+	// 未配置 -cluster 时不检查
+	common.Config.Cluster = ""
+	for sql := range cases {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			continue
+		}
+		for item, f := range funcs {
+			if rule := f(q); rule.Item != "OK" {
+				t.Errorf("cluster not set, SQL: %s, got %s", sql, item)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// CLU.004 写入非 InnoDB 的表
+func TestClusterWriteRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgCluster := common.Config.Cluster
+	defer func() { common.Config.Cluster = orgCluster }()
+
+	engines := map[string]string{"`sakila`.`log`": "MYISAM", "`sakila`.`film`": "INNODB"}
+	q, _ := NewQuery4Audit("INSERT INTO log (msg) VALUES ('a')")
+	if _, ok := clusterWriteRule(q, engines); ok {
+		t.Error("cluster not set, want no CLU.004")
+	}
+
+	common.Config.Cluster = "galera"
+	rule, ok := clusterWriteRule(q, engines)
+	if !ok || rule.Item != "CLU.004" || !strings.Contains(rule.Content, "`sakila`.`log`(MYISAM)") || strings.Contains(rule.Content, "film") {
+		t.Errorf("want CLU.004 for MYISAM table, got %v %v", ok, rule)
+	}
+
+	// 补充说明使用 -lang 指定的语言
+	if err := LoadRuleLocale("zh-CN", ""); err != nil {
+		t.Fatal(err)
+	}
+	rule, _ = clusterWriteRule(q, engines)
+	common.LogIfError(LoadRuleLocale(common.Config.Lang, ""), "")
+	if !strings.Contains(rule.Content, "写入的表使用非 InnoDB 存储引擎: `sakila`.`log`(MYISAM)。") {
+		t.Errorf("unexpected content: %s", rule.Content)
+	}
+	q, _ = NewQuery4Audit("SELECT * FROM log")
+	if _, ok := clusterWriteRule(q, engines); ok {
+		t.Error("SELECT should not trigger CLU.004")
+	}
+	q, _ = NewQuery4Audit("UPDATE film SET title = 'a' WHERE film_id = 1")
+	if _, ok := clusterWriteRule(q, map[string]string{"`sakila`.`film`": "INNODB"}); ok {
+		t.Error("InnoDB table should not trigger CLU.004")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
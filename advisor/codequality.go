@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// codeQualityIssue GitLab Code Quality 报告中的一项
+// https://docs.gitlab.com/ee/ci/testing/code_quality.html#implement-a-custom-tool
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+// codeQualitySeverity 对应 GitLab 的 info, minor, major, critical, blocker
+// severity-labels 中设置了的级别按级别名称转换，保证与其他输出一致
+func codeQualitySeverity(severity string) string {
+	if label, ok := common.Config.SeverityLabels[severity]; ok {
+		return map[string]string{
+			"info":    "info",
+			"warning": "major",
+			"error":   "critical",
+			"blocker": "blocker",
+		}[label]
+	}
+	switch l := severityLevel(severity); {
+	case l == 0:
+		return "info"
+	case l <= 2:
+		return "minor"
+	case l <= 4:
+		return "major"
+	case l <= 7:
+		return "critical"
+	default:
+		return "blocker"
+	}
+}
+
+// FormatCodeQuality 输出 GitLab Code Quality JSON，在 MR 中按文件行号展示建议
+func FormatCodeQuality(findings []Finding) string {
+	issues := make([]codeQualityIssue, 0, len(findings))
+	for _, f := range findings {
+		if f.ok() {
+			continue
+		}
+		issue := codeQualityIssue{
+			Description: f.Rule.Summary,
+			CheckName:   f.Rule.Item,
+			// 同一文件中同一 SQL 的同一条建议指纹不变，行号变化不影响 MR 中新增、修复问题的判断
+			Fingerprint: fmt.Sprintf("%x", md5.Sum([]byte(f.File+":"+f.QueryID+":"+f.Rule.Item))),
+			Severity:    codeQualitySeverity(f.Rule.Severity),
+		}
+		issue.Location.Path = f.File
+		issue.Location.Lines.Begin = f.Line
+		issues = append(issues, issue)
+	}
+	js, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatCodeQuality json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
+
+// rdjsonResult reviewdog Diagnostic Format，reviewdog -f=rdjson 读取后在 PR 中按行评论
+// https://github.com/reviewdog/reviewdog/tree/master/proto/rdf
+type rdjsonResult struct {
+	Source      rdjsonSource       `json:"source"`
+	Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type rdjsonDiagnostic struct {
+	Message  string         `json:"message"`
+	Location rdjsonLocation `json:"location"`
+	Severity string         `json:"severity"`
+	Code     rdjsonCode     `json:"code"`
+}
+
+type rdjsonLocation struct {
+	Path  string      `json:"path"`
+	Range rdjsonRange `json:"range"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition `json:"start"`
+}
+
+type rdjsonPosition struct {
+	Line int `json:"line"`
+}
+
+type rdjsonCode struct {
+	Value string `json:"value"`
+	URL   string `json:"url,omitempty"`
+}
+
+// FormatRDJSON 输出 reviewdog Diagnostic Format JSON
+func FormatRDJSON(findings []Finding) string {
+	res := rdjsonResult{
+		Source:      rdjsonSource{Name: "soar", URL: "https://github.com/XiaoMi/soar"},
+		Diagnostics: make([]rdjsonDiagnostic, 0, len(findings)),
+	}
+	for _, f := range findings {
+		if f.ok() {
+			continue
+		}
+		d := rdjsonDiagnostic{
+			Message:  strings.TrimSpace(f.Rule.Summary + "\n" + f.Rule.Content),
+			Severity: strings.ToUpper(severityClass(f.Rule.Severity)),
+			Code:     rdjsonCode{Value: f.Rule.Item},
+		}
+		if len(f.Rule.References) > 0 {
+			d.Code.URL = f.Rule.References[0]
+		}
+		d.Location.Path = f.File
+		d.Location.Range.Start.Line = f.Line
+		res.Diagnostics = append(res.Diagnostics, d)
+	}
+	js, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatRDJSON json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
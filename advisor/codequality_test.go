@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestCodeQualitySeverity(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]string{
+		"L0": "info",
+		"L1": "minor",
+		"L2": "minor",
+		"L4": "major",
+		"L6": "critical",
+		"L8": "blocker",
+		"":   "info",
+	}
+	for severity, want := range cases {
+		if got := codeQualitySeverity(severity); got != want {
+			t.Errorf("codeQualitySeverity(%q) got %s, want %s", severity, got, want)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestSeverityLabel(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgLabels := common.Config.SeverityLabels
+	defer func() { common.Config.SeverityLabels = orgLabels }()
+	common.Config.SeverityLabels = map[string]string{"L2": "error", "L8": "error"}
+	cases := []struct {
+		severity, label, class, codeQuality string
+	}{
+		{"L0", "info", "info", "info"},
+		{"L1", "warning", "warning", "minor"},
+		{"L2", "error", "error", "critical"},
+		{"L6", "error", "error", "critical"},
+		{"L8", "error", "error", "critical"},
+	}
+	for _, c := range cases {
+		if got := SeverityLabel(c.severity); got != c.label {
+			t.Errorf("SeverityLabel(%q) got %s, want %s", c.severity, got, c.label)
+		}
+		if got := severityClass(c.severity); got != c.class {
+			t.Errorf("severityClass(%q) got %s, want %s", c.severity, got, c.class)
+		}
+		if got := codeQualitySeverity(c.severity); got != c.codeQuality {
+			t.Errorf("codeQualitySeverity(%q) got %s, want %s", c.severity, got, c.codeQuality)
+		}
+	}
+
+	common.Config.SeverityLabels = nil
+	if got := SeverityLabel("L8"); got != "blocker" {
+		t.Errorf("SeverityLabel(L8) got %s, want blocker", got)
+	}
+	if got := severityClass("L8"); got != "error" {
+		t.Errorf("severityClass(L8) got %s, want error", got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatFindings(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sql := "select * from film where title like '%abc'"
+	suggest := map[string]Rule{
+		"OK":      HeuristicRules["OK"],
+		"ARG.001": HeuristicRules["ARG.001"],
+		"COL.001": HeuristicRules["COL.001"],
+	}
+	findings := NewFindings(suggest, sql, []string{"film"}, "sql/film.sql", 3)
+	if len(findings) != 2 {
+		t.Fatalf("NewFindings got %d findings, want 2", len(findings))
+	}
+
+	orgReportType := common.Config.ReportType
+	common.Config.ReportType = "codequality"
+	var issues []codeQualityIssue
+	if err := json.Unmarshal([]byte(FormatFindings(findings)), &issues); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 || issues[0].CheckName != "ARG.001" ||
+		issues[0].Location.Path != "sql/film.sql" || issues[0].Location.Lines.Begin != 3 ||
+		issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Errorf("codequality got %v", issues)
+	}
+
+	common.Config.ReportType = "rdjson"
+	var res rdjsonResult
+	if err := json.Unmarshal([]byte(FormatFindings(findings)), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Source.Name != "soar" || len(res.Diagnostics) != 2 ||
+		res.Diagnostics[1].Code.Value != "COL.001" ||
+		res.Diagnostics[1].Location.Range.Start.Line != 3 {
+		t.Errorf("rdjson got %v", res)
+	}
+	if len(HeuristicRules["ARG.001"].References) > 0 &&
+		res.Diagnostics[0].Code.URL != HeuristicRules["ARG.001"].References[0] {
+		t.Errorf("rdjson code url got %s", res.Diagnostics[0].Code.URL)
+	}
+	common.Config.ReportType = orgReportType
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
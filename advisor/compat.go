@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compatCheck Oracle, SQL Server 中在 MySQL 上无法执行或行为不同的语法
+type compatCheck struct {
+	Source     string // Oracle, SQL Server
+	Name       string
+	Re         *regexp.Regexp
+	Suggestion string
+}
+
+// compatChecks -report-type compat-lint 检查的语法，按来源分组
+var compatChecks = []compatCheck{
+	// Oracle
+	{"Oracle", "ROWNUM", regexp.MustCompile(`(?i)\brownum\b`),
+		"使用 LIMIT n 限制返回行数，需要行号时使用 ROW_NUMBER() OVER ()（MySQL 8.0）"},
+	{"Oracle", "(+) 外连接", regexp.MustCompile(`\(\s*\+\s*\)`),
+		"改写为 LEFT JOIN / RIGHT JOIN ... ON"},
+	{"Oracle", "NVL()", regexp.MustCompile(`(?i)\bnvl\s*\(`),
+		"使用 IFNULL(a, b) 或 COALESCE(a, b)"},
+	{"Oracle", "NVL2()", regexp.MustCompile(`(?i)\bnvl2\s*\(`),
+		"使用 IF(a IS NOT NULL, b, c)"},
+	{"Oracle", "DECODE()", regexp.MustCompile(`(?i)\bdecode\s*\(`),
+		"使用 CASE a WHEN b THEN c ELSE d END，MySQL 的 DECODE() 是加解密函数且已在 8.0 中移除"},
+	{"Oracle", "序列 NEXTVAL/CURRVAL", regexp.MustCompile(`(?i)\w\.(nextval|currval)\b`),
+		"MySQL 不支持序列，使用 AUTO_INCREMENT 列并通过 LAST_INSERT_ID() 获取生成的值"},
+	{"Oracle", "SYSDATE", regexp.MustCompile(`(?i)\bsysdate\b\s*([^\s(]|$)`),
+		"使用 NOW()，MySQL 的 SYSDATE() 需要括号且返回函数执行时的时间"},
+	{"Oracle", "TO_DATE()/TO_CHAR()", regexp.MustCompile(`(?i)\bto_(date|char)\s*\(`),
+		"使用 STR_TO_DATE() / DATE_FORMAT()，注意格式符不同，如 'YYYY-MM-DD' 对应 '%Y-%m-%d'"},
+	{"Oracle", "CONNECT BY 层次查询", regexp.MustCompile(`(?i)\bconnect\s+by\b`),
+		"使用 WITH RECURSIVE 递归 CTE（MySQL 8.0）"},
+	{"Oracle", "MINUS", regexp.MustCompile(`(?i)\bminus\b`),
+		"使用 NOT EXISTS 或 LEFT JOIN ... IS NULL 改写，MySQL 8.0.31 起可以使用 EXCEPT"},
+	{"Oracle", "|| 字符串连接", regexp.MustCompile(`\|\|`),
+		"使用 CONCAT()，MySQL 默认将 || 作为逻辑或运算"},
+	{"Oracle", "MERGE INTO", regexp.MustCompile(`(?i)^\s*merge\s+into\b`),
+		"使用 INSERT ... ON DUPLICATE KEY UPDATE"},
+	{"Oracle", "FETCH FIRST/NEXT n ROWS", regexp.MustCompile(`(?i)\bfetch\s+(first|next)\b`),
+		"使用 LIMIT n OFFSET m"},
+	// SQL Server
+	{"SQL Server", "TOP", regexp.MustCompile(`(?i)^\s*(select|delete|update)\s+(distinct\s+)?top\b`),
+		"使用 LIMIT n"},
+	{"SQL Server", "ISNULL(a, b)", regexp.MustCompile(`(?i)\bisnull\s*\([^()]*,`),
+		"使用 IFNULL(a, b)，MySQL 的 ISNULL() 只接受一个参数，用于判断是否为 NULL"},
+	{"SQL Server", "GETDATE()", regexp.MustCompile(`(?i)\bgetdate\s*\(`),
+		"使用 NOW()"},
+	{"SQL Server", "LEN()", regexp.MustCompile(`(?i)\blen\s*\(`),
+		"使用 CHAR_LENGTH()"},
+	{"SQL Server", "[标识符]", regexp.MustCompile(`\[\w[^\]]*\]`),
+		"使用反引号引用标识符，如 `name`"},
+	{"SQL Server", "WITH (NOLOCK)", regexp.MustCompile(`(?i)\bwith\s*\(\s*nolock\s*\)`),
+		"去掉表提示，确有需要时使用 SET TRANSACTION ISOLATION LEVEL READ UNCOMMITTED"},
+	{"SQL Server", "CONVERT(type, expr)", regexp.MustCompile(`(?i)\bconvert\s*\(\s*n?(var)?char\b|\bconvert\s*\(\s*(int|bigint|date|datetime|decimal)\b`),
+		"使用 CAST(expr AS type)，MySQL 的 CONVERT() 参数顺序为 CONVERT(expr, type)"},
+	{"SQL Server", "IDENTITY", regexp.MustCompile(`(?i)\bidentity\s*\(|@@identity\b|\bscope_identity\s*\(`),
+		"使用 AUTO_INCREMENT 列，通过 LAST_INSERT_ID() 获取生成的值"},
+	{"SQL Server", "DATEADD()/DATEDIFF(unit, ...)", regexp.MustCompile(`(?i)\bdateadd\s*\(|\bdatediff\s*\(\s*(year|month|day|hour|minute|second|dd|mm|yy)\s*,`),
+		"使用 DATE_ADD(d, INTERVAL n unit) / TIMESTAMPDIFF(unit, a, b)"},
+	{"SQL Server", "CROSS/OUTER APPLY", regexp.MustCompile(`(?i)\b(cross|outer)\s+apply\b`),
+		"使用 JOIN LATERAL（MySQL 8.0.14）或改写为子查询关联"},
+	{"SQL Server", "NEXT VALUE FOR", regexp.MustCompile(`(?i)\bnext\s+value\s+for\b`),
+		"MySQL 不支持序列，使用 AUTO_INCREMENT 列并通过 LAST_INSERT_ID() 获取生成的值"},
+}
+
+// CompatIssue compat-lint 发现的不兼容语法
+type CompatIssue struct {
+	Source     string `json:"source"`
+	Name       string `json:"name"`
+	Suggestion string `json:"suggestion"`
+}
+
+// compatMask 去除 SQL 中的注释，并将单引号字符串替换为空字符串，避免字符串中的内容被误判
+// Oracle, SQL Server 的 SQL 通常无法通过 MySQL 语法解析，这里按字符扫描而不依赖 MySQL 词法
+func compatMask(sql string) string {
+	var buf strings.Builder
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case sql[i] == '\'':
+			// '' 为字符串中的单引号
+			for i++; i < len(sql); i++ {
+				if sql[i] == '\'' {
+					if i+1 < len(sql) && sql[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			buf.WriteString("''")
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			buf.WriteByte(' ')
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			buf.WriteByte(' ')
+		default:
+			buf.WriteByte(sql[i])
+		}
+	}
+	return buf.String()
+}
+
+// CompatLint 检查为 Oracle, SQL Server 编写的 SQL 在 MySQL 上无法执行或行为不同的语法，用于 -report-type compat-lint
+func CompatLint(sql string) []CompatIssue {
+	var issues []CompatIssue
+	masked := compatMask(sql)
+	for _, c := range compatChecks {
+		if c.Re.MatchString(masked) {
+			issues = append(issues, CompatIssue{Source: c.Source, Name: c.Name, Suggestion: c.Suggestion})
+		}
+	}
+	return issues
+}
+
+// FormatCompatLint 以 Markdown 格式输出单条 SQL 的不兼容语法，pos 为 SQL 所在的文件及行号
+func FormatCompatLint(sql, pos string, issues []CompatIssue) string {
+	if len(issues) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("## %s\n\n```sql\n%s\n```\n\n", pos, sql))
+	buf.WriteString("| 来源 | 语法 | MySQL 改写建议 |\n|---|---|---|\n")
+	for _, i := range issues {
+		buf.WriteString(fmt.Sprintf("| %s | %s | %s |\n", i.Source,
+			strings.Replace(i.Name, "|", `\|`, -1), strings.Replace(i.Suggestion, "|", `\|`, -1)))
+	}
+	return buf.String()
+}
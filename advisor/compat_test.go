@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestCompatLint(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string][]string{
+		"SELECT a.id, NVL(b.name, 'x') FROM a, b WHERE a.id = b.aid(+) AND ROWNUM <= 10": {"ROWNUM", "(+) 外连接", "NVL()"},
+		"SELECT seq_user.NEXTVAL, SYSDATE FROM dual":                                     {"序列 NEXTVAL/CURRVAL", "SYSDATE"},
+		"SELECT id FROM emp START WITH mgr IS NULL CONNECT BY PRIOR id = mgr":            {"CONNECT BY 层次查询"},
+		"SELECT first_name || ' ' || last_name FROM emp":                                 {"|| 字符串连接"},
+		"SELECT TOP 10 [name], ISNULL(age, 0), LEN(name) FROM users WITH (NOLOCK)":       {"TOP", "ISNULL(a, b)", "LEN()", "[标识符]", "WITH (NOLOCK)"},
+		"SELECT CONVERT(varchar(10), GETDATE(), 120)":                                    {"GETDATE()", "CONVERT(type, expr)"},
+		"SELECT * FROM t1 CROSS APPLY (SELECT TOP 1 * FROM t2 WHERE t2.id = t1.id) x":    {"CROSS/OUTER APPLY"},
+		// MySQL 语法及字符串、注释中的内容不报告
+		"SELECT IFNULL(a, 0), SYSDATE(), ISNULL(b) FROM t LIMIT 10":   nil,
+		"SELECT 'rownum || nvl(a, b)', \"x\" FROM t /* TOP 10 (+) */": nil,
+		"SELECT 'it''s (+)' FROM t -- NVL(a, b)":                      nil,
+	}
+	for sql, expects := range cases {
+		var names []string
+		for _, issue := range CompatLint(sql) {
+			names = append(names, issue.Name)
+		}
+		if strings.Join(names, ";") != strings.Join(expects, ";") {
+			t.Errorf("SQL: %s\nwant: %v\n got: %v", sql, expects, names)
+		}
+	}
+
+	if FormatCompatLint("SELECT 1", "stdin:1", nil) != "" {
+		t.Error("FormatCompatLint should be empty without issues")
+	}
+	str := FormatCompatLint("SELECT a || b FROM t", "stdin:1", CompatLint("SELECT a || b FROM t"))
+	if !strings.Contains(str, "## stdin:1") || !strings.Contains(str, `\|\| 字符串连接`) {
+		t.Error("FormatCompatLint error:", str)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,463 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+const (
+	// compressPageSize 按 InnoDB 页大小分段压缩采样数据，与 InnoDB 逐页压缩的效果更接近
+	compressPageSize = 16 * 1024
+	// dictionaryMaxDistinct 采样数据中不同值不超过该数量的字符串列可以使用字典编码
+	dictionaryMaxDistinct = 256
+	// dictionaryMaxEnum 不同值不超过该数量时建议使用 ENUM，否则建议使用字典表
+	dictionaryMaxEnum = 16
+	// dictionaryMinSample 采样数据中非空值少于该数量时无法判断列的取值范围
+	dictionaryMinSample = 100
+)
+
+// compressionIO 表的读写次数，Source 为统计来源：performance_schema 或输入的业务 SQL
+type compressionIO struct {
+	database.TableIOStat
+	Source string
+}
+
+// writeRatio 写入次数占读写总次数的比例，没有读写统计时返回 -1
+func (io *compressionIO) writeRatio() float64 {
+	if io == nil || io.Read+io.Writes() == 0 {
+		return -1
+	}
+	return float64(io.Writes()) / float64(io.Read+io.Writes())
+}
+
+// randomWriteRatio 随机写入次数占读写总次数的比例，UPDATE, DELETE 会修改已有的页，
+// 主键不是自增列时 INSERT 也会写入聚簇索引中的随机位置
+func (io *compressionIO) randomWriteRatio(seqInsert bool) float64 {
+	if io == nil || io.Read+io.Writes() == 0 {
+		return -1
+	}
+	random := io.Update + io.Delete
+	if !seqInsert {
+		random += io.Insert
+	}
+	return float64(random) / float64(io.Read+io.Writes())
+}
+
+// dictionaryColumn 适合字典编码的低基数字符串列
+type dictionaryColumn struct {
+	Name     string
+	Type     string
+	Distinct int
+	AvgLen   float64
+	Values   []string
+}
+
+// CompressionAdvisor 根据表大小、列类型、读写频率以及采样数据的可压缩性，对超过 -compress-min-size 的冷数据表给出压缩及字典编码建议，
+// 对写入频繁的压缩表给出取消压缩的建议
+func CompressionAdvisor(conn *database.Connector, buf string) map[string]Rule {
+	common.Log.Debug("Enter:  CompressionAdvisor, Caller: %s", common.Caller())
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *conn
+	ruleMap := make(map[string]Rule)
+	number := 1
+
+	// 错误处理，用于汇总所有的错误
+	funcErrCheck := func(err error) {
+		if err != nil {
+			if sug, ok := ruleMap["ERR.003"]; ok {
+				sug.Content += fmt.Sprintf("; %s", err.Error())
+				ruleMap["ERR.003"] = sug
+			} else {
+				ruleMap["ERR.003"] = Rule{
+					Item:     "ERR.003",
+					Severity: "L8",
+					Content:  err.Error(),
+				}
+			}
+		}
+	}
+
+	_, queries, _ := parseWorkload(buf)
+	workload := workloadIO(queries)
+
+	tables, err := tmpOnline.ShowTables()
+	if err != nil {
+		funcErrCheck(err)
+		return ruleMap
+	}
+	for _, tb := range tables {
+		status, err := tmpOnline.ShowTableStatus(tb)
+		if err != nil {
+			funcErrCheck(err)
+			continue
+		}
+		// 只有 InnoDB 支持 ROW_FORMAT=COMPRESSED 及透明页压缩，视图没有存储引擎
+		if len(status.Rows) == 0 || !strings.EqualFold(string(status.Rows[0].Engine), "InnoDB") {
+			continue
+		}
+		ts := status.Rows[0]
+		size := (ts.DataLength + ts.IndexLength) / 1024 / 1024
+		compression := tableCompression(string(ts.RowFormat), string(ts.CreateOptions))
+		if compression == "" && size < common.Config.CompressMinSize {
+			continue
+		}
+
+		desc, err := tmpOnline.ShowColumns(tb)
+		if err != nil {
+			funcErrCheck(err)
+			continue
+		}
+
+		// 优先使用 performance_schema 中的读写统计，不可用时使用输入的业务 SQL 估算
+		io := workload[strings.ToLower(tb)]
+		if stat, err := tmpOnline.TableIOStat(tb); err == nil && stat != nil && stat.Read+stat.Writes() > 0 {
+			io = &compressionIO{TableIOStat: *stat, Source: "performance_schema"}
+		} else if err != nil {
+			common.Log.Debug("CompressionAdvisor TableIOStat %s Error: %v", tb, err)
+		}
+
+		var rule Rule
+		var ok bool
+		if compression != "" {
+			rule, ok = compressedTablePlan(tmpOnline.Database, tb, compression, io, sequentialInsert(desc))
+		} else {
+			ratio := -1.0
+			var dicts []dictionaryColumn
+			if common.Config.Sampling && io.writeRatio() <= common.Config.CompressWriteRatio {
+				columns, rows, err := tmpOnline.SampleRows(tb, common.Config.SamplingStatisticTarget*300)
+				if err != nil {
+					funcErrCheck(err)
+					continue
+				}
+				ratio = compressRatio(rows)
+				dicts = dictionaryColumns(desc, columns, rows)
+			}
+			rule, ok = compressionPlan(tmpOnline.Database, tb, ts.Rows, size, desc, io, ratio, dicts)
+		}
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("CMP.%03d", number)
+		rule.Item = key
+		ruleMap[key] = rule
+		number++
+	}
+	return ruleMap
+}
+
+// workloadIO 统计输入的业务 SQL 中每张表的读写次数，表名统一转为小写
+func workloadIO(queries []sqlparser.Statement) map[string]*compressionIO {
+	ios := make(map[string]*compressionIO)
+	count := func(tb string, f func(io *compressionIO)) {
+		tb = strings.ToLower(tb)
+		if tb == "" || tb == "dual" {
+			return
+		}
+		if _, ok := ios[tb]; !ok {
+			ios[tb] = &compressionIO{Source: "workload"}
+		}
+		f(ios[tb])
+	}
+	for _, stmt := range queries {
+		switch s := stmt.(type) {
+		case *sqlparser.Insert:
+			count(s.Table.Name.String(), func(io *compressionIO) { io.Insert++ })
+		case *sqlparser.Update, *sqlparser.Delete:
+			for _, db := range ast.GetMeta(stmt, nil) {
+				for tb := range db.Table {
+					if _, ok := s.(*sqlparser.Update); ok {
+						count(tb, func(io *compressionIO) { io.Update++ })
+					} else {
+						count(tb, func(io *compressionIO) { io.Delete++ })
+					}
+				}
+			}
+		default:
+			for _, db := range ast.GetMeta(stmt, nil) {
+				for tb := range db.Table {
+					count(tb, func(io *compressionIO) { io.Read++ })
+				}
+			}
+		}
+	}
+	return ios
+}
+
+// tableCompression 根据 SHOW TABLE STATUS 中的 Row_format 及 Create_options 判断表使用的压缩方式，未压缩时返回空字符串
+func tableCompression(rowFormat, createOptions string) string {
+	if strings.EqualFold(rowFormat, "Compressed") {
+		return "ROW_FORMAT=COMPRESSED"
+	}
+	// MariaDB 中引擎定义的选项会带有引号，如 `PAGE_COMPRESSED`='ON'
+	options := strings.NewReplacer("`", "", "'", "", `"`, "").Replace(strings.ToLower(createOptions))
+	switch {
+	case strings.Contains(options, "row_format=compressed"):
+		return "ROW_FORMAT=COMPRESSED"
+	case strings.Contains(options, "page_compressed=1"), strings.Contains(options, "page_compressed=on"):
+		return "PAGE_COMPRESSED"
+	}
+	for _, opt := range strings.Fields(options) {
+		if !strings.HasPrefix(opt, "compression=") {
+			continue
+		}
+		algorithm := strings.TrimPrefix(opt, "compression=")
+		if algorithm != "" && algorithm != "none" {
+			return fmt.Sprintf("COMPRESSION='%s'", algorithm)
+		}
+	}
+	return ""
+}
+
+// sequentialInsert 主键为单列自增列时 INSERT 总是追加到聚簇索引的末尾
+func sequentialInsert(desc *database.TableDesc) bool {
+	pk := 0
+	autoInc := false
+	for _, col := range desc.DescValues {
+		if col.Key != "PRI" {
+			continue
+		}
+		pk++
+		if strings.Contains(strings.ToLower(col.Extra), "auto_increment") {
+			autoInc = true
+		}
+	}
+	return pk == 1 && autoInc
+}
+
+// compressRatio 将采样数据按 InnoDB 页大小分段使用 zlib 压缩，返回压缩后与压缩前大小的比值，没有数据时返回 -1
+func compressRatio(rows [][]string) float64 {
+	var raw, compressed int
+	var page bytes.Buffer
+	flush := func() {
+		if page.Len() == 0 {
+			return
+		}
+		var out bytes.Buffer
+		w := zlib.NewWriter(&out)
+		_, err := w.Write(page.Bytes())
+		common.LogIfWarn(err, "")
+		common.LogIfWarn(w.Close(), "")
+		raw += page.Len()
+		compressed += out.Len()
+		page.Reset()
+	}
+	for _, row := range rows {
+		for _, val := range row {
+			page.WriteString(val)
+		}
+		if page.Len() >= compressPageSize {
+			flush()
+		}
+	}
+	flush()
+	if raw == 0 {
+		return -1
+	}
+	return float64(compressed) / float64(raw)
+}
+
+// dictionaryColumns 从采样数据中找出不同值较少且值较长的字符串列，这些列可以使用 ENUM 或字典表编码
+func dictionaryColumns(desc *database.TableDesc, columns []string, rows [][]string) []dictionaryColumn {
+	types := make(map[string]string)
+	for _, col := range desc.DescValues {
+		types[strings.ToLower(col.Field)] = col.Type
+	}
+	var dicts []dictionaryColumn
+	for i, name := range columns {
+		typ := types[strings.ToLower(name)]
+		switch strings.ToLower(common.GetDataTypeBase(typ)) {
+		case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		default:
+			continue
+		}
+		values := make(map[string]bool)
+		total, length := 0, 0
+		for _, row := range rows {
+			if i >= len(row) || row[i] == "" {
+				continue
+			}
+			values[row[i]] = true
+			total++
+			length += len(row[i])
+			if len(values) > dictionaryMaxDistinct {
+				break
+			}
+		}
+		if total < dictionaryMinSample || len(values) > dictionaryMaxDistinct || len(values)*10 > total {
+			continue
+		}
+		avg := float64(length) / float64(total)
+		// 值较短时编码后节省的空间有限
+		if avg < 4 {
+			continue
+		}
+		dict := dictionaryColumn{Name: name, Type: typ, Distinct: len(values), AvgLen: avg}
+		for v := range values {
+			dict.Values = append(dict.Values, v)
+		}
+		sort.Strings(dict.Values)
+		dicts = append(dicts, dict)
+	}
+	return dicts
+}
+
+// compressibleColumns 未采样时根据列类型判断表中是否有字符串、JSON 等压缩效果较好的列
+func compressibleColumns(desc *database.TableDesc) []string {
+	var cols []string
+	for _, col := range desc.DescValues {
+		switch strings.ToLower(common.GetDataTypeBase(col.Type)) {
+		case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "json", "enum", "set":
+			cols = append(cols, col.Field)
+		}
+	}
+	return cols
+}
+
+// compressionPlan 生成未压缩的大表的压缩及字典编码方案，写入频繁或压缩收益不大的表返回 false
+func compressionPlan(db, tb string, rows, size uint64, desc *database.TableDesc, io *compressionIO, ratio float64, dicts []dictionaryColumn) (Rule, bool) {
+	rule := Rule{
+		Severity: "L2",
+		Summary:  fmt.Sprintf("%s.%s 数据量较大且写入较少，建议压缩存储", db, tb),
+	}
+	content := []string{fmt.Sprintf("表中约 %d 行数据，数据及索引大小约 %d MB", rows, size)}
+
+	writeRatio := io.writeRatio()
+	switch {
+	case writeRatio < 0:
+		content = append(content, "没有该表的读写统计，无法判断写入频率，请确认该表以读为主后再压缩")
+	case writeRatio > common.Config.CompressWriteRatio:
+		// 写入频繁的表压缩后每次修改都需要重新压缩页面，不建议压缩
+		return rule, false
+	default:
+		content = append(content, fmt.Sprintf("%s 中写入次数占读写总次数的 %.1f%%，属于冷数据", io.Source, writeRatio*100))
+	}
+
+	source := fmt.Sprintf("`%s`.`%s`", db, tb)
+	var script []string
+	keyBlockSize := 0
+	switch {
+	case ratio < 0:
+		cols := compressibleColumns(desc)
+		if len(cols) > 0 {
+			keyBlockSize = 8
+			content = append(content, fmt.Sprintf("表中的 %s 等字符串列通常有较好的压缩效果，开启 -sampling 后可根据采样数据估算压缩比", strings.Join(cols, ", ")))
+		}
+	case ratio <= 0.25:
+		keyBlockSize = 4
+	case ratio <= 0.5:
+		keyBlockSize = 8
+	case ratio <= 0.7:
+		// 压缩后超过半页，使用 ROW_FORMAT=COMPRESSED 会频繁发生压缩失败及页分裂，透明页压缩仍可以节省部分空间
+		content = append(content, fmt.Sprintf("采样数据压缩比约 %.0f%%，使用 ROW_FORMAT=COMPRESSED 收益不大", ratio*100))
+	default:
+		content = append(content, fmt.Sprintf("采样数据压缩比约 %.0f%%，数据本身已难以压缩（如已压缩的图片、加密数据），不建议压缩", ratio*100))
+	}
+	if ratio >= 0 && ratio <= 0.5 {
+		content = append(content, fmt.Sprintf("采样数据压缩比约 %.0f%%，压缩后预计可节省 %d MB", ratio*100, uint64(float64(size)*(1-ratio))))
+	}
+	if keyBlockSize > 0 {
+		script = append(script,
+			fmt.Sprintf("ALTER TABLE %s ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=%d;", source, keyBlockSize))
+	}
+	// 透明页压缩以文件系统块为单位回收空间，压缩比不足一半时仍然有效
+	pageCompression := keyBlockSize > 0 || ratio >= 0 && ratio <= 0.7
+	if pageCompression {
+		script = append(script, "-- 或使用透明页压缩，需要 innodb_file_per_table 以及支持 punch hole 的文件系统")
+		if common.TargetDB().IsMariaDB() {
+			script = append(script, fmt.Sprintf("ALTER TABLE %s PAGE_COMPRESSED=1;", source))
+		} else {
+			script = append(script,
+				fmt.Sprintf("ALTER TABLE %s COMPRESSION='zlib';", source),
+				fmt.Sprintf("OPTIMIZE TABLE %s;", source))
+		}
+	}
+
+	// 低基数字符串列使用字典编码
+	for _, dict := range dicts {
+		saved := uint64(float64(rows)*(dict.AvgLen-2)) / 1024 / 1024
+		if dict.Distinct <= dictionaryMaxEnum {
+			var values []string
+			for _, v := range dict.Values {
+				values = append(values, fmt.Sprintf("'%s'", strings.Replace(v, "'", "''", -1)))
+			}
+			content = append(content, fmt.Sprintf("%s 列采样数据中只有 %d 个不同值，平均长度 %.1f，可以改为 ENUM，预计节省 %d MB", dict.Name, dict.Distinct, dict.AvgLen, saved))
+			script = append(script, fmt.Sprintf("ALTER TABLE %s MODIFY `%s` ENUM(%s);", source, dict.Name, strings.Join(values, ", ")))
+			continue
+		}
+		content = append(content, fmt.Sprintf("%s 列采样数据中只有 %d 个不同值，平均长度 %.1f，可以将取值存入字典表，原表只保存字典表的 ID，预计节省 %d MB", dict.Name, dict.Distinct, dict.AvgLen, saved))
+		script = append(script,
+			fmt.Sprintf("CREATE TABLE `%s`.`%s_%s` (`id` SMALLINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY, `value` %s NOT NULL, UNIQUE KEY `uk_value` (`value`));", db, tb, dict.Name, dict.Type),
+			fmt.Sprintf("INSERT INTO `%s`.`%s_%s` (`value`) SELECT DISTINCT `%s` FROM %s WHERE `%s` IS NOT NULL;", db, tb, dict.Name, dict.Name, source, dict.Name))
+	}
+
+	if len(script) == 0 {
+		return rule, false
+	}
+	switch {
+	case keyBlockSize == 0 && pageCompression:
+		rule.Summary = fmt.Sprintf("%s.%s 数据量较大且写入较少，建议使用透明页压缩", db, tb)
+	case !pageCompression:
+		rule.Severity = "L1"
+		rule.Summary = fmt.Sprintf("%s.%s 中有低基数字符串列，建议使用字典编码", db, tb)
+	}
+	rule.Content = strings.Join(content, "; ")
+	rule.Case = strings.Join(script, "\n")
+	return rule, true
+}
+
+// compressedTablePlan 压缩表上的随机写入比例超过 -compress-write-ratio 时，建议取消压缩
+func compressedTablePlan(db, tb, compression string, io *compressionIO, seqInsert bool) (Rule, bool) {
+	random := io.randomWriteRatio(seqInsert)
+	if random <= common.Config.CompressWriteRatio {
+		return Rule{}, false
+	}
+	content := []string{
+		fmt.Sprintf("表使用了 %s，%s 中随机写入次数占读写总次数的 %.1f%%", compression, io.Source, random*100),
+		"压缩表上的 UPDATE, DELETE 以及非自增主键的 INSERT 会修改已有的页，需要重新压缩，压缩失败时还会导致页分裂，同时缓冲池中需要同时保存压缩页及解压后的页",
+		"可以通过 information_schema.INNODB_CMP 中 compress_ops_ok / compress_ops 观察压缩成功率，低于 90% 时建议取消压缩",
+	}
+	source := fmt.Sprintf("`%s`.`%s`", db, tb)
+	var script []string
+	switch {
+	case compression == "ROW_FORMAT=COMPRESSED":
+		script = append(script, fmt.Sprintf("ALTER TABLE %s ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=0;", source))
+	case compression == "PAGE_COMPRESSED":
+		script = append(script, fmt.Sprintf("ALTER TABLE %s PAGE_COMPRESSED=0;", source))
+	default:
+		// 修改 COMPRESSION 属性只对新写入的页生效，需要重建表
+		script = append(script,
+			fmt.Sprintf("ALTER TABLE %s COMPRESSION='None';", source),
+			fmt.Sprintf("OPTIMIZE TABLE %s;", source))
+	}
+	return Rule{
+		Severity: "L3",
+		Summary:  fmt.Sprintf("%s.%s 为压缩表且写入频繁，建议取消压缩", db, tb),
+		Content:  strings.Join(content, "; "),
+		Case:     strings.Join(script, "\n"),
+	}, true
+}
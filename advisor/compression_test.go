@@ -0,0 +1,219 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestTableCompression(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		rowFormat, options, want string
+	}{
+		{"Dynamic", "", ""},
+		{"Compressed", "row_format=COMPRESSED KEY_BLOCK_SIZE=8", "ROW_FORMAT=COMPRESSED"},
+		{"Dynamic", `COMPRESSION="zlib"`, "COMPRESSION='zlib'"},
+		{"Dynamic", `COMPRESSION="None"`, ""},
+		{"Dynamic", "`PAGE_COMPRESSED`='ON'", "PAGE_COMPRESSED"},
+		{"Dynamic", "`PAGE_COMPRESSED`='OFF'", ""},
+	}
+	for _, c := range cases {
+		if got := tableCompression(c.rowFormat, c.options); got != c.want {
+			t.Errorf("tableCompression(%s, %s) want %q, got %q", c.rowFormat, c.options, c.want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestWorkloadIO(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	_, queries, _ := parseWorkload(`SELECT * FROM logs WHERE id = 1;
+SELECT * FROM logs l JOIN users u ON l.uid = u.id;
+INSERT INTO logs (uid, msg) VALUES (1, 'a');
+UPDATE users SET name = 'b' WHERE id = 1;
+DELETE FROM users WHERE id = 2;`)
+	ios := workloadIO(queries)
+	logs, users := ios["logs"], ios["users"]
+	if logs == nil || logs.Read != 2 || logs.Insert != 1 || logs.Writes() != 1 {
+		t.Errorf("logs io: %+v", logs)
+	}
+	if users == nil || users.Read != 1 || users.Update != 1 || users.Delete != 1 {
+		t.Errorf("users io: %+v", users)
+	}
+	if r := users.randomWriteRatio(true); r < 0.66 || r > 0.67 {
+		t.Errorf("users random write ratio: %f", r)
+	}
+	if r := logs.randomWriteRatio(true); r != 0 {
+		t.Errorf("logs random write ratio: %f", r)
+	}
+	if r := logs.randomWriteRatio(false); r < 0.33 || r > 0.34 {
+		t.Errorf("logs random write ratio without auto_increment: %f", r)
+	}
+	var none *compressionIO
+	if none.writeRatio() != -1 {
+		t.Error("nil io should return -1")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCompressRatio(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var text, random [][]string
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		text = append(text, []string{fmt.Sprint(i), "status=paid channel=alipay currency=CNY"})
+		b := make([]byte, 32)
+		r.Read(b)
+		random = append(random, []string{string(b)})
+	}
+	if ratio := compressRatio(text); ratio <= 0 || ratio > 0.25 {
+		t.Errorf("repetitive text should compress well, got %f", ratio)
+	}
+	if ratio := compressRatio(random); ratio < 0.9 {
+		t.Errorf("random bytes should not compress, got %f", ratio)
+	}
+	if ratio := compressRatio(nil); ratio != -1 {
+		t.Errorf("no data want -1, got %f", ratio)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestDictionaryColumns(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	desc := &database.TableDesc{
+		Name: "orders",
+		DescValues: []database.TableDescValue{
+			{Field: "id", Type: "bigint(20)", Key: "PRI", Extra: "auto_increment"},
+			{Field: "status", Type: "varchar(32)"},
+			{Field: "city", Type: "varchar(64)"},
+			{Field: "remark", Type: "varchar(255)"},
+			{Field: "flag", Type: "char(1)"},
+		},
+	}
+	columns := []string{"id", "status", "city", "remark", "flag"}
+	var rows [][]string
+	statuses := []string{"WAIT_PAYMENT", "PAID", "SHIPPED"}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []string{
+			fmt.Sprint(i),
+			statuses[i%len(statuses)],
+			fmt.Sprintf("city-%03d", i%50),
+			fmt.Sprintf("remark %d", i),
+			"Y",
+		})
+	}
+	dicts := dictionaryColumns(desc, columns, rows)
+	if len(dicts) != 2 || dicts[0].Name != "status" || dicts[1].Name != "city" {
+		t.Fatalf("unexpected dictionary columns: %+v", dicts)
+	}
+	if dicts[0].Distinct != 3 || strings.Join(dicts[0].Values, ",") != "PAID,SHIPPED,WAIT_PAYMENT" {
+		t.Errorf("unexpected status dictionary: %+v", dicts[0])
+	}
+	if dicts[1].Distinct != 50 {
+		t.Errorf("unexpected city dictionary: %+v", dicts[1])
+	}
+	if !sequentialInsert(desc) {
+		t.Error("id is auto_increment primary key")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCompressionPlan(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	desc := &database.TableDesc{
+		Name: "logs",
+		DescValues: []database.TableDescValue{
+			{Field: "id", Type: "bigint(20)", Key: "PRI", Extra: "auto_increment"},
+			{Field: "msg", Type: "text"},
+		},
+	}
+	cold := &compressionIO{TableIOStat: database.TableIOStat{Read: 95, Insert: 5}, Source: "performance_schema"}
+	hot := &compressionIO{TableIOStat: database.TableIOStat{Read: 50, Insert: 10, Update: 40}, Source: "workload"}
+
+	// 未采样时根据列类型给出建议
+	rule, ok := compressionPlan("sakila", "logs", 20000000, 4096, desc, cold, -1, nil)
+	if !ok || rule.Severity != "L2" || !strings.Contains(rule.Case, "ALTER TABLE `sakila`.`logs` ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8;") ||
+		!strings.Contains(rule.Case, "ALTER TABLE `sakila`.`logs` COMPRESSION='zlib';") ||
+		!strings.Contains(rule.Content, "写入次数占读写总次数的 5.0%") || !strings.Contains(rule.Content, "-sampling") {
+		t.Errorf("unexpected plan: %+v", rule)
+	}
+
+	// 压缩比较好时使用更小的 KEY_BLOCK_SIZE
+	rule, ok = compressionPlan("sakila", "logs", 20000000, 4096, desc, cold, 0.2, nil)
+	if !ok || !strings.Contains(rule.Case, "KEY_BLOCK_SIZE=4;") || !strings.Contains(rule.Content, "预计可节省 3276 MB") {
+		t.Errorf("unexpected plan: %+v", rule)
+	}
+
+	// 压缩比一般时只建议透明页压缩
+	rule, ok = compressionPlan("sakila", "logs", 20000000, 4096, desc, cold, 0.6, nil)
+	if !ok || strings.Contains(rule.Case, "ROW_FORMAT=COMPRESSED") || !strings.Contains(rule.Summary, "透明页压缩") {
+		t.Errorf("unexpected plan: %+v", rule)
+	}
+
+	// 难以压缩且没有字典编码候选列
+	if rule, ok = compressionPlan("sakila", "logs", 20000000, 4096, desc, cold, 0.95, nil); ok {
+		t.Errorf("incompressible table should not be compressed: %+v", rule)
+	}
+
+	// 难以压缩时仍可以对低基数列使用字典编码
+	dicts := []dictionaryColumn{{Name: "level", Type: "varchar(16)", Distinct: 3, AvgLen: 5, Values: []string{"ERROR", "INFO", "WARN"}}}
+	rule, ok = compressionPlan("sakila", "logs", 20000000, 4096, desc, cold, 0.95, dicts)
+	if !ok || rule.Severity != "L1" || rule.Case != "ALTER TABLE `sakila`.`logs` MODIFY `level` ENUM('ERROR', 'INFO', 'WARN');" {
+		t.Errorf("unexpected plan: %+v", rule)
+	}
+
+	// 写入频繁的表不建议压缩
+	if rule, ok = compressionPlan("sakila", "logs", 20000000, 4096, desc, hot, 0.2, nil); ok {
+		t.Errorf("hot table should not be compressed: %+v", rule)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestCompressedTablePlan(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	hot := &compressionIO{TableIOStat: database.TableIOStat{Read: 50, Insert: 10, Update: 40}, Source: "workload"}
+	rule, ok := compressedTablePlan("sakila", "logs", "ROW_FORMAT=COMPRESSED", hot, true)
+	if !ok || rule.Severity != "L3" || rule.Case != "ALTER TABLE `sakila`.`logs` ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=0;" ||
+		!strings.Contains(rule.Content, "随机写入次数占读写总次数的 40.0%") {
+		t.Errorf("unexpected plan: %+v", rule)
+	}
+
+	rule, ok = compressedTablePlan("sakila", "logs", "COMPRESSION='zlib'", hot, true)
+	if !ok || !strings.Contains(rule.Case, "COMPRESSION='None';\nOPTIMIZE TABLE `sakila`.`logs`;") {
+		t.Errorf("unexpected plan: %+v", rule)
+	}
+
+	// 只有自增主键的 INSERT，属于顺序写入
+	appendOnly := &compressionIO{TableIOStat: database.TableIOStat{Read: 10, Insert: 90}, Source: "workload"}
+	if rule, ok = compressedTablePlan("sakila", "logs", "ROW_FORMAT=COMPRESSED", appendOnly, true); ok {
+		t.Errorf("append only table should keep compression: %+v", rule)
+	}
+	if _, ok = compressedTablePlan("sakila", "logs", "ROW_FORMAT=COMPRESSED", appendOnly, false); !ok {
+		t.Error("random primary key inserts should be flagged")
+	}
+	if _, ok = compressedTablePlan("sakila", "logs", "ROW_FORMAT=COMPRESSED", nil, false); ok {
+		t.Error("no io stat should not be flagged")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+// createTableLike 返回 SQL 中的 CREATE TABLE ... LIKE，不是时返回 nil
+// LIKE 建表的列、索引及表选项都来自源表，依赖建表语句内容的规则需要跳过
+func (q *Query4Audit) createTableLike() *tidb.CreateTableStmt {
+	for _, stmt := range q.TiStmt {
+		if node, ok := stmt.(*tidb.CreateTableStmt); ok && node.ReferTable != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// RuleCreateTableSelect TBL.012
+func (q *Query4Audit) RuleCreateTableSelect() Rule {
+	var rule = q.RuleOK()
+	for _, stmt := range q.TiStmt {
+		node, ok := stmt.(*tidb.CreateTableStmt)
+		if !ok || node.Select == nil {
+			continue
+		}
+		rule = HeuristicRules["TBL.012"]
+		var fixes []string
+		if !createTableHasKey(node) {
+			fixes = append(fixes, fmt.Sprintf("`%s` 表没有定义主键和索引。", node.Table.Name.O))
+		}
+		// MariaDB 的 GTID 没有该限制
+		if common.TargetDB().Unsupported(80021, 1) {
+			fixes = append(fixes, "目标数据库开启 enforce_gtid_consistency 时不允许执行该语句。")
+		}
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// createTableHasKey 建表语句中是否定义了主键或索引，包括列定义中的 PRIMARY KEY, UNIQUE
+func createTableHasKey(node *tidb.CreateTableStmt) bool {
+	for _, constraint := range node.Constraints {
+		if constraint.Tp != tidb.ConstraintForeignKey && constraint.Tp != tidb.ConstraintCheck {
+			return true
+		}
+	}
+	for _, col := range node.Cols {
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey || opt.Tp == tidb.ColumnOptionUniqKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RuleCreateTableLike TBL.013
+func (q *Query4Audit) RuleCreateTableLike() Rule {
+	var rule = q.RuleOK()
+	if q.createTableLike() != nil {
+		rule = HeuristicRules["TBL.013"]
+	}
+	return rule
+}
+
+// RuleCreateTableLike TBL.013
+// 连接线上环境时对源表的建表语句执行启发式规则，并说明不会被复制的外键
+func (idxAdv *IndexAdvisor) RuleCreateTableLike() Rule {
+	rule := HeuristicRules["OK"]
+	if common.Config.OnlineDSN.Disable {
+		return rule
+	}
+	var node *tidb.CreateTableStmt
+	for _, stmt := range idxAdv.TiStmt {
+		if ct, ok := stmt.(*tidb.CreateTableStmt); ok && ct.ReferTable != nil {
+			node = ct
+		}
+	}
+	if node == nil {
+		return rule
+	}
+
+	conn := idxAdv.rEnv
+	if node.ReferTable.Schema.O != "" {
+		conn.Database = node.ReferTable.Schema.O
+	}
+	src := node.ReferTable.Name.O
+	var fixes []string
+	ddl, err := conn.ShowCreateTable(src)
+	if err != nil || ddl == "" {
+		common.Log.Warn("RuleCreateTableLike ShowCreateTable Error: %v", err)
+		return rule
+	}
+	suggest := schemaTableSuggest(ddl)
+	var items []string
+	for item := range suggest {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	var problems []string
+	for _, item := range items {
+		problems = append(problems, fmt.Sprintf("%s %s", item, suggest[item].Summary))
+	}
+	if len(problems) > 0 {
+		fixes = append(fixes, fmt.Sprintf("源表 `%s` 的结构存在以下问题，会一并带入新表：%s。", src, strings.Join(problems, "；")))
+	}
+
+	fks, err := conn.ShowForeignKeys(src)
+	if err != nil {
+		common.Log.Warn("RuleCreateTableLike ShowForeignKeys Error: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, fk := range fks {
+		names[fk.ConstraintName] = true
+	}
+	if len(names) > 0 {
+		fixes = append(fixes, fmt.Sprintf("源表 `%s` 上的 %d 个外键不会被复制。", src, len(names)))
+	}
+
+	if len(fixes) > 0 {
+		rule = HeuristicRules["TBL.013"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
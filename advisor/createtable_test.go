@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestRuleCreateTableSelect(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	cases := []struct {
+		target string
+		sql    string
+		want   string // 为空表示不给出建议
+		fix    string
+	}{
+		{"", "CREATE TABLE film_bak AS SELECT * FROM film", "TBL.012", "`film_bak` 表没有定义主键和索引。"},
+		{"mysql:8.0.20", "CREATE TABLE film_bak (film_id INT PRIMARY KEY) SELECT film_id FROM film", "TBL.012", "目标数据库开启 enforce_gtid_consistency 时不允许执行该语句。"},
+		{"mariadb:10.6", "CREATE TABLE film_bak (KEY idx_id (film_id)) SELECT film_id FROM film", "TBL.012", ""},
+		{"", "CREATE TABLE film_bak (film_id INT PRIMARY KEY)", "", ""},
+		{"", "INSERT INTO film_bak SELECT * FROM film", "", ""},
+	}
+	for _, c := range cases {
+		common.Config.Target = c.target
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := q.RuleCreateTableSelect()
+		if c.want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, want OK, got: %s", c.sql, rule.Item)
+			}
+			continue
+		}
+		if rule.Item != c.want || !strings.HasSuffix(rule.Content, c.fix) ||
+			c.fix == "" && rule.Content != HeuristicRules["TBL.012"].Content {
+			t.Errorf("SQL: %s, got: %+v", c.sql, rule)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestRuleCreateTableLike(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := [][]string{
+		{
+			"CREATE TABLE film_bak LIKE film",
+			"CREATE TABLE IF NOT EXISTS sakila.film_bak LIKE sakila.film",
+		},
+		{
+			"CREATE TABLE film_bak (film_id INT PRIMARY KEY)",
+			"CREATE TABLE film_bak AS SELECT * FROM film",
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule := q.RuleCreateTableLike(); rule.Item != "TBL.013" {
+			t.Error("Rule not match:", rule.Item, "Expect : TBL.013")
+		}
+		// 存储引擎来自源表
+		if rule := q.RuleAllowEngine(); rule.Item != "OK" {
+			t.Error("Rule not match:", rule.Item, "Expect : OK")
+		}
+	}
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule := q.RuleCreateTableLike(); rule.Item != "OK" {
+			t.Error("Rule not match:", rule.Item, "Expect : OK")
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
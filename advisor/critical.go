@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// CriticalTables 返回 tables 中匹配 critical-objects 的库表，tables 为 SchemaMetaInfo 返回的 `db`.`table` 格式
+func CriticalTables(tables []string) []string {
+	var critical []string
+	if len(common.Config.CriticalObjects) == 0 {
+		return critical
+	}
+	var patterns []*regexp.Regexp
+	for _, p := range common.Config.CriticalObjects {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + strings.TrimSpace(p))
+		if err != nil {
+			common.Log.Warning("critical-objects format error: '%s', %v", p, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	for _, tb := range tables {
+		name := strings.Replace(tb, "`", "", -1)
+		for _, re := range patterns {
+			if re.MatchString(name) {
+				critical = append(critical, tb)
+				break
+			}
+		}
+	}
+	return critical
+}
+
+// EscalateCritical 提升涉及关键库表的建议级别，critical-severity 中配置的规则提升至配置的级别，其他规则提升一级，最高为 L8
+// L0 及 OK, EXP.000, Profiling, Trace 等信息类建议不提升，返回是否有建议被提升
+func EscalateCritical(tables []string, suggests ...map[string]Rule) bool {
+	if len(tables) == 0 {
+		return false
+	}
+	escalated := false
+	for _, suggest := range suggests {
+		for item, rule := range suggest {
+			level := severityLevel(rule.Severity)
+			if !isFindingItem(item) || level == 0 {
+				continue
+			}
+			target := level + 1
+			if s, ok := common.Config.CriticalSeverity[item]; ok {
+				target = severityLevel(s)
+			}
+			if target > 8 {
+				target = 8
+			}
+			if target <= level {
+				continue
+			}
+			rule.Content = strings.TrimSpace(rule.Content + " " + ruleMessage("critical", map[string]interface{}{
+				"Tables": tables,
+				"From":   rule.Severity,
+				"To":     fmt.Sprintf("L%d", target),
+			}))
+			rule.Severity = fmt.Sprintf("L%d", target)
+			suggest[item] = rule
+			escalated = true
+		}
+	}
+	return escalated
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestCriticalTables(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgObjects := common.Config.CriticalObjects
+	defer func() { common.Config.CriticalObjects = orgObjects }()
+
+	tables := []string{"`payments`.`orders`", "`sakila`.`film`", "`sakila`.`user_account`"}
+	common.Config.CriticalObjects = nil
+	if critical := CriticalTables(tables); len(critical) != 0 {
+		t.Errorf("want no critical table, got %v", critical)
+	}
+	common.Config.CriticalObjects = []string{`^PAYMENTS\.`, `\.user_`, "["}
+	critical := CriticalTables(tables)
+	if strings.Join(critical, ",") != "`payments`.`orders`,`sakila`.`user_account`" {
+		t.Errorf("unexpected critical tables: %v", critical)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestEscalateCritical(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSeverity := common.Config.CriticalSeverity
+	defer func() { common.Config.CriticalSeverity = orgSeverity }()
+	common.Config.CriticalSeverity = map[string]string{"CLA.015": "L8", "COL.001": "L1"}
+
+	heuristic := map[string]Rule{
+		"CLA.015": {Item: "CLA.015", Severity: "L4", Content: "UPDATE 不指定 WHERE 条件一般是致命的，请您三思后行"},
+		"ALI.001": {Item: "ALI.001", Severity: "L0"},
+		"COL.001": {Item: "COL.001", Severity: "L1"},
+		"KWR.001": {Item: "KWR.001", Severity: "L2"},
+	}
+	exp := map[string]Rule{
+		"EXP.004": {Item: "EXP.004", Severity: "L8"},
+		"EXP.000": {Item: "EXP.000", Severity: "L1"},
+	}
+	if EscalateCritical(nil, heuristic, exp) {
+		t.Error("should not escalate without critical tables")
+	}
+	if !EscalateCritical([]string{"`payments`.`orders`"}, heuristic, exp) {
+		t.Error("want escalated")
+	}
+	want := map[string]string{"CLA.015": "L8", "ALI.001": "L0", "COL.001": "L1", "KWR.001": "L3", "EXP.004": "L8", "EXP.000": "L1"}
+	for _, s := range []map[string]Rule{heuristic, exp} {
+		for item, rule := range s {
+			if rule.Severity != want[item] {
+				t.Errorf("%s want %s, got %s", item, want[item], rule.Severity)
+			}
+		}
+	}
+	if !strings.HasSuffix(heuristic["CLA.015"].Content, "Touches critical tables `payments`.`orders`, severity raised from L4 to L8.") {
+		t.Errorf("unexpected content: %s", heuristic["CLA.015"].Content)
+	}
+
+	// 补充说明使用 -lang 指定的语言
+	defer func() { common.LogIfError(LoadRuleLocale(common.Config.Lang, ""), "") }()
+	if err := LoadRuleLocale("zh-CN", ""); err != nil {
+		t.Fatal(err)
+	}
+	heuristic = map[string]Rule{"KWR.001": {Item: "KWR.001", Severity: "L2"}}
+	EscalateCritical([]string{"`payments`.`orders`"}, heuristic, nil)
+	if heuristic["KWR.001"].Content != "涉及关键库表 `payments`.`orders`，级别由 L2 提升为 L3。" {
+		t.Errorf("unexpected content: %s", heuristic["KWR.001"].Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
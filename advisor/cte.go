@@ -0,0 +1,386 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// vitess 及 TiDB 的解析器都不支持 WITH，CTE 相关的检查基于词法分析
+
+// cteToken 带位置的词法单元，start, end 为在 SQL 中的起止位置，关键字的 val 为小写
+type cteToken struct {
+	typ        int
+	val        string
+	start, end int
+}
+
+// cteDef WITH 子句中的一个 CTE
+type cteDef struct {
+	name    string
+	columns bool       // 是否指定了列名，如 WITH t (a, b) AS (...)
+	body    string     // AS 后括号中的查询
+	tokens  []cteToken // body 的词法单元
+}
+
+// cteQuery 使用 WITH 子句的查询
+type cteQuery struct {
+	recursive bool
+	defs      []*cteDef
+	main      string     // WITH 子句之后的主查询
+	tokens    []cteToken // 主查询的词法单元
+}
+
+// cteTokens 对 SQL 进行词法分析，忽略注释
+func cteTokens(sql string) []cteToken {
+	var tokens []cteToken
+	tkn := sqlparser.NewStringTokenizer(sql)
+	for {
+		typ, val := tkn.Scan()
+		if typ == 0 || typ == sqlparser.LEX_ERROR {
+			break
+		}
+		if typ == sqlparser.COMMENT {
+			continue
+		}
+		t := cteToken{typ: typ, val: string(val), end: tkn.Position - 1}
+		if val == nil {
+			if typ > 255 {
+				t.val = ast.TokenString[typ]
+			} else {
+				t.val = string(rune(typ))
+			}
+		}
+		t.start = t.end - len(val)
+		if t.end > 0 && sql[t.end-1] == '`' {
+			t.start = strings.LastIndex(sql[:t.end-1], "`")
+		} else if val == nil {
+			t.start = t.end - len(t.val)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// matchParen 返回与 tokens[i] 的左括号匹配的右括号位置，没有匹配时返回 -1
+func matchParen(tokens []cteToken, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// parseCTE 解析 WITH 子句，不是 WITH 开头的查询或解析失败时返回 nil
+func parseCTE(sql string) *cteQuery {
+	tokens := cteTokens(sql)
+	if len(tokens) < 2 || tokens[0].val != "with" {
+		return nil
+	}
+	q := &cteQuery{}
+	i := 1
+	if strings.EqualFold(tokens[i].val, "recursive") {
+		q.recursive = true
+		i++
+	}
+	for i < len(tokens) {
+		def := &cteDef{name: tokens[i].val}
+		i++
+		if i < len(tokens) && tokens[i].val == "(" {
+			def.columns = true
+			if i = matchParen(tokens, i); i < 0 {
+				return nil
+			}
+			i++
+		}
+		if i+1 >= len(tokens) || tokens[i].val != "as" || tokens[i+1].val != "(" {
+			return nil
+		}
+		end := matchParen(tokens, i+1)
+		if end < 0 {
+			return nil
+		}
+		def.body = strings.TrimSpace(sql[tokens[i+1].end:tokens[end].start])
+		def.tokens = cteTokens(def.body)
+		q.defs = append(q.defs, def)
+		i = end + 1
+		if i < len(tokens) && tokens[i].val == "," {
+			i++
+			continue
+		}
+		break
+	}
+	if i >= len(tokens) {
+		return nil
+	}
+	q.main = sql[tokens[i].start:]
+	q.tokens = cteTokens(q.main)
+	return q
+}
+
+// cteRefs 返回 tokens 中作为表引用的 name 的位置，即 FROM, JOIN 之后或 FROM 中逗号之后的表名
+func cteRefs(tokens []cteToken, name string) []int {
+	var refs []int
+	for i, t := range tokens {
+		if t.typ != sqlparser.ID || !strings.EqualFold(t.val, name) || i == 0 {
+			continue
+		}
+		if i+1 < len(tokens) && tokens[i+1].val == "." {
+			continue
+		}
+		switch tokens[i-1].val {
+		case "from", "join":
+			refs = append(refs, i)
+		case ",":
+			if inFromList(tokens, i-1) {
+				refs = append(refs, i)
+			}
+		}
+	}
+	return refs
+}
+
+// inFromList 判断 tokens[i] 是否在 FROM 子句中，向前跳过同一层的括号直到遇到子句关键字
+func inFromList(tokens []cteToken, i int) bool {
+	depth := 0
+	for j := i; j >= 0; j-- {
+		switch tokens[j].val {
+		case ")":
+			depth++
+		case "(":
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case "from", "join":
+			if depth == 0 {
+				return true
+			}
+		case "select", "where", "on", "using", "group", "order", "having", "limit", "set", "values", "union":
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// selfRecursive 判断 CTE 是否引用了自身
+func (def *cteDef) selfRecursive() bool {
+	return len(cteRefs(def.tokens, def.name)) > 0
+}
+
+// cteRefCount CTE 在主查询及之后定义的 CTE 中被引用的次数
+func (q *cteQuery) cteRefCount(i int) int {
+	count := len(cteRefs(q.tokens, q.defs[i].name))
+	for _, def := range q.defs[i+1:] {
+		count += len(cteRefs(def.tokens, q.defs[i].name))
+	}
+	return count
+}
+
+// RuleCTEMultipleRefs CTE.001
+func (q *Query4Audit) RuleCTEMultipleRefs() Rule {
+	var rule = q.RuleOK()
+	cte := parseCTE(q.Query)
+	if cte == nil {
+		return rule
+	}
+	var fixes []string
+	for i, def := range cte.defs {
+		if cte.recursive && def.selfRecursive() {
+			continue
+		}
+		if n := cte.cteRefCount(i); n > 1 {
+			fixes = append(fixes, fmt.Sprintf("`%s` 被引用了 %d 次。", def.name, n))
+		}
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["CTE.001"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// RuleCTERecursion CTE.002
+// 检查递归 CTE 中引用自身的部分是否有 WHERE 或 LIMIT，WHERE 中与常量的比较超过递归次数上限时给出提示
+func (q *Query4Audit) RuleCTERecursion() Rule {
+	var rule = q.RuleOK()
+	cte := parseCTE(q.Query)
+	if cte == nil || !cte.recursive {
+		return rule
+	}
+	var fixes []string
+	for _, def := range cte.defs {
+		if !def.selfRecursive() {
+			continue
+		}
+		for _, part := range splitUnion(def.tokens) {
+			if len(cteRefs(part, def.name)) == 0 {
+				continue
+			}
+			where := -1
+			limit := false
+			depth := 0
+			for i, t := range part {
+				switch t.val {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				case "where":
+					if depth == 0 {
+						where = i
+					}
+				case "limit":
+					limit = limit || depth == 0
+				}
+			}
+			if where < 0 && !limit {
+				fixes = append(fixes, fmt.Sprintf("递归 CTE `%s` 引用自身的部分没有 WHERE 或 LIMIT 终止条件。", def.name))
+				continue
+			}
+			// MariaDB 使用 max_recursive_iterations 限制递归次数，默认值很大
+			if where < 0 || common.TargetDB().IsMariaDB() {
+				continue
+			}
+			if n := recursionBound(part[where+1:]); n > int64(RuleThreshold("CTE.002")) {
+				fixes = append(fixes, fmt.Sprintf("递归 CTE `%s` 的终止条件允许递归约 %d 次，超过 cte_max_recursion_depth(%d)。", def.name, n, RuleThreshold("CTE.002")))
+			}
+		}
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["CTE.002"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// splitUnion 按最外层的 UNION 拆分查询
+func splitUnion(tokens []cteToken) [][]cteToken {
+	var parts [][]cteToken
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch t.val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case "union":
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+				if start < len(tokens) && (tokens[start].val == "all" || tokens[start].val == "distinct") {
+					start++
+				}
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// recursionBound 从 WHERE 条件中找出形如 col < N, col <= N 的上限，没有时返回 0
+func recursionBound(tokens []cteToken) int64 {
+	var bound int64
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].typ != sqlparser.ID || (tokens[i+1].val != "<" && tokens[i+1].val != "<=") || tokens[i+2].typ != sqlparser.INTEGRAL {
+			continue
+		}
+		if n, err := strconv.ParseInt(tokens[i+2].val, 10, 64); err == nil && n > bound {
+			bound = n
+		}
+	}
+	return bound
+}
+
+// RuleCTEDerived CTE.003
+// 目标数据库不支持 CTE 时，将非递归 CTE 改写为派生表
+func (q *Query4Audit) RuleCTEDerived() Rule {
+	var rule = q.RuleOK()
+	// MySQL 8.0, MariaDB 10.2 开始支持 CTE
+	if !common.TargetDB().Unsupported(80000, 100200) {
+		return rule
+	}
+	cte := parseCTE(q.Query)
+	if cte == nil {
+		return rule
+	}
+	var fixes []string
+	bodies := make(map[string]string)
+	for i, def := range cte.defs {
+		// 递归 CTE 及指定了列名的 CTE 无法直接改写为派生表
+		if cte.recursive && def.selfRecursive() || def.columns {
+			return rule
+		}
+		if n := cte.cteRefCount(i); n > 1 {
+			fixes = append(fixes, fmt.Sprintf("`%s` 被引用了 %d 次，改写后每个派生表都会单独执行。", def.name, n))
+		}
+		bodies[strings.ToLower(def.name)] = replaceCTERefs(def.body, bodies)
+	}
+	rule = HeuristicRules["CTE.003"]
+	rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	rule.Case = replaceCTERefs(cte.main, bodies)
+	return rule
+}
+
+// replaceCTERefs 将 sql 中对 CTE 的引用替换为派生表，bodies 为小写的 CTE 名称到查询的映射
+func replaceCTERefs(sql string, bodies map[string]string) string {
+	tokens := cteTokens(sql)
+	type replace struct {
+		start, end int
+		text       string
+	}
+	var replaces []replace
+	for name, body := range bodies {
+		for _, i := range cteRefs(tokens, name) {
+			t := tokens[i]
+			text := fmt.Sprintf("(%s)", body)
+			// 没有别名时使用 CTE 名称作为派生表的别名
+			if i+1 >= len(tokens) || tokens[i+1].val != "as" && tokens[i+1].typ != sqlparser.ID {
+				text += " AS " + sql[t.start:t.end]
+			}
+			replaces = append(replaces, replace{t.start, t.end, text})
+		}
+	}
+	// 从后向前替换，避免位置变化
+	for i := 0; i < len(replaces); i++ {
+		for j := i + 1; j < len(replaces); j++ {
+			if replaces[j].start > replaces[i].start {
+				replaces[i], replaces[j] = replaces[j], replaces[i]
+			}
+		}
+	}
+	for _, r := range replaces {
+		sql = sql[:r.start] + r.text + sql[r.end:]
+	}
+	return sql
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestParseCTE(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	q := parseCTE("WITH RECURSIVE `a` (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM a WHERE n < 10), b AS (SELECT * FROM a) /* main */ SELECT * FROM b")
+	if q == nil || !q.recursive || len(q.defs) != 2 {
+		t.Fatalf("unexpected result: %+v", q)
+	}
+	if q.defs[0].name != "a" || !q.defs[0].columns || q.defs[0].body != "SELECT 1 UNION ALL SELECT n + 1 FROM a WHERE n < 10" ||
+		q.defs[1].name != "b" || q.defs[1].columns || q.defs[1].body != "SELECT * FROM a" || q.main != "SELECT * FROM b" {
+		t.Errorf("unexpected result: %+v %+v %s", q.defs[0], q.defs[1], q.main)
+	}
+	for _, sql := range []string{"SELECT * FROM t", "WITH t AS SELECT 1", "WITH t AS (SELECT 1"} {
+		if q = parseCTE(sql); q != nil {
+			t.Errorf("SQL: %s, want nil, got %+v", sql, q)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// CTE.001
+func TestRuleCTEMultipleRefs(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"WITH t AS (SELECT customer_id, SUM(amount) AS total FROM payment GROUP BY customer_id) SELECT * FROM t a JOIN t b ON a.total = b.total": "`t` 被引用了 2 次。",
+		"WITH t AS (SELECT 1 AS id), s AS (SELECT * FROM t) SELECT * FROM t, s":                                                                  "`t` 被引用了 2 次。",
+		"WITH t AS (SELECT 1 AS id) SELECT t.id FROM t WHERE t.id IN (SELECT id FROM film)":                                                      "",
+		"WITH RECURSIVE t AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM t WHERE n < 10) SELECT * FROM t":                                         "",
+		"SELECT * FROM t a JOIN t b ON a.id = b.id":                                                                                              "",
+	}
+	for sql, want := range sqls {
+		q, _ := NewQuery4Audit(sql)
+		rule := q.RuleCTEMultipleRefs()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "CTE.001" || !strings.Contains(rule.Content, want)) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// CTE.002
+func TestRuleCTERecursion(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	common.Config.Target = "mysql:8.0.22"
+	sqls := map[string]string{
+		"WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq) SELECT * FROM seq":                "递归 CTE `seq` 引用自身的部分没有 WHERE 或 LIMIT 终止条件。",
+		"WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq WHERE n < 5000) SELECT * FROM seq": "允许递归约 5000 次，超过 cte_max_recursion_depth(1000)。",
+		"WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq WHERE n < 100) SELECT * FROM seq":  "",
+		"WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq LIMIT 100) SELECT * FROM seq":      "",
+		"WITH seq AS (SELECT 1 AS n) SELECT * FROM seq":                                                          "",
+	}
+	for sql, want := range sqls {
+		q, _ := NewQuery4Audit(sql)
+		rule := q.RuleCTERecursion()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "CTE.002" || !strings.Contains(rule.Content, want)) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Content)
+		}
+	}
+
+	// MariaDB 不受 cte_max_recursion_depth 限制
+	common.Config.Target = "mariadb:10.5"
+	q, _ := NewQuery4Audit("WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq WHERE n < 5000) SELECT * FROM seq")
+	if rule := q.RuleCTERecursion(); rule.Item != "OK" {
+		t.Errorf("want OK, got %s %s", rule.Item, rule.Content)
+	}
+	common.Config.Target = orgTarget
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// CTE.003
+func TestRuleCTEDerived(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	common.Config.Target = "mysql:5.7.30"
+	sqls := map[string]string{
+		"WITH t AS (SELECT customer_id, SUM(amount) AS total FROM payment GROUP BY customer_id) SELECT * FROM t WHERE total > 100": "SELECT * FROM (SELECT customer_id, SUM(amount) AS total FROM payment GROUP BY customer_id) AS t WHERE total > 100",
+		"WITH t AS (SELECT id FROM film), s AS (SELECT * FROM t WHERE id > 1) SELECT * FROM s x JOIN t ON x.id = t.id":             "SELECT * FROM (SELECT * FROM (SELECT id FROM film) AS t WHERE id > 1) x JOIN (SELECT id FROM film) AS t ON x.id = t.id",
+		"WITH t (a) AS (SELECT 1) SELECT * FROM t":                                                             "",
+		"WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq WHERE n < 10) SELECT * FROM seq": "",
+	}
+	for sql, want := range sqls {
+		q, _ := NewQuery4Audit(sql)
+		rule := q.RuleCTEDerived()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "CTE.003" || rule.Case != want) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Case)
+		}
+	}
+
+	// 目标数据库支持 CTE
+	common.Config.Target = "mysql:8.0.22"
+	q, _ := NewQuery4Audit("WITH t AS (SELECT 1 AS id) SELECT * FROM t")
+	if rule := q.RuleCTEDerived(); rule.Item != "OK" {
+		t.Errorf("want OK, got %s %s", rule.Item, rule.Content)
+	}
+	common.Config.Target = orgTarget
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
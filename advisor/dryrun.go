@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+	"github.com/XiaoMi/soar/env"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// DryRunResult 变更脚本中单条语句在测试环境中的执行结果
+type DryRunResult struct {
+	Query    string
+	Error    error
+	Duration time.Duration
+	Suggest  map[string]Rule // 启发式建议
+}
+
+// MigrationDryRun 将 rEnv 当前库的表结构复制到测试环境后按顺序执行 buf 中的变更脚本
+// 某条语句执行失败后继续执行后面的语句，返回每条语句的执行结果，以及执行前后当前库的 Schema 差异
+func MigrationDryRun(vEnv *env.VirtualEnv, rEnv *database.Connector, buf string) ([]DryRunResult, []string, error) {
+	common.Log.Debug("Enter:  MigrationDryRun, Caller: %s", common.Caller())
+	if common.Config.TestDSN.Disable {
+		return nil, nil, fmt.Errorf("migration-dry-run need an available TestDSN")
+	}
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *rEnv
+	db := tmpOnline.Database
+	if err := vEnv.BuildSchemaEnv(&tmpOnline); err != nil {
+		return nil, nil, err
+	}
+	sandbox := *vEnv.Connector
+	sandbox.Database = vEnv.DBRef[db]
+	before := LoadSchema(&sandbox, "")
+
+	var results []DryRunResult
+	for _, sql := range splitScript(buf) {
+		duration, err := vEnv.Execute(&tmpOnline, sql)
+		results = append(results, DryRunResult{
+			Query:    sql,
+			Error:    err,
+			Duration: duration,
+			Suggest:  schemaTableSuggest(sql),
+		})
+	}
+
+	// 只比较 OnlineDsn 指定的库，USE 切换到的其他库不做比较
+	diff, err := SchemaDiff(before, LoadSchema(&sandbox, ""))
+	return results, diff, err
+}
+
+// splitScript 按分隔符切分变更脚本，支持 DELIMITER 命令，忽略注释及空语句
+func splitScript(buf string) []string {
+	var sqls []string
+	delimiter := common.Config.Delimiter
+	for strings.TrimSpace(buf) != "" {
+		_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(delimiter))
+		buf = string(bufBytes)
+		if d, ok := ast.ParseDelimiter(sql); ok {
+			delimiter = d
+			continue
+		}
+		sql = strings.TrimSpace(database.RemoveSQLComments(sql))
+		sql = strings.TrimSpace(strings.TrimSuffix(sql, delimiter))
+		if sql != "" {
+			sqls = append(sqls, sql)
+		}
+	}
+	return sqls
+}
+
+// FormatMigrationDryRun 输出 migration-dry-run 报告，包含执行汇总、每条语句的执行结果及启发式建议、执行后的 Schema 变化
+func FormatMigrationDryRun(results []DryRunResult, diff []string) string {
+	if len(results) == 0 {
+		return "未找到需要执行的语句"
+	}
+	failed := 0
+	var total time.Duration
+	for _, r := range results {
+		total += r.Duration
+		if r.Error != nil {
+			failed++
+		}
+	}
+	buf := []string{"# Dry Run 汇总\n"}
+	buf = append(buf, fmt.Sprintf("* **语句数量:** %d", len(results)))
+	buf = append(buf, fmt.Sprintf("* **执行失败:** %d", failed))
+	buf = append(buf, fmt.Sprintf("* **总耗时:** %s\n", total.Round(time.Millisecond)))
+
+	for _, r := range results {
+		buf = append(buf, fmt.Sprintf("# Query: %s\n", query.Id(Fingerprint(r.Query))))
+		buf = append(buf, fmt.Sprintf("```sql\n%s\n```\n", r.Query))
+		if r.Error != nil {
+			buf = append(buf, fmt.Sprintf("* **执行失败:** %s\n", r.Error.Error()))
+		} else {
+			buf = append(buf, fmt.Sprintf("* **执行成功:** 耗时 %s\n", r.Duration.Round(time.Millisecond)))
+		}
+		buf = append(buf, common.Score(SuggestScore(r.Suggest))+"\n")
+		buf = append(buf, formatHeuristicSuggest(r.Suggest)...)
+	}
+
+	buf = append(buf, "# Schema 变化\n")
+	if len(diff) == 0 {
+		buf = append(buf, "执行前后表结构没有变化")
+	} else {
+		buf = append(buf, fmt.Sprintf("```sql\n%s\n```\n", strings.Join(diff, "\n")))
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestSplitScript(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	buf := `-- add column
+ALTER TABLE film ADD COLUMN c INT;
+
+DELIMITER $$
+CREATE TRIGGER trg BEFORE INSERT ON film FOR EACH ROW BEGIN SET NEW.c = 1; END$$
+DELIMITER ;
+DROP TABLE t;`
+	sqls := splitScript(buf)
+	want := []string{
+		"ALTER TABLE film ADD COLUMN c INT",
+		"CREATE TRIGGER trg BEFORE INSERT ON film FOR EACH ROW BEGIN SET NEW.c = 1; END",
+		"DROP TABLE t",
+	}
+	if strings.Join(sqls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want %q, got %q", want, sqls)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatMigrationDryRun(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	results := []DryRunResult{
+		{Query: "ALTER TABLE film ADD COLUMN c INT", Duration: 120 * time.Millisecond, Suggest: schemaTableSuggest("ALTER TABLE film ADD COLUMN c INT")},
+		{Query: "ALTER TABLE film DROP COLUMN unknown", Error: errors.New("Error 1091: Can't DROP 'unknown'; check that column/key exists")},
+	}
+	str := FormatMigrationDryRun(results, []string{"ALTER TABLE `film` ADD COLUMN `c` INT AFTER `last_update`;"})
+	for _, want := range []string{
+		"* **语句数量:** 2",
+		"* **执行失败:** 1",
+		"* **执行成功:** 耗时 120ms",
+		"* **执行失败:** Error 1091",
+		"ALTER TABLE `film` ADD COLUMN `c` INT AFTER `last_update`;",
+	} {
+		if !strings.Contains(str, want) {
+			t.Errorf("want %s in:\n%s", want, str)
+		}
+	}
+	if str = FormatMigrationDryRun(results, nil); !strings.Contains(str, "执行前后表结构没有变化") {
+		t.Errorf("unexpected result:\n%s", str)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// 部分规则的建议以 InnoDB 为前提，如行锁、间隙锁、Online DDL 及 COUNT(*) 需要扫描全表，
+// 配置了 online-dsn 时根据表实际的存储引擎调整或去除这些建议
+
+// tableLockEngines 只支持表级锁、不支持事务的存储引擎，行锁相关的建议不适用
+var tableLockEngines = map[string]bool{
+	"MYISAM":     true,
+	"MEMORY":     true,
+	"ARCHIVE":    true,
+	"MRG_MYISAM": true,
+	"CSV":        true,
+}
+
+// exactCountEngines 保存了表的精确行数的存储引擎，不带 WHERE 条件的 COUNT(*) 不需要扫描表
+var exactCountEngines = map[string]bool{
+	"MYISAM":  true,
+	"MEMORY":  true,
+	"ARCHIVE": true,
+}
+
+// rowLockRules 以 InnoDB 行锁为前提的建议，表级锁的存储引擎上不给出
+var rowLockRules = []string{"LCK.003", "LCK.004", "LCK.005"}
+
+// alterOnlineRe ALT.002 合并后的语句中 InnoDB Online DDL 相关的子句
+var alterOnlineRe = regexp.MustCompile(`, ALGORITHM=(INSTANT|INPLACE, LOCK=NONE)`)
+
+// TableEngines 使用线上环境的 SHOW TABLE STATUS 查询 SQL 涉及的表的存储引擎，tables 为 `db`.`table` 格式
+// 返回值的 key 与 tables 相同，引擎名称统一转为大写，视图及查询失败的表不返回，未配置 online-dsn 时返回空
+func TableEngines(conn *database.Connector, tables []string) map[string]string {
+	engines := make(map[string]string)
+	if common.Config.OnlineDSN.Disable || conn == nil {
+		return engines
+	}
+	for _, name := range tables {
+		db, tb := splitTableName(name)
+		if tb == "" || tb == "dual" {
+			continue
+		}
+		tmp := *conn
+		if db != "" {
+			tmp.Database = db
+		}
+		status, err := tmp.ShowTableStatus(tb)
+		if err != nil {
+			common.Log.Debug("TableEngines ShowTableStatus %s Error: %v", name, err)
+			continue
+		}
+		if len(status.Rows) > 0 && len(status.Rows[0].Engine) > 0 {
+			engines[name] = strings.ToUpper(string(status.Rows[0].Engine))
+		}
+	}
+	return engines
+}
+
+// splitTableName 将 `db`.`table` 拆分为库名及表名
+func splitTableName(name string) (string, string) {
+	name = strings.Replace(name, "`", "", -1)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// GateEngineRules 根据表实际的存储引擎调整依赖引擎的建议，engines 为 TableEngines 的返回值，为空时不做调整
+// 新增的 FUN.002 添加在第一个 suggest 中
+func GateEngineRules(q *Query4Audit, engines map[string]string, suggests ...map[string]Rule) {
+	if len(engines) == 0 || len(suggests) == 0 {
+		return
+	}
+	var names []string
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// 所有表都只支持表级锁时行锁相关的建议不适用，ROCKSDB 不使用间隙锁
+	tableLock, rocksdb := true, false
+	var nonInnoDB []string
+	for _, name := range names {
+		engine := engines[name]
+		if !tableLockEngines[engine] {
+			tableLock = false
+		}
+		if engine == "ROCKSDB" {
+			rocksdb = true
+		}
+		if engine != "INNODB" {
+			nonInnoDB = append(nonInnoDB, fmt.Sprintf("%s(%s)", name, engine))
+		}
+	}
+
+	for _, suggest := range suggests {
+		for _, item := range rowLockRules {
+			if _, ok := suggest[item]; ok && tableLock {
+				delete(suggest, item)
+			}
+		}
+		if rule, ok := suggest["LCK.001"]; ok && tableLock {
+			rule.Content = strings.Join([]string{rule.Content,
+				fmt.Sprintf("%s 只支持表级锁，执行期间会阻塞其他会话对整张表的写入。", strings.Join(nonInnoDB, ", "))}, " ")
+			suggest["LCK.001"] = rule
+		}
+		if rule, ok := suggest["LCK.003"]; ok && rocksdb {
+			rule.Content = strings.Join([]string{rule.Content,
+				"ROCKSDB 不使用间隙锁，但仍会对扫描过的记录加锁。"}, " ")
+			suggest["LCK.003"] = rule
+		}
+		// 非 InnoDB 的表不支持 ALGORITHM=INSTANT 及 LOCK=NONE，合并后的语句去掉这些子句
+		if rule, ok := suggest["ALT.002"]; ok && len(nonInnoDB) > 0 && alterOnlineRe.MatchString(rule.Case) {
+			rule.Case = alterOnlineRe.ReplaceAllString(rule.Case, "")
+			rule.Content = strings.Join([]string{rule.Content,
+				fmt.Sprintf("%s 不支持 InnoDB 的 Online DDL，变更期间会阻塞写入，合并后的语句已去掉 ALGORITHM, LOCK 子句。", strings.Join(nonInnoDB, ", "))}, " ")
+			suggest["ALT.002"] = rule
+		}
+	}
+
+	// SQL 涉及的表都使用 ROCKSDB 时改用 MyRocks 的建议
+	if allRocksDB(engines) {
+		for _, suggest := range suggests {
+			rocksdbIndexAdvice(suggest)
+		}
+		for item, rule := range rocksdbRules(q, engines) {
+			suggests[0][item] = rule
+		}
+	}
+
+	// 集群中写入非 InnoDB 的表
+	if rule, ok := clusterWriteRule(q, engines); ok {
+		if _, exist := suggests[0]["CLU.004"]; !exist && !IsIgnoreRule("CLU.004") {
+			suggests[0]["CLU.004"] = rule
+		}
+	}
+
+	if rule, ok := countStarRule(q, engines); ok {
+		if _, exist := suggests[0]["FUN.002"]; !exist && !IsIgnoreRule("FUN.002") {
+			suggests[0]["FUN.002"] = rule
+		}
+	}
+}
+
+// countStarRule 单表不带 WHERE 条件的 COUNT(*)，表的存储引擎没有保存精确行数时同样需要扫描全表（FUN.002）
+func countStarRule(q *Query4Audit, engines map[string]string) (Rule, bool) {
+	if q == nil || len(engines) != 1 {
+		return Rule{}, false
+	}
+	sel, ok := q.Stmt.(*sqlparser.Select)
+	if !ok || sel.Where != nil || len(sel.GroupBy) > 0 || len(sel.From) != 1 {
+		return Rule{}, false
+	}
+	from, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return Rule{}, false
+	}
+	if _, ok := from.Expr.(sqlparser.TableName); !ok {
+		return Rule{}, false
+	}
+	count := false
+	for _, expr := range sel.SelectExprs {
+		if e, ok := expr.(*sqlparser.AliasedExpr); ok {
+			if f, ok := e.Expr.(*sqlparser.FuncExpr); ok && f.Name.Lowered() == "count" {
+				count = true
+			}
+		}
+	}
+	if !count {
+		return Rule{}, false
+	}
+	for name, engine := range engines {
+		if exactCountEngines[engine] {
+			return Rule{}, false
+		}
+		rule := HeuristicRules["FUN.002"]
+		rule.Content = strings.Join([]string{rule.Content,
+			fmt.Sprintf("%s 使用 %s 引擎，没有保存表的精确行数，不带 WHERE 条件的 COUNT(*) 同样需要扫描全表。", name, engine)}, " ")
+		return rule, true
+	}
+	return Rule{}, false
+}
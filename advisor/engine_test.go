@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestGateEngineRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	q, err := NewQuery4Audit("SELECT * FROM tbl WHERE status = 0 FOR UPDATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSuggest := func() map[string]Rule {
+		return map[string]Rule{
+			"LCK.001": HeuristicRules["LCK.001"],
+			"LCK.003": HeuristicRules["LCK.003"],
+			"LCK.005": HeuristicRules["LCK.005"],
+			"ALT.002": {Item: "ALT.002", Case: "ALTER TABLE `tbl` ADD COLUMN `c` int, ALGORITHM=INSTANT;\nALTER TABLE `tbl` ADD INDEX `idx_c` (`c`), ALGORITHM=INPLACE, LOCK=NONE;"},
+		}
+	}
+
+	// 未查询到存储引擎时不调整
+	suggest := newSuggest()
+	GateEngineRules(q, nil, suggest)
+	if len(suggest) != 4 || suggest["ALT.002"].Case != newSuggest()["ALT.002"].Case {
+		t.Errorf("want suggest unchanged, got %v", suggest)
+	}
+
+	// 表级锁的存储引擎去除行锁相关的建议，Online DDL 子句不适用
+	suggest = newSuggest()
+	GateEngineRules(q, map[string]string{"`sakila`.`tbl`": "MYISAM"}, suggest)
+	if _, ok := suggest["LCK.003"]; ok {
+		t.Error("want LCK.003 removed for MyISAM")
+	}
+	if _, ok := suggest["LCK.005"]; ok {
+		t.Error("want LCK.005 removed for MyISAM")
+	}
+	if !strings.Contains(suggest["LCK.001"].Content, "`sakila`.`tbl`(MYISAM) 只支持表级锁") {
+		t.Errorf("want table lock note, got %s", suggest["LCK.001"].Content)
+	}
+	if c := suggest["ALT.002"].Case; strings.Contains(c, "ALGORITHM") || strings.Contains(c, "LOCK=NONE") {
+		t.Errorf("want online DDL clauses removed, got %s", c)
+	}
+
+	// InnoDB 不调整，ROCKSDB 保留行锁建议并说明没有间隙锁
+	suggest = newSuggest()
+	GateEngineRules(q, map[string]string{"`sakila`.`tbl`": "INNODB"}, suggest)
+	if len(suggest) != 4 || !strings.Contains(suggest["ALT.002"].Case, "ALGORITHM=INSTANT") {
+		t.Errorf("want suggest unchanged for InnoDB, got %v", suggest)
+	}
+	suggest = newSuggest()
+	GateEngineRules(q, map[string]string{"`sakila`.`tbl`": "ROCKSDB"}, suggest)
+	if !strings.Contains(suggest["LCK.003"].Content, "ROCKSDB 不使用间隙锁") {
+		t.Errorf("want RocksDB note, got %s", suggest["LCK.003"].Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// FUN.002
+func TestCountStarRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		sql    string
+		engine string
+		want   bool
+	}{
+		{"SELECT COUNT(*) FROM film", "INNODB", true},
+		{"SELECT COUNT(*) FROM film", "MYISAM", false},
+		{"SELECT COUNT(*) FROM film", "MEMORY", false},
+		{"SELECT COUNT(*) FROM film WHERE film_id > 10", "INNODB", false},
+		{"SELECT COUNT(*) FROM (SELECT * FROM film) f", "INNODB", false},
+		{"SELECT title FROM film", "INNODB", false},
+	}
+	for _, c := range cases {
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		suggest := map[string]Rule{}
+		GateEngineRules(q, map[string]string{"`sakila`.`film`": c.engine}, suggest)
+		rule, ok := suggest["FUN.002"]
+		if ok != c.want {
+			t.Errorf("%s (%s) want FUN.002 %v, got %v", c.sql, c.engine, c.want, ok)
+		}
+		if ok && !strings.Contains(rule.Content, "`sakila`.`film` 使用 INNODB 引擎") {
+			t.Errorf("want engine note, got %s", rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -20,8 +20,11 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/XiaoMi/soar/ast"
 	"github.com/XiaoMi/soar/common"
 	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
 )
 
 var explainRuleID int
@@ -175,6 +178,19 @@ func ExplainAdvisor(exp *database.ExplainInfo) map[string]Rule {
 	explainRules = make(map[string]Rule)
 	tablesSuggests = make(map[string][]string)
 
+	// TiDB 执行计划与 MySQL 格式不同，单独打印及解读
+	if len(exp.TiDBRows) > 0 {
+		explainRules["EXP.000"] = Rule{
+			Item:     "EXP.000",
+			Severity: "L0",
+			Summary:  "Explain信息",
+			Content:  database.PrintMarkdownTiDBExplainTable(exp),
+			Case:     tidbExplainTranslator(exp),
+			Func:     (*Query4Audit).RuleOK,
+		}
+		return explainRules
+	}
+
 	checkExplainSelectType(exp)
 	checkExplainAccessType(exp)
 	checkExplainFiltered(exp)
@@ -210,6 +226,388 @@ func ExplainAdvisor(exp *database.ExplainInfo) map[string]Rule {
 	return explainRules
 }
 
+// explainSpillSeverity 根据预估数据量与内存上限的比值确定建议级别
+// 内存中可以完成时仅作提示为 L0，超出内存上限时需要使用磁盘为 L3，超出十倍以上时排序需要多轮归并或临时表大量读写磁盘为 L4
+func explainSpillSeverity(bytes, limit int64) string {
+	switch {
+	case bytes <= limit:
+		return "L0"
+	case bytes <= limit*10:
+		return "L3"
+	default:
+		return "L4"
+	}
+}
+
+// ExplainTableNames 返回 EXPLAIN 中可能出现的表名（或别名）到实际表名的映射，用于获取表的平均行长度
+func ExplainTableNames(q *Query4Audit) map[string]string {
+	tables := make(map[string]string)
+	if q == nil || q.Stmt == nil {
+		return tables
+	}
+	for _, db := range ast.GetMeta(q.Stmt, nil) {
+		for name, tb := range db.Table {
+			if name == "" {
+				continue
+			}
+			tables[name] = name
+			for _, alias := range tb.TableAliases {
+				tables[alias] = name
+			}
+		}
+	}
+	return tables
+}
+
+// ExplainSpillAdvisor 估算 Using filesort, Using temporary 是否需要使用磁盘，给出 EXP.001, EXP.002 建议
+// 按查询块（EXPLAIN 中相同的 id）估算参与排序或写入临时表的行数及行长度：行数为各表 rows * filtered 的乘积，行长度为各表平均行长度之和
+// 与 sort_buffer_size, tmp_table_size 比较后按超出的程度调整建议级别，无法获取行长度时不给出建议
+func ExplainSpillAdvisor(exp *database.ExplainInfo, info *database.SpillInfo) map[string]Rule {
+	rules := make(map[string]Rule)
+	if exp == nil || info == nil {
+		return rules
+	}
+	rows := exp.ExplainRows
+	if exp.ExplainFormat == database.JSONFormatExplain {
+		// JSON形式遍历分析不方便，转成Row格式统一处理
+		rows = database.ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+
+	type block struct {
+		rows      float64
+		rowLength int64
+		filesort  bool
+		temporary bool
+		tables    []string
+	}
+	var ids []int
+	blocks := make(map[int]*block)
+	for _, row := range rows {
+		b, ok := blocks[row.ID]
+		if !ok {
+			b = &block{rows: 1}
+			blocks[row.ID] = b
+			ids = append(ids, row.ID)
+		}
+		n := float64(row.Rows)
+		if row.Filtered > 0 {
+			n = n * row.Filtered / 100
+		}
+		if n > 1 {
+			b.rows *= n
+		}
+		b.rowLength += info.RowLength[row.TableName]
+		b.tables = append(b.tables, row.TableName)
+		b.filesort = b.filesort || strings.Contains(row.Extra, "Using filesort")
+		b.temporary = b.temporary || strings.Contains(row.Extra, "Using temporary")
+	}
+
+	for _, id := range ids {
+		b := blocks[id]
+		if b.rowLength <= 0 {
+			continue
+		}
+		// 多表关联时行数的乘积可能非常大，避免溢出
+		size := b.rows * float64(b.rowLength)
+		if size > 1<<60 {
+			size = 1 << 60
+		}
+		bytes := int64(size)
+		est := ruleMessage("EXP.spill", map[string]interface{}{
+			"ID":        id,
+			"Tables":    b.tables,
+			"Rows":      int64(size) / b.rowLength,
+			"RowLength": database.FormatBytes(b.rowLength),
+			"Size":      database.FormatBytes(bytes),
+		})
+		if b.filesort && info.SortBufferSize > 0 {
+			rule := explainSpillRule("EXP.001", est, bytes, info.SortBufferSize)
+			if old, ok := rules[rule.Item]; !ok || old.Severity < rule.Severity {
+				rules[rule.Item] = rule
+			}
+		}
+		if b.temporary && info.TmpTableSize > 0 {
+			rule := explainSpillRule("EXP.002", est, bytes, info.TmpTableSize)
+			if old, ok := rules[rule.Item]; !ok || old.Severity < rule.Severity {
+				rules[rule.Item] = rule
+			}
+		}
+	}
+	return rules
+}
+
+// explainSpillRule 按估算的数据量 bytes 与内存上限 limit 生成 EXP.001, EXP.002 建议，est 为估算过程的说明
+func explainSpillRule(item, est string, bytes, limit int64) Rule {
+	key := item + ".memory"
+	if bytes > limit {
+		key = item + ".disk"
+	}
+	return Rule{
+		Item:     item,
+		Severity: explainSpillSeverity(bytes, limit),
+		Summary:  ruleMessage(key+".summary", nil),
+		Content:  ruleMessage(key, map[string]interface{}{"Estimate": est, "Limit": database.FormatBytes(limit)}),
+		Func:     (*Query4Audit).RuleOK,
+	}
+}
+
+// explainBufferPoolSeverity 按全表扫描的数据量占 Buffer Pool 的比例给出建议级别，超过一半时大部分热点数据页可能被替换
+func explainBufferPoolSeverity(percent float64) string {
+	switch {
+	case percent >= 100:
+		return "L7"
+	case percent >= 50:
+		return "L5"
+	default:
+		return "L3"
+	}
+}
+
+// ExplainBufferPoolAdvisor 估算 EXPLAIN 中全表扫描（type 为 ALL）的 InnoDB 表需要读入 Buffer Pool 的数据量，给出 EXP.006 建议
+// 同一张表只计算一次，数据量超过 innodb_buffer_pool_size 的 max-scan-pool-percent 时给出建议，按占比提升建议级别
+func ExplainBufferPoolAdvisor(exp *database.ExplainInfo, info *database.SpillInfo) (Rule, bool) {
+	if exp == nil || info == nil || info.BufferPoolSize <= 0 || common.Config.MaxScanPoolPercent <= 0 {
+		return Rule{}, false
+	}
+	rows := exp.ExplainRows
+	if exp.ExplainFormat == database.JSONFormatExplain {
+		rows = database.ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+
+	var total int64
+	var tables []string
+	scanned := make(map[string]bool)
+	for _, row := range rows {
+		size := info.DataLength[row.TableName]
+		if row.AccessType != "ALL" || size <= 0 || scanned[row.TableName] {
+			continue
+		}
+		scanned[row.TableName] = true
+		total += size
+		tables = append(tables, fmt.Sprintf("%s(%s)", row.TableName, database.FormatBytes(size)))
+	}
+	percent := float64(total) * 100 / float64(info.BufferPoolSize)
+	if total == 0 || percent < float64(common.Config.MaxScanPoolPercent) {
+		return Rule{}, false
+	}
+	return Rule{
+		Item:     "EXP.006",
+		Severity: explainBufferPoolSeverity(percent),
+		Summary:  fmt.Sprintf("全表扫描将读入约 %.0f%% 的 Buffer Pool", percent),
+		Content: fmt.Sprintf("%s 全表扫描共约 %s，innodb_buffer_pool_size 为 %s。扫描读入的数据页会替换 Buffer Pool 中的其他数据页，"+
+			"InnoDB 的中点插入策略（innodb_old_blocks_pct, innodb_old_blocks_time）只能减轻偶尔执行的扫描的影响，频繁执行或扫描期间反复访问同一数据页时热点数据仍会被挤出，其他查询的磁盘读取及响应时间随之增加。"+
+			"建议添加索引避免全表扫描，必须扫描时放在从库或业务低峰期执行。",
+			strings.Join(tables, ", "), database.FormatBytes(total), database.FormatBytes(info.BufferPoolSize)),
+		Func: (*Query4Audit).RuleOK,
+	}, true
+}
+
+// QueryCost 单条 SQL 的查询代价，IndexBefore, IndexAfter 为测试环境中添加建议的索引前后的代价，未比较时为 0
+type QueryCost struct {
+	Cost        float64
+	IndexBefore float64
+	IndexAfter  float64
+}
+
+// RuleQueryCost EXP.003 输出 SQL 的查询代价及添加建议的索引前后的变化，用于 -show-last-query-cost，没有代价信息时返回 false
+func RuleQueryCost(cost QueryCost) (Rule, bool) {
+	rule := Rule{
+		Item:     "EXP.003",
+		Severity: "L0",
+		Summary:  "查询代价",
+		Func:     (*Query4Audit).RuleOK,
+	}
+	var content []string
+	if cost.Cost > 0 {
+		content = append(content, fmt.Sprintf("Query cost: %.3f", cost.Cost))
+		if cost.Cost > float64(common.Config.MaxQueryCost) {
+			rule.Severity = "L1"
+			content = append(content, fmt.Sprintf("超过 max-query-cost(%d)", common.Config.MaxQueryCost))
+		}
+	}
+	if cost.IndexBefore > 0 && cost.IndexAfter > 0 {
+		content = append(content, fmt.Sprintf("测试环境中添加建议的索引后由 %.3f 变为 %.3f（%+.1f%%）",
+			cost.IndexBefore, cost.IndexAfter, (cost.IndexAfter-cost.IndexBefore)/cost.IndexBefore*100))
+	}
+	if len(content) == 0 {
+		return rule, false
+	}
+	rule.Content = strings.Join(content, "，") + "。"
+	return rule, true
+}
+
+// AffectedRows UPDATE, DELETE 影响的行数，Counted 为 true 时为 SELECT COUNT(*) 的统计值，否则为 EXPLAIN 的预估值
+// 最多统计 max-affected-rows + 1 行，AtLeast 为 true 表示统计达到上限，实际影响的行数超过 max-affected-rows
+type AffectedRows struct {
+	Rows    int64
+	Counted bool
+	AtLeast bool
+}
+
+// ExplainAffectedRows 根据 EXPLAIN 估算 UPDATE, DELETE 影响的行数，为第一个查询块中各表 rows * filtered 的乘积
+func ExplainAffectedRows(exp *database.ExplainInfo) (int64, bool) {
+	if exp == nil {
+		return 0, false
+	}
+	rows := exp.ExplainRows
+	if exp.ExplainFormat == database.JSONFormatExplain {
+		rows = database.ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+	if len(rows) == 0 {
+		return 0, false
+	}
+	affected := 1.0
+	for _, row := range rows {
+		if row.ID != rows[0].ID {
+			continue
+		}
+		n := float64(row.Rows)
+		if row.Filtered > 0 {
+			n = n * row.Filtered / 100
+		}
+		affected *= n
+	}
+	// 多表关联时行数的乘积可能非常大，避免溢出
+	if affected > 1<<60 {
+		affected = 1 << 60
+	}
+	return int64(affected), true
+}
+
+// RuleAffectedRows EXP.004 UPDATE, DELETE 影响的行数，超过 max-affected-rows 时级别为 L6
+func RuleAffectedRows(affected AffectedRows) Rule {
+	rule := Rule{
+		Item:     "EXP.004",
+		Severity: "L0",
+		Summary:  fmt.Sprintf("预计影响约 %d 行", affected.Rows),
+		Func:     (*Query4Audit).RuleOK,
+	}
+	method := "根据 EXPLAIN 的 rows * filtered 估算"
+	if affected.Counted {
+		method = "在线上环境执行 SELECT COUNT(*) 统计"
+		rule.Summary = fmt.Sprintf("影响 %d 行", affected.Rows)
+		if affected.AtLeast {
+			rule.Summary = fmt.Sprintf("影响超过 %d 行", affected.Rows-1)
+		}
+	}
+	rule.Content = method + "，执行前请确认影响的行数符合预期。"
+	if affected.Rows > common.Config.MaxAffectedRows {
+		rule.Severity = "L6"
+		rule.Content = fmt.Sprintf("%s，超过 max-affected-rows(%d)。一次修改大量的行会产生大事务，长时间持有行锁，导致主从延迟，回滚代价也很高。建议按主键范围分批执行，每批提交一次。",
+			method, common.Config.MaxAffectedRows)
+	}
+	return rule
+}
+
+// AffectedRowsAdvisor 给出 UPDATE, DELETE 影响行数的 EXP.004 建议，不是 UPDATE, DELETE 或无法获取行数时返回 false
+// 开启 affected-rows-count 时在线上环境统计，统计失败（如超过 query-timeout）时使用 EXPLAIN 的预估值
+func AffectedRowsAdvisor(conn *database.Connector, q *Query4Audit, exp *database.ExplainInfo) (Rule, bool) {
+	if common.Config.MaxAffectedRows <= 0 || q == nil {
+		return Rule{}, false
+	}
+	switch q.Stmt.(type) {
+	case *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return Rule{}, false
+	}
+	if common.Config.AffectedRowsCount && conn != nil {
+		limit := common.Config.MaxAffectedRows + 1
+		rows, err := conn.AffectedRows(q.Query, limit)
+		if err == nil {
+			return RuleAffectedRows(AffectedRows{Rows: rows, Counted: true, AtLeast: rows >= limit}), true
+		}
+		common.Log.Warn("AffectedRowsAdvisor conn.AffectedRows Warn: %v", err)
+	}
+	rows, ok := ExplainAffectedRows(exp)
+	if !ok {
+		return Rule{}, false
+	}
+	return RuleAffectedRows(AffectedRows{Rows: rows}), true
+}
+
+// ResultSize SELECT 预计返回的行数及平均行长度
+type ResultSize struct {
+	Rows      int64
+	RowLength int64
+}
+
+// ExplainResultSize 根据 EXPLAIN 估算 SELECT 返回的行数及行长度，行数为第一个查询块中各表 rows * filtered 的乘积，行长度为这些表平均行长度之和
+// 只估算没有 LIMIT, GROUP BY 及聚合函数的单个 SELECT，无法获取行长度时返回 false
+func ExplainResultSize(q *Query4Audit, exp *database.ExplainInfo, info *database.SpillInfo) (ResultSize, bool) {
+	var size ResultSize
+	if q == nil || info == nil {
+		return size, false
+	}
+	sel, ok := q.Stmt.(*sqlparser.Select)
+	if !ok || sel.Limit != nil || len(sel.GroupBy) > 0 {
+		return size, false
+	}
+	aggregate := false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.FuncExpr:
+			aggregate = aggregate || n.IsAggregate()
+		}
+		return true, nil
+	}, sel.SelectExprs)
+	common.LogIfError(err, "")
+	if aggregate {
+		return size, false
+	}
+
+	if size.Rows, ok = ExplainAffectedRows(exp); !ok {
+		return size, false
+	}
+	rows := exp.ExplainRows
+	if exp.ExplainFormat == database.JSONFormatExplain {
+		rows = database.ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+	for _, row := range rows {
+		if row.ID == rows[0].ID {
+			size.RowLength += info.RowLength[row.TableName]
+		}
+	}
+	return size, size.RowLength > 0
+}
+
+// RuleResultSize EXP.005 SELECT 预计返回的行数或数据量超过 max-result-rows, max-result-size 时给出警告，未超过时返回 false
+func RuleResultSize(size ResultSize) (Rule, bool) {
+	bytes := size.Rows * size.RowLength
+	// 多表关联时行数的乘积可能非常大，避免溢出
+	if size.RowLength > 0 && size.Rows > (1<<60)/size.RowLength {
+		bytes = 1 << 60
+	}
+	var exceeded []string
+	if common.Config.MaxResultRows > 0 && size.Rows > common.Config.MaxResultRows {
+		exceeded = append(exceeded, fmt.Sprintf("max-result-rows(%d)", common.Config.MaxResultRows))
+	}
+	if common.Config.MaxResultSize > 0 && bytes > common.Config.MaxResultSize<<20 {
+		exceeded = append(exceeded, fmt.Sprintf("max-result-size(%dMB)", common.Config.MaxResultSize))
+	}
+	if len(exceeded) == 0 {
+		return Rule{}, false
+	}
+	return Rule{
+		Item:     "EXP.005",
+		Severity: "L4",
+		Summary:  fmt.Sprintf("预计返回约 %d 行，共约 %s", size.Rows, database.FormatBytes(bytes)),
+		Content: fmt.Sprintf("根据 EXPLAIN 的 rows * filtered 及表的平均行长度(%s)估算，超过 %s。返回大量数据会占用网络带宽、服务端及客户端内存，建议添加过滤条件或使用 LIMIT 分页，只查询需要的列。按整行估算，只查询部分列时实际的数据量更小。",
+			database.FormatBytes(size.RowLength), strings.Join(exceeded, ", ")),
+		Func: (*Query4Audit).RuleOK,
+	}, true
+}
+
+// ResultSizeAdvisor 给出 SELECT 返回结果集大小的 EXP.005 建议，是对 CLA.001 的量化补充
+func ResultSizeAdvisor(q *Query4Audit, exp *database.ExplainInfo, info *database.SpillInfo) (Rule, bool) {
+	size, ok := ExplainResultSize(q, exp, info)
+	if !ok {
+		return Rule{}, false
+	}
+	return RuleResultSize(size)
+}
+
 // DigestExplainText 分析用户输入的EXPLAIN信息
 func DigestExplainText(text string) {
 	// explain信息就不要显示完美了，美不美自己看吧。
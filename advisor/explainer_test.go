@@ -17,9 +17,11 @@
 package advisor
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
 )
 
 func TestDigestExplainText(t *testing.T) {
@@ -43,3 +45,218 @@ func TestDigestExplainText(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestExplainSpillAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	exp := &database.ExplainInfo{
+		ExplainRows: []database.ExplainRow{
+			{ID: 1, TableName: "f", Rows: 1000, Filtered: 100, Extra: "Using temporary; Using filesort"},
+			{ID: 1, TableName: "l", Rows: 1, Filtered: 100, Extra: ""},
+			{ID: 2, TableName: "c", Rows: 100000, Filtered: 10, Extra: "Using filesort"},
+		},
+	}
+	info := &database.SpillInfo{
+		SortBufferSize: 256 << 10,
+		TmpTableSize:   16 << 20,
+		RowLength:      map[string]int64{"f": 200, "l": 56, "c": 400},
+	}
+
+	// id=1: 1000 * 256B = 250KB，排序及临时表都在内存中完成
+	// id=2: 10000 * 400B = 3.8MB，排序超过 sort_buffer_size 十倍以上
+	rules := ExplainSpillAdvisor(exp, info)
+	if rules["EXP.001"].Severity != "L4" || rules["EXP.002"].Severity != "L0" {
+		t.Errorf("spill severity error: %+v", rules)
+	}
+	if rules["EXP.001"].Summary != "Sorting (Using filesort) is expected to use temporary files on disk" ||
+		!strings.HasPrefix(rules["EXP.001"].Content, "id=2 (c) is estimated at 10000 rows, 400B per row") {
+		t.Errorf("spill content error: %+v", rules["EXP.001"])
+	}
+
+	info.TmpTableSize = 128 << 10
+	rules = ExplainSpillAdvisor(exp, info)
+	if rules["EXP.002"].Severity != "L3" {
+		t.Errorf("spill severity error: %+v", rules["EXP.002"])
+	}
+
+	// 无法获取行长度时不给出建议
+	info.RowLength = map[string]int64{}
+	if rules = ExplainSpillAdvisor(exp, info); len(rules) != 0 {
+		t.Errorf("want no suggestion, got %+v", rules)
+	}
+
+	q, _ := NewQuery4Audit("SELECT * FROM film f JOIN language USING (language_id) ORDER BY f.title")
+	tables := ExplainTableNames(q)
+	if tables["f"] != "film" || tables["film"] != "film" || tables["language"] != "language" {
+		t.Errorf("ExplainTableNames error: %v", tables)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExplainBufferPoolAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgPercent := common.Config.MaxScanPoolPercent
+	defer func() { common.Config.MaxScanPoolPercent = orgPercent }()
+	common.Config.MaxScanPoolPercent = 10
+
+	exp := &database.ExplainInfo{
+		ExplainRows: []database.ExplainRow{
+			{ID: 1, TableName: "o", AccessType: "ALL", Rows: 1000000},
+			{ID: 1, TableName: "u", AccessType: "eq_ref", Rows: 1},
+			{ID: 2, TableName: "o", AccessType: "ALL", Rows: 1000000},
+		},
+	}
+	info := &database.SpillInfo{
+		BufferPoolSize: 1 << 30,
+		DataLength:     map[string]int64{"o": 256 << 20, "u": 2 << 30},
+	}
+	// 只计算全表扫描的表，同一张表只计算一次: 256MB / 1GB = 25%
+	rule, ok := ExplainBufferPoolAdvisor(exp, info)
+	if !ok || rule.Item != "EXP.006" || rule.Severity != "L3" || !strings.Contains(rule.Summary, "25%") || !strings.Contains(rule.Content, "o(256MB)") {
+		t.Errorf("want EXP.006 L3, got %v %+v", ok, rule)
+	}
+
+	info.DataLength["o"] = 600 << 20
+	if rule, _ = ExplainBufferPoolAdvisor(exp, info); rule.Severity != "L5" {
+		t.Errorf("want L5, got %+v", rule)
+	}
+	info.DataLength["o"] = 2 << 30
+	if rule, _ = ExplainBufferPoolAdvisor(exp, info); rule.Severity != "L7" {
+		t.Errorf("want L7, got %+v", rule)
+	}
+
+	// 未超过阈值、未获取到 Buffer Pool 大小或关闭检查时不给出建议
+	info.DataLength["o"] = 50 << 20
+	if _, ok = ExplainBufferPoolAdvisor(exp, info); ok {
+		t.Error("want no suggestion below max-scan-pool-percent")
+	}
+	info.DataLength["o"] = 2 << 30
+	common.Config.MaxScanPoolPercent = 0
+	if _, ok = ExplainBufferPoolAdvisor(exp, info); ok {
+		t.Error("want no suggestion when max-scan-pool-percent is 0")
+	}
+	common.Config.MaxScanPoolPercent = 10
+	info.BufferPoolSize = 0
+	if _, ok = ExplainBufferPoolAdvisor(exp, info); ok {
+		t.Error("want no suggestion without innodb_buffer_pool_size")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestRuleQueryCost(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgMaxQueryCost := common.Config.MaxQueryCost
+	defer func() { common.Config.MaxQueryCost = orgMaxQueryCost }()
+	common.Config.MaxQueryCost = 9999
+
+	if _, ok := RuleQueryCost(QueryCost{}); ok {
+		t.Error("want no suggestion without cost")
+	}
+	rule, ok := RuleQueryCost(QueryCost{Cost: 120.5, IndexBefore: 200, IndexAfter: 50})
+	if !ok || rule.Item != "EXP.003" || rule.Severity != "L0" ||
+		!strings.Contains(rule.Content, "120.500") || !strings.Contains(rule.Content, "-75.0%") {
+		t.Errorf("RuleQueryCost error: %+v", rule)
+	}
+	rule, _ = RuleQueryCost(QueryCost{Cost: 12345})
+	if rule.Severity != "L1" {
+		t.Errorf("RuleQueryCost error: %+v", rule)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestRuleAffectedRows(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgMax := common.Config.MaxAffectedRows
+	defer func() { common.Config.MaxAffectedRows = orgMax }()
+	common.Config.MaxAffectedRows = 10000
+
+	exp := &database.ExplainInfo{
+		ExplainRows: []database.ExplainRow{
+			{ID: 1, TableName: "city", Rows: 600, Filtered: 50},
+			{ID: 1, TableName: "country", Rows: 1, Filtered: 100},
+			{ID: 2, TableName: "address", Rows: 100000, Filtered: 100},
+		},
+	}
+	rows, ok := ExplainAffectedRows(exp)
+	if !ok || rows != 300 {
+		t.Errorf("ExplainAffectedRows want 300, got %d", rows)
+	}
+	if _, ok := ExplainAffectedRows(&database.ExplainInfo{}); ok {
+		t.Errorf("ExplainAffectedRows should return false without rows")
+	}
+
+	cases := []struct {
+		affected AffectedRows
+		severity string
+		summary  string
+	}{
+		{AffectedRows{Rows: 300}, "L0", "预计影响约 300 行"},
+		{AffectedRows{Rows: 20000}, "L6", "预计影响约 20000 行"},
+		{AffectedRows{Rows: 10000, Counted: true}, "L0", "影响 10000 行"},
+		{AffectedRows{Rows: 10001, Counted: true, AtLeast: true}, "L6", "影响超过 10000 行"},
+	}
+	q, _ := NewQuery4Audit("UPDATE city SET city = 'Abha' WHERE country_id = 1")
+	if rule, ok := AffectedRowsAdvisor(nil, q, exp); !ok || rule.Summary != "预计影响约 300 行" {
+		t.Errorf("AffectedRowsAdvisor got %+v", rule)
+	}
+	q, _ = NewQuery4Audit("SELECT * FROM city")
+	if _, ok := AffectedRowsAdvisor(nil, q, exp); ok {
+		t.Errorf("AffectedRowsAdvisor should skip SELECT")
+	}
+	for _, c := range cases {
+		rule := RuleAffectedRows(c.affected)
+		if rule.Severity != c.severity || rule.Summary != c.summary {
+			t.Errorf("RuleAffectedRows(%+v) got %s %s", c.affected, rule.Severity, rule.Summary)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestResultSizeAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgRows, orgSize := common.Config.MaxResultRows, common.Config.MaxResultSize
+	defer func() { common.Config.MaxResultRows, common.Config.MaxResultSize = orgRows, orgSize }()
+	common.Config.MaxResultRows, common.Config.MaxResultSize = 100000, 100
+
+	exp := &database.ExplainInfo{
+		ExplainRows: []database.ExplainRow{
+			{ID: 1, TableName: "p", Rows: 1000000, Filtered: 50},
+			{ID: 1, TableName: "c", Rows: 1, Filtered: 100},
+		},
+	}
+	info := &database.SpillInfo{RowLength: map[string]int64{"p": 64, "c": 128}}
+
+	q, _ := NewQuery4Audit("SELECT * FROM payment p JOIN customer c USING (customer_id) WHERE p.amount > 1")
+	size, ok := ExplainResultSize(q, exp, info)
+	if !ok || size.Rows != 500000 || size.RowLength != 192 {
+		t.Errorf("ExplainResultSize got %+v", size)
+	}
+	rule, ok := ResultSizeAdvisor(q, exp, info)
+	if !ok || rule.Item != "EXP.005" || rule.Summary != "预计返回约 500000 行，共约 91.6MB" ||
+		!strings.Contains(rule.Content, "超过 max-result-rows(100000)。") {
+		t.Errorf("ResultSizeAdvisor got %+v", rule)
+	}
+
+	// 行数未超过阈值，数据量超过阈值
+	common.Config.MaxResultRows, common.Config.MaxResultSize = 1000000, 10
+	if rule, ok = RuleResultSize(size); !ok || !strings.Contains(rule.Content, "超过 max-result-size(10MB)。") {
+		t.Errorf("RuleResultSize got %+v", rule)
+	}
+	common.Config.MaxResultSize = 0
+	if _, ok = RuleResultSize(size); ok {
+		t.Errorf("RuleResultSize should not exceed")
+	}
+
+	// 有 LIMIT, GROUP BY, 聚合函数或不是 SELECT 时不估算
+	for _, sql := range []string{
+		"SELECT * FROM payment LIMIT 10",
+		"SELECT customer_id, SUM(amount) FROM payment GROUP BY customer_id",
+		"SELECT COUNT(*) FROM payment",
+		"DELETE FROM payment",
+	} {
+		q, _ = NewQuery4Audit(sql)
+		if _, ok = ExplainResultSize(q, exp, info); ok {
+			t.Errorf("ExplainResultSize should skip %s", sql)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// remoteEngines 数据存放在其他实例上的存储引擎，读写都需要通过网络访问远端实例
+// MariaDB 的 FederatedX 在 SHOW TABLE STATUS 中同样显示为 FEDERATED
+var remoteEngines = map[string]bool{
+	"FEDERATED": true,
+	"CONNECT":   true,
+	"SPIDER":    true,
+}
+
+// remoteTable SQL 涉及的远端表
+type remoteTable struct {
+	Name   string // `db`.`table`
+	Engine string
+}
+
+// remoteTableCache IndexAdvisor 中缓存的远端表查询结果
+type remoteTableCache struct {
+	tables []remoteTable
+	total  int // SQL 涉及的表的数量
+}
+
+// queryMeta 返回 SQL 涉及的库表，GetMeta 不会处理 INSERT 写入的表，需要单独添加
+func queryMeta(stmt sqlparser.Statement) common.Meta {
+	meta := ast.GetMeta(stmt, nil)
+	switch n := stmt.(type) {
+	case *sqlparser.Insert:
+		db, tb := n.Table.Qualifier.String(), n.Table.Name.String()
+		if meta[db] == nil {
+			meta[db] = common.NewDB(db)
+		}
+		if meta[db].Table[tb] == nil {
+			meta[db].Table[tb] = common.NewTable(tb)
+		}
+	}
+	return meta
+}
+
+// remoteTables 使用线上环境的 SHOW TABLE STATUS 查找 SQL 涉及的远端表，同时返回涉及的表的数量，结果在同一条 SQL 的多个规则间复用
+func (idxAdv *IndexAdvisor) remoteTables() ([]remoteTable, int) {
+	if idxAdv.remotes != nil {
+		return idxAdv.remotes.tables, idxAdv.remotes.total
+	}
+	idxAdv.remotes = &remoteTableCache{}
+	if common.Config.OnlineDSN.Disable || idxAdv.Ast == nil {
+		return nil, 0
+	}
+
+	meta := queryMeta(idxAdv.Ast)
+	var dbs []string
+	for db := range meta {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+	for _, db := range dbs {
+		conn := idxAdv.rEnv
+		if db != "" {
+			conn.Database = db
+		}
+		var tables []string
+		for tb := range meta[db].Table {
+			if tb != "" {
+				tables = append(tables, tb)
+			}
+		}
+		sort.Strings(tables)
+		for _, tb := range tables {
+			idxAdv.remotes.total++
+			status, err := conn.ShowTableStatus(tb)
+			if err != nil {
+				common.Log.Warn("remoteTables ShowTableStatus Error: %v", err)
+				continue
+			}
+			if len(status.Rows) == 0 {
+				continue
+			}
+			engine := strings.ToUpper(string(status.Rows[0].Engine))
+			if remoteEngines[engine] {
+				idxAdv.remotes.tables = append(idxAdv.remotes.tables, remoteTable{
+					Name:   fmt.Sprintf("`%s`.`%s`", conn.Database, tb),
+					Engine: engine,
+				})
+			}
+		}
+	}
+	return idxAdv.remotes.tables, idxAdv.remotes.total
+}
+
+// remoteNames 列出远端表及其存储引擎
+func remoteNames(remotes []remoteTable) string {
+	var names []string
+	for _, r := range remotes {
+		names = append(names, fmt.Sprintf("%s(%s)", r.Name, r.Engine))
+	}
+	return strings.Join(names, ", ")
+}
+
+// RuleRemoteTable FED.001
+// 远端表的 EXPLAIN 及索引建议只反映本地的表定义，需要提示查询的实际代价在远端
+func (idxAdv *IndexAdvisor) RuleRemoteTable() Rule {
+	remotes, _ := idxAdv.remoteTables()
+	return remoteTableRule(remotes)
+}
+
+func remoteTableRule(remotes []remoteTable) Rule {
+	rule := HeuristicRules["OK"]
+	if len(remotes) > 0 {
+		rule = HeuristicRules["FED.001"]
+		rule.Content = strings.Join([]string{rule.Content, fmt.Sprintf("涉及的远端表: %s。", remoteNames(remotes))}, " ")
+	}
+	return rule
+}
+
+// RuleRemoteJoin FED.002
+// 远端表与其他表关联时 JOIN 在本地执行，被驱动的远端表按驱动表的每一行向远端发起查询
+func (idxAdv *IndexAdvisor) RuleRemoteJoin() Rule {
+	remotes, total := idxAdv.remoteTables()
+	return remoteJoinRule(remotes, total)
+}
+
+func remoteJoinRule(remotes []remoteTable, total int) Rule {
+	rule := HeuristicRules["OK"]
+	if len(remotes) > 0 && total > 1 {
+		rule = HeuristicRules["FED.002"]
+		rule.Content = strings.Join([]string{rule.Content, fmt.Sprintf("共涉及 %d 张表，其中远端表: %s。", total, remoteNames(remotes))}, " ")
+	}
+	return rule
+}
+
+// RuleRemoteWrite FED.003
+// UPDATE, DELETE 远端表时先读取符合条件的记录，再逐行向远端发送修改语句
+func (idxAdv *IndexAdvisor) RuleRemoteWrite() Rule {
+	switch idxAdv.Ast.(type) {
+	case *sqlparser.Update, *sqlparser.Delete:
+		remotes, _ := idxAdv.remoteTables()
+		return remoteWriteRule(remotes)
+	}
+	return HeuristicRules["OK"]
+}
+
+func remoteWriteRule(remotes []remoteTable) Rule {
+	rule := HeuristicRules["OK"]
+	if len(remotes) > 0 {
+		rule = HeuristicRules["FED.003"]
+		rule.Content = strings.Join([]string{rule.Content, fmt.Sprintf("涉及的远端表: %s。", remoteNames(remotes))}, " ")
+	}
+	return rule
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestQueryMeta(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	stmt, err := sqlparser.Parse("INSERT INTO sakila.remote_log SELECT * FROM film")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := queryMeta(stmt)
+	if meta["sakila"] == nil || meta["sakila"].Table["remote_log"] == nil || meta[""] == nil || meta[""].Table["film"] == nil {
+		t.Errorf("want insert table and select table, got: %v", meta)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// FED.001, FED.002, FED.003
+func TestRuleRemoteTable(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	remotes := []remoteTable{{Name: "`sakila`.`remote_users`", Engine: "FEDERATED"}}
+	if rule := remoteTableRule(nil); rule.Item != "OK" {
+		t.Errorf("want OK without remote tables, got %s", rule.Item)
+	}
+	if rule := remoteTableRule(remotes); rule.Item != "FED.001" || !strings.Contains(rule.Content, "`sakila`.`remote_users`(FEDERATED)") {
+		t.Errorf("want FED.001, got %s: %s", rule.Item, rule.Content)
+	}
+
+	// 只查询一张远端表时不涉及 JOIN
+	if rule := remoteJoinRule(remotes, 1); rule.Item != "OK" {
+		t.Errorf("want OK for single remote table, got %s", rule.Item)
+	}
+	if rule := remoteJoinRule(remotes, 2); rule.Item != "FED.002" || !strings.Contains(rule.Content, "共涉及 2 张表") {
+		t.Errorf("want FED.002, got %s: %s", rule.Item, rule.Content)
+	}
+
+	if rule := remoteWriteRule(remotes); rule.Item != "FED.003" {
+		t.Errorf("want FED.003, got %s", rule.Item)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// Finding 带有文件位置信息的一条建议，codequality, rdjson, checkstyle, tap, xlsx, jira 等格式共用
+type Finding struct {
+	File        string
+	Line        int
+	QueryID     string
+	Fingerprint string
+	SQL         string
+	Tables      []string
+	Owner       string // owner-file 中负责该 SQL 的团队，未配置时为空
+	Rule        Rule
+}
+
+// ok 没有问题的 SQL 对应的 Finding，仅 tap 需要输出
+func (f Finding) ok() bool {
+	return f.Rule.Item == "OK"
+}
+
+// NewFindings 将单条 SQL 的建议转换为 Finding，忽略 EXPLAIN, Profiling, Trace 等非问题类的信息
+// 没有任何问题时返回一条 OK，用于 tap 输出通过的测试项
+func NewFindings(suggest map[string]Rule, sql string, tables []string, file string, line int) []Finding {
+	var findings []Finding
+	fingerprint := Fingerprint(sql)
+	owner := QueryOwner(tables)
+	newFinding := func(rule Rule) Finding {
+		return Finding{
+			File:        file,
+			Line:        line,
+			QueryID:     query.Id(fingerprint),
+			Fingerprint: fingerprint,
+			SQL:         sql,
+			Tables:      tables,
+			Owner:       owner,
+			Rule:        rule,
+		}
+	}
+	for _, item := range common.SortedKey(suggest) {
+		if !isFindingItem(item) {
+			continue
+		}
+		findings = append(findings, newFinding(suggest[item]))
+	}
+	if len(findings) == 0 {
+		findings = append(findings, newFinding(HeuristicRules["OK"]))
+	}
+	return findings
+}
+
+// isFindingItem 是否为需要修复的问题类建议，OK 及 EXPLAIN, Profiling, Trace 等信息类建议除外
+func isFindingItem(item string) bool {
+	return item != "OK" && item != "EXP.000" &&
+		!strings.HasPrefix(item, "PRO") && !strings.HasPrefix(item, "TRA")
+}
+
+// FindingsReportType 判断 report-type 是否使用 Finding 输出
+func FindingsReportType(reportType string) bool {
+	switch reportType {
+	case "codequality", "rdjson", "checkstyle", "tap", "xlsx", "jira", "issues", "archery", "yearning", "bytebase":
+		return true
+	}
+	return false
+}
+
+// severityLevel L0-L8 转换为数字，无法解析时按 L0 处理
+func severityLevel(severity string) int {
+	l, err := strconv.Atoi(strings.TrimPrefix(severity, "L"))
+	if err != nil {
+		return 0
+	}
+	return l
+}
+
+// SeverityLabel L0-L8 对应的级别名称 info, warning, error, blocker，优先使用 severity-labels 中的配置
+func SeverityLabel(severity string) string {
+	if label, ok := common.Config.SeverityLabels[severity]; ok {
+		return label
+	}
+	switch l := severityLevel(severity); {
+	case l == 0:
+		return "info"
+	case l <= 4:
+		return "warning"
+	case l <= 7:
+		return "error"
+	default:
+		return "blocker"
+	}
+}
+
+// severityClass 将 L0-L8 归为 info, warning, error 三类，blocker 按 error 处理
+func severityClass(severity string) string {
+	if label := SeverityLabel(severity); label != "blocker" {
+		return label
+	}
+	return "error"
+}
+
+// FormatFindings 按 report-type 输出 codequality, rdjson, checkstyle, tap, xlsx, jira, issues 或审核平台使用的格式
+func FormatFindings(findings []Finding) string {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	switch common.Config.ReportType {
+	case "rdjson":
+		return FormatRDJSON(findings)
+	case "checkstyle":
+		return FormatCheckstyle(findings)
+	case "tap":
+		return FormatTAP(findings)
+	case "xlsx":
+		return FormatXLSX(findings)
+	case "jira":
+		return FormatJira(findings)
+	case "issues":
+		return FormatIssues(findings)
+	case "archery":
+		return FormatArchery(findings)
+	case "yearning":
+		return FormatYearning(findings)
+	case "bytebase":
+		return FormatBytebase(findings)
+	default:
+		return FormatCodeQuality(findings)
+	}
+}
+
+// checkstyleResult Checkstyle XML，Jenkins warnings-ng 等工具可直接解析
+type checkstyleResult struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// FormatCheckstyle 输出 Checkstyle XML，findings 需已按文件排序
+func FormatCheckstyle(findings []Finding) string {
+	res := checkstyleResult{Version: "4.3"}
+	for _, f := range findings {
+		if len(res.Files) == 0 || res.Files[len(res.Files)-1].Name != f.File {
+			res.Files = append(res.Files, checkstyleFile{Name: f.File})
+		}
+		if f.ok() {
+			continue
+		}
+		file := &res.Files[len(res.Files)-1]
+		file.Errors = append(file.Errors, checkstyleError{
+			Line:     f.Line,
+			Severity: severityClass(f.Rule.Severity),
+			Message:  strings.TrimSpace(f.Rule.Summary + "\n" + f.Rule.Content),
+			Source:   "soar." + f.Rule.Item,
+		})
+	}
+	buf, err := xml.MarshalIndent(res, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatCheckstyle xml.Marshal Error: %v", err)
+	}
+	return xml.Header + string(buf)
+}
+
+// groupFindings 将同一位置同一 SQL 的建议分为一组，findings 需已按文件及行号排序
+func groupFindings(findings []Finding) [][]Finding {
+	var groups [][]Finding
+	for _, f := range findings {
+		last := len(groups) - 1
+		if last >= 0 && groups[last][0].File == f.File && groups[last][0].Line == f.Line &&
+			groups[last][0].QueryID == f.QueryID {
+			groups[last] = append(groups[last], f)
+			continue
+		}
+		groups = append(groups, []Finding{f})
+	}
+	return groups
+}
+
+// FormatTAP 输出 TAP version 13，每条 SQL 为一个测试项，有建议时为 not ok 并在 YAML 块中列出
+func FormatTAP(findings []Finding) string {
+	var buf bytes.Buffer
+	groups := groupFindings(findings)
+
+	fmt.Fprintf(&buf, "TAP version 13\n1..%d\n", len(groups))
+	for i, g := range groups {
+		desc := fmt.Sprintf("%s:%d %s", g[0].File, g[0].Line, g[0].QueryID)
+		if len(g) == 1 && g[0].ok() {
+			fmt.Fprintf(&buf, "ok %d - %s\n", i+1, desc)
+			continue
+		}
+		fmt.Fprintf(&buf, "not ok %d - %s\n", i+1, desc)
+		buf.WriteString("  ---\n  findings:\n")
+		for _, f := range g {
+			fmt.Fprintf(&buf, "    - item: %s\n      severity: %s\n      summary: %s\n",
+				f.Rule.Item, f.Rule.Severity, strconv.Quote(f.Rule.Summary))
+		}
+		buf.WriteString("  ...\n")
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
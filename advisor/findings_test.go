@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestNewFindingsOK(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	findings := NewFindings(map[string]Rule{"OK": HeuristicRules["OK"]}, "select 1", nil, "a.sql", 1)
+	if len(findings) != 1 || !findings[0].ok() {
+		t.Errorf("NewFindings got %v, want one OK finding", findings)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatCheckstyle(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	findings := append(NewFindings(map[string]Rule{"OK": HeuristicRules["OK"]}, "select 1", nil, "a.sql", 1),
+		NewFindings(map[string]Rule{"COL.001": HeuristicRules["COL.001"]}, "select * from film", nil, "b.sql", 5)...)
+	var res checkstyleResult
+	if err := xml.Unmarshal([]byte(FormatCheckstyle(findings)), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 2 || res.Files[0].Name != "a.sql" || len(res.Files[0].Errors) != 0 ||
+		len(res.Files[1].Errors) != 1 || res.Files[1].Errors[0].Line != 5 ||
+		res.Files[1].Errors[0].Source != "soar.COL.001" || res.Files[1].Errors[0].Severity != "warning" {
+		t.Errorf("checkstyle got %v", res)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatTAP(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	findings := append(NewFindings(map[string]Rule{"OK": HeuristicRules["OK"]}, "select 1", nil, "a.sql", 1),
+		NewFindings(map[string]Rule{
+			"CLA.001": HeuristicRules["CLA.001"],
+			"COL.001": HeuristicRules["COL.001"],
+		}, "select * from film", nil, "a.sql", 3)...)
+	tap := FormatTAP(findings)
+	lines := strings.Split(tap, "\n")
+	if lines[0] != "TAP version 13" || lines[1] != "1..2" ||
+		!strings.HasPrefix(lines[2], "ok 1 - a.sql:1 ") ||
+		!strings.HasPrefix(lines[3], "not ok 2 - a.sql:3 ") ||
+		strings.Count(tap, "- item:") != 2 || !strings.HasSuffix(tap, "  ...") {
+		t.Errorf("tap got:\n%s", tap)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
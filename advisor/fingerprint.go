@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/percona/go-mysql/query"
+	"github.com/pingcap/parser"
+)
+
+// Fingerprinters 可选的基础指纹算法，通过 -fingerprint-func 指定，
+// 需要其他算法时可以在此注册后使用
+var Fingerprinters = map[string]func(sql string) string{
+	// pt-query-digest 使用的算法
+	"percona": query.Fingerprint,
+	// TiDB 使用的算法，参见 parser.Normalize
+	"tidb": parser.Normalize,
+}
+
+// fingerprintInRe 占位符组成的 IN 列表，如 in (?, ?), in(?+), in ( ... )
+var fingerprintInRe = regexp.MustCompile(`(?i)\bin\s*\(\s*(\.\.\.|\?\+?(\s*,\s*\?\+?)*)\s*\)`)
+
+// Fingerprint 计算 SQL 指纹，用于 SQL 去重及生成 Query ID
+// 先使用 -fingerprint-func 指定的算法，再按配置依次合并 IN 列表，归一化分表表名，将 -shard-tables 中的物理分表替换为逻辑表名
+func Fingerprint(sql string) string {
+	if common.Config.FingerprintStripComments {
+		sql = database.RemoveSQLComments(sql)
+	}
+
+	fingerprinter, ok := Fingerprinters[common.Config.FingerprintFunc]
+	if !ok {
+		fingerprinter = query.Fingerprint
+	}
+	fingerprint := strings.TrimSpace(fingerprinter(sql))
+
+	if common.Config.FingerprintCollapseIn {
+		fingerprint = fingerprintInRe.ReplaceAllString(fingerprint, "in(?+)")
+	}
+
+	if common.Config.FingerprintShardPattern != "" || len(common.ShardTables()) > 0 {
+		fingerprint = normalizeShardTables(sql, fingerprint)
+	}
+	return fingerprint
+}
+
+// normalizeShardTables 将指纹中的分表表名归一化，如 orders_2024_01 -> orders_*
+func normalizeShardTables(sql, fingerprint string) string {
+	var shardRe *regexp.Regexp
+	if common.Config.FingerprintShardPattern != "" {
+		var err error
+		shardRe, err = regexp.Compile(common.Config.FingerprintShardPattern)
+		if err != nil {
+			common.Log.Warning("normalizeShardTables regexp.Compile %s Error: %v", common.Config.FingerprintShardPattern, err)
+		}
+	}
+	for _, tb := range ast.SchemaMetaInfo(sql, "") {
+		// SchemaMetaInfo 返回 `db`.`table` 格式
+		name := strings.Trim(tb[strings.LastIndex(tb, ".")+1:], "`")
+		shard := common.LogicalTableName(name)
+		if shard == name && shardRe != nil {
+			shard = shardRe.ReplaceAllString(name, "*")
+		}
+		if shard == name || name == "dual" {
+			continue
+		}
+		tbRe := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+		fingerprint = tbRe.ReplaceAllString(fingerprint, strings.ToLower(shard))
+	}
+	return fingerprint
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestFingerprint(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgConfig := *common.Config
+	cases := []struct {
+		config func()
+		sql    string
+		want   string
+	}{
+		{
+			func() {},
+			"select a from t where id in (1, 2, 3)",
+			"select a from t where id in(?+)",
+		},
+		{
+			func() { common.Config.FingerprintStripComments = true },
+			"select a from t# comment\n where b = 1",
+			"select a from t where b = ?",
+		},
+		{
+			func() { common.Config.FingerprintShardPattern = `\d+(_\d+)*$` },
+			"select o.id from orders_2024_01 o join users u on o.uid = u.id",
+			"select o.id from orders_* o join users u on o.uid = u.id",
+		},
+		{
+			func() {
+				common.Config.FingerprintFunc = "tidb"
+				common.Config.FingerprintCollapseIn = true
+			},
+			"SELECT a FROM t WHERE id IN (1, 2, 3)",
+			"select a from t where id in(?+)",
+		},
+	}
+	for _, c := range cases {
+		*common.Config = orgConfig
+		c.config()
+		if got := Fingerprint(c.sql); got != c.want {
+			t.Errorf("Fingerprint(%s)\nwant: %s\ngot: %s", c.sql, c.want, got)
+		}
+	}
+	*common.Config = orgConfig
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,367 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// FixItems -fix 支持自动修复的评审规则
+// ALI.001, STA.001 的修复不改变语法树，LIT.002, COL.001 的修复语义明确，修复后的 SQL 均需要通过语法解析
+var FixItems = []string{"ALI.001", "STA.001", "LIT.002", "COL.001"}
+
+// Fix 一处自动修复
+type Fix struct {
+	Item   string // 对应的评审规则
+	Line   int    // 修复所在行，从 1 开始
+	Before string // 修复前的内容
+	After  string // 修复后的内容
+}
+
+// fixToken 带位置的 token，Start, End 为在 SQL 中的字节偏移
+type fixToken struct {
+	Type  int
+	Start int
+	End   int
+}
+
+// fixEdit 将 SQL 中 [start, end) 的内容替换为 text
+type fixEdit struct {
+	Fix
+	start int
+	end   int
+	text  string
+}
+
+// fixSchema 当前文件中建表语句定义的列名，用于补全 SELECT * 的列名，map[库名.表名][]列名，库名、表名均为小写，未指定库名时库名为空
+// 每个文件单独记录，ALTER, DROP, RENAME 涉及的表不再补全，无法确定表结构变化时清空
+type fixSchema map[string][]string
+
+// FixFile 逐条 SQL 应用白名单中的修复，修复位置之外的格式及注释保持不变，返回修复后的内容及已应用的修复
+// 只使用该文件中的建表语句补全后续 SELECT * 的列名
+func FixFile(buf, delimiter string) (string, []Fix) {
+	var out strings.Builder
+	var fixes []Fix
+	schema := make(fixSchema)
+	rest := []byte(buf)
+	line := 1
+	for len(rest) > 0 {
+		orgSQL, sql, left := ast.SplitStatement(rest, []byte(delimiter))
+		if orgSQL == "" {
+			out.Write(rest)
+			break
+		}
+		rest = left
+		if d, ok := ast.ParseDelimiter(sql); ok {
+			delimiter = d
+			out.WriteString(orgSQL)
+			line += ast.NewLines([]byte(orgSQL))
+			continue
+		}
+		body := strings.TrimSuffix(orgSQL, delimiter)
+		fixed, sqlFixes := fixSQL(body, schema)
+		for _, f := range sqlFixes {
+			f.Line += line - 1
+			fixes = append(fixes, f)
+		}
+		out.WriteString(fixed + orgSQL[len(body):])
+		line += ast.NewLines([]byte(orgSQL))
+	}
+	return out.String(), fixes
+}
+
+// fixSQL 对单条 SQL 应用修复，无法通过语法解析的 SQL 不做修复，建表及修改表结构的语句更新 schema
+func fixSQL(sql string, schema fixSchema) (string, []Fix) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil || strings.Contains(sql, "/*!") {
+		// 无法解析的 DDL 及版本注释中的 DDL 可能修改了任意表的结构
+		if tokens := fixTokens(sql); len(tokens) > 0 {
+			switch tokens[0].Type {
+			case sqlparser.CREATE, sqlparser.ALTER, sqlparser.DROP, sqlparser.RENAME:
+				for k := range schema {
+					delete(schema, k)
+				}
+			}
+		}
+		return sql, nil
+	}
+	if ddl, ok := stmt.(*sqlparser.DDL); ok {
+		schema.update(sql, ddl)
+		return sql, nil
+	}
+
+	// ALI.001, STA.001 只改变写法，修复后的语法树必须与修复前相同
+	origin := sqlparser.String(stmt)
+	sameAST := func(s sqlparser.Statement) bool {
+		return sqlparser.String(s) == origin
+	}
+	var applied []fixEdit
+	sql, applied = applyFixes(sql, applied, fixAlias(sql, fixTokens(sql)), sameAST)
+	sql, applied = applyFixes(sql, applied, fixNotEqual(sql, fixTokens(sql)), sameAST)
+	sql, applied = applyFixes(sql, applied, fixDateLiteral(sql, fixTokens(sql)), nil)
+	sql, applied = applyFixes(sql, applied, fixStarColumns(sql, fixTokens(sql), schema), nil)
+	sort.SliceStable(applied, func(i, j int) bool {
+		return applied[i].start < applied[j].start
+	})
+	var fixes []Fix
+	for _, e := range applied {
+		fixes = append(fixes, e.Fix)
+	}
+	return sql, fixes
+}
+
+// update 记录建表语句定义的列名，ALTER, DROP, RENAME 涉及的表不再补全列名
+func (schema fixSchema) update(sql string, ddl *sqlparser.DDL) {
+	var tables sqlparser.TableNames
+	switch ddl.Action {
+	case sqlparser.CreateStr:
+		stmts, err := ast.TiParse(sql, "", "")
+		if err == nil && len(stmts) == 1 {
+			// CREATE TABLE ... LIKE 及 CREATE TABLE ... AS SELECT 的列名无法从语句中确定
+			if ct, ok := stmts[0].(*tidb.CreateTableStmt); ok && ct.ReferTable == nil && ct.Select == nil {
+				var names []string
+				for _, col := range ct.Cols {
+					if col.Tp != nil {
+						names = append(names, col.Name.Name.O)
+					}
+				}
+				schema[ct.Table.Schema.L+"."+ct.Table.Name.L] = names
+				return
+			}
+		}
+		tables = append(tables, ddl.Table)
+	case sqlparser.AlterStr, sqlparser.TruncateStr:
+		tables = append(tables, ddl.Table)
+	case sqlparser.DropStr, sqlparser.RenameStr:
+		tables = append(append(tables, ddl.FromTables...), ddl.ToTables...)
+	}
+	// 不区分库名，同名的表都不再补全
+	for _, tb := range tables {
+		name := "." + strings.ToLower(tb.Name.String())
+		for k := range schema {
+			if strings.HasSuffix(k, name) {
+				delete(schema, k)
+			}
+		}
+	}
+}
+
+// applyFixes 从后向前应用修改，前面修改的位置不受影响，修改后无法通过语法解析或 check 不通过时放弃该处修改
+// applied 中已应用的修改位置会随之调整，保证按位置排序时与修复后的 SQL 一致
+func applyFixes(sql string, applied []fixEdit, edits []fixEdit, check func(sqlparser.Statement) bool) (string, []fixEdit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, e := range edits {
+		candidate := sql[:e.start] + e.text + sql[e.end:]
+		stmt, err := sqlparser.Parse(candidate)
+		if err != nil || (check != nil && !check(stmt)) {
+			common.Log.Debug("applyFixes skip %s: %s", e.Item, e.Before)
+			continue
+		}
+		for i := range applied {
+			if applied[i].start >= e.end {
+				applied[i].start += len(e.text) - (e.end - e.start)
+			}
+		}
+		e.Line = 1 + strings.Count(sql[:e.start], "\n")
+		applied = append(applied, e)
+		sql = candidate
+	}
+	return sql, applied
+}
+
+// fixTokens 切词并记录每个 token 在 SQL 中的位置，注释不返回
+func fixTokens(sql string) []fixToken {
+	var tokens []fixToken
+	tkn := sqlparser.NewStringTokenizer(sql)
+	end := 0
+	for {
+		typ, _ := tkn.Scan()
+		if typ == 0 || typ == sqlparser.LEX_ERROR {
+			break
+		}
+		start := end
+		for start < len(sql) && strings.IndexByte(" \t\r\n", sql[start]) >= 0 {
+			start++
+		}
+		end = tkn.Position - 1
+		if end > len(sql) {
+			end = len(sql)
+		}
+		if typ != sqlparser.COMMENT {
+			tokens = append(tokens, fixToken{Type: typ, Start: start, End: end})
+		}
+	}
+	return tokens
+}
+
+// fixAlias ALI.001 隐式别名前补充 AS 关键字
+func fixAlias(sql string, tokens []fixToken) []fixEdit {
+	var edits []fixEdit
+	for i := 1; i < len(tokens); i++ {
+		prev, tkn := tokens[i-1], tokens[i]
+		if tkn.Type != sqlparser.ID {
+			continue
+		}
+		switch prev.Type {
+		case sqlparser.ID, ')', sqlparser.STRING, sqlparser.INTEGRAL, sqlparser.FLOAT:
+		default:
+			continue
+		}
+		before, alias := sql[prev.Start:prev.End], sql[tkn.Start:tkn.End]
+		edits = append(edits, fixEdit{
+			Fix:   Fix{Item: "ALI.001", Before: before + " " + alias, After: before + " AS " + alias},
+			start: tkn.Start,
+			end:   tkn.Start,
+			text:  "AS ",
+		})
+	}
+	return edits
+}
+
+// fixNotEqual STA.001 将 != 替换为标准的 <>
+func fixNotEqual(sql string, tokens []fixToken) []fixEdit {
+	var edits []fixEdit
+	for _, tkn := range tokens {
+		if tkn.Type != sqlparser.NE || sql[tkn.Start:tkn.End] != "!=" {
+			continue
+		}
+		edits = append(edits, fixEdit{
+			Fix:   Fix{Item: "STA.001", Before: "!=", After: "<>"},
+			start: tkn.Start,
+			end:   tkn.End,
+			text:  "<>",
+		})
+	}
+	return edits
+}
+
+// fixDateLiteral LIT.002 为比较运算符及 BETWEEN ... AND 中未加引号的日期加上引号
+func fixDateLiteral(sql string, tokens []fixToken) []fixEdit {
+	var edits []fixEdit
+	between := -1 // BETWEEN 之后的第一个日期结束位置，紧跟的 AND 之后的日期同样需要修复
+	for i := 1; i+4 < len(tokens); i++ {
+		date, ok := dateLiteral(sql, tokens[i:i+5])
+		if !ok {
+			continue
+		}
+		// 日期后面不能再有运算，否则无法确定原 SQL 的意图
+		if i+5 < len(tokens) {
+			switch tokens[i+5].Type {
+			case '-', '+', '*', '/', '%', sqlparser.INTEGRAL, sqlparser.FLOAT:
+				continue
+			}
+		}
+		switch tokens[i-1].Type {
+		case '=', '<', '>', sqlparser.LE, sqlparser.GE, sqlparser.NE, sqlparser.NULL_SAFE_EQUAL:
+		case sqlparser.BETWEEN:
+			between = i + 5
+		case sqlparser.AND:
+			if between != i-1 {
+				continue
+			}
+		default:
+			continue
+		}
+		edits = append(edits, fixEdit{
+			Fix:   Fix{Item: "LIT.002", Before: sql[tokens[i].Start:tokens[i+4].End], After: date},
+			start: tokens[i].Start,
+			end:   tokens[i+4].End,
+			text:  date,
+		})
+	}
+	return edits
+}
+
+// dateLiteral 判断 tokens 是否为 YYYY-MM-DD 或 YY-MM-DD 形式的日期，是则返回加引号后的日期
+func dateLiteral(sql string, tokens []fixToken) (string, bool) {
+	if tokens[1].Type != '-' || tokens[3].Type != '-' {
+		return "", false
+	}
+	var parts []string
+	for _, i := range []int{0, 2, 4} {
+		if tokens[i].Type != sqlparser.INTEGRAL {
+			return "", false
+		}
+		parts = append(parts, sql[tokens[i].Start:tokens[i].End])
+	}
+	var month, day int
+	_, errMonth := fmt.Sscan(parts[1], &month)
+	_, errDay := fmt.Sscan(parts[2], &day)
+	if (len(parts[0]) != 4 && len(parts[0]) != 2) || len(parts[1]) > 2 || len(parts[2]) > 2 ||
+		errMonth != nil || errDay != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+		return "", false
+	}
+	return "'" + strings.Join(parts, "-") + "'", true
+}
+
+// fixStarColumns COL.001 单表 SELECT * 在当前文件中已有该表的建表语句时补全列名，SELECT 与建表语句中的库名必须相同
+func fixStarColumns(sql string, tokens []fixToken, schema fixSchema) []fixEdit {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.SelectExprs) != 1 || len(sel.From) != 1 {
+		return nil
+	}
+	star, ok := sel.SelectExprs[0].(*sqlparser.StarExpr)
+	if !ok || !star.TableName.IsEmpty() {
+		return nil
+	}
+	from, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil
+	}
+	table, ok := from.Expr.(sqlparser.TableName)
+	if !ok {
+		return nil
+	}
+	names := schema[strings.ToLower(table.Qualifier.String())+"."+strings.ToLower(table.Name.String())]
+	if len(names) == 0 {
+		return nil
+	}
+
+	// 只处理 SELECT * 及 SELECT DISTINCT *
+	idx := 1
+	if len(tokens) > 2 && tokens[1].Type == sqlparser.DISTINCT {
+		idx = 2
+	}
+	if len(tokens) <= idx || tokens[0].Type != sqlparser.SELECT || tokens[idx].Type != '*' {
+		return nil
+	}
+	var cols []string
+	for _, name := range names {
+		cols = append(cols, sqlparser.String(sqlparser.NewColIdent(name)))
+	}
+	text := strings.Join(cols, ", ")
+	return []fixEdit{{
+		Fix:   Fix{Item: "COL.001", Before: "*", After: text},
+		start: tokens[idx].Start,
+		end:   tokens[idx].End,
+		text:  text,
+	}}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestFixFile(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	buf := `-- 注释及格式保持不变
+CREATE TABLE film (film_id int, title varchar(10), ` + "`desc`" + ` text);
+select  film_id   id, title
+from film f  where film_id != 1; # 行尾注释
+SELECT * FROM film WHERE title = 'a';
+SELECT * FROM actor;
+select count(*) cnt from film where last_update > 2010-01-01 and film_id between 2010-01-01 AND 2010-12-31;
+select title from film where last_update = 2010-01-01 + 1 or title = 2010-13-01;
+select 'a!=b' from film where title = "!=";
+`
+	want := `-- 注释及格式保持不变
+CREATE TABLE film (film_id int, title varchar(10), ` + "`desc`" + ` text);
+select  film_id   AS id, title
+from film AS f  where film_id <> 1; # 行尾注释
+SELECT film_id, title, ` + "`desc`" + ` FROM film WHERE title = 'a';
+SELECT * FROM actor;
+select count(*) AS cnt from film where last_update > '2010-01-01' and film_id between '2010-01-01' AND '2010-12-31';
+select title from film where last_update = 2010-01-01 + 1 or title = 2010-13-01;
+select 'a!=b' from film where title = "!=";
+`
+	got, fixes := FixFile(buf, ";")
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+	wantFixes := []Fix{
+		{Item: "ALI.001", Line: 3, Before: "film_id id", After: "film_id AS id"},
+		{Item: "ALI.001", Line: 4, Before: "film f", After: "film AS f"},
+		{Item: "STA.001", Line: 4, Before: "!=", After: "<>"},
+		{Item: "COL.001", Line: 5, Before: "*", After: "film_id, title, `desc`"},
+		{Item: "ALI.001", Line: 7, Before: ") cnt", After: ") AS cnt"},
+		{Item: "LIT.002", Line: 7, Before: "2010-01-01", After: "'2010-01-01'"},
+		{Item: "LIT.002", Line: 7, Before: "2010-01-01", After: "'2010-01-01'"},
+		{Item: "LIT.002", Line: 7, Before: "2010-12-31", After: "'2010-12-31'"},
+	}
+	if len(fixes) != len(wantFixes) {
+		t.Fatalf("want %d fixes, got: %v", len(wantFixes), fixes)
+	}
+	for i, f := range fixes {
+		if f != wantFixes[i] {
+			t.Errorf("want %v, got %v", wantFixes[i], f)
+		}
+	}
+
+	// 修复后的内容再次修复不会有变化
+	if again, fixes := FixFile(got, ";"); again != got || len(fixes) != 0 {
+		t.Errorf("fix should be idempotent, got: %v", fixes)
+	}
+
+	// 表结构被修改、库名不同或在其他文件中定义的表不补全列名
+	buf = `CREATE TABLE t (a int, b int);
+SELECT * FROM other.t;
+CREATE TABLE other.t (c int);
+SELECT * FROM other.t;
+SELECT * FROM t;
+ALTER TABLE t ADD COLUMN c int;
+SELECT * FROM t;
+RENAME TABLE other.t TO other.t2;
+SELECT * FROM other.t2;
+`
+	want = strings.Replace(buf, "SELECT * FROM other.t;\nSELECT * FROM t;", "SELECT c FROM other.t;\nSELECT a, b FROM t;", 1)
+	if got, _ = FixFile(buf, ";"); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+	if got, fixes = FixFile("SELECT * FROM film;", ";"); len(fixes) != 0 {
+		t.Errorf("schema should not be shared between files, got: %s", got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
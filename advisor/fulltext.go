@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// fulltextMinQueries 同一列上 LIKE '%word%' 的查询达到该条数时才评估全文索引，偶尔出现的模糊查询不值得维护全文索引
+const fulltextMinQueries = 3
+
+// innodbFtMinTokenSize innodb_ft_min_token_size 的默认值，默认解析器不会索引更短的词
+const innodbFtMinTokenSize = 3
+
+// FulltextCandidate 工作负载中多次使用 LIKE '%word%' 模糊查询的列
+type FulltextCandidate struct {
+	Table   string   `json:"Table"`
+	Column  string   `json:"Column"`
+	Queries int      `json:"Queries"` // 使用 LIKE '%word%' 查询该列的 SQL 条数
+	Words   []string `json:"Words"`   // 去除通配符后的关键字
+	Sample  string   `json:"Sample"`  // 一个原始的 LIKE 条件
+}
+
+// fulltextLike SQL 中的一个 col LIKE '%word%' 条件
+type fulltextLike struct {
+	table string
+	col   *sqlparser.ColName
+	word  string
+	cond  string
+}
+
+// fulltextLikes 查找以 % 开头的 LIKE 条件，通配符只出现在首尾且无法确定列所在表的条件会被忽略
+func fulltextLikes(stmt sqlparser.Statement) []fulltextLike {
+	var likes []fulltextLike
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		n, ok := node.(*sqlparser.ComparisonExpr)
+		if !ok || n.Operator != sqlparser.LikeStr || n.Escape != nil {
+			return true, nil
+		}
+		col, ok := n.Left.(*sqlparser.ColName)
+		if !ok {
+			return true, nil
+		}
+		val, ok := n.Right.(*sqlparser.SQLVal)
+		if !ok || val.Type != sqlparser.StrVal || !strings.HasPrefix(string(val.Val), "%") {
+			return true, nil
+		}
+		word := strings.Trim(string(val.Val), "%")
+		if word == "" || strings.ContainsAny(word, `%_'"\`) {
+			return true, nil
+		}
+		table := columnTable(stmt, col)
+		if table == "" {
+			return true, nil
+		}
+		likes = append(likes, fulltextLike{table: table, col: col, word: word, cond: sqlparser.String(n)})
+		return true, nil
+	}, stmt)
+	common.LogIfError(err, "")
+	return likes
+}
+
+// addFulltext 记录 SQL 中的 LIKE '%word%' 条件，同一条 SQL 中同一列只计一次
+func (w *Workload) addFulltext(sql string) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, like := range fulltextLikes(stmt) {
+		key := strings.ToLower(like.table + "." + like.col.Name.String())
+		c, ok := w.fulltext[key]
+		if !ok {
+			c = &FulltextCandidate{Table: like.table, Column: like.col.Name.String(), Sample: like.cond}
+			w.fulltext[key] = c
+		}
+		if !seen[key] {
+			c.Queries++
+			seen[key] = true
+		}
+		found := false
+		for _, word := range c.Words {
+			if word == like.word {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Words = append(c.Words, like.word)
+		}
+	}
+}
+
+// FulltextCandidates 查询条数达到 fulltextMinQueries 且适合添加全文索引的列，按查询条数从高到低排序
+func (w *Workload) FulltextCandidates() []*FulltextCandidate {
+	var candidates []*FulltextCandidate
+	// InnoDB 从 MySQL 5.6、MariaDB 10.0.5 开始支持全文索引
+	if common.TargetDB().Unsupported(50600, 100005) {
+		return candidates
+	}
+	for _, c := range w.fulltext {
+		if c.Queries < fulltextMinQueries {
+			continue
+		}
+		// 离线表结构中的列不是字符串类型时不能添加全文索引
+		if col, ok := offlineSchema[strings.ToLower(c.Table)][strings.ToLower(c.Column)]; ok && columnTypeClass(col.DataType) != "string" {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Queries != candidates[j].Queries {
+			return candidates[i].Queries > candidates[j].Queries
+		}
+		return candidates[i].Table+"."+candidates[i].Column < candidates[j].Table+"."+candidates[j].Column
+	})
+	return candidates
+}
+
+// ngram 关键字中包含中日韩文字或短于 innodb_ft_min_token_size 时使用 ngram 解析器，ngram 解析器从 MySQL 5.7.6 开始支持
+func (c *FulltextCandidate) ngram() bool {
+	if common.TargetDB().IsMariaDB() || common.TargetDB().Unsupported(50706, 0) {
+		return false
+	}
+	for _, word := range c.Words {
+		if len([]rune(word)) < innodbFtMinTokenSize {
+			return true
+		}
+		for _, r := range word {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DDL 添加全文索引的语句
+func (c *FulltextCandidate) DDL() string {
+	ddl := fmt.Sprintf("ALTER TABLE `%s` ADD FULLTEXT INDEX `ft_%s` (`%s`)", c.Table, strings.ToLower(c.Column), c.Column)
+	if c.ngram() {
+		ddl += " WITH PARSER ngram"
+	}
+	return ddl + ";"
+}
+
+// Rewrite 将第一个关键字的 LIKE 条件改写为 MATCH ... AGAINST
+// ngram 解析器及包含空格的关键字使用短语查询，默认解析器使用前缀查询
+func (c *FulltextCandidate) Rewrite() string {
+	word := c.Words[0]
+	against := fmt.Sprintf("+%s*", word)
+	if c.ngram() || strings.ContainsAny(word, " \t") {
+		against = fmt.Sprintf(`"%s"`, word)
+	}
+	return fmt.Sprintf("`%s` 改写为 `MATCH(%s) AGAINST('%s' IN BOOLEAN MODE)`", c.Sample, c.Column, against)
+}
+
+// Notes 使用全文索引前需要确认的语义差异及相关的服务端参数
+func (c *FulltextCandidate) Notes() []string {
+	var notes []string
+	if c.ngram() {
+		notes = append(notes, "关键字中包含中日韩文字或短于 innodb_ft_min_token_size（默认 3）的词，使用 ngram 解析器，按 ngram_token_size（默认 2）切分，短于 ngram_token_size 的关键字无法使用全文索引。")
+	} else {
+		notes = append(notes, "默认解析器按空格及标点分词，只能匹配完整的词或词的前缀，与 LIKE '%word%' 匹配任意子串的语义不同；短于 innodb_ft_min_token_size（默认 3）、长于 innodb_ft_max_token_size（默认 84）的词及停用词（innodb_ft_enable_stopword）不会被索引。")
+	}
+	notes = append(notes,
+		"修改 innodb_ft_min_token_size, ngram_token_size 等参数需要重启实例并重建全文索引。",
+		"全文索引在事务提交时才更新，频繁写入的表需要定期使用 innodb_optimize_fulltext_only 执行 OPTIMIZE TABLE 整理索引。")
+	return notes
+}
+
+// formatFulltext 以 markdown 格式输出全文索引建议，没有候选列时返回 nil
+func (w *Workload) formatFulltext() []string {
+	candidates := w.FulltextCandidates()
+	if len(candidates) == 0 {
+		return nil
+	}
+	buf := []string{"", "## 全文索引建议", "",
+		fmt.Sprintf("以下列在 %d 条以上的 SQL 中使用了以 %% 开头的 LIKE 模糊查询，无法使用普通索引，可以评估是否使用全文索引。", fulltextMinQueries)}
+	for _, c := range candidates {
+		buf = append(buf, "", fmt.Sprintf("### %s.%s", common.MarkdownEscape(c.Table), common.MarkdownEscape(c.Column)), "",
+			fmt.Sprintf("* **SQL 条数:** %d", c.Queries),
+			fmt.Sprintf("* **关键字:** %s", common.MarkdownEscape(strings.Join(c.Words, ", "))),
+			fmt.Sprintf("* **DDL:** `%s`", c.DDL()),
+			fmt.Sprintf("* **改写:** %s", c.Rewrite()))
+		for _, note := range c.Notes() {
+			buf = append(buf, "* "+note)
+		}
+	}
+	return buf
+}
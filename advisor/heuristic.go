@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode/utf8"
@@ -32,7 +33,9 @@ import (
 	"github.com/percona/go-mysql/query"
 	tidb "github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/types"
 	"github.com/tidwall/gjson"
 	"vitess.io/vitess/go/vt/sqlparser"
 )
@@ -45,7 +48,12 @@ func (q *Query4Audit) RuleOK() Rule {
 // RuleImplicitAlias ALI.001
 func (q *Query4Audit) RuleImplicitAlias() Rule {
 	var rule = q.RuleOK()
-	tkns := ast.Tokenizer(q.Query)
+	sql := q.Query
+	// FROM tbl FINAL, SAMPLE 0.1 等 ClickHouse 语法会被误认为是别名
+	if q.ClickHouse != nil {
+		sql = q.ClickHouse.SQL
+	}
+	tkns := ast.Tokenizer(sql)
 	if len(tkns) == 0 {
 		return rule
 	}
@@ -355,6 +363,112 @@ func (idxAdv *IndexAdvisor) RuleImplicitConversion() Rule {
 	return rule
 }
 
+// RuleCharsetMismatch ARG.014
+// 关联或比较的两列字符集、排序规则不一致时无法使用索引，给出统一字符集的 ALTER 语句
+func (idxAdv *IndexAdvisor) RuleCharsetMismatch() Rule {
+	rule := HeuristicRules["OK"]
+	// 未开启测试环境不进行检查
+	if common.Config.TestDSN.Disable {
+		return rule
+	}
+
+	var content []string
+	for _, cond := range ast.FindAllCondition(idxAdv.Ast) {
+		node, ok := cond.(*sqlparser.ComparisonExpr)
+		if !ok {
+			continue
+		}
+
+		// 列与列比较，或列与指定了 COLLATE 的值比较，如： col = 'abc' COLLATE utf8mb4_bin
+		var colList []*common.Column
+		var collate string
+		for _, expr := range []sqlparser.Expr{node.Left, node.Right} {
+			switch n := expr.(type) {
+			case *sqlparser.ColName:
+				col := &common.Column{Name: n.Name.String()}
+				if !n.Qualifier.Name.IsEmpty() {
+					col.Table = n.Qualifier.Name.String()
+				}
+				colList = append(colList, col)
+			case *sqlparser.CollateExpr:
+				if _, ok := n.Expr.(*sqlparser.SQLVal); ok {
+					collate = strings.ToLower(n.Charset)
+				}
+			}
+		}
+
+		colList = CompleteColumnsInfo(idxAdv.Ast, colList, idxAdv.vEnv)
+		for _, col := range colList {
+			// 非字符串类型的列不需要检查字符集
+			if col.Table == "" || col.Character == "" || !isStringType(col.DataType) {
+				colList = nil
+				break
+			}
+		}
+
+		switch {
+		case len(colList) == 2 && collate == "":
+			if colList[0].Character == colList[1].Character && colList[0].Collation == colList[1].Collation {
+				continue
+			}
+			// 优先将字符集转换为 utf8mb4，避免数据丢失
+			target, convert := colList[0], colList[1]
+			if convert.Character == "utf8mb4" && target.Character != "utf8mb4" {
+				target, convert = convert, target
+			}
+			content = append(content, fmt.Sprintf("`%s`.`%s` (%s, %s) VS `%s`.`%s` (%s, %s) charset or collation not match, %s",
+				colList[0].Table, colList[0].Name, colList[0].Character, colList[0].Collation,
+				colList[1].Table, colList[1].Name, colList[1].Character, colList[1].Collation,
+				alterColumnCharset(idxAdv.vEnv.Hash2DB[convert.DB], convert, target.Character, target.Collation)))
+
+		case len(colList) == 1 && collate != "":
+			if colList[0].Collation == collate {
+				continue
+			}
+			content = append(content, fmt.Sprintf("`%s`.`%s` (%s) VS COLLATE %s collation not match",
+				colList[0].Table, colList[0].Name, colList[0].Collation, collate))
+		}
+	}
+
+	if len(content) > 0 {
+		rule = HeuristicRules["ARG.014"]
+		rule.Content = strings.Join(common.RemoveDuplicatesItem(content), " ")
+	}
+	return rule
+}
+
+// isStringType 判断数据类型是否为字符串类型
+func isStringType(dataType string) bool {
+	switch strings.ToLower(common.GetDataTypeBase(dataType)) {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+		return true
+	}
+	return false
+}
+
+// alterColumnCharset 生成修改列字符集的 ALTER 语句，保留列原有的类型、是否为空、默认值及注释
+// 列信息来自测试环境，db 为线上环境中的库名；默认值为空字符串时无法与 NULL 区分，不会出现在语句中
+func alterColumnCharset(db string, col *common.Column, charset, collation string) string {
+	tb := fmt.Sprintf("`%s`", col.Table)
+	if db != "" {
+		tb = fmt.Sprintf("`%s`.%s", db, tb)
+	}
+	def := []string{fmt.Sprintf("ALTER TABLE %s MODIFY `%s` %s CHARACTER SET %s", tb, col.Name, col.DataType, charset)}
+	if collation != "" {
+		def = append(def, "COLLATE "+collation)
+	}
+	if col.Null == "NO" {
+		def = append(def, "NOT NULL")
+	}
+	if col.Default != "" {
+		def = append(def, fmt.Sprintf("DEFAULT '%s'", strings.Replace(col.Default, "'", "''", -1)))
+	}
+	if col.Comment != "" {
+		def = append(def, fmt.Sprintf("COMMENT '%s'", strings.Replace(col.Comment, "'", "''", -1)))
+	}
+	return strings.Join(def, " ") + ";"
+}
+
 // RuleNoWhere CLA.001 & CLA.014 & CLA.015
 func (q *Query4Audit) RuleNoWhere() Rule {
 	var rule = q.RuleOK()
@@ -1076,47 +1190,344 @@ func (idxAdv *IndexAdvisor) RuleImpossibleOuterJoin() Rule {
 // TODO: JOI.004
 
 // RuleNoDeterministicGroupby RES.001
+// 检查 SELECT, HAVING, ORDER BY 中既不在 GROUP BY 中也不在聚合函数中的列，并结合 -sql-mode 判断是否满足 ONLY_FULL_GROUP_BY
 func (q *Query4Audit) RuleNoDeterministicGroupby() Rule {
 	var rule = q.RuleOK()
-	var groupbyCols []*common.Column
-	var selectCols []*common.Column
+	var cols []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if n, ok := node.(*sqlparser.Select); ok {
+			cols = append(cols, nonGroupedColumns(n)...)
+		}
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+
+	if len(cols) > 0 {
+		cols = common.RemoveDuplicatesItem(cols)
+		rule = HeuristicRules["RES.001"]
+		rule.Position = clausePosition(q.Query, "select", cols[0])
+		msg := fmt.Sprintf("%s 既不在 GROUP BY 中也不在聚合函数中", strings.Join(cols, ", "))
+		if common.HasSQLMode("ONLY_FULL_GROUP_BY") {
+			msg += "，sql_mode 包含 ONLY_FULL_GROUP_BY 时该 SQL 会执行失败。"
+		} else {
+			msg += "，返回的值是不确定的。"
+		}
+		rule.Content = strings.Join([]string{rule.Content, msg}, " ")
+	}
+	return rule
+}
+
+// nonGroupedColumns 获取单个 SELECT 中违反 ONLY_FULL_GROUP_BY 的列，不检查子查询，也不考虑主键带来的函数依赖
+func nonGroupedColumns(sel *sqlparser.Select) []string {
+	aliases := selectAliases(sel)
+	var groupExprs []string
+	var groupCols []*sqlparser.ColName
+	for _, g := range sel.GroupBy {
+		// GROUP BY 1, GROUP BY alias
+		if expr := resolveSelectRef(sel, aliases, g); expr != nil {
+			g = expr
+		}
+		groupExprs = append(groupExprs, sqlparser.String(g))
+		if col, ok := g.(*sqlparser.ColName); ok {
+			groupCols = append(groupCols, col)
+		}
+	}
+
+	hasAgg := false
+	for _, expr := range sel.SelectExprs {
+		if e, ok := expr.(*sqlparser.AliasedExpr); ok && len(aggregateColumns(e.Expr)) > 0 {
+			hasAgg = true
+		}
+	}
+	// 没有 GROUP BY 且没有聚合函数时不需要检查
+	if len(sel.GroupBy) == 0 && !hasAgg {
+		return nil
+	}
+
+	grouped := func(expr sqlparser.Expr) []string {
+		for _, g := range groupExprs {
+			if g == sqlparser.String(expr) {
+				return nil
+			}
+		}
+		var cols []string
+		for _, col := range freeColumns(expr) {
+			found := false
+			for _, g := range groupCols {
+				if strings.EqualFold(g.Name.String(), col.Name.String()) &&
+					(g.Qualifier.IsEmpty() || col.Qualifier.IsEmpty() || strings.EqualFold(g.Qualifier.Name.String(), col.Qualifier.Name.String())) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				cols = append(cols, sqlparser.String(col))
+			}
+		}
+		return cols
+	}
+
+	var cols []string
+	for _, expr := range sel.SelectExprs {
+		switch e := expr.(type) {
+		case *sqlparser.StarExpr:
+			if len(sel.GroupBy) > 0 {
+				cols = append(cols, sqlparser.String(e))
+			}
+		case *sqlparser.AliasedExpr:
+			cols = append(cols, grouped(e.Expr)...)
+		}
+	}
+	if sel.Having != nil {
+		cols = append(cols, grouped(sel.Having.Expr)...)
+	}
+	for _, o := range sel.OrderBy {
+		if expr := resolveSelectRef(sel, aliases, o.Expr); expr != nil {
+			// 引用 SELECT 中的列已经检查过了
+			continue
+		}
+		cols = append(cols, grouped(o.Expr)...)
+	}
+	return cols
+}
+
+// selectAliases SELECT 中的别名
+func selectAliases(sel *sqlparser.Select) map[string]sqlparser.Expr {
+	aliases := make(map[string]sqlparser.Expr)
+	for _, expr := range sel.SelectExprs {
+		if e, ok := expr.(*sqlparser.AliasedExpr); ok && !e.As.IsEmpty() {
+			aliases[e.As.Lowered()] = e.Expr
+		}
+	}
+	return aliases
+}
+
+// resolveSelectRef 将 GROUP BY, ORDER BY 中的位置或别名转换为 SELECT 中对应的表达式，不是引用时返回 nil
+func resolveSelectRef(sel *sqlparser.Select, aliases map[string]sqlparser.Expr, expr sqlparser.Expr) sqlparser.Expr {
+	switch e := expr.(type) {
+	case *sqlparser.SQLVal:
+		if e.Type != sqlparser.IntVal {
+			return nil
+		}
+		pos, err := strconv.Atoi(string(e.Val))
+		if err != nil || pos < 1 || pos > len(sel.SelectExprs) {
+			return nil
+		}
+		if ae, ok := sel.SelectExprs[pos-1].(*sqlparser.AliasedExpr); ok {
+			return ae.Expr
+		}
+	case *sqlparser.ColName:
+		if e.Qualifier.IsEmpty() {
+			return aliases[e.Name.Lowered()]
+		}
+	}
+	return nil
+}
+
+// aggregateColumns 获取表达式中聚合函数内的列，没有聚合函数时返回空
+func aggregateColumns(expr sqlparser.Expr) []sqlparser.Expr {
+	var aggs []sqlparser.Expr
 	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
 		switch n := node.(type) {
-		case *sqlparser.Select:
-			// 过滤select列
-			selectCols = ast.FindColumn(n.SelectExprs)
-			// 过滤group by列
-			groupbyCols = ast.FindColumn(n.GroupBy)
-			// `select *`, but not `select count(*)`
-			if strings.Contains(sqlparser.String(n), " * ") && len(groupbyCols) > 0 {
-				rule = HeuristicRules["RES.001"]
+		case *sqlparser.FuncExpr:
+			if n.IsAggregate() {
+				aggs = append(aggs, n)
 				return false, nil
 			}
+		case *sqlparser.GroupConcatExpr:
+			aggs = append(aggs, n)
+			return false, nil
+		case *sqlparser.Subquery:
+			return false, nil
 		}
 		return true, nil
-	}, q.Stmt)
+	}, expr)
 	common.LogIfError(err, "")
+	return aggs
+}
 
-	// TODO：暂时只检查了列名，未对库表名进行检查，也未处理AS
-	for _, s := range selectCols {
-		// 无group by退出
-		if len(groupbyCols) == 0 {
-			break
+// freeColumns 获取表达式中不在聚合函数及子查询中的列
+func freeColumns(expr sqlparser.Expr) []*sqlparser.ColName {
+	var cols []*sqlparser.ColName
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.FuncExpr:
+			// ANY_VALUE() 用于显式地忽略 ONLY_FULL_GROUP_BY 检查
+			if n.IsAggregate() || n.Name.Lowered() == "any_value" {
+				return false, nil
+			}
+		case *sqlparser.GroupConcatExpr, *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.ColName:
+			cols = append(cols, n)
 		}
-		found := false
-		for _, g := range groupbyCols {
-			if g.Name == s.Name {
-				found = true
+		return true, nil
+	}, expr)
+	common.LogIfError(err, "")
+	return cols
+}
+
+// clausePosition 获取 clause 子句之后 word 在 SQL 中的位置，找不到时返回 0
+func clausePosition(query, clause, word string) int {
+	start := 0
+	if loc := regexp.MustCompile(`(?i)\b` + strings.Replace(regexp.QuoteMeta(clause), " ", `\s+`, -1) + `\b`).FindStringIndex(query); loc != nil {
+		start = loc[1]
+	}
+	re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(word))
+	if loc := re.FindStringIndex(query[start:]); loc != nil {
+		return start + loc[0]
+	}
+	return 0
+}
+
+// RuleAliasShadowColumn RES.012
+// ORDER BY 优先使用 SELECT 中的别名，GROUP BY, HAVING 优先使用表中的列，别名与列同名时两者的含义不同
+func (q *Query4Audit) RuleAliasShadowColumn() Rule {
+	var rule = q.RuleOK()
+	var content []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		sel, ok := node.(*sqlparser.Select)
+		if !ok {
+			return true, nil
+		}
+		// SELECT 中出现的列名，别名与之相同时认为别名覆盖了表中的列
+		columns := make(map[string]bool)
+		for _, expr := range sel.SelectExprs {
+			if e, ok := expr.(*sqlparser.AliasedExpr); ok {
+				for _, col := range freeColumns(e.Expr) {
+					columns[col.Name.Lowered()] = true
+				}
+				for _, agg := range aggregateColumns(e.Expr) {
+					err := sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+						if col, ok := node.(*sqlparser.ColName); ok {
+							columns[col.Name.Lowered()] = true
+						}
+						return true, nil
+					}, agg)
+					common.LogIfError(err, "")
+				}
 			}
 		}
-		if !found {
-			rule = HeuristicRules["RES.001"]
-			break
+		if sel.Where != nil {
+			err := sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+				if col, ok := node.(*sqlparser.ColName); ok {
+					columns[col.Name.Lowered()] = true
+				}
+				return true, nil
+			}, sel.Where.Expr)
+			common.LogIfError(err, "")
+		}
+		for _, tb := range offlineTables(sel) {
+			for col := range offlineSchema[tb] {
+				columns[col] = true
+			}
+		}
+
+		shadows := make(map[string]bool)
+		for _, expr := range sel.SelectExprs {
+			e, ok := expr.(*sqlparser.AliasedExpr)
+			if !ok || e.As.IsEmpty() {
+				continue
+			}
+			// SELECT col AS col 不会产生歧义
+			if col, ok := e.Expr.(*sqlparser.ColName); ok && col.Name.Equal(e.As) {
+				continue
+			}
+			if columns[e.As.Lowered()] {
+				shadows[e.As.Lowered()] = true
+			}
+		}
+		if len(shadows) == 0 {
+			return true, nil
+		}
+
+		check := func(clause string, exprs []sqlparser.Expr) {
+			for _, expr := range exprs {
+				col, ok := expr.(*sqlparser.ColName)
+				if ok && col.Qualifier.IsEmpty() && shadows[col.Name.Lowered()] {
+					if rule.Item != "RES.012" {
+						rule = HeuristicRules["RES.012"]
+						rule.Position = clausePosition(q.Query, clause, col.Name.String())
+					}
+					content = append(content, fmt.Sprintf("%s %s", strings.ToUpper(clause), sqlparser.String(col)))
+				}
+			}
 		}
+		var orderBy []sqlparser.Expr
+		for _, o := range sel.OrderBy {
+			orderBy = append(orderBy, o.Expr)
+		}
+		check("order by", orderBy)
+		check("group by", sel.GroupBy)
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+	if len(content) > 0 {
+		rule.Content = strings.Join(append([]string{rule.Content}, common.RemoveDuplicatesItem(content)...), " ")
 	}
 	return rule
 }
 
+// offlineTables SELECT 中引用的在离线表结构中存在的表
+func offlineTables(sel *sqlparser.Select) []string {
+	var tables []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case sqlparser.TableName:
+			if _, ok := offlineSchema[strings.ToLower(n.Name.String())]; ok {
+				tables = append(tables, strings.ToLower(n.Name.String()))
+			}
+		case *sqlparser.Subquery:
+			return false, nil
+		}
+		return true, nil
+	}, sel.From)
+	common.LogIfError(err, "")
+	return tables
+}
+
+// RuleGroupByPositionRange RES.013
+// GROUP BY, ORDER BY 中使用的位置超出了 SELECT 中列的个数
+func (q *Query4Audit) RuleGroupByPositionRange() Rule {
+	var rule = q.RuleOK()
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		sel, ok := node.(*sqlparser.Select)
+		if !ok {
+			return true, nil
+		}
+		// SELECT * 无法确定列的个数
+		for _, expr := range sel.SelectExprs {
+			if _, ok := expr.(*sqlparser.StarExpr); ok {
+				return true, nil
+			}
+		}
+		check := func(clause string, expr sqlparser.Expr) {
+			val, ok := expr.(*sqlparser.SQLVal)
+			if !ok || val.Type != sqlparser.IntVal {
+				return
+			}
+			pos, err := strconv.Atoi(string(val.Val))
+			if err == nil && pos >= 1 && pos <= len(sel.SelectExprs) {
+				return
+			}
+			if rule.Item != "RES.013" {
+				rule = HeuristicRules["RES.013"]
+				rule.Position = clausePosition(q.Query, clause, string(val.Val))
+				rule.Content = fmt.Sprintf("%s %s %s", rule.Content, strings.ToUpper(clause), string(val.Val))
+			}
+		}
+		for _, g := range sel.GroupBy {
+			check("group by", g)
+		}
+		for _, o := range sel.OrderBy {
+			check("order by", o.Expr)
+		}
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+	return rule
+}
+
 // RuleNoDeterministicLimit RES.002
 func (q *Query4Audit) RuleNoDeterministicLimit() Rule {
 	var rule = q.RuleOK()
@@ -1238,6 +1649,12 @@ func (q *Query4Audit) RuleImpossibleWhere() Rule {
 		return true, nil
 	}, q.Stmt)
 	common.LogIfError(err, "")
+
+	// a > 10 AND a < 5 等同一列上没有交集的条件
+	if f := analyzePredicates(q.Stmt); len(f.contradictions) > 0 {
+		rule = HeuristicRules["RES.006"]
+		rule.Content = strings.Join(append([]string{rule.Content}, quotePredicates(f.contradictions)...), " ")
+	}
 	return rule
 }
 
@@ -1296,6 +1713,22 @@ func (q *Query4Audit) RuleMeaninglessWhere() Rule {
 		return true, nil
 	}, q.Stmt)
 	common.LogIfError(err, "")
+
+	// a > 5 OR a <= 5, x OR NOT x 等除 NULL 外永远为真的条件
+	if f := analyzePredicates(q.Stmt); len(f.tautologies) > 0 {
+		rule = HeuristicRules["RES.007"]
+		rule.Content = strings.Join(append([]string{rule.Content}, quotePredicates(f.tautologies)...), " ")
+	}
+	return rule
+}
+
+// RuleDuplicatePredicate RES.014
+func (q *Query4Audit) RuleDuplicatePredicate() Rule {
+	var rule = q.RuleOK()
+	if f := analyzePredicates(q.Stmt); len(f.duplicates) > 0 {
+		rule = HeuristicRules["RES.014"]
+		rule.Content = strings.Join(append([]string{rule.Content}, quotePredicates(f.duplicates)...), " ")
+	}
 	return rule
 }
 
@@ -1580,7 +2013,7 @@ func (q *Query4Audit) RuleInSubquery() Rule {
 // RuleSubqueryDepth SUB.004
 func (q *Query4Audit) RuleSubqueryDepth() Rule {
 	var rule = q.RuleOK()
-	if depth := ast.GetSubqueryDepth(q.Stmt); depth > common.Config.MaxSubqueryDepth {
+	if depth := ast.GetSubqueryDepth(q.Stmt); depth > RuleThreshold("SUB.004") {
 		rule = HeuristicRules["SUB.004"]
 	}
 	return rule
@@ -1719,13 +2152,16 @@ func (q *Query4Audit) RuleRecursiveDependency() Rule {
 }
 
 // RuleImpreciseDataType COL.009
+// 金额、数量列给出建议的 DECIMAL 类型，有离线表结构时还检查 FLOAT 与 DECIMAL 的混合运算
 func (q *Query4Audit) RuleImpreciseDataType() Rule {
 	var rule = q.RuleOK()
+	var notes []string
 	if q.TiStmt != nil {
 		for _, tiStmt := range q.TiStmt {
 			switch node := tiStmt.(type) {
 			case *tidb.CreateTableStmt:
 				// Create table statement
+				notes = append(notes, floatMoneyColumns(node.Cols)...)
 				for _, col := range node.Cols {
 					if col.Tp == nil {
 						continue
@@ -1741,6 +2177,7 @@ func (q *Query4Audit) RuleImpreciseDataType() Rule {
 				for _, spec := range node.Specs {
 					switch spec.Tp {
 					case tidb.AlterTableAddColumns, tidb.AlterTableChangeColumn, tidb.AlterTableModifyColumn:
+						notes = append(notes, floatMoneyColumns(spec.NewColumns)...)
 						for _, col := range spec.NewColumns {
 							if col.Tp == nil {
 								continue
@@ -1778,6 +2215,11 @@ func (q *Query4Audit) RuleImpreciseDataType() Rule {
 		}
 	}
 
+	notes = append(notes, floatDecimalMix(q.Stmt)...)
+	if len(notes) > 0 {
+		rule = HeuristicRules["COL.009"]
+		rule.Content = strings.Join(append([]string{rule.Content}, notes...), " ")
+	}
 	return rule
 }
 
@@ -2018,7 +2460,7 @@ func (q *Query4Audit) RulePatternMatchingUsage() Rule {
 // RuleSpaghettiQueryAlert CLA.012
 func (q *Query4Audit) RuleSpaghettiQueryAlert() Rule {
 	var rule = q.RuleOK()
-	if len(query.Fingerprint(q.Query)) > common.Config.SpaghettiQueryLength {
+	if len(query.Fingerprint(q.Query)) > RuleThreshold("CLA.012") {
 		rule = HeuristicRules["CLA.012"]
 	}
 	return rule
@@ -2054,7 +2496,7 @@ func (q *Query4Audit) RuleReduceNumberOfJoin() Rule {
 		}, q.Stmt)
 		common.LogIfError(err, "")
 	}
-	if len(tables) > common.Config.MaxJoinTableCount {
+	if len(tables) > RuleThreshold("JOI.005") {
 		rule = HeuristicRules["JOI.005"]
 	}
 	return rule
@@ -2067,7 +2509,7 @@ func (q *Query4Audit) RuleDistinctUsage() Rule {
 	switch q.Stmt.(type) {
 	case *sqlparser.Select:
 		re := regexp.MustCompile(`(?i)(\bdistinct\b)`)
-		if len(re.FindAllString(q.Query, -1)) > common.Config.MaxDistinctCount {
+		if len(re.FindAllString(q.Query, -1)) > RuleThreshold("DIS.001") {
 			rule = HeuristicRules["DIS.001"]
 		}
 	}
@@ -2200,28 +2642,69 @@ func (q *Query4Audit) RuleMultiDeleteUpdate() Rule {
 }
 
 // RuleMultiDBJoin JOI.008
+// 配置了 allowed-schema-groups 时同组的库之间允许 JOIN，只对跨组的 JOIN 给出建议
 func (q *Query4Audit) RuleMultiDBJoin() Rule {
 	var rule = q.RuleOK()
 	meta := ast.GetMeta(q.Stmt, nil)
-	dbCount := 0
-	for range meta {
-		dbCount++
+	var dbs []string
+	for db := range meta {
+		dbs = append(dbs, db)
+	}
+	if len(dbs) < 2 {
+		return rule
+	}
+	var groups []string
+	if len(common.Config.AllowedSchemaGroups) > 0 {
+		groups = schemaGroups(dbs)
+		if len(groups) < 2 {
+			return rule
+		}
 	}
 
-	if dbCount > 1 {
-		err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
-			switch node.(type) {
-			case *sqlparser.JoinTableExpr:
-				rule = HeuristicRules["JOI.008"]
-				return false, nil
-			}
-			return true, nil
-		}, q.Stmt)
-		common.LogIfError(err, "")
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node.(type) {
+		case *sqlparser.JoinTableExpr:
+			rule = HeuristicRules["JOI.008"]
+			return false, nil
+		}
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+	if rule.Item == "JOI.008" && len(groups) > 1 {
+		rule.Content = strings.Join([]string{rule.Content, fmt.Sprintf(
+			"涉及的库分属 allowed-schema-groups 中不同的组: %s，拆库后将无法 JOIN。", strings.Join(groups, ", "))}, " ")
 	}
 	return rule
 }
 
+// schemaGroups 返回 dbs 所属的 allowed-schema-groups 分组，按名称排序，未出现在任何分组中的库单独成组
+// 未指定库名的表使用当前库，不参与判断
+func schemaGroups(dbs []string) []string {
+	groupOf := make(map[string]string)
+	for _, g := range common.Config.AllowedSchemaGroups {
+		g = strings.TrimSpace(g)
+		for _, db := range strings.Split(g, "|") {
+			if db = strings.ToLower(strings.TrimSpace(db)); db != "" {
+				if _, ok := groupOf[db]; !ok {
+					groupOf[db] = g
+				}
+			}
+		}
+	}
+	var groups []string
+	for _, db := range dbs {
+		if db == "" {
+			continue
+		}
+		g, ok := groupOf[strings.ToLower(db)]
+		if !ok {
+			g = strings.ToLower(db)
+		}
+		groups = append(groups, g)
+	}
+	return common.RemoveDuplicatesItem(groups)
+}
+
 // RuleORUsage ARG.008
 func (q *Query4Audit) RuleORUsage() Rule {
 	var rule = q.RuleOK()
@@ -2319,14 +2802,44 @@ func (q *Query4Audit) RuleInsertValues() Rule {
 	case *sqlparser.Insert:
 		switch val := s.Rows.(type) {
 		case sqlparser.Values:
-			if len(val) > common.Config.MaxValueCount {
+			if len(val) > RuleThreshold("ARG.012") {
 				rule = HeuristicRules["ARG.012"]
+				if size := insertBatchSize(); size > 0 {
+					// 拆分后的语句可能很长，放在 Content 中，Case 仍然保留规则的示例
+					stmts := splitInsertValues(s, val, size)
+					rule.Content = strings.Join([]string{rule.Content, fmt.Sprintf(
+						"共 %d 行，按每批 %d 行拆分为 %d 条语句，拆分后各语句分别提交，不再是一个原子操作。拆分后的语句：\n%s",
+						len(val), size, len(stmts), strings.Join(stmts, "\n"))}, " ")
+				}
 			}
 		}
 	}
 	return rule
 }
 
+// insertBatchSize 分批写入时每条 INSERT 语句的行数，未配置 insert-batch-size 时使用 ARG.012 的阈值
+func insertBatchSize() int {
+	if common.Config.InsertBatchSize > 0 {
+		return common.Config.InsertBatchSize
+	}
+	return RuleThreshold("ARG.012")
+}
+
+// splitInsertValues 将 INSERT/REPLACE 按每批 size 行拆分，保留列名、IGNORE、PARTITION 及 ON DUPLICATE KEY UPDATE
+func splitInsertValues(stmt *sqlparser.Insert, rows sqlparser.Values, size int) []string {
+	var stmts []string
+	chunk := *stmt
+	for i := 0; i < len(rows); i += size {
+		end := i + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		chunk.Rows = rows[i:end]
+		stmts = append(stmts, sqlparser.String(&chunk)+";")
+	}
+	return stmts
+}
+
 // RuleFullWidthQuote ARG.013
 func (q *Query4Audit) RuleFullWidthQuote() Rule {
 	var rule = q.RuleOK()
@@ -2458,8 +2971,10 @@ func (q *Query4Audit) RuleInjection() Rule {
 }
 
 // RuleCompareWithFunction FUN.001
+// 对命中的条件给出改写为范围查询或建立函数索引的建议
 func (q *Query4Audit) RuleCompareWithFunction() Rule {
 	var rule = q.RuleOK()
+	var conds []sqlparser.Expr
 	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
 		// Vitess 中有些函数进行了单独定义不在 FuncExpr 中，如: substring。所以不能直接用 FuncExpr 判断。
 		switch n := node.(type) {
@@ -2467,8 +2982,7 @@ func (q *Query4Audit) RuleCompareWithFunction() Rule {
 			switch n.Left.(type) {
 			case *sqlparser.SQLVal, *sqlparser.ColName:
 			default:
-				rule = HeuristicRules["FUN.001"]
-				return false, nil
+				conds = append(conds, n)
 			}
 			/*
 				// func always has bracket
@@ -2480,28 +2994,24 @@ func (q *Query4Audit) RuleCompareWithFunction() Rule {
 
 		case *sqlparser.RangeCond:
 			// func(a) between func(c) and func(d)
-			switch n.Left.(type) {
-			case *sqlparser.SQLVal, *sqlparser.ColName:
-			default:
-				rule = HeuristicRules["FUN.001"]
-				return false, nil
-			}
-			switch n.From.(type) {
-			case *sqlparser.SQLVal, *sqlparser.ColName:
-			default:
-				rule = HeuristicRules["FUN.001"]
-				return false, nil
-			}
-			switch n.To.(type) {
-			case *sqlparser.SQLVal, *sqlparser.ColName:
-			default:
-				rule = HeuristicRules["FUN.001"]
-				return false, nil
+			for _, expr := range []sqlparser.Expr{n.Left, n.From, n.To} {
+				switch expr.(type) {
+				case *sqlparser.SQLVal, *sqlparser.ColName:
+				default:
+					conds = append(conds, n)
+					return true, nil
+				}
 			}
 		}
 		return true, nil
 	}, q.Stmt)
 	common.LogIfError(err, "")
+	if len(conds) > 0 {
+		rule = HeuristicRules["FUN.001"]
+		if hints := sargableHints(q.Stmt, conds); len(hints) > 0 {
+			rule.Content = strings.Join(append([]string{rule.Content}, hints...), " ")
+		}
+	}
 	return rule
 }
 
@@ -2549,8 +3059,13 @@ func (q *Query4Audit) RuleIn() Rule {
 							return false, nil
 						}
 					}
-					if len(r) > common.Config.MaxInCount {
+					if len(r) > RuleThreshold("ARG.005") {
 						rule = HeuristicRules["ARG.005"]
+						fixes, script := inListRewrite(q.Query)
+						rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+						if script != "" {
+							rule.Case = script
+						}
 						return false, nil
 					}
 				}
@@ -2786,7 +3301,7 @@ func (q *Query4Audit) RuleTooManyKeys() Rule {
 		for _, tiStmt := range q.TiStmt {
 			switch node := tiStmt.(type) {
 			case *tidb.CreateTableStmt:
-				if len(node.Constraints) > common.Config.MaxIdxCount {
+				if len(node.Constraints) > RuleThreshold("KEY.005") {
 					rule = HeuristicRules["KEY.005"]
 				}
 			}
@@ -2804,11 +3319,11 @@ func (q *Query4Audit) RuleTooManyKeyParts() Rule {
 			switch node := tiStmt.(type) {
 			case *tidb.CreateTableStmt:
 				for _, constraint := range node.Constraints {
-					if len(constraint.Keys) > common.Config.MaxIdxColsCount {
+					if len(constraint.Keys) > RuleThreshold("KEY.006") {
 						return HeuristicRules["KEY.006"]
 					}
 
-					if constraint.Refer != nil && len(constraint.Refer.IndexColNames) > common.Config.MaxIdxColsCount {
+					if constraint.Refer != nil && len(constraint.Refer.IndexColNames) > RuleThreshold("KEY.006") {
 						return HeuristicRules["KEY.006"]
 					}
 				}
@@ -2818,12 +3333,12 @@ func (q *Query4Audit) RuleTooManyKeyParts() Rule {
 					switch spec.Tp {
 					case tidb.AlterTableAddConstraint:
 						if spec.Constraint != nil {
-							if len(spec.Constraint.Keys) > common.Config.MaxIdxColsCount {
+							if len(spec.Constraint.Keys) > RuleThreshold("KEY.006") {
 								return HeuristicRules["KEY.006"]
 							}
 
 							if spec.Constraint.Refer != nil {
-								if len(spec.Constraint.Refer.IndexColNames) > common.Config.MaxIdxColsCount {
+								if len(spec.Constraint.Refer.IndexColNames) > RuleThreshold("KEY.006") {
 									return HeuristicRules["KEY.006"]
 								}
 							}
@@ -2886,6 +3401,10 @@ func (q *Query4Audit) RulePKNotInt() Rule {
 // RuleOrderByMultiDirection KEY.008
 func (q *Query4Audit) RuleOrderByMultiDirection() Rule {
 	var rule = q.RuleOK()
+	// MySQL 8.0, MariaDB 10.8 开始支持降序索引，可以创建与 ORDER BY 方向一致的索引
+	if common.TargetDB().Supports(80000, 100800) {
+		return rule
+	}
 	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
 		switch n := node.(type) {
 		case sqlparser.OrderBy:
@@ -2981,6 +3500,488 @@ func (q *Query4Audit) RuleFulltextIndex() Rule {
 	return rule
 }
 
+// RuleFKWithoutIndex KEY.011
+func (q *Query4Audit) RuleFKWithoutIndex() Rule {
+	var rule = q.RuleOK()
+	switch q.Stmt.(type) {
+	case *sqlparser.DDL:
+		for _, tiStmt := range q.TiStmt {
+			switch node := tiStmt.(type) {
+			case *tidb.CreateTableStmt:
+				// create table 时外键只能使用同一语句中定义的索引
+				var indexes [][]string
+				for _, col := range node.Cols {
+					for _, opt := range col.Options {
+						switch opt.Tp {
+						case tidb.ColumnOptionPrimaryKey, tidb.ColumnOptionUniqKey:
+							indexes = append(indexes, []string{col.Name.Name.L})
+						}
+					}
+				}
+				indexes = append(indexes, constraintIndexes(node.Constraints)...)
+				fixes := fkMissingIndex(node.Table, node.Constraints, indexes)
+				if len(fixes) > 0 {
+					rule = HeuristicRules["KEY.011"]
+					rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+				}
+			}
+		}
+	}
+	return rule
+}
+
+// RuleAlterFKWithoutIndex KEY.011
+// alter table 添加外键时需要结合线上表结构中已有的索引判断
+func (idxAdv *IndexAdvisor) RuleAlterFKWithoutIndex() Rule {
+	rule := HeuristicRules["OK"]
+	if common.Config.OnlineDSN.Disable {
+		return rule
+	}
+
+	for _, tiStmt := range idxAdv.TiStmt {
+		node, ok := tiStmt.(*tidb.AlterTableStmt)
+		if !ok {
+			continue
+		}
+
+		var constraints []*tidb.Constraint
+		for _, spec := range node.Specs {
+			if spec.Tp == tidb.AlterTableAddConstraint && spec.Constraint != nil {
+				constraints = append(constraints, spec.Constraint)
+			}
+		}
+		if len(fkMissingIndex(node.Table, constraints, constraintIndexes(constraints))) == 0 {
+			continue
+		}
+
+		// 线上表结构中已有的索引
+		conn := idxAdv.rEnv
+		if node.Table.Schema.O != "" {
+			conn.Database = node.Table.Schema.O
+		}
+		idxInfo, err := conn.ShowIndex(node.Table.Name.O)
+		if err != nil {
+			common.Log.Warn("RuleAlterFKWithoutIndex ShowIndex Error: %v", err)
+			continue
+		}
+		indexes := constraintIndexes(constraints)
+		var keyNames []string
+		keyCols := make(map[string][]string)
+		for _, row := range idxInfo.Rows {
+			if _, ok := keyCols[row.KeyName]; !ok {
+				keyNames = append(keyNames, row.KeyName)
+			}
+			keyCols[row.KeyName] = append(keyCols[row.KeyName], strings.ToLower(row.ColumnName))
+		}
+		for _, key := range keyNames {
+			indexes = append(indexes, keyCols[key])
+		}
+
+		fixes := fkMissingIndex(node.Table, constraints, indexes)
+		if len(fixes) > 0 {
+			rule = HeuristicRules["KEY.011"]
+			rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+		}
+	}
+	return rule
+}
+
+// constraintIndexes 获取约束中所有可供外键使用的索引列
+func constraintIndexes(constraints []*tidb.Constraint) [][]string {
+	var indexes [][]string
+	for _, constraint := range constraints {
+		switch constraint.Tp {
+		case tidb.ConstraintPrimaryKey, tidb.ConstraintKey, tidb.ConstraintIndex,
+			tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+			var cols []string
+			for _, key := range constraint.Keys {
+				cols = append(cols, key.Column.Name.L)
+			}
+			indexes = append(indexes, cols)
+		}
+	}
+	return indexes
+}
+
+// fkMissingIndex 外键列必须是某个索引的最左前缀，否则返回创建索引的语句
+func fkMissingIndex(table *tidb.TableName, constraints []*tidb.Constraint, indexes [][]string) []string {
+	var fixes []string
+	for _, constraint := range constraints {
+		if constraint.Tp != tidb.ConstraintForeignKey {
+			continue
+		}
+
+		var cols []string
+		for _, key := range constraint.Keys {
+			cols = append(cols, key.Column.Name.L)
+		}
+
+		covered := false
+		for _, idx := range indexes {
+			if len(idx) < len(cols) {
+				continue
+			}
+			covered = true
+			for i, col := range cols {
+				if idx[i] != col {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				break
+			}
+		}
+
+		if !covered && len(cols) > 0 {
+			tb := fmt.Sprintf("`%s`", table.Name.O)
+			if table.Schema.O != "" {
+				tb = fmt.Sprintf("`%s`.%s", table.Schema.O, tb)
+			}
+			fixes = append(fixes, fmt.Sprintf("CREATE INDEX `%s%s` ON %s (`%s`);",
+				common.Config.IdxPrefix, strings.Join(cols, "_"), tb, strings.Join(cols, "`, `")))
+		}
+	}
+	return fixes
+}
+
+// RuleUUIDPrimaryKey KEY.012
+func (q *Query4Audit) RuleUUIDPrimaryKey() Rule {
+	var rule = q.RuleOK()
+	switch n := q.Stmt.(type) {
+	case *sqlparser.DDL:
+		for _, tiStmt := range q.TiStmt {
+			node, ok := tiStmt.(*tidb.CreateTableStmt)
+			if !ok || len(randomKeyColumns(node)) == 0 {
+				continue
+			}
+			rule = HeuristicRules["KEY.012"]
+			if ddl, err := rewriteRandomKey(node); err == nil {
+				rule.Content += " " + ddl
+			} else {
+				common.Log.Warn("RuleUUIDPrimaryKey rewriteRandomKey Error: %v", err)
+			}
+		}
+	case *sqlparser.Insert:
+		// 写入的值为随机生成的 UUID 或散列值，且列名看起来是主键
+		keyLike := func(col string) bool {
+			col = strings.ToLower(col)
+			return col == "id" || strings.HasSuffix(col, "_id") || strings.Contains(col, "uuid") || strings.Contains(col, "guid")
+		}
+		rows, ok := n.Rows.(sqlparser.Values)
+		if !ok {
+			break
+		}
+		for _, row := range rows {
+			for i, expr := range row {
+				if len(n.Columns) > 0 && (i >= len(n.Columns) || !keyLike(n.Columns[i].String())) {
+					continue
+				}
+				if isRandomKeyExpr(expr) {
+					rule = HeuristicRules["KEY.012"]
+					return rule
+				}
+			}
+		}
+	}
+	return rule
+}
+
+// isRandomKeyExpr 判断表达式是否为 UUID(), MD5(UUID()) 等随机值，UUID_TO_BIN(UUID(), 1) 生成的值有序，不认为是随机值
+func isRandomKeyExpr(expr sqlparser.Expr) bool {
+	fun, ok := expr.(*sqlparser.FuncExpr)
+	if !ok {
+		return false
+	}
+	switch fun.Name.Lowered() {
+	case "uuid":
+		return true
+	case "uuid_to_bin":
+		if len(fun.Exprs) > 1 {
+			if swap, ok := fun.Exprs[1].(*sqlparser.AliasedExpr); ok {
+				switch v := swap.Expr.(type) {
+				case *sqlparser.SQLVal:
+					return string(v.Val) == "0"
+				case sqlparser.BoolVal:
+					return !bool(v)
+				}
+			}
+		}
+		return true
+	case "md5", "sha", "sha1", "sha2":
+		for _, arg := range fun.Exprs {
+			if a, ok := arg.(*sqlparser.AliasedExpr); ok {
+				if f, ok := a.Expr.(*sqlparser.FuncExpr); ok {
+					switch f.Name.Lowered() {
+					case "uuid", "rand", "now":
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// randomKeyColumns 获取建表语句中使用 UUID 或散列值作为主键的列
+// CHAR/VARCHAR(32/36) 或列名中包含 uuid, guid 的认为是 UUID，CHAR/VARCHAR(40/64) 认为是 SHA1/SHA256 散列值
+func randomKeyColumns(node *tidb.CreateTableStmt) []*tidb.ColumnDef {
+	pk := make(map[string]bool)
+	for _, col := range node.Cols {
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey {
+				pk[col.Name.Name.L] = true
+			}
+		}
+	}
+	for _, c := range node.Constraints {
+		if c.Tp == tidb.ConstraintPrimaryKey {
+			for _, key := range c.Keys {
+				if key.Column != nil {
+					pk[key.Column.Name.L] = true
+				}
+			}
+		}
+	}
+
+	var cols []*tidb.ColumnDef
+	for _, col := range node.Cols {
+		if !pk[col.Name.Name.L] {
+			continue
+		}
+		switch col.Tp.Tp {
+		case mysql.TypeString, mysql.TypeVarchar, mysql.TypeVarString:
+			if isUUIDColumn(col) || col.Tp.Flen == 40 || col.Tp.Flen == 64 {
+				cols = append(cols, col)
+			}
+		}
+	}
+	return cols
+}
+
+// isUUIDColumn 判断字符串类型的主键列是否用来存储 UUID
+func isUUIDColumn(col *tidb.ColumnDef) bool {
+	name := col.Name.Name.L
+	return col.Tp.Flen == 32 || col.Tp.Flen == 36 || strings.Contains(name, "uuid") || strings.Contains(name, "guid")
+}
+
+// rewriteRandomKey 改写建表语句：UUID 主键改为 BINARY(16)，散列值主键改为唯一索引并添加自增主键
+func rewriteRandomKey(node *tidb.CreateTableStmt) (string, error) {
+	stmt := *node
+	stmt.Cols = nil
+	stmt.Constraints = nil
+
+	var surrogate []string
+	names := make(map[string]bool)
+	for _, col := range node.Cols {
+		names[col.Name.Name.L] = true
+	}
+	random := make(map[string]bool)
+	for _, col := range randomKeyColumns(node) {
+		random[col.Name.Name.L] = true
+	}
+
+	for _, col := range node.Cols {
+		if !random[col.Name.Name.L] {
+			stmt.Cols = append(stmt.Cols, col)
+			continue
+		}
+		c := *col
+		if isUUIDColumn(col) {
+			// 写入时使用 UUID_TO_BIN(UUID(), 1) 交换时间戳的高低位，使 UUID 有序
+			c.Tp = types.NewFieldType(mysql.TypeString)
+			c.Tp.Flen = 16
+			c.Tp.Charset = "binary"
+			c.Tp.Collate = "binary"
+			c.Tp.Flag |= mysql.BinaryFlag
+			c.Options = nil
+			for _, opt := range col.Options {
+				if opt.Tp != tidb.ColumnOptionDefaultValue {
+					c.Options = append(c.Options, opt)
+				}
+			}
+		} else {
+			// 散列值无法变为有序，保留为唯一索引
+			c.Options = nil
+			for _, opt := range col.Options {
+				if opt.Tp != tidb.ColumnOptionPrimaryKey {
+					c.Options = append(c.Options, opt)
+				}
+			}
+			surrogate = append(surrogate, col.Name.Name.O)
+		}
+		stmt.Cols = append(stmt.Cols, &c)
+	}
+
+	if len(surrogate) > 0 {
+		name := "id"
+		if names[name] {
+			name = "auto_id"
+		}
+		tp := types.NewFieldType(mysql.TypeLonglong)
+		tp.Flag |= mysql.UnsignedFlag
+		id := &tidb.ColumnDef{
+			Name: &tidb.ColumnName{Name: model.NewCIStr(name)},
+			Tp:   tp,
+			Options: []*tidb.ColumnOption{
+				{Tp: tidb.ColumnOptionNotNull},
+				{Tp: tidb.ColumnOptionAutoIncrement},
+			},
+		}
+		stmt.Cols = append([]*tidb.ColumnDef{id}, stmt.Cols...)
+		stmt.Constraints = append(stmt.Constraints, &tidb.Constraint{
+			Tp:   tidb.ConstraintPrimaryKey,
+			Keys: []*tidb.IndexColName{{Column: id.Name}},
+		})
+	}
+
+	for _, constraint := range node.Constraints {
+		if constraint.Tp == tidb.ConstraintPrimaryKey && len(surrogate) > 0 {
+			c := *constraint
+			c.Tp = tidb.ConstraintUniqKey
+			c.Name = common.Config.UkPrefix + strings.Join(surrogate, "_")
+			stmt.Constraints = append(stmt.Constraints, &c)
+			continue
+		}
+		stmt.Constraints = append(stmt.Constraints, constraint)
+	}
+	// 列级主键改写为唯一索引
+	for _, col := range node.Cols {
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey && random[col.Name.Name.L] && !isUUIDColumn(col) {
+				stmt.Constraints = append(stmt.Constraints, &tidb.Constraint{
+					Tp:   tidb.ConstraintUniqKey,
+					Name: common.Config.UkPrefix + col.Name.Name.O,
+					Keys: []*tidb.IndexColName{{Column: col.Name}},
+				})
+			}
+		}
+	}
+
+	var sb strings.Builder
+	ctx := format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)
+	if err := stmt.Restore(ctx); err != nil {
+		return "", err
+	}
+	return sb.String() + ";", nil
+}
+
+// RuleAutoIncrementExhausted COL.020
+// 检查 SQL 中用到的表（包括 INSERT 写入的表）在线上的自增值是否接近列类型的最大值
+func (idxAdv *IndexAdvisor) RuleAutoIncrementExhausted() Rule {
+	rule := HeuristicRules["OK"]
+	if common.Config.OnlineDSN.Disable || idxAdv.Ast == nil {
+		return rule
+	}
+
+	meta := queryMeta(idxAdv.Ast)
+	var dbs []string
+	for db := range meta {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+	var fixes []string
+	for _, db := range dbs {
+		conn := idxAdv.rEnv
+		if db != "" {
+			conn.Database = db
+		}
+		var tables []string
+		for tb := range meta[db].Table {
+			if tb != "" {
+				tables = append(tables, tb)
+			}
+		}
+		sort.Strings(tables)
+		for _, tb := range tables {
+			incs, err := conn.ShowAutoIncrement(tb)
+			if err != nil {
+				common.Log.Warn("RuleAutoIncrementExhausted ShowAutoIncrement Error: %v", err)
+				continue
+			}
+			for _, inc := range incs {
+				if fix := autoIncrementFix(conn.Database, inc); fix != "" {
+					fixes = append(fixes, fix)
+				}
+			}
+		}
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["COL.020"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// autoIncrementMax 获取整数类型的最大值，第二个返回值表示是否为整数类型
+func autoIncrementMax(columnType string) (uint64, bool) {
+	unsigned := strings.Contains(strings.ToLower(columnType), "unsigned")
+	var max uint64
+	switch strings.ToLower(common.GetDataTypeBase(strings.Fields(columnType + " ")[0])) {
+	case "tinyint":
+		max = 1<<7 - 1
+	case "smallint":
+		max = 1<<15 - 1
+	case "mediumint":
+		max = 1<<23 - 1
+	case "int", "integer":
+		max = 1<<31 - 1
+	case "bigint":
+		max = 1<<63 - 1
+	default:
+		return 0, false
+	}
+	if unsigned {
+		max = max*2 + 1
+	}
+	return max, true
+}
+
+// autoIncrementFix 自增值超过 -max-auto-inc-ratio 时给出扩大列类型的 ALTER 语句，未超过时返回空字符串
+func autoIncrementFix(db string, inc database.AutoIncrementInfo) string {
+	max, ok := autoIncrementMax(inc.ColumnType)
+	if !ok || inc.AutoIncrement == 0 {
+		return ""
+	}
+	ratio := float64(inc.AutoIncrement-1) / float64(max)
+	if ratio < common.Config.MaxAutoIncRatio {
+		return ""
+	}
+
+	tb := fmt.Sprintf("`%s`", inc.Table)
+	if db != "" {
+		tb = fmt.Sprintf("`%s`.%s", db, tb)
+	}
+	msg := fmt.Sprintf("%s.%s 当前 AUTO_INCREMENT 为 %d，已使用 %s 最大值 %d 的 %.2f%%", tb, inc.Column, inc.AutoIncrement, inc.ColumnType, max, ratio*100)
+
+	def := autoIncrementAlter(tb, inc)
+	if def == "" {
+		return msg + "，已无法扩大列类型，请尽快归档数据或重新规划主键。"
+	}
+	return msg + "，修改列类型需要重建表，大表请使用 pt-online-schema-change 或 gh-ost 执行，引用该列的外键列需要同时修改：" + def
+}
+
+// autoIncrementAlter 扩大自增列类型的 ALTER 语句，tb 为已转义的表名，BIGINT UNSIGNED 已无法扩大时返回空字符串
+func autoIncrementAlter(tb string, inc database.AutoIncrementInfo) string {
+	// BIGINT 扩大为 BIGINT UNSIGNED，其他整数类型扩大为 BIGINT 并保留 UNSIGNED 属性
+	unsigned := strings.Contains(strings.ToLower(inc.ColumnType), "unsigned")
+	base := strings.ToLower(common.GetDataTypeBase(strings.Fields(inc.ColumnType)[0]))
+	var newType string
+	switch {
+	case base == "bigint" && unsigned:
+		return ""
+	case base == "bigint", unsigned:
+		newType = "BIGINT UNSIGNED"
+	default:
+		newType = "BIGINT"
+	}
+	def := fmt.Sprintf("ALTER TABLE %s MODIFY `%s` %s", tb, inc.Column, newType)
+	if inc.Nullable == "NO" {
+		def += " NOT NULL"
+	}
+	return def + " AUTO_INCREMENT;"
+}
+
 // RuleTimestampDefault COL.013
 func (q *Query4Audit) RuleTimestampDefault() Rule {
 	var rule = q.RuleOK()
@@ -3424,7 +4425,7 @@ func (q *Query4Audit) RuleVarcharLength() Rule {
 					}
 					switch col.Tp.Tp {
 					case mysql.TypeVarchar, mysql.TypeVarString:
-						if col.Tp.Flen > common.Config.MaxVarcharLength {
+						if col.Tp.Flen > RuleThreshold("COL.017") {
 							rule = HeuristicRules["COL.017"]
 							break
 						}
@@ -3441,7 +4442,7 @@ func (q *Query4Audit) RuleVarcharLength() Rule {
 							}
 							switch col.Tp.Tp {
 							case mysql.TypeVarchar, mysql.TypeVarString:
-								if col.Tp.Flen > common.Config.MaxVarcharLength {
+								if col.Tp.Flen > RuleThreshold("COL.017") {
 									rule = HeuristicRules["COL.017"]
 									break
 								}
@@ -3535,6 +4536,10 @@ func (q *Query4Audit) RuleTimePrecision() Rule {
 // RuleNoOSCKey KEY.002
 func (q *Query4Audit) RuleNoOSCKey() Rule {
 	var rule = q.RuleOK()
+	// LIKE 建表时主键来自源表
+	if q.createTableLike() != nil {
+		return rule
+	}
 	switch s := q.Stmt.(type) {
 	case *sqlparser.DDL:
 		if s.Action == "create" {
@@ -3558,7 +4563,7 @@ func (q *Query4Audit) RuleTooManyFields() Rule {
 		for _, tiStmt := range q.TiStmt {
 			switch node := tiStmt.(type) {
 			case *tidb.CreateTableStmt:
-				if len(node.Cols) > common.Config.MaxColCount {
+				if len(node.Cols) > RuleThreshold("COL.006") {
 					rule = HeuristicRules["COL.006"]
 				}
 			}
@@ -3588,7 +4593,7 @@ func (q *Query4Audit) RuleMaxTextColsCount() Rule {
 			}
 		}
 	}
-	if textColsCount > common.Config.MaxTextColsCount {
+	if textColsCount > RuleThreshold("COL.007") {
 		rule = HeuristicRules["COL.007"]
 	}
 
@@ -3645,6 +4650,11 @@ func (q *Query4Audit) RuleAllowEngine() Rule {
 		for _, tiStmt := range q.TiStmt {
 			switch node := tiStmt.(type) {
 			case *tidb.CreateTableStmt:
+				// LIKE 建表时存储引擎来自源表
+				if node.ReferTable != nil {
+					hasDefaultEngine = true
+					break
+				}
 				for _, opt := range node.Options {
 					if opt.Tp == tidb.TableOptionEngine {
 						hasDefaultEngine = true
@@ -3866,38 +4876,89 @@ func (q *Query4Audit) RuleStandardName() Rule {
 	return rule
 }
 
-// MergeConflictHeuristicRules merge conflict rules
-func MergeConflictHeuristicRules(rules map[string]Rule) map[string]Rule {
-	// KWR.001 VS ERR.000
-	// select sql_calc_found_rows * from film
-	if _, ok := rules["KWR.001"]; ok {
-		delete(rules, "ERR.000")
-	}
-
-	// SUB.001 VS OWN.004 VS JOI.006
-	if _, ok := rules["SUB.001"]; ok {
-		delete(rules, "ARG.005")
-		delete(rules, "JOI.006")
-	}
+// RulePrecedence 建议间的优先级，Winner 出现时不再给出 Losers 中的建议
+// Item 支持以 * 结尾的前缀匹配，如 IDX.*
+type RulePrecedence struct {
+	Winner string
+	Losers []string
+}
 
-	// SUB.004 VS SUB.001
-	if _, ok := rules["SUB.004"]; ok {
-		delete(rules, "SUB.001")
+// DefaultRulePrecedence 内置的冲突建议合并规则，按顺序执行
+var DefaultRulePrecedence = []RulePrecedence{
+	// select sql_calc_found_rows * from film
+	{Winner: "KWR.001", Losers: []string{"ERR.000"}},
+	{Winner: "SUB.001", Losers: []string{"ARG.005", "JOI.006"}},
+	{Winner: "SUB.004", Losers: []string{"SUB.001"}},
+	{Winner: "KEY.012", Losers: []string{"KEY.007"}},
+	{Winner: "KEY.007", Losers: []string{"KEY.002"}},
+	{Winner: "JOI.002", Losers: []string{"JOI.006"}},
+	{Winner: "JOI.008", Losers: []string{"JOI.007"}},
+	// 集群中没有主键的表无法写入，不再给出 Online DDL 相关的提示
+	{Winner: "CLU.001", Losers: []string{"KEY.002"}},
+}
+
+// ParseRulePrecedence 解析 -rule-precedence 配置，格式为 IDX.001>ARG.003，多个被覆盖的建议使用 | 分隔，如 SUB.001>ARG.005|JOI.006
+func ParseRulePrecedence(conf []string) ([]RulePrecedence, error) {
+	var precedence []RulePrecedence
+	for _, c := range conf {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		kv := strings.Split(c, ">")
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("rule-precedence format error: '%s', e.g. IDX.001>ARG.003", c)
+		}
+		p := RulePrecedence{Winner: strings.TrimSpace(kv[0])}
+		for _, loser := range strings.Split(kv[1], "|") {
+			if loser = strings.TrimSpace(loser); loser != "" {
+				p.Losers = append(p.Losers, loser)
+			}
+		}
+		precedence = append(precedence, p)
 	}
+	return precedence, nil
+}
 
-	// KEY.007 VS KEY.002
-	if _, ok := rules["KEY.007"]; ok {
-		delete(rules, "KEY.002")
+// matchRuleItem 判断建议是否匹配，pattern 以 * 结尾时按前缀匹配
+func matchRuleItem(pattern, item string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(item, strings.TrimSuffix(pattern, "*"))
 	}
+	return pattern == item
+}
 
-	// JOI.002 VS JOI.006
-	if _, ok := rules["JOI.002"]; ok {
-		delete(rules, "JOI.006")
+// MergeConflictHeuristicRules merge conflict rules
+// 先执行配置文件中 rule-precedence 指定的规则，再执行内置的 DefaultRulePrecedence
+func MergeConflictHeuristicRules(rules map[string]Rule) map[string]Rule {
+	precedence, err := ParseRulePrecedence(common.Config.RulePrecedence)
+	if err != nil {
+		common.Log.Warning("MergeConflictHeuristicRules: %v", err)
 	}
+	precedence = append(precedence, DefaultRulePrecedence...)
 
-	// JOI.008 VS JOI.007
-	if _, ok := rules["JOI.008"]; ok {
-		delete(rules, "JOI.007")
+	for _, p := range precedence {
+		fired := false
+		for item := range rules {
+			if matchRuleItem(p.Winner, item) {
+				fired = true
+				break
+			}
+		}
+		if !fired {
+			continue
+		}
+		for item := range rules {
+			if matchRuleItem(p.Winner, item) {
+				continue
+			}
+			for _, loser := range p.Losers {
+				if matchRuleItem(loser, item) {
+					delete(rules, item)
+					break
+				}
+			}
+		}
 	}
 	return rules
 }
@@ -3967,3 +5028,16 @@ func RuleMySQLError(item string, err error) Rule {
 		}
 	}
 }
+
+// RuleSyntaxError ERR.000，将 TiDB 语法解析报错中的行号转换为 SQL 在输入文件中的行号
+// startLine 为 SQL 在输入文件中的起始行号
+func RuleSyntaxError(err error, startLine int) Rule {
+	rule := RuleMySQLError("ERR.000", err)
+	pos := regexp.MustCompile(`^line ([0-9]+) column ([0-9]+)`).FindStringSubmatch(err.Error())
+	if len(pos) == 3 && startLine > 0 {
+		line, _ := strconv.Atoi(pos[1])
+		rule.Content = fmt.Sprintf("line %d column %s%s", startLine+line-1, pos[2], strings.TrimPrefix(err.Error(), pos[0]))
+		rule.Summary = strings.Replace(rule.Summary, err.Error(), rule.Content, 1)
+	}
+	return rule
+}
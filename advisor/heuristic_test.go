@@ -19,9 +19,11 @@ package advisor
 import (
 	"errors"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
 
 	"github.com/XiaoMi/soar/env"
 	"github.com/kr/pretty"
@@ -707,6 +709,45 @@ func TestRuleNoDeterministicGroupby(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+// RES.001
+func TestRuleNoDeterministicGroupbySQLMode(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSQLMode := common.Config.SQLMode
+	defer func() { common.Config.SQLMode = orgSQLMode }()
+
+	sqls := map[string]string{
+		"select c1, c2, c3 from t1 where c2 = 'foo' group by c2":                 "c1, c3 既不在 GROUP BY 中",
+		"select a, count(*) from t":                                              "a 既不在 GROUP BY 中",
+		"select t.a, b from t group by a having count(*) > 1 order by c":         "b, c 既不在 GROUP BY 中",
+		"select a x, count(*) from t group by x order by 2":                      "",
+		"select a, any_value(b) from t group by 1":                               "",
+		"select a + 1, sum(b) from t group by a + 1":                             "",
+		"select a, (select max(c) from t2 where t2.id = t.id) from t group by a": "",
+	}
+	for _, mode := range []string{"ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES", ""} {
+		common.Config.SQLMode = mode
+		for sql, want := range sqls {
+			q, err := NewQuery4Audit(sql)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			rule := q.RuleNoDeterministicGroupby()
+			if want == "" {
+				if rule.Item != "OK" {
+					t.Errorf("SQL: %s, want OK, got: %s", sql, rule.Content)
+				}
+				continue
+			}
+			if rule.Item != "RES.001" || !strings.Contains(rule.Content, want) ||
+				strings.Contains(rule.Content, "ONLY_FULL_GROUP_BY") != (mode != "") {
+				t.Errorf("SQL: %s, sql_mode: %s, got: %s", sql, mode, rule.Content)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 // RES.002
 func TestRuleNoDeterministicLimit(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
@@ -849,10 +890,13 @@ func TestRuleImpossibleWhere(t *testing.T) {
 			"select * from tbl where 1 != 1;",
 			"select * from tbl where 'a' != 'a';",
 			"select * from tbl where col between 10 AND 5;",
+			"select * from tbl where col > 10 and col < 5;",
+			"select * from tbl where col = 1 and col in (2, 3);",
 		},
 		{
 			"select * from tbl where 1 = 1;",
 			"select * from tbl where 'a' != 1;",
+			"select * from tbl where col > 5 and col < 10;",
 		},
 	}
 	for _, sql := range sqls[0] {
@@ -893,10 +937,12 @@ func TestRuleMeaninglessWhere(t *testing.T) {
 			"select * from tbl where 'a' limit 1;",
 			"select * from tbl where 1;",
 			"select * from tbl where 1 limit 1;",
+			"select * from tbl where col > 5 or col <= 5;",
 		},
 		{
 			"select * from tbl where 2 = 1;",
 			"select * from tbl where 'b' = 'a';",
+			"select * from tbl where col < 5 or col > 5;",
 		},
 	}
 	for _, sql := range sqls[0] {
@@ -1956,6 +2002,34 @@ func TestRuleMultiDBJoin(t *testing.T) {
 			t.Error("sqlparser.Parse Error:", err)
 		}
 	}
+
+	// 同组的库允许 JOIN，跨组的 JOIN 给出建议
+	orgGroups := common.Config.AllowedSchemaGroups
+	common.Config.AllowedSchemaGroups = []string{"db1|DB2", "crm|crm_log"}
+	cases := []struct {
+		sql  string
+		item string
+	}{
+		{`SELECT s,p,d FROM db1.tb1 join db2.tb2 on db1.tb1.a = db2.tb2.a where db1.tb1.a > 10;`, "OK"},
+		{`SELECT s,p,d FROM db1.tb1 join tb2 on db1.tb1.a = tb2.a where db1.tb1.a > 10;`, "OK"},
+		{`SELECT s,p,d FROM db1.tb1 join crm.tb2 on db1.tb1.a = crm.tb2.a where db1.tb1.a > 10;`, "JOI.008"},
+		{`SELECT s,p,d FROM crm_log.tb1 join db3.tb2 on crm_log.tb1.a = db3.tb2.a where crm_log.tb1.a > 10;`, "JOI.008"},
+	}
+	for _, c := range cases {
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Error("sqlparser.Parse Error:", err)
+			continue
+		}
+		rule := q.RuleMultiDBJoin()
+		if rule.Item != c.item {
+			t.Errorf("%s want %s, got %s", c.sql, c.item, rule.Item)
+		}
+		if rule.Item == "JOI.008" && !strings.Contains(rule.Content, "crm|crm_log") {
+			t.Errorf("want schema groups in content, got: %s", rule.Content)
+		}
+	}
+	common.Config.AllowedSchemaGroups = orgGroups
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
@@ -2156,6 +2230,54 @@ func TestRuleInsertValues(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+// ARG.012
+func TestRuleInsertValuesSplit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	oldMaxValueCount, oldInsertBatchSize := common.Config.MaxValueCount, common.Config.InsertBatchSize
+	defer func() {
+		common.Config.MaxValueCount, common.Config.InsertBatchSize = oldMaxValueCount, oldInsertBatchSize
+	}()
+	common.Config.MaxValueCount = 3
+
+	cases := []struct {
+		batch int
+		sql   string
+		want  string
+	}{
+		{
+			0,
+			"INSERT INTO tb (a, b) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd')",
+			"insert into tb(a, b) values (1, 'a'), (2, 'b'), (3, 'c');\ninsert into tb(a, b) values (4, 'd');",
+		},
+		{
+			2,
+			"INSERT IGNORE INTO db.tb (a, b) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd') ON DUPLICATE KEY UPDATE b = VALUES(b)",
+			"insert ignore into db.tb(a, b) values (1, 'a'), (2, 'b') on duplicate key update b = values(b);\n" +
+				"insert ignore into db.tb(a, b) values (3, 'c'), (4, 'd') on duplicate key update b = values(b);",
+		},
+		{
+			3,
+			"REPLACE INTO tb VALUES (1), (2), (3), (4), (5), (6), (7)",
+			"replace into tb values (1), (2), (3);\nreplace into tb values (4), (5), (6);\nreplace into tb values (7);",
+		},
+	}
+	for _, c := range cases {
+		common.Config.InsertBatchSize = c.batch
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := q.RuleInsertValues()
+		if rule.Item != "ARG.012" || !strings.HasSuffix(rule.Content, "拆分后的语句：\n"+c.want) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s", c.sql, c.want, rule.Content)
+		}
+		if rule.Case != HeuristicRules["ARG.012"].Case {
+			t.Errorf("ARG.012 Case should keep the rule example, got: %s", rule.Case)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 // ARG.013
 func TestRuleFullWidthQuote(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
@@ -2489,6 +2611,86 @@ func TestRuleCountStar(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+// RES.012
+func TestRuleAliasShadowColumn(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := [][]string{
+		{
+			"SELECT DATE(created) AS created, COUNT(*) FROM tbl GROUP BY created ORDER BY created",
+			"SELECT a AS b FROM tbl WHERE b > 1 ORDER BY b",
+			"SELECT id, SUM(price) AS price FROM tbl GROUP BY id ORDER BY price",
+		},
+		{
+			"SELECT DATE(created) AS day, COUNT(*) FROM tbl GROUP BY day ORDER BY day",
+			"SELECT a AS a FROM tbl ORDER BY a",
+			"SELECT DATE(created) AS created FROM tbl ORDER BY tbl.created",
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleAliasShadowColumn()
+			if rule.Item != "RES.012" || rule.Position == 0 {
+				t.Error("Rule not match:", rule.Item, "Expect : RES.012", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleAliasShadowColumn()
+			if rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// RES.013
+func TestRuleGroupByPositionRange(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := [][]string{
+		{
+			"SELECT col1, COUNT(*) FROM tbl GROUP BY 3",
+			"SELECT col1 FROM tbl ORDER BY 0",
+			"SELECT col1 FROM tbl WHERE col2 IN (SELECT a FROM t2 GROUP BY 1, 2)",
+		},
+		{
+			"SELECT col1, COUNT(*) FROM tbl GROUP BY 1 ORDER BY 2",
+			"SELECT * FROM tbl ORDER BY 5",
+			"SELECT col1 FROM tbl ORDER BY col1",
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleGroupByPositionRange()
+			if rule.Item != "RES.013" || rule.Position == 0 {
+				t.Error("Rule not match:", rule.Item, "Expect : RES.013", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleGroupByPositionRange()
+			if rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 // SEC.001
 func TestRuleTruncateTable(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
@@ -2961,6 +3163,150 @@ func TestRuleFulltextIndex(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+// KEY.011
+func TestRuleFKWithoutIndex(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := [][]string{
+		{
+			`CREATE TABLE tbl (id int unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY, uid int unsigned NOT NULL, FOREIGN KEY (uid) REFERENCES users(id));`,
+			`CREATE TABLE tbl (a int, b int, KEY idx_b_a (b, a), FOREIGN KEY (a, b) REFERENCES t2(a, b));`,
+			`CREATE TABLE tbl (a int, b int, KEY idx_a (a), FOREIGN KEY (a, b) REFERENCES t2(a, b));`,
+		},
+		{
+			`CREATE TABLE tbl (id int unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY, uid int unsigned NOT NULL, KEY idx_uid (uid), FOREIGN KEY (uid) REFERENCES users(id));`,
+			`CREATE TABLE tbl (a int, b int, c int, UNIQUE KEY uk_a_b_c (a, b, c), FOREIGN KEY (a, b) REFERENCES t2(a, b));`,
+			`CREATE TABLE tbl (uid int unsigned NOT NULL PRIMARY KEY, FOREIGN KEY (uid) REFERENCES users(id));`,
+			`CREATE TABLE tbl (id int unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY);`,
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleFKWithoutIndex()
+			if rule.Item != "KEY.011" {
+				t.Error("Rule not match:", rule.Item, "Expect : KEY.011")
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleFKWithoutIndex()
+			if rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK")
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+
+	// 建议中给出创建索引的语句
+	q, err := NewQuery4Audit("CREATE TABLE db.tbl (a int, b int, FOREIGN KEY (a, b) REFERENCES t2(a, b));")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleFKWithoutIndex(); !strings.HasSuffix(rule.Content, "CREATE INDEX `idx_a_b` ON `db`.`tbl` (`a`, `b`);") {
+		t.Error("KEY.011 fix not match:", rule.Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// KEY.012
+func TestRuleUUIDPrimaryKey(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := [][]string{
+		{
+			`CREATE TABLE tbl (id char(36) NOT NULL, name varchar(64), PRIMARY KEY (id));`,
+			`CREATE TABLE tbl (user_uuid varchar(64) NOT NULL PRIMARY KEY, name varchar(64));`,
+			`CREATE TABLE tbl (token char(40) NOT NULL, PRIMARY KEY (token));`,
+			`INSERT INTO tbl (id, name) VALUES (UUID(), 'a');`,
+			`INSERT INTO tbl (order_id, name) VALUES (MD5(UUID()), 'a');`,
+			`INSERT INTO tbl (id, name) VALUES (UUID_TO_BIN(UUID()), 'a');`,
+		},
+		{
+			`CREATE TABLE tbl (id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY, uuid char(36) NOT NULL);`,
+			`CREATE TABLE tbl (code varchar(20) NOT NULL PRIMARY KEY);`,
+			`INSERT INTO tbl (id, name) VALUES (UUID_TO_BIN(UUID(), 1), 'a');`,
+			`INSERT INTO tbl (id, name) VALUES (1, MD5(UUID()));`,
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleUUIDPrimaryKey()
+			if rule.Item != "KEY.012" {
+				t.Error("Rule not match:", rule.Item, "Expect : KEY.012", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleUUIDPrimaryKey()
+			if rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+
+	q, err := NewQuery4Audit(sqls[0][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleUUIDPrimaryKey(); !strings.Contains(rule.Content, "BINARY(16)") {
+		t.Error("rewrite not match:", rule.Content)
+	}
+	q, err = NewQuery4Audit(sqls[0][2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleUUIDPrimaryKey(); !strings.Contains(rule.Content, "AUTO_INCREMENT") || !strings.Contains(rule.Content, "UNIQUE") {
+		t.Error("rewrite not match:", rule.Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// COL.020
+func TestAutoIncrementFix(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		inc  database.AutoIncrementInfo
+		want string
+	}{
+		{
+			database.AutoIncrementInfo{Table: "t1", Column: "id", ColumnType: "int(11)", Nullable: "NO", AutoIncrement: 2000000000},
+			"ALTER TABLE `sakila`.`t1` MODIFY `id` BIGINT NOT NULL AUTO_INCREMENT;",
+		},
+		{
+			database.AutoIncrementInfo{Table: "t1", Column: "id", ColumnType: "smallint(5) unsigned", Nullable: "NO", AutoIncrement: 60000},
+			"ALTER TABLE `sakila`.`t1` MODIFY `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT;",
+		},
+		{
+			database.AutoIncrementInfo{Table: "t1", Column: "id", ColumnType: "bigint(20) unsigned", Nullable: "NO", AutoIncrement: 18000000000000000000},
+			"已无法扩大列类型",
+		},
+		{
+			database.AutoIncrementInfo{Table: "t1", Column: "id", ColumnType: "int(10) unsigned", Nullable: "NO", AutoIncrement: 2000000000},
+			"",
+		},
+	}
+	for _, c := range cases {
+		got := autoIncrementFix("sakila", c.inc)
+		if (c.want == "" && got != "") || !strings.Contains(got, c.want) {
+			t.Errorf("want: %s, got: %s", c.want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 // COL.013
 func TestRuleTimestampDefault(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
@@ -3392,6 +3738,7 @@ func TestRuleNoOSCKey(t *testing.T) {
 		{
 			"CREATE TABLE tbl (a int, primary key(`a`))",
 			"CREATE TABLE tbl (a int, unique key(`a`))",
+			"CREATE TABLE tbl LIKE tbl_src",
 		},
 	}
 	for _, sql := range sqls[0] {
@@ -3752,6 +4099,22 @@ func TestRuleMySQLError(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestRuleSyntaxError(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	_, err := NewQuery4Audit("select *\nfrm t")
+	if err == nil {
+		t.Fatal("want syntax error")
+	}
+	rule := RuleSyntaxError(err, 10)
+	if rule.Item != "ERR.000" || !strings.HasPrefix(rule.Content, "line 11 column ") {
+		t.Error("syntax error position not match:", rule.Content)
+	}
+	if !strings.Contains(rule.Summary, rule.Content) {
+		t.Error("syntax error summary not match:", rule.Summary)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestMergeConflictHeuristicRules(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	tmpRules := make(map[string]Rule)
@@ -3774,3 +4137,38 @@ func TestMergeConflictHeuristicRules(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestRulePrecedence(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgRulePrecedence := common.Config.RulePrecedence
+	common.Config.RulePrecedence = []string{"IDX.001>ARG.003", "CLA.*>COL.001|COL.002", ""}
+	rules := map[string]Rule{
+		"IDX.001": HeuristicRules["OK"],
+		"ARG.003": HeuristicRules["OK"],
+		"CLA.001": HeuristicRules["OK"],
+		"COL.001": HeuristicRules["OK"],
+		"COL.002": HeuristicRules["OK"],
+		"COL.003": HeuristicRules["OK"],
+		"KEY.007": HeuristicRules["OK"],
+		"KEY.002": HeuristicRules["OK"],
+	}
+	suggest := MergeConflictHeuristicRules(rules)
+	for _, item := range []string{"ARG.003", "COL.001", "COL.002", "KEY.002"} {
+		if _, ok := suggest[item]; ok {
+			t.Errorf("%s should be merged", item)
+		}
+	}
+	for _, item := range []string{"IDX.001", "CLA.001", "COL.003", "KEY.007"} {
+		if _, ok := suggest[item]; !ok {
+			t.Errorf("%s should be kept", item)
+		}
+	}
+
+	for _, conf := range []string{"IDX.001", "IDX.001>", ">ARG.003", "A>B>C"} {
+		if _, err := ParseRulePrecedence([]string{conf}); err == nil {
+			t.Errorf("'%s' should be invalid", conf)
+		}
+	}
+	common.Config.RulePrecedence = orgRulePrecedence
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// QueryStats SQL 的执行统计，用于按影响对报告排序
+type QueryStats struct {
+	Count   int64   `json:"Count"`   // 执行次数
+	Latency float64 `json:"Latency"` // 总耗时，单位秒
+}
+
+// queryIDRe query.Id 生成的 Query ID
+var queryIDRe = regexp.MustCompile(`^[0-9A-F]{16}$`)
+
+// slowLogHeaderRe 慢查询日志文件头，MySQL 启动时写入
+var slowLogHeaderRe = regexp.MustCompile(`^(\S+, Version: |Tcp port: |Time\s+Id\s+Command)`)
+
+// slowLogQueryTimeRe 慢查询日志中的 Query_time
+var slowLogQueryTimeRe = regexp.MustCompile(`Query_time:\s*([0-9.]+)`)
+
+// LoadQueryStats 读取 SQL 执行统计，返回值的 key 为 fingerprint.ID
+// .csv 后缀的文件每行的格式为 SQL 或 Query ID,执行次数[,总耗时]，其他文件按 MySQL 慢查询日志解析
+func LoadQueryStats(file string, data []byte) (map[string]QueryStats, error) {
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return csvQueryStats(data)
+	}
+	return slowLogQueryStats(data), nil
+}
+
+// csvQueryStats 解析 CSV 格式的执行统计，执行次数无法解析的行（如表头）会被忽略
+func csvQueryStats(data []byte) (map[string]QueryStats, error) {
+	stats := make(map[string]QueryStats)
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			continue
+		}
+		count, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		var latency float64
+		if len(record) > 2 {
+			latency, err = strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid latency %q for %q", record[2], record[0])
+			}
+		}
+		id := strings.TrimSpace(record[0])
+		if !queryIDRe.MatchString(id) {
+			id = query.Id(Fingerprint(id))
+		}
+		s := stats[id]
+		s.Count += count
+		s.Latency += latency
+		stats[id] = s
+	}
+	return stats, nil
+}
+
+// slowLogQueryStats 解析 MySQL 慢查询日志，按指纹统计执行次数及 Query_time 之和
+func slowLogQueryStats(data []byte) map[string]QueryStats {
+	stats := make(map[string]QueryStats)
+	var buf []string
+	var latency float64
+	flush := func() {
+		sql := slowLogStatement(strings.Join(buf, "\n"))
+		buf = nil
+		if sql == "" {
+			return
+		}
+		id := query.Id(Fingerprint(sql))
+		s := stats[id]
+		s.Count++
+		s.Latency += latency
+		stats[id] = s
+		latency = 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+			if len(buf) > 0 {
+				flush()
+			}
+			if m := slowLogQueryTimeRe.FindStringSubmatch(line); m != nil {
+				latency, _ = strconv.ParseFloat(m[1], 64)
+			}
+		case slowLogHeaderRe.MatchString(line):
+		default:
+			buf = append(buf, line)
+		}
+	}
+	if len(buf) > 0 {
+		flush()
+	}
+	return stats
+}
+
+// slowLogStatement 返回慢查询日志一条记录中真正执行的 SQL，忽略 use db 及 SET timestamp
+func slowLogStatement(entry string) string {
+	var ret string
+	buf := []byte(entry)
+	for len(bytes.TrimSpace(buf)) > 0 {
+		var sql string
+		_, sql, buf = ast.SplitStatement(buf, []byte(";"))
+		sql = strings.TrimSpace(sql)
+		lower := strings.ToLower(sql)
+		if sql == "" || strings.HasPrefix(lower, "use ") || strings.HasPrefix(lower, "set timestamp") {
+			continue
+		}
+		ret = sql
+	}
+	return ret
+}
+
+// QueryImpact 计算 SQL 的影响，为执行次数与所有建议 Severity 之和的乘积
+func QueryImpact(count int64, suggest map[string]Rule) int64 {
+	var severity int64
+	for _, rule := range suggest {
+		severity += int64(severityLevel(rule.Severity))
+	}
+	return count * severity
+}
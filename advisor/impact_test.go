@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+)
+
+func TestLoadQueryStats(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	slowLog := `/usr/sbin/mysqld, Version: 5.7.26-log (MySQL Community Server (GPL)). started with:
+Tcp port: 3306  Unix socket: /tmp/mysql.sock
+Time                 Id Command    Argument
+# Time: 2019-01-01T00:00:00.000000Z
+# User@Host: root[root] @ localhost []  Id:     8
+# Query_time: 1.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000
+use sakila;
+SET timestamp=1546300800;
+select * from film where film_id = 1;
+# Time: 2019-01-01T00:00:01.000000Z
+# User@Host: root[root] @ localhost []  Id:     8
+# Query_time: 0.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000
+SET timestamp=1546300801;
+select *
+from film where film_id = 2;
+# Time: 2019-01-01T00:00:02.000000Z
+# User@Host: root[root] @ localhost []  Id:     8
+# Query_time: 0.100000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1
+SET timestamp=1546300802;
+select * from actor;
+`
+	stats, err := LoadQueryStats("slow.log", []byte(slowLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	film := query.Id(Fingerprint("select * from film where film_id = 3"))
+	actor := query.Id(Fingerprint("select * from actor"))
+	if len(stats) != 2 || stats[film].Count != 2 || stats[film].Latency != 2 || stats[actor].Count != 1 {
+		t.Errorf("slow log stats got %v", stats)
+	}
+
+	csv := "query,count,latency\nselect * from film where film_id = 1,100,2.5\n" + actor + ",10\nselect * from film where film_id = 2,50,0.5\n"
+	stats, err = LoadQueryStats("stats.csv", []byte(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[film].Count != 150 || stats[film].Latency != 3 || stats[actor].Count != 10 {
+		t.Errorf("csv stats got %v", stats)
+	}
+
+	if _, err = LoadQueryStats("stats.csv", []byte("select 1,1,abc\n")); err == nil {
+		t.Error("want error for invalid latency")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestQueryImpact(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	suggest := map[string]Rule{
+		"CLA.001": {Severity: "L4"},
+		"COL.001": {Severity: "L1"},
+	}
+	if impact := QueryImpact(10, suggest); impact != 50 {
+		t.Errorf("want 50, got %d", impact)
+	}
+	if impact := QueryImpact(10, map[string]Rule{"OK": {Severity: "L0"}}); impact != 0 {
+		t.Errorf("want 0, got %d", impact)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/XiaoMi/soar/env"
 
 	"github.com/dchest/uniuri"
+	tidb "github.com/pingcap/parser/ast"
 	"vitess.io/vitess/go/vt/sqlparser"
 )
 
@@ -40,6 +41,7 @@ type IndexAdvisor struct {
 	vEnv      *env.VirtualEnv     // 线下虚拟测试环境（测试环境）
 	rEnv      database.Connector  // 线上真实环境
 	Ast       sqlparser.Statement // Vitess Parser生成的抽象语法树
+	TiStmt    []tidb.StmtNode     // TiDB Parser生成的抽象语法树，仅 DDL 使用
 	where     []*common.Column    // 所有where条件中用到的列
 	whereEQ   []*common.Column    // where条件中可以加索引的等值条件列
 	whereINEQ []*common.Column    // where条件中可以加索引的非等值条件列
@@ -47,15 +49,40 @@ type IndexAdvisor struct {
 	orderBy   []*common.Column    // order by可以加索引列
 	joinCond  [][]*common.Column  // 由于join condition跨层级间索引不可共用，需要多一个维度用来维护层级关系
 	IndexMeta map[string]map[string]*database.TableIndexInfo
+	usage     map[string]string // db.table.column -> 该列在索引中的用途，如 WHERE 等值条件, ORDER BY
+	measured  map[string]string // db.table.column -> 散粒度的来源，未记录的列散粒度未知
+	remotes   *remoteTableCache // SQL 涉及的远端表，nil 表示尚未查询
 }
 
+// 索引中各列的用途，在索引建议中说明索引的哪部分用于过滤，哪部分用于避免排序
+const (
+	indexUsageEQ      = "WHERE 等值条件"
+	indexUsageRange   = "WHERE 范围条件"
+	indexUsageGroupBy = "GROUP BY"
+	indexUsageOrderBy = "ORDER BY"
+	indexUsageJoin    = "JOIN 关联条件"
+)
+
+// 散粒度的来源，在索引建议中说明各列顺序的依据
+const (
+	cardinalityFromKey      = "主键或唯一索引"
+	cardinalityFromSampling = "测试环境采样数据"
+	cardinalityFromStats    = "索引统计信息"
+	cardinalityFromOnline   = "线上索引统计信息"
+)
+
 // IndexInfo 创建一条索引需要的信息
 type IndexInfo struct {
-	Name          string           `json:"name"`           // 索引名称
-	Database      string           `json:"database"`       // 数据库名
-	Table         string           `json:"table"`          // 表名
-	DDL           string           `json:"ddl"`            // ALTER, CREATE 等类型的 DDL 语句
-	ColumnDetails []*common.Column `json:"column_details"` // 列详情
+	Name          string           `json:"name"`              // 索引名称
+	Database      string           `json:"database"`          // 数据库名
+	Table         string           `json:"table"`             // 表名
+	DDL           string           `json:"ddl"`               // ALTER, CREATE 等类型的 DDL 语句
+	ColumnDetails []*common.Column `json:"column_details"`    // 列详情
+	Usage         []string         `json:"usage,omitempty"`   // 与 ColumnDetails 一一对应，各列在索引中的用途
+	Reasons       []string         `json:"reasons,omitempty"` // 与 ColumnDetails 一一对应，各列在索引中位置的依据
+	Extends       string           `json:"extends,omitempty"` // 被扩展或调整列顺序的已有索引，新索引与删除该索引在同一条 ALTER 中执行
+	Reorder       bool             `json:"reorder,omitempty"` // 为 true 时已有索引与新索引的列相同，仅调整列的顺序
+	Benefit       *IndexBenefit    `json:"benefit,omitempty"` // 测试环境中添加索引前后 EXPLAIN 的变化，未测量时为 nil
 }
 
 // IndexAdvises IndexAdvises列表
@@ -91,6 +118,10 @@ func NewAdvisor(env *env.VirtualEnv, rEnv database.Connector, q Query4Audit) (*I
 	if common.Config.TestDSN.Disable {
 		return nil, fmt.Errorf("TestDSN is Disabled: %s", common.Config.TestDSN.Addr)
 	}
+	// 索引建议依赖 vitess 的 AST，vitess 解析失败或未注册 vitess 解析器时跳过
+	if q.Stmt == nil {
+		return nil, nil
+	}
 	// DDL 检测
 	switch stmt := q.Stmt.(type) {
 	case *sqlparser.DDL:
@@ -113,9 +144,10 @@ func NewAdvisor(env *env.VirtualEnv, rEnv database.Connector, q Query4Audit) (*I
 		}
 
 		return &IndexAdvisor{
-			vEnv: env,
-			rEnv: rEnv,
-			Ast:  q.Stmt,
+			vEnv:   env,
+			rEnv:   rEnv,
+			Ast:    q.Stmt,
+			TiStmt: q.TiStmt,
 		}, nil
 
 	case *sqlparser.DBDDL:
@@ -178,7 +210,6 @@ columns at the end. Now the index contains all the columns required for an index
 */
 
 // IndexAdvise 索引优化建议算法入口主函数
-// TODO 索引顺序该如何确定
 func (idxAdv *IndexAdvisor) IndexAdvise() IndexAdvises {
 	// 支持不依赖DB的索引建议分析
 	if common.Config.TestDSN.Disable {
@@ -232,16 +263,13 @@ func (idxAdv *IndexAdvisor) IndexAdvise() IndexAdvises {
 
 		for i, joinCols := range idxAdv.joinCond {
 			idxAdv.calcCardinality(joinCols)
-			joinCols = common.ColumnSort(joinCols)
-			idxAdv.joinCond[i] = joinCols
+			idxAdv.joinCond[i] = idxAdv.cardinalitySort(joinCols)
 		}
 
 		// 根据散粒度进行排序
-		// 对所有列进行排序，按散粒度由大到小排序
-		idxAdv.whereEQ = common.ColumnSort(idxAdv.whereEQ)
-		idxAdv.whereINEQ = common.ColumnSort(idxAdv.whereINEQ)
-		idxAdv.orderBy = common.ColumnSort(idxAdv.orderBy)
-		idxAdv.groupBy = common.ColumnSort(idxAdv.groupBy)
+		// 散粒度已知的列按散粒度由大到小排序，散粒度未知的列排在后面并保持书写顺序
+		idxAdv.whereEQ = idxAdv.cardinalitySort(idxAdv.whereEQ)
+		idxAdv.whereINEQ = idxAdv.cardinalitySort(idxAdv.whereINEQ)
 
 	}
 
@@ -279,46 +307,44 @@ func (idxAdv *IndexAdvisor) IndexAdvise() IndexAdvises {
 	}
 
 	// 索引优化算法入口，从这里开始放大招
+	// GROUP BY 的列可以同时避免临时表及排序，有 GROUP BY 时优先为 GROUP BY 添加索引，否则为 ORDER BY 添加索引
+	sortCols, sortUsage := idxAdv.groupBy, indexUsageGroupBy
+	if len(sortCols) == 0 {
+		sortCols, sortUsage = idxAdv.orderBy, indexUsageOrderBy
+	}
 	if hasWhere {
 		// 有Where条件的先分析 等值条件
 		for _, index := range idxAdv.whereEQ {
-			// 对应列在前面已经按散粒度由大到小排序好了
-			idxAdv.mergeIndex(indexList, index)
-		}
-		// 若存在非等值查询条件，可以给第一个非等值条件添加索引
-		if len(idxAdv.whereINEQ) > 0 {
-			idxAdv.mergeIndex(indexList, idxAdv.whereINEQ[0])
-		}
-		// 有WHERE条件，但 WHERE 条件未能给出索引建议就不能再加 GROUP BY 和 ORDER BY 建议了
-		if len(ignore) == 0 {
-			// 没有非等值查询条件时可以再为 GroupBy 和 OrderBy 添加索引
-			for _, index := range idxAdv.groupBy {
-				idxAdv.mergeIndex(indexList, index)
+			// 对应列在前面已经按散粒度由大到小排序好了，范围条件的列总是排在等值列之后
+			idxAdv.mergeIndexFor(indexList, index, indexUsageEQ)
+		}
+		switch {
+		case len(ignore) > 0:
+			// 有WHERE条件，但 WHERE 条件未能给出索引建议就不能再加 GROUP BY 和 ORDER BY 建议了
+			if len(idxAdv.whereINEQ) > 0 {
+				idxAdv.mergeIndexFor(indexList, idxAdv.whereINEQ[0], indexUsageRange)
 			}
-
-			// OrderBy
-			// 没有 GroupBy 时可以为 OrderBy 加索引
-			if len(idxAdv.groupBy) == 0 {
-				for _, index := range idxAdv.orderBy {
-					idxAdv.mergeIndex(indexList, index)
-				}
+		case len(idxAdv.whereINEQ) == 0:
+			// 只有等值条件时，等值列之后紧跟 GROUP BY 或 ORDER BY 的列，索引可以同时满足过滤及排序
+			for _, index := range sortCols {
+				idxAdv.mergeIndexFor(indexList, index, sortUsage)
+			}
+		case len(sortCols) > 0 && sortCols[0].Equal(idxAdv.whereINEQ[0]):
+			// 范围条件的列正好是第一个排序列时，范围扫描的结果本身有序，排序列可以全部添加
+			idxAdv.mergeIndexFor(indexList, idxAdv.whereINEQ[0], indexUsageRange)
+			for _, index := range sortCols {
+				idxAdv.mergeIndexFor(indexList, index, sortUsage)
 			}
+		default:
+			// 范围条件之后的列无法用于排序，优先使用索引过滤数据，排序仍需要 filesort
+			idxAdv.mergeIndexFor(indexList, idxAdv.whereINEQ[0], indexUsageRange)
 		}
 	} else {
-		// 未指定 Where 条件的，只需要 GroupBy 和 OrderBy 的索引建议
+		// 未指定 Where 条件的，只需要 GroupBy 的索引建议
+		// 没有 where 条件时 OrderBy 的索引仅能够在索引覆盖的情况下被使用
 		for _, index := range idxAdv.groupBy {
-			idxAdv.mergeIndex(indexList, index)
+			idxAdv.mergeIndexFor(indexList, index, indexUsageGroupBy)
 		}
-
-		// OrderBy
-		// 没有GroupBy 时可以为 OrderBy 加索引
-		// 没有 where 条件时 OrderBy 的索引仅能够在索引覆盖的情况下被使用
-
-		// if len(idxAdv.groupBy) == 0 {
-		// 	for _, index := range idxAdv.orderBy {
-		// 		mergeIndex(indexList, index)
-		// 	}
-		// }
 	}
 
 	// 开始整合索引信息，添加索引
@@ -369,13 +395,16 @@ func (idxAdv *IndexAdvisor) idxColsTypeCheck(idxList []IndexInfo) []IndexInfo {
 	for _, idx := range idxList {
 		var newCols []*common.Column
 		var newColInfo []string
+		var newUsage []string
+		var newReasons []string
 		// 索引总长度
 		idxBytesTotal := 0
 		isOverFlow := false
-		for _, col := range idx.ColumnDetails {
+		for i, col := range idx.ColumnDetails {
 			// 获取字段 bytes
 			bytes := col.GetDataBytes(common.Config.OnlineDSN.Version)
 			tmpCol := col.Name
+			prefixNote := ""
 			overFlow := 0
 			// 加上该列后是否索引长度过长
 			if bytes < 0 {
@@ -417,7 +446,22 @@ func (idxAdv *IndexAdvisor) idxColsTypeCheck(idxList []IndexInfo) []IndexInfo {
 
 				// 保留两个字节的安全余量
 				length := (common.Config.MaxIdxBytesPerColumn - 2) / v
-				if isOverFlow {
+				maxLength := length
+				if isOverFlow && (common.Config.MaxIdxBytes-idxBytesTotal-2)/v < maxLength {
+					maxLength = (common.Config.MaxIdxBytes - idxBytesTotal - 2) / v
+				}
+				if prefix, ratio := idxAdv.prefixLength(col, maxLength); prefix > 0 {
+					// 根据采样数据选择区分度足够的最短前缀
+					common.Log.Debug("index column %s.%s use prefix length %d, cardinality ratio %0.4f",
+						col.Table, col.Name, prefix, ratio)
+					tmpCol += fmt.Sprintf("_OPR_SPLIT_(%d)", prefix)
+					prefixNote = fmt.Sprintf("，使用长度为 %d 的前缀索引，前缀的散粒度为整列的 %0.2f%%", prefix, ratio*100)
+					if !isOverFlow {
+						idxBytesTotal -= bytes
+					}
+					idxBytesTotal += prefix*v + 2
+					isOverFlow = false
+				} else if isOverFlow {
 					// 在索引中添加该列会导致索引长度过长，建议根据需求转换为合理的前缀索引
 					// _OPR_SPLIT_ 是自定的用于后续处理的特殊分隔符
 					common.Log.Warning("adding index '%s(%s)' to table '%s' causes the index to be too long, overflow is %d",
@@ -434,6 +478,12 @@ func (idxAdv *IndexAdvisor) idxColsTypeCheck(idxList []IndexInfo) []IndexInfo {
 
 			newCols = append(newCols, col)
 			newColInfo = append(newColInfo, tmpCol)
+			if len(idx.Usage) == len(idx.ColumnDetails) {
+				newUsage = append(newUsage, idx.Usage[i])
+			}
+			if len(idx.Reasons) == len(idx.ColumnDetails) {
+				newReasons = append(newReasons, idx.Reasons[i]+prefixNote)
+			}
 		}
 
 		// 为新索引重建索引语句
@@ -468,6 +518,8 @@ func (idxAdv *IndexAdvisor) idxColsTypeCheck(idxList []IndexInfo) []IndexInfo {
 
 		// 将筛选改造后的索引信息信息加入到新的索引列表中
 		idx.ColumnDetails = newCols
+		idx.Usage = newUsage
+		idx.Reasons = newReasons
 		idx.DDL = newDDL
 		indexes = append(indexes, idx)
 	}
@@ -475,6 +527,52 @@ func (idxAdv *IndexAdvisor) idxColsTypeCheck(idxList []IndexInfo) []IndexInfo {
 	return indexes
 }
 
+// prefixLengths 前缀索引的候选长度（字符数）
+var prefixLengths = []int{4, 8, 12, 16, 20, 24, 32, 48, 64, 96, 128, 191, 255, 384, 512, 768}
+
+// prefixSelectivity 前缀的散粒度不低于整列散粒度的该比例时认为前缀的区分度足够
+const prefixSelectivity = 0.95
+
+// prefixLength 根据测试环境中的采样数据，为字符串列选择不超过 maxLength 且区分度足够的最短前缀
+// 未开启采样或无法计算时返回 -1
+func (idxAdv *IndexAdvisor) prefixLength(col *common.Column, maxLength int) (int, float64) {
+	if !common.Config.Sampling || maxLength <= 0 || idxAdv.vEnv == nil || idxAdv.vEnv.Connector == nil {
+		return -1, 0
+	}
+	var lengths []int
+	for _, length := range prefixLengths {
+		if length < maxLength {
+			lengths = append(lengths, length)
+		}
+	}
+	lengths = append(lengths, maxLength)
+
+	conn := *idxAdv.vEnv.Connector
+	conn.Database = idxAdv.vEnv.DBHash(col.DB)
+	cardinality, err := conn.PrefixCardinality(col.Table, col.Name, lengths)
+	if err != nil {
+		common.Log.Warn("prefixLength %s.%s error: %v", col.Table, col.Name, err)
+		return -1, 0
+	}
+	return choosePrefix(lengths, cardinality)
+}
+
+// choosePrefix 选择散粒度不低于整列散粒度 prefixSelectivity 倍的最短前缀，cardinality 的最后一个元素为整列的散粒度
+// 所有候选前缀的区分度都不够时使用允许的最长前缀
+func choosePrefix(lengths []int, cardinality []float64) (int, float64) {
+	if len(lengths) == 0 || len(cardinality) != len(lengths)+1 || cardinality[len(lengths)] == 0 {
+		return -1, 0
+	}
+	full := cardinality[len(lengths)]
+	for i, length := range lengths {
+		if ratio := cardinality[i] / full; ratio >= prefixSelectivity {
+			return length, ratio
+		}
+	}
+	last := len(lengths) - 1
+	return lengths[last], cardinality[last] / full
+}
+
 // mergeIndexes 与线上环境对比，将给出的索引建议进行去重
 func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 	// TODO 暂不支持前缀索引去重
@@ -541,6 +639,12 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 						continue
 					}
 
+					// 已有的普通索引是新索引的最左前缀，扩展已有索引而不是新增一个索引
+					if !isConstraint && idx.Extends == "" {
+						idx = extendIndex(idx, idxName, false)
+						continue
+					}
+
 					// 库、表、列名需要用反撇转义
 					// TODO: 关于外键索引去重的优雅解决方案
 					if !isConstraint {
@@ -559,6 +663,9 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 								strings.Join(cols, ","), idxName)
 						}
 					}
+				} else if !isConstraint && idx.Extends == "" && common.IsColsSame(colsDetail, idx.ColumnDetails) {
+					// 已有的普通索引与新索引的列相同仅顺序不同，调整已有索引的列顺序
+					idx = extendIndex(idx, existedIdx.KeyName, true)
 				}
 			}
 		}
@@ -589,6 +696,28 @@ func (idxAdv *IndexAdvisor) mergeIndexes(idxList []IndexInfo) []IndexInfo {
 	return rmSelfDupIndex(indexes)
 }
 
+// extendIndex 将新增索引的建议改为在同一条 ALTER 中删除已有索引并添加新索引
+func extendIndex(idx IndexInfo, existed string, reorder bool) IndexInfo {
+	idx.DDL = strings.Replace(idx.DDL, fmt.Sprintf(" add index `%s`", idx.Name),
+		fmt.Sprintf(" drop index `%s`, add index `%s`", existed, idx.Name), 1)
+	idx.Extends = existed
+	idx.Reorder = reorder
+	return idx
+}
+
+// extendNote 说明扩展或调整已有索引的原因及 Online DDL 的影响，未涉及已有索引时返回空
+func (idx IndexInfo) extendNote() string {
+	if idx.Extends == "" {
+		return ""
+	}
+	note := fmt.Sprintf("已有索引 %s 是 %s 的最左前缀，建议扩展已有索引而不是新增索引，减少表上的索引数量。", idx.Extends, idx.Name)
+	if idx.Reorder {
+		note = fmt.Sprintf("已有索引 %s 与 %s 的列相同但顺序不同，建议调整已有索引的列顺序，调整前请确认其他查询不依赖原有的列顺序。", idx.Extends, idx.Name)
+	}
+	return note + "删除及添加索引在同一条 ALTER 中执行，InnoDB 可以使用 Online DDL（ALGORITHM=INPLACE, LOCK=NONE）不阻塞读写，" +
+		"但大表上重建索引耗时较长并可能导致主从延迟，使用 FORCE INDEX 等指定原索引名称的 SQL 需要同步修改。"
+}
+
 // getRandomIndexSuffix format: _xxxx, length: 5
 func getRandomIndexSuffix() string {
 	return fmt.Sprintf("_%s", uniuri.New()[:4])
@@ -628,7 +757,7 @@ func (idxAdv *IndexAdvisor) buildJoinIndex(meta common.Meta) []IndexInfo {
 		// 如果该列的库表为join condition中需要添加索引的库表
 		indexColsList := make(map[string]map[string][]*common.Column)
 		for _, col := range IndexCols {
-			idxAdv.mergeIndex(indexColsList, col)
+			idxAdv.mergeIndexFor(indexColsList, col, indexUsageJoin)
 		}
 
 		if common.Config.TestDSN.Disable || common.Config.OnlineDSN.Disable {
@@ -683,6 +812,8 @@ func (idxAdv *IndexAdvisor) buildIndex(idxList map[string]map[string][]*common.C
 				Table:         tb,
 				DDL:           alterSQL,
 				ColumnDetails: cols,
+				Usage:         idxAdv.columnUsage(cols),
+				Reasons:       idxAdv.columnReasons(cols),
 			})
 		}
 	}
@@ -713,6 +844,7 @@ func (idxAdv *IndexAdvisor) buildIndexWithNoEnv(indexList map[string]map[string]
 					Table:         col.Table,
 					DDL:           alterSQL,
 					ColumnDetails: []*common.Column{col},
+					Usage:         idxAdv.columnUsage([]*common.Column{col}),
 				})
 			}
 
@@ -764,6 +896,84 @@ func (idxAdv *IndexAdvisor) mergeIndex(idxList map[string]map[string][]*common.C
 	}
 }
 
+// mergeIndexFor 同 mergeIndex，并记录该列在索引中的用途，同一列有多种用途时以第一次添加时为准
+func (idxAdv *IndexAdvisor) mergeIndexFor(idxList map[string]map[string][]*common.Column, column *common.Column, usage string) {
+	idxAdv.mergeIndex(idxList, column)
+	if idxAdv.usage == nil {
+		idxAdv.usage = make(map[string]string)
+	}
+	key := column.DB + "." + column.Table + "." + column.Name
+	if _, ok := idxAdv.usage[key]; !ok {
+		idxAdv.usage[key] = usage
+	}
+}
+
+// columnUsage 返回索引中各列的用途，未记录用途时返回 nil
+func (idxAdv *IndexAdvisor) columnUsage(cols []*common.Column) []string {
+	var usage []string
+	for _, col := range cols {
+		u, ok := idxAdv.usage[col.DB+"."+col.Table+"."+col.Name]
+		if !ok {
+			return nil
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// columnReasons 说明索引中各列位置的依据，未记录用途时返回 nil
+func (idxAdv *IndexAdvisor) columnReasons(cols []*common.Column) []string {
+	usage := idxAdv.columnUsage(cols)
+	if usage == nil {
+		return nil
+	}
+	var reasons []string
+	rank := make(map[string]int)
+	for i, col := range cols {
+		rank[usage[i]]++
+		switch usage[i] {
+		case indexUsageEQ, indexUsageJoin:
+			from, ok := idxAdv.measured[col.DB+"."+col.Table+"."+col.Name]
+			if !ok {
+				reasons = append(reasons, fmt.Sprintf("用于%s，散粒度未知，按书写顺序排列，顺序需要自行调整", usage[i]))
+				continue
+			}
+			reasons = append(reasons, fmt.Sprintf("用于%s，散粒度为 %0.2f%%（%s），在%s的列中按散粒度由高到低排第 %d 位",
+				usage[i], col.Cardinality*100, from, usage[i], rank[usage[i]]))
+		case indexUsageRange:
+			reasons = append(reasons, fmt.Sprintf("用于%s，放在等值条件的列之后，之后的列无法再用于过滤", usage[i]))
+		default:
+			reasons = append(reasons, fmt.Sprintf("用于%s，保持 %s 的书写顺序", usage[i], usage[i]))
+		}
+	}
+	return reasons
+}
+
+// usageNote 说明索引中哪些列用于过滤，哪些列用于 GROUP BY, ORDER BY，各列用途未知时返回空
+func (idx IndexInfo) usageNote() string {
+	if len(idx.Usage) == 0 || len(idx.Usage) != len(idx.ColumnDetails) {
+		return ""
+	}
+	var parts []string
+	var names []string
+	sorted := ""
+	for i, col := range idx.ColumnDetails {
+		names = append(names, col.Name)
+		if i+1 < len(idx.Usage) && idx.Usage[i+1] == idx.Usage[i] {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s 用于 %s", strings.Join(names, ", "), idx.Usage[i]))
+		names = nil
+		switch idx.Usage[i] {
+		case indexUsageGroupBy:
+			sorted = "GROUP BY 可以按索引顺序读取，避免临时表（Using temporary）及排序（Using filesort）。"
+		case indexUsageOrderBy:
+			sorted = "ORDER BY 可以按索引顺序读取，避免排序（Using filesort）。"
+		}
+	}
+	return fmt.Sprintf("索引 %s 中 %s。%s", idx.Name, strings.Join(parts, "，"), sorted)
+}
+
 // CompleteColumnsInfo 补全索引可能会用到列的所属库名、表名等信息
 func CompleteColumnsInfo(stmt sqlparser.Statement, cols []*common.Column, env *env.VirtualEnv) []*common.Column {
 	// 如果传过来的列是空的，没必要跑逻辑
@@ -945,6 +1155,7 @@ func (idxAdv *IndexAdvisor) calcCardinality(cols []*common.Column) []*common.Col
 				if (index.KeyName == "PRIMARY" || index.NonUnique == 0) && columnCount == 1 {
 					common.Log.Debug("column '%s' is PK or UK, no need to calculate cardinality.", col.Name)
 					col.Cardinality = 1
+					idxAdv.setMeasured(col, cardinalityFromKey)
 					break
 				}
 			}
@@ -954,12 +1165,239 @@ func (idxAdv *IndexAdvisor) calcCardinality(cols []*common.Column) []*common.Col
 		// 给非 PRIMARY、UNIQUE 的列计算散粒度
 		if col.Cardinality != 1 {
 			col.Cardinality = idxAdv.vEnv.ColumnCardinality(col.Table, col.Name)
+			switch {
+			case common.Config.Sampling:
+				idxAdv.setMeasured(col, cardinalityFromSampling)
+			case common.Config.StatisticsTransfer:
+				idxAdv.setMeasured(col, cardinalityFromStats)
+			case !common.Config.OnlineDSN.Disable:
+				// 未开启采样时测试环境中没有数据，使用线上环境的索引统计信息
+				if cardinality := idxAdv.onlineCardinality(col); cardinality >= 0 {
+					col.Cardinality = cardinality
+					idxAdv.setMeasured(col, cardinalityFromOnline)
+				}
+			}
 		}
 	}
 
 	return cols
 }
 
+// onlineCardinality 根据线上环境的表状态及索引统计信息估算列的散粒度，不会对线上表执行 COUNT(DISTINCT)
+// 只有当列是某个已有索引的第一列时才能估算，否则返回 -1
+func (idxAdv *IndexAdvisor) onlineCardinality(col *common.Column) float64 {
+	conn := idxAdv.rEnv
+	if col.DB != "" {
+		conn.Database = col.DB
+	}
+	tbStatus, err := conn.ShowTableStatus(col.Table)
+	if err != nil || len(tbStatus.Rows) == 0 {
+		return -1
+	}
+	return conn.IndexCardinality(col.Table, col.Name, tbStatus.Rows[0].Rows)
+}
+
+// setMeasured 记录列散粒度的来源
+func (idxAdv *IndexAdvisor) setMeasured(col *common.Column, from string) {
+	if idxAdv.measured == nil {
+		idxAdv.measured = make(map[string]string)
+	}
+	idxAdv.measured[col.DB+"."+col.Table+"."+col.Name] = from
+}
+
+// cardinalitySort 散粒度已知的列按散粒度由大到小排序，散粒度未知的列排在后面，相等时保持书写顺序
+func (idxAdv *IndexAdvisor) cardinalitySort(cols []*common.Column) []*common.Column {
+	sort.SliceStable(cols, func(i, j int) bool {
+		_, mi := idxAdv.measured[cols[i].DB+"."+cols[i].Table+"."+cols[i].Name]
+		_, mj := idxAdv.measured[cols[j].DB+"."+cols[j].Table+"."+cols[j].Name]
+		if mi != mj {
+			return mi
+		}
+		return mi && cols[i].Cardinality > cols[j].Cardinality
+	})
+	return cols
+}
+
+// addIndexes 在测试环境中添加建议的索引，返回删除这些索引的函数，调用方在比较完成后需要调用该函数
+// 只添加新增的索引，DDL 中的库名为线上环境的库名，需要替换为测试环境中对应的库
+func (idxAdv *IndexAdvisor) addIndexes(idxAdvs IndexAdvises) (func(), error) {
+	var drops []string
+	drop := func() {
+		for _, ddl := range drops {
+			res, err := idxAdv.vEnv.Query(ddl)
+			if err != nil {
+				common.Log.Warning("drop index Error: %v", err)
+				continue
+			}
+			common.LogIfWarn(res.Rows.Close(), "")
+		}
+	}
+	for _, idx := range idxAdvs {
+		if !strings.Contains(idx.DDL, " add index ") {
+			continue
+		}
+		// 扩展已有索引时只添加新索引，不删除测试环境中的已有索引
+		ddl := strings.Replace(idx.DDL, fmt.Sprintf(" drop index `%s`,", idx.Extends), "", 1)
+		db := idxAdv.vEnv.DBHash(idx.Database)
+		if db == "" {
+			db = idxAdv.vEnv.Database
+		}
+		ddl = strings.Replace(ddl, fmt.Sprintf("`%s`.", idx.Database), fmt.Sprintf("`%s`.", db), 1)
+		res, err := idxAdv.vEnv.Query(ddl)
+		if err != nil {
+			drop()
+			return nil, err
+		}
+		common.LogIfWarn(res.Rows.Close(), "")
+		drops = append(drops, fmt.Sprintf("alter table `%s`.`%s` drop index `%s`", db, idx.Table, idx.Name))
+	}
+	if len(drops) == 0 {
+		return nil, fmt.Errorf("no index added")
+	}
+	return drop, nil
+}
+
+// IndexCost 在测试环境中比较添加建议的索引前后 SQL 的查询代价，比较完成后删除添加的索引
+func (idxAdv *IndexAdvisor) IndexCost(sql string, idxAdvs IndexAdvises) (before, after float64, err error) {
+	if idxAdv == nil || idxAdv.vEnv == nil || len(idxAdvs) == 0 {
+		return 0, 0, fmt.Errorf("no index advise")
+	}
+	before, err = idxAdv.vEnv.QueryCost(sql)
+	if err != nil {
+		return 0, 0, err
+	}
+	drop, err := idxAdv.addIndexes(idxAdvs)
+	if err != nil {
+		return before, 0, err
+	}
+	defer drop()
+	after, err = idxAdv.vEnv.QueryCost(sql)
+	return before, after, err
+}
+
+// IndexBenefit 添加索引前后 EXPLAIN 中该表的变化
+type IndexBenefit struct {
+	BeforeType string  `json:"before_type"` // 访问类型
+	AfterType  string  `json:"after_type"`
+	BeforeRows int64   `json:"before_rows"` // 预估扫描行数
+	AfterRows  int64   `json:"after_rows"`
+	BeforeCost float64 `json:"before_cost"` // 整条 SQL 的查询代价，无法获取时为 0
+	AfterCost  float64 `json:"after_cost"`
+}
+
+// String 用于在索引建议中输出添加索引前后的变化
+func (b *IndexBenefit) String() string {
+	str := fmt.Sprintf("测试环境中添加索引后 EXPLAIN 由 %s 扫描 %d 行变为 %s 扫描 %d 行",
+		b.BeforeType, b.BeforeRows, b.AfterType, b.AfterRows)
+	if b.BeforeCost > 0 && b.AfterCost > 0 {
+		str += fmt.Sprintf("，查询代价由 %.3f 变为 %.3f", b.BeforeCost, b.AfterCost)
+	}
+	return str + "。"
+}
+
+// explainTable 汇总 EXPLAIN 中某张表的访问类型及扫描行数，同一张表出现多次时行数累加，访问类型取第一次出现的
+func explainTable(rows []database.ExplainRow, names map[string]bool) (accessType string, examined int64, found bool) {
+	for _, row := range rows {
+		if !names[row.TableName] {
+			continue
+		}
+		if !found {
+			accessType = row.AccessType
+			found = true
+		}
+		examined += row.Rows
+	}
+	return accessType, examined, found
+}
+
+// MeasureBenefit 在测试环境中逐张表添加建议的索引后重新执行 EXPLAIN，将该表访问类型、扫描行数及查询代价的变化记录到 IndexInfo.Benefit
+// 测试环境中只有开启 -sampling 后才有数据，否则 EXPLAIN 的行数没有参考意义，调用方需要自行判断
+func (idxAdv *IndexAdvisor) MeasureBenefit(sql string, idxAdvs IndexAdvises) {
+	if idxAdv == nil || idxAdv.vEnv == nil || len(idxAdvs) == 0 {
+		return
+	}
+	explain := func() ([]database.ExplainRow, float64, error) {
+		exp, err := idxAdv.vEnv.Explain(sql, database.TraditionalExplainType, database.JSONFormatExplain)
+		if err != nil {
+			return nil, 0, err
+		}
+		if exp.ExplainJSON == nil {
+			return exp.ExplainRows, exp.Cost(), nil
+		}
+		return database.ConvertExplainJSON2Row(exp.ExplainJSON), exp.Cost(), nil
+	}
+	before, beforeCost, err := explain()
+	if err != nil {
+		common.Log.Warning("MeasureBenefit Explain Error: %v", err)
+		return
+	}
+
+	// EXPLAIN 中的表名可能是别名
+	aliases := make(map[string]map[string]bool)
+	var meta common.Meta
+	if idxAdv.Ast != nil {
+		meta = ast.GetMeta(idxAdv.Ast, nil)
+	}
+	for _, db := range meta {
+		for name, tb := range db.Table {
+			aliases[name] = map[string]bool{name: true}
+			for _, alias := range tb.TableAliases {
+				aliases[name][alias] = true
+			}
+		}
+	}
+
+	// 按表分组，同一张表的索引一起添加
+	var tables []string
+	groups := make(map[string]IndexAdvises)
+	for _, idx := range idxAdvs {
+		key := idx.Database + "." + idx.Table
+		if _, ok := groups[key]; !ok {
+			tables = append(tables, key)
+		}
+		groups[key] = append(groups[key], idx)
+	}
+	for _, key := range tables {
+		group := groups[key]
+		names := aliases[group[0].Table]
+		if names == nil {
+			names = map[string]bool{group[0].Table: true}
+		}
+		beforeType, beforeRows, ok := explainTable(before, names)
+		if !ok {
+			continue
+		}
+		drop, err := idxAdv.addIndexes(group)
+		if err != nil {
+			common.Log.Debug("MeasureBenefit addIndexes %s Error: %v", key, err)
+			continue
+		}
+		after, afterCost, err := explain()
+		drop()
+		if err != nil {
+			common.Log.Warning("MeasureBenefit Explain Error: %v", err)
+			continue
+		}
+		afterType, afterRows, ok := explainTable(after, names)
+		if !ok {
+			continue
+		}
+		benefit := &IndexBenefit{
+			BeforeType: beforeType,
+			AfterType:  afterType,
+			BeforeRows: beforeRows,
+			AfterRows:  afterRows,
+			BeforeCost: beforeCost,
+			AfterCost:  afterCost,
+		}
+		for i := range idxAdvs {
+			if idxAdvs[i].Database+"."+idxAdvs[i].Table == key {
+				idxAdvs[i].Benefit = benefit
+			}
+		}
+	}
+}
+
 // Format 用于格式化输出索引建议
 func (idxAdvs IndexAdvises) Format() map[string]Rule {
 	rulesMap := make(map[string]Rule)
@@ -989,7 +1427,11 @@ func (idxAdvs IndexAdvises) Format() map[string]Rule {
 			}
 		}
 
-		for _, col := range advise.ColumnDetails {
+		for i, col := range advise.ColumnDetails {
+			if len(advise.Reasons) == len(advise.ColumnDetails) {
+				rules[advKey].Content += fmt.Sprintf("为列%s添加索引，%s; ", col.Name, advise.Reasons[i])
+				continue
+			}
 			// 为了更好地显示效果
 			if common.Config.Sampling {
 				cardinal := fmt.Sprintf("%0.2f", col.Cardinality*100)
@@ -1001,11 +1443,23 @@ func (idxAdvs IndexAdvises) Format() map[string]Rule {
 				rules[advKey].Content += fmt.Sprintf("为列%s添加索引;", col.Name)
 			}
 		}
-		if !common.Config.Sampling && len(rules[advKey].Content) > 5 {
+		if note := advise.usageNote(); note != "" {
+			rules[advKey].Content += " " + note
+		}
+		if note := advise.extendNote(); note != "" {
+			rules[advKey].Content += " " + note
+		}
+		if !common.Config.Sampling && len(advise.Reasons) == 0 && len(rules[advKey].Content) > 5 {
 			rules[advKey].Content += " 由于未开启数据采样，各列在索引中的顺序需要自行调整。"
 		}
 		// 清理多余的标点
 		rules[advKey].Content = strings.Trim(rules[advKey].Content, common.Config.Delimiter)
+		if shard, _ := common.FindShardTable(advise.Table); shard != nil && !strings.Contains(rules[advKey].Content, shard.Pattern) {
+			rules[advKey].Content += fmt.Sprintf(" %s 为分表 %s 的一部分，需要在所有分表上添加索引。", advise.Table, shard.Pattern)
+		}
+		if advise.Benefit != nil && !strings.Contains(rules[advKey].Content, "测试环境中添加索引后") {
+			rules[advKey].Content += " " + advise.Benefit.String()
+		}
 	}
 
 	var sortAdvs []string
@@ -1043,11 +1497,22 @@ func (idxAdv *IndexAdvisor) HeuristicCheck(q Query4Audit) map[string]Rule {
 	}
 
 	ruleFuncs := []func(*IndexAdvisor) Rule{
-		(*IndexAdvisor).RuleMaxTextColsCount,   // COL.007
-		(*IndexAdvisor).RuleImplicitConversion, // ARG.003
-		(*IndexAdvisor).RuleGroupByConst,       // CLA.004
-		(*IndexAdvisor).RuleOrderByConst,       // CLA.005
-		(*IndexAdvisor).RuleUpdatePrimaryKey,   // CLA.016
+		(*IndexAdvisor).RuleMaxTextColsCount,       // COL.007
+		(*IndexAdvisor).RuleImplicitConversion,     // ARG.003
+		(*IndexAdvisor).RuleCharsetMismatch,        // ARG.014
+		(*IndexAdvisor).RuleGroupByConst,           // CLA.004
+		(*IndexAdvisor).RuleOrderByConst,           // CLA.005
+		(*IndexAdvisor).RuleUpdatePrimaryKey,       // CLA.016
+		(*IndexAdvisor).RuleAlterFKWithoutIndex,    // KEY.011
+		(*IndexAdvisor).RuleAutoIncrementExhausted, // COL.020
+		(*IndexAdvisor).RuleMoneyPrecision,         // COL.009
+		(*IndexAdvisor).RuleCartesianProduct,       // JOI.009
+		(*IndexAdvisor).RuleLockReadWithoutIndex,   // LCK.003
+		(*IndexAdvisor).RuleAlterMetadataLock,      // ALT.005
+		(*IndexAdvisor).RuleCreateTableLike,        // TBL.013
+		(*IndexAdvisor).RuleRemoteTable,            // FED.001
+		(*IndexAdvisor).RuleRemoteJoin,             // FED.002
+		(*IndexAdvisor).RuleRemoteWrite,            // FED.003
 		// (*IndexAdvisor).RuleImpossibleOuterJoin, // TODO: JOI.003, JOI.004
 	}
 
@@ -1100,7 +1565,17 @@ func DuplicateKeyChecker(conn *database.Connector, databases ...string) map[stri
 			}
 		}
 
+		// 同一分表的物理表结构相同，只检查其中一张
+		checkedShards := make(map[string]bool)
 		for _, tb := range tables {
+			shard, _ := common.FindShardTable(tb)
+			if shard != nil {
+				if checkedShards[shard.Logical] {
+					continue
+				}
+				checkedShards[shard.Logical] = true
+			}
+
 			// 获取表中所有的索引
 			idxMap := make(map[string][]*common.Column)
 			idxInfo, err := tmpOnline.ShowIndex(tb)
@@ -1144,6 +1619,9 @@ func DuplicateKeyChecker(conn *database.Connector, databases ...string) map[stri
 
 			// TODO 重复索引检查添加对约束及索引的判断，提供重复索引的删除功能
 			if hasDup {
+				if shard != nil {
+					content += fmt.Sprintf("%s 为分表 %s 的一部分，其他分表未重复检查。", tb, shard.Pattern)
+				}
 				tmpOnline.Database = db
 				ddl, _ := tmpOnline.ShowCreateTable(tb)
 				key := fmt.Sprintf("IDX.%03d", number)
@@ -44,6 +44,7 @@ func TestMain(m *testing.M) {
 		common.DevPath, _ = filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	}
 	common.BaseDir = common.DevPath
+	common.RedirectTestLog()
 	err := common.ParseConfig("")
 	common.LogIfError(err, "init ParseConfig")
 	common.Log.Debug("advisor_test init")
@@ -144,6 +145,78 @@ func TestRuleImplicitConversion(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+// ARG.014
+func TestRuleCharsetMismatch(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dsn := common.Config.OnlineDSN
+	common.Config.OnlineDSN = common.Config.TestDSN
+
+	initSQLs := []string{
+		`CREATE TABLE t1 (id int, title varchar(255) CHARSET utf8 COLLATE utf8_general_ci);`,
+		`CREATE TABLE t2 (id int, title varchar(255) CHARSET utf8mb4);`,
+		`CREATE TABLE t3 (id int, title varchar(255) CHARSET utf8 COLLATE utf8_bin);`,
+	}
+	for _, sql := range initSQLs {
+		vEnv.BuildVirtualEnv(rEnv, sql)
+	}
+
+	sqls := [][]string{
+		{
+			"SELECT * FROM t1 JOIN t2 ON t1.title = t2.title;",
+			"SELECT * FROM t1, t3 WHERE t1.title = t3.title;",
+			"SELECT * FROM t1 WHERE title = 'abc' COLLATE utf8_bin;",
+		},
+		{
+			"SELECT * FROM t1 JOIN t2 ON t1.id = t2.id;",
+			"SELECT * FROM t1 WHERE title = 'abc';",
+			"SELECT * FROM t1 a JOIN t1 b ON a.title = b.title;",
+		},
+	}
+	for i, list := range sqls {
+		want := []string{"ARG.014", "OK"}[i]
+		for _, sql := range list {
+			stmt, syntaxErr := sqlparser.Parse(sql)
+			if syntaxErr != nil {
+				common.Log.Critical("Syntax Error: %v, SQL: %s", syntaxErr, sql)
+			}
+
+			q := &Query4Audit{Query: sql, Stmt: stmt}
+
+			idxAdvisor, err := NewAdvisor(vEnv, *rEnv, *q)
+			if err != nil {
+				t.Error("NewAdvisor Error: ", err, "SQL: ", sql)
+			}
+
+			if idxAdvisor != nil {
+				rule := idxAdvisor.RuleCharsetMismatch()
+				if rule.Item != want {
+					t.Error("Rule not match:", rule, "Expect : "+want+", SQL:", sql)
+				}
+			}
+		}
+	}
+
+	common.Config.OnlineDSN = dsn
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestAlterColumnCharset(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	col := &common.Column{
+		Name:     "title",
+		Table:    "t1",
+		DataType: "varchar(255)",
+		Null:     "NO",
+		Default:  "it's",
+		Comment:  "标题",
+	}
+	want := "ALTER TABLE `sakila`.`t1` MODIFY `title` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'it''s' COMMENT '标题';"
+	if got := alterColumnCharset("sakila", col, "utf8mb4", "utf8mb4_general_ci"); got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 // JOI.003 & JOI.004
 func TestRuleImpossibleOuterJoin(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
@@ -458,6 +531,159 @@ func TestMergeAdvices(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestIndexUsageNote(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cols := []*common.Column{
+		{Name: "language_id", Table: "film", DB: "sakila"},
+		{Name: "rating", Table: "film", DB: "sakila"},
+		{Name: "title", Table: "film", DB: "sakila"},
+	}
+	idxAdv := &IndexAdvisor{usage: map[string]string{
+		"sakila.film.language_id": indexUsageEQ,
+		"sakila.film.rating":      indexUsageEQ,
+		"sakila.film.title":       indexUsageOrderBy,
+	}}
+	idx := IndexInfo{Name: "idx_language_id_rating_title", ColumnDetails: cols, Usage: idxAdv.columnUsage(cols)}
+	expect := "索引 idx_language_id_rating_title 中 language_id, rating 用于 WHERE 等值条件，title 用于 ORDER BY。ORDER BY 可以按索引顺序读取，避免排序（Using filesort）。"
+	if note := idx.usageNote(); note != expect {
+		t.Errorf("want: %s\ngot: %s", expect, note)
+	}
+
+	// 未记录用途的列不输出说明
+	cols = append(cols, &common.Column{Name: "length", Table: "film", DB: "sakila"})
+	idx = IndexInfo{Name: "idx", ColumnDetails: cols, Usage: idxAdv.columnUsage(cols)}
+	if note := idx.usageNote(); note != "" {
+		t.Errorf("want empty note, got: %s", note)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestIndexColumnReasons(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cols := []*common.Column{
+		{Name: "rating", Table: "film", DB: "sakila", Cardinality: 0.005},
+		{Name: "title", Table: "film", DB: "sakila", Cardinality: 1},
+		{Name: "language_id", Table: "film", DB: "sakila", Cardinality: 1},
+		{Name: "film_id", Table: "film", DB: "sakila", Cardinality: 1},
+	}
+	idxAdv := &IndexAdvisor{
+		usage: map[string]string{
+			"sakila.film.rating":      indexUsageEQ,
+			"sakila.film.title":       indexUsageEQ,
+			"sakila.film.language_id": indexUsageEQ,
+			"sakila.film.length":      indexUsageRange,
+		},
+		measured: map[string]string{
+			"sakila.film.rating":  cardinalityFromOnline,
+			"sakila.film.title":   cardinalityFromOnline,
+			"sakila.film.film_id": cardinalityFromKey,
+		},
+	}
+	// 散粒度已知的列按散粒度由大到小排序，散粒度未知的列排在后面
+	sorted := idxAdv.cardinalitySort(cols)
+	var names []string
+	for _, col := range sorted {
+		names = append(names, col.Name)
+	}
+	if strings.Join(names, ",") != "title,film_id,rating,language_id" {
+		t.Errorf("cardinalitySort got: %v", names)
+	}
+
+	cols = []*common.Column{sorted[0], sorted[3], {Name: "length", Table: "film", DB: "sakila"}}
+	expect := []string{
+		"用于WHERE 等值条件，散粒度为 100.00%（线上索引统计信息），在WHERE 等值条件的列中按散粒度由高到低排第 1 位",
+		"用于WHERE 等值条件，散粒度未知，按书写顺序排列，顺序需要自行调整",
+		"用于WHERE 范围条件，放在等值条件的列之后，之后的列无法再用于过滤",
+	}
+	reasons := idxAdv.columnReasons(cols)
+	if strings.Join(reasons, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("want: %v\ngot: %v", expect, reasons)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestChoosePrefix(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	lengths := []int{4, 8, 16, 32}
+	cases := []struct {
+		cardinality []float64
+		length      int
+	}{
+		{[]float64{0.2, 0.7, 0.96, 0.98, 1}, 16},
+		{[]float64{0.5, 0.5, 0.5, 0.5, 0.5}, 4},
+		{[]float64{0.1, 0.2, 0.3, 0.4, 0.8}, 32},
+		{[]float64{0, 0, 0, 0, 0}, -1},
+		{nil, -1},
+	}
+	for _, c := range cases {
+		if length, _ := choosePrefix(lengths, c.cardinality); length != c.length {
+			t.Errorf("choosePrefix(%v) want: %d, got: %d", c.cardinality, c.length, length)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExtendIndex(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	idx := IndexInfo{
+		Name:     "idx_language_id_title",
+		Database: "sakila",
+		Table:    "film",
+		DDL:      "alter table `sakila`.`film` add index `idx_language_id_title` (`language_id`,`title`)",
+	}
+	extended := extendIndex(idx, "idx_fk_language_id", false)
+	expect := "alter table `sakila`.`film` drop index `idx_fk_language_id`, add index `idx_language_id_title` (`language_id`,`title`)"
+	if extended.DDL != expect {
+		t.Errorf("want: %s\ngot: %s", expect, extended.DDL)
+	}
+	if note := extended.extendNote(); !strings.Contains(note, "最左前缀") || !strings.Contains(note, "Online DDL") {
+		t.Errorf("extendNote got: %s", note)
+	}
+	if note := extendIndex(idx, "idx_title_language_id", true).extendNote(); !strings.Contains(note, "顺序不同") {
+		t.Errorf("extendNote got: %s", note)
+	}
+	if note := idx.extendNote(); note != "" {
+		t.Errorf("want empty note, got: %s", note)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestIndexBenefit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	rows := []database.ExplainRow{
+		{TableName: "f", AccessType: "ALL", Rows: 1200000},
+		{TableName: "l", AccessType: "eq_ref", Rows: 1},
+	}
+	accessType, examined, ok := explainTable(rows, map[string]bool{"film": true, "f": true})
+	if !ok || accessType != "ALL" || examined != 1200000 {
+		t.Errorf("explainTable error: %s %d %v", accessType, examined, ok)
+	}
+	if _, _, ok = explainTable(rows, map[string]bool{"actor": true}); ok {
+		t.Error("explainTable should not find actor")
+	}
+
+	idxAdvs := IndexAdvises{
+		{
+			Name:          "idx_length",
+			Database:      "sakila",
+			Table:         "film",
+			DDL:           "alter table `sakila`.`film` add index `idx_length` (`length`)",
+			ColumnDetails: []*common.Column{{Name: "length"}},
+			Benefit: &IndexBenefit{
+				BeforeType: "ALL", AfterType: "range",
+				BeforeRows: 1200000, AfterRows: 40,
+				BeforeCost: 240000.5, AfterCost: 17.01,
+			},
+		},
+	}
+	for _, rule := range idxAdvs.Format() {
+		if !strings.Contains(rule.Content, "由 ALL 扫描 1200000 行变为 range 扫描 40 行，查询代价由 240000.500 变为 17.010") {
+			t.Error("index benefit not in content:", rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestIdxColsTypeCheck(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	sqls := []string{
@@ -0,0 +1,246 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// inListColumn 临时表中存放 IN 列表的值的列名
+const inListColumn = "in_value"
+
+// largeInList 值的个数超过 ARG.005 阈值的 IN 列表
+type largeInList struct {
+	cond    *sqlparser.ComparisonExpr
+	col     *sqlparser.ColName
+	vals    []*sqlparser.SQLVal
+	table   string // 临时表名
+	colType string // 临时表中列的类型
+}
+
+// inListRewrite 统计超过阈值的 IN 列表，并将由常量组成的 IN 列表改写为临时表 JOIN
+// 返回每个 IN 列表的说明，以及建临时表、分批写入、改写后的 SQL 组成的脚本，无法改写时脚本为空
+func inListRewrite(sql string) ([]string, string) {
+	// 改写会修改 AST，重新解析一份，不影响其他规则使用的 Query4Audit.Stmt
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil, ""
+	}
+	tables := offlineTableMap(stmt)
+
+	var fixes []string
+	var lists []*largeInList
+	names := make(map[string]int)
+	err = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		cond, ok := node.(*sqlparser.ComparisonExpr)
+		if !ok || cond.Operator != sqlparser.InStr {
+			return true, nil
+		}
+		tuple, ok := cond.Right.(sqlparser.ValTuple)
+		if !ok || len(tuple) <= RuleThreshold("ARG.005") {
+			return true, nil
+		}
+		fixes = append(fixes, fmt.Sprintf("`%s` 的 IN 列表中有 %d 个值，超过了 %d 个的阈值。",
+			sqlparser.String(cond.Left), len(tuple), RuleThreshold("ARG.005")))
+
+		col, ok := cond.Left.(*sqlparser.ColName)
+		if !ok {
+			return true, nil
+		}
+		list := &largeInList{cond: cond, col: col}
+		for _, v := range tuple {
+			val, ok := v.(*sqlparser.SQLVal)
+			if !ok {
+				return true, nil
+			}
+			list.vals = append(list.vals, val)
+		}
+		if list.colType = inListType(col, list.vals, tables); list.colType == "" {
+			return true, nil
+		}
+		name := "tmp_in_" + strings.ToLower(col.Name.String())
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, names[name])
+		}
+		list.table = name
+		lists = append(lists, list)
+		return true, nil
+	}, stmt)
+	common.LogIfError(err, "")
+	if len(lists) == 0 {
+		return fixes, ""
+	}
+
+	if !inListJoin(stmt, lists) {
+		// 无法改写为 JOIN 时改写为 IN 子查询，MySQL 会将其优化为半连接
+		for _, list := range lists {
+			sub, err := sqlparser.Parse(fmt.Sprintf("SELECT `%s` FROM `%s`", inListColumn, list.table))
+			if err != nil {
+				return fixes, ""
+			}
+			list.cond.Right = &sqlparser.Subquery{Select: sub.(*sqlparser.Select)}
+		}
+	}
+
+	var script []string
+	size := insertBatchSize()
+	if size <= 0 {
+		size = len(lists[0].vals)
+	}
+	for _, list := range lists {
+		script = append(script, fmt.Sprintf("CREATE TEMPORARY TABLE `%s` (`%s` %s NOT NULL, PRIMARY KEY (`%s`));",
+			list.table, inListColumn, list.colType, inListColumn))
+		for i := 0; i < len(list.vals); i += size {
+			var values []string
+			for j := i; j < i+size && j < len(list.vals); j++ {
+				values = append(values, "("+sqlparser.String(list.vals[j])+")")
+			}
+			// IN 列表中可能有重复的值，临时表中去重，保证 JOIN 之后不会产生重复的行
+			script = append(script, fmt.Sprintf("INSERT IGNORE INTO `%s` (`%s`) VALUES %s;",
+				list.table, inListColumn, strings.Join(values, ", ")))
+		}
+	}
+	script = append(script, sqlparser.String(stmt)+";")
+	for _, list := range lists {
+		script = append(script, fmt.Sprintf("DROP TEMPORARY TABLE `%s`;", list.table))
+	}
+	return fixes, strings.Join(script, "\n")
+}
+
+// inListType 临时表中列的类型，优先使用离线表结构中列的类型，否则根据 IN 列表中的值推断
+// 值的类型不一致或无法作为主键时返回空
+func inListType(col *sqlparser.ColName, vals []*sqlparser.SQLVal, tables map[string]string) string {
+	if c := offlineColumn(col, tables); c != nil {
+		switch strings.Fields(strings.ToLower(common.GetDataTypeBase(c.DataType)) + " ")[0] {
+		case "tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+			"decimal", "numeric", "char", "varchar", "date", "datetime", "timestamp":
+			return c.DataType
+		}
+	}
+
+	colType := ""
+	length := 1
+	for _, val := range vals {
+		var t string
+		switch val.Type {
+		case sqlparser.IntVal:
+			t = "BIGINT"
+		case sqlparser.FloatVal:
+			t = "DECIMAL(65, 30)"
+		case sqlparser.StrVal:
+			t = "VARCHAR"
+			if n := utf8.RuneCount(val.Val); n > length {
+				length = n
+			}
+		default:
+			return ""
+		}
+		switch {
+		case colType == "" || colType == t:
+			colType = t
+		case colType != "VARCHAR" && t != "VARCHAR":
+			// 整数与小数混用
+			colType = "DECIMAL(65, 30)"
+		default:
+			return ""
+		}
+	}
+	if colType == "VARCHAR" {
+		colType = fmt.Sprintf("VARCHAR(%d)", length)
+	}
+	return colType
+}
+
+// inListJoin 单表 SELECT 中 AND 连接的 IN 条件改写为与临时表 JOIN，不满足条件时不做修改并返回 false
+func inListJoin(stmt sqlparser.Statement, lists []*largeInList) bool {
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || sel.Where == nil || len(sel.From) != 1 {
+		return false
+	}
+	from, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return false
+	}
+	tb, ok := from.Expr.(sqlparser.TableName)
+	if !ok {
+		return false
+	}
+	qualifier := sqlparser.TableName{Name: tb.Name, Qualifier: tb.Qualifier}
+	if !from.As.IsEmpty() {
+		qualifier = sqlparser.TableName{Name: from.As}
+	}
+
+	targets := make(map[*sqlparser.ComparisonExpr]bool)
+	for _, list := range lists {
+		targets[list.cond] = true
+	}
+	var rest []sqlparser.Expr
+	for _, expr := range flatten(sel.Where.Expr, true) {
+		if cond, ok := expr.(*sqlparser.ComparisonExpr); ok && targets[cond] {
+			delete(targets, cond)
+			continue
+		}
+		rest = append(rest, expr)
+	}
+	// 有 IN 条件在 OR 或子查询中
+	if len(targets) > 0 {
+		return false
+	}
+
+	var where sqlparser.Expr
+	for _, expr := range rest {
+		if where == nil {
+			where = expr
+		} else {
+			where = &sqlparser.AndExpr{Left: where, Right: expr}
+		}
+	}
+	sel.Where = sqlparser.NewWhere(sqlparser.WhereStr, where)
+
+	var join sqlparser.TableExpr = from
+	for _, list := range lists {
+		if list.col.Qualifier.IsEmpty() {
+			list.col.Qualifier = qualifier
+		}
+		tmp := sqlparser.TableName{Name: sqlparser.NewTableIdent(list.table)}
+		join = &sqlparser.JoinTableExpr{
+			LeftExpr:  join,
+			Join:      sqlparser.JoinStr,
+			RightExpr: &sqlparser.AliasedTableExpr{Expr: tmp},
+			Condition: sqlparser.JoinCondition{On: &sqlparser.ComparisonExpr{
+				Operator: sqlparser.EqualStr,
+				Left:     list.col,
+				Right:    &sqlparser.ColName{Name: sqlparser.NewColIdent(inListColumn), Qualifier: tmp},
+			}},
+		}
+	}
+	sel.From = sqlparser.TableExprs{join}
+
+	// SELECT * 只返回原表的列
+	for _, expr := range sel.SelectExprs {
+		if star, ok := expr.(*sqlparser.StarExpr); ok && star.TableName.IsEmpty() {
+			star.TableName = qualifier
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// ARG.005
+func TestInListRewrite(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgMaxInCount, orgMaxValueCount := common.Config.MaxInCount, common.Config.MaxValueCount
+	orgSchema := offlineSchema
+	defer func() {
+		common.Config.MaxInCount, common.Config.MaxValueCount = orgMaxInCount, orgMaxValueCount
+		offlineSchema = orgSchema
+	}()
+	common.Config.MaxInCount = 3
+	common.Config.MaxValueCount = 2
+	offlineSchema = make(map[string]map[string]*common.Column)
+	LoadOfflineSchema("CREATE TABLE `film` (`film_id` smallint unsigned NOT NULL, `title` varchar(128), PRIMARY KEY (`film_id`));")
+
+	cases := []struct {
+		sql    string
+		fixes  []string
+		script []string
+	}{
+		{
+			"SELECT * FROM film WHERE film_id IN (1, 2, 3, 4) AND title = 'a'",
+			[]string{"`film_id` 的 IN 列表中有 4 个值，超过了 3 个的阈值。"},
+			[]string{
+				"CREATE TEMPORARY TABLE `tmp_in_film_id` (`in_value` smallint(6) unsigned NOT NULL, PRIMARY KEY (`in_value`));",
+				"INSERT IGNORE INTO `tmp_in_film_id` (`in_value`) VALUES (1), (2);",
+				"INSERT IGNORE INTO `tmp_in_film_id` (`in_value`) VALUES (3), (4);",
+				"select film.* from film join tmp_in_film_id on film.film_id = tmp_in_film_id.in_value where title = 'a';",
+				"DROP TEMPORARY TABLE `tmp_in_film_id`;",
+			},
+		},
+		{
+			"DELETE FROM actor WHERE name IN ('a', 'bb', 'ccc', 'a') OR id = 1",
+			[]string{"`name` 的 IN 列表中有 4 个值，超过了 3 个的阈值。"},
+			[]string{
+				"CREATE TEMPORARY TABLE `tmp_in_name` (`in_value` VARCHAR(3) NOT NULL, PRIMARY KEY (`in_value`));",
+				"INSERT IGNORE INTO `tmp_in_name` (`in_value`) VALUES ('a'), ('bb');",
+				"INSERT IGNORE INTO `tmp_in_name` (`in_value`) VALUES ('ccc'), ('a');",
+				"delete from actor where name in (select in_value from tmp_in_name) or id = 1;",
+				"DROP TEMPORARY TABLE `tmp_in_name`;",
+			},
+		},
+		{
+			// 值的类型不一致，只统计个数
+			"SELECT * FROM actor a WHERE a.id IN (1, 2, 3, '4') AND a.uid IN (1, 2, 3)",
+			[]string{"`a.id` 的 IN 列表中有 4 个值，超过了 3 个的阈值。"},
+			nil,
+		},
+	}
+	for _, c := range cases {
+		fixes, script := inListRewrite(c.sql)
+		if strings.Join(fixes, "\n") != strings.Join(c.fixes, "\n") {
+			t.Errorf("SQL: %s\nwant: %v\ngot: %v", c.sql, c.fixes, fixes)
+		}
+		if want := strings.Join(c.script, "\n"); script != want {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s", c.sql, want, script)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// Issue 导出至缺陷跟踪系统的一个问题，同一 SQL 指纹的同一条建议合并为一个 Issue
+type Issue struct {
+	DedupKey    string          `json:"dedup_key"`
+	Title       string          `json:"title"`
+	Item        string          `json:"item"`
+	Severity    string          `json:"severity"`
+	Label       string          `json:"label"`
+	Summary     string          `json:"summary"`
+	Content     string          `json:"content"`
+	QueryID     string          `json:"query_id"`
+	Fingerprint string          `json:"fingerprint"`
+	Sample      string          `json:"sample"`
+	Tables      []string        `json:"tables"`
+	Owner       string          `json:"owner,omitempty"`
+	Locations   []IssueLocation `json:"locations"`
+}
+
+// IssueLocation 问题在输入文件中出现的位置
+type IssueLocation struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// issueDedupKey 根据指纹及规则生成去重键，与文件及行号无关，SQL 移动位置或在多个文件中出现时不会重复建单
+func issueDedupKey(f Finding) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(f.Fingerprint+":"+f.Rule.Item)))
+}
+
+// NewIssues 将不低于 issue-min-severity 的建议按去重键合并为 Issue，保持首次出现的顺序
+func NewIssues(findings []Finding) []Issue {
+	minLevel := severityLevel(common.Config.IssueMinSeverity)
+	issues := make([]Issue, 0)
+	index := make(map[string]int)
+	for _, f := range findings {
+		if f.ok() || severityLevel(f.Rule.Severity) < minLevel {
+			continue
+		}
+		key := issueDedupKey(f)
+		loc := IssueLocation{File: f.File, Line: f.Line}
+		if i, ok := index[key]; ok {
+			issues[i].Locations = append(issues[i].Locations, loc)
+			continue
+		}
+		index[key] = len(issues)
+		issues = append(issues, Issue{
+			DedupKey:    key,
+			Title:       fmt.Sprintf("[soar] %s %s (%s)", f.Rule.Item, f.Rule.Summary, f.QueryID),
+			Item:        f.Rule.Item,
+			Severity:    f.Rule.Severity,
+			Label:       SeverityLabel(f.Rule.Severity),
+			Summary:     f.Rule.Summary,
+			Content:     f.Rule.Content,
+			QueryID:     f.QueryID,
+			Fingerprint: f.Fingerprint,
+			Sample:      f.SQL,
+			Tables:      f.Tables,
+			Owner:       f.Owner,
+			Locations:   []IssueLocation{loc},
+		})
+	}
+	return issues
+}
+
+// FormatIssues 输出通用的 issue JSON
+func FormatIssues(findings []Finding) string {
+	js, err := json.MarshalIndent(NewIssues(findings), "", "  ")
+	if err != nil {
+		common.Log.Error("FormatIssues json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
+
+// jiraBulk Jira REST API POST /rest/api/2/issue/bulk 的请求体
+// https://developer.atlassian.com/cloud/jira/platform/rest/v2/api-group-issues/#api-rest-api-2-issue-bulk-post
+type jiraBulk struct {
+	IssueUpdates []jiraIssue `json:"issueUpdates"`
+}
+
+type jiraIssue struct {
+	Fields jiraFields `json:"fields"`
+}
+
+type jiraFields struct {
+	Project     jiraKey  `json:"project"`
+	IssueType   jiraName `json:"issuetype"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	Priority    jiraName `json:"priority"`
+	Labels      []string `json:"labels"`
+}
+
+type jiraKey struct {
+	Key string `json:"key"`
+}
+
+type jiraName struct {
+	Name string `json:"name"`
+}
+
+// jiraPriority 按级别名称对应 Jira 默认的优先级
+func jiraPriority(severity string) string {
+	return map[string]string{
+		"info":    "Low",
+		"warning": "Medium",
+		"error":   "High",
+		"blocker": "Highest",
+	}[SeverityLabel(severity)]
+}
+
+// jiraDescription 使用 Jira wiki 格式输出建议内容、SQL 及出现位置
+func jiraDescription(issue Issue) string {
+	buf := []string{
+		fmt.Sprintf("*%s* %s (%s)", issue.Item, issue.Summary, issue.Severity),
+		"",
+		issue.Content,
+		"",
+		"{code:sql}",
+		issue.Sample,
+		"{code}",
+		"",
+		fmt.Sprintf("*Query ID:* %s", issue.QueryID),
+	}
+	if len(issue.Tables) > 0 {
+		buf = append(buf, fmt.Sprintf("*Tables:* %s", strings.Join(issue.Tables, ", ")))
+	}
+	if issue.Owner != "" {
+		buf = append(buf, fmt.Sprintf("*Owner:* %s", issue.Owner))
+	}
+	buf = append(buf, "*Locations:*")
+	for _, loc := range issue.Locations {
+		buf = append(buf, fmt.Sprintf("* %s:%d", loc.File, loc.Line))
+	}
+	return strings.Join(buf, "\n")
+}
+
+// FormatJira 输出 Jira 批量创建 issue 的请求体，每个 issue 带有 soar-<dedup_key> 标签，配置了 owner-file 时带有 owner-<团队> 标签
+// 创建前使用 JQL labels = soar-<dedup_key> 查询，已存在的 issue 跳过即可避免重复建单
+func FormatJira(findings []Finding) string {
+	bulk := jiraBulk{IssueUpdates: make([]jiraIssue, 0)}
+	for _, issue := range NewIssues(findings) {
+		labels := []string{"soar", "soar-" + issue.Item, "soar-" + issue.DedupKey}
+		if issue.Owner != "" {
+			labels = append(labels, "owner-"+issue.Owner)
+		}
+		bulk.IssueUpdates = append(bulk.IssueUpdates, jiraIssue{Fields: jiraFields{
+			Project:     jiraKey{Key: common.Config.JiraProject},
+			IssueType:   jiraName{Name: common.Config.JiraIssueType},
+			Summary:     issue.Title,
+			Description: jiraDescription(issue),
+			Priority:    jiraName{Name: jiraPriority(issue.Severity)},
+			Labels:      labels,
+		}})
+	}
+	js, err := json.MarshalIndent(bulk, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatJira json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
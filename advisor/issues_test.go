@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestNewIssues(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgMinSeverity := common.Config.IssueMinSeverity
+	common.Config.IssueMinSeverity = "L1"
+	suggest := map[string]Rule{
+		"CLA.001": HeuristicRules["CLA.001"], // L4
+		"ALI.001": HeuristicRules["ALI.001"], // L0
+	}
+	var findings []Finding
+	findings = append(findings, NewFindings(suggest, "select * from film where id = 1", nil, "a.sql", 3)...)
+	findings = append(findings, NewFindings(suggest, "select * from film where id = 2", nil, "b.sql", 7)...)
+	findings = append(findings, NewFindings(map[string]Rule{"OK": HeuristicRules["OK"]}, "select 1", nil, "b.sql", 9)...)
+
+	// 指纹相同的同一条建议合并为一个 issue，L0 及 OK 不导出
+	issues := NewIssues(findings)
+	if len(issues) != 1 || issues[0].Item != "CLA.001" || len(issues[0].Locations) != 2 ||
+		issues[0].Locations[1] != (IssueLocation{File: "b.sql", Line: 7}) {
+		t.Errorf("issues got: %v", issues)
+	}
+	// 去重键与文件位置无关
+	moved := NewIssues(NewFindings(suggest, "select * from film where id = 3", nil, "c.sql", 1))
+	if len(moved) != 1 || moved[0].DedupKey != issues[0].DedupKey {
+		t.Errorf("dedup key should not change, got: %v", moved)
+	}
+
+	common.Config.IssueMinSeverity = "L5"
+	if issues = NewIssues(findings); len(issues) != 0 {
+		t.Errorf("issues below issue-min-severity should be ignored, got: %v", issues)
+	}
+	common.Config.IssueMinSeverity = orgMinSeverity
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatJira(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgProject := common.Config.JiraProject
+	common.Config.JiraProject = "DBA"
+	findings := NewFindings(map[string]Rule{"CLA.001": HeuristicRules["CLA.001"]},
+		"select * from film", []string{"`sakila`.`film`"}, "a.sql", 3)
+	var bulk jiraBulk
+	if err := json.Unmarshal([]byte(FormatJira(findings)), &bulk); err != nil {
+		t.Fatal(err)
+	}
+	if len(bulk.IssueUpdates) != 1 {
+		t.Fatalf("jira got: %v", bulk)
+	}
+	fields := bulk.IssueUpdates[0].Fields
+	key := issueDedupKey(findings[0])
+	if fields.Project.Key != "DBA" || fields.IssueType.Name != "Task" || fields.Priority.Name != "Medium" ||
+		!strings.HasPrefix(fields.Summary, "[soar] CLA.001 ") ||
+		!strings.Contains(fields.Description, "{code:sql}\nselect * from film\n{code}") ||
+		!strings.Contains(fields.Description, "* a.sql:3") ||
+		len(fields.Labels) != 3 || fields.Labels[2] != "soar-"+key {
+		t.Errorf("jira fields got: %v", fields)
+	}
+
+	// 配置了 owner-file 时按团队添加标签
+	orgRules := ownerRules
+	ownerRules, _ = parseOwnerRules("^sakila\\. team-dba")
+	findings = NewFindings(map[string]Rule{"CLA.001": HeuristicRules["CLA.001"]},
+		"select * from film", []string{"`sakila`.`film`"}, "a.sql", 3)
+	bulk = jiraBulk{}
+	if err := json.Unmarshal([]byte(FormatJira(findings)), &bulk); err != nil {
+		t.Fatal(err)
+	}
+	fields = bulk.IssueUpdates[0].Fields
+	if len(fields.Labels) != 4 || fields.Labels[3] != "owner-team-dba" || !strings.Contains(fields.Description, "*Owner:* team-dba") {
+		t.Errorf("jira owner label got: %v", fields)
+	}
+	ownerRules = orgRules
+	common.Config.JiraProject = orgProject
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
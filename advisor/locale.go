@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/XiaoMi/soar/common"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RuleText 评审规则中随语言变化的文本
+type RuleText struct {
+	Summary string `yaml:"summary"`
+	Content string `yaml:"content"`
+}
+
+// RuleLocales 各语言的评审规则文本，key 为 -lang 支持的语言
+var RuleLocales = map[string]map[string]RuleText{
+	"en":    ruleTextEN,
+	"zh-CN": ruleTextZhCN,
+}
+
+// MessageLocales 各语言的规则补充说明，规则函数根据 SQL 及线上环境生成的内容追加在 Content 后，key 为消息名
+// 内容为 text/template 模板，缺少翻译时使用英文
+var MessageLocales = map[string]map[string]string{
+	"en":    messageTextEN,
+	"zh-CN": messageTextZhCN,
+}
+
+// messageTemplates 当前语言的规则补充说明模板，由 LoadRuleLocale 设置
+var messageTemplates = make(map[string]*template.Template)
+
+// ruleTemplates 规则 Content 中的模板，在输出报告时使用当前配置渲染
+// 如 {{.Threshold}} 为该规则的阈值，{{.MaxInCount}} 等为配置项，{{join .AllowEngines ","}} 拼接列表配置
+var ruleTemplates = make(map[string]*template.Template)
+
+// ruleTemplateData 渲染规则模板时可以使用的数据
+type ruleTemplateData struct {
+	*common.Configuration
+	Threshold int
+	MariaDB   bool // -target 指定的目标数据库为 MariaDB
+}
+
+// LoadRuleLocale 使用指定语言的文本更新 HeuristicRules 的 Summary 和 Content
+// file 为用户自定义的 YAML 文件，可以按 Item 覆盖部分规则的文本，未覆盖的规则使用内置文本，如:
+//
+//	ARG.003:
+//	  summary: 隐式类型转换
+//	  content: 请联系 DBA 确认字段类型
+func LoadRuleLocale(lang, file string) error {
+	texts, ok := RuleLocales[matchLang(lang)]
+	if !ok {
+		var langs []string
+		for l := range RuleLocales {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		return fmt.Errorf("lang '%s' not support, available: %s", lang, strings.Join(langs, ", "))
+	}
+
+	custom := make(map[string]RuleText)
+	if file != "" {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = yaml.Unmarshal(buf, &custom)
+		if err != nil {
+			return fmt.Errorf("lang-file %s: %v", file, err)
+		}
+		for item := range custom {
+			if _, ok := HeuristicRules[item]; !ok {
+				common.Log.Warning("LoadRuleLocale: rule %s in %s not found", item, file)
+			}
+		}
+	}
+
+	templates := make(map[string]*template.Template)
+	for item, rule := range HeuristicRules {
+		text, ok := texts[item]
+		if !ok {
+			// 未翻译的规则使用英文文本
+			text = ruleTextEN[item]
+		}
+		if c, ok := custom[item]; ok {
+			if c.Summary != "" {
+				text.Summary = c.Summary
+			}
+			if c.Content != "" {
+				text.Content = c.Content
+			}
+		}
+		if strings.Contains(text.Content, "{{") {
+			tmpl, err := template.New(item).Funcs(template.FuncMap{"join": strings.Join}).Parse(text.Content)
+			if err != nil {
+				return fmt.Errorf("rule %s content template error: %v", item, err)
+			}
+			templates[item] = tmpl
+		}
+		rule.Summary = text.Summary
+		rule.Content = text.Content
+		HeuristicRules[item] = rule
+	}
+	ruleTemplates = templates
+
+	messages := make(map[string]*template.Template)
+	for name, text := range messageTextEN {
+		if t, ok := MessageLocales[matchLang(lang)][name]; ok {
+			text = t
+		}
+		tmpl, err := template.New(name).Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+		if err != nil {
+			return fmt.Errorf("message %s template error: %v", name, err)
+		}
+		messages[name] = tmpl
+	}
+	messageTemplates = messages
+	return nil
+}
+
+// ruleMessage 使用当前语言渲染规则的补充说明，data 为模板中使用的数据
+func ruleMessage(name string, data interface{}) string {
+	tmpl, ok := messageTemplates[name]
+	if !ok {
+		common.Log.Error("ruleMessage %s not found", name)
+		return ""
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		common.Log.Error("ruleMessage %s Error: %v", name, err)
+	}
+	return buf.String()
+}
+
+// renderRule 使用当前配置渲染规则 Content 中的模板
+// 规则函数可能在 Content 后追加内容，只渲染 Content 开头的模板部分，追加的内容不会被当作模板执行
+func renderRule(rule Rule) Rule {
+	tmpl, ok := ruleTemplates[rule.Item]
+	if !ok {
+		return rule
+	}
+	source := HeuristicRules[rule.Item].Content
+	if !strings.HasPrefix(rule.Content, source) {
+		return rule
+	}
+	var buf strings.Builder
+	err := tmpl.Execute(&buf, ruleTemplateData{
+		Configuration: common.Config,
+		Threshold:     RuleThreshold(rule.Item),
+		MariaDB:       common.TargetDB().IsMariaDB(),
+	})
+	if err != nil {
+		common.Log.Error("renderRule %s Error: %v", rule.Item, err)
+		return rule
+	}
+	rule.Content = buf.String() + strings.TrimPrefix(rule.Content, source)
+	return rule
+}
+
+// matchLang 忽略大小写及 '-', '_' 的差异匹配支持的语言，如 zh, zh_CN, zh-cn 均匹配 zh-CN
+func matchLang(lang string) string {
+	lang = strings.Replace(lang, "_", "-", -1)
+	for l := range RuleLocales {
+		if strings.EqualFold(l, lang) {
+			return l
+		}
+	}
+	for l := range RuleLocales {
+		if strings.EqualFold(strings.Split(l, "-")[0], strings.Split(lang, "-")[0]) {
+			return l
+		}
+	}
+	return lang
+}
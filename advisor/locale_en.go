@@ -0,0 +1,819 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+// ruleTextEN 英文评审规则文本
+var ruleTextEN = map[string]RuleText{
+	"OK": {
+		Summary: "OK",
+		Content: `OK`,
+	},
+	"ALI.001": {
+		Summary: "It is recommended to use the AS keyword to display an alias.",
+		Content: `In a column or table alias (such as "tbl AS alias"), explicitly using the AS keyword is easier to understand than an implicit alias (such as "tbl alias").`,
+	},
+	"ALI.002": {
+		Summary: "Setting aliases for column wildcard '*' is not recommended",
+		Content: `Example: "SELECT tbl.* col1, col2" The above SQL has an alias for the column wildcard, so SQL may have a logic error. You might want to query col1, but instead of renaming it is the last column of tbl.`,
+	},
+	"ALT.001": {
+		Summary: "Changing the default charset of a table does not change the charset of its columns",
+		Content: `Many people assume ALTER TABLE tbl_name [DEFAULT] CHARACTER SET 'UTF8' changes the charset of all columns, but it only affects columns added afterwards and leaves the charset of existing columns unchanged. To change the charset of all columns in the table use ALTER TABLE tbl_name CONVERT TO CHARACTER SET charset_name;`,
+	},
+	"ALT.002": {
+		Summary: "ALTER table with more than one article of recommendation together as a request",
+		Content: `Every table structure changes have an impact on the online service will even be able to be adjusted by the number of online tools Please try as much as possible to reduce the operation requested by merging ALTER. When merging, DROP clauses go first and clauses that support INSTANT are executed separately from clauses that rebuild the table.`,
+	},
+	"ALT.003": {
+		Summary: "Delete classified as high-risk operation, whether before operating Remember to check the business logic as well as dependence",
+		Content: `Such as business logic relies not completely eliminate, the row is deleted may result in data can not be written or are unable to locate the deleted column data lead to abnormal program. In this case the user will be lost even if the data write requested backup data rewind.`,
+	},
+	"ALT.004": {
+		Summary: "Primary and foreign keys remove high-risk operations, verify operation before impact with the DBA",
+		Content: `Primary keys and foreign keys to a relational database two important constraints, remove the existing constraints will break the existing business logic, business development, please confirm before the operation and impact of DBA, think twice.`,
+	},
+	"ALT.005": {
+		Summary: "ALTER on a frequently accessed table may cause a metadata lock pileup",
+		Content: `ALTER needs an exclusive metadata lock on the table when it starts and when it finishes. If an uncommitted transaction or a running slow query accesses the table, ALTER keeps waiting, and every later request on the table is blocked behind it, which can exhaust connections quickly. Run it at off-peak hours, check and end long transactions first, set a small lock_wait_timeout in the session so that the ALTER fails and retries instead of waiting, or use tools such as pt-online-schema-change and gh-ost.`,
+	},
+	"ARG.001": {
+		Summary: "Not recommended for use in the preceding paragraph wildcards to find",
+		Content: `For example, "% foo", the query parameter has a wildcard in the case of the preceding paragraph can not use an existing index.`,
+	},
+	"ARG.002": {
+		Summary: "No wildcard LIKE query",
+		Content: `It does not contain a wildcard LIKE query logic errors may exist, because it is logically equivalent to the same query.`,
+	},
+	"ARG.003": {
+		Summary: "Compare parameter contains an implicit conversion, you can not use the index",
+		Content: "Implicit type conversion risk index can not hit, the consequences under high concurrency, large amount of data, the life is not in the index caused very serious.",
+	},
+	"ARG.004": {
+		Summary: "IN (NULL)/NOT IN (NULL) Non-true forever",
+		Content: "Correct approach is col IN ('val1', 'val2', 'val3') OR col IS NULL",
+	},
+	"ARG.005": {
+		Summary: "IN To be used with caution, elements too much can cause a full table scan",
+		Content: `Such as: select id from t where num in (1,2,3) for successive values ​​BETWEEN can not use the IN: select id from t where num between 1 and 3. When too much value IN MySQL may also enter a full table scan led to a sharp decline in performance. The number of IN elements should not exceed {{.Threshold}}.`,
+	},
+	"ARG.006": {
+		Summary: "Fields should be avoided to a NULL value is determined in the WHERE clause",
+		Content: `Use IS NULL or IS NOT NULL likely to cause the engine to give up using the index and full table scan, such as: select id from t where num is null; may set the default value of 0 on the num, ensuring table num column is not a NULL value, then so that the query: select id from t where num = 0;`,
+	},
+	"ARG.007": {
+		Summary: "Avoid using pattern matching",
+		Content: `The biggest drawback is the performance problems using pattern matching operator. LIKE or use a regular expression pattern matching queries Another issue is likely to return unexpected results. The best solution is to use special search engine technology to replace SQL, such as Apache Lucene. Another option is to save the results up thereby reducing duplication of search overhead. If you must use SQL, consider using third-party extensions like FULLTEXT index in MySQL. But more broadly, you do not have to use SQL to solve all the problems.`,
+	},
+	"ARG.008": {
+		Summary: "Try to use when OR IN predicate query the index column",
+		Content: `IN-list predicates can be used for index search, and the optimizer can sort the IN-list, to match the ordered sequence index, so as to obtain a more efficient retrieval. Note, IN-list must contain only constant, or kept at constant values ​​during the execution of a query block, e.g. external reference.`,
+	},
+	"ARG.009": {
+		Summary: "Beginning or end of a string of quotes contain spaces",
+		Content: `If the presence of the front and rear spaces VARCHAR column logic may cause problems, such as MySQL 5.5 in 'a' and 'a' may be considered in the query is the same value.`,
+	},
+	"ARG.010": {
+		Summary: "Do not use a hint, such as: sql_no_cache, force index, ignore key, straight join, etc.",
+		Content: `SQL is used to force the hint to be executed in an execution plan, but with the change in the amount of data we can not guarantee that the original pre-judgment is correct.`,
+	},
+	"ARG.011": {
+		Summary: "Do not use the negative to the query, such as: NOT IN / NOT LIKE",
+		Content: `Please try not to use negative to a query, which will result in a full table scan, a greater impact on query performance.`,
+	},
+	"ARG.012": {
+		Summary: "Too much data disposable INSERT / REPLACE of",
+		Content: "Single INSERT / REPLACE statement large quantities of data inserted poor performance, and may even lead to synchronization delay from the library. To improve the performance, reduce the quantities of the write data from the database affect the synchronization delay, the proposed method of inserting batches. A single statement should not write more than {{.Threshold}} rows.",
+	},
+	"ARG.013": {
+		Summary: "DDL Statements using the Chinese full-width quotes",
+		Content: "DDL Statements using the Chinese full-width quotes '' or '', which may be clerical errors, make sure that in line with expectations.",
+	},
+	"ARG.014": {
+		Summary: "Character set or collation of compared columns does not match",
+		Content: "Joining or comparing columns with different character sets or collations (e.g. utf8 VS utf8mb4) makes MySQL convert one side, so the index on that column can not be used. Unify the character set and collation of both columns:",
+	},
+	"CKH.001": {
+		Summary: "SELECT * on wide MergeTree table",
+		Content: `Column-oriented MergeTree tables store every column separately, SELECT * on a wide table has to read and decompress all the columns even though the query needs only a few of them. List the columns explicitly.`,
+	},
+	"CKH.002": {
+		Summary: "Consider PREWHERE for selective filters",
+		Content: `PREWHERE reads only the columns of the filter first and reads the other columns of the matched rows afterwards. ClickHouse moves suitable WHERE conditions to PREWHERE automatically, but not when FINAL is used or the query joins several tables. Move the selective conditions on small columns to PREWHERE explicitly.`,
+	},
+	"CKH.003": {
+		Summary: "Filter does not use the prefix of the MergeTree sorting key",
+		Content: `The sparse primary index of MergeTree is ordered by the sorting key. A filter on the latter columns of the key without the first column can hardly skip any granule and the query reads most of the table. Add a condition on the leading key column or reconsider the order of the ORDER BY key.`,
+	},
+	"CKH.004": {
+		Summary: "Avoid FINAL in queries",
+		Content: `FINAL merges the data of ReplacingMergeTree, CollapsingMergeTree and similar engines at query time, the query becomes much slower and uses more memory. Deduplicate with argMax() and GROUP BY, or design the table so that the final state is not required at query time.`,
+	},
+	"CLA.001": {
+		Summary: "Outermost SELECT WHERE condition is not specified",
+		Content: `SELECT statement has no WHERE clause, you may check more than expected lines (full table scan). For SELECT COUNT (*) If the type of request is not required accuracy, it is recommended to use alternative EXPLAIN or SHOW TABLE STATUS.`,
+	},
+	"CLA.002": {
+		Summary: "Not recommended for use ORDER BY RAND ()",
+		Content: `ORDER BY RAND () to retrieve a stochastic concentration is a very inefficient method of rows from the results, since it would result entire sort and discard most of its data.`,
+	},
+	"CLA.003": {
+		Summary: "Not recommended for use with the LIMIT OFFSET query",
+		Content: `LIMIT and OFFSET using the result set page complexity is O (n ^ 2), and will increase as the data lead to performance problems. A "bookmark" method of scanning for higher pagination efficiency.`,
+	},
+	"CLA.004": {
+		Summary: "Not recommended for constants GROUP BY",
+		Content: `GROUP BY GROUP BY representation. 1 in a first column. If the GROUP BY clause using digital rather than an expression or column name, column order when changing a query, it can cause problems.`,
+	},
+	"CLA.005": {
+		Summary: "No sense constant ORDER BY column",
+		Content: `There may be errors on SQL logic; at best a useless operation, does not change the results.`,
+	},
+	"CLA.006": {
+		Summary: "GROUP BY or ORDER BY on different tables",
+		Content: `This will force the use of temporary tables and filesort, which may have significant performance problems, and can consume large amounts of memory and temporary space on the disk.`,
+	},
+	"CLA.007": {
+		Summary: "ORDER BY statement uses a different direction for a plurality of different conditions can not be used to sort the index",
+		Content: `ORDER BY clause must be sorted by all expressions of unity ASC or DESC directions for use of the index.`,
+	},
+	"CLA.008": {
+		Summary: "Show me add conditions for the GROUP BY ORDER BY",
+		Content: `MySQL will default 'GROUP BY col1, col2, ...' requested sort 'ORDER BY col1, col2, ...' in the following order. If the GROUP BY ORDER BY statement does not specify the condition can lead to unnecessary sorting produce, if not the sort proposed to add 'ORDER BY NULL'.`,
+	},
+	"CLA.009": {
+		Summary: "ORDER BY conditions for expression",
+		Content: `When the condition is ORDER BY expression or function to use a temporary table, if the result is not specified in the WHERE WHERE condition or return set is large performance will be poor.`,
+	},
+	"CLA.010": {
+		Summary: "GROUP BY conditions for expression",
+		Content: `When GROUP BY condition expression or function is to use a temporary table, if the result is not specified in the WHERE WHERE condition or return set is large performance will be poor.`,
+	},
+	"CLA.011": {
+		Summary: "Recommend add comments to the table",
+		Content: `Add a comment for the table can make a clearer sense of the table, which brings great convenience for future maintenance.`,
+	},
+	"CLA.012": {
+		Summary: "The complex bindings type a query into several simple queries",
+		Content: `SQL is a very expressive language, you can query in a single SQL statement or a single to complete a lot of things. But this does not mean that only one line of code to be mandatory, or that one line of code to get each task is a good idea. To get all the results of the query by a common consequence has been a Cartesian product. When there is no condition between two tables in a query limit their relationship, this situation occurs. There is no corresponding restriction table used directly coupling two queries, each line will get a combination of each row in the first table and the second table. Each of these combinations will become a row of the result set, eventually you'll get the number of a lot of rows in the result set. It is important to consider these queries difficult to write, difficult to modify and difficult to debug. Increasing database query request should be expected to do. Managers who want more sophisticated reports and add more fields in the user interface. If your design is very complex, and is a single query, to extend them will be very time consuming. Regardless of your project or, the time spent on these things above, not worth it. The complex spaghetti query into several simple queries. When you split a complex SQL query, the result may be that many similar queries may only differ in data type. Write all these queries can be tedious, so it is best to have a program to automatically generate the code. SQL code generation is a very good application. Although SQL supports solving complex problems with a single line of code, but do not do unrealistic things.`,
+	},
+	"CLA.013": {
+		Summary: "HAVING clause is not recommended",
+		Content: `HAVING clause of the query rewrite the query WHERE clause, you can use the index during query processing.`,
+	},
+	"CLA.014": {
+		Summary: "Recommended alternative TRUNCATE DELETE When you delete a whole table",
+		Content: `Recommended alternative TRUNCATE DELETE When you delete a whole table`,
+	},
+	"CLA.015": {
+		Summary: "UPDATE WHERE condition is not specified",
+		Content: `UPDATE WHERE condition is not specified, usually fatal, please think twice`,
+	},
+	"CLA.016": {
+		Summary: "Do not UPDATE the primary key",
+		Content: `A primary key is a unique identifier for the data records in the table is not recommended to frequently update the primary key column, which will affect the metadata information thereby affecting the normal statistical queries.`,
+	},
+	"CLU.001": {
+		Summary: "Tables in a cluster must have a primary key",
+		Content: `Group Replication and Galera rely on the primary key to detect write conflicts between nodes. Group Replication rejects writes to tables without a primary key, and on Galera every UPDATE or DELETE on such a table is applied with a full table scan on the other nodes and may leave the nodes inconsistent. Add a primary key to the table.`,
+	},
+	"CLU.002": {
+		Summary: "Avoid large transactions in a cluster",
+		Content: `The cluster replicates and certifies the whole write set of a transaction at commit time. Large transactions cause replication lag and high memory usage, and a transaction exceeding the size limit of the cluster is rolled back. Split the change into batches by primary key range.`,
+	},
+	"CLU.003": {
+		Summary: "Avoid LOCK TABLES and GET_LOCK() in a cluster",
+		Content: `Locks taken by LOCK TABLES and GET_LOCK() only exist on the local node and are not replicated, they do not prevent concurrent writes from other nodes. Galera does not support LOCK TABLES, and table locks are not honored across nodes in Group Replication multi-primary mode either. Use transactions and SELECT ... FOR UPDATE instead.`,
+	},
+	"CLU.004": {
+		Summary: "Use only the InnoDB storage engine in a cluster",
+		Content: `Group Replication and Galera only replicate data in InnoDB tables. Tables using MyISAM or other storage engines cannot be replicated reliably and writing to them leaves the nodes inconsistent, Group Replication rejects such writes. Convert the table to InnoDB.`,
+	},
+	"COL.001": {
+		Summary: "SELECT * queries are not recommended",
+		Content: `When the table structure changes, using the * wildcard to select all columns will lead to meaning and behavior changes when the query, the query returns may result in more data.`,
+	},
+	"COL.002": {
+		Summary: "INSERT/REPLACE does not specify column names",
+		Content: `When the table structure is changed, if the INSERT or REPLACE request does not explicitly specify the column name, a request will be different than intended; recommended "INSERT INTO tbl (col1, col2) VALUES ..." instead.`,
+	},
+	"COL.003": {
+		Summary: "It proposed to amend the increment ID unsigned type",
+		Content: `It proposed to amend the increment ID unsigned type`,
+	},
+	"COL.004": {
+		Summary: "Please add a default value for a column",
+		Content: `Please add default values ​​for the column, if it is ALTER operation, do not forget to write the original default value on the field. Field with no default, when a large table table structure can not be changed online.`,
+	},
+	"COL.005": {
+		Summary: "Column does not add comments",
+		Content: `We recommend add comments for each column in the table, to clarify the meaning and role of each column in the table.`,
+	},
+	"COL.006": {
+		Summary: "Table contains too many columns",
+		Content: "Table contains more than {{.Threshold}} columns",
+	},
+	"COL.007": {
+		Summary: "Table contains too much text / blob column",
+		Content: `Table contains more than {{.Threshold}} text / blob columns`,
+	},
+	"COL.008": {
+		Summary: "May be used instead of VARCHAR CHAR, VARBINARY place BINARY",
+		Content: `First, variable-length field is a small storage space, you can save storage space. Followed by the query, in a relatively small field of search efficiency is clearly higher.`,
+	},
+	"COL.009": {
+		Summary: "We recommend the use of precise data type",
+		Content: `In fact, any use FLOAT, REAL, or DOUBLE PRECISION data type of design are likely to be anti-pattern. Most applications use the range of floating-point does not need to reach the maximum / minimum interval defined by the IEEE 754 standard. In calculating the total impact of non-precision floating-point number accumulated serious. The use SQL NUMERIC or DECIMAL FLOAT type and the like instead of the type of data stored in fixed decimal precision. These data types to store data accurately specified when you define the accuracy of this column. Do not use floating-point numbers as possible.`,
+	},
+	"COL.010": {
+		Summary: "We do not recommend the use of ENUM data types",
+		Content: `ENUM defines the type of values ​​in a column, use the value in the ENUM string representation, the data is actually stored in the column ordinal number of them in the definition. Thus, this column data is byte-aligned, when you make a sorting query, the result is stored in accordance with the ordinal value of the actual sorting, rather than alphabetically sorted string of values. This may not be what you want. There's nothing to add or remove a syntax supports value from ENUM or check constraint; you can only use a new set of redefining this column. If you plan to discard an option, you may worry for the historical data. As a strategy, change metadata - that is, change the definition of tables and columns - should be infrequent, and pay attention to testing and quality assurance. There is a better solution to the constraints of an optional value: Create a checklist, with each row containing a candidate appear in the column are allowed; then declare a foreign key constraint on the old table references the new table.`,
+	},
+	"COL.011": {
+		Summary: "The only constraint when needed to use NULL, not only when there are missing values ​​using a column NOT NULL",
+		Content: `NULL and 0 are different, multiplied by 10 NULL or NULL. NULL and empty string is not the same. The standard SQL and a string of NULL unite the result was NULL. NULL and FALSE are different. AND, OR and NOT Boolean operators if it involves three NULL, the result is also a lot of people confused. When you declare a NOT NULL, meaning that for every value in this column must exist and be meaningful. Null value to indicate a NULL does not exist any type. When you declare a NOT NULL, meaning that for every value in this column must exist and be meaningful.`,
+	},
+	"COL.012": {
+		Summary: "BLOB and TEXT types of fields is not recommended to NOT NULL",
+		Content: `BLOB and TEXT types of fields can not specify a non-NULL default value, if you add a NOT NULL restriction, write time and not likely to lead to a write failure to specify the value of the field.`,
+	},
+	"COL.013": {
+		Summary: "TIMESTAMP Type Default abnormalities",
+		Content: `TIMESTAMP type is recommended to set the default values, and do not recommend using 0 as a default value or 0000-00-00 00:00:00. Consider using 1970-08-02 01:01:01`,
+	},
+	"COL.014": {
+		Summary: "Specified for the column character set",
+		Content: `Recommended columns and tables use the same character set, do not specify the character set column alone.`,
+	},
+	"COL.015": {
+		Summary: "TEXT and BLOB fields not specify the type of non-NULL defaults",
+		Content: `TEXT MySQL database and BLOB fields not specify the type of non-NULL default value. TEXT maximum length of 2 ^ 16-1 characters, MEDIUMTEXT maximum length of 2 ^ 32-1 characters, LONGTEXT maximum length of 2 ^ 64-1 characters.`,
+	},
+	"COL.016": {
+		Summary: "Integer defined recommended INT (10) or BIGINT (20)",
+		Content: `INT (M) in the integer data type, M represents the maximum width of the display. In INT (M), M values ​​with INT (M) percentage how much storage space does not have any relationship. INT (3), INT (4), INT (8) on a disk are occupied by 4 bytes of storage space. High version of MySQL has not recommended to set the display width of an integer.`,
+	},
+	"COL.017": {
+		Summary: "VARCHAR defined too long",
+		Content: `varchar Variable length strings, not pre-allocated storage space, a length not more than {{.Threshold}}, if the memory length is too long, MySQL will define field type text, an independent list, with the corresponding primary key, to avoid affecting the efficiency index of other fields.`,
+	},
+	"COL.018": {
+		Summary: "Construction of the table statement does not recommend the use of field types",
+		Content: `The following field types are not recommended: {{join .ColumnNotAllowType ","}}`,
+	},
+	"COL.019": {
+		Summary: "Time data is not recommended in the second stage of use of the following types of precision",
+		Content: "Bring high-precision data type storage time is relatively large space consumption; the MySQL can support accurate to the microsecond time data types 5.6.4 above, need to be considered when using the version compatibility problems.",
+	},
+	"COL.020": {
+		Summary: "AUTO_INCREMENT value is close to the maximum of the column type",
+		Content: `Once the AUTO_INCREMENT value reaches the maximum of the column type, every INSERT fails with a duplicate key error. Widen the column before it is exhausted:`,
+	},
+	"COL.021": {
+		Summary: "Row size is close to the MySQL or InnoDB limit",
+		Content: "A MySQL row may use at most 65535 bytes excluding BLOB/TEXT contents, and an InnoDB row stores at most about 8126 bytes in a 16K page, longer variable-length columns are stored in overflow pages. The maximum row size below is estimated from the column types and character sets.",
+	},
+	"CTE.001": {
+		Summary: "Non-recursive CTE is referenced multiple times",
+		Content: "MySQL 8.0 materializes a CTE referenced more than once a single time and cannot merge it into the outer query like a CTE referenced once, before 8.0.22 outer conditions are not pushed down into it either, so the materialized temporary table may be large. MariaDB merges or materializes each reference separately, so the CTE is executed several times. Please check the execution plan and filter early inside the CTE if needed.",
+	},
+	"CTE.002": {
+		Summary: "Recursive CTE has no termination condition or recurses deeper than cte_max_recursion_depth",
+		Content: "The recursive part of a recursive CTE needs a WHERE or LIMIT to stop the recursion, otherwise MySQL fails when the recursion reaches cte_max_recursion_depth (1000 by default) and MariaDB keeps running until max_recursive_iterations. A termination condition allowing more iterations than cte_max_recursion_depth also fails, please adjust the condition or raise the variable in the session.",
+	},
+	"CTE.003": {
+		Summary: "Target database does not support CTE, rewrite it as a derived table",
+		Content: "MySQL before 8.0 and MariaDB before 10.2 do not support the WITH clause, non-recursive CTEs can be rewritten as derived tables.",
+	},
+	"DIS.001": {
+		Summary: "Eliminating unnecessary DISTINCT conditions",
+		Content: `Too many DISTINCT condition is a symptom complex bindings type queries. Consider creating complex queries into a number of simple queries and reduce the number DISTINCT conditions. If the primary key column is part of the result set for the column, the DISTINCT may have no effect.`,
+	},
+	"DIS.002": {
+		Summary: "When the multi-column results COUNT (DISTINCT) may differ from what you want it",
+		Content: `COUNT (DISTINCT col) calculate the number of rows do not overlap other than the NULL column, note COUNT (DISTINCT col, col2) If a NULL is full even if the other row have different values, it returns 0.`,
+	},
+	"DIS.003": {
+		Summary: "DISTINCT * is meaningless for tables with a primary key",
+		Content: `When the table has a primary key, it outputs the result DISTINCT results for all columns DISTINCT not operate the same, do not superfluous.`,
+	},
+	"FED.001": {
+		Summary: "Query on FEDERATED, CONNECT or SPIDER remote tables",
+		Content: `The data of remote tables is stored on another instance. Only part of the WHERE conditions can be pushed down to the remote server, function expressions, ORDER BY, GROUP BY and LIMIT are usually processed locally, which may read the whole remote table over the network. The rows in EXPLAIN and the local index suggestions do not reflect the actual execution on the remote server, please analyze the execution plan and add indexes on the remote instance.`,
+	},
+	"FED.002": {
+		Summary: "Remote table in JOIN",
+		Content: `When remote tables are joined with other tables the JOIN is executed locally. If the remote table is the driven table, a remote query is sent for every row of the driving table, so the network round trips are amplified by the rows of the driving table. Joins between remote tables can not be executed on the remote server either. Please put the joined tables on the same instance, or read the filtered remote data into a local temporary table before joining.`,
+	},
+	"FED.003": {
+		Summary: "Modify data of remote tables",
+		Content: `UPDATE and DELETE on remote tables read the matching rows from the remote server first, then send a modification statement for each row, the network round trips are proportional to the affected rows. FEDERATED and CONNECT do not support transactions, finished modifications can not be rolled back if the statement is interrupted. Please execute batch modifications on the remote instance directly.`,
+	},
+	"FUN.001": {
+		Summary: "Avoid the use of other operators in the WHERE condition",
+		Content: `Although the use of functions in SQL can simplify many complex queries, but use the query function can not use the index table has been established, the query will be poor full table scan performance. It is always advisable to write the name of the column to the left of comparison operators, comparison operators will query filter condition on the right side. Do not recommend writing on both sides of the extra brackets if the query conditions, which have a relatively large reading problems.`,
+	},
+	"FUN.002": {
+		Summary: "COUNT is specified using the WHERE conditions or non-MyISAM engine (*) poor operating performance",
+		Content: `Role COUNT (*) is the number of tables lines, the role COUNT (COL) is a statistical specified number of lines of non-NULL columns. For MyISAM tables COUNT (*) counts the number of rows whole table has been specially optimized Under normal circumstances very quickly. But for the non-MyISAM table or specify a certain WHERE conditions, COUNT (*) operation requires a large number of rows to scan in order to obtain accurate results, and therefore poor performance. Sometimes some service scenarios do not require full accuracy COUNT values, an approximation can be replaced at this time. EXPLAIN out the number of rows the optimizer estimates is a good approximation, the implementation of EXPLAIN does not really need to execute the query, so the cost is very low.`,
+	},
+	"FUN.003": {
+		Summary: "The combined use of a column to be an empty string is connected",
+		Content: `In some queries, you need to force a column or an expression returns non-NULL value, so that the query logic easier, but do not want to survive this value. You can use the COALESCE () function to construct an expression connected, so that even a null value does not cause the entire column expression becomes NULL.`,
+	},
+	"FUN.004": {
+		Summary: "Not recommended SYSDATE () function",
+		Content: `SYSDATE () function may result in inconsistent data from the master, use NOW () function instead SYSDATE ().`,
+	},
+	"FUN.005": {
+		Summary: "Not recommended for use COUNT (col) or COUNT (constant)",
+		Content: `Do not use COUNT (col) or COUNT (constant) to replace the COUNT (*), COUNT (*) is the standard statistical method the number of rows SQL92 definition, has nothing to do with the data, with NULL and non-NULL has nothing to do.`,
+	},
+	"FUN.006": {
+		Summary: "NPE should pay attention to the problem when using the SUM (COL)",
+		Content: `NPE should pay attention to a problem when the value of the whole column is NULL, COUNT (COL) returns a value of 0, the SUM (COL) returns a value of NULL, and therefore use SUM (). May be used in the following manner to avoid the problem of SUM NPE: SELECT IF (ISNULL (SUM (COL)), 0, SUM (COL)) FROM tbl`,
+	},
+	"FUN.007": {
+		Summary: "Not recommended for use triggers",
+		Content: `Execution of a trigger and without feedback logs, hides the actual implementation of the steps, when the database problem is that the specific implementation can not slow log analysis trigger, difficult to find the problem. In MySQL, the trigger can not be temporarily closed or open, migration or data recovery scenario in the data, you need to trigger a temporary drop may affect the production environment.`,
+	},
+	"FUN.008": {
+		Summary: "We do not recommend the use of stored procedures",
+		Content: `No versioning stored procedures, stored procedures with the business of upgrading difficult to do business without perception. Stored Procedures are also problems in the development and migration.`,
+	},
+	"FUN.009": {
+		Summary: "We do not recommend the use of a custom function",
+		Content: `We do not recommend the use of a custom function`,
+	},
+	"GIS.001": {
+		Summary: "Specify SRID for spatial columns in MySQL 8.0",
+		Content: "A spatial column without an SRID attribute in MySQL 8.0 accepts values with any SRID, and the optimizer will not use SPATIAL indexes on it. Specify the SRID in the column definition, e.g. POINT NOT NULL SRID 4326.",
+	},
+	"GIS.002": {
+		Summary: "Spatial predicate on a column without SPATIAL index",
+		Content: "Spatial relation functions such as ST_Contains, ST_Within and MBRContains can only avoid a full table scan when the spatial column has a SPATIAL index. SPATIAL indexes require NOT NULL columns, and an SRID attribute in MySQL 8.0.",
+	},
+	"GIS.003": {
+		Summary: "Filtering by distance cannot use spatial indexes",
+		Content: "ST_Distance and ST_Distance_Sphere are computed for every row and cannot use SPATIAL indexes. Filter by the bounding box with MBRContains first, which can use the index, then filter by the exact distance.",
+	},
+	"GIS.004": {
+		Summary: "Avoid FLOAT or DOUBLE for latitude and longitude",
+		Content: "FLOAT only has about 7 significant digits, which means errors of meters for longitude, and floating point comparisons are inexact. Use DECIMAL(9,6) or DECIMAL(10,7) to store coordinates, or a POINT column with a SPATIAL index when querying by distance or area.",
+	},
+	"GIS.005": {
+		Summary: "Range on latitude and longitude cannot use both indexes",
+		Content: "Only the first of the two range conditions on latitude and longitude can use a B-Tree index, the other one is checked row by row. Store the coordinates in a POINT column with a SPATIAL index and query the rectangle with MBRContains.",
+	},
+	"GRP.001": {
+		Summary: "Not recommended for the equivalent GROUP BY query column",
+		Content: `GROUP BY columns used in the previous equivalent query WHERE condition, such a column GROUP BY little significance.`,
+	},
+	"JOI.001": {
+		Summary: "JOIN statement mix commas and ANSI mode",
+		Content: `Time-table joins and ANSI JOIN mix comma is not easy to understand humans, and the behavior of different versions of MySQL table joins and priorities are different, when the MySQL version change may introduce errors.`,
+	},
+	"JOI.002": {
+		Summary: "It is connected to the same table twice",
+		Content: `It appears at least twice in the same table in the FROM clause can be simplified to a single access to the table.`,
+	},
+	"JOI.003": {
+		Summary: "OUTER JOIN Fail",
+		Content: `Since such error OUTER JOIN WHERE condition table no external data is returned, it will be converted to an implicit query INNER JOIN. Such as: select c from L left join R using (c) where L.a = 5 and R.b = 10. It may exist on this SQL logic error or misunderstanding of the programmer how to work OUTER JOIN, because LEFT / RIGHT JOIN is LEFT / RIGHT OUTER JOIN acronym.`,
+	},
+	"JOI.004": {
+		Summary: "We do not recommend the use of exclusive JOIN",
+		Content: `Only the right side of the table is NULL WHERE clause LEFT OUTER JOIN statement, there may be used an error in the WHERE clause are listed, such as: "... FROM l LEFT OUTER JOIN r ON ll = rr WHERE rz IS NULL ", this query may be correct logic WHERE rr iS NULL.`,
+	},
+	"JOI.005": {
+		Summary: "JOIN reduce the number of",
+		Content: `Too many JOIN is a symptom complex bindings type queries. Consider creating complex queries into a number of simple queries and reduce the number of JOIN. The number of tables joined should not exceed {{.Threshold}}.`,
+	},
+	"JOI.006": {
+		Summary: "The nested query rewrite JOIN usually leads to more efficient and more effective implementation of optimization",
+		Content: `In general, for a non-nested subquery always correlated subquery, up from a table in the FROM clause, the query predicates for these sub ANY, ALL EXISTS and the. If, at most subqueries The semantics of the query returns a row determinant, then a subquery or unrelated to the FROM clause of a plurality of tables to be pressed flat.`,
+	},
+	"JOI.007": {
+		Summary: "It does not recommend the use of contingency tables delete or update",
+		Content: `Recommended when you need to delete or update multiple tables at the same time using a simple statement, a SQL only delete or update a table, try not to operate multiple tables in the same statement.`,
+	},
+	"JOI.008": {
+		Summary: "Do not use the JOIN query across databases",
+		Content: `In general, cross-database JOIN query means queries across two different subsystems, which may mean coupling system is too high or database table design unreasonable.`,
+	},
+	"JOI.009": {
+		Summary: "Cartesian product, tables are joined without any join condition",
+		Content: `There is no join condition between some of the tables in the query, every row of one table is combined with every row of the other, so the number of rows in the result set is the product of the table sizes and consumes a lot of CPU, memory and network resources as the tables grow. Please check whether a join condition is missing.`,
+	},
+	"KEY.001": {
+		Summary: "Since additional recommended as a primary key, used in combination as the primary key self-energizing self-energizing key set as the first column",
+		Content: `Since additional recommended as a primary key, used in combination as the primary key self-energizing self-energizing key set as the first column`,
+	},
+	"KEY.002": {
+		Summary: "No primary key or unique key, can not change the table structure online",
+		Content: `No primary key or unique key, can not change the table structure online`,
+	},
+	"KEY.003": {
+		Summary: "To avoid the recurrence relation of keys, etc.",
+		Content: `Data exists recursive relationship is very common, often like a tree or data hierarchically organized. However, creating a foreign key constraint to enforce the relationship between the two in the same table, it can lead to awkward queries. Each layer of the tree corresponds to the other connector. You will need to issue a recursive query to get all descendants or ancestors of all nodes. Solution is to construct a closure attached table. It records the relationships between all nodes in the tree, not just those with a direct parent-child relationship. You can also compare different levels of design data: Closures table, path enumeration, nested sets. Then select a required application.`,
+	},
+	"KEY.004": {
+		Summary: "Reminder: Please be aligned with the query sequence index properties",
+		Content: `If the column to create a composite index, make sure the order of queries and index properties property for DBMS using an index when processing queries. If the query and index attributes orders are not aligned, then the DBMS may not be able to use the index during query processing.`,
+	},
+	"KEY.005": {
+		Summary: "Table overindexing built",
+		Content: "Table has more than {{.Threshold}} indexes",
+	},
+	"KEY.006": {
+		Summary: "Excessive primary key column",
+		Content: "Primary key contains more than {{.Threshold}} columns",
+	},
+	"KEY.007": {
+		Summary: "Primary or primary key or a non-int Not specified bigint",
+		Content: `No primary or primary key or a non-int bigint, recommended to set the primary key or unsigned int bigint unsigned.`,
+	},
+	"KEY.008": {
+		Summary: "ORDER BY multiple columns, but not the sort direction at the same time may not use the index",
+		Content: `Before {{if .MariaDB}}MariaDB 10.8{{else}}MySQL 8.0{{end}} when ORDER BY multiple columns specified is not the same sort direction will not be able to use the index has been established.`,
+	},
+	"KEY.009": {
+		Summary: "Before adding a unique index Please note that the only checks data",
+		Content: `Please check ahead of time to add unique data unique index column, if not unique online data table structure adjustment will be possible to automatically delete duplicate columns, which may result in data loss.`,
+	},
+	"KEY.010": {
+		Summary: "Full-text index is not a silver bullet",
+		Content: `Full-text index is mainly used to solve the problem of fuzzy query performance, but need to control the frequency and degree of concurrency good query. At the same time pay attention to adjust ft_min_word_len, ft_max_word_len, ngram_token_size and other parameters.`,
+	},
+	"KEY.011": {
+		Summary: "Foreign key columns should be backed by an index",
+		Content: `The referencing columns of a foreign key must be the leftmost prefix of an index. Otherwise InnoDB silently creates an implicitly named index for it, which does not show up in the reviewed DDL and makes later index maintenance error-prone. MyISAM and other engines without foreign key support accept the definition but ignore it, so the constraint is not enforced there at all. Create the index explicitly:`,
+	},
+	"KEY.012": {
+		Summary: "Avoid random UUID or hash values as primary key",
+		Content: `InnoDB stores rows in primary key order. Random values such as UUID() or MD5/SHA hashes are inserted at random positions of the clustered index, causing frequent page splits, fragmentation and a much larger working set in the buffer pool; the long string key is also copied into every secondary index. {{if .MariaDB}}On MariaDB 10.7+ use the UUID data type which stores values in time order, on earlier versions use{{else}}Store UUIDs as BINARY(16) written with UUID_TO_BIN(UUID(), 1) (MySQL 8.0+, swaps the time parts so values are ordered, read back with BIN_TO_UUID(id, 1)), or use{{end}} an AUTO_INCREMENT surrogate primary key and keep the random value in a unique index. Foreign keys referencing the column have to be changed as well.`,
+	},
+	"KWR.001": {
+		Summary: "SQL_CALC_FOUND_ROWS low efficiency",
+		Content: `Because SQL_CALC_FOUND_ROWS not scale well, it may lead to performance issues; proposed business use other strategies to replace the counting function SQL_CALC_FOUND_ROWS offer, such as: paged results show and so on.`,
+	},
+	"KWR.002": {
+		Summary: "We do not recommend the use of MySQL keywords column name or table name",
+		Content: `When using the keyword as a column or table names in the program you need to table names and column names escape, if negligence was the cause request can not be performed.`,
+	},
+	"KWR.003": {
+		Summary: "We do not recommend the use of a complex table names or column names",
+		Content: `Table names should only represent an entity table of contents inside, should not represent the number of entities, DO corresponding to the class name is singular, idiomatic.`,
+	},
+	"KWR.004": {
+		Summary: "Not recommended to use multi-byte character encoding (Chinese) name",
+		Content: `For the library, tables, columns, recommend the use of English, numbers, underscores and other characters, does not recommend the use of Chinese or other multi-byte character encoding alias name.`,
+	},
+	"LCK.001": {
+		Summary: "INSERT INTO xx SELECT locking granularity greater caution",
+		Content: `INSERT INTO xx SELECT locking granularity greater caution`,
+	},
+	"LCK.002": {
+		Summary: "Use caution INSERT ON DUPLICATE KEY UPDATE",
+		Content: `Use INSERT ON DUPLICATE KEY UPDATE when the primary key is auto-increment primary keys keys may cause a large number of non-continuous rapid growth, the primary key can not continue to write quickly overflow. In extreme cases it may also lead to a master-slave data inconsistencies.`,
+	},
+	"LCK.003": {
+		Summary: "The WHERE condition of locking read can not use index",
+		Content: `SELECT ... FOR UPDATE and LOCK IN SHARE MODE lock every record scanned, and the gaps between records are also locked under the REPEATABLE READ isolation level. If the WHERE condition can not use an index, the whole table is locked and writes of other transactions are blocked. Please add a suitable index for the conditions of locking read.`,
+	},
+	"LCK.004": {
+		Summary: "The transaction of locking read is too long",
+		Content: `Row locks acquired by locking read are held until the transaction commits or rolls back. The more statements executed after the lock is acquired, the longer the lock is held and the more likely lock waits or even deadlocks happen. Place locking read as late as possible in the transaction, no more than {{.Threshold}} statements are recommended after it.`,
+	},
+	"LCK.005": {
+		Summary: "Use SKIP LOCKED or NOWAIT for queue-like locking read",
+		Content: `When jobs are fetched with SELECT ... ORDER BY ... LIMIT ... FOR UPDATE, consumers compete for the same records and later transactions have to wait for the lock. MySQL 8.0.1 and MariaDB 10.6 support FOR UPDATE SKIP LOCKED to skip locked records, NOWAIT can also be used to return an error immediately instead of waiting.`,
+	},
+	"LIT.001": {
+		Summary: "IP address with the character type storage",
+		Content: `It looks like a string literal IP address, but not INET_ATON () parameter indicates the character data is stored as an integer instead. The IP address is stored as an integer more effective.`,
+	},
+	"LIT.002": {
+		Summary: "Date / time is not used quotes",
+		Content: `Queries such as "WHERE col <2010-02-12" and the like are effective SQL, but it would be a mistake, because it will be interpreted as a "WHERE col <1996"; date / time text should be quoted.`,
+	},
+	"LIT.003": {
+		Summary: "Storing a series of data collection",
+		Content: `The ID is stored as a list, as VARCHAR / TEXT columns, this can cause performance and data integrity problems. Queries such a column requires the use of pattern matching expressions. Use a comma-separated list of multi-table join queries do locate a row of data is extremely elegant and time-consuming. This will make it more difficult to verify ID. Consider, for a list of how much data is stored up to support it? It will be a separate table, instead of using multi-value storage attribute ID, attribute value such that each individual row are occupied. Such cross table to achieve the many relationships between two tables. This will simplify the query better, more efficiently verify ID.`,
+	},
+	"LIT.004": {
+		Summary: "Please use a semicolon or the end DELIMITER set",
+		Content: `USE database, SHOW DATABASES commands also need to use a semicolon or the end DELIMITER has been set.`,
+	},
+	"MAT.001": {
+		Summary: "Derived table cannot be merged into the outer query and will be materialized",
+		Content: `A subquery in the FROM clause (derived table) or a common table expression in the WITH clause that contains aggregate functions, DISTINCT, GROUP BY, HAVING, LIMIT, UNION or subqueries in the select list cannot be merged into the outer query. MySQL materializes its result into a temporary table which only has the index automatically created for joining. Before MySQL 8.0.22 and MariaDB 10.2.2, conditions of the outer query are not pushed down into materialized derived tables. Move conditions that only reference columns of the derived table into it, or add indexes on the filter and grouping columns of its base tables to reduce the number of materialized rows.`,
+	},
+	"RES.001": {
+		Summary: "Non-deterministic GROUP BY",
+		Content: `SQL return neither column nor row aggregate function in GROUP BY expression, so the results of these values ​​will be non-deterministic. Such as: select a, b, c from tbl where foo = "bar" group by a, the result is returned by SQL indeterminate.`,
+	},
+	"RES.002": {
+		Summary: "Not use the LIMIT ORDER BY queries",
+		Content: `No ORDER BY LIMIT will lead to the non-deterministic results, depending on the query execution plan.`,
+	},
+	"RES.003": {
+		Summary: "UPDATE / DELETE operation conditions used LIMIT",
+		Content: `UPDATE / DELETE operations using LIMIT conditions and do not add WHERE conditions as dangerous as it can lead to a master-slave data will be inconsistent or synchronous interrupt from the library.`,
+	},
+	"RES.004": {
+		Summary: "UPDATE / DELETE operations specified conditions ORDER BY",
+		Content: `UPDATE / DELETE operations do not specify ORDER BY condition.`,
+	},
+	"RES.005": {
+		Summary: "UPDATE statement possible logic error, resulting in data corruption",
+		Content: "In an UPDATE statement, if you want to update multiple fields, between fields you can not use the AND, and should be separated by commas.",
+	},
+	"RES.006": {
+		Summary: "Never really compare conditions",
+		Content: "Query forever is not true, if the condition appears where the inquiry could lead to no matching results.",
+	},
+	"RES.007": {
+		Summary: "Always true comparison condition",
+		Content: "Query is always true, it could lead to failure of a full table WHERE condition queries.",
+	},
+	"RES.008": {
+		Summary: "Not recommended LOAD DATA / SELECT ... INTO OUTFILE",
+		Content: "SELECT INTO OUTFILE FILE need to grant permission, which will be introduced by security issues. LOAD DATA Although the rate of introduction of data can be improved, but also may result in an excessive delay from the database synchronization.",
+	},
+	"RES.009": {
+		Summary: "We do not recommend the use of continuous judgment",
+		Content: "Like this SELECT * FROM tbl WHERE col = col = 'abc' statement may be clerical error, meaning you might want to express col = 'abc'. If that is the business requirements and recommend changes to col = col and col = 'abc'.",
+	},
+	"RES.010": {
+		Summary: "Construction of the table statement is defined as the ON UPDATE CURRENT_TIMESTAMP fields contain the business logic is not recommended",
+		Content: "It is defined as the ON UPDATE CURRENT_TIMESTAMP fields modified when the linkage table updates other fields, if the business logic will be visible to the user lay hidden. If batch follow-up data but do not want to modify the changes will result in an error when the data field.",
+	},
+	"RES.011": {
+		Summary: "Comprising a table update request operation field ON UPDATE CURRENT_TIMESTAMP",
+		Content: "It is defined as the ON UPDATE CURRENT_TIMESTAMP fields modified when the linkage table updates other fields, check the note. The update time not want to modify the field can use the following method: UPDATE category SET name = 'ActioN', last_update = last_update WHERE category_id = 1",
+	},
+	"RES.012": {
+		Summary: "Select-list alias has the same name as a table column",
+		Content: "MySQL resolves an unqualified name in ORDER BY to the select-list alias first, while GROUP BY and HAVING look at the table columns first. When an alias has the same name as a column, ORDER BY sorts by the aliased expression but GROUP BY groups by the original column, which is easy to get wrong. Rename the alias or qualify the column with its table name.",
+	},
+	"RES.013": {
+		Summary: "GROUP BY or ORDER BY position is out of range",
+		Content: "The column position used in GROUP BY or ORDER BY is greater than the number of columns in the select list (or less than 1), the query will fail with Unknown column error. Positions also break silently when the select list changes, use column names instead.",
+	},
+	"RES.014": {
+		Summary: "Duplicate predicates in the same AND/OR condition",
+		Content: "The same predicate appears more than once in an AND or OR condition. It does not change the result but usually means a typo, for example the author meant to compare a different column or value.",
+	},
+	"RKS.001": {
+		Summary: "Descending order on ROCKSDB tables needs reverse scan",
+		Content: `MyRocks stores data in LSM, reverse iteration needs to merge SST files of multiple levels and skip deleted records, which is much slower than forward scan. Please put indexes frequently read in descending order into a reverse column family, e.g. KEY idx_user (user_id, id) COMMENT 'rev:cf_user', or read in ascending order.`,
+	},
+	"RKS.002": {
+		Summary: "Conditions on ROCKSDB tables can not use prefix bloom filter",
+		Content: `MyRocks uses bloom filters on index prefixes to skip SST files without the prefix. Only equality conditions can use the bloom filter, range-only conditions need to read the matching range of every level. Please put the columns of equality conditions first when designing indexes, and make sure the bloom filter prefix length (prefix_extractor) is not longer than the equality conditions cover.`,
+	},
+	"RKS.003": {
+		Summary: "Unique secondary index on ROCKSDB tables needs read before write",
+		Content: `MyRocks writes to non-unique secondary indexes without reading existing data, while unique secondary indexes need to read and lock records to check uniqueness before every write, which significantly decreases write performance. Please confirm whether the database needs to guarantee uniqueness, for tables with frequent writes consider guaranteeing uniqueness in the application and use normal indexes.`,
+	},
+	"SEC.001": {
+		Summary: "Please use caution TRUNCATE operation",
+		Content: `Generally want to empty the quickest approach is to use a table TRUNCATE TABLE tbl_name; statement. But TRUNCATE operation is not costless, TRUNCATE TABLE can not return the exact number of rows to be deleted, if you need to return the number of rows to be deleted recommended DELETE syntax. TRUNCATE operation also resets AUTO_INCREMENT, if not want to reset the value recommended DELETE FROM tbl_name WHERE 1; alternative. TRUNCATE operation will add the source data dictionary data latch (the MDL), when a table needs TRUNCATE affects many instances throughout all requests, so long DROP CREATE a manner to reduce lock To + TRUNCATE recommendations multiple tables.`,
+	},
+	"SEC.002": {
+		Summary: "Do not store passwords in plain text",
+		Content: `Use passwords stored in plain text or plain text passwords are insecure pass on the network. If an attacker can intercept the password you use to insert the SQL statement, they will be able to directly read the password. In addition, the user input string is inserted in the clear to pure SQL statement, also allow an attacker to find it. If you are able to read password, a hacker can. The solution is to use a one-way hash function to the original password encryption coding. Hashing means to convert an input string into another new, unrecognizable function strings. Password encryption expressions add random strings to defend against "dictionary attacks." Do not plaintext password into the SQL query statement. Calculate the hash string in the application code, only use a hash strings in a SQL query.`,
+	},
+	"SEC.003": {
+		Summary: "Note that when using the backup DELETE / DROP / TRUNCATE other operations",
+		Content: `Back up the data before you perform high-risk operations is very necessary.`,
+	},
+	"SEC.004": {
+		Summary: "Find common SQL injection function",
+		Content: `SLEEP(), BENCHMARK(), GET_LOCK(), RELEASE_LOCK()And other functions usually appear in SQL injection statement, will seriously affect database performance.`,
+	},
+	"SHD.001": {
+		Summary: "Query on sharded table without shard key in WHERE",
+		Content: `The query references the logical table of a sharded table but the WHERE clause has no condition on the shard key, the sharding middleware has to send the query to every shard and merge the results. Add an equality, IN or range condition on the shard key configured by shard-tables.`,
+	},
+	"STA.001": {
+		Summary: "'! =' Operator is nonstandard",
+		Content: `"<>" It is not equal to the standard SQL operators.`,
+	},
+	"STA.002": {
+		Summary: "Library name or table name is recommended after the point of no space",
+		Content: `When db.table table.column format or access the tables or fields, do not add a space dot behind, although this grammatically correct.`,
+	},
+	"STA.003": {
+		Summary: "Index named non-standard",
+		Content: `It suggests that in general secondary index to idx_ prefixed, unique index to uk_ as a prefix.`,
+	},
+	"STA.004": {
+		Summary: "Do not use characters other than letters, numbers, and underscores when naming",
+		Content: `Start with a letter or an underscore, the name only letters, numbers and underscores. Please unified case, do not use the hump nomenclature. Do not appear in the name continuous underscore '__', making it difficult to identify.`,
+	},
+	"SUB.001": {
+		Summary: "MySQL optimization results in poor subquery",
+		Content: `MySQL each row in the outer query as a dependent sub-query execution sub-queries. This is a common cause of serious performance problems. This may improve in the MySQL 5.6 version, but 5.1 and earlier versions, it is recommended the class were rewritten to query JOIN or LEFT OUTER JOIN.`,
+	},
+	"SUB.002": {
+		Summary: "If you do not care to repeat the words, it recommends the use of alternative UNION ALL UNION",
+		Content: `And removing duplicate different UNION, UNION ALL allow duplicate tuples. If you do not care about duplicate tuples, use UNION ALL would be a faster option.`,
+	},
+	"SUB.003": {
+		Summary: "Consider using EXISTS instead of DISTINCT subquery",
+		Content: `DISTINCT keyword to remove duplicate in the sorted tuple. Instead, consider using a subquery with EXISTS keywords, you can avoid returning the entire table.`,
+	},
+	"SUB.004": {
+		Summary: "Implementation plan nesting depth is too deep connection",
+		Content: `MySQL optimization results in poor sub-queries, MySQL each row in the outer query as a dependent sub-query execution sub-queries. This is a common cause of serious performance problems. Subquery nesting depth should not exceed {{.Threshold}}.`,
+	},
+	"SUB.005": {
+		Summary: "Subquery does not support LIMIT",
+		Content: `The current version of MySQL does not support 'LIMIT & IN / ALL / ANY / SOME' in the sub-queries.`,
+	},
+	"SUB.006": {
+		Summary: "Not recommended for use in sub-query function",
+		Content: `MySQL each row in the outer query as a query execution dependency subset subquery, if the function is in a subquery, even semi-join query is difficult to perform efficient. Subquery may be rewritten as OUTER JOIN statement and filters the data connection conditions.`,
+	},
+	"SUB.007": {
+		Summary: "UNION joint inquiry with the outer limit of LIMIT output, it is also recommended to add inner query output limit LIMIT",
+		Content: `MySQL may not be from outer limits "pushed down" to the inner layer, which makes the original limit who can restrict partial returns results could not be applied to the optimization of the inner query. For example: (SELECT * FROM tb1 ORDER BY name) UNION ALL (SELECT * FROM tb2 ORDER BY name) LIMIT 20; MySQL result will be two sub-queries in a temporary table, and then remove the 20 results can be obtained by two Add LIMIT 20 sub-query data to reduce temporary tables. (SELECT * FROM tb1 ORDER BY name LIMIT 20) UNION ALL (SELECT * FROM tb2 ORDER BY name LIMIT 20) LIMIT 20;`,
+	},
+	"SYS.001": {
+		Summary: "The InnoDB buffer pool is smaller than the tables used by the query",
+		Content: `The data and indexes of the tables used by the query exceed innodb_buffer_pool_size. The query has to read pages from disk frequently and evicts other hot data from the buffer pool, so its latency will fluctuate. Make sure the hot data fits in the buffer pool, increase innodb_buffer_pool_size (usually 50%~75% of the memory) if needed.`,
+	},
+	"SYS.002": {
+		Summary: "Written data may be lost on crash",
+		Content: `When sync_binlog or innodb_flush_log_at_trx_commit is not 1, commits do not wait for the logs to reach the disk. Committed data may be lost on crash and InnoDB may become inconsistent with the binlog. Set both variables to 1 for important data.`,
+	},
+	"SYS.003": {
+		Summary: "Slow queries are not logged",
+		Content: `The slow query log is disabled or long_query_time is too large on the online server. Performance problems of the audited queries cannot be found with the slow query log after release. Enable slow_query_log and set long_query_time to 1 second or less.`,
+	},
+	"SYS.004": {
+		Summary: "max_connections is too large",
+		Content: `Sorting, grouping and joins allocate buffers such as sort_buffer_size and join_buffer_size per connection on demand. When max_connections connections run them at the same time the memory used may exceed the buffer pool and the server may run out of memory. Reduce max_connections or the session buffers, and limit concurrency with a connection pool.`,
+	},
+	"TBL.001": {
+		Summary: "Not recommended partition table",
+		Content: `Not recommended partition table. If partitioning is really needed, use '-report-type partition' with the table DDL and the workload SQL to check which queries can prune partitions.`,
+	},
+	"TBL.002": {
+		Summary: "Please choose the right storage engine for the table",
+		Content: `Recommended using the recommended storage engine, such as when construction of the table or modify the table storage engine:{{join .AllowEngines ","}}`,
+	},
+	"TBL.003": {
+		Summary: "DUAL named table to have a special meaning in the database",
+		Content: `DUAL table is a virtual table, no need to create to use, and does not advise the service DUAL named to the table.`,
+	},
+	"TBL.004": {
+		Summary: "AUTO_INCREMENT initial value table is not 0",
+		Content: `AUTO_INCREMENT is not 0 result in data voids.`,
+	},
+	"TBL.005": {
+		Summary: "Please use the recommended character set",
+		Content: `Table character set allows only to '{{join .AllowCharsets ","}}'`,
+	},
+	"TBL.006": {
+		Summary: "Not recommended View",
+		Content: `Not recommended View`,
+	},
+	"TBL.007": {
+		Summary: "We do not recommend the use of temporary table",
+		Content: `We do not recommend the use of temporary table`,
+	},
+	"TBL.008": {
+		Summary: "Use recommended COLLATE",
+		Content: `COLLATE only set to '{{join .AllowCollates ","}}'`,
+	},
+	"TBL.009": {
+		Summary: "Avoid the EAV (Entity-Attribute-Value) design",
+		Content: "An EAV table stores each attribute of an entity as a row, so attribute values use a generic string type and cannot have NOT NULL, data type or foreign key constraints. Reading one entity requires several self joins or pivoting, and indexes on the values cannot compare them by their real type.",
+	},
+	"TBL.010": {
+		Summary: "Avoid polymorphic associations",
+		Content: "A polymorphic association uses a xxx_type and a xxx_id column to reference different tables. xxx_id cannot have a foreign key constraint, so referential integrity is not guaranteed, and queries need to join different tables for each type.",
+	},
+	"TBL.011": {
+		Summary: "Too many boolean columns in the table",
+		Content: "Many boolean columns usually come from flags added one by one as requirements change, every new flag needs a schema change, and these low selectivity columns cannot use indexes efficiently. Merge them into one SET column, or store the flags in an association table.",
+	},
+	"TBL.012": {
+		Summary: "CREATE TABLE ... SELECT does not copy the indexes of the source table",
+		Content: "CREATE TABLE ... SELECT only creates columns from the query result. It does not copy the primary key, indexes or attributes such as AUTO_INCREMENT of the source table, and column types may be converted. The statement implicitly commits the current transaction and takes shared locks on the source rows it reads, which blocks writes. With statement-based replication a nondeterministic result order may make the replica diverge, and before MySQL 8.0.21 it is not allowed when enforce_gtid_consistency is on. Define the primary key and indexes with CREATE TABLE first, then load the data in batches with INSERT ... SELECT.",
+	},
+	"TBL.013": {
+		Summary: "The structure of a table created by CREATE TABLE ... LIKE cannot be audited",
+		Content: "CREATE TABLE ... LIKE copies the columns, indexes and table options of the source table, but not foreign keys or the DATA DIRECTORY and INDEX DIRECTORY options. Problems in the source table are carried into the new table, while the audit cannot see its structure. Write the full CREATE TABLE statement, or configure OnlineDsn to check the source table.",
+	},
+	"TDB.001": {
+		Summary: "AUTO_INCREMENT primary key causes write hotspot in TiDB",
+		Content: `TiDB uses an integer primary key as the row ID, monotonically increasing AUTO_INCREMENT values make all new rows land in the last Region and a single TiKV node becomes the write hotspot. Use AUTO_RANDOM instead of AUTO_INCREMENT when the IDs do not need to be continuous.`,
+	},
+	"TDB.002": {
+		Summary: "Set SHARD_ROW_ID_BITS for tables without integer primary key",
+		Content: `Tables without a single-column integer primary key use the implicit increasing _tidb_rowid as the row ID, new rows are written to the same Region. Set SHARD_ROW_ID_BITS (and PRE_SPLIT_REGIONS) to scatter the row IDs for tables with heavy writes.`,
+	},
+	"TDB.003": {
+		Summary: "Feature not supported by TiDB",
+		Content: `TiDB does not support or enforce foreign keys, full-text indexes, spatial types, triggers, stored procedures, user-defined functions, events and XA transactions. The statement may fail or behave differently from MySQL, please implement the logic in the application instead.`,
+	},
+	"TDB.004": {
+		Summary: "TiFlash/MPP hints require TiFlash replicas",
+		Content: `READ_FROM_STORAGE(TIFLASH[...]) and MPP hints such as MPP_1PHASE_AGG, MPP_2PHASE_AGG, SHUFFLE_JOIN and BROADCAST_JOIN only take effect when the tables have TiFlash replicas (ALTER TABLE ... SET TIFLASH REPLICA) and MPP is enabled by tidb_allow_mpp, otherwise they are silently ignored. Make sure the replicas are available and check the plan with EXPLAIN.`,
+	},
+	"TIM.001": {
+		Summary: "TIMESTAMP only stores times between 1970 and 2038",
+		Content: "The range of TIMESTAMP is '1970-01-01 00:00:01' UTC to '2038-01-19 03:14:07' UTC, values out of range cause an error or are stored as zero. TIMESTAMP values are converted from the session time_zone to UTC for storage and back for retrieval, so connections with different time zones read different values. DATETIME supports years 1000 to 9999 and stores values as written, without time zone conversion.",
+	},
+	"TIM.002": {
+		Summary: "Comparing DATETIME with TIMESTAMP columns",
+		Content: "TIMESTAMP values are converted to the session time_zone before being compared with DATETIME values, which are never converted, so the result depends on the time zone of the connection. Use the same type for both columns, or store all times in UTC.",
+	},
+	"TIM.003": {
+		Summary: "Mixing local time and UTC time",
+		Content: "NOW(), CURDATE() and similar functions return the time in the session time zone, while UTC_TIMESTAMP() and UTC_DATE() return UTC time. Mixing them in one statement, or comparing UTC time with TIMESTAMP columns, gives results that depend on the time_zone setting. Use a single time reference.",
+	},
+	"TIM.004": {
+		Summary: "Do not use numeric arithmetic on dates",
+		Content: "Date strings and temporal columns are converted to numbers when added to or subtracted from a number, e.g. '2020-01-01' + 1 is 2021, and a DATETIME column plus 1 is a YYYYMMDDhhmmss number instead of a time. Use DATE_ADD(), DATE_SUB() or INTERVAL for date arithmetic.",
+	},
+	"VER.001": {
+		Summary: "Syntax not supported by the target database version",
+		Content: `The statement uses syntax that is not available on the database and version specified by -target, such as window functions and CTE (MySQL 8.0, MariaDB 10.2), ALGORITHM=INSTANT (MySQL 8.0.12, MariaDB 10.3), system-versioned tables, SEQUENCE and RETURNING (MariaDB only), LATERAL and UUID_TO_BIN() (MySQL only). Rewrite the statement or upgrade the database.`,
+	},
+	"WIN.001": {
+		Summary: "No index supports the PARTITION BY and ORDER BY of the window function",
+		Content: `Window functions sort rows by the PARTITION BY and ORDER BY columns first. Without an index in that order an extra filesort is required, which spills to temporary files on disk for large data sets. For single-table queries, add an index on the WHERE equality columns, followed by the PARTITION BY columns and the ORDER BY columns. This check relies on -schema-file or CREATE TABLE statements in the input.`,
+	},
+	"WIN.002": {
+		Summary: "Window frame extends to the last row of the partition",
+		Content: `When the window frame ends with UNBOUNDED FOLLOWING, MySQL has to buffer the whole partition in an internal temporary table and read it entirely before producing the first row. The larger the partition, the more likely the temporary table is converted to an on-disk table. If only a per-partition aggregate is needed, compute it with GROUP BY and join the result. For running totals the default RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW is sufficient.`,
+	},
+	"WIN.003": {
+		Summary: "Windows with different orderings",
+		Content: `Windows with different PARTITION BY or ORDER BY in the same query sort the rows separately, every additional ordering costs another sort. Try to use the same PARTITION BY and ORDER BY for all windows, or split window functions with different orderings into separate queries.`,
+	},
+	"WIN.004": {
+		Summary: "Use the WINDOW clause for repeated window definitions",
+		Content: `When several window functions share the same window definition, define a named window in the WINDOW clause and reference it with OVER w. This avoids repetition and keeps the orderings of these windows consistent.`,
+	},
+}
+
+// messageTextEN 英文规则补充说明
+var messageTextEN = map[string]string{
+	"critical":                "Touches critical tables {{join .Tables \", \"}}, severity raised from {{.From}} to {{.To}}.",
+	"EXP.spill":               "id={{.ID}} ({{join .Tables \", \"}}) is estimated at {{.Rows}} rows, {{.RowLength}} per row, about {{.Size}} in total",
+	"EXP.001.memory.summary":  "Sorting (Using filesort) fits in memory",
+	"EXP.001.memory":          "{{.Estimate}}, within sort_buffer_size ({{.Limit}}).",
+	"EXP.001.disk.summary":    "Sorting (Using filesort) is expected to use temporary files on disk",
+	"EXP.001.disk":            "{{.Estimate}}, exceeding sort_buffer_size ({{.Limit}}), so the data is sorted in chunks written to temporary files on disk and then merged. Add an index matching the ORDER BY to avoid the sort, or reduce the rows and columns being sorted.",
+	"EXP.002.memory.summary":  "The internal temporary table (Using temporary) fits in memory",
+	"EXP.002.memory":          "{{.Estimate}}, within the smaller of tmp_table_size and max_heap_table_size ({{.Limit}}).",
+	"EXP.002.disk.summary":    "The internal temporary table (Using temporary) is expected to be converted to an on-disk table",
+	"EXP.002.disk":            "{{.Estimate}}, exceeding the smaller of tmp_table_size and max_heap_table_size ({{.Limit}}), so the in-memory temporary table will be converted to an on-disk table. Index the GROUP BY and DISTINCT columns, or reduce the rows and columns written to the temporary table.",
+	"MAT.derived":             "derived table",
+	"MAT.cte":                 "CTE",
+	"MAT.reason.aggregate":    "aggregate functions",
+	"MAT.reason.subquery":     "subqueries in the SELECT list",
+	"MAT.001.reasons":         "The {{.Kind}} `{{.Alias}}` uses {{join .Reasons \", \"}}, so it must be materialized into a temporary table.",
+	"MAT.001.unmergeable":     "The target database version cannot merge derived tables, so the {{.Kind}} `{{.Alias}}` must be materialized into a temporary table.",
+	"MAT.001.pushdown":        "The outer condition `{{.Condition}}` only references columns of the {{.Kind}} `{{.Alias}}`; move it inside the {{.Kind}} to reduce the rows being materialized.",
+	"MAT.002.estimate":        "Derived table {{.Name}} ({{join .Tables \", \"}}) is estimated at {{.Rows}} rows, {{.RowLength}} per row, about {{.Size}} in total",
+	"MAT.002.memory.summary":  "The materialized derived table fits in memory",
+	"MAT.002.memory":          "{{.Estimate}}, within the smaller of tmp_table_size and max_heap_table_size ({{.Limit}}).",
+	"MAT.002.disk.summary":    "The materialized derived table is expected to be converted to an on-disk table",
+	"MAT.002.disk":            "{{.Estimate}}, exceeding the smaller of tmp_table_size and max_heap_table_size ({{.Limit}}), so it will be materialized into an on-disk temporary table. Move the outer query's filters inside the derived table, or index the filter and join columns of {{join .Tables \", \"}}, to reduce the rows being materialized.",
+	"MAT.002.lateral":         "The derived table depends on the outer query (LATERAL) and is materialized again for every outer row.",
+	"MAT.002.autokey":         "The outer query reads the materialized table through an automatically created index, which adds to the cost of materialization.",
+	"SYS.001":                 "The data and indexes of {{join .Tables \", \"}} total {{.Size}}, while innodb_buffer_pool_size is {{.Pool}}.",
+	"SYS.002.flush_log":       "innodb_flush_log_at_trx_commit = {{.Value}}, so transactions committed in about the last second may be lost if mysqld crashes or the server goes down.",
+	"SYS.002.sync_binlog":     "sync_binlog = {{.Value}}, so committed transactions may be missing from the binlog if the server goes down, leaving replicas and binlog-based recovery inconsistent with the primary.",
+	"SYS.003.slow_query_log":  "slow_query_log is disabled.",
+	"SYS.003.long_query_time": "long_query_time = {{.Value}} seconds, so most slow queries are not logged.",
+	"SYS.004":                 "max_connections = {{.Connections}} and {{join .Variables \", \"}} add up to {{.PerConnection}} per connection, so all connections running at once may need up to {{.Total}}, while innodb_buffer_pool_size is {{.Pool}}.",
+	"CLU.002.galera":          "In Galera the write set of a single transaction cannot exceed wsrep_max_ws_size (2GB by default) and wsrep_max_ws_rows. A large transaction is only replicated to the other nodes at commit time and blocks their commits (flow control).",
+	"CLU.002.group":           "In Group Replication a single transaction cannot exceed group_replication_transaction_size_limit (150MB by default in MySQL 8.0) and is rolled back when it does. Large transactions also delay certification and apply on every node.",
+	"CLU.002.update":          "UPDATE has no WHERE condition and modifies the whole table in one transaction.",
+	"CLU.002.delete":          "DELETE has no WHERE condition and deletes the whole table in one transaction. Use TRUNCATE to empty a table.",
+	"CLU.002.insert":          "INSERT writes {{.Rows}} rows at once, more than {{.Threshold}}.",
+	"CLU.002.insert_select":   "The rows written by INSERT ... SELECT depend on the query result. Check the size of the result set.",
+	"CLU.004.engine":          "The statement specifies the {{.Engine}} storage engine.",
+	"CLU.004.tables":          "Tables written with a non-InnoDB storage engine: {{join .Tables \", \"}}.",
+}
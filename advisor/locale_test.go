@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestRuleLocales(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	for lang, texts := range RuleLocales {
+		for item := range HeuristicRules {
+			if texts[item].Summary == "" || texts[item].Content == "" {
+				t.Errorf("lang %s: rule %s has no text", lang, item)
+			}
+		}
+		for item := range texts {
+			if _, ok := HeuristicRules[item]; !ok {
+				t.Errorf("lang %s: rule %s not found", lang, item)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestMessageLocales(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	for lang, texts := range MessageLocales {
+		for name := range messageTextEN {
+			if texts[name] == "" {
+				t.Errorf("lang %s: message %s has no text", lang, name)
+			}
+		}
+		for name := range texts {
+			if _, ok := messageTextEN[name]; !ok {
+				t.Errorf("lang %s: message %s not found in en", lang, name)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestLoadRuleLocale(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "lang.yaml")
+	err = ioutil.WriteFile(file, []byte("ARG.003:\n  summary: 隐式类型转换\nCOL.017:\n  content: varchar 长度不超过 {{.Threshold}}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lang := range []string{"zh_CN", "zh-cn", "zh"} {
+		if err = LoadRuleLocale(lang, file); err != nil {
+			t.Error(err)
+		}
+		if HeuristicRules["ARG.003"].Summary != "隐式类型转换" {
+			t.Errorf("lang %s: summary not override: %s", lang, HeuristicRules["ARG.003"].Summary)
+		}
+		if HeuristicRules["ARG.003"].Content != ruleTextZhCN["ARG.003"].Content {
+			t.Errorf("lang %s: content should not be override: %s", lang, HeuristicRules["ARG.003"].Content)
+		}
+		if renderRule(HeuristicRules["COL.017"]).Content != "varchar 长度不超过 1024" {
+			t.Errorf("lang %s: content not rendered: %s", lang, renderRule(HeuristicRules["COL.017"]).Content)
+		}
+	}
+
+	if err = LoadRuleLocale("fr", ""); err == nil {
+		t.Error("lang fr should not support")
+	}
+	if err = LoadRuleLocale("en", filepath.Join(dir, "not_exist.yaml")); err == nil {
+		t.Error("lang-file not exist should return error")
+	}
+
+	err = LoadRuleLocale("en", "")
+	if err != nil {
+		t.Error(err)
+	}
+	if HeuristicRules["ARG.003"].Summary != ruleTextEN["ARG.003"].Summary {
+		t.Errorf("summary should be restored: %s", HeuristicRules["ARG.003"].Summary)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestRenderRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgMaxInCount := common.Config.MaxInCount
+	orgThresholds := common.Config.RuleThresholds
+	orgEngines := common.Config.AllowEngines
+
+	common.Config.MaxInCount = 20
+	rule := renderRule(HeuristicRules["ARG.005"])
+	if !strings.HasSuffix(rule.Content, "should not exceed 20.") {
+		t.Errorf("ARG.005 content not rendered: %s", rule.Content)
+	}
+
+	common.Config.RuleThresholds = map[string]int{"ARG.005": 30}
+	rule = HeuristicRules["ARG.005"]
+	rule.Content += " {{.TestDSN.Password}}"
+	rule = renderRule(rule)
+	if !strings.HasSuffix(rule.Content, "should not exceed 30. {{.TestDSN.Password}}") {
+		t.Errorf("ARG.005 content not rendered: %s", rule.Content)
+	}
+
+	common.Config.AllowEngines = []string{"innodb", "tokudb"}
+	rule = renderRule(HeuristicRules["TBL.002"])
+	if !strings.HasSuffix(rule.Content, "innodb,tokudb") {
+		t.Errorf("TBL.002 content not rendered: %s", rule.Content)
+	}
+
+	rule = renderRule(HeuristicRules["ALI.001"])
+	if rule.Content != HeuristicRules["ALI.001"].Content {
+		t.Errorf("ALI.001 content should not change: %s", rule.Content)
+	}
+
+	common.Config.MaxInCount = orgMaxInCount
+	common.Config.RuleThresholds = orgThresholds
+	common.Config.AllowEngines = orgEngines
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
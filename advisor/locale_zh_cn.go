@@ -0,0 +1,819 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+// ruleTextZhCN 简体中文评审规则文本
+var ruleTextZhCN = map[string]RuleText{
+	"OK": {
+		Summary: "OK",
+		Content: "OK",
+	},
+	"ALI.001": {
+		Summary: "建议使用 AS 关键字显示声明一个别名",
+		Content: "在列或表别名(如\"tbl AS alias\")中, 明确使用 AS 关键字比隐含别名(如\"tbl alias\")更易懂。",
+	},
+	"ALI.002": {
+		Summary: "不建议给列通配符'*'设置别名",
+		Content: "例: \"SELECT tbl.* col1, col2\"上面这条 SQL 给列通配符设置了别名，这样的SQL可能存在逻辑错误。您可能意在查询 col1, 但是代替它的是重命名的是 tbl 的最后一列。",
+	},
+	"ALT.001": {
+		Summary: "修改表的默认字符集不会改表各个字段的字符集",
+		Content: "很多初学者会将 ALTER TABLE tbl_name [DEFAULT] CHARACTER SET 'UTF8' 误认为会修改所有字段的字符集，但实际上它只会影响后续新增的字段不会改表已有字段的字符集。如果想修改整张表所有字段的字符集建议使用 ALTER TABLE tbl_name CONVERT TO CHARACTER SET charset_name;",
+	},
+	"ALT.002": {
+		Summary: "同一张表的多条 ALTER 请求建议合为一条",
+		Content: "每次表结构变更对线上服务都会产生影响，即使是能够通过在线工具进行调整也请尽量通过合并 ALTER 请求的试减少操作次数。合并时 DROP 子句放在前面，支持 INSTANT 的子句与需要重建表的子句分开执行。",
+	},
+	"ALT.003": {
+		Summary: "删除列为高危操作，操作前请注意检查业务逻辑是否还有依赖",
+		Content: "如业务逻辑依赖未完全消除，列被删除后可能导致数据无法写入或无法查询到已删除列数据导致程序异常的情况。这种情况下即使通过备份数据回滚也会丢失用户请求写入的数据。",
+	},
+	"ALT.004": {
+		Summary: "删除主键和外键为高危操作，操作前请与 DBA 确认影响",
+		Content: "主键和外键为关系型数据库中两种重要约束，删除已有约束会打破已有业务逻辑，操作前请业务开发与 DBA 确认影响，三思而行。",
+	},
+	"ALT.005": {
+		Summary: "对访问频繁的表执行 ALTER 可能导致元数据锁堆积",
+		Content: "ALTER 在开始和结束时都需要获取表的排他元数据锁，如果有未提交的事务或执行中的慢查询访问该表，ALTER 会一直等待，期间之后所有访问该表的请求都会被阻塞，短时间内即可耗尽连接。建议在业务低峰期执行，执行前检查并结束长事务，在会话中设置较小的 lock_wait_timeout 使等待超时后失败重试，或使用 pt-online-schema-change, gh-ost 等工具。",
+	},
+	"ARG.001": {
+		Summary: "不建议使用前项通配符查找",
+		Content: "例如 \"％foo\"，查询参数有一个前项通配符的情况无法使用已有索引。",
+	},
+	"ARG.002": {
+		Summary: "没有通配符的 LIKE 查询",
+		Content: "不包含通配符的 LIKE 查询可能存在逻辑错误，因为逻辑上它与等值查询相同。",
+	},
+	"ARG.003": {
+		Summary: "参数比较包含隐式转换，无法使用索引",
+		Content: "隐式类型转换有无法命中索引的风险，在高并发、大数据量的情况下，命不中索引带来的后果非常严重。",
+	},
+	"ARG.004": {
+		Summary: "IN (NULL)/NOT IN (NULL) 永远非真",
+		Content: "正确的作法是 col IN ('val1', 'val2', 'val3') OR col IS NULL",
+	},
+	"ARG.005": {
+		Summary: "IN 要慎用，元素过多会导致全表扫描",
+		Content: "如：select id from t where num in(1,2,3)对于连续的数值，能用 BETWEEN 就不要用 IN 了：select id from t where num between 1 and 3。而当 IN 值过多时 MySQL 也可能会进入全表扫描导致性能急剧下降。IN 中的元素个数建议不超过{{.Threshold}}个。",
+	},
+	"ARG.006": {
+		Summary: "应尽量避免在 WHERE 子句中对字段进行 NULL 值判断",
+		Content: "使用 IS NULL 或 IS NOT NULL 将可能导致引擎放弃使用索引而进行全表扫描，如：select id from t where num is null;可以在num上设置默认值0，确保表中 num 列没有 NULL 值，然后这样查询： select id from t where num=0;",
+	},
+	"ARG.007": {
+		Summary: "避免使用模式匹配",
+		Content: "性能问题是使用模式匹配操作符的最大缺点。使用 LIKE 或正则表达式进行模式匹配进行查询的另一个问题，是可能会返回意料之外的结果。最好的方案就是使用特殊的搜索引擎技术来替代 SQL，比如 Apache Lucene。另一个可选方案是将结果保存起来从而减少重复的搜索开销。如果一定要使用SQL，请考虑在 MySQL 中使用像 FULLTEXT 索引这样的第三方扩展。但更广泛地说，您不一定要使用SQL来解决所有问题。",
+	},
+	"ARG.008": {
+		Summary: "OR 查询索引列时请尽量使用 IN 谓词",
+		Content: "IN-list 谓词可以用于索引检索，并且优化器可以对 IN-list 进行排序，以匹配索引的排序序列，从而获得更有效的检索。请注意，IN-list 必须只包含常量，或在查询块执行期间保持常量的值，例如外引用。",
+	},
+	"ARG.009": {
+		Summary: "引号中的字符串开头或结尾包含空格",
+		Content: "如果 VARCHAR 列的前后存在空格将可能引起逻辑问题，如在 MySQL 5.5中 'a' 和 'a ' 可能会在查询中被认为是相同的值。",
+	},
+	"ARG.010": {
+		Summary: "不要使用 hint，如：sql_no_cache, force index, ignore key, straight join等",
+		Content: "hint 是用来强制 SQL 按照某个执行计划来执行，但随着数据量变化我们无法保证自己当初的预判是正确的。",
+	},
+	"ARG.011": {
+		Summary: "不要使用负向查询，如：NOT IN/NOT LIKE",
+		Content: "请尽量不要使用负向查询，这将导致全表扫描，对查询性能影响较大。",
+	},
+	"ARG.012": {
+		Summary: "一次性 INSERT/REPLACE 的数据过多",
+		Content: "单条 INSERT/REPLACE 语句批量插入大量数据性能较差，甚至可能导致从库同步延迟。为了提升性能，减少批量写入数据对从库同步延时的影响，建议采用分批次插入的方法。单条语句写入的行数建议不超过{{.Threshold}}行。",
+	},
+	"ARG.013": {
+		Summary: "DDL 语句中使用了中文全角引号",
+		Content: "DDL 语句中使用了中文全角引号“”或‘’，这可能是书写错误，请确认是否符合预期。",
+	},
+	"ARG.014": {
+		Summary: "比较两侧字符集或排序规则不一致",
+		Content: "JOIN 或 WHERE 条件中比较的两个字符串列字符集或排序规则不一致，MySQL 需要对其中一侧做隐式转换，导致该列上的索引无法使用，也可能报 Illegal mix of collations 错误。建议统一列的字符集和排序规则，SOAR 会给出对应的 ALTER TABLE 语句。",
+	},
+	"CKH.001": {
+		Summary: "不建议在 MergeTree 宽表上使用 SELECT *",
+		Content: "MergeTree 等列存表的每一列单独存储，宽表上的 SELECT * 需要读取并解压所有列，即使查询只用到其中几列。请明确写出需要查询的列。",
+	},
+	"CKH.002": {
+		Summary: "建议将选择性高的过滤条件放入 PREWHERE",
+		Content: "PREWHERE 先只读取过滤条件用到的列，过滤后再读取其他列。ClickHouse 默认会将合适的 WHERE 条件自动移至 PREWHERE，但使用 FINAL 或多表 JOIN 时不会自动优化，建议将小字段上选择性高的过滤条件显式写入 PREWHERE。",
+	},
+	"CKH.003": {
+		Summary: "过滤条件没有使用 MergeTree 排序键的前缀",
+		Content: "MergeTree 的稀疏主键索引按排序键有序，过滤条件只使用了排序键中靠后的列而没有使用第一列时几乎无法跳过数据块，查询需要读取大部分数据。建议增加排序键第一列上的条件，或调整 ORDER BY 排序键中列的顺序。",
+	},
+	"CKH.004": {
+		Summary: "不建议在查询中使用 FINAL",
+		Content: "FINAL 会在查询时对 ReplacingMergeTree, CollapsingMergeTree 等引擎的数据进行合并，查询性能大幅下降并占用更多内存。建议使用 argMax() 配合 GROUP BY 去重，或调整表设计避免查询时依赖合并后的结果。",
+	},
+	"CLA.001": {
+		Summary: "最外层 SELECT 未指定 WHERE 条件",
+		Content: "SELECT 语句没有 WHERE 子句，可能检查比预期更多的行(全表扫描)。对于 SELECT COUNT(*) 类型的请求如果不要求精度，建议使用 SHOW TABLE STATUS 或 EXPLAIN 替代。",
+	},
+	"CLA.002": {
+		Summary: "不建议使用 ORDER BY RAND()",
+		Content: "ORDER BY RAND() 是从结果集中检索随机行的一种非常低效的方法，因为它会对整个结果进行排序并丢弃其大部分数据。",
+	},
+	"CLA.003": {
+		Summary: "不建议使用带 OFFSET 的LIMIT 查询",
+		Content: "使用 LIMIT 和 OFFSET 对结果集分页的复杂度是 O(n^2)，并且会随着数据增大而导致性能问题。采用“书签”扫描的方法实现分页效率更高。",
+	},
+	"CLA.004": {
+		Summary: "不建议对常量进行 GROUP BY",
+		Content: "GROUP BY 1 表示按第一列进行 GROUP BY。如果在 GROUP BY 子句中使用数字，而不是表达式或列名称，当查询列顺序改变时，可能会导致问题。",
+	},
+	"CLA.005": {
+		Summary: "ORDER BY 常数列没有任何意义",
+		Content: "SQL 逻辑上可能存在错误; 最多只是一个无用的操作，不会更改查询结果。",
+	},
+	"CLA.006": {
+		Summary: "在不同的表中 GROUP BY 或 ORDER BY",
+		Content: "这将强制使用临时表和 filesort，可能产生巨大性能隐患，并且可能消耗大量内存和磁盘上的临时空间。",
+	},
+	"CLA.007": {
+		Summary: "ORDER BY 语句对多个不同条件使用不同方向的排序无法使用索引",
+		Content: "ORDER BY 子句中的所有表达式必须按统一的 ASC 或 DESC 方向排序，以便利用索引。",
+	},
+	"CLA.008": {
+		Summary: "请为 GROUP BY 显示添加 ORDER BY 条件",
+		Content: "默认 MySQL 会对 'GROUP BY col1, col2, ...' 请求按如下顺序排序 'ORDER BY col1, col2, ...'。如果 GROUP BY 语句不指定 ORDER BY 条件会导致无谓的排序产生，如果不需要排序建议添加 'ORDER BY NULL'。",
+	},
+	"CLA.009": {
+		Summary: "ORDER BY 的条件为表达式",
+		Content: "当 ORDER BY 条件为表达式或函数时会使用到临时表，如果在未指定 WHERE 或 WHERE 条件返回的结果集较大时性能会很差。",
+	},
+	"CLA.010": {
+		Summary: "GROUP BY 的条件为表达式",
+		Content: "当 GROUP BY 条件为表达式或函数时会使用到临时表，如果在未指定 WHERE 或 WHERE 条件返回的结果集较大时性能会很差。",
+	},
+	"CLA.011": {
+		Summary: "建议为表添加注释",
+		Content: "为表添加注释能够使得表的意义更明确，从而为日后的维护带来极大的便利。",
+	},
+	"CLA.012": {
+		Summary: "将复杂的裹脚布式查询分解成几个简单的查询",
+		Content: "SQL是一门极具表现力的语言，您可以在单个SQL查询或者单条语句中完成很多事情。但这并不意味着必须强制只使用一行代码，或者认为使用一行代码就搞定每个任务是个好主意。通过一个查询来获得所有结果的常见后果是得到了一个笛卡儿积。当查询中的两张表之间没有条件限制它们的关系时，就会发生这种情况。没有对应的限制而直接使用两张表进行联结查询，就会得到第一张表中的每一行和第二张表中的每一行的一个组合。每一个这样的组合就会成为结果集中的一行，最终您就会得到一个行数很多的结果集。重要的是要考虑这些查询很难编写、难以修改和难以调试。数据库查询请求的日益增加应该是预料之中的事。经理们想要更复杂的报告以及在用户界面上添加更多的字段。如果您的设计很复杂，并且是一个单一查询，要扩展它们就会很费时费力。不论对您还是项目来说，时间花在这些事情上面不值得。将复杂的意大利面条式查询分解成几个简单的查询。当您拆分一个复杂的SQL查询时，得到的结果可能是很多类似的查询，可能仅仅在数据类型上有所不同。编写所有的这些查询是很乏味的，因此，最好能够有个程序自动生成这些代码。SQL代码生成是一个很好的应用。尽管SQL支持用一行代码解决复杂的问题，但也别做不切实际的事情。",
+	},
+	"CLA.013": {
+		Summary: "不建议使用 HAVING 子句",
+		Content: "将查询的 HAVING 子句改写为 WHERE 中的查询条件，可以在查询处理期间使用索引。",
+	},
+	"CLA.014": {
+		Summary: "删除全表时建议使用 TRUNCATE 替代 DELETE",
+		Content: "删除全表时建议使用 TRUNCATE 替代 DELETE",
+	},
+	"CLA.015": {
+		Summary: "UPDATE 未指定 WHERE 条件",
+		Content: "UPDATE 不指定 WHERE 条件一般是致命的，请您三思后行",
+	},
+	"CLA.016": {
+		Summary: "不要 UPDATE 主键",
+		Content: "主键是数据表中记录的唯一标识符，不建议频繁更新主键列，这将影响元数据统计信息进而影响正常的查询。",
+	},
+	"CLU.001": {
+		Summary: "集群中的表必须有主键",
+		Content: "Group Replication 及 Galera 依赖主键检测各节点间的写冲突，Group Replication 拒绝写入没有主键的表，Galera 中没有主键的表在其他节点应用 UPDATE, DELETE 时需要逐行扫描全表，并可能导致各节点数据不一致。请为表添加主键。",
+	},
+	"CLU.002": {
+		Summary: "集群中避免大事务",
+		Content: "集群在事务提交时将整个事务的写集复制到其他节点并进行认证，大事务会导致复制延迟、内存占用过高，超过集群的事务大小限制时事务会被回滚。请按主键范围分批执行。",
+	},
+	"CLU.003": {
+		Summary: "集群中不要使用 LOCK TABLES 及 GET_LOCK()",
+		Content: "LOCK TABLES, GET_LOCK() 加的锁只在当前节点生效，不会同步到集群中的其他节点，无法阻止其他节点的并发写入。Galera 不支持 LOCK TABLES，Group Replication 多主模式下表锁同样不会在节点间生效，请使用事务及 SELECT ... FOR UPDATE。",
+	},
+	"CLU.004": {
+		Summary: "集群中只能使用 InnoDB 存储引擎",
+		Content: "Group Replication 及 Galera 只复制 InnoDB 表中的数据，MyISAM 等其他存储引擎的表在集群中无法可靠复制，写入这些表会导致各节点数据不一致，Group Replication 会拒绝写入。请将表转换为 InnoDB。",
+	},
+	"COL.001": {
+		Summary: "不建议使用 SELECT * 类型查询",
+		Content: "当表结构变更时，使用 * 通配符选择所有列将导致查询的含义和行为会发生更改，可能导致查询返回更多的数据。",
+	},
+	"COL.002": {
+		Summary: "INSERT/REPLACE 未指定列名",
+		Content: "当表结构发生变更，如果 INSERT 或 REPLACE 请求不明确指定列名，请求的结果将会与预想的不同; 建议使用 “INSERT INTO tbl(col1，col2)VALUES ...” 代替。",
+	},
+	"COL.003": {
+		Summary: "建议修改自增 ID 为无符号类型",
+		Content: "建议修改自增 ID 为无符号类型",
+	},
+	"COL.004": {
+		Summary: "请为列添加默认值",
+		Content: "请为列添加默认值，如果是 ALTER 操作，请不要忘记将原字段的默认值写上。字段无默认值，当表较大时无法在线变更表结构。",
+	},
+	"COL.005": {
+		Summary: "列未添加注释",
+		Content: "建议对表中每个列添加注释，来明确每个列在表中的含义及作用。",
+	},
+	"COL.006": {
+		Summary: "表中包含有太多的列",
+		Content: "表中包含超过{{.Threshold}}列",
+	},
+	"COL.007": {
+		Summary: "表中包含有太多的 text/blob 列",
+		Content: "表中包含超过{{.Threshold}}个的 text/blob 列",
+	},
+	"COL.008": {
+		Summary: "可使用 VARCHAR 代替 CHAR， VARBINARY 代替 BINARY",
+		Content: "为首先变长字段存储空间小，可以节省存储空间。其次对于查询来说，在一个相对较小的字段内搜索效率显然要高些。",
+	},
+	"COL.009": {
+		Summary: "建议使用精确的数据类型",
+		Content: "实际上，任何使用 FLOAT, REAL 或 DOUBLE PRECISION 数据类型的设计都有可能是反模式。大多数应用程序使用的浮点数的取值范围并不需要达到IEEE 754标准所定义的最大/最小区间。在计算总量时，非精确浮点数所积累的影响是严重的。使用 SQL 中的 NUMERIC 或 DECIMAL 类型来代替 FLOAT 及其类似的数据类型进行固定精度的小数存储。这些数据类型精确地根据您定义这一列时指定的精度来存储数据。尽可能不要使用浮点数。",
+	},
+	"COL.010": {
+		Summary: "不建议使用 ENUM 数据类型",
+		Content: "ENUM 定义了列中值的类型，使用字符串表示 ENUM 里的值时，实际存储在列中的数据是这些值在定义时的序数。因此，这列的数据是字节对齐的，当您进行一次排序查询时，结果是按照实际存储的序数值排序的，而不是按字符串值的字母顺序排序的。这可能不是您所希望的。没有什么语法支持从 ENUM 或者 check 约束中添加或删除一个值；您只能使用一个新的集合重新定义这一列。如果您打算废弃一个选项，您可能会为历史数据而烦恼。作为一种策略，改变元数据——也就是说，改变表和列的定义——应该是不常见的，并且要注意测试和质量保证。有一个更好的解决方案来约束一列中的可选值:创建一张检查表，每一行包含一个允许在列中出现的候选值；然后在引用新表的旧表上声明一个外键约束。",
+	},
+	"COL.011": {
+		Summary: "当需要唯一约束时才使用 NULL，仅当列不能有缺失值时才使用 NOT NULL",
+		Content: "NULL 和0是不同的，10乘以 NULL 还是 NULL。NULL 和空字符串是不一样的。将一个字符串和标准 SQL 中的 NULL 联合起来的结果还是 NULL。NULL 和 FALSE 也是不同的。AND、OR 和 NOT 这三个布尔操作如果涉及 NULL，其结果也让很多人感到困惑。当您将一列声明为 NOT NULL 时，也就是说这列中的每一个值都必须存在且是有意义的。使用 NULL 来表示任意类型不存在的空值。 当您将一列声明为 NOT NULL 时，也就是说这列中的每一个值都必须存在且是有意义的。",
+	},
+	"COL.012": {
+		Summary: "BLOB 和 TEXT 类型的字段不建议设置为 NOT NULL",
+		Content: "BLOB 和 TEXT 类型的字段无法指定非 NULL 的默认值，如果添加了 NOT NULL 限制，写入数据时又未对该字段指定值可能导致写入失败。",
+	},
+	"COL.013": {
+		Summary: "TIMESTAMP 类型默认值检查异常",
+		Content: "TIMESTAMP 类型建议设置默认值，且不建议使用 0 或 0000-00-00 00:00:00 作为默认值。可以考虑使用 1970-08-02 01:01:01",
+	},
+	"COL.014": {
+		Summary: "为列指定了字符集",
+		Content: "建议列与表使用同一个字符集，不要单独指定列的字符集。",
+	},
+	"COL.015": {
+		Summary: "TEXT 和 BLOB 类型的字段不可指定非 NULL 的默认值",
+		Content: "MySQL 数据库中 TEXT 和 BLOB 类型的字段不可指定非 NULL 的默认值。TEXT最大长度为2^16-1个字符，MEDIUMTEXT最大长度为2^32-1个字符，LONGTEXT最大长度为2^64-1个字符。",
+	},
+	"COL.016": {
+		Summary: "整型定义建议采用 INT(10) 或 BIGINT(20)",
+		Content: "INT(M) 在 integer 数据类型中，M 表示最大显示宽度。 在 INT(M) 中，M 的值跟 INT(M) 所占多少存储空间并无任何关系。 INT(3)、INT(4)、INT(8) 在磁盘上都是占用 4 bytes 的存储空间。高版本 MySQL 已经不推荐设置整数显示宽度。",
+	},
+	"COL.017": {
+		Summary: "VARCHAR 定义长度过长",
+		Content: "varchar 是可变长字符串，不预先分配存储空间，长度不要超过{{.Threshold}}，如果存储长度过长 MySQL 将定义字段类型为 text，独立出来一张表，用主键来对应，避免影响其它字段索引效率。",
+	},
+	"COL.018": {
+		Summary: "建表语句中使用了不推荐的字段类型",
+		Content: "以下字段类型不被推荐使用：{{join .ColumnNotAllowType \",\"}}",
+	},
+	"COL.019": {
+		Summary: "不建议使用精度在秒级以下的时间数据类型",
+		Content: "使用高精度的时间数据类型带来的存储空间消耗相对较大；MySQL 在5.6.4以上才可以支持精确到微秒的时间数据类型，使用时需要考虑版本兼容问题。",
+	},
+	"COL.020": {
+		Summary: "自增值接近列类型的最大值",
+		Content: "自增值达到列类型的最大值后，所有 INSERT 都会报主键冲突错误。SOAR 会检查线上表当前的 AUTO_INCREMENT 值，超过 -max-auto-inc-ratio 时给出扩大列类型的 ALTER 语句，请在耗尽前完成变更。",
+	},
+	"COL.021": {
+		Summary: "行长度接近 MySQL 或 InnoDB 的限制",
+		Content: "MySQL 一行中除 BLOB/TEXT 内容外最多 65535 字节，InnoDB 一行在 16K 的页内最多保存约 8126 字节，超出部分的变长列会存储在溢出页。以下按列类型及字符集估算最大行长度。",
+	},
+	"CTE.001": {
+		Summary: "非递归 CTE 被多次引用",
+		Content: "MySQL 8.0 中被多次引用的 CTE 只会物化一次，无法像只引用一次的 CTE 那样合并到外层查询，8.0.22 之前外层的过滤条件也不能下推到 CTE 中，物化的临时表可能很大；MariaDB 则对每次引用单独合并或物化，CTE 会被执行多次。请确认执行计划符合预期，必要时在 CTE 中提前过滤。",
+	},
+	"CTE.002": {
+		Summary: "递归 CTE 缺少终止条件或递归次数超过 cte_max_recursion_depth",
+		Content: "递归 CTE 中引用自身的部分需要通过 WHERE 或 LIMIT 终止递归，否则 MySQL 会在递归次数达到 cte_max_recursion_depth(默认 1000) 时报错，MariaDB 则会一直执行到 max_recursive_iterations。终止条件允许的递归次数超过 cte_max_recursion_depth 时同样会报错，请调整条件或在会话中增大该变量。",
+	},
+	"CTE.003": {
+		Summary: "目标数据库不支持 CTE，建议改写为派生表",
+		Content: "MySQL 8.0 及 MariaDB 10.2 之前的版本不支持 WITH 子句，可以将非递归 CTE 改写为派生表。",
+	},
+	"DIS.001": {
+		Summary: "消除不必要的 DISTINCT 条件",
+		Content: "太多DISTINCT条件是复杂的裹脚布式查询的症状。考虑将复杂查询分解成许多简单的查询，并减少DISTINCT条件的数量。如果主键列是列的结果集的一部分，则DISTINCT条件可能没有影响。",
+	},
+	"DIS.002": {
+		Summary: "COUNT(DISTINCT) 多列时结果可能和你预想的不同",
+		Content: "COUNT(DISTINCT col) 计算该列除NULL之外的不重复行数，注意 COUNT(DISTINCT col, col2) 如果其中一列全为 NULL 那么即使另一列有不同的值，也返回0。",
+	},
+	"DIS.003": {
+		Summary: "DISTINCT * 对有主键的表没有意义",
+		Content: "当表已经有主键时，对所有列进行 DISTINCT 的输出结果与不进行 DISTINCT 操作的结果相同，请不要画蛇添足。",
+	},
+	"FED.001": {
+		Summary: "查询涉及 FEDERATED, CONNECT, SPIDER 等远端表",
+		Content: "远端表的数据存放在其他实例上，只有部分 WHERE 条件能下推至远端执行，函数表达式、ORDER BY, GROUP BY 及 LIMIT 通常在本地处理，可能需要通过网络读取远端的整张表。EXPLAIN 中的 rows 及本地的索引建议不反映远端的实际执行情况，请在远端实例上分析执行计划并添加索引。",
+	},
+	"FED.002": {
+		Summary: "远端表参与 JOIN",
+		Content: "远端表与其他表关联时 JOIN 在本地执行，远端表作为被驱动表时驱动表的每一行都会向远端发起一次查询，网络往返次数随驱动表的行数成倍放大；多张远端表之间也无法在远端完成关联。建议将需要关联的表放在同一实例，或先按条件读取远端数据写入本地临时表后再关联。",
+	},
+	"FED.003": {
+		Summary: "修改远端表的数据",
+		Content: "UPDATE, DELETE 远端表时会先从远端读取符合条件的记录，再逐行向远端发送修改语句，网络往返次数与影响的行数成正比。FEDERATED, CONNECT 不支持事务，执行中断时已完成的修改无法回滚。建议直接连接远端实例执行批量修改。",
+	},
+	"FUN.001": {
+		Summary: "避免在 WHERE 条件中使用函数或其他运算符",
+		Content: "虽然在 SQL 中使用函数可以简化很多复杂的查询，但使用了函数的查询无法利用表中已经建立的索引，该查询将会是全表扫描，性能较差。通常建议将列名写在比较运算符左侧，将查询过滤条件放在比较运算符右侧。也不建议在查询比较条件两侧书写多余的括号，这会对阅读产生比较大的困扰。",
+	},
+	"FUN.002": {
+		Summary: "指定了 WHERE 条件或非 MyISAM 引擎时使用 COUNT(*) 操作性能不佳",
+		Content: "COUNT(*) 的作用是统计表行数，COUNT(COL) 的作用是统计指定列非 NULL 的行数。MyISAM 表对于 COUNT(*) 统计全表行数进行了特殊的优化，通常情况下非常快。但对于非 MyISAM 表或指定了某些 WHERE 条件，COUNT(*) 操作需要扫描大量的行才能获取精确的结果，性能也因此不佳。有时候某些业务场景并不需要完全精确的 COUNT 值，此时可以用近似值来代替。EXPLAIN 出来的优化器估算的行数就是一个不错的近似值，执行 EXPLAIN 并不需要真正去执行查询，所以成本很低。",
+	},
+	"FUN.003": {
+		Summary: "使用了合并为可空列的字符串连接",
+		Content: "在一些查询请求中，您需要强制让某一列或者某个表达式返回非 NULL 的值，从而让查询逻辑变得更简单，但又不想将这个值存下来。可以使用 COALESCE() 函数来构造连接的表达式，这样即使是空值列也不会使整表达式变为 NULL。",
+	},
+	"FUN.004": {
+		Summary: "不建议使用 SYSDATE() 函数",
+		Content: "SYSDATE() 函数可能导致主从数据不一致，请使用 NOW() 函数替代 SYSDATE()。",
+	},
+	"FUN.005": {
+		Summary: "不建议使用 COUNT(col) 或 COUNT(常量)",
+		Content: "不要使用 COUNT(col) 或 COUNT(常量) 来替代 COUNT(*), COUNT(*) 是 SQL92 定义的标准统计行数的方法，跟数据无关，跟 NULL 和非 NULL 也无关。",
+	},
+	"FUN.006": {
+		Summary: "使用 SUM(COL) 时需注意 NPE 问题",
+		Content: "当某一列的值全是 NULL 时，COUNT(COL) 的返回结果为0,但 SUM(COL) 的返回结果为 NULL，因此使用 SUM() 时需注意 NPE 问题。可以使用如下方式来避免 SUM 的 NPE 问题: SELECT IF(ISNULL(SUM(COL)), 0, SUM(COL)) FROM tbl",
+	},
+	"FUN.007": {
+		Summary: "不建议使用触发器",
+		Content: "触发器的执行没有反馈和日志，隐藏了实际的执行步骤，当数据库出现问题是，不能通过慢日志分析触发器的具体执行情况，不易发现问题。在MySQL中，触发器不能临时关闭或打开，在数据迁移或数据恢复等场景下，需要临时drop触发器，可能影响到生产环境。",
+	},
+	"FUN.008": {
+		Summary: "不建议使用存储过程",
+		Content: "存储过程无版本控制，配合业务的存储过程升级很难做到业务无感知。存储过程在拓展和移植上也存在问题。",
+	},
+	"FUN.009": {
+		Summary: "不建议使用自定义函数",
+		Content: "不建议使用自定义函数",
+	},
+	"GIS.001": {
+		Summary: "MySQL 8.0 中空间列需要指定 SRID",
+		Content: "MySQL 8.0 中未指定 SRID 的空间列可以保存任意 SRID 的数据，优化器不会使用该列上的 SPATIAL 索引。建议在列定义中指定 SRID，如 POINT NOT NULL SRID 4326。",
+	},
+	"GIS.002": {
+		Summary: "空间查询条件所在的列没有 SPATIAL 索引",
+		Content: "ST_Contains, ST_Within, MBRContains 等空间关系函数只有在空间列上有 SPATIAL 索引时才能避免全表扫描并逐行计算。SPATIAL 索引要求列为 NOT NULL，MySQL 8.0 中还需要指定 SRID。",
+	},
+	"GIS.003": {
+		Summary: "按距离过滤无法使用空间索引",
+		Content: "ST_Distance, ST_Distance_Sphere 需要对每一行计算距离，无法使用 SPATIAL 索引。建议先使用 MBRContains 按外接矩形在索引中过滤，再按距离精确过滤。",
+	},
+	"GIS.004": {
+		Summary: "不建议使用 FLOAT 或 DOUBLE 保存经纬度",
+		Content: "FLOAT 只有约 7 位有效数字，保存经度时误差可达米级，DOUBLE 等浮点数在比较时也可能不精确。只保存坐标时建议使用 DECIMAL(9,6) 或 DECIMAL(10,7)，需要按距离或范围查询时建议使用 POINT 类型及 SPATIAL 索引。",
+	},
+	"GIS.005": {
+		Summary: "经纬度范围查询无法同时使用两列上的索引",
+		Content: "纬度、经度两列上的范围条件只有第一列可以使用 B-Tree 索引，另一列需要逐行过滤。建议将经纬度保存为 POINT 列并添加 SPATIAL 索引，使用 MBRContains 查询矩形范围内的数据。",
+	},
+	"GRP.001": {
+		Summary: "不建议对等值查询列使用 GROUP BY",
+		Content: "GROUP BY 中的列在前面的 WHERE 条件中使用了等值查询，对这样的列进行 GROUP BY 意义不大。",
+	},
+	"JOI.001": {
+		Summary: "JOIN 语句混用逗号和 ANSI 模式",
+		Content: "表连接的时候混用逗号和 ANSI JOIN 不便于人类理解，并且MySQL不同版本的表连接行为和优先级均有所不同，当 MySQL 版本变化后可能会引入错误。",
+	},
+	"JOI.002": {
+		Summary: "同一张表被连接两次",
+		Content: "相同的表在 FROM 子句中至少出现两次，可以简化为对该表的单次访问。",
+	},
+	"JOI.003": {
+		Summary: "OUTER JOIN 失效",
+		Content: "由于 WHERE 条件错误使得 OUTER JOIN 的外部表无数据返回，这会将查询隐式转换为 INNER JOIN 。如：select c from L left join R using(c) where L.a=5 and R.b=10。这种 SQL 逻辑上可能存在错误或程序员对 OUTER JOIN 如何工作存在误解，因为 LEFT/RIGHT JOIN 是 LEFT/RIGHT OUTER JOIN 的缩写。",
+	},
+	"JOI.004": {
+		Summary: "不建议使用排它 JOIN",
+		Content: "只在右侧表为 NULL 的带 WHERE 子句的 LEFT OUTER JOIN 语句，有可能是在WHERE子句中使用错误的列，如：“... FROM l LEFT OUTER JOIN r ON l.l = r.r WHERE r.z IS NULL”，这个查询正确的逻辑可能是 WHERE r.r IS NULL。",
+	},
+	"JOI.005": {
+		Summary: "减少 JOIN 的数量",
+		Content: "太多的 JOIN 是复杂的裹脚布式查询的症状。考虑将复杂查询分解成许多简单的查询，并减少 JOIN 的数量。单条 SQL 中 JOIN 的表建议不超过{{.Threshold}}张。",
+	},
+	"JOI.006": {
+		Summary: "将嵌套查询重写为 JOIN 通常会导致更高效的执行和更有效的优化",
+		Content: "一般来说，非嵌套子查询总是用于关联子查询，最多是来自FROM子句中的一个表，这些子查询用于 ANY, ALL 和 EXISTS 的谓词。如果可以根据查询语义决定子查询最多返回一个行，那么一个不相关的子查询或来自FROM子句中的多个表的子查询就被压平了。",
+	},
+	"JOI.007": {
+		Summary: "不建议使用联表删除或更新",
+		Content: "当需要同时删除或更新多张表时建议使用简单语句，一条 SQL 只删除或更新一张表，尽量不要将多张表的操作在同一条语句。",
+	},
+	"JOI.008": {
+		Summary: "不要使用跨数据库的 JOIN 查询",
+		Content: "一般来说，跨数据库的 JOIN 查询意味着查询语句跨越了两个不同的子系统，这可能意味着系统耦合度过高或库表结构设计不合理。",
+	},
+	"JOI.009": {
+		Summary: "笛卡尔积，表之间缺少关联条件",
+		Content: "查询中部分表之间没有任何关联条件，一张表的每一行都会与另一张表的每一行组合，结果集的行数是各表行数的乘积，表的数据量增长后会消耗大量的 CPU、内存及网络资源。请检查是否遗漏了关联条件。",
+	},
+	"KEY.001": {
+		Summary: "建议使用自增列作为主键，如使用联合自增主键时请将自增键作为第一列",
+		Content: "建议使用自增列作为主键，如使用联合自增主键时请将自增键作为第一列",
+	},
+	"KEY.002": {
+		Summary: "无主键或唯一键，无法在线变更表结构",
+		Content: "无主键或唯一键，无法在线变更表结构",
+	},
+	"KEY.003": {
+		Summary: "避免外键等递归关系",
+		Content: "存在递归关系的数据很常见，数据常会像树或者以层级方式组织。然而，创建一个外键约束来强制执行同一表中两列之间的关系，会导致笨拙的查询。树的每一层对应着另一个连接。您将需要发出递归查询，以获得节点的所有后代或所有祖先。解决方案是构造一个附加的闭包表。它记录了树中所有节点间的关系，而不仅仅是那些具有直接的父子关系。您也可以比较不同层次的数据设计：闭包表，路径枚举，嵌套集。然后根据应用程序的需要选择一个。",
+	},
+	"KEY.004": {
+		Summary: "提醒：请将索引属性顺序与查询对齐",
+		Content: "如果为列创建复合索引，请确保查询属性与索引属性的顺序相同，以便DBMS在处理查询时使用索引。如果查询和索引属性订单没有对齐，那么DBMS可能无法在查询处理期间使用索引。",
+	},
+	"KEY.005": {
+		Summary: "表建的索引过多",
+		Content: "表中的索引数量超过{{.Threshold}}个",
+	},
+	"KEY.006": {
+		Summary: "主键中的列过多",
+		Content: "主键中的列超过{{.Threshold}}个",
+	},
+	"KEY.007": {
+		Summary: "未指定主键或主键非 int 或 bigint",
+		Content: "未指定主键或主键非 int 或 bigint，建议将主键设置为 int unsigned 或 bigint unsigned。",
+	},
+	"KEY.008": {
+		Summary: "ORDER BY 多个列但排序方向不同时可能无法使用索引",
+		Content: "在 {{if .MariaDB}}MariaDB 10.8{{else}}MySQL 8.0{{end}}之前当 ORDER BY 多个列指定的排序方向不同时将无法使用已经建立的索引。",
+	},
+	"KEY.009": {
+		Summary: "添加唯一索引前请注意检查数据唯一性",
+		Content: "请提前检查添加唯一索引列的数据唯一性，如果数据不唯一在线表结构调整时将有可能自动将重复列删除，这有可能导致数据丢失。",
+	},
+	"KEY.010": {
+		Summary: "全文索引不是银弹",
+		Content: "全文索引主要用于解决模糊查询的性能问题，但需要控制好查询的频率和并发度。同时注意调整 ft_min_word_len, ft_max_word_len, ngram_token_size 等参数。",
+	},
+	"KEY.011": {
+		Summary: "外键列需要有索引",
+		Content: "外键的引用列必须是某个索引的最左前缀，否则 InnoDB 会隐式创建一个自动命名的索引，该索引不会出现在评审的 DDL 中，给之后的索引维护带来隐患。MyISAM 等不支持外键的存储引擎会忽略外键定义，约束并不生效。建议显式创建索引，SOAR 会给出对应的 CREATE INDEX 语句。",
+	},
+	"KEY.012": {
+		Summary: "避免使用随机的 UUID 或散列值作为主键",
+		Content: "InnoDB 按主键顺序组织数据，UUID() 或 MD5/SHA 散列值这类随机值会写入聚簇索引的随机位置，导致频繁的页分裂和碎片，Buffer Pool 中需要缓存的热点数据也会变多；同时较长的字符串主键会复制到每一个二级索引中。{{if .MariaDB}}MariaDB 10.7+ 建议使用按时间有序存储的 UUID 数据类型，更早的版本建议{{else}}建议将 UUID 存储为 BINARY(16)，写入时使用 UUID_TO_BIN(UUID(), 1)（MySQL 8.0+，交换时间戳高低位使其有序，读取时使用 BIN_TO_UUID(id, 1)），或者{{end}}使用自增列作为代理主键，将随机值保留在唯一索引中。引用该列的外键也需要一并修改。SOAR 会给出改写后的建表语句。",
+	},
+	"KWR.001": {
+		Summary: "SQL_CALC_FOUND_ROWS 效率低下",
+		Content: "因为 SQL_CALC_FOUND_ROWS 不能很好地扩展，所以可能导致性能问题; 建议业务使用其他策略来替代 SQL_CALC_FOUND_ROWS 提供的计数功能，比如：分页结果展示等。",
+	},
+	"KWR.002": {
+		Summary: "不建议使用 MySQL 关键字做列名或表名",
+		Content: "当使用关键字做为列名或表名时程序需要对列名和表名进行转义，如果疏忽被将导致请求无法执行。",
+	},
+	"KWR.003": {
+		Summary: "不建议使用复数做列名或表名",
+		Content: "表名应该仅仅表示表里面的实体内容，不应该表示实体数量，对应于 DO 类名也是单数形式，符合表达习惯。",
+	},
+	"KWR.004": {
+		Summary: "不建议使用使用多字节编码字符(中文)命名",
+		Content: "为库、表、列、别名命名时建议使用英文，数字，下划线等字符，不建议使用中文或其他多字节编码字符。",
+	},
+	"LCK.001": {
+		Summary: "INSERT INTO xx SELECT 加锁粒度较大请谨慎",
+		Content: "INSERT INTO xx SELECT 加锁粒度较大请谨慎",
+	},
+	"LCK.002": {
+		Summary: "请慎用 INSERT ON DUPLICATE KEY UPDATE",
+		Content: "当主键为自增键时使用 INSERT ON DUPLICATE KEY UPDATE 可能会导致主键出现大量不连续快速增长，导致主键快速溢出无法继续写入。极端情况下还有可能导致主从数据不一致。",
+	},
+	"LCK.003": {
+		Summary: "加锁读的 WHERE 条件无法使用索引",
+		Content: "SELECT ... FOR UPDATE 及 LOCK IN SHARE MODE 会对扫描过的所有记录加锁，在 REPEATABLE READ 隔离级别下还会锁住记录之间的间隙。WHERE 条件无法使用索引时将锁住整张表，阻塞其他事务的写入，请为加锁读的查询条件添加合适的索引。",
+	},
+	"LCK.004": {
+		Summary: "加锁读所在的事务过长",
+		Content: "加锁读持有的行锁直到事务提交或回滚时才会释放，加锁之后事务中执行的语句越多，锁持有的时间越长，越容易出现锁等待甚至死锁。建议将加锁读放在事务中尽量靠后的位置，加锁之后执行的语句建议不超过{{.Threshold}}条。",
+	},
+	"LCK.005": {
+		Summary: "队列场景的加锁读建议使用 SKIP LOCKED 或 NOWAIT",
+		Content: "使用 SELECT ... ORDER BY ... LIMIT ... FOR UPDATE 领取任务时，多个消费者会争抢同一批记录，后来的事务只能等待锁释放。MySQL 8.0.1、MariaDB 10.6 开始支持 FOR UPDATE SKIP LOCKED 跳过已被锁定的记录，也可以使用 NOWAIT 在记录被锁定时立即返回错误而不是等待。",
+	},
+	"LIT.001": {
+		Summary: "用字符类型存储IP地址",
+		Content: "字符串字面上看起来像IP地址，但不是 INET_ATON() 的参数，表示数据被存储为字符而不是整数。将IP地址存储为整数更为有效。",
+	},
+	"LIT.002": {
+		Summary: "日期/时间未使用引号括起",
+		Content: "诸如“WHERE col <2010-02-12”之类的查询是有效的SQL，但可能是一个错误，因为它将被解释为“WHERE col <1996”; 日期/时间文字应该加引号。",
+	},
+	"LIT.003": {
+		Summary: "一列中存储一系列相关数据的集合",
+		Content: "将 ID 存储为一个列表，作为 VARCHAR/TEXT 列，这样能导致性能和数据完整性问题。查询这样的列需要使用模式匹配的表达式。使用逗号分隔的列表来做多表联结查询定位一行数据是极不优雅和耗时的。这将使验证 ID 更加困难。考虑一下，列表最多支持存放多少数据呢？将 ID 存储在一张单独的表中，代替使用多值属性，从而每个单独的属性值都可以占据一行。这样交叉表实现了两张表之间的多对多关系。这将更好地简化查询，也更有效地验证ID。",
+	},
+	"LIT.004": {
+		Summary: "请使用分号或已设定的 DELIMITER 结尾",
+		Content: "USE database, SHOW DATABASES 等命令也需要使用使用分号或已设定的 DELIMITER 结尾。",
+	},
+	"MAT.001": {
+		Summary: "派生表无法合并到外层查询，需要物化为临时表",
+		Content: "FROM 子句中的子查询（派生表）及 WITH 子句中的 CTE 包含聚合函数、DISTINCT、GROUP BY、HAVING、LIMIT、UNION 或 SELECT 列表中的子查询时无法合并到外层查询，MySQL 会先将其结果物化为临时表，物化表上只有关联时自动创建的索引。MySQL 8.0.22、MariaDB 10.2.2 之前外层查询的过滤条件不会下推到物化的派生表中，建议将只引用派生表列的条件移到派生表内部，或为派生表中基表的过滤及分组列添加索引，减少物化的行数。",
+	},
+	"RES.001": {
+		Summary: "非确定性的 GROUP BY",
+		Content: "SQL返回的列既不在聚合函数中也不是 GROUP BY 表达式的列中，因此这些值的结果将是非确定性的。如：select a, b, c from tbl where foo=\"bar\" group by a，该 SQL 返回的结果就是不确定的。",
+	},
+	"RES.002": {
+		Summary: "未使用 ORDER BY 的 LIMIT 查询",
+		Content: "没有 ORDER BY 的 LIMIT 会导致非确定性的结果，这取决于查询执行计划。",
+	},
+	"RES.003": {
+		Summary: "UPDATE/DELETE 操作使用了 LIMIT 条件",
+		Content: "UPDATE/DELETE 操作使用 LIMIT 条件和不添加 WHERE 条件一样危险，它可将会导致主从数据不一致或从库同步中断。",
+	},
+	"RES.004": {
+		Summary: "UPDATE/DELETE 操作指定了 ORDER BY 条件",
+		Content: "UPDATE/DELETE 操作不要指定 ORDER BY 条件。",
+	},
+	"RES.005": {
+		Summary: "UPDATE 语句可能存在逻辑错误，导致数据损坏",
+		Content: "在一条 UPDATE 语句中，如果要更新多个字段，字段间不能使用 AND ，而应该用逗号分隔。",
+	},
+	"RES.006": {
+		Summary: "永远不真的比较条件",
+		Content: "查询条件永远非真，如果该条件出现在 where 中可能导致查询无匹配到的结果。",
+	},
+	"RES.007": {
+		Summary: "永远为真的比较条件",
+		Content: "查询条件永远为真，可能导致 WHERE 条件失效进行全表查询。",
+	},
+	"RES.008": {
+		Summary: "不建议使用LOAD DATA/SELECT ... INTO OUTFILE",
+		Content: "SELECT INTO OUTFILE 需要授予 FILE 权限，这通过会引入安全问题。LOAD DATA 虽然可以提高数据导入速度，但同时也可能导致从库同步延迟过大。",
+	},
+	"RES.009": {
+		Summary: "不建议使用连续判断",
+		Content: "类似这样的 SELECT * FROM tbl WHERE col = col = 'abc' 语句可能是书写错误，您可能想表达的含义是 col = 'abc'。如果确实是业务需求建议修改为 col = col and col = 'abc'。",
+	},
+	"RES.010": {
+		Summary: "建表语句中定义为 ON UPDATE CURRENT_TIMESTAMP 的字段不建议包含业务逻辑",
+		Content: "定义为 ON UPDATE CURRENT_TIMESTAMP 的字段在该表其他字段更新时会联动修改，如果包含业务逻辑用户可见会埋下隐患。后续如有批量修改数据却又不想修改该字段时会导致数据错误。",
+	},
+	"RES.011": {
+		Summary: "更新请求操作的表包含 ON UPDATE CURRENT_TIMESTAMP 字段",
+		Content: "定义为 ON UPDATE CURRENT_TIMESTAMP 的字段在该表其他字段更新时会联动修改，请注意检查。如不想修改字段的更新时间可以使用如下方法：UPDATE category SET name='ActioN', last_update=last_update WHERE category_id=1",
+	},
+	"RES.012": {
+		Summary: "SELECT 中的别名与表中的列同名",
+		Content: "MySQL 解析 ORDER BY 中不带表名的名称时优先使用 SELECT 中的别名，而 GROUP BY 和 HAVING 优先使用表中的列。别名与列同名时 ORDER BY 按别名对应的表达式排序，GROUP BY 却按原始列分组，结果很容易与预期不符。建议修改别名或为列加上表名前缀。",
+	},
+	"RES.013": {
+		Summary: "GROUP BY 或 ORDER BY 中的列位置超出范围",
+		Content: "GROUP BY 或 ORDER BY 中使用的列位置大于 SELECT 中列的个数（或小于 1），SQL 执行时会报 Unknown column 错误。使用列位置在 SELECT 中的列发生变化时也容易出错，建议使用列名。",
+	},
+	"RES.014": {
+		Summary: "同一个 AND/OR 中存在重复的条件",
+		Content: "同一个 AND 或 OR 中多次出现了相同的条件，虽然不影响查询结果，但通常是书写错误，如原本想比较的是其他列或其他值。",
+	},
+	"RKS.001": {
+		Summary: "ROCKSDB 表的降序排序需要反向扫描",
+		Content: "MyRocks 使用 LSM 存储数据，反向迭代需要合并多层 SST 文件并跳过已删除的记录，比正向扫描慢很多。经常按降序读取的索引建议放在反向列族中，如 KEY idx_user (user_id, id) COMMENT 'rev:cf_user'，或调整业务改为正向读取。",
+	},
+	"RKS.002": {
+		Summary: "ROCKSDB 表的查询条件无法使用前缀 Bloom Filter",
+		Content: "MyRocks 使用索引前缀的 Bloom Filter 跳过不包含该前缀的 SST 文件，只有等值条件能使用 Bloom Filter，只有范围条件时需要读取每一层中符合范围的数据。建议设计索引时将等值条件的列放在前面，并保证 Bloom Filter 的前缀长度（prefix_extractor）不超过等值条件覆盖的长度。",
+	},
+	"RKS.003": {
+		Summary: "ROCKSDB 表的唯一二级索引需要写前读",
+		Content: "MyRocks 写入非唯一二级索引时不需要读取已有的数据，而唯一二级索引在每次写入前都需要读取并锁定记录以检查唯一性，会明显降低写入性能。请确认是否需要数据库保证唯一性，写入频繁的表可以考虑在业务层保证唯一性并改用普通索引。",
+	},
+	"SEC.001": {
+		Summary: "请谨慎使用TRUNCATE操作",
+		Content: "一般来说想清空一张表最快速的做法就是使用TRUNCATE TABLE tbl_name;语句。但TRUNCATE操作也并非是毫无代价的，TRUNCATE TABLE无法返回被删除的准确行数，如果需要返回被删除的行数建议使用DELETE语法。TRUNCATE 操作还会重置 AUTO_INCREMENT，如果不想重置该值建议使用 DELETE FROM tbl_name WHERE 1;替代。TRUNCATE 操作会对数据字典添加源数据锁(MDL)，当一次需要 TRUNCATE 很多表时会影响整个实例的所有请求，因此如果要 TRUNCATE 多个表建议用 DROP+CREATE 的方式以减少锁时长。",
+	},
+	"SEC.002": {
+		Summary: "不使用明文存储密码",
+		Content: "使用明文存储密码或者使用明文在网络上传递密码都是不安全的。如果攻击者能够截获您用来插入密码的SQL语句，他们就能直接读到密码。另外，将用户输入的字符串以明文的形式插入到纯SQL语句中，也会让攻击者发现它。如果您能够读取密码，黑客也可以。解决方案是使用单向哈希函数对原始密码进行加密编码。哈希是指将输入字符串转化成另一个新的、不可识别的字符串的函数。对密码加密表达式加点随机串来防御“字典攻击”。不要将明文密码输入到SQL查询语句中。在应用程序代码中计算哈希串，只在SQL查询中使用哈希串。",
+	},
+	"SEC.003": {
+		Summary: "使用DELETE/DROP/TRUNCATE等操作时注意备份",
+		Content: "在执行高危操作之前对数据进行备份是十分有必要的。",
+	},
+	"SEC.004": {
+		Summary: "发现常见 SQL 注入函数",
+		Content: "SLEEP(), BENCHMARK(), GET_LOCK(), RELEASE_LOCK() 等函数通常出现在 SQL 注入语句中，会严重影响数据库性能。",
+	},
+	"SHD.001": {
+		Summary: "查询分表时 WHERE 条件中没有分片键",
+		Content: "SQL 中使用的是分表的逻辑表名，但 WHERE 条件中没有分片键，分库分表中间件需要将查询发送到所有分表上执行后再合并结果。建议在 WHERE 条件中添加 shard-tables 中配置的分片键的等值、IN 或范围查询条件。",
+	},
+	"STA.001": {
+		Summary: "'!=' 运算符是非标准的",
+		Content: "\"<>\"才是标准SQL中的不等于运算符。",
+	},
+	"STA.002": {
+		Summary: "库名或表名点后建议不要加空格",
+		Content: "当使用 db.table 或 table.column 格式访问表或字段时，请不要在点号后面添加空格，虽然这样语法正确。",
+	},
+	"STA.003": {
+		Summary: "索引起名不规范",
+		Content: "建议普通二级索引以idx_为前缀，唯一索引以uk_为前缀。",
+	},
+	"STA.004": {
+		Summary: "起名时请不要使用字母、数字和下划线之外的字符",
+		Content: "以字母或下划线开头，名字只允许使用字母、数字和下划线。请统一大小写，不要使用驼峰命名法。不要在名字中出现连续下划线'__'，这样很难辨认。",
+	},
+	"SUB.001": {
+		Summary: "MySQL 对子查询的优化效果不佳",
+		Content: "MySQL 将外部查询中的每一行作为依赖子查询执行子查询。 这是导致严重性能问题的常见原因。这可能会在 MySQL 5.6 版本中得到改善, 但对于5.1及更早版本, 建议将该类查询分别重写为 JOIN 或 LEFT OUTER JOIN。",
+	},
+	"SUB.002": {
+		Summary: "如果您不在乎重复的话，建议使用 UNION ALL 替代 UNION",
+		Content: "与去除重复的UNION不同，UNION ALL允许重复元组。如果您不关心重复元组，那么使用UNION ALL将是一个更快的选项。",
+	},
+	"SUB.003": {
+		Summary: "考虑使用 EXISTS 而不是 DISTINCT 子查询",
+		Content: "DISTINCT 关键字在对元组排序后删除重复。相反，考虑使用一个带有 EXISTS 关键字的子查询，您可以避免返回整个表。",
+	},
+	"SUB.004": {
+		Summary: "执行计划中嵌套连接深度过深",
+		Content: "MySQL对子查询的优化效果不佳,MySQL将外部查询中的每一行作为依赖子查询执行子查询。 这是导致严重性能问题的常见原因。子查询的嵌套深度建议不超过{{.Threshold}}层。",
+	},
+	"SUB.005": {
+		Summary: "子查询不支持LIMIT",
+		Content: "当前 MySQL 版本不支持在子查询中进行 'LIMIT & IN/ALL/ANY/SOME'。",
+	},
+	"SUB.006": {
+		Summary: "不建议在子查询中使用函数",
+		Content: "MySQL将外部查询中的每一行作为依赖子查询执行子查询，如果在子查询中使用函数，即使是semi-join也很难进行高效的查询。可以将子查询重写为OUTER JOIN语句并用连接条件对数据进行过滤。",
+	},
+	"SUB.007": {
+		Summary: "外层带有 LIMIT 输出限制的 UNION 联合查询，其内层查询建议也添加 LIMIT 输出限制",
+		Content: "有时 MySQL 无法将限制条件从外层“下推”到内层，这会使得原本可以限制能够限制部分返回结果的条件无法应用到内层查询的优化上。比如：(SELECT * FROM tb1 ORDER BY name) UNION ALL (SELECT * FROM tb2 ORDER BY name) LIMIT 20;  MySQL 会将两个子查询的结果放在一个临时表中，然后取出 20 条结果，可以通过在两个子查询中添加 LIMIT 20 来减少临时表中的数据。(SELECT * FROM tb1 ORDER BY name LIMIT 20) UNION ALL (SELECT * FROM tb2 ORDER BY name LIMIT 20) LIMIT 20;",
+	},
+	"SYS.001": {
+		Summary: "InnoDB Buffer Pool 小于 SQL 使用的表",
+		Content: "SQL 使用的表的数据及索引超过了 innodb_buffer_pool_size，查询需要频繁从磁盘读取数据页，并将其他热点数据挤出 Buffer Pool，执行时间会明显波动。请确认热点数据能否放入 Buffer Pool，必要时增大 innodb_buffer_pool_size（通常为内存的 50%~75%）。",
+	},
+	"SYS.002": {
+		Summary: "写入的数据在崩溃时可能丢失",
+		Content: "sync_binlog, innodb_flush_log_at_trx_commit 不为 1 时事务提交不会等待日志写入磁盘，写入的数据在崩溃时可能丢失，InnoDB 与 binlog 也可能不一致。重要的数据请将两个变量都设置为 1。",
+	},
+	"SYS.003": {
+		Summary: "未记录慢查询日志",
+		Content: "线上环境未开启慢查询日志或 long_query_time 过大，评审的 SQL 上线后出现性能问题时无法通过慢查询日志发现及分析。建议开启 slow_query_log，并将 long_query_time 设置为 1 秒或更小。",
+	},
+	"SYS.004": {
+		Summary: "max_connections 过大",
+		Content: "排序、分组及 JOIN 会在每个连接中按需分配 sort_buffer_size, join_buffer_size 等缓冲区，max_connections 个连接同时执行时占用的内存可能超过 Buffer Pool，导致服务器内存不足。请减小 max_connections 或会话级缓冲区的大小，并使用连接池控制并发。",
+	},
+	"TBL.001": {
+		Summary: "不建议使用分区表",
+		Content: "不建议使用分区表。如果确实需要分区，可以使用 -report-type partition 输入建表语句及业务 SQL，分析哪些请求能够进行分区裁剪。",
+	},
+	"TBL.002": {
+		Summary: "请为表选择合适的存储引擎",
+		Content: "建表或修改表的存储引擎时建议使用推荐的存储引擎，如：{{join .AllowEngines \",\"}}",
+	},
+	"TBL.003": {
+		Summary: "以DUAL命名的表在数据库中有特殊含义",
+		Content: "DUAL表为虚拟表，不需要创建即可使用，也不建议服务以DUAL命名表。",
+	},
+	"TBL.004": {
+		Summary: "表的初始AUTO_INCREMENT值不为0",
+		Content: "AUTO_INCREMENT不为0会导致数据空洞。",
+	},
+	"TBL.005": {
+		Summary: "请使用推荐的字符集",
+		Content: "表字符集只允许设置为'{{join .AllowCharsets \",\"}}'",
+	},
+	"TBL.006": {
+		Summary: "不建议使用视图",
+		Content: "不建议使用视图",
+	},
+	"TBL.007": {
+		Summary: "不建议使用临时表",
+		Content: "不建议使用临时表",
+	},
+	"TBL.008": {
+		Summary: "请使用推荐的COLLATE",
+		Content: "COLLATE 只允许设置为'{{join .AllowCollates \",\"}}'",
+	},
+	"TBL.009": {
+		Summary: "不建议使用 EAV（实体-属性-值）表结构",
+		Content: "EAV 表将实体的每个属性保存为一行，属性值只能使用通用的字符串类型，无法使用 NOT NULL、类型及外键约束，读取一个实体需要多次自连接或行转列，属性值上的索引也无法按类型比较。",
+	},
+	"TBL.010": {
+		Summary: "不建议使用多态关联",
+		Content: "多态关联使用 xxx_type 与 xxx_id 两列引用不同的表，xxx_id 无法定义外键约束，无法保证引用完整性，查询时需要按类型分别连接不同的表。",
+	},
+	"TBL.011": {
+		Summary: "表中布尔列过多",
+		Content: "大量布尔列通常是随需求逐个添加的标记，每增加一个标记都需要修改表结构，这些列选择性很低也无法有效使用索引。建议合并为一个 SET 列，或使用关联表保存标记。",
+	},
+	"TBL.012": {
+		Summary: "CREATE TABLE ... SELECT 不会复制源表的索引",
+		Content: "CREATE TABLE ... SELECT 只根据查询结果创建列，不会复制源表的主键、索引及 AUTO_INCREMENT 等属性，列类型也可能发生转换。该语句会隐式提交当前事务，执行期间会对读取的源表记录加共享锁阻塞写入；基于语句的复制中查询结果的顺序不确定时可能导致主从数据不一致，MySQL 8.0.21 之前开启 enforce_gtid_consistency 时不允许执行。建议先使用 CREATE TABLE 定义好主键和索引，再通过 INSERT ... SELECT 分批导入数据。",
+	},
+	"TBL.013": {
+		Summary: "CREATE TABLE ... LIKE 无法审核新表的结构",
+		Content: "CREATE TABLE ... LIKE 会复制源表的列、索引及表选项，但不会复制外键以及 DATA DIRECTORY, INDEX DIRECTORY 选项，源表结构中的问题也会一并带入新表，而审核时无法看到新表的结构。建议写出完整的建表语句，或配置 OnlineDsn 检查源表的结构。",
+	},
+	"TDB.001": {
+		Summary: "TiDB 中 AUTO_INCREMENT 主键会造成写入热点",
+		Content: "TiDB 直接使用整型主键作为行 ID，单调递增的 AUTO_INCREMENT 值使新写入的数据都落在最后一个 Region 上，单个 TiKV 节点成为写入热点。如果业务不要求 ID 连续，建议使用 AUTO_RANDOM 代替 AUTO_INCREMENT。",
+	},
+	"TDB.002": {
+		Summary: "没有整型主键的表建议设置 SHARD_ROW_ID_BITS",
+		Content: "没有单列整型主键的表使用隐式递增的 _tidb_rowid 作为行 ID，新写入的数据会集中在同一个 Region 上。写入量大的表建议设置 SHARD_ROW_ID_BITS（及 PRE_SPLIT_REGIONS）打散行 ID。",
+	},
+	"TDB.003": {
+		Summary: "使用了 TiDB 不支持的特性",
+		Content: "TiDB 不支持或不生效的特性包括外键、全文索引、空间类型、触发器、存储过程、自定义函数、事件及 XA 事务，语句可能执行失败或行为与 MySQL 不一致，建议在应用中实现相应逻辑。",
+	},
+	"TDB.004": {
+		Summary: "TiFlash/MPP Hint 依赖 TiFlash 副本",
+		Content: "READ_FROM_STORAGE(TIFLASH[...]) 及 MPP_1PHASE_AGG, MPP_2PHASE_AGG, SHUFFLE_JOIN, BROADCAST_JOIN 等 MPP Hint 只有在表存在 TiFlash 副本（ALTER TABLE ... SET TIFLASH REPLICA）且 tidb_allow_mpp 开启时才生效，否则会被静默忽略。请确认副本可用并使用 EXPLAIN 检查执行计划。",
+	},
+	"TIM.001": {
+		Summary: "TIMESTAMP 只能保存 1970 至 2038 年之间的时间",
+		Content: "TIMESTAMP 的取值范围为 '1970-01-01 00:00:01' UTC 至 '2038-01-19 03:14:07' UTC，超出范围的值会报错或被保存为零值；写入时按会话 time_zone 转换为 UTC 保存，读取时再转换为会话时区，不同时区的连接读到的值不同。DATETIME 的取值范围为 1000 至 9999 年，按写入的值原样保存，不做时区转换。",
+	},
+	"TIM.002": {
+		Summary: "DATETIME 与 TIMESTAMP 列比较",
+		Content: "TIMESTAMP 列按会话 time_zone 转换后再与 DATETIME 比较，DATETIME 不做时区转换，比较结果随连接的时区设置变化。建议统一两列的类型，或统一以 UTC 保存时间。",
+	},
+	"TIM.003": {
+		Summary: "混用本地时间与 UTC 时间",
+		Content: "NOW(), CURDATE() 等函数返回会话时区的时间，UTC_TIMESTAMP(), UTC_DATE() 返回 UTC 时间，两者相差会话时区的偏移量，在同一条 SQL 中混用或与 TIMESTAMP 列比较时，结果随 time_zone 设置变化。建议统一使用一种时间基准。",
+	},
+	"TIM.004": {
+		Summary: "不要对日期直接进行数值加减运算",
+		Content: "日期字符串或时间类型的列直接与数值加减时会先转换为数值，如 '2020-01-01' + 1 的结果为 2021，DATETIME 列加 1 得到的是 YYYYMMDDhhmmss 形式的数值而不是时间。请使用 DATE_ADD(), DATE_SUB() 或 INTERVAL 进行日期运算。",
+	},
+	"VER.001": {
+		Summary: "使用了目标数据库版本不支持的语法",
+		Content: "语句中使用了 -target 指定的数据库及版本不支持的语法，如窗口函数和 CTE（MySQL 8.0, MariaDB 10.2），ALGORITHM=INSTANT（MySQL 8.0.12, MariaDB 10.3），系统版本表、SEQUENCE 及 RETURNING（仅 MariaDB 支持），LATERAL 及 UUID_TO_BIN()（仅 MySQL 支持）。请改写语句或升级数据库版本。",
+	},
+	"WIN.001": {
+		Summary: "窗口函数的 PARTITION BY, ORDER BY 没有可用的索引",
+		Content: "窗口函数需要先按 PARTITION BY 及 ORDER BY 的列对数据排序，没有按该顺序排列的索引时需要额外的 filesort，数据量较大时还会使用磁盘临时文件。单表查询建议添加依次包含 WHERE 等值条件列、PARTITION BY 列及 ORDER BY 列的索引。该检查依赖 -schema-file 或输入中的建表语句。",
+	},
+	"WIN.002": {
+		Summary: "窗口范围包含到分区的最后一行",
+		Content: "窗口范围以 UNBOUNDED FOLLOWING 结束时，MySQL 需要把整个分区缓存到内部临时表中，读完整个分区后才能计算第一行，分区越大越容易转为磁盘临时表。如果只需要分区的汇总值，建议先用 GROUP BY 计算汇总结果再关联；计算累计值时使用默认的 RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW 即可。",
+	},
+	"WIN.003": {
+		Summary: "多个窗口的排序方式不同",
+		Content: "同一查询中 PARTITION BY 或 ORDER BY 不同的窗口需要分别对数据排序，每多一种排序方式就多一次排序。建议尽量让窗口使用相同的 PARTITION BY 及 ORDER BY，或将排序方式不同的窗口函数拆分到不同的查询中。",
+	},
+	"WIN.004": {
+		Summary: "建议使用 WINDOW 子句定义重复的窗口",
+		Content: "多个窗口函数使用相同的窗口定义时，建议在 WINDOW 子句中定义命名窗口，并通过 OVER w 引用，避免重复并保证这些窗口的排序方式一致。",
+	},
+}
+
+// messageTextZhCN 中文规则补充说明
+var messageTextZhCN = map[string]string{
+	"critical":                "涉及关键库表 {{join .Tables \", \"}}，级别由 {{.From}} 提升为 {{.To}}。",
+	"EXP.spill":               "id={{.ID}} ({{join .Tables \", \"}}) 预计 {{.Rows}} 行，平均每行 {{.RowLength}}，共约 {{.Size}}",
+	"EXP.001.memory.summary":  "排序（Using filesort）可以在内存中完成",
+	"EXP.001.memory":          "{{.Estimate}}，小于 sort_buffer_size({{.Limit}})。",
+	"EXP.001.disk.summary":    "排序（Using filesort）预计需要使用磁盘临时文件",
+	"EXP.001.disk":            "{{.Estimate}}，超过 sort_buffer_size({{.Limit}})，需要将数据分段排序后写入磁盘临时文件再归并。建议添加与 ORDER BY 顺序一致的索引避免排序，或减少参与排序的行数及列数。",
+	"EXP.002.memory.summary":  "内部临时表（Using temporary）可以在内存中完成",
+	"EXP.002.memory":          "{{.Estimate}}，小于 tmp_table_size 与 max_heap_table_size 中较小的值({{.Limit}})。",
+	"EXP.002.disk.summary":    "内部临时表（Using temporary）预计会转为磁盘临时表",
+	"EXP.002.disk":            "{{.Estimate}}，超过 tmp_table_size 与 max_heap_table_size 中较小的值({{.Limit}})，内存临时表将转为磁盘临时表。建议为 GROUP BY, DISTINCT 的列添加索引，或减少写入临时表的行数及列数。",
+	"MAT.derived":             "派生表",
+	"MAT.cte":                 "CTE",
+	"MAT.reason.aggregate":    "聚合函数",
+	"MAT.reason.subquery":     "SELECT 列表中的子查询",
+	"MAT.001.reasons":         "{{.Kind}} `{{.Alias}}` 中使用了 {{join .Reasons \", \"}}，需要物化为临时表。",
+	"MAT.001.unmergeable":     "目标数据库版本不支持派生表合并，{{.Kind}} `{{.Alias}}` 需要物化为临时表。",
+	"MAT.001.pushdown":        "外层条件 `{{.Condition}}` 只引用了{{.Kind}} `{{.Alias}}` 的列，可以移到{{.Kind}}内部减少物化的行数。",
+	"MAT.002.estimate":        "派生表 {{.Name}} ({{join .Tables \", \"}}) 预计 {{.Rows}} 行，平均每行 {{.RowLength}}，共约 {{.Size}}",
+	"MAT.002.memory.summary":  "物化的派生表可以在内存中完成",
+	"MAT.002.memory":          "{{.Estimate}}，小于 tmp_table_size 与 max_heap_table_size 中较小的值({{.Limit}})。",
+	"MAT.002.disk.summary":    "物化的派生表预计会转为磁盘临时表",
+	"MAT.002.disk":            "{{.Estimate}}，超过 tmp_table_size 与 max_heap_table_size 中较小的值({{.Limit}})，物化时将使用磁盘临时表。建议将外层查询的过滤条件移到派生表内部，或为 {{join .Tables \", \"}} 上的过滤及关联列添加索引，减少物化的行数。",
+	"MAT.002.lateral":         "该派生表依赖外层查询（LATERAL），外层的每一行都需要重新物化一次。",
+	"MAT.002.autokey":         "外层查询通过自动创建的索引访问物化表，创建该索引会增加物化的开销。",
+	"SYS.001":                 "{{join .Tables \", \"}} 的数据及索引共 {{.Size}}，innodb_buffer_pool_size 为 {{.Pool}}。",
+	"SYS.002.flush_log":       "innodb_flush_log_at_trx_commit = {{.Value}}，mysqld 崩溃或服务器宕机时可能丢失最近约 1 秒提交的事务。",
+	"SYS.002.sync_binlog":     "sync_binlog = {{.Value}}，服务器宕机时 binlog 中可能缺少已提交的事务，从库及基于 binlog 的恢复会与主库不一致。",
+	"SYS.003.slow_query_log":  "slow_query_log 未开启。",
+	"SYS.003.long_query_time": "long_query_time = {{.Value}} 秒，大部分慢查询不会被记录。",
+	"SYS.004":                 "max_connections = {{.Connections}}，每个连接的 {{join .Variables \", \"}} 共 {{.PerConnection}}，全部连接同时执行时最多需要 {{.Total}}，innodb_buffer_pool_size 为 {{.Pool}}。",
+	"CLU.002.galera":          "Galera 中单个事务的写集不能超过 wsrep_max_ws_size（默认 2GB）及 wsrep_max_ws_rows，大事务在提交时才复制到其他节点，会阻塞其他节点的提交（流控）。",
+	"CLU.002.group":           "Group Replication 中单个事务的大小不能超过 group_replication_transaction_size_limit（MySQL 8.0 默认 150MB），超过时事务回滚，大事务还会导致各节点的认证及应用延迟。",
+	"CLU.002.update":          "UPDATE 没有 WHERE 条件，将在一个事务中修改整张表。",
+	"CLU.002.delete":          "DELETE 没有 WHERE 条件，将在一个事务中删除整张表的数据，清空表请使用 TRUNCATE。",
+	"CLU.002.insert":          "INSERT 一次写入 {{.Rows}} 行，超过 {{.Threshold}} 行。",
+	"CLU.002.insert_select":   "INSERT ... SELECT 写入的行数取决于查询结果，请确认结果集的大小。",
+	"CLU.004.engine":          "语句中指定的存储引擎为 {{.Engine}}。",
+	"CLU.004.tables":          "写入的表使用非 InnoDB 存储引擎: {{join .Tables \", \"}}。",
+}
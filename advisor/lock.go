@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// lockRead 返回 SQL 中的加锁读（SELECT ... FOR UPDATE, LOCK IN SHARE MODE），不是加锁读时返回 nil
+// vitess 不支持 NOWAIT，这里使用 TiDB 的 AST
+func (q *Query4Audit) lockRead() *tidb.SelectStmt {
+	for _, stmt := range q.TiStmt {
+		if sel, ok := stmt.(*tidb.SelectStmt); ok && sel.LockTp != tidb.SelectLockNone {
+			return sel
+		}
+	}
+	return nil
+}
+
+// RuleLockReadWithoutIndex LCK.003
+// 离线只能检查没有 WHERE 条件的加锁读，WHERE 条件能否使用索引在 IndexAdvisor 中结合表结构检查
+func (q *Query4Audit) RuleLockReadWithoutIndex() Rule {
+	var rule = q.RuleOK()
+	sel := q.lockRead()
+	// 带 LIMIT 时只会锁住扫描到的前几行，如按主键领取任务
+	if sel != nil && sel.From != nil && sel.Where == nil && sel.Limit == nil {
+		rule = HeuristicRules["LCK.003"]
+	}
+	return rule
+}
+
+// RuleLockReadWithoutIndex LCK.003
+// 加锁读的 WHERE 条件中没有任何一列是索引的第一列时，InnoDB 需要扫描并锁住全表的记录
+func (idxAdv *IndexAdvisor) RuleLockReadWithoutIndex() Rule {
+	rule := HeuristicRules["OK"]
+	sel, ok := idxAdv.Ast.(*sqlparser.Select)
+	if !ok || sel.Lock == "" || sel.Where == nil || common.Config.TestDSN.Disable {
+		return rule
+	}
+
+	cols := make(map[string][]string)
+	indexed := make(map[string]bool)
+	for _, col := range idxAdv.calcCardinality(CompleteColumnsInfo(idxAdv.Ast, idxAdv.where, idxAdv.vEnv)) {
+		idxMeta := idxAdv.IndexMeta[idxAdv.vEnv.DBHash(col.DB)][col.Table]
+		if idxMeta == nil {
+			continue
+		}
+		cols[col.Table] = append(cols[col.Table], col.Name)
+		for _, idx := range idxMeta.Rows {
+			if idx.SeqInIndex == 1 && strings.EqualFold(idx.ColumnName, col.Name) {
+				indexed[col.Table] = true
+			}
+		}
+	}
+
+	var tables []string
+	for tb := range cols {
+		if !indexed[tb] {
+			tables = append(tables, tb)
+		}
+	}
+	sort.Strings(tables)
+	var fixes []string
+	for _, tb := range tables {
+		fixes = append(fixes, fmt.Sprintf("`%s` 表的查询条件 `%s` 上没有可用的索引。", tb, strings.Join(cols[tb], "`, `")))
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["LCK.003"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// RuleLockReadQueue LCK.005
+// 带 LIMIT 的 FOR UPDATE 通常用于从表中领取任务，目标数据库确定不支持 SKIP LOCKED 时不给出建议
+func (q *Query4Audit) RuleLockReadQueue() Rule {
+	var rule = q.RuleOK()
+	sel := q.lockRead()
+	if sel == nil || sel.LockTp != tidb.SelectLockForUpdate || sel.Limit == nil {
+		return rule
+	}
+	// MySQL 8.0.1, MariaDB 10.6 开始支持 SKIP LOCKED
+	if common.TargetDB().Unsupported(80001, 100600) {
+		return rule
+	}
+	return HeuristicRules["LCK.005"]
+}
+
+var (
+	txnBeginRe     = regexp.MustCompile(`(?i)^\s*(begin|start\s+transaction)\b`)
+	txnEndRe       = regexp.MustCompile(`(?i)^\s*(commit|rollback)\b`)
+	txnSavepointRe = regexp.MustCompile(`(?i)^\s*rollback\s+(work\s+)?to\b`)
+	txnImplicitRe  = regexp.MustCompile(`(?i)^\s*(create|alter|drop|truncate|rename|lock\s+tables|unlock\s+tables)\b`)
+	txnLockReadRe  = regexp.MustCompile(`(?is)^\s*\(?\s*select\b.*\b(for\s+update|for\s+share|lock\s+in\s+share\s+mode)\b`)
+)
+
+// TransactionChecker 跟踪输入中 BEGIN, START TRANSACTION 开始的显式事务，检查加锁读之后事务是否持续过长（LCK.004）
+// 去重跳过的 SQL 也需要按顺序 Add，每个输入文件使用一个新的 TransactionChecker
+type TransactionChecker struct {
+	inTxn    bool
+	lockLine int // 事务中第一条加锁读所在的行，0 表示还没有加锁读
+	stmts    int // 加锁读之后执行的语句数
+}
+
+// NewTransactionChecker 初始化 TransactionChecker
+func NewTransactionChecker() *TransactionChecker {
+	return &TransactionChecker{}
+}
+
+// Add 按顺序添加一条 SQL，line 为 SQL 所在的行
+// 事务结束时加锁读之后执行的语句数超过阈值则返回 LCK.004，其他情况返回的 Rule.Item 为空
+func (t *TransactionChecker) Add(sql string, line int) Rule {
+	switch {
+	case txnSavepointRe.MatchString(sql):
+		t.count()
+	case txnBeginRe.MatchString(sql):
+		// 事务中再次 BEGIN 会隐式提交之前的事务
+		rule := t.end()
+		t.inTxn = true
+		return rule
+	case txnEndRe.MatchString(sql), txnImplicitRe.MatchString(sql):
+		return t.end()
+	case t.inTxn && t.lockLine == 0 && txnLockReadRe.MatchString(sql):
+		t.lockLine = line
+	default:
+		t.count()
+	}
+	return Rule{}
+}
+
+// count 加锁读之后执行的语句计数
+func (t *TransactionChecker) count() {
+	if t.lockLine > 0 {
+		t.stmts++
+	}
+}
+
+// end 事务结束，重置状态
+func (t *TransactionChecker) end() Rule {
+	var rule Rule
+	if t.lockLine > 0 && t.stmts > RuleThreshold("LCK.004") {
+		rule = HeuristicRules["LCK.004"]
+		rule.Content = strings.Join([]string{rule.Content,
+			fmt.Sprintf("第 %d 行的加锁读之后事务中又执行了 %d 条语句才结束。", t.lockLine, t.stmts)}, " ")
+	}
+	*t = TransactionChecker{}
+	return rule
+}
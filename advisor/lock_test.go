@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// LCK.003
+func TestRuleLockReadWithoutIndex(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := [][]string{
+		{
+			"SELECT * FROM tbl FOR UPDATE",
+			"SELECT * FROM tbl LOCK IN SHARE MODE",
+		},
+		{
+			"SELECT * FROM tbl",
+			"SELECT * FROM tbl WHERE id = 1 FOR UPDATE",
+			"SELECT id FROM jobs ORDER BY id LIMIT 1 FOR UPDATE",
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule := q.RuleLockReadWithoutIndex(); rule.Item != "LCK.003" {
+			t.Errorf("SQL: %s want LCK.003, got: %s", sql, rule.Item)
+		}
+	}
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule := q.RuleLockReadWithoutIndex(); rule.Item != "OK" {
+			t.Errorf("SQL: %s want OK, got: %s", sql, rule.Item)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// LCK.005
+func TestRuleLockReadQueue(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	cases := []struct {
+		target string
+		sql    string
+		want   string
+	}{
+		{"", "SELECT id FROM jobs WHERE status = 0 ORDER BY id LIMIT 1 FOR UPDATE", "LCK.005"},
+		{"mysql:8.0.23", "SELECT id FROM jobs WHERE status = 0 ORDER BY id LIMIT 10 FOR UPDATE", "LCK.005"},
+		{"mysql:5.7", "SELECT id FROM jobs WHERE status = 0 ORDER BY id LIMIT 1 FOR UPDATE", "OK"},
+		{"mariadb:10.6", "SELECT id FROM jobs WHERE status = 0 LIMIT 1 FOR UPDATE", "LCK.005"},
+		{"", "SELECT id FROM jobs WHERE status = 0 ORDER BY id LIMIT 1 FOR UPDATE NOWAIT", "OK"},
+		{"", "SELECT id FROM jobs WHERE status = 0 LIMIT 1 LOCK IN SHARE MODE", "OK"},
+		{"", "SELECT id FROM jobs WHERE id = 1 FOR UPDATE", "OK"},
+	}
+	for _, c := range cases {
+		common.Config.Target = c.target
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule := q.RuleLockReadQueue(); rule.Item != c.want {
+			t.Errorf("target: %s, SQL: %s want %s, got: %s", c.target, c.sql, c.want, rule.Item)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// LCK.004
+func TestTransactionChecker(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgThresholds := common.Config.RuleThresholds
+	defer func() { common.Config.RuleThresholds = orgThresholds }()
+	common.Config.RuleThresholds = map[string]int{"LCK.004": 2}
+
+	cases := []struct {
+		sqls []string
+		want string
+	}{
+		// 加锁读之后执行了 3 条语句
+		{[]string{"BEGIN", "SELECT * FROM t WHERE id = 1 FOR UPDATE", "UPDATE t SET a = 1 WHERE id = 1",
+			"INSERT INTO log VALUES (1)", "SAVEPOINT s1", "COMMIT"}, "第 2 行的加锁读之后事务中又执行了 3 条语句才结束。"},
+		// 加锁之前的语句不计数
+		{[]string{"START TRANSACTION", "UPDATE t SET a = 1", "UPDATE t SET a = 2", "UPDATE t SET a = 3",
+			"SELECT * FROM t WHERE id = 1 FOR UPDATE", "UPDATE t SET a = 4", "ROLLBACK"}, ""},
+		// 不在显式事务中
+		{[]string{"SELECT * FROM t WHERE id = 1 FOR UPDATE", "UPDATE t SET a = 1", "UPDATE t SET a = 2",
+			"UPDATE t SET a = 3", "COMMIT"}, ""},
+		// DDL 隐式提交事务
+		{[]string{"BEGIN", "SELECT * FROM t WHERE id = 1 LOCK IN SHARE MODE", "UPDATE t SET a = 1",
+			"UPDATE t SET a = 2", "UPDATE t SET a = 3", "ALTER TABLE t ADD COLUMN b INT"}, "第 2 行的加锁读之后事务中又执行了 3 条语句才结束。"},
+	}
+	for _, c := range cases {
+		checker := NewTransactionChecker()
+		var got string
+		for i, sql := range c.sqls {
+			if rule := checker.Add(sql, i+1); rule.Item != "" {
+				if rule.Item != "LCK.004" || i != len(c.sqls)-1 {
+					t.Errorf("SQL: %s, got: %s", sql, rule.Item)
+				}
+				got = rule.Content
+			}
+		}
+		if !strings.HasSuffix(got, c.want) || (c.want == "") != (got == "") {
+			t.Errorf("SQLs: %v\nwant: %s\ngot: %s", c.sqls, c.want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,313 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// RuleDerivedMaterialized MAT.001
+// 检查 FROM 子句中无法合并到外层查询的派生表及 CTE，目标数据库不支持条件下推时给出可以移到派生表内部的外层条件
+func (q *Query4Audit) RuleDerivedMaterialized() Rule {
+	var rule = q.RuleOK()
+	var fixes []string
+	if cte := parseCTE(q.Query); cte != nil {
+		// vitess 不支持 WITH，分别解析各个 CTE 及主查询
+		ctes := make(map[string]materializeSource)
+		for _, def := range cte.defs {
+			stmt, err := sqlparser.Parse(def.body)
+			body, ok := stmt.(sqlparser.SelectStatement)
+			if err != nil || !ok {
+				continue
+			}
+			fixes = append(fixes, derivedMaterializeFixes(body, ctes)...)
+			// 递归 CTE 总是需要物化，由 CTE.002 检查
+			if !cte.recursive || !def.selfRecursive() {
+				ctes[strings.ToLower(def.name)] = materializeSource{kind: "MAT.cte", stmt: body, renamed: def.columns}
+			}
+		}
+		if main, err := sqlparser.Parse(cte.main); err == nil {
+			fixes = append(fixes, derivedMaterializeFixes(main, ctes)...)
+		}
+	} else {
+		fixes = derivedMaterializeFixes(q.Stmt, nil)
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["MAT.001"]
+		content := []string{rule.Content}
+		// 多次引用同一 CTE 时只提示一次
+		seen := make(map[string]bool)
+		for _, fix := range fixes {
+			if !seen[fix] {
+				seen[fix] = true
+				content = append(content, fix)
+			}
+		}
+		rule.Content = strings.Join(content, " ")
+	}
+	return rule
+}
+
+// derivedMaterializeFixes 检查 stmt 中需要物化的派生表及对 ctes 中 CTE 的引用，返回物化的原因及可以下推的外层条件
+func derivedMaterializeFixes(stmt sqlparser.SQLNode, ctes map[string]materializeSource) []string {
+	var fixes []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		sel, ok := node.(*sqlparser.Select)
+		if !ok {
+			return true, nil
+		}
+		for _, source := range materializeSources(sel.From, ctes) {
+			reasons := materializeReasons(source.stmt)
+			switch {
+			case len(reasons) > 0:
+				fixes = append(fixes, ruleMessage("MAT.001.reasons", source.message(map[string]interface{}{"Reasons": reasons})))
+			case common.TargetDB().Unsupported(50706, 50300):
+				// MySQL 5.7.6 之前的版本总是物化派生表
+				fixes = append(fixes, ruleMessage("MAT.001.unmergeable", source.message(nil)))
+			default:
+				continue
+			}
+			// MySQL 8.0.22, MariaDB 10.2.2 开始会自动将外层条件下推到物化的派生表中
+			if common.TargetDB().Supports(80022, 100202) || sel.Where == nil || source.renamed {
+				continue
+			}
+			inner, ok := source.stmt.(*sqlparser.Select)
+			if !ok || inner.Limit != nil {
+				continue
+			}
+			for _, cond := range splitAndExpr(sel.Where.Expr) {
+				if pushableCondition(cond, source.alias, inner) {
+					fixes = append(fixes, ruleMessage("MAT.001.pushdown", source.message(map[string]interface{}{"Condition": sqlparser.String(cond)})))
+				}
+			}
+		}
+		return true, nil
+	}, stmt)
+	common.LogIfError(err, "")
+	return fixes
+}
+
+// materializeSource 可能需要物化的派生表或 CTE
+type materializeSource struct {
+	kind    string // 类型名称的消息名，MAT.derived 或 MAT.cte
+	alias   string
+	stmt    sqlparser.SelectStatement
+	renamed bool // CTE 指定了列名，外层查询中的列名与内部不同，不检查条件下推
+}
+
+// message 在 data 中补充派生表的类型及别名，用于渲染 MAT.001 的补充说明
+func (source materializeSource) message(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["Kind"] = ruleMessage(source.kind, nil)
+	data["Alias"] = source.alias
+	return data
+}
+
+// materializeSources 返回 FROM 子句中所有带别名的派生表及对 ctes 中 CTE 的引用，包括 JOIN 中的派生表
+func materializeSources(exprs sqlparser.TableExprs, ctes map[string]materializeSource) []materializeSource {
+	var sources []materializeSource
+	for _, expr := range exprs {
+		switch n := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			switch table := n.Expr.(type) {
+			case *sqlparser.Subquery:
+				sources = append(sources, materializeSource{kind: "MAT.derived", alias: n.As.String(), stmt: table.Select})
+			case sqlparser.TableName:
+				source, ok := ctes[strings.ToLower(table.Name.String())]
+				if !ok || !table.Qualifier.IsEmpty() {
+					continue
+				}
+				source.alias = n.As.String()
+				if source.alias == "" {
+					source.alias = table.Name.String()
+				}
+				sources = append(sources, source)
+			}
+		case *sqlparser.ParenTableExpr:
+			sources = append(sources, materializeSources(n.Exprs, ctes)...)
+		case *sqlparser.JoinTableExpr:
+			sources = append(sources, materializeSources(sqlparser.TableExprs{n.LeftExpr, n.RightExpr}, ctes)...)
+		}
+	}
+	return sources
+}
+
+// materializeReasons 返回派生表无法合并到外层查询的原因，可以合并时返回空
+func materializeReasons(stmt sqlparser.SelectStatement) []string {
+	var reasons []string
+	switch s := stmt.(type) {
+	case *sqlparser.Union:
+		reasons = append(reasons, "UNION")
+	case *sqlparser.ParenSelect:
+		return materializeReasons(s.Select)
+	case *sqlparser.Select:
+		if s.Distinct == sqlparser.DistinctStr {
+			reasons = append(reasons, "DISTINCT")
+		}
+		var aggregate, subquery bool
+		err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			switch n := node.(type) {
+			case *sqlparser.FuncExpr:
+				aggregate = aggregate || n.IsAggregate()
+			case *sqlparser.GroupConcatExpr:
+				aggregate = true
+			case *sqlparser.Subquery:
+				subquery = true
+				return false, nil
+			}
+			return true, nil
+		}, s.SelectExprs)
+		common.LogIfError(err, "")
+		if aggregate {
+			reasons = append(reasons, ruleMessage("MAT.reason.aggregate", nil))
+		}
+		if len(s.GroupBy) > 0 {
+			reasons = append(reasons, "GROUP BY")
+		}
+		if s.Having != nil {
+			reasons = append(reasons, "HAVING")
+		}
+		if s.Limit != nil {
+			reasons = append(reasons, "LIMIT")
+		}
+		if subquery {
+			reasons = append(reasons, ruleMessage("MAT.reason.subquery", nil))
+		}
+	}
+	return reasons
+}
+
+// splitAndExpr 将 AND 连接的条件拆分为多个条件
+func splitAndExpr(expr sqlparser.Expr) []sqlparser.Expr {
+	switch n := expr.(type) {
+	case *sqlparser.AndExpr:
+		return append(splitAndExpr(n.Left), splitAndExpr(n.Right)...)
+	case *sqlparser.ParenExpr:
+		if and, ok := n.Expr.(*sqlparser.AndExpr); ok {
+			return splitAndExpr(and)
+		}
+	}
+	return []sqlparser.Expr{expr}
+}
+
+// pushableCondition 判断外层条件是否只引用了派生表中直接输出的列，且不包含子查询，可以移到派生表内部
+// 派生表中有 GROUP BY 时，条件中的列还需要是分组列
+func pushableCondition(cond sqlparser.Expr, alias string, inner *sqlparser.Select) bool {
+	pushable, found := true, false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			pushable = false
+		case *sqlparser.ColName:
+			found = true
+			if n.Qualifier.Name.String() != alias || derivedColumn(inner, n.Name.String()) == nil {
+				pushable = false
+			}
+		}
+		return pushable, nil
+	}, cond)
+	common.LogIfError(err, "")
+	return pushable && found
+}
+
+// derivedColumn 返回派生表中名为 name 的输出列对应的原始列，输出列不是原始列或不是分组列时返回 nil
+func derivedColumn(inner *sqlparser.Select, name string) *sqlparser.ColName {
+	for _, expr := range inner.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		col, ok := aliased.Expr.(*sqlparser.ColName)
+		if !ok {
+			continue
+		}
+		if !(aliased.As.EqualString(name) || aliased.As.IsEmpty() && col.Name.EqualString(name)) {
+			continue
+		}
+		if len(inner.GroupBy) == 0 {
+			return col
+		}
+		for _, g := range inner.GroupBy {
+			if gc, ok := g.(*sqlparser.ColName); ok && gc.Equal(col) {
+				return col
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// ExplainMaterializeAdvisor 估算 EXPLAIN 中物化的派生表大小，给出 MAT.002 建议
+// 行数为 EXPLAIN 中派生表的 rows，行长度优先使用 JSON 格式中的 data_read_per_join，否则为物化查询中各表平均行长度之和
+// 与 tmp_table_size 比较后按超出的程度调整建议级别，无法估算行长度时不给出建议
+func ExplainMaterializeAdvisor(exp *database.ExplainInfo, info *database.SpillInfo) map[string]Rule {
+	rules := make(map[string]Rule)
+	if exp == nil || info == nil || info.TmpTableSize <= 0 {
+		return rules
+	}
+	for _, mt := range database.MaterializedTables(exp) {
+		rowLength := mt.RowBytes
+		if rowLength <= 0 {
+			for _, tb := range mt.Tables {
+				rowLength += info.RowLength[tb]
+			}
+		}
+		if rowLength <= 0 {
+			continue
+		}
+		size := float64(mt.Rows) * float64(rowLength)
+		if size > 1<<60 {
+			size = 1 << 60
+		}
+		bytes := int64(size)
+		key := "MAT.002.memory"
+		if bytes > info.TmpTableSize {
+			key = "MAT.002.disk"
+		}
+		rule := Rule{
+			Item:     "MAT.002",
+			Severity: explainSpillSeverity(bytes, info.TmpTableSize),
+			Summary:  ruleMessage(key+".summary", nil),
+			Func:     (*Query4Audit).RuleOK,
+		}
+		rule.Content = ruleMessage(key, map[string]interface{}{
+			"Estimate": ruleMessage("MAT.002.estimate", map[string]interface{}{
+				"Name": mt.Name, "Tables": mt.Tables, "Rows": mt.Rows,
+				"RowLength": database.FormatBytes(rowLength), "Size": database.FormatBytes(bytes),
+			}),
+			"Limit":  database.FormatBytes(info.TmpTableSize),
+			"Tables": mt.Tables,
+		})
+		if mt.Dependent {
+			rule.Content += " " + ruleMessage("MAT.002.lateral", nil)
+		}
+		if mt.AutoKey {
+			rule.Content += " " + ruleMessage("MAT.002.autokey", nil)
+		}
+		if old, ok := rules[rule.Item]; !ok || old.Severity < rule.Severity {
+			rules[rule.Item] = rule
+		}
+	}
+	return rules
+}
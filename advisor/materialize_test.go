@@ -0,0 +1,177 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// MAT.001
+func TestRuleDerivedMaterialized(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	common.Config.Target = "mysql:5.7.30"
+	sqls := [][]string{
+		{
+			"SELECT * FROM (SELECT customer_id, COUNT(*) AS cnt FROM payment GROUP BY customer_id) p WHERE p.customer_id < 100",
+			"SELECT * FROM film f JOIN (SELECT DISTINCT film_id FROM inventory) i ON f.film_id = i.film_id",
+			"SELECT * FROM (SELECT film_id FROM film UNION SELECT film_id FROM inventory) t",
+			"SELECT * FROM (SELECT actor_id, (SELECT COUNT(*) FROM film_actor fa WHERE fa.actor_id = a.actor_id) AS cnt FROM actor a) t",
+			"SELECT * FROM (SELECT * FROM film ORDER BY title LIMIT 10) t",
+		},
+		{
+			"SELECT * FROM (SELECT film_id, title FROM film WHERE length > 100) t WHERE t.film_id < 100",
+			"SELECT * FROM film WHERE film_id IN (SELECT film_id FROM inventory GROUP BY film_id)",
+			"SELECT * FROM film",
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleDerivedMaterialized()
+			if rule.Item != "MAT.001" {
+				t.Error("Rule not match:", rule.Item, "Expect : MAT.001", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleDerivedMaterialized()
+			if rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+
+	// 外层条件下推
+	q, _ := NewQuery4Audit("SELECT * FROM (SELECT customer_id, COUNT(*) AS cnt FROM payment GROUP BY customer_id) p WHERE p.customer_id < 100 AND p.cnt > 10")
+	rule := q.RuleDerivedMaterialized()
+	if !strings.Contains(rule.Content, "The derived table `p` uses aggregate functions, GROUP BY") ||
+		!strings.Contains(rule.Content, "The outer condition `p.customer_id < 100`") || strings.Contains(rule.Content, "p.cnt > 10") {
+		t.Errorf("unexpected content: %s", rule.Content)
+	}
+
+	// MySQL 8.0.22 会自动下推外层条件
+	common.Config.Target = "mysql:8.0.22"
+	if rule = q.RuleDerivedMaterialized(); rule.Item != "MAT.001" || strings.Contains(rule.Content, "outer condition") {
+		t.Errorf("unexpected content: %s", rule.Content)
+	}
+
+	// MySQL 5.6 不支持派生表合并
+	common.Config.Target = "mysql:5.6"
+	q, _ = NewQuery4Audit(sqls[1][0])
+	if rule = q.RuleDerivedMaterialized(); !strings.Contains(rule.Content, "cannot merge derived tables") ||
+		!strings.Contains(rule.Content, "The outer condition `t.film_id < 100`") {
+		t.Errorf("unexpected content: %s", rule.Content)
+	}
+	common.Config.Target = orgTarget
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// MAT.001
+func TestRuleDerivedMaterializedCTE(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	common.Config.Target = "mysql:8.0.21"
+	sqls := map[string][]string{
+		"WITH p AS (SELECT customer_id, COUNT(*) AS cnt FROM payment GROUP BY customer_id) SELECT * FROM p WHERE p.customer_id < 100 AND p.cnt > 10": {
+			"The CTE `p` uses aggregate functions, GROUP BY", "The outer condition `p.customer_id < 100` only references columns of the CTE `p`",
+		},
+		"WITH t AS (SELECT DISTINCT film_id FROM inventory) SELECT * FROM film f JOIN t i ON f.film_id = i.film_id JOIN t j ON f.film_id = j.film_id": {
+			"The CTE `i` uses DISTINCT", "The CTE `j` uses DISTINCT",
+		},
+		"WITH t AS (SELECT * FROM (SELECT film_id FROM film UNION SELECT film_id FROM inventory) u) SELECT * FROM t": {
+			"The derived table `u` uses UNION",
+		},
+		"WITH t (id, cnt) AS (SELECT customer_id, COUNT(*) FROM payment GROUP BY customer_id) SELECT * FROM t WHERE t.id < 100": {
+			"The CTE `t` uses aggregate functions, GROUP BY",
+		},
+		"WITH t AS (SELECT film_id, title FROM film WHERE length > 100) SELECT * FROM t WHERE t.film_id < 100": nil,
+		"WITH RECURSIVE seq AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM seq WHERE n < 10) SELECT * FROM seq": nil,
+	}
+	for sql, wants := range sqls {
+		q, _ := NewQuery4Audit(sql)
+		rule := q.RuleDerivedMaterialized()
+		if len(wants) == 0 && rule.Item != "OK" || len(wants) > 0 && rule.Item != "MAT.001" {
+			t.Errorf("SQL: %s\ngot: %s %s", sql, rule.Item, rule.Content)
+			continue
+		}
+		for _, want := range wants {
+			if !strings.Contains(rule.Content, want) {
+				t.Errorf("SQL: %s\nwant: %s\ngot: %s", sql, want, rule.Content)
+			}
+		}
+		if strings.Contains(rule.Content, "p.cnt > 10") || strings.Contains(rule.Content, "t.id < 100") {
+			t.Errorf("SQL: %s\nunexpected content: %s", sql, rule.Content)
+		}
+	}
+	common.Config.Target = orgTarget
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExplainMaterializeAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	exp := &database.ExplainInfo{
+		ExplainRows: []database.ExplainRow{
+			{ID: 1, SelectType: "PRIMARY", TableName: "c", Rows: 599, Filtered: 100},
+			{ID: 1, SelectType: "PRIMARY", TableName: "<derived2>", Key: "<auto_key0>", Rows: 100000, Filtered: 100},
+			{ID: 2, SelectType: "DERIVED", TableName: "p", Rows: 100000, Filtered: 100},
+		},
+	}
+	info := &database.SpillInfo{
+		TmpTableSize: 16 << 20,
+		RowLength:    map[string]int64{"c": 120, "p": 64},
+	}
+
+	// 100000 * 64B = 6.1MB，小于 16MB
+	rules := ExplainMaterializeAdvisor(exp, info)
+	if rule := rules["MAT.002"]; rule.Severity != "L0" || !strings.Contains(rule.Content, "automatically created index") {
+		t.Errorf("unexpected suggestion: %+v", rule)
+	}
+
+	info.TmpTableSize = 1 << 20
+	rules = ExplainMaterializeAdvisor(exp, info)
+	if rule := rules["MAT.002"]; rule.Severity != "L3" || !strings.Contains(rule.Content, "index the filter and join columns of p") {
+		t.Errorf("unexpected suggestion: %+v", rule)
+	}
+
+	// 补充说明使用 -lang 指定的语言
+	if err := LoadRuleLocale("zh-CN", ""); err != nil {
+		t.Fatal(err)
+	}
+	rules = ExplainMaterializeAdvisor(exp, info)
+	common.LogIfError(LoadRuleLocale(common.Config.Lang, ""), "")
+	if rule := rules["MAT.002"]; rule.Summary != "物化的派生表预计会转为磁盘临时表" || !strings.Contains(rule.Content, "为 p 上的过滤及关联列添加索引") {
+		t.Errorf("unexpected suggestion: %+v", rule)
+	}
+
+	// 无法获取行长度时不给出建议
+	info.RowLength = map[string]int64{}
+	if rules = ExplainMaterializeAdvisor(exp, info); len(rules) != 0 {
+		t.Errorf("want no suggestion, got %+v", rules)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// moneyColumnRe 按命名判断为金额或数量的列
+var moneyColumnRe = regexp.MustCompile(`(?i)(^|_)(price|amount|amt|cost|fee|fees|money|balance|total|subtotal|salary|wage|payment|tax|discount|charge|revenue|refund|income|profit|budget|deposit|credit|debit|quantity|qty)(_|$)`)
+
+// moneyExcludeRe 包含金额关键字但表示时间、次数、比例等的列，如 total_time, fee_rate
+var moneyExcludeRe = regexp.MustCompile(`(?i)(^|_)(time|date|at|count|cnt|num|id|rate|ratio|percent|pct)$`)
+
+// moneyColumn 按列名判断是否为金额或数量列
+func moneyColumn(name string) bool {
+	return moneyColumnRe.MatchString(name) && !moneyExcludeRe.MatchString(name)
+}
+
+// moneyDefaultDecimal 无法获取列的取值时建议使用的 DECIMAL 类型
+const moneyDefaultDecimal = "DECIMAL(19,4)"
+
+// moneyHeadroom 按采样值计算精度时为整数部分预留的位数
+const moneyHeadroom = 2
+
+// moneyMaxScale 采样值中超过该位数的小数多为浮点误差，按该位数保留
+const moneyMaxScale = 6
+
+// moneyDecimal 根据采样得到的整数及小数位数给出 DECIMAL 类型，小数至少保留两位
+func moneyDecimal(intDigits, scale int) string {
+	if scale < 2 {
+		scale = 2
+	}
+	if scale > moneyMaxScale {
+		scale = moneyMaxScale
+	}
+	precision := intDigits + moneyHeadroom + scale
+	if precision > 65 {
+		precision = 65
+	}
+	return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale)
+}
+
+// floatMoneyColumns 使用 FLOAT 或 DOUBLE 保存的金额、数量列及建议的 DECIMAL 类型
+// 列定义中指定了 (M,D) 时保持相同的精度，否则使用 moneyDefaultDecimal
+func floatMoneyColumns(cols []*tidb.ColumnDef) []string {
+	var notes []string
+	for _, c := range cols {
+		if c.Tp == nil || (c.Tp.Tp != mysql.TypeFloat && c.Tp.Tp != mysql.TypeDouble) || !moneyColumn(c.Name.Name.O) {
+			continue
+		}
+		decimal := moneyDefaultDecimal
+		if c.Tp.Flen > 0 && c.Tp.Decimal > 0 {
+			decimal = fmt.Sprintf("DECIMAL(%d,%d)", c.Tp.Flen, c.Tp.Decimal)
+		}
+		notes = append(notes, fmt.Sprintf("金额或数量列 `%s` 使用了 %s，建议使用 %s。", c.Name.Name.O, strings.ToUpper(c.Tp.InfoSchemaStr()), decimal))
+	}
+	return notes
+}
+
+// floatDecimalMix 根据离线表结构查找 FLOAT/DOUBLE 列与 DECIMAL 列的比较及运算，混合运算时 DECIMAL 会先转换为 DOUBLE
+func floatDecimalMix(stmt sqlparser.Statement) []string {
+	if len(offlineSchema) == 0 || stmt == nil {
+		return nil
+	}
+	tables := offlineTableMap(stmt)
+	if len(tables) == 0 {
+		return nil
+	}
+	class := func(expr sqlparser.Expr) (*common.Column, string) {
+		col, ok := expr.(*sqlparser.ColName)
+		if !ok {
+			return nil, ""
+		}
+		c := offlineColumn(col, tables)
+		if c == nil {
+			return nil, ""
+		}
+		switch strings.ToLower(strings.Fields(common.GetDataTypeBase(c.DataType) + " ")[0]) {
+		case "float", "double", "real":
+			return c, "float"
+		case "decimal", "numeric":
+			return c, "decimal"
+		}
+		return nil, ""
+	}
+
+	var notes []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		var left, right sqlparser.Expr
+		switch n := node.(type) {
+		case *sqlparser.BinaryExpr:
+			left, right = n.Left, n.Right
+		case *sqlparser.ComparisonExpr:
+			left, right = n.Left, n.Right
+		default:
+			return true, nil
+		}
+		l, lc := class(left)
+		r, rc := class(right)
+		if lc == "" || rc == "" || lc == rc {
+			return true, nil
+		}
+		float := l
+		if rc == "float" {
+			float = r
+		}
+		notes = append(notes, fmt.Sprintf("`%s` 中 FLOAT/DOUBLE 与 DECIMAL 混合计算，结果按 DOUBLE 计算会丢失精度，建议将 `%s`.`%s` 修改为 DECIMAL。",
+			sqlparser.String(node), float.Table, float.Name))
+		return true, nil
+	}, stmt)
+	common.LogIfError(err, "")
+	return common.RemoveDuplicatesItem(notes)
+}
+
+// RuleMoneyPrecision COL.009
+// 开启数据采样时根据测试环境中采样数据的实际取值，为使用 FLOAT/DOUBLE 保存的金额、数量列给出 DECIMAL(p,s) 建议
+func (idxAdv *IndexAdvisor) RuleMoneyPrecision() Rule {
+	rule := HeuristicRules["OK"]
+	if !common.Config.Sampling || idxAdv.Ast == nil || idxAdv.vEnv == nil || idxAdv.vEnv.Connector == nil {
+		return rule
+	}
+	// DDL 语句在测试环境中没有采样数据
+	if _, ok := idxAdv.Ast.(*sqlparser.DDL); ok {
+		return rule
+	}
+
+	meta := ast.GetMeta(idxAdv.Ast, nil)
+	var dbs []string
+	for db := range meta {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+	var fixes []string
+	for _, db := range dbs {
+		dbRef := db
+		if dbRef == "" {
+			dbRef = idxAdv.rEnv.Database
+		}
+		conn := *idxAdv.vEnv.Connector
+		conn.Database = idxAdv.vEnv.DBHash(dbRef)
+		var tables []string
+		for tb := range meta[db].Table {
+			if tb != "" {
+				tables = append(tables, tb)
+			}
+		}
+		sort.Strings(tables)
+		for _, tb := range tables {
+			desc, err := conn.ShowColumns(tb)
+			if err != nil {
+				common.Log.Warn("RuleMoneyPrecision ShowColumns Error: %v", err)
+				continue
+			}
+			for _, col := range desc.DescValues {
+				base := strings.ToLower(strings.Fields(common.GetDataTypeBase(col.Type) + " ")[0])
+				if (base != "float" && base != "double") || !moneyColumn(col.Field) {
+					continue
+				}
+				intDigits, scale, err := conn.NumericDigits(tb, col.Field, common.Config.SamplingStatisticTarget*300)
+				if err != nil {
+					common.Log.Warn("RuleMoneyPrecision NumericDigits Error: %v", err)
+					continue
+				}
+				if intDigits < 0 {
+					continue
+				}
+				notNull := ""
+				if col.Null == "NO" {
+					notNull = " NOT NULL"
+				}
+				fixes = append(fixes, fmt.Sprintf("采样数据中 `%s`.`%s` 整数部分最多 %d 位，小数部分最多 %d 位: ALTER TABLE `%s`.`%s` MODIFY `%s` %s%s;",
+					tb, col.Field, intDigits, scale, dbRef, tb, col.Field, moneyDecimal(intDigits, scale), notNull))
+			}
+		}
+	}
+	if len(fixes) > 0 {
+		rule = HeuristicRules["COL.009"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
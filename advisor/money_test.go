@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestMoneyDecimal(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[[2]int]string{
+		{3, 2}:  "DECIMAL(7,2)",
+		{5, 0}:  "DECIMAL(9,2)",
+		{0, 4}:  "DECIMAL(6,4)",
+		{8, 17}: "DECIMAL(16,6)",
+		{62, 2}: "DECIMAL(65,2)",
+	}
+	for digits, want := range cases {
+		if got := moneyDecimal(digits[0], digits[1]); got != want {
+			t.Errorf("%v want: %s, got: %s", digits, want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// COL.009
+func TestRuleImpreciseDataTypeMoney(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"CREATE TABLE orders (id INT PRIMARY KEY, total_price FLOAT NOT NULL, weight FLOAT)": "金额或数量列 `total_price` 使用了 FLOAT，建议使用 DECIMAL(19,4)。",
+		"ALTER TABLE orders MODIFY fee DOUBLE(10,3), ADD COLUMN qty DOUBLE":                  "金额或数量列 `fee` 使用了 DOUBLE(10,3)，建议使用 DECIMAL(10,3)。 金额或数量列 `qty` 使用了 DOUBLE，建议使用 DECIMAL(19,4)。",
+		"CREATE TABLE orders (id INT PRIMARY KEY, price DECIMAL(10,2), total_time FLOAT)":    "",
+		"CREATE TABLE orders (id INT PRIMARY KEY, price DECIMAL(10,2), totally_float FLOAT)": "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleImpreciseDataType()
+		if rule.Item != "COL.009" {
+			t.Errorf("SQL: %s, got: %s", sql, rule.Item)
+			continue
+		}
+		if want == "" {
+			if strings.Contains(rule.Content, "金额或数量列") {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if !strings.HasSuffix(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// COL.009
+func TestFloatDecimalMix(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSchema, orgNames, orgFKs := offlineSchema, offlineColumnNames, offlineForeignKeys
+	defer func() { offlineSchema, offlineColumnNames, offlineForeignKeys = orgSchema, orgNames, orgFKs }()
+	offlineSchema = make(map[string]map[string]*common.Column)
+	offlineColumnNames = make(map[string][]string)
+	offlineForeignKeys = make(map[string][]foreignKey)
+	q, err := NewQuery4Audit("CREATE TABLE item (id INT PRIMARY KEY, price DECIMAL(10,2), rate FLOAT, cnt INT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddOfflineSchema(q)
+
+	sqls := map[string]string{
+		"SELECT price * rate FROM item":                 "`price * rate` 中 FLOAT/DOUBLE 与 DECIMAL 混合计算，结果按 DOUBLE 计算会丢失精度，建议将 `item`.`rate` 修改为 DECIMAL。",
+		"SELECT id FROM item i WHERE i.rate > i.price":  "`i.rate > i.price` 中 FLOAT/DOUBLE 与 DECIMAL 混合计算",
+		"SELECT price * cnt FROM item WHERE price > 10": "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleImpreciseDataType()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "COL.009" || !strings.Contains(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// offlineSchema 离线表结构，来自 -schema-file 及输入中的建表语句，map[table]map[column]*common.Column，表名和列名均为小写
+var offlineSchema = make(map[string]map[string]*common.Column)
+
+// offlineColumnNames 离线表结构中各表按定义顺序排列的列名，表名为小写
+var offlineColumnNames = make(map[string][]string)
+
+// offlineForeignKeys 离线表结构中的外键，map[table][]foreignKey，表名为小写
+var offlineForeignKeys = make(map[string][]foreignKey)
+
+// offlineIndexes 离线表结构中各索引（包括主键、唯一键）按顺序排列的列名，表名和列名均为小写
+var offlineIndexes = make(map[string][][]string)
+
+// foreignKey 外键关系，Columns 与 RefColumns 按顺序一一对应，表名和列名均为小写
+type foreignKey struct {
+	Table      string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// LoadOfflineSchema 读取 mysqldump --no-data 导出的建表语句作为离线表结构
+func LoadOfflineSchema(buf string) {
+	for _, tb := range parseSchemaDump(buf) {
+		if stmt, err := sqlparser.Parse(tb.Query); err == nil {
+			addOfflineSpatial(stmt)
+		}
+		stmts, err := ast.TiParse(tb.Query, "", "")
+		if err != nil {
+			continue
+		}
+		addOfflineSchema(tb.Database, stmts)
+	}
+}
+
+// AddOfflineSchema 记录输入中的建表语句，供后续 SQL 的离线检查使用，只记录解析成功的部分
+func AddOfflineSchema(q *Query4Audit) {
+	addOfflineSpatial(q.Stmt)
+	addOfflineSchema("", q.TiStmt)
+}
+
+func addOfflineSchema(db string, stmts []tidb.StmtNode) {
+	for _, stmt := range stmts {
+		ct, ok := stmt.(*tidb.CreateTableStmt)
+		if !ok {
+			continue
+		}
+		if ct.Table.Schema.O != "" {
+			db = ct.Table.Schema.O
+		}
+		cols := make(map[string]*common.Column)
+		var names []string
+		for _, col := range ct.Cols {
+			if col.Tp == nil {
+				continue
+			}
+			names = append(names, col.Name.Name.O)
+			cols[col.Name.Name.L] = &common.Column{
+				Name:      col.Name.Name.O,
+				Table:     ct.Table.Name.O,
+				DB:        db,
+				DataType:  col.Tp.InfoSchemaStr(),
+				Character: col.Tp.Charset,
+				Collation: col.Tp.Collate,
+			}
+		}
+		var indexes [][]string
+		for _, col := range ct.Cols {
+			for _, opt := range col.Options {
+				if opt.Tp == tidb.ColumnOptionPrimaryKey || opt.Tp == tidb.ColumnOptionUniqKey {
+					indexes = append(indexes, []string{col.Name.Name.L})
+				}
+			}
+		}
+		for _, cons := range ct.Constraints {
+			switch cons.Tp {
+			case tidb.ConstraintPrimaryKey, tidb.ConstraintKey, tidb.ConstraintIndex,
+				tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+				var idx []string
+				for _, key := range cons.Keys {
+					if key.Column != nil {
+						idx = append(idx, key.Column.Name.L)
+					}
+				}
+				indexes = append(indexes, idx)
+			}
+		}
+		// MySQL 会忽略列定义中的 REFERENCES，只有表级的 FOREIGN KEY 才会生效
+		var fks []foreignKey
+		for _, cons := range ct.Constraints {
+			if cons.Tp != tidb.ConstraintForeignKey || cons.Refer == nil {
+				continue
+			}
+			fk := foreignKey{Table: ct.Table.Name.L, RefTable: cons.Refer.Table.Name.L}
+			for _, key := range cons.Keys {
+				fk.Columns = append(fk.Columns, key.Column.Name.L)
+			}
+			for _, key := range cons.Refer.IndexColNames {
+				fk.RefColumns = append(fk.RefColumns, key.Column.Name.L)
+			}
+			if len(fk.Columns) == len(fk.RefColumns) {
+				fks = append(fks, fk)
+			}
+		}
+		common.Log.Debug("addOfflineSchema: %s.%s", db, ct.Table.Name.O)
+		offlineSchema[ct.Table.Name.L] = cols
+		offlineColumnNames[ct.Table.Name.L] = names
+		offlineForeignKeys[ct.Table.Name.L] = fks
+		offlineIndexes[ct.Table.Name.L] = indexes
+	}
+}
+
+// offlineColumn 从离线表结构中查找列定义，tables 为 SQL 中的别名与表名的对应关系
+// 未指定表名的列只在 SQL 引用的表中唯一存在时才能确定
+func offlineColumn(col *sqlparser.ColName, tables map[string]string) *common.Column {
+	name := strings.ToLower(col.Name.String())
+	if !col.Qualifier.Name.IsEmpty() {
+		tb, ok := tables[strings.ToLower(col.Qualifier.Name.String())]
+		if !ok {
+			return nil
+		}
+		return offlineSchema[tb][name]
+	}
+
+	var found *common.Column
+	for alias, tb := range tables {
+		// 表名和别名指向同一张表，只检查表名
+		if alias != tb {
+			continue
+		}
+		if c, ok := offlineSchema[tb][name]; ok {
+			if found != nil {
+				return nil
+			}
+			found = c
+		}
+	}
+	return found
+}
+
+// offlineTableMap SQL 中引用的离线表结构中的表，别名及表名到表名的映射，均为小写
+func offlineTableMap(stmt sqlparser.Statement) map[string]string {
+	tables := make(map[string]string)
+	for _, db := range ast.GetMeta(stmt, nil) {
+		for _, tb := range db.Table {
+			name := strings.ToLower(tb.TableName)
+			if _, ok := offlineSchema[name]; !ok {
+				continue
+			}
+			tables[name] = name
+			for _, alias := range tb.TableAliases {
+				tables[strings.ToLower(alias)] = name
+			}
+		}
+	}
+	return tables
+}
+
+// columnTypeClass 将列类型归为数值和字符串两类，其他类型（时间、ENUM、JSON、BLOB 等）返回空
+func columnTypeClass(dataType string) string {
+	base := strings.Fields(strings.ToLower(common.GetDataTypeBase(dataType)))
+	if len(base) == 0 {
+		return ""
+	}
+	switch base[0] {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+		"decimal", "numeric", "float", "double", "real":
+		return "number"
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		return "string"
+	}
+	return ""
+}
+
+// valueTypeClass 常量的类型，与 columnTypeClass 对应
+func valueTypeClass(val *sqlparser.SQLVal) string {
+	switch val.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal:
+		return "number"
+	case sqlparser.StrVal:
+		return "string"
+	}
+	return ""
+}
+
+// RuleImplicitConversion ARG.003
+// 根据离线表结构检查条件中列与常量、列与列的类型是否一致，测试环境中的检查见 IndexAdvisor.RuleImplicitConversion
+func (q *Query4Audit) RuleImplicitConversion() Rule {
+	var rule = q.RuleOK()
+	if len(offlineSchema) == 0 || q.Stmt == nil {
+		return rule
+	}
+
+	tables := offlineTableMap(q.Stmt)
+	if len(tables) == 0 {
+		return rule
+	}
+
+	var content []string
+	check := func(cond sqlparser.Expr, col *sqlparser.ColName, exprs ...sqlparser.Expr) {
+		c := offlineColumn(col, tables)
+		if c == nil {
+			return
+		}
+		colClass := columnTypeClass(c.DataType)
+		if colClass == "" {
+			return
+		}
+		for _, expr := range exprs {
+			var vals []*sqlparser.SQLVal
+			switch e := expr.(type) {
+			case *sqlparser.SQLVal:
+				vals = append(vals, e)
+			case sqlparser.ValTuple:
+				for _, v := range e {
+					if val, ok := v.(*sqlparser.SQLVal); ok {
+						vals = append(vals, val)
+					}
+				}
+			case *sqlparser.ColName:
+				// 列与列比较
+				other := offlineColumn(e, tables)
+				if other == nil {
+					continue
+				}
+				if otherClass := columnTypeClass(other.DataType); otherClass != "" && otherClass != colClass {
+					content = append(content, fmt.Sprintf("`%s`.`%s` (%s) VS `%s`.`%s` (%s) datatype not match: %s",
+						c.Table, c.Name, c.DataType, other.Table, other.Name, other.DataType, sqlparser.String(cond)))
+				}
+			}
+			for _, val := range vals {
+				if valClass := valueTypeClass(val); valClass != "" && valClass != colClass {
+					content = append(content, fmt.Sprintf("%s表中列%s的定义是 %s 而不是 %s: %s",
+						c.Table, c.Name, c.DataType, valClass, sqlparser.String(cond)))
+					break
+				}
+			}
+		}
+	}
+
+	for _, cond := range ast.FindAllCondition(q.Stmt) {
+		switch node := cond.(type) {
+		case *sqlparser.ComparisonExpr:
+			switch node.Operator {
+			case sqlparser.LikeStr, sqlparser.NotLikeStr, sqlparser.RegexpStr, sqlparser.NotRegexpStr:
+				// LIKE, REGEXP 按字符串匹配
+				continue
+			}
+			if left, ok := node.Left.(*sqlparser.ColName); ok {
+				check(node, left, node.Right)
+			} else if right, ok := node.Right.(*sqlparser.ColName); ok {
+				check(node, right, node.Left)
+			}
+		case *sqlparser.RangeCond:
+			if left, ok := node.Left.(*sqlparser.ColName); ok {
+				check(node, left, node.From, node.To)
+			}
+		}
+	}
+
+	if len(content) > 0 {
+		rule = HeuristicRules["ARG.003"]
+		rule.Content = strings.Join(common.RemoveDuplicatesItem(content), " ")
+	}
+	return rule
+}
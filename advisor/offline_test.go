@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// ARG.003
+func TestRuleImplicitConversionOffline(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSchema := offlineSchema
+	defer func() { offlineSchema = orgSchema }()
+	offlineSchema = make(map[string]map[string]*common.Column)
+
+	LoadOfflineSchema("CREATE TABLE `film` (`film_id` int NOT NULL, `title` varchar(128), `length` smallint unsigned, `last_update` timestamp, PRIMARY KEY (`film_id`));")
+	q, err := NewQuery4Audit("CREATE TABLE actor (actor_id int, first_name varchar(45))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddOfflineSchema(q)
+
+	sqls := map[string][]string{
+		"SELECT * FROM film WHERE length >= '60'":                                 {"length >= '60'"},
+		"SELECT * FROM film f WHERE f.title = 123":                                {"f.title = 123"},
+		"SELECT * FROM film WHERE film_id BETWEEN 1 AND '10'":                     {"film_id between 1 and '10'"},
+		"SELECT * FROM film WHERE title IN ('a', 1)":                              {"title in ('a', 1)"},
+		"SELECT * FROM film f JOIN actor a ON f.title = a.actor_id":               {"datatype not match"},
+		"SELECT * FROM film WHERE length >= 60 AND title = 'a'":                   nil,
+		"SELECT * FROM film WHERE last_update > '2006-01-01' AND title LIKE 1":    nil,
+		"SELECT * FROM film f JOIN actor a ON f.film_id = a.actor_id WHERE a = 1": nil,
+		"SELECT * FROM unknown WHERE title = 1":                                   nil,
+	}
+	for sql, wants := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleImplicitConversion()
+		if len(wants) == 0 {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, want OK, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "ARG.003" {
+			t.Errorf("SQL: %s, want ARG.003, got: %s", sql, rule.Item)
+			continue
+		}
+		for _, want := range wants {
+			if !strings.Contains(rule.Content, want) {
+				t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
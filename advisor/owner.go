@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Unowned 未匹配 owner-file 中任何规则的 SQL 所属的团队
+const Unowned = "unowned"
+
+// ownerRule owner-file 中的一行，匹配 db.table 的正则表达式及负责的团队
+type ownerRule struct {
+	pattern *regexp.Regexp
+	team    string
+}
+
+// ownerRules LoadOwnerFile 加载的库表归属规则，为空时不按团队分组
+var ownerRules []ownerRule
+
+// LoadOwnerFile 加载 owner-file，每行格式为 `正则表达式 团队`，如 `^payments\. team-pay`，# 开头的行为注释
+// 正则表达式匹配 db.table，不区分大小写，按文件中的顺序第一条匹配的规则生效
+func LoadOwnerFile(file string) error {
+	ownerRules = nil
+	if file == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	ownerRules, err = parseOwnerRules(string(data))
+	return err
+}
+
+// parseOwnerRules 解析 owner-file 的内容
+func parseOwnerRules(data string) ([]ownerRule, error) {
+	var rules []ownerRule
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("owner-file line %d format error: '%s', e.g. ^payments\\. team-pay", n, line)
+		}
+		re, err := regexp.Compile("(?i)" + fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("owner-file line %d format error: %v", n, err)
+		}
+		rules = append(rules, ownerRule{pattern: re, team: fields[1]})
+	}
+	return rules, scanner.Err()
+}
+
+// OwnersEnabled 是否配置了 owner-file
+func OwnersEnabled() bool {
+	return len(ownerRules) > 0
+}
+
+// TableOwner 返回库表所属的团队，table 为 `db`.`table` 或 db.table 格式，未匹配时返回 Unowned
+func TableOwner(table string) string {
+	name := strings.Replace(table, "`", "", -1)
+	for _, r := range ownerRules {
+		if r.pattern.MatchString(name) {
+			return r.team
+		}
+	}
+	return Unowned
+}
+
+// QueryOwner 返回 SQL 所属的团队，涉及多个团队的表时以第一张匹配到团队的表为准，未配置 owner-file 时返回空
+func QueryOwner(tables []string) string {
+	if !OwnersEnabled() {
+		return ""
+	}
+	for _, tb := range tables {
+		if owner := TableOwner(tb); owner != Unowned {
+			return owner
+		}
+	}
+	return Unowned
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestQueryOwner(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgRules := ownerRules
+	defer func() { ownerRules = orgRules }()
+
+	ownerRules = nil
+	if owner := QueryOwner([]string{"`sakila`.`film`"}); owner != "" {
+		t.Errorf("want no owner without owner-file, got %s", owner)
+	}
+
+	var err error
+	ownerRules, err = parseOwnerRules(`
+# 支付相关的库
+^payments\.        team-pay
+^sakila\.(film|actor)$ team-catalog
+^sakila\.          team-dba
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tables []string
+		owner  string
+	}{
+		{[]string{"`payments`.`orders`"}, "team-pay"},
+		{[]string{"`SAKILA`.`Film`"}, "team-catalog"},
+		{[]string{"`sakila`.`film_text`"}, "team-dba"},
+		{[]string{"`test`.`t1`", "`sakila`.`actor`"}, "team-catalog"},
+		{[]string{"`test`.`t1`"}, Unowned},
+		{nil, Unowned},
+	}
+	for _, c := range cases {
+		if owner := QueryOwner(c.tables); owner != c.owner {
+			t.Errorf("%v want owner %s, got %s", c.tables, c.owner, owner)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestParseOwnerRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	for _, data := range []string{"^payments\\.", "^payments\\. team-pay extra", "^(payments team-pay"} {
+		if _, err := parseOwnerRules(data); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("%s want format error, got %v", data, err)
+		}
+	}
+	if err := LoadOwnerFile("not-exist-owner-file"); err == nil {
+		t.Error("want error for missing owner-file")
+	}
+	if err := LoadOwnerFile(""); err != nil || OwnersEnabled() {
+		t.Errorf("empty owner-file should disable owners, got %v", err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"github.com/XiaoMi/soar/ast"
+)
+
+// Parser SQL 语法解析器，NewQuery4Audit 依次使用 Parsers 中的解析器解析 SQL 并将 AST 保存在 Query4Audit 中
+// Parse 返回的错误作为语法错误（ERR.000）报告，只用于辅助的解析器出错时应记录日志并返回 nil
+type Parser interface {
+	Name() string
+	Parse(q *Query4Audit, charset, collation string) error
+}
+
+// Parsers 已注册的语法解析器，按顺序执行
+// 目前大部分启发式规则依赖 vitess 的 AST（Query4Audit.Stmt），默认同时使用 vitess 和 TiDB 解析
+// vitess 解析器在 parser_vitess.go 中注册，使用 `-tags novitess` 编译时只使用 TiDB 解析，依赖 Stmt 的规则及索引建议将被跳过
+var Parsers = []Parser{tidbParser{}}
+
+// RegisterParser 注册语法解析器，同名的解析器会被替换，否则追加在最后
+func RegisterParser(p Parser) {
+	for i, parser := range Parsers {
+		if parser.Name() == p.Name() {
+			Parsers[i] = p
+			return
+		}
+	}
+	Parsers = append(Parsers, p)
+}
+
+// UnregisterParser 移除指定名称的语法解析器，如只使用 TiDB 解析时移除 vitess
+func UnregisterParser(name string) {
+	var parsers []Parser
+	for _, p := range Parsers {
+		if p.Name() != name {
+			parsers = append(parsers, p)
+		}
+	}
+	Parsers = parsers
+}
+
+// tidbParser TiDB 语法解析
+type tidbParser struct{}
+
+func (tidbParser) Name() string {
+	return "tidb"
+}
+
+func (tidbParser) Parse(q *Query4Audit, charset, collation string) error {
+	var err error
+	q.TiStmt, err = ast.TiParse(q.Query, charset, collation)
+	return err
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+type mockParser struct{}
+
+func (mockParser) Name() string {
+	return "mock"
+}
+
+func (mockParser) Parse(q *Query4Audit, charset, collation string) error {
+	return errors.New("mock parse error")
+}
+
+func TestRegisterParser(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgParsers := Parsers
+	defer func() { Parsers = orgParsers }()
+
+	RegisterParser(mockParser{})
+	q, err := NewQuery4Audit("select 1")
+	if err == nil || err.Error() != "mock parse error" {
+		t.Error("want mock parse error, got:", err)
+	}
+	if q.Stmt == nil || len(q.TiStmt) == 0 {
+		t.Error("registered parsers should still be used")
+	}
+
+	UnregisterParser("vitess")
+	UnregisterParser("mock")
+	q, err = NewQuery4Audit("select 1")
+	if err != nil || q.Stmt != nil || len(q.TiStmt) == 0 {
+		t.Error("TiDB only parse not match:", err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestRulesWithoutVitess(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgParsers := Parsers
+	defer func() { Parsers = orgParsers }()
+
+	// 不使用 vitess 解析时 Stmt 为 nil，依赖 Stmt 的规则不给出建议，其他规则仍然正常检查
+	UnregisterParser("vitess")
+	sqls := append([]string{}, common.TestSQLs...)
+	for _, rule := range HeuristicRules {
+		sqls = append(sqls, rule.Case)
+	}
+	for _, sql := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			continue
+		}
+		for item, rule := range HeuristicRules {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%s panic without vitess: %v, SQL: %s", item, r, sql)
+					}
+				}()
+				rule.Func(q)
+			}()
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
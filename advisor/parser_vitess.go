@@ -0,0 +1,47 @@
+//go:build !novitess
+// +build !novitess
+
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// vitess 解析器默认编译，使用 `-tags novitess` 编译时不注册
+func init() {
+	Parsers = append([]Parser{vitessParser{}}, Parsers...)
+}
+
+// vitessParser vitess 语法解析，错误不上报，以 TiDB parser 为主
+type vitessParser struct{}
+
+func (vitessParser) Name() string {
+	return "vitess"
+}
+
+func (vitessParser) Parse(q *Query4Audit, charset, collation string) error {
+	var err error
+	q.Stmt, err = sqlparser.Parse(q.Query)
+	if err != nil {
+		common.Log.Warn("NewQuery4Audit vitess parse Error: %s, Query: %s", err.Error(), q.Query)
+	}
+	return nil
+}
@@ -0,0 +1,435 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// 分区建议中 RANGE 分区预建的月份数及 HASH 分区数
+const (
+	partitionMonths = 12
+	partitionHashes = 16
+)
+
+// partitionPredicate 记录一条 SQL 中对某张表的列使用的条件类型
+type partitionPredicate struct {
+	Query string
+	Eq    map[string]bool // 等值条件，=, <=>, IN
+	Range map[string]bool // 范围条件，<, >, <=, >=, BETWEEN
+}
+
+// partitionScheme 分区方案
+type partitionScheme struct {
+	Type   string // RANGE 或 HASH
+	Column string
+	Clause string // PARTITION BY 子句
+}
+
+// PartitionAdvise 根据建表语句及业务 SQL 给出分区建议，输入中可以同时包含 CREATE TABLE 语句和 SELECT, UPDATE, DELETE 请求
+// 对每张表给出分区键、分区方式，哪些请求可以进行分区裁剪，以及改写后的建表语句
+func PartitionAdvise(buf string) string {
+	return partitionAdvise(buf, time.Now())
+}
+
+func partitionAdvise(buf string, start time.Time) string {
+	tables, queries, samples := parseWorkload(buf)
+	if len(tables) == 0 {
+		return "未找到 CREATE TABLE 语句，分区建议需要同时输入建表语句和业务 SQL"
+	}
+
+	buffer := []string{"# 分区建议\n"}
+	for _, ct := range tables {
+		tb := ct.Table.Name.String()
+		columns := make(map[string]bool)
+		for _, col := range ct.Cols {
+			columns[col.Name.Name.L] = true
+		}
+		var preds []partitionPredicate
+		for i, stmt := range queries {
+			if p, ok := partitionPredicates(stmt, ct.Table.Name.L, columns); ok {
+				p.Query = samples[i]
+				preds = append(preds, p)
+			}
+		}
+
+		buffer = append(buffer, fmt.Sprintf("## %s\n", common.MarkdownEscape(tb)))
+		scheme := choosePartitionScheme(ct, preds, start)
+		if scheme == nil {
+			buffer = append(buffer, fmt.Sprintf("* 输入的 %d 条 SQL 中没有适合作为分区键的条件，不建议对该表分区\n", len(preds)))
+			continue
+		}
+
+		buffer = append(buffer, fmt.Sprintf("* **分区方式:** %s(%s)", scheme.Type, scheme.Column))
+		pruned := 0
+		var pruneList []string
+		for _, p := range preds {
+			prune := p.Eq[scheme.Column] || (scheme.Type == "RANGE" && p.Range[scheme.Column])
+			if prune {
+				pruned++
+				pruneList = append(pruneList, fmt.Sprintf("* 可以裁剪: `%s`", p.Query))
+			} else {
+				pruneList = append(pruneList, fmt.Sprintf("* 无法裁剪，需要扫描所有分区: `%s`", p.Query))
+			}
+		}
+		buffer = append(buffer, fmt.Sprintf("* **依据:** 输入的 %d 条 SQL 中有 %d 条可以通过 %s 列进行分区裁剪", len(preds), pruned, scheme.Column))
+		for _, note := range partitionTableNotes(ct, scheme.Column) {
+			buffer = append(buffer, "* **注意:** "+note)
+		}
+		buffer = append(buffer, "\n### 分区裁剪\n")
+		buffer = append(buffer, pruneList...)
+
+		ddl, err := partitionDDL(ct, scheme)
+		if err != nil {
+			common.Log.Error("PartitionAdvise partitionDDL Error: %v", err)
+			continue
+		}
+		buffer = append(buffer, fmt.Sprintf("\n### 分区表建表语句\n\n```sql\n%s\n```\n", ddl))
+	}
+	return strings.Join(buffer, "\n")
+}
+
+// parseWorkload 切分输入的 SQL，建表语句使用 TiDB 解析，方便改写后还原；SELECT, INSERT, UPDATE, DELETE 请求使用 vitess 解析
+func parseWorkload(buf string) ([]*tidb.CreateTableStmt, []sqlparser.Statement, []string) {
+	var tables []*tidb.CreateTableStmt
+	var queries []sqlparser.Statement
+	var samples []string
+	delimiter := common.Config.Delimiter
+	for {
+		if strings.TrimSpace(buf) == "" {
+			break
+		}
+		_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(delimiter))
+		buf = string(bufBytes)
+		if d, ok := ast.ParseDelimiter(sql); ok {
+			delimiter = d
+			continue
+		}
+		sql = database.RemoveSQLComments(sql)
+		if sql == "" {
+			continue
+		}
+
+		if tiStmts, err := ast.TiParse(sql, "", ""); err == nil {
+			isDDL := false
+			for _, stmt := range tiStmts {
+				if ct, ok := stmt.(*tidb.CreateTableStmt); ok {
+					tables = append(tables, ct)
+					isDDL = true
+				}
+			}
+			if isDDL {
+				continue
+			}
+		}
+
+		stmt, err := sqlparser.Parse(sql)
+		if err != nil {
+			common.Log.Warning("parseWorkload sqlparser.Parse Error: %v, SQL: %s", err, sql)
+			continue
+		}
+		switch stmt.(type) {
+		case *sqlparser.Select, *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+			queries = append(queries, stmt)
+			samples = append(samples, sql)
+		}
+	}
+	return tables, queries, samples
+}
+
+// partitionPredicates 提取 SQL 中作用于指定表的 WHERE 条件，第二个返回值为 false 表示该 SQL 未使用这张表
+// tb 为小写的表名，columns 为表中所有小写的列名
+func partitionPredicates(stmt sqlparser.Statement, tb string, columns map[string]bool) (partitionPredicate, bool) {
+	p := partitionPredicate{
+		Eq:    make(map[string]bool),
+		Range: make(map[string]bool),
+	}
+
+	var from sqlparser.TableExprs
+	var where *sqlparser.Where
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		from, where = s.From, s.Where
+	case *sqlparser.Update:
+		from, where = s.TableExprs, s.Where
+	case *sqlparser.Delete:
+		from, where = s.TableExprs, s.Where
+	}
+
+	// 表名及别名
+	names := make(map[string]bool)
+	tableCount := 0
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if n, ok := node.(*sqlparser.AliasedTableExpr); ok {
+			tableCount++
+			if t, ok := n.Expr.(sqlparser.TableName); ok && strings.ToLower(t.Name.String()) == tb {
+				names[tb] = true
+				if !n.As.IsEmpty() {
+					names[strings.ToLower(n.As.String())] = true
+				}
+			}
+		}
+		return true, nil
+	}, from)
+	common.LogIfError(err, "")
+	if len(names) == 0 {
+		return p, false
+	}
+	if where == nil {
+		return p, true
+	}
+
+	// 判断列是否属于这张表，未指定表名时单表查询或列名存在于表中即认为属于这张表
+	column := func(expr sqlparser.Expr) string {
+		col, ok := expr.(*sqlparser.ColName)
+		if !ok {
+			return ""
+		}
+		name := col.Name.Lowered()
+		if !col.Qualifier.IsEmpty() {
+			if names[strings.ToLower(col.Qualifier.Name.String())] {
+				return name
+			}
+			return ""
+		}
+		if tableCount == 1 || columns[name] {
+			return name
+		}
+		return ""
+	}
+
+	// 只有最外层 AND 连接的条件能够用于分区裁剪
+	var conjuncts func(expr sqlparser.Expr)
+	conjuncts = func(expr sqlparser.Expr) {
+		switch e := expr.(type) {
+		case *sqlparser.AndExpr:
+			conjuncts(e.Left)
+			conjuncts(e.Right)
+		case *sqlparser.ParenExpr:
+			conjuncts(e.Expr)
+		case *sqlparser.ComparisonExpr:
+			name := column(e.Left)
+			other := e.Right
+			if name == "" {
+				name, other = column(e.Right), e.Left
+			}
+			// 两边都是列的条件为关联条件
+			if _, ok := other.(*sqlparser.ColName); name == "" || ok {
+				return
+			}
+			switch e.Operator {
+			case sqlparser.EqualStr, sqlparser.NullSafeEqualStr, sqlparser.InStr:
+				p.Eq[name] = true
+			case sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr, sqlparser.GreaterEqualStr:
+				p.Range[name] = true
+			}
+		case *sqlparser.RangeCond:
+			if name := column(e.Left); name != "" && e.Operator == sqlparser.BetweenStr {
+				p.Range[name] = true
+			}
+		}
+	}
+	conjuncts(where.Expr)
+	return p, true
+}
+
+// choosePartitionScheme 优先选择范围条件使用最多的时间类型列进行 RANGE 分区，其次选择等值条件使用最多的列进行 HASH 分区
+func choosePartitionScheme(ct *tidb.CreateTableStmt, preds []partitionPredicate, start time.Time) *partitionScheme {
+	type candidate struct {
+		col   *tidb.ColumnDef
+		count int
+	}
+	var dateCol, hashCol candidate
+	for _, col := range ct.Cols {
+		name := col.Name.Name.L
+		eq, rng := 0, 0
+		for _, p := range preds {
+			if p.Eq[name] {
+				eq++
+			}
+			if p.Range[name] {
+				rng++
+			}
+		}
+		switch col.Tp.Tp {
+		case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
+			if eq+rng > dateCol.count {
+				dateCol = candidate{col: col, count: eq + rng}
+			}
+		case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong,
+			mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeString:
+			if eq > hashCol.count {
+				hashCol = candidate{col: col, count: eq}
+			}
+		}
+	}
+
+	switch {
+	case dateCol.col != nil && dateCol.count >= hashCol.count:
+		name := dateCol.col.Name.Name.O
+		var parts []string
+		month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < partitionMonths; i++ {
+			next := month.AddDate(0, 1, 0)
+			bound := fmt.Sprintf("'%s'", next.Format("2006-01-02"))
+			if dateCol.col.Tp.Tp == mysql.TypeTimestamp {
+				// TIMESTAMP 列不支持 RANGE COLUMNS，只能使用 UNIX_TIMESTAMP
+				bound = fmt.Sprintf("UNIX_TIMESTAMP('%s')", next.Format("2006-01-02 15:04:05"))
+			}
+			parts = append(parts, fmt.Sprintf("PARTITION p%s VALUES LESS THAN (%s)", month.Format("200601"), bound))
+			month = next
+		}
+		parts = append(parts, "PARTITION pmax VALUES LESS THAN (MAXVALUE)")
+		by := fmt.Sprintf("RANGE COLUMNS(`%s`)", name)
+		if dateCol.col.Tp.Tp == mysql.TypeTimestamp {
+			by = fmt.Sprintf("RANGE (UNIX_TIMESTAMP(`%s`))", name)
+		}
+		return &partitionScheme{
+			Type:   "RANGE",
+			Column: dateCol.col.Name.Name.L,
+			Clause: fmt.Sprintf("PARTITION BY %s (\n  %s\n)", by, strings.Join(parts, ",\n  ")),
+		}
+	case hashCol.col != nil:
+		name := hashCol.col.Name.Name.O
+		// 非整型列只能使用 KEY 分区
+		by := fmt.Sprintf("HASH(`%s`)", name)
+		switch hashCol.col.Tp.Tp {
+		case mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeString:
+			by = fmt.Sprintf("KEY(`%s`)", name)
+		}
+		return &partitionScheme{
+			Type:   "HASH",
+			Column: hashCol.col.Name.Name.L,
+			Clause: fmt.Sprintf("PARTITION BY %s PARTITIONS %d", by, partitionHashes),
+		}
+	}
+	return nil
+}
+
+// partitionTableNotes 分区表的限制：主键及唯一索引必须包含分区键，不支持外键
+func partitionTableNotes(ct *tidb.CreateTableStmt, column string) []string {
+	var notes []string
+	for _, c := range ct.Constraints {
+		switch c.Tp {
+		case tidb.ConstraintPrimaryKey, tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+			if !indexHasColumn(c.Keys, column) {
+				name := c.Name
+				if c.Tp == tidb.ConstraintPrimaryKey {
+					name = "PRIMARY"
+				}
+				notes = append(notes, fmt.Sprintf("%s 未包含分区键 %s，建表语句中已将其追加到索引末尾，唯一性约束的语义会发生变化", name, column))
+			}
+		case tidb.ConstraintForeignKey:
+			notes = append(notes, fmt.Sprintf("分区表不支持外键，需要删除外键 %s", c.Name))
+		}
+	}
+	for _, col := range ct.Cols {
+		for _, opt := range col.Options {
+			switch opt.Tp {
+			case tidb.ColumnOptionPrimaryKey, tidb.ColumnOptionUniqKey:
+				if col.Name.Name.L != column {
+					notes = append(notes, fmt.Sprintf("列 %s 上的主键或唯一约束未包含分区键 %s，建表语句中已将其改写为包含分区键的联合索引", col.Name.Name.O, column))
+				}
+			}
+		}
+	}
+	return notes
+}
+
+// partitionDDL 生成分区表的建表语句，将分区键追加到主键及唯一索引中，去掉外键
+func partitionDDL(ct *tidb.CreateTableStmt, scheme *partitionScheme) (string, error) {
+	partCol := &tidb.IndexColName{Column: &tidb.ColumnName{Name: model.NewCIStr(scheme.Column)}}
+	for _, c := range ct.Cols {
+		if c.Name.Name.L == scheme.Column {
+			partCol.Column.Name = c.Name.Name
+		}
+	}
+
+	// 复制一份，避免修改原有的语法树
+	stmt := *ct
+	stmt.Cols = nil
+	stmt.Constraints = nil
+	stmt.Partition = nil
+	for _, col := range ct.Cols {
+		c := *col
+		c.Options = nil
+		for _, opt := range col.Options {
+			switch {
+			case opt.Tp == tidb.ColumnOptionPrimaryKey && c.Name.Name.L != scheme.Column:
+				stmt.Constraints = append(stmt.Constraints, &tidb.Constraint{
+					Tp:   tidb.ConstraintPrimaryKey,
+					Keys: []*tidb.IndexColName{{Column: c.Name}, partCol},
+				})
+			case opt.Tp == tidb.ColumnOptionUniqKey && c.Name.Name.L != scheme.Column:
+				stmt.Constraints = append(stmt.Constraints, &tidb.Constraint{
+					Tp:   tidb.ConstraintUniqKey,
+					Name: c.Name.Name.O,
+					Keys: []*tidb.IndexColName{{Column: c.Name}, partCol},
+				})
+			default:
+				c.Options = append(c.Options, opt)
+			}
+		}
+		stmt.Cols = append(stmt.Cols, &c)
+	}
+	for _, constraint := range ct.Constraints {
+		c := *constraint
+		switch c.Tp {
+		case tidb.ConstraintForeignKey:
+			continue
+		case tidb.ConstraintPrimaryKey, tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+			if !indexHasColumn(c.Keys, scheme.Column) {
+				c.Keys = append(append([]*tidb.IndexColName{}, c.Keys...), partCol)
+			}
+		}
+		stmt.Constraints = append(stmt.Constraints, &c)
+	}
+	// 主键放在最前面，与 SHOW CREATE TABLE 的顺序一致
+	sort.SliceStable(stmt.Constraints, func(i, j int) bool {
+		return stmt.Constraints[i].Tp == tidb.ConstraintPrimaryKey && stmt.Constraints[j].Tp != tidb.ConstraintPrimaryKey
+	})
+
+	var sb strings.Builder
+	ctx := format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)
+	if err := stmt.Restore(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n%s;", sb.String(), scheme.Clause), nil
+}
+
+// indexHasColumn 判断索引中是否包含指定列
+func indexHasColumn(keys []*tidb.IndexColName, column string) bool {
+	for _, key := range keys {
+		if key.Column != nil && key.Column.Name.L == column {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestPartitionAdvise(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	start := time.Date(2019, 11, 15, 0, 0, 0, 0, time.UTC)
+
+	// RANGE 分区
+	buf := `CREATE TABLE orders (id bigint NOT NULL AUTO_INCREMENT, uid int, created_at datetime, PRIMARY KEY (id));
+SELECT * FROM orders WHERE created_at >= '2019-01-01' AND created_at < '2019-02-01';
+SELECT o.* FROM orders o JOIN users u ON o.uid = u.id WHERE o.created_at BETWEEN '2019-01-01' AND '2019-01-02';
+SELECT * FROM orders WHERE id = 1 OR created_at > '2019-01-01';`
+	out := partitionAdvise(buf, start)
+	for _, want := range []string{
+		"RANGE(created_at)",
+		"输入的 3 条 SQL 中有 2 条可以通过 created_at 列进行分区裁剪",
+		"无法裁剪，需要扫描所有分区: `SELECT * FROM orders WHERE id = 1 OR created_at > '2019-01-01'`",
+		"PRIMARY KEY(`id`, `created_at`)",
+		"PARTITION p201911 VALUES LESS THAN ('2019-12-01')",
+		"PARTITION pmax VALUES LESS THAN (MAXVALUE)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("want: %s, got:\n%s", want, out)
+		}
+	}
+
+	// HASH 分区
+	buf = `CREATE TABLE msg (id bigint NOT NULL, uid int NOT NULL, body text, UNIQUE KEY uk_id (id));
+SELECT * FROM msg WHERE uid = 1;
+DELETE FROM msg WHERE uid IN (1, 2);`
+	out = partitionAdvise(buf, start)
+	for _, want := range []string{
+		"HASH(uid)",
+		"UNIQUE `uk_id`(`id`, `uid`)",
+		"PARTITION BY HASH(`uid`) PARTITIONS 16;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("want: %s, got:\n%s", want, out)
+		}
+	}
+
+	// 没有合适的分区键
+	buf = `CREATE TABLE t1 (id int, c1 varchar(10));
+SELECT * FROM t1 WHERE c1 LIKE 'a%';`
+	if out = partitionAdvise(buf, start); !strings.Contains(out, "不建议对该表分区") {
+		t.Errorf("got:\n%s", out)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
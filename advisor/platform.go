@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// SQL 审核平台适配，输出与 Archery, Yearning 审核结果及 Bytebase SQL Review 相同结构的 JSON，审核平台调用 soar 后可直接使用
+
+// platformLevel 审核平台通用的错误级别，0 为通过，1 为警告，2 为错误
+func platformLevel(severity string) int {
+	switch severityClass(severity) {
+	case "info":
+		return 0
+	case "warning":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// groupLevel 一条 SQL 所有建议中最高的错误级别及以换行分隔的建议内容
+func groupLevel(group []Finding) (int, string) {
+	level := 0
+	var msgs []string
+	for _, f := range group {
+		if f.ok() {
+			continue
+		}
+		if l := platformLevel(f.Rule.Severity); l > level {
+			level = l
+		}
+		msgs = append(msgs, fmt.Sprintf("[%s][%s] %s", f.Rule.Item, f.Rule.Severity, f.Rule.Summary))
+	}
+	return level, strings.Join(msgs, "\n")
+}
+
+// archeryResult Archery ReviewResult 的 JSON 结构，每条 SQL 一行
+type archeryResult struct {
+	ID                 int    `json:"id"`
+	Stage              string `json:"stage"`
+	ErrLevel           int    `json:"errlevel"`
+	StageStatus        string `json:"stagestatus"`
+	ErrorMessage       string `json:"errormessage"`
+	SQL                string `json:"sql"`
+	AffectedRows       int    `json:"affected_rows"`
+	Sequence           string `json:"sequence"`
+	BackupDBName       string `json:"backup_dbname"`
+	ExecuteTime        string `json:"execute_time"`
+	SQLSha1            string `json:"sqlsha1"`
+	ActualAffectedRows string `json:"actual_affected_rows"`
+}
+
+// FormatArchery 输出 Archery 工单审核结果，errlevel 为该 SQL 所有建议中的最高级别
+func FormatArchery(findings []Finding) string {
+	results := make([]archeryResult, 0)
+	for i, group := range groupFindings(findings) {
+		level, msg := groupLevel(group)
+		status := "Audit completed"
+		if level == 2 {
+			status = "Audit failed"
+		}
+		results = append(results, archeryResult{
+			ID:           i + 1,
+			Stage:        "CHECKED",
+			ErrLevel:     level,
+			StageStatus:  status,
+			ErrorMessage: msg,
+			SQL:          group[0].SQL,
+			Sequence:     fmt.Sprintf("'0_0_%08d'", i),
+			ExecuteTime:  "0",
+		})
+	}
+	js, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatArchery json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
+
+// yearningRecord Yearning 审核结果的 JSON 结构，每条 SQL 一行
+type yearningRecord struct {
+	SQL        string `json:"sql"`
+	AffectRows int    `json:"affect_rows"`
+	Level      int    `json:"level"`
+	Error      string `json:"error"`
+}
+
+// FormatYearning 输出 Yearning 工单审核结果，level 为该 SQL 所有建议中的最高级别
+func FormatYearning(findings []Finding) string {
+	records := make([]yearningRecord, 0)
+	for _, group := range groupFindings(findings) {
+		level, msg := groupLevel(group)
+		records = append(records, yearningRecord{
+			SQL:   group[0].SQL,
+			Level: level,
+			Error: msg,
+		})
+	}
+	js, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatYearning json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
+
+// bytebaseResult Bytebase SQL Review 的检查结果
+type bytebaseResult struct {
+	Advices []bytebaseAdvice `json:"advices"`
+}
+
+type bytebaseAdvice struct {
+	Status  string `json:"status"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Line    int    `json:"line"`
+	Detail  string `json:"detail"`
+}
+
+// FormatBytebase 输出 Bytebase SQL Review 的检查结果，没有任何建议时输出一条 SUCCESS
+func FormatBytebase(findings []Finding) string {
+	res := bytebaseResult{Advices: make([]bytebaseAdvice, 0)}
+	for _, f := range findings {
+		if f.ok() {
+			continue
+		}
+		res.Advices = append(res.Advices, bytebaseAdvice{
+			Status:  map[int]string{0: "SUCCESS", 1: "WARNING", 2: "ERROR"}[platformLevel(f.Rule.Severity)],
+			Title:   fmt.Sprintf("soar.%s", f.Rule.Item),
+			Content: strings.TrimSpace(f.Rule.Summary + "\n" + f.Rule.Content),
+			Line:    f.Line,
+			Detail:  f.SQL,
+		})
+	}
+	if len(res.Advices) == 0 {
+		res.Advices = append(res.Advices, bytebaseAdvice{Status: "SUCCESS", Title: "OK"})
+	}
+	js, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		common.Log.Error("FormatBytebase json.Marshal Error: %v", err)
+	}
+	return string(js)
+}
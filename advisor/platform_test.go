@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// platformFindings 一条通过的 SQL 及一条有 L4, L1 两条建议的 SQL
+func platformFindings() []Finding {
+	return append(NewFindings(map[string]Rule{"OK": HeuristicRules["OK"]}, "select 1", nil, "a.sql", 1),
+		NewFindings(map[string]Rule{
+			"CLA.001": HeuristicRules["CLA.001"],
+			"COL.001": HeuristicRules["COL.001"],
+		}, "select * from film", nil, "a.sql", 3)...)
+}
+
+func TestFormatArchery(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var results []archeryResult
+	if err := json.Unmarshal([]byte(FormatArchery(platformFindings())), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ErrLevel != 0 || results[0].ErrorMessage != "" ||
+		results[1].ID != 2 || results[1].ErrLevel != 1 || results[1].SQL != "select * from film" ||
+		results[1].ErrorMessage != "[CLA.001][L4] "+HeuristicRules["CLA.001"].Summary+"\n[COL.001][L1] "+HeuristicRules["COL.001"].Summary {
+		t.Errorf("archery got: %v", results)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatYearning(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var records []yearningRecord
+	if err := json.Unmarshal([]byte(FormatYearning(platformFindings())), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Level != 0 || records[1].Level != 1 || records[1].SQL != "select * from film" {
+		t.Errorf("yearning got: %v", records)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestFormatBytebase(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var res bytebaseResult
+	if err := json.Unmarshal([]byte(FormatBytebase(platformFindings())), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Advices) != 2 || res.Advices[0].Status != "WARNING" || res.Advices[0].Title != "soar.CLA.001" ||
+		res.Advices[0].Line != 3 {
+		t.Errorf("bytebase got: %v", res)
+	}
+
+	// 没有建议时输出一条 SUCCESS
+	res = bytebaseResult{}
+	if err := json.Unmarshal([]byte(FormatBytebase(platformFindings()[:1])), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Advices) != 1 || res.Advices[0].Status != "SUCCESS" {
+		t.Errorf("bytebase got: %v", res)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
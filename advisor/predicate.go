@@ -0,0 +1,532 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// predicateFindings 对 WHERE, HAVING, ON 条件的分析结果，每一项为有问题的子表达式
+type predicateFindings struct {
+	contradictions []string // 永远不为真的条件，如 a > 10 AND a < 5
+	tautologies    []string // 除 NULL 外永远为真的条件，如 a > 5 OR a <= 5
+	duplicates     []string // 同一个 AND 或 OR 中重复出现的条件
+}
+
+// predicateValue 条件中的常量，数值与字符串不做比较
+type predicateValue struct {
+	num   float64
+	str   string
+	isNum bool
+}
+
+func (v predicateValue) equal(o predicateValue) bool {
+	if v.isNum != o.isNum {
+		return false
+	}
+	if v.isNum {
+		return v.num == o.num
+	}
+	// 字符串比较与排序规则有关，按大小写不敏感且忽略尾部空格处理，避免误报
+	return strings.EqualFold(strings.TrimRight(v.str, " "), strings.TrimRight(o.str, " "))
+}
+
+// interval 数值区间，无边界时使用正负无穷
+type interval struct {
+	lo, hi       float64
+	loInc, hiInc bool
+}
+
+func (i interval) contains(v float64) bool {
+	return (v > i.lo || v == i.lo && i.loInc) && (v < i.hi || v == i.hi && i.hiInc)
+}
+
+func (i interval) empty() bool {
+	return i.lo > i.hi || i.lo == i.hi && !(i.loInc && i.hiInc)
+}
+
+// intersect 两个区间的交集
+func (i interval) intersect(o interval) interval {
+	if o.lo > i.lo || o.lo == i.lo && !o.loInc {
+		i.lo, i.loInc = o.lo, o.loInc
+	}
+	if o.hi < i.hi || o.hi == i.hi && !o.hiInc {
+		i.hi, i.hiInc = o.hi, o.hiInc
+	}
+	return i
+}
+
+// fullCover 判断区间的并集是否覆盖整个数轴
+func fullCover(ivs []interval) bool {
+	sort.Slice(ivs, func(a, b int) bool {
+		if ivs[a].lo != ivs[b].lo {
+			return ivs[a].lo < ivs[b].lo
+		}
+		return ivs[a].loInc && !ivs[b].loInc
+	})
+	reach, reachInc := math.Inf(-1), false
+	for _, iv := range ivs {
+		if iv.empty() {
+			continue
+		}
+		if iv.lo > reach || iv.lo == reach && !reachInc && !iv.loInc && !math.IsInf(reach, -1) {
+			return false
+		}
+		if iv.hi > reach || iv.hi == reach && iv.hiInc {
+			reach, reachInc = iv.hi, iv.hiInc
+		}
+	}
+	return math.IsInf(reach, 1)
+}
+
+// atom 单列与常量比较的简单条件
+type atom struct {
+	col  string
+	op   string // =, !=, <, <=, >, >=, in, between, is null, is not null
+	vals []predicateValue
+}
+
+// constValue 获取常量的值
+func constValue(expr sqlparser.Expr) (predicateValue, bool) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok {
+		return predicateValue{}, false
+	}
+	switch val.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal:
+		f, err := strconv.ParseFloat(string(val.Val), 64)
+		return predicateValue{num: f, isNum: true}, err == nil
+	case sqlparser.StrVal:
+		return predicateValue{str: string(val.Val)}, true
+	}
+	return predicateValue{}, false
+}
+
+// flipOperator 常量在左侧时交换比较运算符的方向
+var flipOperator = map[string]string{
+	sqlparser.EqualStr:        sqlparser.EqualStr,
+	sqlparser.NotEqualStr:     sqlparser.NotEqualStr,
+	sqlparser.LessThanStr:     sqlparser.GreaterThanStr,
+	sqlparser.LessEqualStr:    sqlparser.GreaterEqualStr,
+	sqlparser.GreaterThanStr:  sqlparser.LessThanStr,
+	sqlparser.GreaterEqualStr: sqlparser.LessEqualStr,
+}
+
+// parseAtom 将条件解析为 atom，无法解析时返回 false
+func parseAtom(expr sqlparser.Expr) (atom, bool) {
+	switch e := expr.(type) {
+	case *sqlparser.ParenExpr:
+		return parseAtom(e.Expr)
+	case *sqlparser.ComparisonExpr:
+		if e.Operator == sqlparser.InStr {
+			col, ok := e.Left.(*sqlparser.ColName)
+			tuple, isTuple := e.Right.(sqlparser.ValTuple)
+			if !ok || !isTuple {
+				return atom{}, false
+			}
+			a := atom{col: strings.ToLower(sqlparser.String(col)), op: "in"}
+			for _, v := range tuple {
+				val, ok := constValue(v)
+				if !ok {
+					return atom{}, false
+				}
+				if len(a.vals) > 0 && a.vals[0].isNum != val.isNum {
+					return atom{}, false
+				}
+				a.vals = append(a.vals, val)
+			}
+			return a, true
+		}
+		op, ok := flipOperator[e.Operator]
+		if !ok {
+			return atom{}, false
+		}
+		if col, ok := e.Left.(*sqlparser.ColName); ok {
+			if val, ok := constValue(e.Right); ok {
+				return atom{col: strings.ToLower(sqlparser.String(col)), op: e.Operator, vals: []predicateValue{val}}, true
+			}
+		}
+		if col, ok := e.Right.(*sqlparser.ColName); ok {
+			if val, ok := constValue(e.Left); ok {
+				return atom{col: strings.ToLower(sqlparser.String(col)), op: op, vals: []predicateValue{val}}, true
+			}
+		}
+	case *sqlparser.RangeCond:
+		col, ok := e.Left.(*sqlparser.ColName)
+		from, fromOK := constValue(e.From)
+		to, toOK := constValue(e.To)
+		if ok && fromOK && toOK && from.isNum && to.isNum && e.Operator == sqlparser.BetweenStr {
+			return atom{col: strings.ToLower(sqlparser.String(col)), op: "between", vals: []predicateValue{from, to}}, true
+		}
+	case *sqlparser.IsExpr:
+		if col, ok := e.Expr.(*sqlparser.ColName); ok && (e.Operator == sqlparser.IsNullStr || e.Operator == sqlparser.IsNotNullStr) {
+			return atom{col: strings.ToLower(sqlparser.String(col)), op: e.Operator}, true
+		}
+	}
+	return atom{}, false
+}
+
+// interval 条件对应的数值区间，!= 对应两个区间
+func (a atom) intervals() []interval {
+	inf := math.Inf(1)
+	switch a.op {
+	case sqlparser.EqualStr:
+		return []interval{{a.vals[0].num, a.vals[0].num, true, true}}
+	case sqlparser.NotEqualStr:
+		return []interval{{-inf, a.vals[0].num, true, false}, {a.vals[0].num, inf, false, true}}
+	case sqlparser.LessThanStr:
+		return []interval{{-inf, a.vals[0].num, true, false}}
+	case sqlparser.LessEqualStr:
+		return []interval{{-inf, a.vals[0].num, true, true}}
+	case sqlparser.GreaterThanStr:
+		return []interval{{a.vals[0].num, inf, false, true}}
+	case sqlparser.GreaterEqualStr:
+		return []interval{{a.vals[0].num, inf, true, true}}
+	case "between":
+		return []interval{{a.vals[0].num, a.vals[1].num, true, true}}
+	case "in":
+		var ivs []interval
+		for _, v := range a.vals {
+			ivs = append(ivs, interval{v.num, v.num, true, true})
+		}
+		return ivs
+	}
+	return nil
+}
+
+// numeric 条件中的常量是否都是数值，不含常量时返回 false
+func (a atom) numeric() bool {
+	for _, v := range a.vals {
+		if !v.isNum {
+			return false
+		}
+	}
+	return len(a.vals) > 0
+}
+
+// analyzePredicates 分析 SQL 中所有 WHERE, HAVING, ON 条件
+func analyzePredicates(stmt sqlparser.Statement) predicateFindings {
+	var f predicateFindings
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Where:
+			if n != nil {
+				f.analyze(n.Expr)
+			}
+		case *sqlparser.JoinTableExpr:
+			f.analyze(n.Condition.On)
+		}
+		return true, nil
+	}, stmt)
+	common.LogIfError(err, "")
+	f.contradictions = common.RemoveDuplicatesItem(f.contradictions)
+	f.tautologies = common.RemoveDuplicatesItem(f.tautologies)
+	f.duplicates = common.RemoveDuplicatesItem(f.duplicates)
+	return f
+}
+
+// flatten 展开连续的 AND 或 OR
+func flatten(expr sqlparser.Expr, and bool) []sqlparser.Expr {
+	switch e := expr.(type) {
+	case *sqlparser.ParenExpr:
+		if _, ok := e.Expr.(*sqlparser.AndExpr); ok && and {
+			return flatten(e.Expr, and)
+		}
+		if _, ok := e.Expr.(*sqlparser.OrExpr); ok && !and {
+			return flatten(e.Expr, and)
+		}
+	case *sqlparser.AndExpr:
+		if and {
+			return append(flatten(e.Left, and), flatten(e.Right, and)...)
+		}
+	case *sqlparser.OrExpr:
+		if !and {
+			return append(flatten(e.Left, and), flatten(e.Right, and)...)
+		}
+	}
+	return []sqlparser.Expr{expr}
+}
+
+func (f *predicateFindings) analyze(expr sqlparser.Expr) {
+	if expr == nil {
+		return
+	}
+	switch e := expr.(type) {
+	case *sqlparser.ParenExpr:
+		f.analyze(e.Expr)
+	case *sqlparser.NotExpr:
+		f.analyze(e.Expr)
+	case *sqlparser.OrExpr:
+		conds := flatten(e, false)
+		f.checkDuplicate(conds)
+		f.checkTautology(conds)
+		for _, c := range conds {
+			f.analyze(c)
+		}
+	default:
+		// 单个条件当作只有一项的 AND 处理，如 a BETWEEN 10 AND 5
+		conds := flatten(e, true)
+		f.checkDuplicate(conds)
+		f.checkContradiction(conds)
+		if len(conds) > 1 {
+			for _, c := range conds {
+				f.analyze(c)
+			}
+		}
+	}
+}
+
+// normalizePredicate 用于判断重复条件，常量在左侧的比较会交换至右侧
+func normalizePredicate(expr sqlparser.Expr) string {
+	if a, ok := parseAtom(expr); ok && len(a.vals) == 1 {
+		return a.col + " " + a.op + " " + strings.ToLower(sqlparser.String(a.valExpr()))
+	}
+	return strings.ToLower(sqlparser.String(expr))
+}
+
+// valExpr 单值条件中的常量
+func (a atom) valExpr() sqlparser.Expr {
+	v := a.vals[0]
+	if v.isNum {
+		return sqlparser.NewFloatVal([]byte(strconv.FormatFloat(v.num, 'f', -1, 64)))
+	}
+	return sqlparser.NewStrVal([]byte(v.str))
+}
+
+func (f *predicateFindings) checkDuplicate(conds []sqlparser.Expr) {
+	seen := make(map[string]bool)
+	for _, c := range conds {
+		key := normalizePredicate(c)
+		if seen[key] {
+			f.duplicates = append(f.duplicates, sqlparser.String(c))
+		}
+		seen[key] = true
+	}
+}
+
+// checkContradiction 同一列上的 AND 条件没有交集时条件永远不为真
+func (f *predicateFindings) checkContradiction(conds []sqlparser.Expr) {
+	var cols []string
+	atoms := make(map[string][]atom)
+	sources := make(map[string][]string)
+	for _, c := range conds {
+		a, ok := parseAtom(c)
+		if !ok {
+			continue
+		}
+		if _, ok := atoms[a.col]; !ok {
+			cols = append(cols, a.col)
+		}
+		atoms[a.col] = append(atoms[a.col], a)
+		sources[a.col] = append(sources[a.col], sqlparser.String(c))
+	}
+	for _, col := range cols {
+		if contradictory(atoms[col]) {
+			f.contradictions = append(f.contradictions, strings.Join(sources[col], " AND "))
+		}
+	}
+}
+
+// contradictory 判断同一列上的多个条件同时成立是否可能
+func contradictory(atoms []atom) bool {
+	isNull, notNull, numeric, str := false, false, false, false
+	for _, a := range atoms {
+		switch a.op {
+		case sqlparser.IsNullStr:
+			isNull = true
+		case sqlparser.IsNotNullStr:
+			notNull = true
+		default:
+			if a.numeric() {
+				numeric = true
+			} else {
+				str = true
+			}
+		}
+	}
+	// 与 NULL 的比较结果为 NULL
+	if isNull && (notNull || numeric || str) {
+		return true
+	}
+	// 数值与字符串比较存在隐式类型转换，不做判断
+	if numeric && str {
+		return false
+	}
+
+	if numeric {
+		whole := interval{math.Inf(-1), math.Inf(1), true, true}
+		var allowed []float64 // =, IN 限定的取值
+		var excluded []float64
+		restricted := false
+		for _, a := range atoms {
+			switch a.op {
+			case sqlparser.EqualStr, "in":
+				var vals []float64
+				for _, v := range a.vals {
+					if !restricted || containsFloat(allowed, v.num) {
+						vals = append(vals, v.num)
+					}
+				}
+				allowed, restricted = vals, true
+			case sqlparser.NotEqualStr:
+				excluded = append(excluded, a.vals[0].num)
+			case sqlparser.IsNullStr, sqlparser.IsNotNullStr:
+			default:
+				whole = whole.intersect(a.intervals()[0])
+			}
+		}
+		if whole.empty() {
+			return true
+		}
+		if restricted {
+			for _, v := range allowed {
+				if whole.contains(v) && !containsFloat(excluded, v) {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+
+	if str {
+		var allowed []predicateValue
+		var excluded []predicateValue
+		restricted := false
+		for _, a := range atoms {
+			switch a.op {
+			case sqlparser.EqualStr, "in":
+				var vals []predicateValue
+				for _, v := range a.vals {
+					if !restricted || containsValue(allowed, v) {
+						vals = append(vals, v)
+					}
+				}
+				allowed, restricted = vals, true
+			case sqlparser.NotEqualStr:
+				excluded = append(excluded, a.vals[0])
+			}
+		}
+		if !restricted {
+			return false
+		}
+		for _, v := range allowed {
+			if !containsValue(excluded, v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func containsFloat(list []float64, v float64) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(list []predicateValue, v predicateValue) bool {
+	for _, l := range list {
+		if l.equal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTautology 同一列上的 OR 条件覆盖所有取值，或同时包含 x 与 NOT x 时条件永远为真（列为 NULL 时除外）
+func (f *predicateFindings) checkTautology(conds []sqlparser.Expr) {
+	exprs := make(map[string]bool)
+	for _, c := range conds {
+		exprs[strings.ToLower(sqlparser.String(c))] = true
+	}
+	for _, c := range conds {
+		if not, ok := c.(*sqlparser.NotExpr); ok && exprs[strings.ToLower(sqlparser.String(not.Expr))] {
+			f.tautologies = append(f.tautologies, sqlparser.String(not.Expr)+" OR "+sqlparser.String(c))
+		}
+	}
+
+	var cols []string
+	atoms := make(map[string][]atom)
+	sources := make(map[string][]string)
+	for _, c := range conds {
+		a, ok := parseAtom(c)
+		if !ok || a.op == sqlparser.IsNullStr || a.op == sqlparser.IsNotNullStr {
+			continue
+		}
+		if _, ok := atoms[a.col]; !ok {
+			cols = append(cols, a.col)
+		}
+		atoms[a.col] = append(atoms[a.col], a)
+		sources[a.col] = append(sources[a.col], sqlparser.String(c))
+	}
+	for _, col := range cols {
+		if len(atoms[col]) > 1 && alwaysTrue(atoms[col]) {
+			f.tautologies = append(f.tautologies, strings.Join(sources[col], " OR "))
+		}
+	}
+}
+
+// alwaysTrue 判断同一列上的多个条件是否至少有一个成立
+func alwaysTrue(atoms []atom) bool {
+	numeric := true
+	for _, a := range atoms {
+		if !a.numeric() {
+			numeric = false
+		}
+	}
+	if numeric {
+		var ivs []interval
+		for _, a := range atoms {
+			ivs = append(ivs, a.intervals()...)
+		}
+		return fullCover(ivs)
+	}
+
+	// 字符串只判断 col = 'a' OR col != 'a'
+	for _, ne := range atoms {
+		if ne.op != sqlparser.NotEqualStr || ne.numeric() {
+			continue
+		}
+		for _, eq := range atoms {
+			if (eq.op == sqlparser.EqualStr || eq.op == "in") && !eq.numeric() && containsValue(eq.vals, ne.vals[0]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// quotePredicates 将子表达式格式化为建议中的内容
+func quotePredicates(exprs []string) []string {
+	var quoted []string
+	for _, e := range exprs {
+		quoted = append(quoted, "`"+e+"`")
+	}
+	return quoted
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestAnalyzePredicates(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		sql            string
+		contradictions []string
+		tautologies    []string
+		duplicates     []string
+	}{
+		{"select * from t where a > 10 and b = 1 and a < 5", []string{"a > 10 AND a < 5"}, nil, nil},
+		{"select * from t where a >= 5 and a <= 5", nil, nil, nil},
+		{"select * from t where a > 5 and a <= 5", []string{"a > 5 AND a <= 5"}, nil, nil},
+		{"select * from t where a between 1 and 10 and a != 5", nil, nil, nil},
+		{"select * from t where a in (1, 2) and a != 1 and a != 2", []string{"a in (1, 2) AND a != 1 AND a != 2"}, nil, nil},
+		{"select * from t where a = 'x' and a = 'y'", []string{"a = 'x' AND a = 'y'"}, nil, nil},
+		{"select * from t where a = 'x' and a = 'X '", nil, nil, nil},
+		{"select * from t where a = 1 and a = '2'", nil, nil, nil},
+		{"select * from t where a is null and a > 1", []string{"a is null AND a > 1"}, nil, nil},
+		{"select * from t where (a > 10 and a < 5) or b = 1", []string{"a > 10 AND a < 5"}, nil, nil},
+		{"select * from t1 join t2 on t1.id = t2.id and t1.x > 3 and t1.x < 2", []string{"t1.x > 3 AND t1.x < 2"}, nil, nil},
+		{"select * from t where (a > 5 or a <= 5) and b = 1", nil, []string{"a > 5 OR a <= 5"}, nil},
+		{"select * from t where a < 5 or a between 5 and 10 or a > 10", nil, []string{"a < 5 OR a between 5 and 10 OR a > 10"}, nil},
+		{"select * from t where a < 5 or a > 5", nil, nil, nil},
+		{"select * from t where a = 'x' or a != 'x'", nil, []string{"a = 'x' OR a != 'x'"}, nil},
+		{"select * from t where b or not b", nil, []string{"b OR not b"}, nil},
+		{"select * from t where a = 1 and 1 = a", nil, nil, []string{"1 = a"}},
+		{"select * from t where a = 1 or b = 2 or b = 2", nil, nil, []string{"b = 2"}},
+		{"select * from t where a = 1 and b = 1", nil, nil, nil},
+	}
+	for _, c := range cases {
+		q, err := NewQuery4Audit(c.sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		f := analyzePredicates(q.Stmt)
+		if strings.Join(f.contradictions, ";") != strings.Join(c.contradictions, ";") ||
+			strings.Join(f.tautologies, ";") != strings.Join(c.tautologies, ";") ||
+			strings.Join(f.duplicates, ";") != strings.Join(c.duplicates, ";") {
+			t.Errorf("SQL: %s\ngot contradictions: %v, tautologies: %v, duplicates: %v",
+				c.sql, f.contradictions, f.tautologies, f.duplicates)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// RES.014
+func TestRuleDuplicatePredicate(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	q, err := NewQuery4Audit("SELECT * FROM tbl WHERE col = 1 AND 1 = col")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleDuplicatePredicate(); rule.Item != "RES.014" || !strings.Contains(rule.Content, "`1 = col`") {
+		t.Errorf("got: %s %s", rule.Item, rule.Content)
+	}
+	q, err = NewQuery4Audit("SELECT * FROM tbl WHERE col = 1 AND col = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleDuplicatePredicate(); rule.Item != "OK" {
+		t.Errorf("got: %s %s", rule.Item, rule.Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
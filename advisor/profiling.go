@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+
+	"github.com/XiaoMi/soar/database"
+)
+
+// profilingExaminedRatio 扫描行数超过返回（或影响）行数的倍数时给出 PRO.002
+const profilingExaminedRatio = 100
+
+// profilingMinExamined 扫描行数较少时不给出 PRO.002
+const profilingMinExamined = 1000
+
+// ProfilingAdvisor 根据测试环境中执行 SQL 的统计信息给出 PRO 类建议
+// PRO.001 为各阶段耗时及语句统计，其他建议依赖 performance_schema 中的语句统计，使用 SHOW PROFILE 时只给出 PRO.001
+func ProfilingAdvisor(p *database.Profile) map[string]Rule {
+	rules := make(map[string]Rule)
+	if p == nil {
+		return rules
+	}
+	rules["PRO.001"] = Rule{
+		Item:     "PRO.001",
+		Severity: "L0",
+		Summary:  fmt.Sprintf("Profiling 信息（%s）", p.Source),
+		Content:  database.FormatProfile(p),
+		Func:     (*Query4Audit).RuleOK,
+	}
+	st := p.Statement
+	if st == nil {
+		return rules
+	}
+
+	returned := st.RowsSent + st.RowsAffected
+	if returned < 1 {
+		returned = 1
+	}
+	if st.RowsExamined >= profilingMinExamined && st.RowsExamined > returned*profilingExaminedRatio {
+		rules["PRO.002"] = Rule{
+			Item:     "PRO.002",
+			Severity: "L2",
+			Summary:  "扫描行数远大于返回行数",
+			Content: fmt.Sprintf("执行时扫描了 %d 行，只返回（或影响）了 %d 行，大部分数据在 Server 层被过滤，建议检查查询条件是否能够使用索引。",
+				st.RowsExamined, st.RowsSent+st.RowsAffected),
+			Func: (*Query4Audit).RuleOK,
+		}
+	}
+	if st.CreatedTmpDiskTables > 0 || st.SortMergePasses > 0 {
+		rules["PRO.003"] = Rule{
+			Item:     "PRO.003",
+			Severity: "L3",
+			Summary:  "使用了磁盘临时表或排序需要多次归并",
+			Content: fmt.Sprintf("执行时创建了 %d 个临时表，其中 %d 个为磁盘临时表；排序 %d 行，归并 %d 次。建议添加索引避免排序及临时表，或调整 tmp_table_size, sort_buffer_size。",
+				st.CreatedTmpTables, st.CreatedTmpDiskTables, st.SortRows, st.SortMergePasses),
+			Func: (*Query4Audit).RuleOK,
+		}
+	}
+	if st.NoIndexUsed > 0 || st.SelectFullJoin > 0 {
+		rules["PRO.004"] = Rule{
+			Item:     "PRO.004",
+			Severity: "L2",
+			Summary:  "执行时存在未使用索引的扫描或关联",
+			Content: fmt.Sprintf("performance_schema 记录 NO_INDEX_USED=%d, SELECT_FULL_JOIN=%d，表示存在全表扫描或关联时被驱动表没有可用的索引。",
+				st.NoIndexUsed, st.SelectFullJoin),
+			Func: (*Query4Audit).RuleOK,
+		}
+	}
+	return rules
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestProfilingAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	if rules := ProfilingAdvisor(nil); len(rules) != 0 {
+		t.Errorf("want no rules, got: %v", rules)
+	}
+
+	// SHOW PROFILE 没有语句统计，只给出 PRO.001
+	rules := ProfilingAdvisor(&database.Profile{
+		Source: "SHOW PROFILE",
+		Stages: []database.ProfilingRow{{Status: "executing", Duration: 0.01}},
+	})
+	if len(rules) != 1 || rules["PRO.001"].Item != "PRO.001" {
+		t.Errorf("want only PRO.001, got: %v", rules)
+	}
+
+	rules = ProfilingAdvisor(&database.Profile{
+		Source: "performance_schema",
+		Statement: &database.StatementStat{
+			RowsExamined:         100000,
+			RowsSent:             10,
+			CreatedTmpTables:     1,
+			CreatedTmpDiskTables: 1,
+			NoIndexUsed:          1,
+		},
+	})
+	for _, item := range []string{"PRO.001", "PRO.002", "PRO.003", "PRO.004"} {
+		if _, ok := rules[item]; !ok {
+			t.Errorf("%s not found", item)
+		}
+	}
+	if !strings.Contains(rules["PRO.001"].Content, "| Rows_examined |") {
+		t.Errorf("PRO.001 content: %s", rules["PRO.001"].Content)
+	}
+
+	rules = ProfilingAdvisor(&database.Profile{
+		Source:    "performance_schema",
+		Statement: &database.StatementStat{RowsExamined: 100, RowsSent: 100},
+	})
+	if len(rules) != 1 {
+		t.Errorf("want only PRO.001, got: %v", rules)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// MyRocks 使用 LSM 存储数据，反向扫描、范围查询及二级索引回表的代价与 InnoDB 不同，
+// SQL 涉及的表都使用 ROCKSDB 引擎时改用以下建议，并调整以 InnoDB 为前提的索引建议
+
+// rocksdbIndexNote 追加在 ROCKSDB 表索引建议中的说明
+const rocksdbIndexNote = "MyRocks 不使用间隙锁，无需为缩小间隙锁的范围添加索引；二级索引回表需要在 LSM 中查找主键记录，代价高于 InnoDB，建议优先使用覆盖索引。"
+
+// allRocksDB SQL 涉及的表是否都使用 ROCKSDB 引擎
+func allRocksDB(engines map[string]string) bool {
+	for _, engine := range engines {
+		if engine != "ROCKSDB" {
+			return false
+		}
+	}
+	return len(engines) > 0
+}
+
+// rocksdbRules SQL 涉及的表都使用 ROCKSDB 引擎时给出的建议
+func rocksdbRules(q *Query4Audit, engines map[string]string) map[string]Rule {
+	rules := make(map[string]Rule)
+	if q == nil || !allRocksDB(engines) {
+		return rules
+	}
+	for _, f := range []func(*Query4Audit) Rule{
+		(*Query4Audit).ruleRocksDBReverseScan,
+		(*Query4Audit).ruleRocksDBBloomFilter,
+		(*Query4Audit).ruleRocksDBUniqueIndex,
+	} {
+		if rule := f(q); rule.Item != "OK" && !IsIgnoreRule(rule.Item) {
+			rules[rule.Item] = rule
+		}
+	}
+	return rules
+}
+
+// ruleRocksDBReverseScan RKS.001
+// ORDER BY ... DESC 需要反向迭代 LSM，比正向扫描慢
+func (q *Query4Audit) ruleRocksDBReverseScan() Rule {
+	rule := q.RuleOK()
+	sel, ok := q.Stmt.(*sqlparser.Select)
+	if !ok {
+		return rule
+	}
+	for _, order := range sel.OrderBy {
+		if order.Direction == sqlparser.DescScr {
+			return HeuristicRules["RKS.001"]
+		}
+	}
+	return rule
+}
+
+// ruleRocksDBBloomFilter RKS.002
+// WHERE 条件中只有范围条件时无法使用前缀 Bloom Filter 跳过不相关的 SST 文件
+func (q *Query4Audit) ruleRocksDBBloomFilter() Rule {
+	rule := q.RuleOK()
+	var where *sqlparser.Where
+	switch s := q.Stmt.(type) {
+	case *sqlparser.Select:
+		where = s.Where
+	case *sqlparser.Update:
+		where = s.Where
+	case *sqlparser.Delete:
+		where = s.Where
+	}
+	if where == nil {
+		return rule
+	}
+	equal, rangeCond := false, false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.ComparisonExpr:
+			switch n.Operator {
+			case sqlparser.EqualStr, sqlparser.NullSafeEqualStr, sqlparser.InStr:
+				equal = true
+			case sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr,
+				sqlparser.GreaterEqualStr, sqlparser.LikeStr:
+				rangeCond = true
+			}
+		case *sqlparser.RangeCond:
+			rangeCond = true
+		case *sqlparser.Subquery:
+			return false, nil
+		}
+		return true, nil
+	}, where.Expr)
+	common.LogIfError(err, "")
+	if rangeCond && !equal {
+		rule = HeuristicRules["RKS.002"]
+	}
+	return rule
+}
+
+// ruleRocksDBUniqueIndex RKS.003
+// 唯一二级索引在写入前需要读取并锁定记录检查唯一性，非唯一二级索引的写入不需要读取
+func (q *Query4Audit) ruleRocksDBUniqueIndex() Rule {
+	rule := q.RuleOK()
+	for _, stmt := range q.TiStmt {
+		switch node := stmt.(type) {
+		case *tidb.AlterTableStmt:
+			for _, spec := range node.Specs {
+				if spec.Tp != tidb.AlterTableAddConstraint || spec.Constraint == nil {
+					continue
+				}
+				switch spec.Constraint.Tp {
+				case tidb.ConstraintUniq, tidb.ConstraintUniqKey, tidb.ConstraintUniqIndex:
+					return HeuristicRules["RKS.003"]
+				}
+			}
+		case *tidb.CreateIndexStmt:
+			if node.KeyType == tidb.IndexKeyTypeUnique {
+				return HeuristicRules["RKS.003"]
+			}
+		}
+	}
+	return rule
+}
+
+// rocksdbIndexAdvice 在索引建议中追加 MyRocks 的说明
+func rocksdbIndexAdvice(suggest map[string]Rule) {
+	for item, rule := range suggest {
+		if strings.HasPrefix(item, "IDX.") && !strings.Contains(rule.Content, rocksdbIndexNote) {
+			rule.Content = strings.Join([]string{rule.Content, rocksdbIndexNote}, " ")
+			suggest[item] = rule
+		}
+	}
+}
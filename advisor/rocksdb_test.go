@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// RKS.001, RKS.002, RKS.003
+func TestRocksDBRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string][]string{
+		"SELECT * FROM orders WHERE user_id = 1 ORDER BY id DESC LIMIT 10":       {"RKS.001"},
+		"SELECT * FROM orders WHERE created > '2024-01-01'":                      {"RKS.002"},
+		"SELECT * FROM orders WHERE user_id = 1 AND created > '2024-01-01'":      nil,
+		"DELETE FROM orders WHERE created BETWEEN '2024-01-01' AND '2024-02-01'": {"RKS.002"},
+		"ALTER TABLE orders ADD UNIQUE KEY uk_no (order_no)":                     {"RKS.003"},
+		"CREATE UNIQUE INDEX uk_no ON orders (order_no)":                         {"RKS.003"},
+		"ALTER TABLE orders ADD KEY idx_no (order_no)":                           nil,
+	}
+	engines := map[string]string{"`sakila`.`orders`": "ROCKSDB"}
+	for sql, want := range cases {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var got []string
+		for item := range rocksdbRules(q, engines) {
+			got = append(got, item)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("SQL: %s, want %v, got %v", sql, want, got)
+		}
+	}
+
+	// 涉及非 ROCKSDB 的表时不使用 MyRocks 的建议
+	q, _ := NewQuery4Audit("SELECT * FROM orders o JOIN users u ON o.user_id = u.id ORDER BY o.id DESC")
+	if rules := rocksdbRules(q, map[string]string{"`sakila`.`orders`": "ROCKSDB", "`sakila`.`users`": "INNODB"}); len(rules) != 0 {
+		t.Errorf("want no MyRocks rules for mixed engines, got %v", rules)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestGateEngineRulesRocksDB(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	q, err := NewQuery4Audit("SELECT * FROM orders WHERE user_id = 1 ORDER BY id DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	heuristic := map[string]Rule{}
+	idx := map[string]Rule{"IDX.001": {Item: "IDX.001", Content: "为列user_id添加索引"}}
+	GateEngineRules(q, map[string]string{"`sakila`.`orders`": "ROCKSDB"}, heuristic, idx)
+	if _, ok := heuristic["RKS.001"]; !ok {
+		t.Errorf("want RKS.001, got %v", heuristic)
+	}
+	if !strings.Contains(idx["IDX.001"].Content, rocksdbIndexNote) {
+		t.Errorf("want MyRocks index note, got %s", idx["IDX.001"].Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
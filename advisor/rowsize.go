@@ -0,0 +1,310 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/mysql"
+)
+
+const (
+	// maxRowSize MySQL 的行长度限制，不包含 BLOB/TEXT 列的内容
+	maxRowSize = 65535
+	// maxInPageRowSize innodb_page_size 为 16K 时一行在页内最多保存的字节数
+	maxInPageRowSize = 8126
+	// rowSizeWarnRatio 行长度达到限制的该比例时给出提醒
+	rowSizeWarnRatio = 0.8
+	// offPageColumnThreshold 预计存储在溢出页的列达到该数量时建议垂直拆分
+	offPageColumnThreshold = 4
+	// offPagePointer 存储在溢出页的列在页内保留的指针长度
+	offPagePointer = 20
+	// compactPrefix COMPACT, REDUNDANT 行格式中存储在溢出页的列在页内保留的前缀长度
+	compactPrefix = 768
+)
+
+// charsetMaxLen 字符集中一个字符最多占用的字节数，未列出的字符集按 4 字节计算
+var charsetMaxLen = map[string]int{
+	"binary": 1, "ascii": 1, "latin1": 1, "latin2": 1, "latin5": 1, "latin7": 1, "cp1250": 1, "cp1251": 1,
+	"cp1256": 1, "cp1257": 1, "cp850": 1, "cp852": 1, "cp866": 1, "dec8": 1, "greek": 1, "hebrew": 1,
+	"hp8": 1, "keybcs2": 1, "koi8r": 1, "koi8u": 1, "macce": 1, "macroman": 1, "swe7": 1, "tis620": 1,
+	"armscii8": 1, "geostd8": 1,
+	"gbk": 2, "gb2312": 2, "big5": 2, "sjis": 2, "cp932": 2, "euckr": 2, "ucs2": 2,
+	"ujis": 3, "eucjpms": 3, "utf8": 3, "utf8mb3": 3,
+	"gb18030": 4, "utf8mb4": 4, "utf16": 4, "utf16le": 4, "utf32": 4,
+}
+
+// rowColumn 列在行中最多占用的字节数
+type rowColumn struct {
+	def     *tidb.ColumnDef
+	size    int  // 最多占用的字节数，BLOB/TEXT 为内容的最大长度
+	inRow   int  // 计入 65535 行长度限制的字节数
+	varLen  bool // 变长列，行长度超过页内限制时可以存储在溢出页
+	blob    bool // BLOB, TEXT, JSON 等类型
+	notNull bool
+}
+
+// decimalSize DECIMAL 类型占用的字节数，每 9 位数字占用 4 个字节
+func decimalSize(precision, scale int) int {
+	leftover := []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+	intDigits := precision - scale
+	return intDigits/9*4 + leftover[intDigits%9] + scale/9*4 + leftover[scale%9]
+}
+
+// fspSize 时间类型小数秒占用的字节数
+func fspSize(fsp int) int {
+	if fsp <= 0 {
+		return 0
+	}
+	return (fsp + 1) / 2
+}
+
+// columnRowSize 计算列最多占用的字节数，charset 为表的默认字符集
+func columnRowSize(c *tidb.ColumnDef, charset string) rowColumn {
+	col := rowColumn{def: c}
+	for _, opt := range c.Options {
+		if opt.Tp == tidb.ColumnOptionNotNull || opt.Tp == tidb.ColumnOptionPrimaryKey {
+			col.notNull = true
+		}
+	}
+	if c.Tp.Charset != "" {
+		charset = c.Tp.Charset
+	}
+	mbMaxLen, ok := charsetMaxLen[strings.ToLower(charset)]
+	if !ok {
+		mbMaxLen = 4
+	}
+	flen := c.Tp.Flen
+	switch c.Tp.Tp {
+	case mysql.TypeTiny, mysql.TypeYear:
+		col.size = 1
+	case mysql.TypeShort:
+		col.size = 2
+	case mysql.TypeInt24, mysql.TypeDate, mysql.TypeNewDate:
+		col.size = 3
+	case mysql.TypeLong, mysql.TypeFloat:
+		col.size = 4
+	case mysql.TypeLonglong, mysql.TypeDouble:
+		col.size = 8
+	case mysql.TypeNewDecimal, mysql.TypeDecimal:
+		precision, scale := flen, c.Tp.Decimal
+		if precision <= 0 {
+			precision = 10
+		}
+		if scale < 0 {
+			scale = 0
+		}
+		col.size = decimalSize(precision, scale)
+	case mysql.TypeDuration:
+		col.size = 3 + fspSize(c.Tp.Decimal)
+	case mysql.TypeDatetime:
+		col.size = 5 + fspSize(c.Tp.Decimal)
+	case mysql.TypeTimestamp:
+		col.size = 4 + fspSize(c.Tp.Decimal)
+	case mysql.TypeBit:
+		if flen <= 0 {
+			flen = 1
+		}
+		col.size = (flen + 7) / 8
+	case mysql.TypeEnum:
+		col.size = 1
+		if len(c.Tp.Elems) > 255 {
+			col.size = 2
+		}
+	case mysql.TypeSet:
+		col.size = (len(c.Tp.Elems) + 7) / 8
+		if col.size > 4 {
+			col.size = 8
+		}
+	case mysql.TypeString:
+		if flen < 0 {
+			flen = 1
+		}
+		col.size = flen * mbMaxLen
+	case mysql.TypeVarchar, mysql.TypeVarString:
+		col.size = flen * mbMaxLen
+		col.varLen = true
+		if col.size > 255 {
+			col.size += 2
+		} else {
+			col.size++
+		}
+	case mysql.TypeTinyBlob:
+		col.size, col.inRow, col.blob, col.varLen = 255, 9, true, true
+	case mysql.TypeBlob:
+		col.size, col.inRow, col.blob, col.varLen = 65535, 10, true, true
+	case mysql.TypeMediumBlob:
+		col.size, col.inRow, col.blob, col.varLen = 1<<24-1, 11, true, true
+	default:
+		// LONGBLOB, LONGTEXT, JSON, GEOMETRY
+		col.size, col.inRow, col.blob, col.varLen = 1<<32-1, 12, true, true
+	}
+	if !col.blob {
+		col.inRow = col.size
+	}
+	return col
+}
+
+// RowSizeEstimate 建表语句的行长度估算结果
+type RowSizeEstimate struct {
+	Table     string
+	RowSize   int      // 计入 65535 限制的最大行长度
+	InPage    int      // 变长列全部保存在页内时的最大长度
+	MinInPage int      // 变长列尽可能存储在溢出页后页内的最大长度
+	OffPage   []string // 行最长时需要存储在溢出页的列
+	Compact   bool     // 使用 COMPACT 或 REDUNDANT 行格式
+	columns   []rowColumn
+}
+
+// EstimateRowSize 根据列类型及字符集估算建表语句的最大行长度
+// 行长度超过页内限制时，InnoDB 从最长的变长列开始存储到溢出页，直到剩余部分可以保存在页内
+func EstimateRowSize(ct *tidb.CreateTableStmt) RowSizeEstimate {
+	est := RowSizeEstimate{Table: ct.Table.Name.O}
+	charset := "utf8mb4"
+	for _, opt := range ct.Options {
+		switch opt.Tp {
+		case tidb.TableOptionCharset:
+			charset = opt.StrValue
+		case tidb.TableOptionRowFormat:
+			est.Compact = opt.UintValue == tidb.RowFormatCompact || opt.UintValue == tidb.RowFormatRedundant
+		}
+	}
+
+	nullable := 0
+	for _, c := range ct.Cols {
+		if c.Tp == nil {
+			continue
+		}
+		col := columnRowSize(c, charset)
+		est.columns = append(est.columns, col)
+		est.RowSize += col.inRow
+		est.InPage += col.size
+		if !col.notNull {
+			nullable++
+		}
+	}
+	est.RowSize += (nullable + 7) / 8
+	est.InPage += (nullable + 7) / 8
+
+	// DYNAMIC 行格式中超过 40 字节的变长列可以整列存储在溢出页，COMPACT 行格式在页内保留 768 字节前缀
+	reserved, minLen := offPagePointer, 40
+	if est.Compact {
+		reserved, minLen = compactPrefix+offPagePointer, compactPrefix
+	}
+	var candidates []rowColumn
+	for _, col := range est.columns {
+		if col.varLen && col.size > minLen {
+			candidates = append(candidates, col)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].size > candidates[j].size })
+	est.MinInPage = est.InPage
+	for _, col := range candidates {
+		if est.MinInPage > maxInPageRowSize {
+			est.OffPage = append(est.OffPage, col.def.Name.Name.O)
+		}
+		if col.size > reserved {
+			est.MinInPage -= col.size - reserved
+		}
+	}
+	return est
+}
+
+// verticalSplit 将预计存储在溢出页的列拆分到单独的表中，使用单列主键关联
+func (est RowSizeEstimate) verticalSplit(ct *tidb.CreateTableStmt) string {
+	pk := primaryKeyColumns(ct)
+	if len(pk) != 1 {
+		return ""
+	}
+	offPage := make(map[string]bool)
+	for _, name := range est.OffPage {
+		offPage[strings.ToLower(name)] = true
+	}
+	var pkDef string
+	var defs []string
+	for _, col := range est.columns {
+		name := col.def.Name.Name.L
+		if !pk[name] && !offPage[name] {
+			continue
+		}
+		var sb strings.Builder
+		if err := col.def.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)); err != nil {
+			return ""
+		}
+		def := sb.String()
+		if pk[name] {
+			def = fmt.Sprintf("`%s` %s NOT NULL", col.def.Name.Name.O, col.def.Tp.InfoSchemaStr())
+			pkDef = col.def.Name.Name.O
+			defs = append([]string{def}, defs...)
+			continue
+		}
+		defs = append(defs, def)
+	}
+	return fmt.Sprintf("CREATE TABLE `%s_ext` (%s, PRIMARY KEY (`%s`));", est.Table, strings.Join(defs, ", "), pkDef)
+}
+
+// RuleRowSize COL.021
+func (q *Query4Audit) RuleRowSize() Rule {
+	var rule = q.RuleOK()
+	for _, node := range q.TiStmt {
+		ct, ok := node.(*tidb.CreateTableStmt)
+		if !ok || len(ct.Cols) == 0 {
+			continue
+		}
+		engine := ""
+		for _, opt := range ct.Options {
+			if opt.Tp == tidb.TableOptionEngine {
+				engine = strings.ToLower(opt.StrValue)
+			}
+		}
+		if engine != "" && engine != "innodb" {
+			continue
+		}
+
+		est := EstimateRowSize(ct)
+		var notes []string
+		switch {
+		case est.RowSize > maxRowSize:
+			notes = append(notes, fmt.Sprintf("`%s` 的最大行长度约为 %d 字节，超过了 %d 字节的限制，建表时会报错 (ERROR 1118)，请将部分 VARCHAR 列改为 TEXT 或拆分表。",
+				est.Table, est.RowSize, maxRowSize))
+		case float64(est.RowSize) >= rowSizeWarnRatio*maxRowSize:
+			notes = append(notes, fmt.Sprintf("`%s` 的最大行长度约为 %d 字节，已接近 %d 字节的限制，后续无法再添加较长的列。",
+				est.Table, est.RowSize, maxRowSize))
+		}
+		if est.MinInPage > maxInPageRowSize {
+			notes = append(notes, fmt.Sprintf("`%s` 的变长列全部存储在溢出页后页内仍需约 %d 字节，超过了 innodb_page_size 为 16K 时的 %d 字节，开启 innodb_strict_mode 时建表会报错，否则写入较长的行时报错。",
+				est.Table, est.MinInPage, maxInPageRowSize))
+		}
+		if len(est.OffPage) >= offPageColumnThreshold {
+			note := fmt.Sprintf("`%s` 的行超过页内 %d 字节时，%s 等 %d 列将存储在溢出页，读取这些列需要额外的 I/O。",
+				est.Table, maxInPageRowSize, strings.Join(est.OffPage, ", "), len(est.OffPage))
+			if split := est.verticalSplit(ct); split != "" {
+				note += " 不经常读取的大字段建议拆分到单独的表: " + split
+			}
+			notes = append(notes, note)
+		}
+		if len(notes) > 0 {
+			rule = HeuristicRules["COL.021"]
+			rule.Content = strings.Join(append([]string{rule.Content}, notes...), " ")
+		}
+	}
+	return rule
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+func TestEstimateRowSize(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string][3]int{
+		// 4 + (10 * 4 + 1) + 8 + 5 + 1(NULL)
+		"CREATE TABLE t (id INT NOT NULL, a VARCHAR(10), b BIGINT, c DECIMAL(10,2))": {4 + 41 + 8 + 5 + 1, 4 + 41 + 8 + 5 + 1, 0},
+		// latin1 CHAR(10) + DATETIME(3) + TEXT
+		"CREATE TABLE t (a CHAR(10) CHARSET latin1 NOT NULL, b DATETIME(3) NOT NULL, c TEXT NOT NULL)": {10 + 7 + 10, 10 + 7 + 65535, 1},
+		"CREATE TABLE t (a VARCHAR(300) NOT NULL) DEFAULT CHARSET=utf8":                                {902, 902, 0},
+	}
+	for sql, want := range cases {
+		stmts, err := ast.TiParse(sql, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		est := EstimateRowSize(stmts[0].(*tidb.CreateTableStmt))
+		if est.RowSize != want[0] || est.InPage != want[1] || len(est.OffPage) != want[2] {
+			t.Errorf("SQL: %s, want: %v, got: %d %d %v", sql, want, est.RowSize, est.InPage, est.OffPage)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// COL.021
+func TestRuleRowSize(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string][]string{
+		HeuristicRules["COL.021"].Case: {"`tbl` 的最大行长度约为 80009 字节，超过了 65535 字节的限制"},
+		"CREATE TABLE t (id INT PRIMARY KEY, a VARCHAR(7000) CHARSET latin1, b VARCHAR(7000) CHARSET latin1, c VARCHAR(7000) CHARSET latin1, d VARCHAR(7000) CHARSET latin1, e VARCHAR(7000) CHARSET latin1, f VARCHAR(7000) CHARSET latin1, g VARCHAR(7000) CHARSET latin1, h VARCHAR(7000) CHARSET latin1)": {
+			"`t` 的最大行长度约为 56021 字节，已接近 65535 字节的限制",
+			"a, b, c, d, e, f, g 等 7 列将存储在溢出页",
+			"CREATE TABLE `t_ext` (`id` int(11) NOT NULL, `a` VARCHAR(7000) CHARACTER SET LATIN1,",
+		},
+		// COMPACT 行格式中每个溢出列在页内保留 768 字节前缀及 20 字节指针
+		"CREATE TABLE t (id INT PRIMARY KEY, c0 VARCHAR(1000), c1 VARCHAR(1000), c2 VARCHAR(1000), c3 VARCHAR(1000), c4 VARCHAR(1000), c5 VARCHAR(1000), c6 VARCHAR(1000), c7 VARCHAR(1000), c8 VARCHAR(1000), c9 VARCHAR(1000), c10 VARCHAR(1000)) ROW_FORMAT=COMPACT": {
+			"变长列全部存储在溢出页后页内仍需约 8674 字节",
+		},
+		"CREATE TABLE t (id INT PRIMARY KEY, a VARCHAR(1000), b TEXT)":                          nil,
+		"CREATE TABLE t (id INT PRIMARY KEY, a VARCHAR(10000), b VARCHAR(10000)) ENGINE=MyISAM": nil,
+	}
+	for sql, wants := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleRowSize()
+		if len(wants) == 0 {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		for _, want := range wants {
+			if rule.Item != "COL.021" || !strings.Contains(rule.Content, want) {
+				t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
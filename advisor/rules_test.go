@@ -46,6 +46,35 @@ func TestListHeuristicRules(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestListRuleSummaries(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	err := common.GoldenDiff(func() { ListRuleSummaries() }, t.Name(), update)
+	if nil != err {
+		t.Fatal(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestShowHeuristicRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	err := common.GoldenDiff(func() {
+		for _, item := range []string{"ARG.003", "col.001", "ALI.001"} {
+			if err := ShowHeuristicRule(item); err != nil {
+				t.Error(err)
+			}
+		}
+	}, t.Name(), update)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, item := range []string{"OK", "XXX.001"} {
+		if ShowHeuristicRule(item) == nil {
+			t.Errorf("%s should not be found", item)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestInBlackList(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	sqls := []string{
@@ -69,3 +98,26 @@ func TestIsIgnoreRule(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestFormatJoinGraph(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgJoinGraph := common.Config.JoinGraph
+	sql := "select * from film f join film_actor fa on f.film_id = fa.film_id"
+	if graph := formatJoinGraph(sql, map[string]Rule{}); graph != "" {
+		t.Errorf("simple join should not output join graph, got: %s", graph)
+	}
+	common.Config.JoinGraph = "mermaid"
+	if graph := formatJoinGraph(sql, map[string]Rule{"JOI.005": HeuristicRules["JOI.005"]}); !strings.HasPrefix(graph, "## JOIN 关系图\n```mermaid\ngraph LR") {
+		t.Errorf("JOI.005 should output mermaid join graph, got: %s", graph)
+	}
+	common.Config.JoinGraph = "dot"
+	if graph := formatJoinGraph("select * from film, actor", map[string]Rule{}); !strings.Contains(graph, "```dot\ngraph join {") {
+		t.Errorf("cartesian join should output dot join graph, got: %s", graph)
+	}
+	common.Config.JoinGraph = ""
+	if graph := formatJoinGraph("select * from film, actor", map[string]Rule{}); graph != "" {
+		t.Errorf("join-graph disabled, got: %s", graph)
+	}
+	common.Config.JoinGraph = orgJoinGraph
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RuleTestCase 规则单元测试用例，soar rules test 读取的 YAML 文件为用例列表，每项包含 name, sql, triggered, not-triggered
+type RuleTestCase struct {
+	Name         string   `yaml:"name"`
+	SQL          string   `yaml:"sql"`
+	Triggered    []string `yaml:"triggered"`     // 期望给出的建议，支持以 * 结尾的前缀匹配
+	NotTriggered []string `yaml:"not-triggered"` // 期望不给出的建议，支持以 * 结尾的前缀匹配
+}
+
+// RuleTestResult 规则单元测试结果
+type RuleTestResult struct {
+	Case       RuleTestCase `json:"Case"`
+	Suggest    []string     `json:"Suggest"`    // 实际给出的建议
+	Missing    []string     `json:"Missing"`    // 期望给出但未给出的建议
+	Unexpected []string     `json:"Unexpected"` // 期望不给出但给出了的建议
+}
+
+// Passed 测试用例是否通过
+func (r RuleTestResult) Passed() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// LoadRuleTestCases 读取 YAML 格式的规则单元测试用例
+func LoadRuleTestCases(file string) ([]RuleTestCase, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cases []RuleTestCase
+	err = yaml.Unmarshal(buf, &cases)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for i, c := range cases {
+		if strings.TrimSpace(c.SQL) == "" {
+			return nil, fmt.Errorf("%s: case %d '%s' sql is empty", file, i+1, c.Name)
+		}
+		if c.Name == "" {
+			cases[i].Name = fmt.Sprintf("case %d", i+1)
+		}
+	}
+	return cases, nil
+}
+
+// RunRuleTest 使用当前配置（ignore-rules, rule-thresholds, rule-precedence 等）执行启发式规则，检查建议是否符合预期
+func RunRuleTest(c RuleTestCase) RuleTestResult {
+	res := RuleTestResult{Case: c}
+	suggest := ruleTestSuggest(c.SQL)
+	for item := range suggest {
+		res.Suggest = append(res.Suggest, item)
+	}
+	sort.Strings(res.Suggest)
+
+	for _, expect := range c.Triggered {
+		found := false
+		for _, item := range res.Suggest {
+			if matchRuleItem(expect, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res.Missing = append(res.Missing, expect)
+		}
+	}
+	for _, unexpect := range c.NotTriggered {
+		for _, item := range res.Suggest {
+			if matchRuleItem(unexpect, item) {
+				res.Unexpected = append(res.Unexpected, item)
+			}
+		}
+	}
+	return res
+}
+
+// ruleTestSuggest 执行所有未忽略的启发式规则，语法错误时给出 ERR.000
+func ruleTestSuggest(sql string) map[string]Rule {
+	suggest := make(map[string]Rule)
+	q, err := NewQuery4Audit(sql)
+	if err != nil {
+		suggest["ERR.000"] = RuleSyntaxError(err, 0)
+		if q == nil {
+			return suggest
+		}
+	}
+	for item, rule := range HeuristicRules {
+		if item == "OK" || IsIgnoreRule(item) {
+			continue
+		}
+		if r := ruleTestCheck(item, rule, q); r.Item == item {
+			suggest[item] = r
+		}
+	}
+	return MergeConflictHeuristicRules(suggest)
+}
+
+// ruleTestCheck 执行单条规则，捕获规则执行时的 panic，避免一条用例中断整个测试
+func ruleTestCheck(item string, rule Rule, q *Query4Audit) (r Rule) {
+	defer func() {
+		if err := recover(); err != nil {
+			common.Log.Error("ruleTestCheck %s recover: %v, Query: %s", item, err, q.Query)
+			r = HeuristicRules["OK"]
+		}
+	}()
+	return rule.Func(q)
+}
+
+// FormatRuleTestResults 格式化规则单元测试结果，输出格式与 go test 类似，report-type 为 json 时输出 JSON
+func FormatRuleTestResults(results []RuleTestResult) string {
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+
+	if common.Config.ReportType == "json" {
+		js, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			common.Log.Error("FormatRuleTestResults json.Marshal Error: %v", err)
+		}
+		return string(js)
+	}
+
+	var buf []string
+	for _, r := range results {
+		if r.Passed() {
+			buf = append(buf, fmt.Sprintf("--- PASS: %s", r.Case.Name))
+			continue
+		}
+		buf = append(buf, fmt.Sprintf("--- FAIL: %s", r.Case.Name))
+		buf = append(buf, fmt.Sprintf("    sql: %s", strings.TrimSpace(r.Case.SQL)))
+		if len(r.Missing) > 0 {
+			buf = append(buf, fmt.Sprintf("    expected triggered: %s", strings.Join(r.Missing, ", ")))
+		}
+		if len(r.Unexpected) > 0 {
+			buf = append(buf, fmt.Sprintf("    expected not triggered: %s", strings.Join(r.Unexpected, ", ")))
+		}
+		buf = append(buf, fmt.Sprintf("    got: %s", strings.Join(r.Suggest, ", ")))
+	}
+	if failed > 0 {
+		buf = append(buf, fmt.Sprintf("FAIL\t%d cases, %d failed", len(results), failed))
+	} else {
+		buf = append(buf, fmt.Sprintf("PASS\t%d cases", len(results)))
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestRunRuleTest(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-rule-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "rules_test.yaml")
+	err = ioutil.WriteFile(file, []byte(`
+- name: select star
+  sql: SELECT * FROM film WHERE id = 1
+  triggered: [COL.001]
+  not-triggered: [CLA.001]
+- sql: SELECT title FROM film
+  triggered: [COL.001]
+  not-triggered: [CLA.*]
+- name: syntax error
+  sql: SELEC * FROM film
+  triggered: [ERR.000]
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases, err := LoadRuleTestCases(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 3 || cases[1].Name != "case 2" {
+		t.Fatalf("LoadRuleTestCases got: %v", cases)
+	}
+
+	var results []RuleTestResult
+	for _, c := range cases {
+		results = append(results, RunRuleTest(c))
+	}
+	if !results[0].Passed() || !results[2].Passed() {
+		t.Errorf("case should pass: %v, %v", results[0], results[2])
+	}
+	if results[1].Passed() ||
+		strings.Join(results[1].Missing, ",") != "COL.001" ||
+		!strings.Contains(strings.Join(results[1].Unexpected, ","), "CLA.001") {
+		t.Errorf("case should fail: %v", results[1])
+	}
+
+	output := FormatRuleTestResults(results)
+	for _, s := range []string{"--- PASS: select star", "--- FAIL: case 2", "FAIL\t3 cases, 1 failed"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("output should contain '%s', got: %s", s, output)
+		}
+	}
+
+	err = ioutil.WriteFile(file, []byte("- name: empty\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadRuleTestCases(file); err == nil {
+		t.Error("case without sql should return error")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
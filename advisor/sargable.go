@@ -0,0 +1,516 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// sargableHints 对 FUN.001 命中的条件给出改写建议，无法安全改写时建议使用函数索引或生成列
+func sargableHints(stmt sqlparser.Statement, conds []sqlparser.Expr) []string {
+	var hints []string
+	for _, cond := range conds {
+		var hint string
+		switch n := cond.(type) {
+		case *sqlparser.ComparisonExpr:
+			hint = comparisonHint(stmt, n)
+		case *sqlparser.RangeCond:
+			hint = rangeHint(stmt, n)
+		}
+		if hint != "" {
+			hints = append(hints, hint)
+		}
+	}
+	return common.RemoveDuplicatesItem(hints)
+}
+
+// wrappedColumn 获取表达式中唯一的列，表达式中包含子查询或多个列时返回 nil
+func wrappedColumn(expr sqlparser.Expr) *sqlparser.ColName {
+	var col *sqlparser.ColName
+	multi := false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.ColName:
+			if col != nil && !col.Equal(n) {
+				multi = true
+			}
+			col = n
+		case *sqlparser.Subquery:
+			multi = true
+		}
+		return !multi, nil
+	}, expr)
+	common.LogIfError(err, "")
+	if multi {
+		return nil
+	}
+	return col
+}
+
+// sameColumn 判断表达式是否为指定的列
+func sameColumn(col *sqlparser.ColName, expr sqlparser.Expr) bool {
+	c, ok := expr.(*sqlparser.ColName)
+	return ok && col.Equal(c)
+}
+
+// isConstExpr 表达式中不包含列及子查询
+func isConstExpr(exprs ...sqlparser.Expr) bool {
+	for _, expr := range exprs {
+		if expr == nil {
+			return false
+		}
+		constant := true
+		err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			switch node.(type) {
+			case *sqlparser.ColName, *sqlparser.Subquery:
+				constant = false
+			}
+			return constant, nil
+		}, expr)
+		common.LogIfError(err, "")
+		if !constant {
+			return false
+		}
+	}
+	return true
+}
+
+// intValue 获取整数常量的值
+func intValue(expr sqlparser.Expr) (int64, bool) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(string(val.Val), 10, 64)
+	return i, err == nil
+}
+
+// numberValue 获取数值常量的值
+func numberValue(expr sqlparser.Expr) (float64, bool) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || (val.Type != sqlparser.IntVal && val.Type != sqlparser.FloatVal) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(string(val.Val), 64)
+	return f, err == nil
+}
+
+// funcArgs 获取函数的参数，参数中包含 * 等非表达式时返回 false
+func funcArgs(f *sqlparser.FuncExpr) ([]sqlparser.Expr, bool) {
+	var args []sqlparser.Expr
+	for _, e := range f.Exprs {
+		arg, ok := e.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, false
+		}
+		args = append(args, arg.Expr)
+	}
+	return args, true
+}
+
+// likePrefix 将 SUBSTRING(col, 1, n) = 'abc' 改写为 col LIKE 'abc%'，要求常量长度等于 n
+func likePrefix(col *sqlparser.ColName, from, length, right sqlparser.Expr) string {
+	val, ok := right.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.StrVal {
+		return ""
+	}
+	if from != nil {
+		if pos, ok := intValue(from); !ok || pos != 1 {
+			return ""
+		}
+	}
+	n, ok := intValue(length)
+	if !ok || int64(len([]rune(string(val.Val)))) != n {
+		return ""
+	}
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `'`, `''`).Replace(string(val.Val))
+	return fmt.Sprintf("%s LIKE '%s%%'", sqlparser.String(col), prefix)
+}
+
+// dateRange 将 DATE(col) op 'd' 改写为 col 上的范围查询
+func dateRange(col, op, v string) string {
+	next := fmt.Sprintf("DATE_ADD(%s, INTERVAL 1 DAY)", v)
+	switch op {
+	case sqlparser.EqualStr:
+		return fmt.Sprintf("%s >= %s AND %s < %s", col, v, col, next)
+	case sqlparser.GreaterThanStr:
+		return fmt.Sprintf("%s >= %s", col, next)
+	case sqlparser.GreaterEqualStr:
+		return fmt.Sprintf("%s >= %s", col, v)
+	case sqlparser.LessThanStr:
+		return fmt.Sprintf("%s < %s", col, v)
+	case sqlparser.LessEqualStr:
+		return fmt.Sprintf("%s < %s", col, next)
+	}
+	return ""
+}
+
+// yearRange 将 YEAR(col) op N 改写为 col 上的范围查询
+func yearRange(col, op string, year int64) string {
+	begin := fmt.Sprintf("'%d-01-01'", year)
+	end := fmt.Sprintf("'%d-01-01'", year+1)
+	switch op {
+	case sqlparser.EqualStr:
+		return fmt.Sprintf("%s >= %s AND %s < %s", col, begin, col, end)
+	case sqlparser.GreaterThanStr:
+		return fmt.Sprintf("%s >= %s", col, end)
+	case sqlparser.GreaterEqualStr:
+		return fmt.Sprintf("%s >= %s", col, begin)
+	case sqlparser.LessThanStr:
+		return fmt.Sprintf("%s < %s", col, begin)
+	case sqlparser.LessEqualStr:
+		return fmt.Sprintf("%s < %s", col, end)
+	}
+	return ""
+}
+
+// arithmeticInverse 将 col + N 等运算移至常量一侧，只处理 N 为正数的情况以免改变比较方向
+func arithmeticInverse(expr *sqlparser.BinaryExpr, right sqlparser.Expr) (*sqlparser.ColName, string) {
+	col, ok := expr.Left.(*sqlparser.ColName)
+	operand := expr.Right
+	if !ok {
+		// N + col, N * col
+		if expr.Operator != sqlparser.PlusStr && expr.Operator != sqlparser.MultStr {
+			return nil, ""
+		}
+		if col, ok = expr.Right.(*sqlparser.ColName); !ok {
+			return nil, ""
+		}
+		operand = expr.Left
+	}
+	n, ok := numberValue(operand)
+	if !ok {
+		return nil, ""
+	}
+	var inverse string
+	switch expr.Operator {
+	case sqlparser.PlusStr:
+		inverse = sqlparser.MinusStr
+	case sqlparser.MinusStr:
+		inverse = sqlparser.PlusStr
+	case sqlparser.MultStr:
+		inverse = sqlparser.DivStr
+	case sqlparser.DivStr:
+		inverse = sqlparser.MultStr
+	default:
+		return nil, ""
+	}
+	if (inverse == sqlparser.DivStr || inverse == sqlparser.MultStr) && n <= 0 {
+		return nil, ""
+	}
+
+	// 常量直接计算结果
+	if v, ok := numberValue(right); ok {
+		var res float64
+		switch inverse {
+		case sqlparser.PlusStr:
+			res = v + n
+		case sqlparser.MinusStr:
+			res = v - n
+		case sqlparser.MultStr:
+			res = v * n
+		case sqlparser.DivStr:
+			res = v / n
+		}
+		return col, strconv.FormatFloat(res, 'f', -1, 64)
+	}
+	return col, fmt.Sprintf("%s %s %s", sqlparser.String(right), inverse, sqlparser.String(operand))
+}
+
+// comparisonHint 比较运算的改写建议
+func comparisonHint(stmt sqlparser.Statement, n *sqlparser.ComparisonExpr) string {
+	col := wrappedColumn(n.Left)
+	if col == nil || !isConstExpr(n.Right) {
+		return ""
+	}
+	right := sqlparser.String(n.Right)
+	var rewrite string
+	switch left := n.Left.(type) {
+	case *sqlparser.FuncExpr:
+		args, ok := funcArgs(left)
+		if !ok || len(args) == 0 || !sameColumn(col, args[0]) {
+			break
+		}
+		switch left.Name.Lowered() {
+		case "date":
+			rewrite = dateRange(sqlparser.String(col), n.Operator, right)
+		case "year":
+			if year, ok := intValue(n.Right); ok {
+				rewrite = yearRange(sqlparser.String(col), n.Operator, year)
+			}
+		case "unix_timestamp":
+			if len(args) == 1 && n.Operator != sqlparser.NullSafeEqualStr {
+				rewrite = fmt.Sprintf("%s %s FROM_UNIXTIME(%s)", sqlparser.String(col), n.Operator, right)
+			}
+		case "left":
+			if len(args) == 2 && n.Operator == sqlparser.EqualStr {
+				rewrite = likePrefix(col, nil, args[1], n.Right)
+			}
+		case "substr", "substring":
+			if len(args) == 3 && n.Operator == sqlparser.EqualStr {
+				rewrite = likePrefix(col, args[1], args[2], n.Right)
+			}
+		}
+	case *sqlparser.SubstrExpr:
+		if left.Name != nil && left.Name.Equal(col) && n.Operator == sqlparser.EqualStr {
+			rewrite = likePrefix(col, left.From, left.To, n.Right)
+		}
+	case *sqlparser.BinaryExpr:
+		switch n.Operator {
+		case sqlparser.EqualStr, sqlparser.LessThanStr, sqlparser.GreaterThanStr,
+			sqlparser.LessEqualStr, sqlparser.GreaterEqualStr, sqlparser.NotEqualStr:
+			if c, v := arithmeticInverse(left, n.Right); c != nil {
+				rewrite = fmt.Sprintf("%s %s %s", sqlparser.String(c), n.Operator, v)
+			}
+		}
+	}
+	if rewrite != "" {
+		hint := fmt.Sprintf("`%s` 可改写为 `%s`。", sqlparser.String(n), rewrite)
+		// 日期函数的条件改写为范围查询后，后面的列无法再使用索引，生成列可以作为等值条件用于复合索引
+		if f, ok := n.Left.(*sqlparser.FuncExpr); ok && (f.Name.Lowered() == "year" || f.Name.Lowered() == "date") {
+			if generated := generatedColumnHint(stmt, n, col, n.Left); generated != "" {
+				hint += " 也" + generated
+			}
+		}
+		return hint
+	}
+	return functionIndexHint(stmt, n, col, n.Left)
+}
+
+// rangeHint BETWEEN 的改写建议
+func rangeHint(stmt sqlparser.Statement, n *sqlparser.RangeCond) string {
+	col := wrappedColumn(n.Left)
+	if col == nil || n.Operator != sqlparser.BetweenStr || !isConstExpr(n.From, n.To) {
+		return ""
+	}
+	from, to := sqlparser.String(n.From), sqlparser.String(n.To)
+	var rewrite string
+	switch left := n.Left.(type) {
+	case *sqlparser.FuncExpr:
+		args, ok := funcArgs(left)
+		if !ok || len(args) != 1 || !sameColumn(col, args[0]) {
+			break
+		}
+		c := sqlparser.String(col)
+		switch left.Name.Lowered() {
+		case "date":
+			rewrite = fmt.Sprintf("%s >= %s AND %s < DATE_ADD(%s, INTERVAL 1 DAY)", c, from, c, to)
+		case "unix_timestamp":
+			rewrite = fmt.Sprintf("%s BETWEEN FROM_UNIXTIME(%s) AND FROM_UNIXTIME(%s)", c, from, to)
+		}
+	case *sqlparser.BinaryExpr:
+		c, f := arithmeticInverse(left, n.From)
+		_, t := arithmeticInverse(left, n.To)
+		if c != nil {
+			rewrite = fmt.Sprintf("%s BETWEEN %s AND %s", sqlparser.String(c), f, t)
+		}
+	}
+	if rewrite != "" {
+		return fmt.Sprintf("`%s` 可改写为 `%s`。", sqlparser.String(n), rewrite)
+	}
+	return functionIndexHint(stmt, n, col, n.Left)
+}
+
+// functionIndexHint 无法改写的表达式建议使用函数索引（MySQL 8.0.13+）或生成列（MySQL 5.7+）
+func functionIndexHint(stmt sqlparser.Statement, cond sqlparser.Expr, col *sqlparser.ColName, expr sqlparser.Expr) string {
+	if hasNondeterministic(expr) {
+		return fmt.Sprintf("`%s` 无法改写，表达式中包含结果不确定的函数，不能用于函数索引或生成列。", sqlparser.String(cond))
+	}
+	table := columnTable(stmt, col)
+	name, e := generatedColumnName(col, expr)
+
+	if table == "" {
+		return fmt.Sprintf("`%s` 无法改写，可以为表达式 `%s` 添加生成列并建立索引。", sqlparser.String(cond), e)
+	}
+	if common.TargetDB().Supports(80013, 0) {
+		return fmt.Sprintf("`%s` 无法改写，可以建立函数索引: ALTER TABLE `%s` ADD INDEX `idx_%s` ((%s));",
+			sqlparser.String(cond), table, name, e)
+	}
+	if hint := generatedColumnHint(stmt, cond, col, expr); hint != "" {
+		return fmt.Sprintf("`%s` 无法改写，%s", sqlparser.String(cond), hint)
+	}
+	return fmt.Sprintf("`%s` 无法改写，目标数据库不支持生成列，可以在写入时计算表达式的值保存到单独的列中并建立索引。", sqlparser.String(cond))
+}
+
+// generatedColumnHint 为表达式添加生成列及索引的完整 DDL 及改写后的查询条件，目标数据库不支持生成列或无法确定表名时返回空
+func generatedColumnHint(stmt sqlparser.Statement, cond sqlparser.Expr, col *sqlparser.ColName, expr sqlparser.Expr) string {
+	table := columnTable(stmt, col)
+	if table == "" || hasNondeterministic(expr) || common.TargetDB().Unsupported(50700, 50200) {
+		return ""
+	}
+	name, e := generatedColumnName(col, expr)
+	dataType := generatedColumnType(stmt, col, expr)
+	if dataType == "" {
+		dataType = "<类型>"
+	}
+
+	// 将条件中的表达式替换为生成列，保留原有的表名或别名前缀
+	generated := &sqlparser.ColName{Name: sqlparser.NewColIdent(name), Qualifier: col.Qualifier}
+	buf := sqlparser.NewTrackedBuffer(func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		if node == sqlparser.SQLNode(expr) {
+			generated.Format(buf)
+			return
+		}
+		node.Format(buf)
+	})
+	buf.Myprintf("%v", cond)
+
+	return fmt.Sprintf("可以添加生成列并建立索引: ALTER TABLE `%s` ADD COLUMN `%s` %s AS (%s) VIRTUAL, ADD INDEX `idx_%s` (`%s`); 查询条件改写为 `%s`。",
+		table, name, dataType, e, name, name, buf.String())
+}
+
+// generatedColumnName 生成列的名称及不带表名前缀的表达式
+func generatedColumnName(col *sqlparser.ColName, expr sqlparser.Expr) (string, string) {
+	name := strings.ToLower(col.Name.String())
+	switch f := expr.(type) {
+	case *sqlparser.FuncExpr:
+		name += "_" + f.Name.Lowered()
+	case *sqlparser.SubstrExpr:
+		name += "_substr"
+	default:
+		// 避免与原有的列重名
+		name += "_expr"
+	}
+	// 索引表达式中的列不能带表名或别名前缀
+	buf := sqlparser.NewTrackedBuffer(func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		if c, ok := node.(*sqlparser.ColName); ok {
+			buf.Myprintf("%v", c.Name)
+			return
+		}
+		node.Format(buf)
+	})
+	buf.Myprintf("%v", expr)
+	return name, buf.String()
+}
+
+// generatedColumnType 根据函数的返回值及离线表结构中列的类型推断生成列的类型，无法推断时返回空
+func generatedColumnType(stmt sqlparser.Statement, col *sqlparser.ColName, expr sqlparser.Expr) string {
+	column := offlineColumn(col, offlineTableMap(stmt))
+	stringType := "varchar(255)"
+	if column != nil && columnTypeClass(column.DataType) == "string" {
+		base := strings.ToLower(common.GetDataTypeBase(column.DataType))
+		if base == "char" || base == "varchar" {
+			stringType = column.DataType
+		}
+	}
+
+	switch f := expr.(type) {
+	case *sqlparser.FuncExpr:
+		switch f.Name.Lowered() {
+		case "year", "dayofyear":
+			return "smallint"
+		case "month", "day", "dayofmonth", "dayofweek", "weekday", "week", "quarter", "hour", "minute", "second":
+			return "tinyint"
+		case "date":
+			return "date"
+		case "time":
+			return "time"
+		case "unix_timestamp", "to_days", "to_seconds":
+			return "bigint"
+		case "length", "char_length", "character_length", "bit_length":
+			return "int"
+		case "crc32":
+			return "int unsigned"
+		case "md5":
+			return "char(32)"
+		case "sha", "sha1":
+			return "char(40)"
+		case "left", "right":
+			if args, ok := funcArgs(f); ok && len(args) == 2 {
+				if n, ok := intValue(args[1]); ok && n > 0 {
+					return fmt.Sprintf("varchar(%d)", n)
+				}
+			}
+			return stringType
+		case "lower", "upper", "lcase", "ucase", "trim", "ltrim", "rtrim", "reverse", "substr", "substring", "json_unquote":
+			return stringType
+		case "abs", "floor", "ceil", "ceiling", "round", "truncate":
+			if column != nil && columnTypeClass(column.DataType) == "number" {
+				return column.DataType
+			}
+		}
+	case *sqlparser.SubstrExpr:
+		return stringType
+	case *sqlparser.BinaryExpr:
+		if column != nil && columnTypeClass(column.DataType) == "number" {
+			return column.DataType
+		}
+	}
+	return ""
+}
+
+// nondeterministicFuncs 结果不确定的函数，不能用于生成列及函数索引
+var nondeterministicFuncs = map[string]bool{
+	"now": true, "sysdate": true, "curdate": true, "curtime": true, "current_date": true, "current_time": true,
+	"current_timestamp": true, "localtime": true, "localtimestamp": true, "utc_date": true, "utc_time": true,
+	"utc_timestamp": true, "rand": true, "uuid": true, "uuid_short": true, "connection_id": true,
+	"current_user": true, "user": true, "session_user": true, "system_user": true, "database": true,
+	"schema": true, "found_rows": true, "row_count": true, "last_insert_id": true, "sleep": true,
+}
+
+// hasNondeterministic 表达式中是否包含结果不确定的函数
+func hasNondeterministic(expr sqlparser.Expr) bool {
+	found := false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.FuncExpr:
+			found = nondeterministicFuncs[n.Name.Lowered()]
+		case *sqlparser.CurTimeFuncExpr:
+			found = true
+		}
+		return !found, nil
+	}, expr)
+	common.LogIfError(err, "")
+	return found
+}
+
+// columnTable 获取列所在的表名，无法确定时返回空
+func columnTable(stmt sqlparser.Statement, col *sqlparser.ColName) string {
+	var tables []*common.Table
+	for _, db := range ast.GetMeta(stmt, nil) {
+		for _, tb := range db.Table {
+			if tb.TableName != "" {
+				tables = append(tables, tb)
+			}
+		}
+	}
+	if col.Qualifier.Name.IsEmpty() {
+		if len(tables) == 1 {
+			return tables[0].TableName
+		}
+		return ""
+	}
+	qualifier := col.Qualifier.Name.String()
+	for _, tb := range tables {
+		if strings.EqualFold(tb.TableName, qualifier) {
+			return tb.TableName
+		}
+		for _, alias := range tb.TableAliases {
+			if strings.EqualFold(alias, qualifier) {
+				return tb.TableName
+			}
+		}
+	}
+	return ""
+}
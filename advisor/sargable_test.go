@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// FUN.001
+func TestSargableHints(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+	common.Config.Target = ""
+
+	sqls := map[string]string{
+		"select * from t where date(c) = '2020-01-01'":                         "`c >= '2020-01-01' AND c < DATE_ADD('2020-01-01', INTERVAL 1 DAY)`",
+		"select * from t where date(c) between '2020-01-01' and '2020-01-31'":  "`c >= '2020-01-01' AND c < DATE_ADD('2020-01-31', INTERVAL 1 DAY)`",
+		"select * from t a where year(a.c) > 2020":                             "`a.c >= '2021-01-01'`",
+		"select * from t where left(c, 3) = 'a_c'":                             "`c LIKE 'a\\_c%'`",
+		"select id from t where substring(name,1,3)='abc'":                     "`name LIKE 'abc%'`",
+		"select id from t where num/2 = 100":                                   "`num = 200`",
+		"select id from t where 1 + num >= 7":                                  "`num >= 6`",
+		"select id from t where num + 1 between 3 and 5":                       "`num BETWEEN 2 AND 4`",
+		"select id from t where unix_timestamp(c) > 1542332760":                "`c > FROM_UNIXTIME(1542332760)`",
+		"select * from t1 join t2 on t1.id = t2.id where lower(t1.name) = 'a'": "ALTER TABLE `t1` ADD COLUMN `name_lower` varchar(255) AS (lower(name)) VIRTUAL, ADD INDEX `idx_name_lower` (`name_lower`); 查询条件改写为 `t1.name_lower = 'a'`。",
+		"select * from t where year(created_at) = 2024":                        "ALTER TABLE `t` ADD COLUMN `created_at_year` smallint AS (year(created_at)) VIRTUAL, ADD INDEX `idx_created_at_year` (`created_at_year`); 查询条件改写为 `created_at_year = 2024`。",
+		"select * from t where left(c, 3) = 'ab'":                              "ADD COLUMN `c_left` varchar(3) AS (left(c, 3))",
+		"select id from t where num % 7 = 1":                                   "ADD COLUMN `num_expr` <类型> AS (num % 7)",
+		"select id from t where num + rand() > 1":                              "不能用于函数索引或生成列",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleCompareWithFunction()
+		if rule.Item != "FUN.001" || !strings.Contains(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+
+	// 无法安全改写时不给出改写建议
+	for _, sql := range []string{
+		"select * from t where left(c, 3) = 'ab'",
+		"select * from t where c * -1 = 3",
+		"select * from t where date(c) != '2020-01-01' and substr(c, 2, 3) = 'abc'",
+	} {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleCompareWithFunction()
+		if rule.Item != "FUN.001" || strings.Contains(rule.Content, "可改写为") {
+			t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+		}
+	}
+
+	common.Config.Target = "mysql:5.6"
+	q, err := NewQuery4Audit("select * from t where lower(c) = 'a'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleCompareWithFunction(); !strings.Contains(rule.Content, "目标数据库不支持生成列") {
+		t.Errorf("got: %s", rule.Content)
+	}
+
+	common.Config.Target = "mysql:8.0.13"
+	q, err = NewQuery4Audit("select * from t where abs(t.c) = 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := q.RuleCompareWithFunction(); !strings.Contains(rule.Content, "ALTER TABLE `t` ADD INDEX `idx_c_abs` ((abs(c)));") {
+		t.Errorf("got: %s", rule.Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
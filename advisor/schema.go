@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+)
+
+// SchemaTable 单表的评审结果
+type SchemaTable struct {
+	Database string
+	Table    string
+	Query    string          // 建表语句
+	Score    int             // 得分，计算方法与 SQL 评审相同
+	Suggest  map[string]Rule // 启发式建议
+}
+
+// SchemaAudit 对整个库的建表语句逐表执行启发式规则检查
+// buf 为 mysqldump --no-data 导出的内容，buf 为空时通过 SHOW CREATE TABLE 获取 OnlineDsn 中所有表的建表语句
+func SchemaAudit(conn *database.Connector, buf string) []SchemaTable {
+	common.Log.Debug("Enter:  SchemaAudit, Caller: %s", common.Caller())
+	tables := LoadSchema(conn, buf)
+	for i, tb := range tables {
+		tables[i].Suggest = schemaTableSuggest(tb.Query)
+		tables[i].Score = SuggestScore(tables[i].Suggest)
+	}
+	return tables
+}
+
+// LoadSchema 获取所有表的建表语句，buf 为 mysqldump --no-data 导出的内容，buf 为空时从 conn 中获取
+func LoadSchema(conn *database.Connector, buf string) []SchemaTable {
+	if strings.TrimSpace(buf) != "" {
+		return parseSchemaDump(buf)
+	}
+
+	var result []SchemaTable
+	if conn == nil {
+		return result
+	}
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *conn
+	tables, err := tmpOnline.ShowTables()
+	if err != nil {
+		common.Log.Error("LoadSchema ShowTables Error: %v", err)
+		return result
+	}
+	for _, name := range tables {
+		ddl, err := tmpOnline.ShowCreateTableFull(name)
+		if err != nil {
+			common.Log.Warning("LoadSchema ShowCreateTableFull Error: %v, Table: %s", err, name)
+			continue
+		}
+		// 跳过视图
+		if !strings.HasPrefix(strings.ToUpper(ddl), "CREATE TABLE") {
+			continue
+		}
+		result = append(result, SchemaTable{
+			Database: tmpOnline.Database,
+			Table:    name,
+			Query:    ddl,
+		})
+	}
+	return result
+}
+
+// parseSchemaDump 从 mysqldump 导出的内容中提取建表语句，其他语句（SET, DROP TABLE, LOCK TABLES 等）会被忽略
+func parseSchemaDump(buf string) []SchemaTable {
+	var tables []SchemaTable
+	var currentDB string
+	delimiter := common.Config.Delimiter
+	for {
+		if strings.TrimSpace(buf) == "" {
+			break
+		}
+		_, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(delimiter))
+		buf = string(bufBytes)
+		if d, ok := ast.ParseDelimiter(sql); ok {
+			delimiter = d
+			continue
+		}
+		sql = database.RemoveSQLComments(sql)
+		if sql == "" {
+			continue
+		}
+
+		// mysqldump --databases 导出时会使用 USE `db` 切换数据库
+		fields := strings.Fields(sql)
+		if len(fields) == 2 && strings.ToLower(fields[0]) == "use" {
+			currentDB = strings.Trim(fields[1], "`;")
+			continue
+		}
+
+		tiStmts, err := ast.TiParse(sql, "", "")
+		if err != nil {
+			continue
+		}
+		for _, stmt := range tiStmts {
+			ct, ok := stmt.(*tidb.CreateTableStmt)
+			if !ok {
+				continue
+			}
+			db := currentDB
+			if ct.Table.Schema.O != "" {
+				db = ct.Table.Schema.O
+			}
+			tables = append(tables, SchemaTable{
+				Database: db,
+				Table:    ct.Table.Name.O,
+				Query:    sql,
+			})
+		}
+	}
+	return tables
+}
+
+// schemaTableSuggest 对单条建表语句执行所有启发式规则
+func schemaTableSuggest(sql string) map[string]Rule {
+	suggest := make(map[string]Rule)
+	q, err := NewQuery4Audit(sql)
+	if err != nil {
+		common.Log.Warning("schemaTableSuggest NewQuery4Audit Error: %v, SQL: %s", err, sql)
+		return suggest
+	}
+	for item, rule := range HeuristicRules {
+		if item == "OK" || IsIgnoreRule(item) {
+			continue
+		}
+		r := rule.Func(q)
+		if r.Item == item {
+			suggest[item] = r
+		}
+	}
+	return MergeConflictHeuristicRules(suggest)
+}
+
+// SuggestScore 根据建议的严重程度打分，每条建议扣除 Severity*5 分，schema-audit, schema-diff 及评审记录使用
+func SuggestScore(suggest map[string]Rule) int {
+	score := 100
+	for item, rule := range suggest {
+		if item == "OK" {
+			continue
+		}
+		l, err := strconv.Atoi(strings.TrimLeft(rule.Severity, "L"))
+		if err != nil {
+			common.Log.Debug("SuggestScore strconv.Atoi Error: %v, Item: %s", err, item)
+			continue
+		}
+		score -= l * 5
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
+
+// FormatSchemaAudit 格式化 schema-audit 的结果，先给出整个库的汇总信息，再逐表给出得分及建议
+func FormatSchemaAudit(tables []SchemaTable) string {
+	if len(tables) == 0 {
+		return "未找到 CREATE TABLE 语句"
+	}
+
+	name := func(tb SchemaTable) string {
+		if tb.Database == "" {
+			return fmt.Sprintf("`%s`", tb.Table)
+		}
+		return fmt.Sprintf("`%s`.`%s`", tb.Database, tb.Table)
+	}
+
+	// 汇总
+	total := 0
+	lowest := tables[0]
+	ruleTables := make(map[string]int)
+	for _, tb := range tables {
+		total += tb.Score
+		if tb.Score < lowest.Score {
+			lowest = tb
+		}
+		for item := range tb.Suggest {
+			ruleTables[item]++
+		}
+	}
+	var items []string
+	for item := range ruleTables {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if ruleTables[items[i]] != ruleTables[items[j]] {
+			return ruleTables[items[i]] > ruleTables[items[j]]
+		}
+		return items[i] < items[j]
+	})
+
+	buf := []string{"# Schema 评审汇总\n"}
+	buf = append(buf, fmt.Sprintf("* **表数量:** %d", len(tables)))
+	buf = append(buf, fmt.Sprintf("* **平均得分:** %d", total/len(tables)))
+	buf = append(buf, fmt.Sprintf("* **最低得分:** %s %d\n", name(lowest), lowest.Score))
+
+	buf = append(buf, "| 表 | 得分 | 建议数 |")
+	buf = append(buf, "| --- | --- | --- |")
+	for _, tb := range tables {
+		buf = append(buf, fmt.Sprintf("| %s | %d | %d |", name(tb), tb.Score, len(tb.Suggest)))
+	}
+	buf = append(buf, "")
+
+	if len(items) > 0 {
+		buf = append(buf, "## 问题分布\n")
+		buf = append(buf, "| Item | Severity | Summary | 涉及表数量 |")
+		buf = append(buf, "| --- | --- | --- | --- |")
+		for _, item := range items {
+			rule := HeuristicRules[item]
+			buf = append(buf, fmt.Sprintf("| %s | %s | %s | %d |", item, rule.Severity, common.MarkdownEscape(rule.Summary), ruleTables[item]))
+		}
+		buf = append(buf, "")
+	}
+
+	// 逐表建议
+	for _, tb := range tables {
+		buf = append(buf, fmt.Sprintf("# Table: %s\n", name(tb)))
+		buf = append(buf, common.Score(tb.Score)+"\n")
+		buf = append(buf, formatHeuristicSuggest(tb.Suggest)...)
+	}
+	return strings.Join(buf, "\n")
+}
+
+// formatHeuristicSuggest 按 Item 排序输出 markdown 格式的启发式建议
+func formatHeuristicSuggest(suggest map[string]Rule) []string {
+	var buf []string
+	if len(suggest) == 0 {
+		return []string{fmt.Sprintln("##", HeuristicRules["OK"].Summary)}
+	}
+	var sorted []string
+	for item := range suggest {
+		sorted = append(sorted, item)
+	}
+	sort.Strings(sorted)
+	for _, item := range sorted {
+		rule := renderRule(suggest[item])
+		buf = append(buf, fmt.Sprintln("##", rule.Summary))
+		buf = append(buf, fmt.Sprintln("* **Item:** ", item))
+		buf = append(buf, fmt.Sprintln("* **Severity:** ", rule.Severity))
+		buf = append(buf, fmt.Sprintln("* **Content:** ", common.MarkdownEscape(rule.Content)))
+		if len(rule.References) > 0 {
+			buf = append(buf, fmt.Sprintln("* **References:** ", formatReferences(rule.References)))
+		}
+	}
+	return buf
+}
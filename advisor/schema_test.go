@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestSchemaAudit(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dump := "-- MySQL dump 10.13\n" +
+		"/*!40101 SET NAMES utf8 */;\n" +
+		"USE `sakila`;\n" +
+		"DROP TABLE IF EXISTS `t1`;\n" +
+		"CREATE TABLE `t1` (\n  `id` int(10) unsigned NOT NULL AUTO_INCREMENT COMMENT 'id',\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='t1';\n" +
+		"DROP TABLE IF EXISTS `t2`;\n" +
+		"CREATE TABLE `t2` (`a` varchar(10), `b` text);\n" +
+		"LOCK TABLES `t2` WRITE;\nUNLOCK TABLES;\n"
+
+	tables := SchemaAudit(nil, dump)
+	if len(tables) != 2 {
+		t.Fatalf("want 2 tables, got %d", len(tables))
+	}
+	if tables[0].Database != "sakila" || tables[1].Table != "t2" {
+		t.Errorf("table name not match: %s.%s, %s.%s", tables[0].Database, tables[0].Table, tables[1].Database, tables[1].Table)
+	}
+	if tables[1].Score >= tables[0].Score {
+		t.Errorf("t2 should score lower than t1, got %d >= %d", tables[1].Score, tables[0].Score)
+	}
+
+	report := FormatSchemaAudit(tables)
+	for _, want := range []string{"# Schema 评审汇总", "* **表数量:** 2", "# Table: `sakila`.`t2`", "## 问题分布"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report should contain %q, got:\n%s", want, report)
+		}
+	}
+
+	if FormatSchemaAudit(SchemaAudit(nil, "SELECT 1;")) != "未找到 CREATE TABLE 语句" {
+		t.Error("empty schema report not match")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,355 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+)
+
+// SchemaDiff 比较两个 Schema，生成将 base 变更为 target 的 SQL
+// 新增的表给出 CREATE TABLE，修改的表每张表合并为一条 ALTER TABLE，删除的表放在最后给出 DROP TABLE
+func SchemaDiff(base, target []SchemaTable) ([]string, error) {
+	baseTables := parseCreateTables(base)
+	targetTables := parseCreateTables(target)
+
+	baseMap := make(map[string]*tidb.CreateTableStmt)
+	for _, ct := range baseTables {
+		baseMap[ct.Table.Name.L] = ct
+	}
+	targetMap := make(map[string]*tidb.CreateTableStmt)
+	for _, ct := range targetTables {
+		targetMap[ct.Table.Name.L] = ct
+	}
+
+	var creates, alters, drops []string
+	for _, ct := range targetTables {
+		old, ok := baseMap[ct.Table.Name.L]
+		if !ok {
+			sql, err := restoreNode(ct)
+			if err != nil {
+				return nil, err
+			}
+			creates = append(creates, sql+";")
+			continue
+		}
+		sql, err := alterTableDiff(old, ct)
+		if err != nil {
+			return nil, err
+		}
+		if sql != "" {
+			alters = append(alters, sql)
+		}
+	}
+	for _, ct := range baseTables {
+		if _, ok := targetMap[ct.Table.Name.L]; !ok {
+			drops = append(drops, fmt.Sprintf("DROP TABLE `%s`;", ct.Table.Name.O))
+		}
+	}
+	return append(append(creates, alters...), drops...), nil
+}
+
+// parseCreateTables 解析建表语句，忽略库名
+func parseCreateTables(tables []SchemaTable) []*tidb.CreateTableStmt {
+	var result []*tidb.CreateTableStmt
+	for _, tb := range tables {
+		stmts, err := ast.TiParse(tb.Query, "", "")
+		if err != nil {
+			common.Log.Warning("parseCreateTables TiParse Error: %v, Table: %s", err, tb.Table)
+			continue
+		}
+		for _, stmt := range stmts {
+			if ct, ok := stmt.(*tidb.CreateTableStmt); ok {
+				result = append(result, ct)
+			}
+		}
+	}
+	return result
+}
+
+// alterTableDiff 生成单表的 ALTER TABLE 语句，两张表一致时返回空字符串
+// 子句顺序：删除外键、删除索引、删除列、修改列、添加列、添加索引、添加外键、修改表属性
+func alterTableDiff(old, cur *tidb.CreateTableStmt) (string, error) {
+	var dropFKs, dropKeys, dropCols, modifyCols, addCols, addKeys, addFKs []*tidb.AlterTableSpec
+
+	// 列
+	oldCols := make(map[string]*tidb.ColumnDef)
+	for _, col := range old.Cols {
+		oldCols[col.Name.Name.L] = col
+	}
+	newCols := make(map[string]bool)
+	for i, col := range cur.Cols {
+		newCols[col.Name.Name.L] = true
+		def := diffColumnDef(col)
+		oc, ok := oldCols[col.Name.Name.L]
+		if !ok {
+			pos := &tidb.ColumnPosition{Tp: tidb.ColumnPositionFirst}
+			if i > 0 {
+				pos = &tidb.ColumnPosition{Tp: tidb.ColumnPositionAfter, RelativeColumn: cur.Cols[i-1].Name}
+			}
+			addCols = append(addCols, &tidb.AlterTableSpec{
+				Tp:         tidb.AlterTableAddColumns,
+				NewColumns: []*tidb.ColumnDef{def},
+				Position:   pos,
+			})
+			continue
+		}
+		oldDef, err := restoreNode(diffColumnDef(oc))
+		if err != nil {
+			return "", err
+		}
+		newDef, err := restoreNode(def)
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(oldDef, newDef) {
+			modifyCols = append(modifyCols, &tidb.AlterTableSpec{
+				Tp:         tidb.AlterTableModifyColumn,
+				NewColumns: []*tidb.ColumnDef{def},
+				Position:   &tidb.ColumnPosition{Tp: tidb.ColumnPositionNone},
+			})
+		}
+	}
+	for _, col := range old.Cols {
+		if !newCols[col.Name.Name.L] {
+			dropCols = append(dropCols, &tidb.AlterTableSpec{
+				Tp:            tidb.AlterTableDropColumn,
+				OldColumnName: col.Name,
+			})
+		}
+	}
+
+	// 索引及外键
+	oldKeys, oldOrder := diffConstraints(old)
+	newKeys, newOrder := diffConstraints(cur)
+	for _, name := range oldOrder {
+		c := oldKeys[name]
+		if nc, ok := newKeys[name]; ok {
+			same, err := sameConstraint(c, nc)
+			if err != nil {
+				return "", err
+			}
+			if same {
+				continue
+			}
+		}
+		switch c.Tp {
+		case tidb.ConstraintForeignKey:
+			dropFKs = append(dropFKs, &tidb.AlterTableSpec{Tp: tidb.AlterTableDropForeignKey, Name: c.Name})
+		case tidb.ConstraintPrimaryKey:
+			dropKeys = append(dropKeys, &tidb.AlterTableSpec{Tp: tidb.AlterTableDropPrimaryKey})
+		default:
+			dropKeys = append(dropKeys, &tidb.AlterTableSpec{Tp: tidb.AlterTableDropIndex, Name: c.Name})
+		}
+	}
+	for _, name := range newOrder {
+		c := newKeys[name]
+		if oc, ok := oldKeys[name]; ok {
+			same, err := sameConstraint(oc, c)
+			if err != nil {
+				return "", err
+			}
+			if same {
+				continue
+			}
+		}
+		spec := &tidb.AlterTableSpec{Tp: tidb.AlterTableAddConstraint, Constraint: c}
+		if c.Tp == tidb.ConstraintForeignKey {
+			addFKs = append(addFKs, spec)
+		} else {
+			addKeys = append(addKeys, spec)
+		}
+	}
+
+	var specs []*tidb.AlterTableSpec
+	for _, s := range [][]*tidb.AlterTableSpec{dropFKs, dropKeys, dropCols, modifyCols, addCols, addKeys, addFKs} {
+		specs = append(specs, s...)
+	}
+
+	// 表属性，只比较 target 中指定的属性，忽略 AUTO_INCREMENT 等随数据变化的属性
+	options, err := diffTableOptions(old, cur)
+	if err != nil {
+		return "", err
+	}
+	if len(options) > 0 {
+		specs = append(specs, &tidb.AlterTableSpec{Tp: tidb.AlterTableOption, Options: options})
+	}
+
+	if len(specs) == 0 {
+		return "", nil
+	}
+	alter := &tidb.AlterTableStmt{
+		Table: &tidb.TableName{Name: cur.Table.Name},
+		Specs: specs,
+	}
+	sql, err := restoreNode(alter)
+	if err != nil {
+		return "", err
+	}
+	return sql + ";", nil
+}
+
+// diffColumnDef 复制列定义，去除列上的 PRIMARY KEY, UNIQUE 属性（作为索引比较），以及 NULL, DEFAULT NULL 这类默认属性
+func diffColumnDef(col *tidb.ColumnDef) *tidb.ColumnDef {
+	c := *col
+	c.Options = nil
+	for _, opt := range col.Options {
+		switch opt.Tp {
+		case tidb.ColumnOptionPrimaryKey, tidb.ColumnOptionUniqKey, tidb.ColumnOptionNull:
+			continue
+		case tidb.ColumnOptionDefaultValue:
+			if expr, err := restoreNode(opt.Expr); err == nil && strings.EqualFold(expr, "NULL") {
+				continue
+			}
+		}
+		c.Options = append(c.Options, opt)
+	}
+	return &c
+}
+
+// diffConstraints 获取表中所有的索引及外键，列上定义的 PRIMARY KEY, UNIQUE 会转换为索引
+// 未命名的索引按 MySQL 的规则使用第一列的列名作为索引名，主键的名称为 PRIMARY
+func diffConstraints(ct *tidb.CreateTableStmt) (map[string]*tidb.Constraint, []string) {
+	keys := make(map[string]*tidb.Constraint)
+	var order []string
+	add := func(c *tidb.Constraint) {
+		name := strings.ToLower(c.Name)
+		if c.Tp == tidb.ConstraintPrimaryKey {
+			name = "primary"
+		} else if name == "" && len(c.Keys) > 0 && c.Keys[0].Column != nil {
+			cp := *c
+			cp.Name = c.Keys[0].Column.Name.O
+			c = &cp
+			name = c.Keys[0].Column.Name.L
+		}
+		if _, ok := keys[name]; ok || name == "" {
+			return
+		}
+		keys[name] = c
+		order = append(order, name)
+	}
+
+	for _, col := range ct.Cols {
+		for _, opt := range col.Options {
+			switch opt.Tp {
+			case tidb.ColumnOptionPrimaryKey:
+				add(&tidb.Constraint{Tp: tidb.ConstraintPrimaryKey, Keys: []*tidb.IndexColName{{Column: col.Name, Length: -1}}})
+			case tidb.ColumnOptionUniqKey:
+				add(&tidb.Constraint{Tp: tidb.ConstraintUniqKey, Name: col.Name.Name.O, Keys: []*tidb.IndexColName{{Column: col.Name, Length: -1}}})
+			}
+		}
+	}
+	for _, c := range ct.Constraints {
+		if c.Tp == tidb.ConstraintCheck {
+			continue
+		}
+		add(c)
+	}
+	return keys, order
+}
+
+// sameConstraint 比较两个索引定义是否相同，KEY 与 INDEX, UNIQUE 与 UNIQUE KEY 视为相同
+func sameConstraint(a, b *tidb.Constraint) (bool, error) {
+	normalize := func(c *tidb.Constraint) *tidb.Constraint {
+		cp := *c
+		switch cp.Tp {
+		case tidb.ConstraintIndex:
+			cp.Tp = tidb.ConstraintKey
+		case tidb.ConstraintUniq, tidb.ConstraintUniqIndex:
+			cp.Tp = tidb.ConstraintUniqKey
+		}
+		return &cp
+	}
+	sa, err := restoreNode(normalize(a))
+	if err != nil {
+		return false, err
+	}
+	sb, err := restoreNode(normalize(b))
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(sa, sb), nil
+}
+
+// diffTableOptions 返回 target 中与 base 不同的表属性
+func diffTableOptions(old, cur *tidb.CreateTableStmt) ([]*tidb.TableOption, error) {
+	compared := map[tidb.TableOptionType]bool{
+		tidb.TableOptionEngine:       true,
+		tidb.TableOptionCharset:      true,
+		tidb.TableOptionCollate:      true,
+		tidb.TableOptionComment:      true,
+		tidb.TableOptionRowFormat:    true,
+		tidb.TableOptionKeyBlockSize: true,
+		tidb.TableOptionCompression:  true,
+	}
+	oldOptions := make(map[tidb.TableOptionType]string)
+	for _, opt := range old.Options {
+		s, err := restoreNode(opt)
+		if err != nil {
+			return nil, err
+		}
+		oldOptions[opt.Tp] = s
+	}
+	var options []*tidb.TableOption
+	for _, opt := range cur.Options {
+		if !compared[opt.Tp] {
+			continue
+		}
+		s, err := restoreNode(opt)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(oldOptions[opt.Tp], s) {
+			options = append(options, opt)
+		}
+	}
+	return options, nil
+}
+
+// restoreNode 将 TiDB AST 节点还原为 SQL，TableOption 等结构不是 Node，这里只要求实现 Restore
+func restoreNode(node interface {
+	Restore(ctx *format.RestoreCtx) error
+}) (string, error) {
+	var sb strings.Builder
+	ctx := format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)
+	if err := node.Restore(ctx); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// FormatSchemaDiff 输出变更语句，并对每条变更语句给出启发式建议
+func FormatSchemaDiff(sqls []string) string {
+	if len(sqls) == 0 {
+		return "两个 Schema 一致，无需变更"
+	}
+	buf := []string{"# Schema 变更语句\n", fmt.Sprintf("```sql\n%s\n```\n", strings.Join(sqls, "\n"))}
+	for _, sql := range sqls {
+		suggest := schemaTableSuggest(sql)
+		buf = append(buf, fmt.Sprintf("# Query: %s\n", query.Id(Fingerprint(sql))))
+		buf = append(buf, fmt.Sprintf("```sql\n%s\n```\n", sql))
+		buf = append(buf, common.Score(SuggestScore(suggest))+"\n")
+		buf = append(buf, formatHeuristicSuggest(suggest)...)
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestSchemaDiff(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	base := LoadSchema(nil, "CREATE TABLE `t1` (\n"+
+		"  `id` int(11) NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(32) DEFAULT NULL,\n"+
+		"  `old_col` int(11) DEFAULT NULL,\n"+
+		"  `uid` int(11) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_name` (`name`)\n"+
+		") ENGINE=InnoDB AUTO_INCREMENT=100 DEFAULT CHARSET=utf8;\n"+
+		"CREATE TABLE `t2` (`id` int NOT NULL PRIMARY KEY);\n"+
+		"CREATE TABLE `t4` (`id` int NOT NULL PRIMARY KEY, `a` int, UNIQUE KEY (`a`)) ENGINE=InnoDB;")
+	target := LoadSchema(nil, "CREATE TABLE `t1` (\n"+
+		"  `id` int(11) NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(64),\n"+
+		"  `uid` int(11) NOT NULL,\n"+
+		"  `age` int(11) NOT NULL DEFAULT 0,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_name_age` (`name`, `age`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"+
+		"CREATE TABLE `t3` (`id` int NOT NULL PRIMARY KEY);\n"+
+		"CREATE TABLE `t4` (`id` int NOT NULL, `a` int, PRIMARY KEY (`id`), UNIQUE INDEX `a` (`a`)) ENGINE=InnoDB;")
+
+	sqls, err := SchemaDiff(base, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"CREATE TABLE `t3` (`id` INT NOT NULL PRIMARY KEY);",
+		"ALTER TABLE `t1` DROP INDEX `idx_name`, DROP COLUMN `old_col`, MODIFY COLUMN `name` VARCHAR(64), ADD COLUMN `age` INT(11) NOT NULL DEFAULT 0 AFTER `uid`, ADD INDEX `idx_name_age`(`name`, `age`), DEFAULT CHARACTER SET = UTF8MB4;",
+		"DROP TABLE `t2`;",
+	}
+	if len(sqls) != len(expect) {
+		t.Fatalf("want %d statements, got %d:\n%s", len(expect), len(sqls), strings.Join(sqls, "\n"))
+	}
+	for i := range expect {
+		if sqls[i] != expect[i] {
+			t.Errorf("want: %s\ngot: %s", expect[i], sqls[i])
+		}
+	}
+
+	report := FormatSchemaDiff(sqls)
+	if !strings.Contains(report, "# Schema 变更语句") || strings.Count(report, "# Query: ") != len(expect) {
+		t.Errorf("report not match:\n%s", report)
+	}
+
+	sqls, err = SchemaDiff(target, target)
+	if err != nil || FormatSchemaDiff(sqls) != "两个 Schema 一致，无需变更" {
+		t.Errorf("same schema should have no diff, got: %v, %v", sqls, err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// LogicalTables 将 ast.SchemaMetaInfo 返回的 `db`.`table` 中的物理分表替换为逻辑表名并去重，
+// 使报告中同一分表的多张物理表作为一张逻辑表统计
+func LogicalTables(tables []string) []string {
+	if len(common.ShardTables()) == 0 {
+		return tables
+	}
+	var logical []string
+	for _, tb := range tables {
+		i := strings.LastIndex(tb, ".")
+		name := strings.Trim(tb[i+1:], "`")
+		if l := common.LogicalTableName(name); l != name {
+			tb = tb[:i+1] + "`" + l + "`"
+		}
+		logical = append(logical, tb)
+	}
+	return removeDuplicates(logical)
+}
+
+// removeDuplicates 去重并保持原有顺序
+func removeDuplicates(items []string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// RuleCrossShard SHD.001
+// 查询分表的逻辑表时 WHERE 条件中没有分片键，中间件需要将查询发送到所有分表
+func (q *Query4Audit) RuleCrossShard() Rule {
+	var rule = q.RuleOK()
+	if len(common.ShardTables()) == 0 {
+		return rule
+	}
+
+	var where *sqlparser.Where
+	var tableExprs sqlparser.TableExprs
+	switch s := q.Stmt.(type) {
+	case *sqlparser.Select:
+		where, tableExprs = s.Where, s.From
+	case *sqlparser.Update:
+		where, tableExprs = s.Where, s.TableExprs
+	case *sqlparser.Delete:
+		where, tableExprs = s.Where, s.TableExprs
+	default:
+		return rule
+	}
+
+	// 查询中使用的逻辑表及其分片键，key 为表名或别名
+	keys := make(map[string]string)
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.AliasedTableExpr:
+			if tb, ok := n.Expr.(sqlparser.TableName); ok {
+				shard, isLogical := common.FindShardTable(tb.Name.String())
+				if shard != nil && isLogical && shard.Key != "" {
+					alias := tb.Name.String()
+					if !n.As.IsEmpty() {
+						alias = n.As.String()
+					}
+					keys[strings.ToLower(alias)] = shard.Key
+				}
+			}
+		}
+		return true, nil
+	}, tableExprs)
+	common.LogIfError(err, "")
+	if len(keys) == 0 {
+		return rule
+	}
+
+	// WHERE 条件中出现分片键的等值、IN、范围查询时可以路由到部分分表
+	if where != nil {
+		for alias, key := range keys {
+			if restrictShardKey(where.Expr, alias, key, true) {
+				delete(keys, alias)
+			}
+		}
+	}
+
+	if len(keys) > 0 {
+		rule = HeuristicRules["SHD.001"]
+	}
+	return rule
+}
+
+// restrictShardKey 条件是否限定了分片键的取值，OR 的两侧都需要限定
+// rangeOK 为 false 时只认可等值及 IN 条件，哈希分片时范围查询仍然需要发送到所有分片
+func restrictShardKey(expr sqlparser.Expr, alias, key string, rangeOK bool) bool {
+	isKey := func(e sqlparser.Expr) bool {
+		col, ok := e.(*sqlparser.ColName)
+		return ok && strings.EqualFold(col.Name.String(), key) &&
+			(col.Qualifier.IsEmpty() || strings.EqualFold(col.Qualifier.Name.String(), alias))
+	}
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		return restrictShardKey(e.Left, alias, key, rangeOK) || restrictShardKey(e.Right, alias, key, rangeOK)
+	case *sqlparser.OrExpr:
+		return restrictShardKey(e.Left, alias, key, rangeOK) && restrictShardKey(e.Right, alias, key, rangeOK)
+	case *sqlparser.ParenExpr:
+		return restrictShardKey(e.Expr, alias, key, rangeOK)
+	case *sqlparser.ComparisonExpr:
+		switch e.Operator {
+		case sqlparser.EqualStr, sqlparser.InStr, sqlparser.NullSafeEqualStr:
+			return isKey(e.Left)
+		case sqlparser.LessThanStr, sqlparser.LessEqualStr, sqlparser.GreaterThanStr, sqlparser.GreaterEqualStr:
+			return rangeOK && isKey(e.Left)
+		}
+	case *sqlparser.RangeCond:
+		return rangeOK && e.Operator == sqlparser.BetweenStr && isKey(e.Left)
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// SHD.001
+func TestRuleCrossShard(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgShardTables := common.Config.ShardTables
+	common.Config.ShardTables = []string{"user=user_%d:uid", "log_%d:created_at"}
+	sqls := [][]string{
+		{
+			"select * from user where name = 'soar'",
+			"select * from user u join log l on u.uid = l.uid where u.uid = 1",
+			"update user set name = 'soar' where uid > 10 or name = 'a'",
+			"delete from user",
+		},
+		{
+			"select * from user where uid = 1",
+			"select * from user where uid in (1, 2)",
+			"select * from user_01 where name = 'soar'",
+			"select * from user u join log l on u.uid = l.uid where u.uid = 1 and l.created_at between '2024-01-01' and '2024-02-01'",
+			"select * from film",
+		},
+	}
+	for _, sql := range sqls[0] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleCrossShard()
+			if rule.Item != "SHD.001" {
+				t.Error("Rule not match:", rule.Item, "Expect : SHD.001, SQL:", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	for _, sql := range sqls[1] {
+		q, err := NewQuery4Audit(sql)
+		if err == nil {
+			rule := q.RuleCrossShard()
+			if rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK, SQL:", sql)
+			}
+		} else {
+			t.Error("sqlparser.Parse Error:", err)
+		}
+	}
+	common.Config.ShardTables = orgShardTables
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestLogicalTables(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgShardTables := common.Config.ShardTables
+	common.Config.ShardTables = []string{"user=user_%d:uid"}
+	tables := LogicalTables([]string{"`db`.`user_01`", "`db`.`user_02`", "`db`.`film`"})
+	if len(tables) != 2 || tables[0] != "`db`.`user`" || tables[1] != "`db`.`film`" {
+		t.Errorf("LogicalTables got %v", tables)
+	}
+	if fp := Fingerprint("select * from user_01 where uid = 1"); fp != "select * from user where uid = ?" {
+		t.Errorf("Fingerprint got %s", fp)
+	}
+	common.Config.ShardTables = orgShardTables
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,340 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// shardAdvisorMaxScatter 每张表最多列出的跨分片查询数
+const shardAdvisorMaxScatter = 10
+
+// ShardAdvisor 根据批量 SQL 中的查询条件为每张表推荐分片键，对应 -report-type shard-advisor
+// 输入中包含建表语句时只推荐表中存在的列，并标记主键
+type ShardAdvisor struct {
+	Tables  map[string]*ShardAdvisorTable
+	samples map[string]string // Query ID -> SQL
+}
+
+// ShardAdvisorTable 单张表的查询条件统计
+type ShardAdvisorTable struct {
+	Name     string
+	Queries  int                 // 访问该表的 SELECT, UPDATE, DELETE 条数
+	Keys     map[string][]string // 列 -> 能够通过该列路由的 Query ID，重复出现的 SQL 重复记录
+	Joins    map[string]int      // 列 -> 作为 JOIN 等值条件出现的次数，作为分片键时关联查询可以在同一分片内完成
+	QueryIDs []string            // 访问该表的 Query ID，重复出现的 SQL 重复记录
+	Columns  []string            // 建表语句中的列，为空表示没有表结构
+	PK       map[string]bool
+}
+
+// NewShardAdvisor 初始化 ShardAdvisor
+func NewShardAdvisor() *ShardAdvisor {
+	return &ShardAdvisor{
+		Tables:  make(map[string]*ShardAdvisorTable),
+		samples: make(map[string]string),
+	}
+}
+
+func (sa *ShardAdvisor) table(name string) *ShardAdvisorTable {
+	key := strings.ToLower(common.LogicalTableName(name))
+	t, ok := sa.Tables[key]
+	if !ok {
+		t = &ShardAdvisorTable{
+			Name:  common.LogicalTableName(name),
+			Keys:  make(map[string][]string),
+			Joins: make(map[string]int),
+			PK:    make(map[string]bool),
+		}
+		sa.Tables[key] = t
+	}
+	return t
+}
+
+// Add 加入一条 SQL，建表语句用于获取表结构，SELECT, UPDATE, DELETE 用于统计查询条件
+func (sa *ShardAdvisor) Add(sql string) {
+	if stmts, err := ast.TiParse(sql, "", ""); err == nil {
+		for _, stmt := range stmts {
+			if ct, ok := stmt.(*tidb.CreateTableStmt); ok {
+				sa.addSchema(ct)
+				return
+			}
+		}
+	}
+
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return
+	}
+	var where *sqlparser.Where
+	var tableExprs sqlparser.TableExprs
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		where, tableExprs = s.Where, s.From
+	case *sqlparser.Update:
+		where, tableExprs = s.Where, s.TableExprs
+	case *sqlparser.Delete:
+		where, tableExprs = s.Where, s.TableExprs
+	default:
+		return
+	}
+
+	id := query.Id(Fingerprint(sql))
+	sa.samples[id] = sql
+
+	// 别名 -> 表名，不进入子查询
+	aliases := make(map[string]string)
+	var joinConds []sqlparser.Expr
+	err = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.JoinTableExpr:
+			if n.Condition.On != nil {
+				joinConds = append(joinConds, n.Condition.On)
+			}
+		case *sqlparser.AliasedTableExpr:
+			if tb, ok := n.Expr.(sqlparser.TableName); ok {
+				alias := tb.Name.String()
+				if !n.As.IsEmpty() {
+					alias = n.As.String()
+				}
+				aliases[strings.ToLower(alias)] = tb.Name.String()
+			}
+		}
+		return true, nil
+	}, tableExprs)
+	common.LogIfError(err, "")
+	if where != nil {
+		joinConds = append(joinConds, where.Expr)
+	}
+
+	// 列所属的表，多表查询中未指定表名的列无法判断所属的表
+	tableOf := func(col *sqlparser.ColName) string {
+		if col.Qualifier.IsEmpty() {
+			if len(aliases) == 1 {
+				for alias := range aliases {
+					return alias
+				}
+			}
+			return ""
+		}
+		alias := strings.ToLower(col.Qualifier.Name.String())
+		if _, ok := aliases[alias]; ok {
+			return alias
+		}
+		return ""
+	}
+
+	// 等值 JOIN 条件中的列
+	joins := make(map[string]map[string]bool)
+	for _, cond := range joinConds {
+		err = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			switch n := node.(type) {
+			case *sqlparser.Subquery:
+				return false, nil
+			case *sqlparser.ComparisonExpr:
+				left, lok := n.Left.(*sqlparser.ColName)
+				right, rok := n.Right.(*sqlparser.ColName)
+				if n.Operator != sqlparser.EqualStr || !lok || !rok {
+					return true, nil
+				}
+				for _, col := range []*sqlparser.ColName{left, right} {
+					if alias := tableOf(col); alias != "" {
+						if joins[alias] == nil {
+							joins[alias] = make(map[string]bool)
+						}
+						joins[alias][col.Name.Lowered()] = true
+					}
+				}
+			}
+			return true, nil
+		}, cond)
+		common.LogIfError(err, "")
+	}
+
+	// WHERE 中引用的列
+	candidates := make(map[string]map[string]bool)
+	if where != nil {
+		err = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			switch n := node.(type) {
+			case *sqlparser.Subquery:
+				return false, nil
+			case *sqlparser.ColName:
+				if alias := tableOf(n); alias != "" {
+					if candidates[alias] == nil {
+						candidates[alias] = make(map[string]bool)
+					}
+					candidates[alias][n.Name.Lowered()] = true
+				}
+			}
+			return true, nil
+		}, where.Expr)
+		common.LogIfError(err, "")
+	}
+
+	for alias, name := range aliases {
+		t := sa.table(name)
+		t.Queries++
+		t.QueryIDs = append(t.QueryIDs, id)
+		for col := range candidates[alias] {
+			if restrictShardKey(where.Expr, alias, col, false) {
+				t.Keys[col] = append(t.Keys[col], id)
+			}
+		}
+		for col := range joins[alias] {
+			t.Joins[col]++
+		}
+	}
+}
+
+func (sa *ShardAdvisor) addSchema(ct *tidb.CreateTableStmt) {
+	t := sa.table(ct.Table.Name.O)
+	t.Columns = nil
+	for _, col := range ct.Cols {
+		t.Columns = append(t.Columns, col.Name.Name.L)
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey {
+				t.PK[col.Name.Name.L] = true
+			}
+		}
+	}
+	for _, c := range ct.Constraints {
+		if c.Tp == tidb.ConstraintPrimaryKey {
+			for _, key := range c.Keys {
+				if key.Column != nil {
+					t.PK[key.Column.Name.L] = true
+				}
+			}
+		}
+	}
+}
+
+// hasColumn 列是否在表中，没有表结构时认为存在
+func (t *ShardAdvisorTable) hasColumn(col string) bool {
+	if len(t.Columns) == 0 {
+		return true
+	}
+	for _, c := range t.Columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates 按能够路由的查询数、JOIN 次数排序的候选分片键，有表结构时去掉表中不存在的列
+func (t *ShardAdvisorTable) candidates() []string {
+	var cols []string
+	for col := range t.Keys {
+		if !t.hasColumn(col) {
+			continue
+		}
+		cols = append(cols, col)
+	}
+	for col := range t.Joins {
+		if _, ok := t.Keys[col]; ok || !t.hasColumn(col) {
+			continue
+		}
+		cols = append(cols, col)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		ki, kj := len(t.Keys[cols[i]]), len(t.Keys[cols[j]])
+		if ki != kj {
+			return ki > kj
+		}
+		if t.Joins[cols[i]] != t.Joins[cols[j]] {
+			return t.Joins[cols[i]] > t.Joins[cols[j]]
+		}
+		return cols[i] < cols[j]
+	})
+	return cols
+}
+
+// scatter 以 key 作为分片键时需要发送到所有分片的 Query ID
+func (t *ShardAdvisorTable) scatter(key string) []string {
+	routed := make(map[string]bool)
+	for _, id := range t.Keys[key] {
+		routed[id] = true
+	}
+	var ids []string
+	for _, id := range t.QueryIDs {
+		if !routed[id] {
+			ids = append(ids, id)
+		}
+	}
+	return removeDuplicates(ids)
+}
+
+// Format 以 markdown 格式输出每张表的候选分片键及跨分片查询
+func (sa *ShardAdvisor) Format() string {
+	var tables []*ShardAdvisorTable
+	for _, t := range sa.Tables {
+		if t.Queries > 0 {
+			tables = append(tables, t)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].Queries != tables[j].Queries {
+			return tables[i].Queries > tables[j].Queries
+		}
+		return tables[i].Name < tables[j].Name
+	})
+
+	buf := []string{"# 分片键建议"}
+	if len(tables) == 0 {
+		buf = append(buf, "", "输入中没有可以分析的 SELECT, UPDATE, DELETE 语句。")
+	}
+	for _, t := range tables {
+		buf = append(buf, "", fmt.Sprintf("## %s", common.MarkdownEscape(t.Name)), "")
+		cols := t.candidates()
+		if len(cols) == 0 {
+			buf = append(buf, fmt.Sprintf("%d 条 SQL 的 WHERE 条件中都没有可以用于路由的列，无法推荐分片键。", t.Queries))
+			continue
+		}
+		buf = append(buf, "| 候选分片键 | 可路由 SQL | 占比 | JOIN 次数 | 主键 |", "|---|---|---|---|---|")
+		for _, col := range cols {
+			pk := ""
+			if t.PK[col] {
+				pk = "是"
+			}
+			buf = append(buf, fmt.Sprintf("| %s | %d/%d | %.0f%% | %d | %s |", common.MarkdownEscape(col),
+				len(t.Keys[col]), t.Queries, float64(len(t.Keys[col]))*100/float64(t.Queries), t.Joins[col], pk))
+		}
+
+		key := cols[0]
+		scatter := t.scatter(key)
+		buf = append(buf, "", fmt.Sprintf("* **建议分片键:** %s", common.MarkdownEscape(key)),
+			fmt.Sprintf("* **跨分片查询:** %d 条", len(scatter)))
+		for i, id := range scatter {
+			if i >= shardAdvisorMaxScatter {
+				buf = append(buf, fmt.Sprintf("* 其余 %d 条未列出", len(scatter)-shardAdvisorMaxScatter))
+				break
+			}
+			buf = append(buf, "", fmt.Sprintf("Query: %s", id), "", "```sql", sa.samples[id], "```")
+		}
+	}
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestShardAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sa := NewShardAdvisor()
+	for _, sql := range []string{
+		"CREATE TABLE orders (id bigint PRIMARY KEY, uid bigint, status int, created_at datetime)",
+		"select * from orders where uid = 1",
+		"select * from orders where uid in (1, 2) and status = 1",
+		"select * from orders where id = 10",
+		"select * from orders o join users u on o.uid = u.id where u.name = 'soar'",
+		"update orders set status = 2 where uid = 3",
+		"select * from orders where nocol = 1",
+		"insert into orders (id, uid) values (1, 1)",
+	} {
+		sa.Add(sql)
+	}
+	orders := sa.Tables["orders"]
+	if orders == nil || orders.Queries != 6 {
+		t.Fatalf("orders should be queried 6 times, got %+v", orders)
+	}
+	cols := orders.candidates()
+	if strings.Join(cols, ",") != "uid,id,status" {
+		t.Errorf("candidates got %v", cols)
+	}
+	if scatter := orders.scatter("uid"); len(scatter) != 3 {
+		t.Errorf("scatter queries got %v", scatter)
+	}
+	report := sa.Format()
+	if !strings.Contains(report, "| uid | 3/6 | 50% | 1 |  |") || !strings.Contains(report, "| id | 1/6 | 17% | 0 | 是 |") ||
+		!strings.Contains(report, "* **建议分片键:** uid") || !strings.Contains(report, "## users") {
+		t.Errorf("shard advisor report got:\n%s", report)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
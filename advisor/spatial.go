@@ -0,0 +1,388 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// spatialTypes 空间数据类型
+var spatialTypes = map[string]bool{
+	"geometry": true, "point": true, "linestring": true, "polygon": true, "multipoint": true,
+	"multilinestring": true, "multipolygon": true, "geometrycollection": true, "geomcollection": true,
+}
+
+// spatialColumnRe DDL 中的空间列定义，TiDB parser 不支持空间类型，vitess 不支持 SRID，只能按文本匹配
+// 第一个分组为列名，第二个分组为类型，第三个分组为到下一个逗号之前的列属性
+var spatialColumnRe = regexp.MustCompile("(?i)(?:[(,]|\\bcolumn|\\badd|\\bmodify)\\s*(`[^`]+`|\\w+)\\s+" +
+	"(geometry|point|linestring|polygon|multipoint|multilinestring|multipolygon|geometrycollection|geomcollection)\\b([^,]*)")
+
+// tableDDLRe 建表或修改表结构的语句
+var tableDDLRe = regexp.MustCompile(`(?i)^\s*(create|alter)\s+table\b`)
+
+// spatialRelationFuncs 可以使用 SPATIAL 索引的空间关系函数
+var spatialRelationFuncs = map[string]bool{
+	"st_contains": true, "st_within": true, "st_intersects": true, "st_overlaps": true, "st_touches": true,
+	"st_crosses": true, "st_equals": true, "mbrcontains": true, "mbrwithin": true, "mbrintersects": true,
+	"mbroverlaps": true, "mbrtouches": true, "mbrcovers": true, "mbrcoveredby": true, "mbrequals": true,
+}
+
+// offlineSpatial 离线表结构中的空间列是否有 SPATIAL 索引，map[table]map[column]bool，表名和列名均为小写
+// TiDB parser 无法解析含空间列的建表语句，这里使用 vitess 解析的结果
+var offlineSpatial = make(map[string]map[string]bool)
+
+// addOfflineSpatial 记录建表语句中的空间列及 SPATIAL 索引
+func addOfflineSpatial(stmt sqlparser.Statement) {
+	ddl, ok := stmt.(*sqlparser.DDL)
+	if !ok || ddl.Action != sqlparser.CreateStr || ddl.TableSpec == nil {
+		return
+	}
+	cols := make(map[string]bool)
+	for _, col := range ddl.TableSpec.Columns {
+		if spatialTypes[strings.ToLower(col.Type.Type)] {
+			cols[col.Name.Lowered()] = false
+		}
+	}
+	if len(cols) == 0 {
+		return
+	}
+	for _, idx := range ddl.TableSpec.Indexes {
+		if idx.Info == nil || !idx.Info.Spatial || len(idx.Columns) == 0 {
+			continue
+		}
+		name := idx.Columns[0].Column.Lowered()
+		if _, ok := cols[name]; ok {
+			cols[name] = true
+		}
+	}
+	offlineSpatial[strings.ToLower(ddl.Table.Name.String())] = cols
+}
+
+// geoAxis 根据列名判断是否为纬度（lat）或经度（lng）列，都不是时返回空
+func geoAxis(name string) string {
+	name = strings.ToLower(strings.Trim(name, "`"))
+	for axis, names := range map[string][]string{
+		"lat": {"latitude", "lat"},
+		"lng": {"longitude", "lng", "lon", "long"},
+	} {
+		for _, n := range names {
+			if name == n || strings.HasSuffix(name, "_"+n) {
+				return axis
+			}
+		}
+	}
+	return ""
+}
+
+// pointSchemaDDL 将经纬度列转换为 POINT 列并添加 SPATIAL 索引的语句
+// MySQL 8.0 中 SRID 4326 的 WKT 坐标顺序为纬度在前，5.7 中没有 SRID，POINT(x, y) 的 x 为经度
+func pointSchemaDDL(table, lat, lng string) string {
+	if common.TargetDB().Supports(80003, 0) {
+		return fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `location` POINT SRID 4326; "+
+			"UPDATE `%s` SET `location` = ST_PointFromText(CONCAT('POINT(', `%s`, ' ', `%s`, ')'), 4326); "+
+			"ALTER TABLE `%s` MODIFY `location` POINT NOT NULL SRID 4326, ADD SPATIAL INDEX `idx_location` (`location`);",
+			table, table, lat, lng, table)
+	}
+	return fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `location` POINT; "+
+		"UPDATE `%s` SET `location` = POINT(`%s`, `%s`); "+
+		"ALTER TABLE `%s` MODIFY `location` POINT NOT NULL, ADD SPATIAL INDEX `idx_location` (`location`);",
+		table, table, lng, lat, table)
+}
+
+// boxPolygon 经纬度范围对应的矩形，srid 为 true 时与 pointSchemaDDL 一致使用 SRID 4326，否则 x 为经度
+func boxPolygon(latLo, latHi, lngLo, lngHi string, srid bool) string {
+	if srid {
+		return fmt.Sprintf("ST_GeomFromText('POLYGON((%[1]s %[3]s, %[1]s %[4]s, %[2]s %[4]s, %[2]s %[3]s, %[1]s %[3]s))', 4326)",
+			latLo, latHi, lngLo, lngHi)
+	}
+	return fmt.Sprintf("ST_GeomFromText('POLYGON((%[3]s %[1]s, %[4]s %[1]s, %[4]s %[2]s, %[3]s %[2]s, %[3]s %[1]s))')",
+		latLo, latHi, lngLo, lngHi)
+}
+
+// RuleSpatialSRID GIS.001
+func (q *Query4Audit) RuleSpatialSRID() Rule {
+	var rule = q.RuleOK()
+	// SRID 列属性从 MySQL 8.0.3 开始支持
+	if !common.TargetDB().Supports(80003, 0) {
+		return rule
+	}
+	// 指定了 SRID 的语句 vitess 也无法解析，这里只判断语句类型
+	if !tableDDLRe.MatchString(q.Query) {
+		return rule
+	}
+	var cols []string
+	for _, m := range spatialColumnRe.FindAllStringSubmatch(q.Query, -1) {
+		if !strings.Contains(strings.ToUpper(m[3]), "SRID") {
+			cols = append(cols, strings.Trim(m[1], "`"))
+		}
+	}
+	if len(cols) > 0 {
+		rule = HeuristicRules["GIS.001"]
+		rule.Content += fmt.Sprintf(" 未指定 SRID 的列: %s。", strings.Join(cols, ", "))
+	}
+	return rule
+}
+
+// RuleSpatialIndex GIS.002
+// 需要离线表结构（-schema-file 或输入中的建表语句）才能判断空间列上是否有 SPATIAL 索引
+func (q *Query4Audit) RuleSpatialIndex() Rule {
+	var rule = q.RuleOK()
+	if len(offlineSpatial) == 0 {
+		return rule
+	}
+	var hints []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		f, ok := node.(*sqlparser.FuncExpr)
+		if !ok || !spatialRelationFuncs[f.Name.Lowered()] {
+			return true, nil
+		}
+		args, ok := funcArgs(f)
+		if !ok {
+			return true, nil
+		}
+		for _, arg := range args {
+			col, ok := arg.(*sqlparser.ColName)
+			if !ok {
+				continue
+			}
+			table := columnTable(q.Stmt, col)
+			if indexed, ok := offlineSpatial[strings.ToLower(table)][col.Name.Lowered()]; ok && !indexed {
+				hints = append(hints, fmt.Sprintf("ALTER TABLE `%s` ADD SPATIAL INDEX `idx_%s` (`%s`);",
+					table, col.Name.Lowered(), col.Name.String()))
+			}
+		}
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+	if len(hints) > 0 {
+		rule = HeuristicRules["GIS.002"]
+		rule.Content = strings.Join(append([]string{rule.Content}, common.RemoveDuplicatesItem(hints)...), " ")
+	}
+	return rule
+}
+
+// distanceBox 将 ST_Distance_Sphere(col, POINT(lng, lat)) < r 改写为先使用 MBRContains 按外接矩形过滤，无法改写时返回空
+// 矩形的坐标顺序与原条件中的 POINT(lng, lat) 保持一致
+func distanceBox(f *sqlparser.FuncExpr, op string, right sqlparser.Expr) string {
+	if f.Name.Lowered() != "st_distance_sphere" || (op != sqlparser.LessThanStr && op != sqlparser.LessEqualStr) {
+		return ""
+	}
+	args, ok := funcArgs(f)
+	r, rok := numberValue(right)
+	if !ok || !rok || len(args) < 2 || r <= 0 {
+		return ""
+	}
+	col, ok := args[0].(*sqlparser.ColName)
+	center := args[1]
+	if !ok {
+		if col, ok = args[1].(*sqlparser.ColName); !ok {
+			return ""
+		}
+		center = args[0]
+	}
+	p, ok := center.(*sqlparser.FuncExpr)
+	if !ok || p.Name.Lowered() != "point" {
+		return ""
+	}
+	pArgs, ok := funcArgs(p)
+	if !ok || len(pArgs) != 2 {
+		return ""
+	}
+	lng, lngOK := numberValue(pArgs[0])
+	lat, latOK := numberValue(pArgs[1])
+	if !lngOK || !latOK || math.Abs(lat) >= 90 {
+		return ""
+	}
+	// 纬度 1 度约 111.32 公里，经度 1 度的距离随纬度变小
+	dLat := r / 111320
+	dLng := r / (111320 * math.Cos(lat*math.Pi/180))
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	return fmt.Sprintf("MBRContains(%s, %s) AND %s %s %s", boxPolygon(format(lat-dLat), format(lat+dLat), format(lng-dLng), format(lng+dLng), false),
+		sqlparser.String(col), sqlparser.String(f), op, sqlparser.String(right))
+}
+
+// RuleSpatialDistance GIS.003
+func (q *Query4Audit) RuleSpatialDistance() Rule {
+	var rule = q.RuleOK()
+	var hints []string
+	found := false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		n, ok := node.(*sqlparser.ComparisonExpr)
+		if !ok {
+			return true, nil
+		}
+		f, ok := n.Left.(*sqlparser.FuncExpr)
+		if !ok || (f.Name.Lowered() != "st_distance" && f.Name.Lowered() != "st_distance_sphere") || wrappedColumn(f) == nil {
+			return true, nil
+		}
+		found = true
+		if rewrite := distanceBox(f, n.Operator, n.Right); rewrite != "" {
+			hints = append(hints, fmt.Sprintf("`%s` 可改写为 `%s`。", sqlparser.String(n), rewrite))
+		}
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+	if found {
+		rule = HeuristicRules["GIS.003"]
+		rule.Content = strings.Join(append([]string{rule.Content}, hints...), " ")
+	}
+	return rule
+}
+
+// RuleLatLngFloat GIS.004
+func (q *Query4Audit) RuleLatLngFloat() Rule {
+	var rule = q.RuleOK()
+	for _, node := range q.TiStmt {
+		var table string
+		var cols []*tidb.ColumnDef
+		switch n := node.(type) {
+		case *tidb.CreateTableStmt:
+			table = n.Table.Name.O
+			cols = n.Cols
+		case *tidb.AlterTableStmt:
+			table = n.Table.Name.O
+			for _, s := range n.Specs {
+				switch s.Tp {
+				case tidb.AlterTableAddColumns, tidb.AlterTableChangeColumn, tidb.AlterTableModifyColumn:
+					cols = append(cols, s.NewColumns...)
+				}
+			}
+		default:
+			continue
+		}
+
+		var lat, lng string
+		var floats []string
+		for _, c := range cols {
+			if c.Tp == nil {
+				continue
+			}
+			axis := geoAxis(c.Name.Name.O)
+			switch axis {
+			case "lat":
+				lat = c.Name.Name.O
+			case "lng":
+				lng = c.Name.Name.O
+			default:
+				continue
+			}
+			switch strings.ToLower(common.GetDataTypeBase(c.Tp.InfoSchemaStr())) {
+			case "float", "double":
+				floats = append(floats, c.Name.Name.O)
+			}
+		}
+		if len(floats) == 0 {
+			continue
+		}
+		rule = HeuristicRules["GIS.004"]
+		rule.Content += fmt.Sprintf(" 使用浮点数保存经纬度的列: %s。", strings.Join(floats, ", "))
+		if lat != "" && lng != "" {
+			rule.Content += " 按距离或范围查询时建议使用 POINT 类型及 SPATIAL 索引: " + pointSchemaDDL(table, lat, lng)
+		}
+	}
+	return rule
+}
+
+// latLngBounds 获取 WHERE 条件中经纬度列的上下界，只处理 BETWEEN 及 >, >=, <, <= 常量
+// 返回 map[axis][2]string，分别为下界和上界，以及对应的列
+func latLngBounds(stmt sqlparser.Statement) (map[string][2]string, map[string]*sqlparser.ColName) {
+	bounds := make(map[string][2]string)
+	cols := make(map[string]*sqlparser.ColName)
+	set := func(col *sqlparser.ColName, lo, hi sqlparser.Expr) {
+		axis := geoAxis(col.Name.String())
+		if axis == "" {
+			return
+		}
+		b := bounds[axis]
+		if _, ok := numberValue(lo); ok {
+			b[0] = sqlparser.String(lo)
+		}
+		if _, ok := numberValue(hi); ok {
+			b[1] = sqlparser.String(hi)
+		}
+		bounds[axis] = b
+		cols[axis] = col
+	}
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery, *sqlparser.OrExpr:
+			return false, nil
+		case *sqlparser.RangeCond:
+			if col, ok := n.Left.(*sqlparser.ColName); ok && n.Operator == sqlparser.BetweenStr {
+				set(col, n.From, n.To)
+			}
+		case *sqlparser.ComparisonExpr:
+			col, ok := n.Left.(*sqlparser.ColName)
+			if !ok {
+				return true, nil
+			}
+			switch n.Operator {
+			case sqlparser.GreaterThanStr, sqlparser.GreaterEqualStr:
+				set(col, n.Right, nil)
+			case sqlparser.LessThanStr, sqlparser.LessEqualStr:
+				set(col, nil, n.Right)
+			}
+		}
+		return true, nil
+	}, stmt)
+	common.LogIfError(err, "")
+	return bounds, cols
+}
+
+// RuleLatLngRange GIS.005
+func (q *Query4Audit) RuleLatLngRange() Rule {
+	var rule = q.RuleOK()
+	var where *sqlparser.Where
+	switch n := q.Stmt.(type) {
+	case *sqlparser.Select:
+		where = n.Where
+	case *sqlparser.Update:
+		where = n.Where
+	case *sqlparser.Delete:
+		where = n.Where
+	}
+	if where == nil {
+		return rule
+	}
+	bounds, cols := latLngBounds(q.Stmt)
+	lat, lng := bounds["lat"], bounds["lng"]
+	if lat[0] == "" || lat[1] == "" || lng[0] == "" || lng[1] == "" {
+		return rule
+	}
+	table := columnTable(q.Stmt, cols["lat"])
+	if table == "" {
+		table = "tbl"
+	}
+	location := "location"
+	if !cols["lat"].Qualifier.Name.IsEmpty() {
+		location = cols["lat"].Qualifier.Name.String() + ".location"
+	}
+	rule = HeuristicRules["GIS.005"]
+	rule.Content += fmt.Sprintf(" 添加 POINT 列及 SPATIAL 索引: %s 查询条件改写为 `MBRContains(%s, %s)`。",
+		pointSchemaDDL(table, cols["lat"].Name.String(), cols["lng"].Name.String()),
+		boxPolygon(lat[0], lat[1], lng[0], lng[1], common.TargetDB().Supports(80003, 0)), location)
+	return rule
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// GIS.001
+func TestRuleSpatialSRID(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	common.Config.Target = "mysql:8.0.30"
+	sqls := map[string]string{
+		"CREATE TABLE t (id INT PRIMARY KEY, g POINT NOT NULL, SPATIAL INDEX (g))":                      "g",
+		"CREATE TABLE t (id INT, `shape` GEOMETRY NOT NULL SRID 4326, area POLYGON NOT NULL)":           "area",
+		"ALTER TABLE t ADD COLUMN location POINT NOT NULL":                                              "location",
+		"CREATE TABLE t (id INT PRIMARY KEY, g POINT NOT NULL SRID 4326, SPATIAL INDEX (g))":            "",
+		"CREATE TABLE t (id INT PRIMARY KEY, point_name VARCHAR(32), polygon_id INT, KEY (polygon_id))": "",
+	}
+	for sql, want := range sqls {
+		// TiDB parser 不支持空间类型，忽略语法错误
+		q, _ := NewQuery4Audit(sql)
+		rule := q.RuleSpatialSRID()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Item)
+			}
+			continue
+		}
+		if rule.Item != "GIS.001" || !strings.HasSuffix(rule.Content, want+"。") {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+
+	// 5.7 不支持 SRID 列属性
+	common.Config.Target = "mysql:5.7"
+	q, _ := NewQuery4Audit("CREATE TABLE t (id INT PRIMARY KEY, g POINT NOT NULL)")
+	if rule := q.RuleSpatialSRID(); rule.Item != "OK" {
+		t.Errorf("got: %s", rule.Item)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// GIS.002
+func TestRuleSpatialIndex(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSpatial := offlineSpatial
+	defer func() { offlineSpatial = orgSpatial }()
+	offlineSpatial = make(map[string]map[string]bool)
+
+	for _, sql := range []string{
+		"CREATE TABLE shop (id INT PRIMARY KEY, location POINT NOT NULL, area POLYGON NOT NULL, SPATIAL INDEX idx_area (area))",
+	} {
+		stmt, err := sqlparser.Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addOfflineSpatial(stmt)
+	}
+
+	sqls := map[string]string{
+		"SELECT id FROM shop WHERE ST_Contains(ST_GeomFromText('POLYGON((0 0, 0 1, 1 1, 1 0, 0 0))'), location)": "ALTER TABLE `shop` ADD SPATIAL INDEX `idx_location` (`location`);",
+		"SELECT s.id FROM shop s WHERE MBRWithin(s.location, @box) AND ST_Intersects(s.area, @box)":              "ALTER TABLE `shop` ADD SPATIAL INDEX `idx_location` (`location`);",
+		"SELECT id FROM shop WHERE ST_Intersects(area, @box)":                                                    "",
+		"SELECT id FROM other WHERE ST_Contains(@box, location)":                                                 "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleSpatialIndex()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "GIS.002" || !strings.HasSuffix(rule.Content, want) || strings.Contains(rule.Content, "idx_area") {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// GIS.003
+func TestRuleSpatialDistance(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"SELECT id FROM t WHERE ST_Distance_Sphere(g, POINT(116.4, 39.9)) < 1000":      "`ST_Distance_Sphere(g, point(116.4, 39.9)) < 1000` 可改写为 `MBRContains(ST_GeomFromText('POLYGON((116.388291 39.891017, 116.411709 39.891017, 116.411709 39.908983, 116.388291 39.908983, 116.388291 39.891017))'), g) AND ST_Distance_Sphere(g, point(116.4, 39.9)) < 1000`。",
+		"SELECT id FROM t WHERE ST_Distance(g, ST_GeomFromText('POINT(1 1)')) <= 0.01": "GIS.003",
+		"SELECT ST_Distance_Sphere(g, @p) AS d FROM t ORDER BY d":                      "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleSpatialDistance()
+		switch want {
+		case "":
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+		case "GIS.003":
+			if rule.Item != want || strings.Contains(rule.Content, "可改写为") {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+		default:
+			if rule.Item != "GIS.003" || !strings.HasSuffix(rule.Content, want) {
+				t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// GIS.004
+func TestRuleLatLngFloat(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	common.Config.Target = "mysql:8.0.30"
+	sqls := map[string]string{
+		"CREATE TABLE shop (id INT PRIMARY KEY, lat FLOAT, lng FLOAT)":                "ALTER TABLE `shop` ADD COLUMN `location` POINT SRID 4326; UPDATE `shop` SET `location` = ST_PointFromText(CONCAT('POINT(', `lat`, ' ', `lng`, ')'), 4326); ALTER TABLE `shop` MODIFY `location` POINT NOT NULL SRID 4326, ADD SPATIAL INDEX `idx_location` (`location`);",
+		"ALTER TABLE shop MODIFY longitude DOUBLE":                                    "使用浮点数保存经纬度的列: longitude。",
+		"CREATE TABLE shop (id INT PRIMARY KEY, lat DECIMAL(9,6), lng DECIMAL(10,7))": "",
+		"CREATE TABLE shop (id INT PRIMARY KEY, latency FLOAT, lang FLOAT)":           "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleLatLngFloat()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "GIS.004" || !strings.HasSuffix(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// GIS.005
+func TestRuleLatLngRange(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	common.Config.Target = "mysql:5.7"
+	sqls := map[string]string{
+		"SELECT id FROM shop WHERE lat BETWEEN 39.8 AND 40.0 AND lng BETWEEN 116.3 AND 116.5":               "UPDATE `shop` SET `location` = POINT(`lng`, `lat`); ALTER TABLE `shop` MODIFY `location` POINT NOT NULL, ADD SPATIAL INDEX `idx_location` (`location`); 查询条件改写为 `MBRContains(ST_GeomFromText('POLYGON((116.3 39.8, 116.5 39.8, 116.5 40.0, 116.3 40.0, 116.3 39.8))'), location)`。",
+		"SELECT s.id FROM shop s WHERE s.lat > 39.8 AND s.lat < 40.0 AND s.lng >= 116.3 AND s.lng <= 116.5": ", s.location)`。",
+		"SELECT id FROM shop WHERE lat BETWEEN 39.8 AND 40.0":                                               "",
+		"SELECT id FROM shop WHERE lat BETWEEN 39.8 AND 40.0 OR lng BETWEEN 116.3 AND 116.5":                "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleLatLngRange()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "GIS.005" || !strings.HasSuffix(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// sysVariableNames SYS 类建议使用的服务器变量
+var sysVariableNames = []string{
+	"innodb_buffer_pool_size",
+	"sync_binlog", "innodb_flush_log_at_trx_commit", "log_bin",
+	"slow_query_log", "long_query_time",
+	"max_connections", "sort_buffer_size", "join_buffer_size", "read_buffer_size", "read_rnd_buffer_size", "thread_stack",
+}
+
+// sessionBufferVariables 每个连接按需分配的缓冲区，用于估算 max_connections 个连接同时排序、JOIN 时占用的内存
+var sessionBufferVariables = []string{"sort_buffer_size", "join_buffer_size", "read_buffer_size", "read_rnd_buffer_size", "thread_stack"}
+
+// LoadSysVariables 获取 SYS 类建议使用的服务器变量，未开启 check-sys-variables、未配置线上环境或获取失败时返回 nil
+func LoadSysVariables(conn *database.Connector) map[string]string {
+	if !common.Config.CheckSysVariables || common.Config.OnlineDSN.Disable || conn == nil {
+		return nil
+	}
+	vars, err := conn.GlobalVariables(sysVariableNames...)
+	if err != nil {
+		common.Log.Warning("LoadSysVariables Error: %v", err)
+		return nil
+	}
+	return vars
+}
+
+// SysChecker 结合服务器变量检查与评审 SQL 相关的配置问题（SYS.*），每个输入文件使用一个新的 SysChecker，相同的建议只给出一次
+type SysChecker struct {
+	conn  *database.Connector
+	vars  map[string]string
+	given map[string]bool // 已给出的建议，key 为 Item + Content
+}
+
+// NewSysChecker 初始化 SysChecker，vars 为 LoadSysVariables 的返回值，为空时不给出建议
+func NewSysChecker(conn *database.Connector, vars map[string]string) *SysChecker {
+	return &SysChecker{conn: conn, vars: vars, given: make(map[string]bool)}
+}
+
+// Check 返回该 SQL 相关的 SYS 类建议，tables 为 SQL 使用的库表名
+func (s *SysChecker) Check(q *Query4Audit, tables []string) map[string]Rule {
+	suggest := make(map[string]Rule)
+	if s == nil || len(s.vars) == 0 || q == nil {
+		return suggest
+	}
+	var rules []Rule
+	if rule, ok := sysBufferPoolRule(s.vars, TableSizes(s.conn, tables)); ok {
+		rules = append(rules, rule)
+	}
+	for _, f := range []func(*Query4Audit, map[string]string) (Rule, bool){sysDurabilityRule, sysSlowLogRule, sysMaxConnectionsRule} {
+		if rule, ok := f(q, s.vars); ok {
+			rules = append(rules, rule)
+		}
+	}
+	for _, rule := range rules {
+		key := rule.Item + rule.Content
+		if IsIgnoreRule(rule.Item) || s.given[key] {
+			continue
+		}
+		s.given[key] = true
+		suggest[rule.Item] = rule
+	}
+	return suggest
+}
+
+// TableSizes 获取线上环境中 InnoDB 表的数据及索引大小，key 与 tables 中的库表名相同，获取失败的表不返回
+func TableSizes(conn *database.Connector, tables []string) map[string]int64 {
+	sizes := make(map[string]int64)
+	if common.Config.OnlineDSN.Disable || conn == nil {
+		return sizes
+	}
+	for _, name := range tables {
+		db, tb := splitTableName(name)
+		if tb == "" || tb == "dual" {
+			continue
+		}
+		tmp := *conn
+		if db != "" {
+			tmp.Database = db
+		}
+		status, err := tmp.ShowTableStatus(tb)
+		if err != nil || len(status.Rows) == 0 {
+			common.Log.Debug("TableSizes ShowTableStatus %s Error: %v", name, err)
+			continue
+		}
+		row := status.Rows[0]
+		if strings.EqualFold(string(row.Engine), "InnoDB") {
+			sizes[name] = int64(row.DataLength + row.IndexLength)
+		}
+	}
+	return sizes
+}
+
+// sysInt 整数类型的变量值，不存在或不是整数时 ok 为 false
+func sysInt(vars map[string]string, name string) (int64, bool) {
+	v, err := strconv.ParseInt(vars[name], 10, 64)
+	return v, err == nil
+}
+
+// sysOn 开关类型的变量是否开启
+func sysOn(vars map[string]string, name string) bool {
+	switch strings.ToUpper(vars[name]) {
+	case "ON", "1":
+		return true
+	}
+	return false
+}
+
+// sysBufferPoolRule SQL 使用的 InnoDB 表的数据及索引大小之和超过 innodb_buffer_pool_size（SYS.001）
+func sysBufferPoolRule(vars map[string]string, sizes map[string]int64) (Rule, bool) {
+	pool, ok := sysInt(vars, "innodb_buffer_pool_size")
+	if !ok || pool <= 0 || len(sizes) == 0 {
+		return Rule{}, false
+	}
+	var total int64
+	var tables []string
+	for _, name := range common.SortedKey(sizes) {
+		total += sizes[name]
+		tables = append(tables, fmt.Sprintf("%s(%s)", name, database.FormatBytes(sizes[name])))
+	}
+	if total <= pool {
+		return Rule{}, false
+	}
+	rule := HeuristicRules["SYS.001"]
+	rule.Content = strings.Join([]string{rule.Content, ruleMessage("SYS.001", map[string]interface{}{
+		"Tables": tables, "Size": database.FormatBytes(total), "Pool": database.FormatBytes(pool),
+	})}, " ")
+	return rule, true
+}
+
+// sysDurabilityRule 写入的 SQL 在 sync_binlog, innodb_flush_log_at_trx_commit 不为 1 时崩溃可能丢失已提交的事务（SYS.002）
+func sysDurabilityRule(q *Query4Audit, vars map[string]string) (Rule, bool) {
+	switch q.Stmt.(type) {
+	case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return Rule{}, false
+	}
+	var reasons []string
+	if v, ok := sysInt(vars, "innodb_flush_log_at_trx_commit"); ok && v != 1 {
+		reasons = append(reasons, ruleMessage("SYS.002.flush_log", map[string]interface{}{"Value": v}))
+	}
+	if v, ok := sysInt(vars, "sync_binlog"); ok && v != 1 && sysOn(vars, "log_bin") {
+		reasons = append(reasons, ruleMessage("SYS.002.sync_binlog", map[string]interface{}{"Value": v}))
+	}
+	if len(reasons) == 0 {
+		return Rule{}, false
+	}
+	rule := HeuristicRules["SYS.002"]
+	rule.Content = strings.Join(append([]string{rule.Content}, reasons...), " ")
+	return rule, true
+}
+
+// sysSlowLogRule 未开启慢查询日志或 long_query_time 过大，无法在线上发现评审的 SQL 的性能问题（SYS.003）
+func sysSlowLogRule(q *Query4Audit, vars map[string]string) (Rule, bool) {
+	switch q.Stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return Rule{}, false
+	}
+	var reason string
+	if _, ok := vars["slow_query_log"]; ok && !sysOn(vars, "slow_query_log") {
+		reason = ruleMessage("SYS.003.slow_query_log", nil)
+	} else if v, err := strconv.ParseFloat(vars["long_query_time"], 64); err == nil && v >= 10 {
+		reason = ruleMessage("SYS.003.long_query_time", map[string]interface{}{"Value": vars["long_query_time"]})
+	}
+	if reason == "" {
+		return Rule{}, false
+	}
+	rule := HeuristicRules["SYS.003"]
+	rule.Content = strings.Join([]string{rule.Content, reason}, " ")
+	return rule, true
+}
+
+// sysMaxConnectionsRule 排序、分组或 JOIN 的 SELECT 在 max_connections 个连接同时执行时，各连接的缓冲区之和超过 innodb_buffer_pool_size（SYS.004）
+func sysMaxConnectionsRule(q *Query4Audit, vars map[string]string) (Rule, bool) {
+	sel, ok := q.Stmt.(*sqlparser.Select)
+	if !ok || (len(sel.OrderBy) == 0 && len(sel.GroupBy) == 0 && sel.Distinct == "" && !hasJoin(sel.From)) {
+		return Rule{}, false
+	}
+	conns, ok := sysInt(vars, "max_connections")
+	if !ok {
+		return Rule{}, false
+	}
+	pool, ok := sysInt(vars, "innodb_buffer_pool_size")
+	if !ok || pool <= 0 {
+		return Rule{}, false
+	}
+	var perConn int64
+	for _, name := range sessionBufferVariables {
+		if v, ok := sysInt(vars, name); ok {
+			perConn += v
+		}
+	}
+	if perConn == 0 || conns*perConn <= pool {
+		return Rule{}, false
+	}
+	rule := HeuristicRules["SYS.004"]
+	rule.Content = strings.Join([]string{rule.Content, ruleMessage("SYS.004", map[string]interface{}{
+		"Connections": conns, "Variables": sessionBufferVariables, "PerConnection": database.FormatBytes(perConn),
+		"Total": database.FormatBytes(conns * perConn), "Pool": database.FormatBytes(pool),
+	})}, " ")
+	return rule, true
+}
+
+// hasJoin FROM 中是否有 JOIN
+func hasJoin(from sqlparser.TableExprs) bool {
+	for _, expr := range from {
+		if _, ok := expr.(*sqlparser.JoinTableExpr); ok {
+			return true
+		}
+	}
+	return len(from) > 1
+}
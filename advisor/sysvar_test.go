@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// SYS.001
+func TestSysBufferPoolRule(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	vars := map[string]string{"innodb_buffer_pool_size": "134217728"}
+	rule, ok := sysBufferPoolRule(vars, map[string]int64{"`sakila`.`orders`": 100 << 20, "`sakila`.`users`": 64 << 20})
+	if !ok || rule.Item != "SYS.001" || !strings.Contains(rule.Content, "`sakila`.`orders`(100MB), `sakila`.`users`(64MB) total 164MB, while innodb_buffer_pool_size is 128MB") {
+		t.Errorf("want SYS.001, got %v %v", ok, rule)
+	}
+
+	// 补充说明使用 -lang 指定的语言
+	if err := LoadRuleLocale("zh-CN", ""); err != nil {
+		t.Fatal(err)
+	}
+	rule, _ = sysBufferPoolRule(vars, map[string]int64{"`sakila`.`orders`": 100 << 20, "`sakila`.`users`": 64 << 20})
+	common.LogIfError(LoadRuleLocale(common.Config.Lang, ""), "")
+	if !strings.Contains(rule.Content, "`sakila`.`users`(64MB) 的数据及索引共 164MB，innodb_buffer_pool_size 为 128MB") {
+		t.Errorf("unexpected content: %s", rule.Content)
+	}
+	if _, ok := sysBufferPoolRule(vars, map[string]int64{"`sakila`.`orders`": 100 << 20}); ok {
+		t.Error("tables fit in buffer pool, want no SYS.001")
+	}
+	if _, ok := sysBufferPoolRule(map[string]string{}, map[string]int64{"`sakila`.`orders`": 100 << 20}); ok {
+		t.Error("innodb_buffer_pool_size unknown, want no SYS.001")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// SYS.002, SYS.003, SYS.004
+func TestSysQueryRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	unsafe := map[string]string{
+		"innodb_flush_log_at_trx_commit": "2",
+		"sync_binlog":                    "0",
+		"log_bin":                        "ON",
+		"slow_query_log":                 "OFF",
+		"long_query_time":                "10.000000",
+		"max_connections":                "10000",
+		"sort_buffer_size":               "67108864",
+		"innodb_buffer_pool_size":        "1073741824",
+	}
+	safe := map[string]string{
+		"innodb_flush_log_at_trx_commit": "1",
+		"sync_binlog":                    "1",
+		"log_bin":                        "ON",
+		"slow_query_log":                 "ON",
+		"long_query_time":                "1.000000",
+		"max_connections":                "151",
+		"sort_buffer_size":               "262144",
+		"innodb_buffer_pool_size":        "1073741824",
+	}
+	cases := map[string]string{
+		"UPDATE orders SET status = 1 WHERE id = 1":               "SYS.002,SYS.003",
+		"SELECT * FROM orders WHERE user_id = 1":                  "SYS.003",
+		"SELECT * FROM orders ORDER BY created DESC LIMIT 10":     "SYS.003,SYS.004",
+		"SELECT * FROM orders o JOIN users u ON o.user_id = u.id": "SYS.003,SYS.004",
+		"SELECT user_id, count(*) FROM orders GROUP BY user_id":   "SYS.003,SYS.004",
+		"CREATE TABLE orders (id int PRIMARY KEY, user_id int)":   "",
+	}
+	funcs := []func(*Query4Audit, map[string]string) (Rule, bool){sysDurabilityRule, sysSlowLogRule, sysMaxConnectionsRule}
+	for sql, want := range cases {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(sql, err)
+			continue
+		}
+		var got []string
+		for _, f := range funcs {
+			if rule, ok := f(q, unsafe); ok {
+				got = append(got, rule.Item)
+			}
+			if rule, ok := f(q, safe); ok {
+				t.Errorf("SQL: %s, want no advice with safe variables, got %s", sql, rule.Item)
+			}
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("SQL: %s, want %s, got %v", sql, want, got)
+		}
+	}
+
+	q, _ := NewQuery4Audit("DELETE FROM orders WHERE id = 1")
+	rule, _ := sysDurabilityRule(q, map[string]string{"innodb_flush_log_at_trx_commit": "1", "sync_binlog": "0", "log_bin": "ON"})
+	if !strings.Contains(rule.Content, "sync_binlog = 0") || strings.Contains(rule.Content, "innodb_flush_log_at_trx_commit =") {
+		t.Errorf("want only sync_binlog in content, got %s", rule.Content)
+	}
+	// 未开启 binlog 时不检查 sync_binlog
+	if _, ok := sysDurabilityRule(q, map[string]string{"sync_binlog": "0", "log_bin": "OFF"}); ok {
+		t.Error("log_bin is OFF, want no SYS.002")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestSysChecker(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	vars := map[string]string{"slow_query_log": "OFF"}
+	checker := NewSysChecker(nil, vars)
+	q, _ := NewQuery4Audit("SELECT * FROM orders WHERE user_id = 1")
+	if suggest := checker.Check(q, nil); len(suggest) != 1 || suggest["SYS.003"].Item != "SYS.003" {
+		t.Errorf("want SYS.003, got %v", suggest)
+	}
+	// 同一个输入文件中相同的建议只给出一次
+	q, _ = NewQuery4Audit("SELECT * FROM users WHERE id = 1")
+	if suggest := checker.Check(q, nil); len(suggest) != 0 {
+		t.Errorf("want SYS.003 only once, got %v", suggest)
+	}
+	if suggest := NewSysChecker(nil, nil).Check(q, nil); len(suggest) != 0 {
+		t.Errorf("check-sys-variables disabled, want no advice, got %v", suggest)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
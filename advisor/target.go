@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// targetFeature 依赖数据库版本的语法特性
+// MySQL, MariaDB 为开始支持该特性的最低版本，格式与 Dsn.Version 相同，0 表示不支持
+type targetFeature struct {
+	Name    string
+	MySQL   int
+	MariaDB int
+	Re      *regexp.Regexp
+}
+
+// targetFeatures VER.001 检查的语法特性
+var targetFeatures = []targetFeature{
+	{"window function", 80000, 100200, regexp.MustCompile(`(?i)\)\s*over\s*(\(|\w)`)},
+	{"WITH (CTE)", 80000, 100200, regexp.MustCompile(`(?is)^\s*\(?\s*with\s+(recursive\s+)?\w+\s*(\([^)]*\)\s*)?as\s*\(`)},
+	{"ALGORITHM=INSTANT", 80012, 100300, regexp.MustCompile(`(?i)algorithm\s*=?\s*instant`)},
+	{"ALGORITHM=INSTANT DROP COLUMN / FIRST / AFTER", 80029, 100400, regexp.MustCompile(`(?is)algorithm\s*=?\s*instant.*\b(drop\s+column|first|after)\b|\b(drop\s+column|first|after)\b.*algorithm\s*=?\s*instant`)},
+	{"WITH SYSTEM VERSIONING", 0, 100300, regexp.MustCompile(`(?i)\bsystem\s+versioning\b|\bfor\s+system_time\b`)},
+	{"JSON_TABLE()", 80004, 100600, regexp.MustCompile(`(?i)\bjson_table\s*\(`)},
+	{"LATERAL", 80014, 0, regexp.MustCompile(`(?i)\bjoin\s+lateral\b|,\s*lateral\s*\(`)},
+	{"RETURNING", 0, 100500, regexp.MustCompile(`(?is)^\s*(insert|replace|delete)\b.*\breturning\b`)},
+	{"SEQUENCE", 0, 100300, regexp.MustCompile(`(?i)\b(create|alter|drop)\s+sequence\b|\bnextval\s*\(|\bnext\s+value\s+for\b`)},
+	{"UUID_TO_BIN()/BIN_TO_UUID()", 80000, 0, regexp.MustCompile(`(?i)\b(uuid_to_bin|bin_to_uuid)\s*\(`)},
+	{"JSON ->/->> operator", 50709, 0, regexp.MustCompile(`\w\s*->>?\s*'\$`)},
+}
+
+// RuleTargetVersion VER.001
+func (q *Query4Audit) RuleTargetVersion() Rule {
+	var rule = q.RuleOK()
+	target := common.TargetDB()
+	if target.Flavor == "" {
+		return rule
+	}
+	var features []string
+	for _, f := range targetFeatures {
+		if target.Unsupported(f.MySQL, f.MariaDB) && f.Re.MatchString(q.Query) {
+			features = append(features, f.Name)
+		}
+	}
+	if len(features) > 0 {
+		rule = HeuristicRules["VER.001"]
+		rule.Content = fmt.Sprintf("%s (%s: %s)", rule.Content, common.Config.Target, strings.Join(features, ", "))
+	}
+	return rule
+}
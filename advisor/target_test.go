@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// VER.001
+func TestRuleTargetVersion(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	cases := []struct {
+		target string
+		hit    []string
+		miss   []string
+	}{
+		{
+			"mysql:5.7",
+			[]string{
+				"SELECT id, ROW_NUMBER() OVER (PARTITION BY c ORDER BY id) FROM tbl",
+				"WITH t AS (SELECT id FROM tbl) SELECT * FROM t",
+				"ALTER TABLE tbl ADD COLUMN c int, ALGORITHM=INSTANT",
+				"SELECT * FROM tbl WHERE doc->>'$.name' = 'soar'",
+				"CREATE TABLE tbl (id int) WITH SYSTEM VERSIONING",
+			},
+			[]string{
+				"SELECT id, count(*) FROM tbl GROUP BY id",
+				"SELECT * FROM tbl WHERE JSON_EXTRACT(doc, '$.name') = 'soar'",
+			},
+		},
+		{
+			"mysql:8.0.20",
+			[]string{
+				"ALTER TABLE tbl DROP COLUMN c, ALGORITHM=INSTANT",
+				"INSERT INTO tbl (c) VALUES (1) RETURNING id",
+				"CREATE SEQUENCE seq START WITH 1",
+			},
+			[]string{
+				"SELECT id, ROW_NUMBER() OVER (PARTITION BY c ORDER BY id) FROM tbl",
+				"ALTER TABLE tbl ADD COLUMN c int, ALGORITHM=INSTANT",
+				"SELECT * FROM t1 JOIN LATERAL (SELECT * FROM t2 WHERE t2.id = t1.id) AS d",
+			},
+		},
+		{
+			"mariadb:10.6",
+			[]string{
+				"SELECT * FROM t1 JOIN LATERAL (SELECT * FROM t2 WHERE t2.id = t1.id) AS d",
+				"INSERT INTO tbl (id) VALUES (UUID_TO_BIN(UUID(), 1))",
+				"SELECT * FROM tbl WHERE doc->>'$.name' = 'soar'",
+			},
+			[]string{
+				"SELECT id, ROW_NUMBER() OVER (PARTITION BY c ORDER BY id) FROM tbl",
+				"CREATE TABLE tbl (id int) WITH SYSTEM VERSIONING",
+				"SELECT * FROM tbl FOR SYSTEM_TIME AS OF TIMESTAMP '2020-01-01 00:00:00'",
+				"INSERT INTO tbl (c) VALUES (1) RETURNING id",
+				"ALTER TABLE tbl DROP COLUMN c, ALGORITHM=INSTANT",
+				"SELECT * FROM JSON_TABLE(@doc, '$[*]' COLUMNS (id int PATH '$.id')) AS jt",
+			},
+		},
+		{
+			"",
+			nil,
+			[]string{
+				"SELECT id, ROW_NUMBER() OVER (PARTITION BY c ORDER BY id) FROM tbl",
+				"INSERT INTO tbl (c) VALUES (1) RETURNING id",
+			},
+		},
+	}
+	for _, c := range cases {
+		common.Config.Target = c.target
+		for _, sql := range c.hit {
+			q, _ := NewQuery4Audit(sql)
+			if rule := q.RuleTargetVersion(); rule.Item != "VER.001" {
+				t.Error("Rule not match:", rule.Item, "Expect : VER.001, target:", c.target, "SQL:", sql)
+			}
+		}
+		for _, sql := range c.miss {
+			q, _ := NewQuery4Audit(sql)
+			if rule := q.RuleTargetVersion(); rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK, target:", c.target, "SQL:", sql)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// KEY.008, KEY.012 按 -target 调整
+func TestTargetAdjustedRules(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+
+	sql := "SELECT * FROM tbl ORDER BY a DESC, b ASC"
+	for target, expect := range map[string]string{
+		"":              "KEY.008",
+		"mysql":         "KEY.008",
+		"mysql:5.7":     "KEY.008",
+		"mysql:8.0":     "OK",
+		"mariadb:10.6":  "KEY.008",
+		"mariadb:10.11": "OK",
+	} {
+		common.Config.Target = target
+		q, _ := NewQuery4Audit(sql)
+		if rule := q.RuleOrderByMultiDirection(); rule.Item != expect {
+			t.Error("Rule not match:", rule.Item, "Expect :", expect, "target:", target)
+		}
+	}
+
+	common.Config.Target = "mariadb:10.6"
+	if content := renderRule(HeuristicRules["KEY.012"]).Content; strings.Contains(content, "UUID_TO_BIN") {
+		t.Error("KEY.012 should not recommend UUID_TO_BIN for MariaDB:", content)
+	}
+	common.Config.Target = "mysql:8.0"
+	if content := renderRule(HeuristicRules["KEY.012"]).Content; !strings.Contains(content, "UUID_TO_BIN") {
+		t.Error("KEY.012 should recommend UUID_TO_BIN for MySQL:", content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
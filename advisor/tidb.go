@@ -0,0 +1,276 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// isTiDB 是否启用 TiDB 方言，TDB 类规则仅在 -dialect=tidb 时检查
+func isTiDB() bool {
+	return common.Config.Dialect == "tidb"
+}
+
+// tidbIntPrimaryKey 返回建表语句中的单列整型主键，没有时返回 nil
+// TiDB 会直接使用单列整型主键作为行 ID，其他情况使用隐式的 _tidb_rowid
+func tidbIntPrimaryKey(node *tidb.CreateTableStmt) *tidb.ColumnDef {
+	cols := make(map[string]*tidb.ColumnDef)
+	for _, col := range node.Cols {
+		cols[col.Name.Name.L] = col
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionPrimaryKey && col.Tp != nil && mysql.IsIntegerType(col.Tp.Tp) {
+				return col
+			}
+		}
+	}
+	for _, cons := range node.Constraints {
+		if cons.Tp != tidb.ConstraintPrimaryKey || len(cons.Keys) != 1 || cons.Keys[0].Column == nil {
+			continue
+		}
+		col, ok := cols[cons.Keys[0].Column.Name.L]
+		if ok && col.Tp != nil && mysql.IsIntegerType(col.Tp.Tp) {
+			return col
+		}
+	}
+	return nil
+}
+
+// RuleTiDBAutoIncPrimaryKey TDB.001
+// AUTO_INCREMENT 整型主键在 TiDB 中连续递增，写入会集中在最后一个 Region
+func (q *Query4Audit) RuleTiDBAutoIncPrimaryKey() Rule {
+	var rule = q.RuleOK()
+	if !isTiDB() {
+		return rule
+	}
+	for _, tiStmt := range q.TiStmt {
+		node, ok := tiStmt.(*tidb.CreateTableStmt)
+		if !ok {
+			continue
+		}
+		pk := tidbIntPrimaryKey(node)
+		if pk == nil {
+			continue
+		}
+		for _, opt := range pk.Options {
+			if opt.Tp == tidb.ColumnOptionAutoIncrement {
+				return HeuristicRules["TDB.001"]
+			}
+		}
+	}
+	return rule
+}
+
+// RuleTiDBShardRowIDBits TDB.002
+// 没有整型主键的表使用隐式递增的 _tidb_rowid，未设置 SHARD_ROW_ID_BITS 时写入同样会集中在一个 Region
+func (q *Query4Audit) RuleTiDBShardRowIDBits() Rule {
+	var rule = q.RuleOK()
+	if !isTiDB() {
+		return rule
+	}
+	for _, tiStmt := range q.TiStmt {
+		node, ok := tiStmt.(*tidb.CreateTableStmt)
+		if !ok || node.ReferTable != nil || node.Select != nil {
+			continue
+		}
+		if tidbIntPrimaryKey(node) != nil {
+			continue
+		}
+		var shard bool
+		for _, opt := range node.Options {
+			if opt.Tp == tidb.TableOptionShardRowID && opt.UintValue > 0 {
+				shard = true
+			}
+		}
+		if !shard {
+			return HeuristicRules["TDB.002"]
+		}
+	}
+	return rule
+}
+
+// TiDB 不支持的语句
+var tidbUnsupportedStmtRe = regexp.MustCompile(`(?i)^\s*(create\s+(definer\s*=\s*\S+\s+)?(trigger|procedure|function|event)\b|xa\s+(start|begin|end|prepare|commit|rollback|recover)\b)`)
+
+// 空间类型及空间索引
+var tidbSpatialRe = regexp.MustCompile("(?i)\\bspatial\\s+(key|index)\\b|[\\w`]\\s+(geometry|point|linestring|polygon|multipoint|multilinestring|multipolygon|geometrycollection)\\b")
+
+// RuleTiDBUnsupported TDB.003
+// 外键、全文索引、空间类型、触发器、存储过程、自定义函数、事件及 XA 事务在 TiDB 中不支持或不生效
+func (q *Query4Audit) RuleTiDBUnsupported() Rule {
+	var rule = q.RuleOK()
+	if !isTiDB() {
+		return rule
+	}
+	if tidbUnsupportedStmtRe.MatchString(q.Query) {
+		return HeuristicRules["TDB.003"]
+	}
+
+	// TiDB 解析器无法解析空间类型及空间索引
+	if _, ok := q.Stmt.(*sqlparser.DDL); ok && len(q.TiStmt) == 0 && tidbSpatialRe.MatchString(q.Query) {
+		return HeuristicRules["TDB.003"]
+	}
+
+	unsupportedCol := func(col *tidb.ColumnDef) bool {
+		for _, opt := range col.Options {
+			if opt.Tp == tidb.ColumnOptionReference {
+				return true
+			}
+		}
+		return false
+	}
+	unsupportedCons := func(cons *tidb.Constraint) bool {
+		return cons != nil && (cons.Tp == tidb.ConstraintForeignKey || cons.Tp == tidb.ConstraintFulltext)
+	}
+
+	for _, tiStmt := range q.TiStmt {
+		switch node := tiStmt.(type) {
+		case *tidb.CreateTableStmt:
+			for _, col := range node.Cols {
+				if unsupportedCol(col) {
+					return HeuristicRules["TDB.003"]
+				}
+			}
+			for _, cons := range node.Constraints {
+				if unsupportedCons(cons) {
+					return HeuristicRules["TDB.003"]
+				}
+			}
+		case *tidb.AlterTableStmt:
+			for _, spec := range node.Specs {
+				for _, col := range spec.NewColumns {
+					if unsupportedCol(col) {
+						return HeuristicRules["TDB.003"]
+					}
+				}
+				if unsupportedCons(spec.Constraint) {
+					return HeuristicRules["TDB.003"]
+				}
+			}
+		case *tidb.CreateIndexStmt:
+			if node.KeyType == tidb.IndexKeyTypeFullText || node.KeyType == tidb.IndexKeyTypeSpatial {
+				return HeuristicRules["TDB.003"]
+			}
+		}
+	}
+	return rule
+}
+
+// TiFlash 及 MPP 相关的 Hint
+var tidbTiFlashHintRe = regexp.MustCompile(`(?is)/\*\+.*?\b(read_from_storage\s*\(\s*tiflash|mpp_1phase_agg|mpp_2phase_agg|shuffle_join|broadcast_join)\b.*?\*/`)
+
+// RuleTiDBTiFlashHint TDB.004
+// TiFlash/MPP Hint 依赖表的 TiFlash 副本及 tidb_allow_mpp 等系统变量，条件不满足时 Hint 会被静默忽略
+func (q *Query4Audit) RuleTiDBTiFlashHint() Rule {
+	var rule = q.RuleOK()
+	if !isTiDB() {
+		return rule
+	}
+	if tidbTiFlashHintRe.MatchString(q.Query) {
+		rule = HeuristicRules["TDB.004"]
+	}
+	return rule
+}
+
+// tidbOperators TiDB 常见算子说明
+var tidbOperators = map[string]string{
+	"TableFullScan":    "全表扫描，TiKV 或 TiFlash 中读取整张表的数据。",
+	"TableRangeScan":   "按主键范围扫描表数据。",
+	"TableRowIDScan":   "根据索引中读取到的 RowID 回表读取数据。",
+	"IndexFullScan":    "扫描整个索引。",
+	"IndexRangeScan":   "按索引范围扫描。",
+	"IndexLookUp":      "先读取索引再回表读取数据，索引返回行数较多时回表代价较高。",
+	"IndexReader":      "只读取索引即可返回结果（覆盖索引）。",
+	"TableReader":      "汇总 TiKV 或 TiFlash 返回的表数据。",
+	"IndexMerge":       "使用多个索引读取数据后合并。",
+	"Point_Get":        "按主键或唯一索引等值查询单行数据，效率最高。",
+	"Batch_Point_Get":  "按主键或唯一索引等值查询多行数据。",
+	"HashJoin":         "哈希连接，需要在内存中为内表构建哈希表。",
+	"IndexJoin":        "使用外表结果查找内表索引进行连接。",
+	"IndexHashJoin":    "与 IndexJoin 类似，使用哈希表匹配内表结果。",
+	"MergeJoin":        "排序合并连接，要求两侧输入有序。",
+	"HashAgg":          "哈希聚合。",
+	"StreamAgg":        "流式聚合，要求输入有序。",
+	"Sort":             "排序，数据量较大时可能占用大量内存或落盘。",
+	"TopN":             "ORDER BY ... LIMIT 的排序，可下推至存储层。",
+	"Selection":        "过滤条件。",
+	"Projection":       "投影计算。",
+	"ExchangeSender":   "MPP 模式下向其他节点发送数据。",
+	"ExchangeReceiver": "MPP 模式下从其他节点接收数据。",
+}
+
+// tidbFullScan 判断算子是否为全表扫描，TiDB 4.0 之前全表扫描为 range:[-inf,+inf] 的 TableScan
+func tidbFullScan(row database.TiDBExplainRow) bool {
+	return row.Operator == "TableFullScan" ||
+		(row.Operator == "TableScan" && strings.Contains(row.OperatorInfo, "range:[-inf,+inf]"))
+}
+
+// tidbExplainTranslator 解读 TiDB 执行计划，对全表扫描、统计信息缺失、估算偏差及 TiFlash 使用情况给出提示
+func tidbExplainTranslator(exp *database.ExplainInfo) string {
+	var ops, notes []string
+	seen := make(map[string]bool)
+	for _, row := range exp.TiDBRows {
+		if desc, ok := tidbOperators[row.Operator]; ok && !seen[row.Operator] {
+			seen[row.Operator] = true
+			ops = append(ops, fmt.Sprintf("* **%s**: %s", common.MarkdownEscape(row.Operator), desc))
+		}
+
+		if tidbFullScan(row) && common.Config.ExplainMaxRows > 0 && int64(row.EstRows) >= common.Config.ExplainMaxRows {
+			notes = append(notes, fmt.Sprintf("* ☠️ **%s** 对 %s 全表扫描，估算 %.0f 行，请检查是否缺少索引。",
+				common.MarkdownEscape(row.ID), common.MarkdownEscape(row.AccessObject), row.EstRows))
+		}
+		if strings.Contains(row.OperatorInfo, "stats:pseudo") {
+			notes = append(notes, fmt.Sprintf("* **%s** 使用了 pseudo 统计信息，执行计划可能不准确，建议对 %s 执行 ANALYZE TABLE。",
+				common.MarkdownEscape(row.ID), common.MarkdownEscape(row.AccessObject)))
+		}
+		// 估算行数与实际行数相差 10 倍以上时认为统计信息已过期
+		if row.ActRows >= 0 {
+			est, act := row.EstRows+1, float64(row.ActRows+1)
+			if est > act*10 || act > est*10 {
+				notes = append(notes, fmt.Sprintf("* **%s** 估算 %.0f 行，实际 %d 行，统计信息可能已过期。",
+					common.MarkdownEscape(row.ID), row.EstRows, row.ActRows))
+			}
+		}
+		if strings.HasPrefix(row.Task, "mpp") {
+			notes = append(notes, fmt.Sprintf("* **%s** 使用 MPP 模式在 TiFlash 中执行。", common.MarkdownEscape(row.ID)))
+		} else if strings.Contains(row.Task, "tiflash") {
+			notes = append(notes, fmt.Sprintf("* **%s** 从 TiFlash 列存副本读取数据。", common.MarkdownEscape(row.ID)))
+		}
+	}
+	if len(ops) == 0 && len(notes) == 0 {
+		return ""
+	}
+
+	sort.Strings(ops)
+	buf := []string{"### TiDB 执行计划解读\n"}
+	if len(ops) > 0 {
+		buf = append(buf, "#### Operator 信息解读\n", strings.Join(ops, "\n"), "")
+	}
+	if len(notes) > 0 {
+		buf = append(buf, "#### 执行计划提示\n", strings.Join(notes, "\n"))
+	}
+	return strings.Join(buf, "\n")
+}
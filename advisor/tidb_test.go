@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// TDB.001 ~ TDB.004
+func TestRuleTiDB(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDialect := common.Config.Dialect
+	defer func() { common.Config.Dialect = orgDialect }()
+
+	cases := []struct {
+		item string
+		rule func(*Query4Audit) Rule
+		hit  []string
+		miss []string
+	}{
+		{
+			"TDB.001", (*Query4Audit).RuleTiDBAutoIncPrimaryKey,
+			[]string{
+				"CREATE TABLE tbl (id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY, name varchar(64))",
+				"CREATE TABLE tbl (id int NOT NULL AUTO_INCREMENT, name varchar(64), PRIMARY KEY (id))",
+			},
+			[]string{
+				"CREATE TABLE tbl (id bigint NOT NULL PRIMARY KEY, name varchar(64))",
+				"CREATE TABLE tbl (id bigint NOT NULL AUTO_INCREMENT, uid varchar(36), PRIMARY KEY (uid), KEY (id))",
+			},
+		},
+		{
+			"TDB.002", (*Query4Audit).RuleTiDBShardRowIDBits,
+			[]string{
+				"CREATE TABLE tbl (uuid varchar(36) NOT NULL PRIMARY KEY, name varchar(64))",
+				"CREATE TABLE tbl (a int, b int)",
+			},
+			[]string{
+				"CREATE TABLE tbl (id bigint NOT NULL PRIMARY KEY, name varchar(64))",
+				"CREATE TABLE tbl (a int, b int) SHARD_ROW_ID_BITS = 4",
+			},
+		},
+		{
+			"TDB.003", (*Query4Audit).RuleTiDBUnsupported,
+			[]string{
+				"CREATE TABLE tbl (id bigint PRIMARY KEY, pid bigint, FOREIGN KEY (pid) REFERENCES parent(id))",
+				"CREATE TABLE tbl (id bigint PRIMARY KEY, g geometry)",
+				"CREATE TABLE tbl (id bigint PRIMARY KEY, g point NOT NULL, SPATIAL KEY (g))",
+				"ALTER TABLE tbl ADD FULLTEXT INDEX ft_c (c)",
+				"CREATE TRIGGER trg BEFORE INSERT ON tbl FOR EACH ROW SET NEW.a = 1",
+			},
+			[]string{
+				"CREATE TABLE tbl (id bigint PRIMARY KEY, pid bigint, KEY (pid))",
+			},
+		},
+		{
+			"TDB.004", (*Query4Audit).RuleTiDBTiFlashHint,
+			[]string{
+				"SELECT /*+ READ_FROM_STORAGE(TIFLASH[t]) */ count(*) FROM t",
+				"SELECT /*+ BROADCAST_JOIN(t1) */ count(*) FROM t1 JOIN t2 ON t1.id = t2.id",
+			},
+			[]string{
+				"SELECT /*+ READ_FROM_STORAGE(TIKV[t]) */ count(*) FROM t",
+				"SELECT count(*) FROM t WHERE c = 'broadcast_join'",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		for _, dialect := range []string{"tidb", "mysql"} {
+			common.Config.Dialect = dialect
+			for _, sql := range c.hit {
+				q, _ := NewQuery4Audit(sql)
+				expect := c.item
+				if dialect != "tidb" {
+					expect = "OK"
+				}
+				if rule := c.rule(q); rule.Item != expect {
+					t.Error("Rule not match:", rule.Item, "Expect :", expect, "SQL:", sql)
+				}
+			}
+		}
+		common.Config.Dialect = "tidb"
+		for _, sql := range c.miss {
+			q, _ := NewQuery4Audit(sql)
+			if rule := c.rule(q); rule.Item != "OK" {
+				t.Error("Rule not match:", rule.Item, "Expect : OK, SQL:", sql)
+			}
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestTiDBExplainAdvisor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	exp := &database.ExplainInfo{TiDBRows: []database.TiDBExplainRow{
+		{ID: "TableReader_5", Operator: "TableReader", EstRows: 100000, ActRows: 3, Task: "root"},
+		{ID: "TableFullScan_4", Operator: "TableFullScan", Depth: 1, EstRows: 100000, ActRows: 3, Task: "cop[tiflash]",
+			AccessObject: "table:t", OperatorInfo: "keep order:false, stats:pseudo"},
+	}}
+	rule, ok := ExplainAdvisor(exp)["EXP.000"]
+	if !ok {
+		t.Fatal("EXP.000 not found")
+	}
+	if !strings.Contains(rule.Content, "TableFullScan\\_4") {
+		t.Error("explain table not found:", rule.Content)
+	}
+	for _, s := range []string{"全表扫描，估算 100000 行", "pseudo", "实际 3 行", "TiFlash 列存副本", "**TableReader**"} {
+		if !strings.Contains(rule.Case, s) {
+			t.Errorf("'%s' not found in:\n%s", s, rule.Case)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/mysql"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// localTimeFuncs 返回会话时区当前时间的函数
+var localTimeFuncs = map[string]bool{
+	"now": true, "current_timestamp": true, "localtime": true, "localtimestamp": true, "sysdate": true,
+	"curdate": true, "current_date": true, "curtime": true, "current_time": true,
+}
+
+// utcTimeFuncs 返回 UTC 当前时间的函数
+var utcTimeFuncs = map[string]bool{
+	"utc_timestamp": true, "utc_date": true, "utc_time": true,
+}
+
+// dateStringRe 日期或日期时间格式的字符串常量
+var dateStringRe = regexp.MustCompile(`^\d{4}-\d{1,2}-\d{1,2}([ T]\d{1,2}:\d{1,2}(:\d{1,2}(\.\d+)?)?)?$`)
+
+// timeTypeBase 时间类型列的基础类型，非时间类型返回空
+func timeTypeBase(dataType string) string {
+	base := strings.Fields(strings.ToLower(common.GetDataTypeBase(dataType)))
+	if len(base) == 0 {
+		return ""
+	}
+	switch base[0] {
+	case "date", "datetime", "timestamp", "time":
+		return base[0]
+	}
+	return ""
+}
+
+// autoTimestamp 列定义中是否使用 CURRENT_TIMESTAMP 作为默认值或 ON UPDATE 的值，这类列记录的是数据变更时间
+func autoTimestamp(col *tidb.ColumnDef) bool {
+	for _, option := range col.Options {
+		if option.Tp != tidb.ColumnOptionDefaultValue && option.Tp != tidb.ColumnOptionOnUpdate {
+			continue
+		}
+		var sb strings.Builder
+		if err := option.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)); err != nil {
+			continue
+		}
+		upper := strings.ToUpper(sb.String())
+		if strings.Contains(upper, "CURRENT_TIMESTAMP") || strings.Contains(upper, "NOW(") {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleTimestampRange TIM.001
+// 以 CURRENT_TIMESTAMP 自动维护的变更时间不受影响，只检查由业务写入的 TIMESTAMP 列
+func (q *Query4Audit) RuleTimestampRange() Rule {
+	var rule = q.RuleOK()
+	var cols []string
+	for _, node := range q.TiStmt {
+		var defs []*tidb.ColumnDef
+		switch n := node.(type) {
+		case *tidb.CreateTableStmt:
+			defs = n.Cols
+		case *tidb.AlterTableStmt:
+			for _, s := range n.Specs {
+				switch s.Tp {
+				case tidb.AlterTableAddColumns, tidb.AlterTableChangeColumn, tidb.AlterTableModifyColumn:
+					defs = append(defs, s.NewColumns...)
+				}
+			}
+		}
+		for _, c := range defs {
+			if c.Tp != nil && c.Tp.Tp == mysql.TypeTimestamp && !autoTimestamp(c) {
+				cols = append(cols, c.Name.Name.O)
+			}
+		}
+	}
+	if len(cols) > 0 {
+		rule = HeuristicRules["TIM.001"]
+		rule.Content += fmt.Sprintf(" 由业务写入的 TIMESTAMP 列: %s，如可能保存 2038 年以后或 1970 年以前的时间，或需要按写入时的时区原样保存，请改为 DATETIME。", strings.Join(cols, ", "))
+	}
+	return rule
+}
+
+// RuleDatetimeTimestampCompare TIM.002
+// 需要离线表结构才能判断列的类型
+func (q *Query4Audit) RuleDatetimeTimestampCompare() Rule {
+	var rule = q.RuleOK()
+	if len(offlineSchema) == 0 || q.Stmt == nil {
+		return rule
+	}
+	tables := offlineTableMap(q.Stmt)
+	if len(tables) == 0 {
+		return rule
+	}
+
+	var content []string
+	for _, cond := range ast.FindAllCondition(q.Stmt) {
+		node, ok := cond.(*sqlparser.ComparisonExpr)
+		if !ok {
+			continue
+		}
+		left, lok := node.Left.(*sqlparser.ColName)
+		right, rok := node.Right.(*sqlparser.ColName)
+		if !lok || !rok {
+			continue
+		}
+		l, r := offlineColumn(left, tables), offlineColumn(right, tables)
+		if l == nil || r == nil {
+			continue
+		}
+		lt, rt := timeTypeBase(l.DataType), timeTypeBase(r.DataType)
+		if (lt == "datetime" && rt == "timestamp") || (lt == "timestamp" && rt == "datetime") {
+			content = append(content, fmt.Sprintf("`%s`.`%s` (%s) VS `%s`.`%s` (%s): %s",
+				l.Table, l.Name, l.DataType, r.Table, r.Name, r.DataType, sqlparser.String(node)))
+		}
+	}
+	if len(content) > 0 {
+		rule = HeuristicRules["TIM.002"]
+		rule.Content = strings.Join(append([]string{rule.Content}, common.RemoveDuplicatesItem(content)...), " ")
+	}
+	return rule
+}
+
+// RuleMixedTimezone TIM.003
+// 同一条 SQL 中混用本地时间与 UTC 时间函数，有离线表结构时还检查 TIMESTAMP 列与 UTC 时间的比较
+func (q *Query4Audit) RuleMixedTimezone() Rule {
+	var rule = q.RuleOK()
+	if q.Stmt == nil {
+		return rule
+	}
+	var local, utc []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if f, ok := node.(*sqlparser.FuncExpr); ok {
+			if localTimeFuncs[f.Name.Lowered()] {
+				local = append(local, strings.ToUpper(f.Name.String()))
+			} else if utcTimeFuncs[f.Name.Lowered()] {
+				utc = append(utc, strings.ToUpper(f.Name.String()))
+			}
+		}
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+
+	var content []string
+	if len(local) > 0 && len(utc) > 0 {
+		content = append(content, fmt.Sprintf("同时使用了 %s 和 %s。",
+			strings.Join(common.RemoveDuplicatesItem(local), ", "), strings.Join(common.RemoveDuplicatesItem(utc), ", ")))
+	}
+
+	// TIMESTAMP 列按会话时区返回，与 UTC 时间比较只在 time_zone 为 UTC 时正确
+	if tables := offlineTableMap(q.Stmt); len(utc) > 0 && len(tables) > 0 {
+		for _, cond := range ast.FindAllCondition(q.Stmt) {
+			var col *sqlparser.ColName
+			var exprs []sqlparser.Expr
+			switch node := cond.(type) {
+			case *sqlparser.ComparisonExpr:
+				if c, ok := node.Left.(*sqlparser.ColName); ok {
+					col, exprs = c, []sqlparser.Expr{node.Right}
+				} else if c, ok := node.Right.(*sqlparser.ColName); ok {
+					col, exprs = c, []sqlparser.Expr{node.Left}
+				}
+			case *sqlparser.RangeCond:
+				if c, ok := node.Left.(*sqlparser.ColName); ok {
+					col, exprs = c, []sqlparser.Expr{node.From, node.To}
+				}
+			}
+			if col == nil {
+				continue
+			}
+			c := offlineColumn(col, tables)
+			if c == nil || timeTypeBase(c.DataType) != "timestamp" {
+				continue
+			}
+			for _, expr := range exprs {
+				if hasTimeFunc(expr, utcTimeFuncs) {
+					content = append(content, fmt.Sprintf("`%s`.`%s` 为 TIMESTAMP 类型，按会话时区返回，与 UTC 时间比较只在 time_zone 为 '+00:00' 时正确: %s",
+						c.Table, c.Name, sqlparser.String(cond.(sqlparser.SQLNode))))
+					break
+				}
+			}
+		}
+	}
+
+	if len(content) > 0 {
+		rule = HeuristicRules["TIM.003"]
+		rule.Content = strings.Join(append([]string{rule.Content}, common.RemoveDuplicatesItem(content)...), " ")
+	}
+	return rule
+}
+
+// hasTimeFunc 表达式中是否使用了 funcs 中的函数
+func hasTimeFunc(expr sqlparser.Expr, funcs map[string]bool) bool {
+	found := false
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if f, ok := node.(*sqlparser.FuncExpr); ok && funcs[f.Name.Lowered()] {
+			found = true
+		}
+		return !found, nil
+	}, expr)
+	common.LogIfError(err, "")
+	return found
+}
+
+// dateArithmetic 判断加减运算的一侧是否为日期字符串或时间类型的列，另一侧为数值，返回建议使用的 INTERVAL 单位
+func dateArithmetic(expr, other sqlparser.Expr, tables map[string]string) (string, bool) {
+	if _, ok := numberValue(other); !ok {
+		return "", false
+	}
+	switch e := expr.(type) {
+	case *sqlparser.SQLVal:
+		if e.Type != sqlparser.StrVal || !dateStringRe.MatchString(string(e.Val)) {
+			return "", false
+		}
+		if strings.ContainsAny(string(e.Val), " T") {
+			return "SECOND", true
+		}
+		return "DAY", true
+	case *sqlparser.ColName:
+		if len(tables) == 0 {
+			return "", false
+		}
+		c := offlineColumn(e, tables)
+		if c == nil {
+			return "", false
+		}
+		switch timeTypeBase(c.DataType) {
+		case "date":
+			return "DAY", true
+		case "datetime", "timestamp", "time":
+			return "SECOND", true
+		}
+	}
+	return "", false
+}
+
+// RuleDateArithmetic TIM.004
+// 日期字符串及时间类型的列直接与数值加减时按数值计算，结果不是日期
+func (q *Query4Audit) RuleDateArithmetic() Rule {
+	var rule = q.RuleOK()
+	if q.Stmt == nil {
+		return rule
+	}
+	var tables map[string]string
+	if len(offlineSchema) > 0 {
+		tables = offlineTableMap(q.Stmt)
+	}
+
+	var content []string
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		n, ok := node.(*sqlparser.BinaryExpr)
+		if !ok || (n.Operator != sqlparser.PlusStr && n.Operator != sqlparser.MinusStr) {
+			return true, nil
+		}
+		expr, other := n.Left, n.Right
+		unit, ok := dateArithmetic(expr, other, tables)
+		if !ok && n.Operator == sqlparser.PlusStr {
+			expr, other = n.Right, n.Left
+			unit, ok = dateArithmetic(expr, other, tables)
+		}
+		if !ok {
+			return true, nil
+		}
+		fun := "DATE_ADD"
+		if n.Operator == sqlparser.MinusStr {
+			fun = "DATE_SUB"
+		}
+		content = append(content, fmt.Sprintf("`%s` 可改写为 `%s(%s, INTERVAL %s %s)`，请确认时间单位。",
+			sqlparser.String(n), fun, sqlparser.String(expr), sqlparser.String(other), unit))
+		return true, nil
+	}, q.Stmt)
+	common.LogIfError(err, "")
+
+	if len(content) > 0 {
+		rule = HeuristicRules["TIM.004"]
+		rule.Content = strings.Join(append([]string{rule.Content}, common.RemoveDuplicatesItem(content)...), " ")
+	}
+	return rule
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// timezoneSchema 加载测试用的离线表结构，返回恢复原表结构的函数
+func timezoneSchema(t *testing.T) func() {
+	orgSchema, orgNames, orgFKs := offlineSchema, offlineColumnNames, offlineForeignKeys
+	offlineSchema = make(map[string]map[string]*common.Column)
+	offlineColumnNames = make(map[string][]string)
+	offlineForeignKeys = make(map[string][]foreignKey)
+	for _, sql := range []string{
+		"CREATE TABLE orders (id INT PRIMARY KEY, created_at DATETIME NOT NULL, updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, ship_date DATE)",
+		"CREATE TABLE payment (id INT PRIMARY KEY, order_id INT NOT NULL, paid_at TIMESTAMP NULL)",
+	} {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AddOfflineSchema(q)
+	}
+	return func() {
+		offlineSchema, offlineColumnNames, offlineForeignKeys = orgSchema, orgNames, orgFKs
+	}
+}
+
+// TIM.001
+func TestRuleTimestampRange(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"CREATE TABLE coupon (id INT PRIMARY KEY, expire_time TIMESTAMP NULL)":                                                      "expire_time",
+		"ALTER TABLE coupon ADD COLUMN start_time TIMESTAMP NOT NULL DEFAULT '2020-01-01 00:00:00', MODIFY end_time TIMESTAMP NULL": "start_time, end_time",
+		"CREATE TABLE t (id INT, c TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, u TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP)":     "",
+		"CREATE TABLE t (id INT, expire_time DATETIME NOT NULL)":                                                                    "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleTimestampRange()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "TIM.001" || !strings.Contains(rule.Content, "TIMESTAMP 列: "+want+"，") {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// TIM.002
+func TestRuleDatetimeTimestampCompare(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	defer timezoneSchema(t)()
+	sqls := map[string]string{
+		"SELECT o.id FROM orders o JOIN payment p ON o.id = p.order_id WHERE p.paid_at > o.created_at": "`payment`.`paid_at` (timestamp) VS `orders`.`created_at` (datetime): p.paid_at > o.created_at",
+		"SELECT id FROM orders WHERE created_at < updated_at":                                          "`orders`.`created_at` (datetime) VS `orders`.`updated_at` (timestamp): created_at < updated_at",
+		"SELECT o.id FROM orders o JOIN payment p ON o.id = p.order_id WHERE p.paid_at > o.updated_at": "",
+		"SELECT id FROM orders WHERE created_at > '2020-01-01'":                                        "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleDatetimeTimestampCompare()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "TIM.002" || !strings.HasSuffix(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// TIM.003
+func TestRuleMixedTimezone(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	defer timezoneSchema(t)()
+	sqls := map[string]string{
+		"SELECT id FROM orders WHERE created_at > UTC_TIMESTAMP() - INTERVAL 1 DAY AND ship_date < CURDATE()": "同时使用了 CURDATE 和 UTC_TIMESTAMP。",
+		"SELECT id FROM orders WHERE updated_at BETWEEN UTC_DATE() AND UTC_TIMESTAMP()":                       "`orders`.`updated_at` 为 TIMESTAMP 类型",
+		"SELECT id FROM orders WHERE created_at > UTC_TIMESTAMP() - INTERVAL 1 DAY":                           "",
+		"SELECT id FROM orders WHERE updated_at > NOW() - INTERVAL 1 DAY":                                     "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleMixedTimezone()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "TIM.003" || !strings.Contains(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// TIM.004
+func TestRuleDateArithmetic(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	defer timezoneSchema(t)()
+	sqls := map[string]string{
+		"SELECT id FROM orders WHERE created_at > '2020-01-01' + 1":              "`'2020-01-01' + 1` 可改写为 `DATE_ADD('2020-01-01', INTERVAL 1 DAY)`，请确认时间单位。",
+		"SELECT id FROM orders WHERE created_at > 7 + '2020-01-01 12:00:00'":     "`7 + '2020-01-01 12:00:00'` 可改写为 `DATE_ADD('2020-01-01 12:00:00', INTERVAL 7 SECOND)`，请确认时间单位。",
+		"SELECT ship_date - 3 FROM orders":                                       "`ship_date - 3` 可改写为 `DATE_SUB(ship_date, INTERVAL 3 DAY)`，请确认时间单位。",
+		"SELECT id FROM orders WHERE created_at - 86400 > updated_at":            "`created_at - 86400` 可改写为 `DATE_SUB(created_at, INTERVAL 86400 SECOND)`，请确认时间单位。",
+		"SELECT id FROM orders WHERE created_at > '2020-01-01' + INTERVAL 1 DAY": "",
+		"SELECT id + 1 FROM orders":                                              "",
+		"SELECT '1 - 2' - 1 FROM orders":                                         "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		rule := q.RuleDateArithmetic()
+		if want == "" {
+			if rule.Item != "OK" {
+				t.Errorf("SQL: %s, got: %s", sql, rule.Content)
+			}
+			continue
+		}
+		if rule.Item != "TIM.004" || !strings.HasSuffix(rule.Content, want) {
+			t.Errorf("SQL: %s, want: %s, got: %s", sql, want, rule.Content)
+		}
+	}
+
+	// 没有离线表结构时无法判断列的类型
+	orgSchema := offlineSchema
+	defer func() { offlineSchema = orgSchema }()
+	offlineSchema = make(map[string]map[string]*common.Column)
+	q, err := NewQuery4Audit("SELECT ship_date - 3 FROM orders")
+	if err != nil {
+		t.Error(err)
+	}
+	if rule := q.RuleDateArithmetic(); rule.Item != "OK" {
+		t.Errorf("got: %s", rule.Content)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
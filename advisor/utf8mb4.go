@@ -0,0 +1,388 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+// UTF8MB4Migration 对 OnlineDsn 中指定的库表给出 utf8 迁移至 utf8mb4 的完整方案
+// 包括受影响的表和列，索引长度超限问题，需要调整的行格式，以及按执行顺序排列的 ALTER 语句
+func UTF8MB4Migration(conn *database.Connector, databases ...string) map[string]Rule {
+	common.Log.Debug("Enter:  UTF8MB4Migration, Caller: %s", common.Caller())
+	// 复制一份online connector,防止环境切换影响其他功能的使用
+	tmpOnline := *conn
+	ruleMap := make(map[string]Rule)
+	number := 1
+
+	// 错误处理，用于汇总所有的错误
+	funcErrCheck := func(err error) {
+		if err != nil {
+			if sug, ok := ruleMap["ERR.003"]; ok {
+				sug.Content += fmt.Sprintf("; %s", err.Error())
+				ruleMap["ERR.003"] = sug
+			} else {
+				ruleMap["ERR.003"] = Rule{
+					Item:     "ERR.003",
+					Severity: "L8",
+					Content:  err.Error(),
+				}
+			}
+		}
+	}
+
+	// 不指定 DB 的时候检查 online dsn 中的 DB
+	if len(databases) == 0 {
+		databases = append(databases, tmpOnline.Database)
+	}
+
+	for _, db := range databases {
+		tmpOnline.Database = db
+
+		// 库的默认字符集只影响之后新建的表，放在迁移方案的最前面
+		collation, err := databaseCollation(&tmpOnline, db)
+		if err != nil {
+			funcErrCheck(err)
+			if !common.Config.DryRun {
+				return ruleMap
+			}
+		}
+		if isUTF8Collation(collation) {
+			key := fmt.Sprintf("MIG.%03d", number)
+			ruleMap[key] = Rule{
+				Item:     key,
+				Severity: "L2",
+				Summary:  fmt.Sprintf("%s 库默认字符集迁移至 utf8mb4", db),
+				Content:  fmt.Sprintf("库默认排序规则 %s 转换为 %s，只影响之后新建的表，已有的表需要逐个转换", collation, utf8mb4Collation(collation)),
+				Case:     fmt.Sprintf("ALTER DATABASE `%s` CHARACTER SET utf8mb4 COLLATE %s;", db, utf8mb4Collation(collation)),
+			}
+			number++
+		}
+
+		tables, err := tmpOnline.ShowTables()
+		if err != nil {
+			funcErrCheck(err)
+			if !common.Config.DryRun {
+				return ruleMap
+			}
+		}
+
+		for _, tb := range tables {
+			status, err := tmpOnline.ShowTableStatus(tb)
+			if err != nil {
+				funcErrCheck(err)
+				continue
+			}
+			desc, err := tmpOnline.ShowColumns(tb)
+			if err != nil {
+				funcErrCheck(err)
+				continue
+			}
+			idxInfo, err := tmpOnline.ShowIndex(tb)
+			if err != nil {
+				funcErrCheck(err)
+				continue
+			}
+
+			// 视图没有存储引擎，跟随基表迁移即可
+			if len(status.Rows) == 0 || len(status.Rows[0].Engine) == 0 {
+				continue
+			}
+			ts := status.Rows[0]
+			if rule, ok := utf8mb4TablePlan(db, tb, string(ts.Engine), string(ts.RowFormat), string(ts.Collation), desc, idxInfo); ok {
+				key := fmt.Sprintf("MIG.%03d", number)
+				rule.Item = key
+				ruleMap[key] = rule
+				number++
+			}
+		}
+	}
+
+	return ruleMap
+}
+
+// databaseCollation 获取库的默认排序规则
+func databaseCollation(conn *database.Connector, db string) (string, error) {
+	res, err := conn.Query(fmt.Sprintf("SELECT DEFAULT_COLLATION_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = '%s'", database.Escape(db, false)))
+	if err != nil {
+		return "", err
+	}
+	var collation string
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&collation)
+	}
+	res.Rows.Close()
+	return collation, err
+}
+
+// utf8mb4TablePlan 根据 SHOW TABLE STATUS, SHOW FULL COLUMNS, SHOW INDEX 的结果生成单表的迁移方案
+// 第二个返回值为 false 时表示该表无需迁移
+func utf8mb4TablePlan(db, tbName, engine, rowFormat, tbCollation string, desc *database.TableDesc, idxInfo *database.TableIndexInfo) (Rule, bool) {
+	if desc == nil {
+		return Rule{}, false
+	}
+	tb := fmt.Sprintf("`%s`.`%s`", db, tbName)
+
+	// 受影响的列，以及表中是否存在其他字符集的列
+	columns := make(map[string]database.TableDescValue)
+	var affected []database.TableDescValue
+	mixed := false
+	for _, col := range desc.DescValues {
+		columns[col.Field] = col
+		switch {
+		case isUTF8Collation(string(col.Collation)):
+			affected = append(affected, col)
+		case len(col.Collation) > 0:
+			mixed = true
+		}
+	}
+	if !isUTF8Collation(tbCollation) && len(affected) == 0 {
+		return Rule{}, false
+	}
+	// 表中所有字符串列都是 utf8 时可以直接 CONVERT TO 转换整张表，否则需要逐列转换
+	convert := isUTF8Collation(tbCollation) && !mixed
+
+	severity := "L2"
+	var content []string
+	if isUTF8Collation(tbCollation) {
+		content = append(content, fmt.Sprintf("表默认排序规则 %s 转换为 %s", tbCollation, utf8mb4Collation(tbCollation)))
+	}
+	if len(affected) > 0 {
+		var cols []string
+		for _, col := range affected {
+			cols = append(cols, fmt.Sprintf("%s(%s %s)", col.Field, col.Type, string(col.Collation)))
+		}
+		content = append(content, "需要转换的列："+strings.Join(cols, ", "))
+	}
+
+	// 转换后每个列的字符集
+	charsetOf := func(col database.TableDescValue) string {
+		if isUTF8Collation(string(col.Collation)) {
+			return "utf8mb4"
+		}
+		return collationCharset(string(col.Collation))
+	}
+
+	// 行长度检查，VARCHAR 列按字符计算长度，转换后占用的字节数可能超过 65535 字节的行长度限制
+	rowBytes := 0
+	for _, col := range desc.DescValues {
+		switch strings.ToLower(common.GetDataTypeBase(col.Type)) {
+		case "tinyblob", "tinytext", "blob", "text", "mediumblob", "mediumtext", "longblob", "longtext":
+			// 不定长字段在行内只保存指针
+			rowBytes += 12
+		default:
+			c := &common.Column{Name: col.Field, Table: tbName, DataType: col.Type, Character: charsetOf(col)}
+			if b := c.GetDataBytes(common.Config.OnlineDSN.Version); b > 0 {
+				rowBytes += b
+			}
+		}
+	}
+	if rowBytes > 65535 {
+		severity = "L4"
+		content = append(content, fmt.Sprintf("转换后行长度约 %d 字节，超过 65535 字节限制，需要先将部分 VARCHAR 列修改为 TEXT", rowBytes))
+	}
+
+	// TEXT 类型的列在 CONVERT TO 时会被提升为更大的类型，以保证能存储的字符数不变
+	for _, col := range affected {
+		switch strings.ToLower(common.GetDataTypeBase(col.Type)) {
+		case "tinytext", "text", "mediumtext":
+			if convert {
+				content = append(content, fmt.Sprintf("CONVERT TO 会将 %s 列的 %s 类型提升为更大的 TEXT 类型，如不需要可在转换后使用 MODIFY 改回", col.Field, col.Type))
+			}
+		}
+	}
+
+	// 索引长度检查，COMPACT, REDUNDANT 行格式下单列索引最大 767 字节，DYNAMIC, COMPRESSED 行格式下最大 3072 字节
+	// MyISAM 索引总长度限制为 1000 字节
+	maxIdxBytes := common.Config.MaxIdxBytes
+	if strings.EqualFold(engine, "MyISAM") {
+		maxIdxBytes = 1000
+	}
+	smallPrefix := strings.EqualFold(engine, "InnoDB") && (strings.EqualFold(rowFormat, "compact") || strings.EqualFold(rowFormat, "redundant"))
+	needDynamic := false
+	var idxSQLs []string
+	var keys []string
+	idxRows := make(map[string][]database.TableIndexRow)
+	if idxInfo != nil {
+		for _, row := range idxInfo.Rows {
+			if _, ok := idxRows[row.KeyName]; !ok {
+				keys = append(keys, row.KeyName)
+			}
+			idxRows[row.KeyName] = append(idxRows[row.KeyName], row)
+		}
+	}
+	for _, key := range keys {
+		rows := idxRows[key]
+		sort.Slice(rows, func(i, j int) bool { return rows[i].SeqInIndex < rows[j].SeqInIndex })
+		if strings.EqualFold(rows[0].IndexType, "FULLTEXT") {
+			continue
+		}
+
+		total, strCols, otherBytes := 0, 0, 0
+		touched := false
+		var colNames []string
+		for _, row := range rows {
+			col := columns[row.ColumnName]
+			bytes, chars := indexColumnBytes(col, row.SubPart, charsetOf(col))
+			if isUTF8Collation(string(col.Collation)) {
+				touched = true
+			}
+			if chars > 0 {
+				strCols++
+			} else {
+				otherBytes += bytes
+			}
+			if smallPrefix && bytes > common.Config.MaxIdxBytesPerColumn && isUTF8Collation(string(col.Collation)) {
+				needDynamic = true
+			}
+			total += bytes
+			colNames = append(colNames, row.ColumnName)
+		}
+		if !touched || total <= maxIdxBytes {
+			continue
+		}
+
+		// 改为 DYNAMIC 行格式后仍然超长，需要缩短为前缀索引
+		severity = "L4"
+		content = append(content, fmt.Sprintf("索引 %s(%s) 转换后长度 %d 字节，超过 %d 字节限制，需要改为前缀索引", key, strings.Join(colNames, ", "), total, maxIdxBytes))
+		maxChars := 0
+		if strCols > 0 {
+			maxChars = (maxIdxBytes - otherBytes) / strCols / 4
+		}
+		var defs []string
+		for _, row := range rows {
+			col := columns[row.ColumnName]
+			_, chars := indexColumnBytes(col, row.SubPart, charsetOf(col))
+			switch {
+			case chars > maxChars:
+				defs = append(defs, fmt.Sprintf("`%s`(%d)", row.ColumnName, maxChars))
+			case row.SubPart > 0:
+				defs = append(defs, fmt.Sprintf("`%s`(%d)", row.ColumnName, row.SubPart))
+			default:
+				defs = append(defs, fmt.Sprintf("`%s`", row.ColumnName))
+			}
+		}
+		switch {
+		case key == "PRIMARY":
+			idxSQLs = append(idxSQLs, fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY, ADD PRIMARY KEY (%s);", tb, strings.Join(defs, ", ")))
+		case rows[0].NonUnique == 0:
+			idxSQLs = append(idxSQLs, fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`, ADD UNIQUE INDEX `%s` (%s);", tb, key, key, strings.Join(defs, ", ")))
+		default:
+			idxSQLs = append(idxSQLs, fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`, ADD INDEX `%s` (%s);", tb, key, key, strings.Join(defs, ", ")))
+		}
+		if rows[0].NonUnique == 0 {
+			content = append(content, fmt.Sprintf("%s 为唯一索引，改为前缀索引后唯一性约束的语义会发生变化，请人工确认", key))
+		}
+	}
+	if needDynamic {
+		content = append(content, fmt.Sprintf("当前行格式为 %s，单列索引最大 %d 字节，需要先修改为 DYNAMIC 行格式（MySQL 5.6 需开启 innodb_large_prefix 并使用 Barracuda 文件格式）", rowFormat, common.Config.MaxIdxBytesPerColumn))
+	}
+
+	// 按执行顺序生成迁移语句：行格式 -> 缩短索引 -> 转换字符集
+	var stmts []string
+	if needDynamic {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ROW_FORMAT=DYNAMIC;", tb))
+	}
+	stmts = append(stmts, idxSQLs...)
+	target := utf8mb4Collation(tbCollation)
+	switch {
+	case convert:
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s CONVERT TO CHARACTER SET utf8mb4 COLLATE %s;", tb, target))
+	case isUTF8Collation(tbCollation):
+		// 存在其他字符集的列时 CONVERT TO 会一并转换，只修改表的默认字符集，然后逐列转换
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DEFAULT CHARACTER SET utf8mb4 COLLATE %s;", tb, target))
+	}
+	for _, col := range affected {
+		collation := utf8mb4Collation(string(col.Collation))
+		if convert && collation == target {
+			continue
+		}
+		stmts = append(stmts, alterColumnCharset(db, &common.Column{
+			Name:     col.Field,
+			Table:    tbName,
+			DataType: col.Type,
+			Null:     col.Null,
+			Default:  string(col.Default),
+			Comment:  col.Comment,
+		}, "utf8mb4", collation))
+	}
+
+	return Rule{
+		Severity: severity,
+		Summary:  fmt.Sprintf("%s.%s 迁移至 utf8mb4", db, tbName),
+		Content:  strings.Join(content, "; "),
+		Case:     strings.Join(stmts, "\n"),
+	}, true
+}
+
+// indexColumnBytes 计算列在索引中占用的字节数，字符串类型同时返回字符数
+func indexColumnBytes(col database.TableDescValue, subPart int, charset string) (bytes int, chars int) {
+	switch strings.ToLower(common.GetDataTypeBase(col.Type)) {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		chars = subPart
+		if chars == 0 {
+			chars = common.GetDataTypeLength(col.Type)[0]
+		}
+		if chars < 0 {
+			chars = 0
+		}
+		bytesPerChar := 1
+		if n, ok := common.CharSets[strings.ToLower(charset)]; ok {
+			bytesPerChar = n
+		}
+		return chars * bytesPerChar, chars
+	}
+	if subPart > 0 {
+		return subPart, 0
+	}
+	c := &common.Column{Name: col.Field, DataType: col.Type}
+	if bytes = c.GetDataBytes(common.Config.OnlineDSN.Version); bytes < 0 {
+		bytes = 0
+	}
+	return bytes, 0
+}
+
+// isUTF8Collation 判断排序规则是否属于 utf8(utf8mb3) 字符集
+func isUTF8Collation(collation string) bool {
+	collation = strings.ToLower(collation)
+	return strings.HasPrefix(collation, "utf8_") || strings.HasPrefix(collation, "utf8mb3_")
+}
+
+// collationCharset 由排序规则得到字符集，如 latin1_swedish_ci -> latin1
+func collationCharset(collation string) string {
+	return strings.SplitN(strings.ToLower(collation), "_", 2)[0]
+}
+
+// utf8mb4Collation 获取 utf8 排序规则在 utf8mb4 中对应的排序规则
+func utf8mb4Collation(collation string) string {
+	collation = strings.ToLower(collation)
+	switch {
+	case collation == "utf8_general_mysql500_ci", collation == "utf8mb3_general_mysql500_ci":
+		// utf8mb4 中没有对应的排序规则
+		return "utf8mb4_general_ci"
+	case strings.HasPrefix(collation, "utf8_"):
+		return "utf8mb4_" + strings.TrimPrefix(collation, "utf8_")
+	case strings.HasPrefix(collation, "utf8mb3_"):
+		return "utf8mb4_" + strings.TrimPrefix(collation, "utf8mb3_")
+	}
+	return "utf8mb4_general_ci"
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+func TestUTF8MB4TablePlan(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	desc := &database.TableDesc{
+		Name: "film",
+		DescValues: []database.TableDescValue{
+			{Field: "id", Type: "int(11)", Null: "NO"},
+			{Field: "title", Type: "varchar(255)", Collation: []byte("utf8_general_ci"), Null: "NO"},
+			{Field: "code", Type: "varchar(1000)", Collation: []byte("utf8_bin"), Null: "YES"},
+			{Field: "description", Type: "text", Collation: []byte("utf8_general_ci"), Null: "YES"},
+		},
+	}
+	idx := &database.TableIndexInfo{
+		TableName: "film",
+		Rows: []database.TableIndexRow{
+			{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id", IndexType: "BTREE"},
+			{KeyName: "idx_title", NonUnique: 1, SeqInIndex: 1, ColumnName: "title", IndexType: "BTREE"},
+			{KeyName: "idx_code", NonUnique: 1, SeqInIndex: 1, ColumnName: "code", IndexType: "BTREE"},
+		},
+	}
+
+	rule, ok := utf8mb4TablePlan("sakila", "film", "InnoDB", "Compact", "utf8_general_ci", desc, idx)
+	if !ok {
+		t.Fatal("film should be migrated")
+	}
+	want := strings.Join([]string{
+		"ALTER TABLE `sakila`.`film` ROW_FORMAT=DYNAMIC;",
+		"ALTER TABLE `sakila`.`film` DROP INDEX `idx_code`, ADD INDEX `idx_code` (`code`(768));",
+		"ALTER TABLE `sakila`.`film` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;",
+		"ALTER TABLE `sakila`.`film` MODIFY `code` varchar(1000) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;",
+	}, "\n")
+	if rule.Case != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, rule.Case)
+	}
+	if rule.Severity != "L4" {
+		t.Errorf("want severity L4, got %s", rule.Severity)
+	}
+
+	// 表中还有 latin1 的列时不能 CONVERT TO
+	desc.DescValues = append(desc.DescValues, database.TableDescValue{Field: "note", Type: "varchar(10)", Collation: []byte("latin1_swedish_ci"), Null: "YES"})
+	rule, _ = utf8mb4TablePlan("sakila", "film", "InnoDB", "Dynamic", "utf8_general_ci", desc, nil)
+	if strings.Contains(rule.Case, "CONVERT TO") || !strings.Contains(rule.Case, "DEFAULT CHARACTER SET utf8mb4") {
+		t.Errorf("unexpected plan:\n%s", rule.Case)
+	}
+
+	// 无需迁移
+	desc = &database.TableDesc{
+		Name: "actor",
+		DescValues: []database.TableDescValue{
+			{Field: "name", Type: "varchar(45)", Collation: []byte("utf8mb4_general_ci")},
+		},
+	}
+	if _, ok := utf8mb4TablePlan("sakila", "actor", "InnoDB", "Dynamic", "utf8mb4_general_ci", desc, nil); ok {
+		t.Error("actor should not be migrated")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestUTF8MB4Collation(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]string{
+		"utf8_general_ci":          "utf8mb4_general_ci",
+		"utf8_bin":                 "utf8mb4_bin",
+		"utf8mb3_unicode_ci":       "utf8mb4_unicode_ci",
+		"utf8_general_mysql500_ci": "utf8mb4_general_ci",
+	}
+	for collation, want := range cases {
+		if got := utf8mb4Collation(collation); got != want {
+			t.Errorf("%s want: %s, got: %s", collation, want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,389 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tidb "github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/opcode"
+)
+
+// windowFunc 查询块中的一个窗口函数，spec 为展开命名窗口引用后的窗口定义
+type windowFunc struct {
+	expr *tidb.WindowFuncExpr
+	spec tidb.WindowSpec
+}
+
+// selectCollector 收集 SQL 中所有的 SELECT 查询块，包括子查询
+type selectCollector struct {
+	selects []*tidb.SelectStmt
+}
+
+func (v *selectCollector) Enter(in tidb.Node) (tidb.Node, bool) {
+	if sel, ok := in.(*tidb.SelectStmt); ok {
+		v.selects = append(v.selects, sel)
+	}
+	return in, false
+}
+
+func (v *selectCollector) Leave(in tidb.Node) (tidb.Node, bool) {
+	return in, true
+}
+
+// windowCollector 收集查询块中的窗口函数，不进入子查询
+type windowCollector struct {
+	funcs []*tidb.WindowFuncExpr
+}
+
+func (v *windowCollector) Enter(in tidb.Node) (tidb.Node, bool) {
+	switch n := in.(type) {
+	case *tidb.WindowFuncExpr:
+		v.funcs = append(v.funcs, n)
+	case *tidb.SubqueryExpr:
+		return in, true
+	}
+	return in, false
+}
+
+func (v *windowCollector) Leave(in tidb.Node) (tidb.Node, bool) {
+	return in, true
+}
+
+// windowSelects 返回 SQL 中使用了窗口函数的查询块及其中的窗口函数
+func (q *Query4Audit) windowSelects() map[*tidb.SelectStmt][]windowFunc {
+	windows := make(map[*tidb.SelectStmt][]windowFunc)
+	for _, stmt := range q.TiStmt {
+		sc := &selectCollector{}
+		stmt.Accept(sc)
+		for _, sel := range sc.selects {
+			wc := &windowCollector{}
+			if sel.Fields != nil {
+				sel.Fields.Accept(wc)
+			}
+			if sel.OrderBy != nil {
+				sel.OrderBy.Accept(wc)
+			}
+			named := make(map[string]tidb.WindowSpec)
+			for _, spec := range sel.WindowSpecs {
+				named[spec.Name.L] = spec
+			}
+			for _, f := range wc.funcs {
+				windows[sel] = append(windows[sel], windowFunc{expr: f, spec: resolveWindowSpec(f.Spec, named, 0)})
+			}
+		}
+	}
+	return windows
+}
+
+// resolveWindowSpec 展开 OVER w, OVER (w ORDER BY ...) 中引用的命名窗口
+func resolveWindowSpec(spec tidb.WindowSpec, named map[string]tidb.WindowSpec, depth int) tidb.WindowSpec {
+	ref := spec.Ref.L
+	if spec.OnlyAlias {
+		ref = spec.Name.L
+	}
+	base, ok := named[ref]
+	if ref == "" || !ok || depth > len(named) {
+		return spec
+	}
+	base = resolveWindowSpec(base, named, depth+1)
+	if spec.OnlyAlias {
+		return base
+	}
+	if spec.PartitionBy == nil {
+		spec.PartitionBy = base.PartitionBy
+	}
+	if spec.OrderBy == nil {
+		spec.OrderBy = base.OrderBy
+	}
+	if spec.Frame == nil {
+		spec.Frame = base.Frame
+	}
+	return spec
+}
+
+// windowSortKey 窗口需要的排序，即 PARTITION BY 与 ORDER BY 的组合，不需要排序时返回空
+func windowSortKey(spec tidb.WindowSpec) string {
+	var keys []string
+	if spec.PartitionBy != nil {
+		if s, err := restoreNode(spec.PartitionBy); err == nil {
+			keys = append(keys, s)
+		}
+	}
+	if spec.OrderBy != nil {
+		if s, err := restoreNode(spec.OrderBy); err == nil {
+			keys = append(keys, s)
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+// RuleWindowWithoutIndex WIN.001
+// 单表查询中窗口的 PARTITION BY, ORDER BY 都是列时，根据离线表结构检查是否有按该顺序排列的索引
+func (q *Query4Audit) RuleWindowWithoutIndex() Rule {
+	var rule = q.RuleOK()
+	var fixes []string
+	for sel, funcs := range q.windowSelects() {
+		tb := singleTableName(sel)
+		indexes, ok := offlineIndexes[tb]
+		if _, known := offlineSchema[tb]; !ok || !known {
+			continue
+		}
+		eq := whereEqualColumns(sel.Where)
+		checked := make(map[string]bool)
+		for _, f := range funcs {
+			partition, order, ok := windowColumns(f.spec)
+			if !ok || len(partition)+len(order) == 0 {
+				continue
+			}
+			cols := append(append([]string{}, partition...), order...)
+			key := strings.Join(cols, ",")
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+			if windowIndexed(indexes, eq, partition, order) {
+				continue
+			}
+			name := "idx_" + strings.Join(cols, "_")
+			if len(name) > IndexNameMaxLength {
+				name = strings.TrimRight(name[:IndexNameMaxLength], "_")
+			}
+			fixes = append(fixes, fmt.Sprintf("`%s` 表上没有按 (%s) 排列的索引，建议添加：ALTER TABLE `%s` ADD INDEX `%s` (`%s`);",
+				tb, strings.Join(cols, ", "), tb, name, strings.Join(cols, "`, `")))
+		}
+	}
+	if len(fixes) > 0 {
+		sort.Strings(fixes)
+		rule = HeuristicRules["WIN.001"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// singleTableName 单表查询时返回小写的表名，否则返回空
+func singleTableName(sel *tidb.SelectStmt) string {
+	if sel.From == nil || sel.From.TableRefs == nil || sel.From.TableRefs.Right != nil {
+		return ""
+	}
+	ts, ok := sel.From.TableRefs.Left.(*tidb.TableSource)
+	if !ok {
+		return ""
+	}
+	if tn, ok := ts.Source.(*tidb.TableName); ok {
+		return tn.Name.L
+	}
+	return ""
+}
+
+// whereEqualColumns WHERE 中与常量等值比较的列，这些列可以出现在索引的最左侧
+func whereEqualColumns(where tidb.ExprNode) map[string]bool {
+	cols := make(map[string]bool)
+	var walk func(expr tidb.ExprNode)
+	walk = func(expr tidb.ExprNode) {
+		switch n := expr.(type) {
+		case *tidb.ParenthesesExpr:
+			walk(n.Expr)
+		case *tidb.BinaryOperationExpr:
+			switch n.Op {
+			case opcode.LogicAnd:
+				walk(n.L)
+				walk(n.R)
+			case opcode.EQ:
+				col, ok := n.L.(*tidb.ColumnNameExpr)
+				_, isValue := n.R.(tidb.ValueExpr)
+				if !ok {
+					col, ok = n.R.(*tidb.ColumnNameExpr)
+					_, isValue = n.L.(tidb.ValueExpr)
+				}
+				if ok && isValue {
+					cols[col.Name.Name.L] = true
+				}
+			}
+		}
+	}
+	if where != nil {
+		walk(where)
+	}
+	return cols
+}
+
+// windowColumns 返回窗口 PARTITION BY, ORDER BY 中的列名，存在表达式或 ORDER BY 方向不一致时无法使用索引
+func windowColumns(spec tidb.WindowSpec) (partition, order []string, ok bool) {
+	if spec.PartitionBy != nil {
+		for _, item := range spec.PartitionBy.Items {
+			col, isCol := item.Expr.(*tidb.ColumnNameExpr)
+			if !isCol {
+				return nil, nil, false
+			}
+			partition = append(partition, col.Name.Name.L)
+		}
+	}
+	if spec.OrderBy != nil {
+		for _, item := range spec.OrderBy.Items {
+			col, isCol := item.Expr.(*tidb.ColumnNameExpr)
+			if !isCol || item.Desc != spec.OrderBy.Items[0].Desc {
+				return nil, nil, false
+			}
+			order = append(order, col.Name.Name.L)
+		}
+	}
+	return partition, order, true
+}
+
+// windowIndexed 判断是否有索引可以按窗口的顺序读取数据：跳过最左侧的等值条件列后，依次为 PARTITION BY 的列（顺序不限）及 ORDER BY 的列
+func windowIndexed(indexes [][]string, eq map[string]bool, partition, order []string) bool {
+	for _, idx := range indexes {
+		i := 0
+		for i < len(idx) && eq[idx[i]] && !containsString(partition, idx[i]) && !containsString(order, idx[i]) {
+			i++
+		}
+		if len(idx)-i < len(partition)+len(order) {
+			continue
+		}
+		matched := true
+		for _, col := range idx[i : i+len(partition)] {
+			if !containsString(partition, col) {
+				matched = false
+			}
+		}
+		for j, col := range order {
+			if idx[i+len(partition)+j] != col {
+				matched = false
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString 判断 list 中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleWindowUnboundedFrame WIN.002
+// 窗口范围以 UNBOUNDED FOLLOWING 结束时需要读取整个分区后才能计算第一行
+func (q *Query4Audit) RuleWindowUnboundedFrame() Rule {
+	var rule = q.RuleOK()
+	var fixes []string
+	for _, funcs := range q.windowSelects() {
+		for _, f := range funcs {
+			frame := f.spec.Frame
+			if frame == nil || !frame.Extent.End.UnBounded || frame.Extent.End.Type != tidb.Following {
+				continue
+			}
+			expr, err := restoreNode(f.expr)
+			if err != nil {
+				continue
+			}
+			fix := fmt.Sprintf("`%s` 的窗口范围为 %s。", expr, frameText(frame))
+			if f.spec.PartitionBy == nil {
+				fix = fmt.Sprintf("`%s` 的窗口范围为 %s，且没有 PARTITION BY，整个结果集为一个分区。", expr, frameText(frame))
+			}
+			fixes = append(fixes, fix)
+		}
+	}
+	if len(fixes) > 0 {
+		sort.Strings(fixes)
+		rule = HeuristicRules["WIN.002"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// frameText 窗口范围的 SQL 表示
+func frameText(frame *tidb.FrameClause) string {
+	s, err := restoreNode(frame)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// RuleWindowMultipleSorts WIN.003
+// 同一查询块中的窗口按不同的 PARTITION BY, ORDER BY 排序时，MySQL 需要对结果分别排序
+func (q *Query4Audit) RuleWindowMultipleSorts() Rule {
+	var rule = q.RuleOK()
+	var fixes []string
+	for _, funcs := range q.windowSelects() {
+		var keys []string
+		seen := make(map[string]bool)
+		for _, f := range funcs {
+			key := windowSortKey(f.spec)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		if len(keys) > 1 {
+			fixes = append(fixes, fmt.Sprintf("查询中的窗口需要按 %d 种不同的顺序排序：%s。", len(keys), strings.Join(keys, "；")))
+		}
+	}
+	if len(fixes) > 0 {
+		sort.Strings(fixes)
+		rule = HeuristicRules["WIN.003"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
+
+// RuleWindowNamed WIN.004
+// 相同的窗口定义在 OVER 子句中重复出现时，建议使用 WINDOW 子句定义命名窗口
+func (q *Query4Audit) RuleWindowNamed() Rule {
+	var rule = q.RuleOK()
+	var fixes []string
+	for _, funcs := range q.windowSelects() {
+		var specs []string
+		count := make(map[string]int)
+		for _, f := range funcs {
+			// 只统计直接写在 OVER 中的窗口
+			if f.expr.Spec.OnlyAlias || f.expr.Spec.Ref.L != "" {
+				continue
+			}
+			spec, err := restoreNode(&f.expr.Spec)
+			if err != nil || spec == "()" {
+				continue
+			}
+			if count[spec] == 0 {
+				specs = append(specs, spec)
+			}
+			count[spec]++
+		}
+		for _, spec := range specs {
+			if count[spec] > 1 {
+				fixes = append(fixes, fmt.Sprintf("窗口 %s 重复定义了 %d 次，可以改写为 WINDOW w AS %s，并在窗口函数中使用 OVER w。", spec, count[spec], spec))
+			}
+		}
+	}
+	if len(fixes) > 0 {
+		sort.Strings(fixes)
+		rule = HeuristicRules["WIN.004"]
+		rule.Content = strings.Join(append([]string{rule.Content}, fixes...), " ")
+	}
+	return rule
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// WIN.001
+func TestRuleWindowWithoutIndex(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgSchema, orgNames, orgFKs, orgIndexes := offlineSchema, offlineColumnNames, offlineForeignKeys, offlineIndexes
+	defer func() {
+		offlineSchema, offlineColumnNames, offlineForeignKeys, offlineIndexes = orgSchema, orgNames, orgFKs, orgIndexes
+	}()
+	offlineSchema = make(map[string]map[string]*common.Column)
+	offlineColumnNames = make(map[string][]string)
+	offlineForeignKeys = make(map[string][]foreignKey)
+	offlineIndexes = make(map[string][][]string)
+	q, err := NewQuery4Audit(`CREATE TABLE payment (payment_id INT PRIMARY KEY, customer_id INT, staff_id INT, amount DECIMAL(5,2),
+		payment_date DATETIME, KEY idx_staff_customer_date (staff_id, customer_id, payment_date))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddOfflineSchema(q)
+
+	sqls := map[string]string{
+		"SELECT payment_id, ROW_NUMBER() OVER (PARTITION BY customer_id ORDER BY payment_date) AS rn FROM payment": "`payment` 表上没有按 (customer_id, payment_date) 排列的索引，建议添加：ALTER TABLE `payment` ADD INDEX `idx_customer_id_payment_date` (`customer_id`, `payment_date`);",
+		"SELECT payment_id, SUM(amount) OVER w FROM payment WINDOW w AS (ORDER BY amount DESC)":                    "`payment` 表上没有按 (amount) 排列的索引",
+		// 跳过 WHERE 中的等值条件列后可以使用索引
+		"SELECT payment_id, ROW_NUMBER() OVER (PARTITION BY customer_id ORDER BY payment_date) AS rn FROM payment WHERE staff_id = 1": "",
+		"SELECT payment_id, RANK() OVER (ORDER BY payment_id) FROM payment":                                                           "",
+		"SELECT payment_id, RANK() OVER (ORDER BY amount + 1) FROM payment":                                                           "",
+		"SELECT p.payment_id, RANK() OVER (ORDER BY p.amount) FROM payment p JOIN customer c USING (customer_id)":                     "",
+		"SELECT id, RANK() OVER (ORDER BY amount) FROM unknown":                                                                       "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := q.RuleWindowWithoutIndex()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "WIN.001" || !strings.Contains(rule.Content, want)) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// WIN.002
+func TestRuleWindowUnboundedFrame(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"SELECT id, SUM(amount) OVER (PARTITION BY customer_id ORDER BY payment_date ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS total FROM payment": "ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING。",
+		"SELECT id, LAST_VALUE(amount) OVER w FROM payment WINDOW w AS (ORDER BY id RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING)":                               "且没有 PARTITION BY，整个结果集为一个分区。",
+		"SELECT id, SUM(amount) OVER (PARTITION BY customer_id ORDER BY payment_date) AS total FROM payment":                                                          "",
+		"SELECT id, AVG(amount) OVER (ORDER BY id ROWS BETWEEN 2 PRECEDING AND 2 FOLLOWING) FROM payment":                                                             "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := q.RuleWindowUnboundedFrame()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "WIN.002" || !strings.Contains(rule.Content, want)) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// WIN.003
+func TestRuleWindowMultipleSorts(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"SELECT id, ROW_NUMBER() OVER (PARTITION BY customer_id ORDER BY payment_date) AS rn, RANK() OVER (ORDER BY amount DESC) AS rk FROM payment": "查询中的窗口需要按 2 种不同的顺序排序",
+		// 命名窗口展开后排序方式相同
+		"SELECT id, ROW_NUMBER() OVER w, SUM(amount) OVER (w ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) FROM payment WINDOW w AS (PARTITION BY customer_id ORDER BY payment_date)": "",
+		"SELECT id, SUM(amount) OVER (), COUNT(*) OVER (ORDER BY id) FROM payment":                                                                                                "",
+		// 子查询中的窗口单独计算
+		"SELECT * FROM (SELECT id, RANK() OVER (ORDER BY amount) rk FROM payment) t WHERE rk IN (SELECT ROW_NUMBER() OVER (ORDER BY id) FROM customer)": "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := q.RuleWindowMultipleSorts()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "WIN.003" || !strings.Contains(rule.Content, want)) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+// WIN.004
+func TestRuleWindowNamed(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := map[string]string{
+		"SELECT SUM(amount) OVER (PARTITION BY customer_id ORDER BY payment_date) AS total, AVG(amount) OVER (PARTITION BY customer_id ORDER BY payment_date) AS average FROM payment": "重复定义了 2 次，可以改写为 WINDOW w AS (PARTITION BY `customer_id` ORDER BY `payment_date`)",
+		"SELECT SUM(amount) OVER w AS total, AVG(amount) OVER w AS average FROM payment WINDOW w AS (PARTITION BY customer_id ORDER BY payment_date)":                                  "",
+		"SELECT SUM(amount) OVER (), AVG(amount) OVER () FROM payment": "",
+	}
+	for sql, want := range sqls {
+		q, err := NewQuery4Audit(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := q.RuleWindowNamed()
+		if want == "" && rule.Item != "OK" || want != "" && (rule.Item != "WIN.004" || !strings.Contains(rule.Content, want)) {
+			t.Errorf("SQL: %s\nwant: %s\ngot: %s %s", sql, want, rule.Item, rule.Content)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/percona/go-mysql/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// workloadDigitRe 分库分表、按日期拆分的表名中的数字，如 order_01, log_20190101
+var workloadDigitRe = regexp.MustCompile(`\d+`)
+
+// WorkloadCluster 结构相似的一组 SQL
+// 相同语句类型、访问相同的表（忽略分表后缀中的数字）、WHERE 条件使用相同列的 SQL 归为一类，
+// 即使 SELECT 的列、IN 列表长度、表别名不同导致指纹不同，通常也来自同一个代码模板
+type WorkloadCluster struct {
+	ID           string         `json:"ID"`
+	Type         string         `json:"Type"`
+	Tables       []string       `json:"Tables"`
+	Columns      []string       `json:"Columns"`
+	Queries      int            `json:"Queries"`      // SQL 条数，相同 SQL 多次出现时重复计数，反映负载
+	Fingerprints []string       `json:"Fingerprints"` // 聚类中不同的指纹
+	Sample       string         `json:"Sample"`
+	Rules        map[string]int `json:"Rules"`  // 建议 Item 及其出现的 SQL 条数
+	Weight       int            `json:"Weight"` // 所有 SQL 建议的 Severity 之和
+}
+
+// Workload 批量 SQL 的聚类及反模式统计，对应 -report-type workload
+type Workload struct {
+	Clusters map[string]*WorkloadCluster
+	Queries  int
+	rules    map[string]map[string]int // 建议 Item -> 聚类 ID -> SQL 条数
+	ruleInfo map[string]Rule
+	fulltext map[string]*FulltextCandidate // 表名.列名 -> 该列上的 LIKE '%word%' 查询
+}
+
+// NewWorkload 初始化 Workload
+func NewWorkload() *Workload {
+	return &Workload{
+		Clusters: make(map[string]*WorkloadCluster),
+		rules:    make(map[string]map[string]int),
+		ruleInfo: make(map[string]Rule),
+		fulltext: make(map[string]*FulltextCandidate),
+	}
+}
+
+// workloadKey 计算 SQL 的结构特征，返回语句类型、表、WHERE 条件中的列
+func workloadKey(sql string, tables []string) (string, []string, []string) {
+	typ := ast.QueryType(sql)
+
+	var tbs []string
+	for _, tb := range tables {
+		tbs = append(tbs, workloadDigitRe.ReplaceAllString(strings.Replace(tb, "`", "", -1), "?"))
+	}
+	tbs = common.RemoveDuplicatesItem(tbs)
+	sort.Strings(tbs)
+
+	var cols []string
+	if stmt, err := sqlparser.Parse(sql); err == nil {
+		for _, col := range append(ast.FindWhereEQ(stmt), ast.FindWhereINEQ(stmt)...) {
+			cols = append(cols, strings.ToLower(col.Name))
+		}
+	}
+	cols = common.RemoveDuplicatesItem(cols)
+	sort.Strings(cols)
+	return typ, tbs, cols
+}
+
+// Add 将一条 SQL 及其建议加入对应的聚类，相同 SQL 多次出现时需要多次 Add
+func (w *Workload) Add(sql string, tables []string, suggest map[string]Rule) {
+	typ, tbs, cols := workloadKey(sql, tables)
+	id := query.Id(typ + "|" + strings.Join(tbs, ",") + "|" + strings.Join(cols, ","))
+	c, ok := w.Clusters[id]
+	if !ok {
+		c = &WorkloadCluster{
+			ID:      id,
+			Type:    typ,
+			Tables:  tbs,
+			Columns: cols,
+			Sample:  sql,
+			Rules:   make(map[string]int),
+		}
+		w.Clusters[id] = c
+	}
+	w.Queries++
+	c.Queries++
+	fingerprint := Fingerprint(sql)
+	found := false
+	for _, fp := range c.Fingerprints {
+		if fp == fingerprint {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.Fingerprints = append(c.Fingerprints, fingerprint)
+	}
+	w.addFulltext(sql)
+
+	for item, rule := range suggest {
+		if !isFindingItem(item) {
+			continue
+		}
+		c.Rules[item]++
+		c.Weight += severityLevel(rule.Severity)
+		if w.rules[item] == nil {
+			w.rules[item] = make(map[string]int)
+			w.ruleInfo[item] = rule
+		}
+		w.rules[item][id]++
+	}
+}
+
+// sortedClusters 按建议权重、SQL 条数从高到低排序，修复排在前面的模板收益最大
+func (w *Workload) sortedClusters() []*WorkloadCluster {
+	var clusters []*WorkloadCluster
+	for _, c := range w.Clusters {
+		clusters = append(clusters, c)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Weight != clusters[j].Weight {
+			return clusters[i].Weight > clusters[j].Weight
+		}
+		if clusters[i].Queries != clusters[j].Queries {
+			return clusters[i].Queries > clusters[j].Queries
+		}
+		return clusters[i].ID < clusters[j].ID
+	})
+	return clusters
+}
+
+// topRules 聚类中出现次数最多的建议
+func (c *WorkloadCluster) topRules() string {
+	items := common.SortedKey(c.Rules)
+	sort.SliceStable(items, func(i, j int) bool {
+		return c.Rules[items[i]] > c.Rules[items[j]]
+	})
+	var buf []string
+	for _, item := range items {
+		buf = append(buf, fmt.Sprintf("%s(%d)", item, c.Rules[item]))
+	}
+	return strings.Join(buf, ", ")
+}
+
+// Format 以 markdown 格式输出聚类及反模式统计
+func (w *Workload) Format() string {
+	var fingerprints int
+	clusters := w.sortedClusters()
+	for _, c := range clusters {
+		fingerprints += len(c.Fingerprints)
+	}
+
+	buf := []string{
+		"# Workload",
+		"",
+		fmt.Sprintf("共 %d 条 SQL，%d 个指纹，按结构相似度归为 %d 类。", w.Queries, fingerprints, len(clusters)),
+		"",
+		"## 聚类",
+		"",
+		"| Cluster | Type | Tables | SQL 条数 | 指纹数 | 权重 | 主要建议 |",
+		"|---|---|---|---|---|---|---|",
+	}
+	for _, c := range clusters {
+		buf = append(buf, fmt.Sprintf("| %s | %s | %s | %d | %d | %d | %s |",
+			c.ID, c.Type, common.MarkdownEscape(strings.Join(c.Tables, ", ")),
+			c.Queries, len(c.Fingerprints), c.Weight, c.topRules()))
+	}
+
+	for _, c := range clusters {
+		if len(c.Rules) == 0 {
+			continue
+		}
+		buf = append(buf, "", fmt.Sprintf("### Cluster: %s", c.ID), "")
+		if len(c.Columns) > 0 {
+			buf = append(buf, fmt.Sprintf("* **WHERE 列:** %s", common.MarkdownEscape(strings.Join(c.Columns, ", "))))
+		}
+		buf = append(buf, fmt.Sprintf("* **建议:** %s", c.topRules()), "", "```sql", c.Sample, "```")
+	}
+
+	buf = append(buf, w.formatFulltext()...)
+
+	buf = append(buf, "", "## 反模式统计", "",
+		"| Item | Severity | Summary | SQL 条数 | 聚类数 |",
+		"|---|---|---|---|---|")
+	items := common.SortedKey(w.rules)
+	count := func(item string) int {
+		var n int
+		for _, c := range w.rules[item] {
+			n += c
+		}
+		return n
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return count(items[i]) > count(items[j])
+	})
+	for _, item := range items {
+		rule := w.ruleInfo[item]
+		buf = append(buf, fmt.Sprintf("| %s | %s | %s | %d | %d |",
+			item, rule.Severity, common.MarkdownEscape(rule.Summary), count(item), len(w.rules[item])))
+	}
+	return strings.Join(buf, "\n")
+}
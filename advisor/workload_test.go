@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestWorkload(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := []struct {
+		sql    string
+		tables []string
+	}{
+		{"select * from order_01 where uid = 1 and status in (1, 2)", []string{"`shop`.`order_01`"}},
+		{"select id, amount from order_02 o where o.uid = 3 and o.status in (1)", []string{"`shop`.`order_02`"}},
+		{"select * from order_01 where uid = 1 and status in (1, 2)", []string{"`shop`.`order_01`"}},
+		{"select * from film where title = 'a'", []string{"`sakila`.`film`"}},
+	}
+	w := NewWorkload()
+	for _, s := range sqls {
+		w.Add(s.sql, s.tables, map[string]Rule{
+			"COL.001": HeuristicRules["COL.001"],
+			"EXP.000": {Item: "EXP.000"},
+		})
+	}
+	if w.Queries != 4 || len(w.Clusters) != 2 {
+		t.Fatalf("want 4 queries in 2 clusters, got %d queries in %d clusters", w.Queries, len(w.Clusters))
+	}
+	top := w.sortedClusters()[0]
+	if top.Queries != 3 || len(top.Fingerprints) != 2 || top.Rules["COL.001"] != 3 ||
+		strings.Join(top.Tables, ",") != "shop.order_?" || strings.Join(top.Columns, ",") != "status,uid" {
+		t.Errorf("top cluster got %+v", top)
+	}
+	report := w.Format()
+	if !strings.Contains(report, "共 4 条 SQL，3 个指纹，按结构相似度归为 2 类。") ||
+		!strings.Contains(report, "| COL.001 | L1 |") || strings.Contains(report, "EXP.000") {
+		t.Errorf("workload report got:\n%s", report)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestWorkloadFulltext(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTarget := common.Config.Target
+	defer func() { common.Config.Target = orgTarget }()
+	common.Config.Target = "mysql:8.0"
+
+	w := NewWorkload()
+	for _, sql := range []string{
+		"select * from film where title like '%love%'",
+		"select film_id from film f where f.title like '%dog%' and f.title like '%cat%'",
+		"select * from film where title like '%love%'",
+		"select * from film where description like '%爱情%'",
+		"select * from film where description like 'abc%'",
+	} {
+		w.Add(sql, []string{"`sakila`.`film`"}, nil)
+	}
+	candidates := w.FulltextCandidates()
+	if len(candidates) != 1 || candidates[0].Column != "title" || candidates[0].Queries != 3 ||
+		strings.Join(candidates[0].Words, ",") != "love,dog,cat" {
+		t.Fatalf("FulltextCandidates got: %+v", candidates)
+	}
+	c := candidates[0]
+	if ddl := c.DDL(); ddl != "ALTER TABLE `film` ADD FULLTEXT INDEX `ft_title` (`title`);" {
+		t.Errorf("DDL got: %s", ddl)
+	}
+	if rewrite := c.Rewrite(); rewrite != "`title like '%love%'` 改写为 `MATCH(title) AGAINST('+love*' IN BOOLEAN MODE)`" {
+		t.Errorf("Rewrite got: %s", rewrite)
+	}
+
+	// 中文关键字使用 ngram 解析器
+	c = &FulltextCandidate{Table: "film", Column: "description", Words: []string{"爱情"}, Sample: "description like '%爱情%'"}
+	if !strings.HasSuffix(c.DDL(), "WITH PARSER ngram;") || !strings.Contains(c.Rewrite(), `AGAINST('"爱情"' IN BOOLEAN MODE)`) {
+		t.Errorf("ngram got: %s %s", c.DDL(), c.Rewrite())
+	}
+	if report := w.Format(); !strings.Contains(report, "## 全文索引建议") {
+		t.Errorf("workload report got:\n%s", report)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
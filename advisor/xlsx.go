@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// xlsxColumns xlsx 报告的列名
+var xlsxColumns = []string{"Fingerprint", "Sample", "Rule", "Severity", "Advice", "Tables", "File", "Line"}
+
+// xlsxCellLimit Excel 单元格最多容纳的字符数
+const xlsxCellLimit = 32767
+
+// FormatXLSX 输出 xlsx 格式报告，每个 Severity 一个 sheet，便于将评审结果以表格交给业务方
+// 不依赖第三方库，直接按 Office Open XML 规范生成最小可用的工作簿
+func FormatXLSX(findings []Finding) string {
+	var sheets []string
+	rows := make(map[string][][]string)
+	for _, f := range findings {
+		if f.ok() {
+			continue
+		}
+		severity := f.Rule.Severity
+		if _, ok := rows[severity]; !ok {
+			sheets = append(sheets, severity)
+		}
+		rows[severity] = append(rows[severity], []string{
+			f.Fingerprint,
+			f.SQL,
+			f.Rule.Item,
+			f.Rule.Severity,
+			strings.TrimSpace(f.Rule.Summary + "\n" + f.Rule.Content),
+			strings.Join(f.Tables, ","),
+			f.File,
+			fmt.Sprint(f.Line),
+		})
+	}
+	if len(sheets) == 0 {
+		sheets = append(sheets, "OK")
+	}
+	sortSeverity(sheets)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+	}
+	for i, sheet := range sheets {
+		files = append(files, struct{ name, body string }{
+			fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxSheet(rows[sheet]),
+		})
+	}
+	for _, file := range files {
+		fw, err := w.Create(file.name)
+		if err != nil {
+			common.Log.Error("FormatXLSX zip.Create %s Error: %v", file.name, err)
+			return ""
+		}
+		_, err = fw.Write([]byte(file.body))
+		common.LogIfError(err, "")
+	}
+	common.LogIfError(w.Close(), "")
+	return buf.String()
+}
+
+// sortSeverity 按 L0-L8 从高到低排列 sheet，问题严重的排在前面
+func sortSeverity(sheets []string) {
+	for i := 1; i < len(sheets); i++ {
+		for j := i; j > 0 && severityLevel(sheets[j]) > severityLevel(sheets[j-1]); j-- {
+			sheets[j], sheets[j-1] = sheets[j-1], sheets[j]
+		}
+	}
+}
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func xlsxContentTypes(sheets int) string {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&buf, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	buf.WriteString(`</Types>`)
+	return buf.String()
+}
+
+func xlsxWorkbook(sheets []string) string {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range sheets {
+		fmt.Fprintf(&buf, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(sheet), i+1, i+1)
+	}
+	buf.WriteString(`</sheets></workbook>`)
+	return buf.String()
+}
+
+func xlsxWorkbookRels(sheets int) string {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&buf, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	buf.WriteString(`</Relationships>`)
+	return buf.String()
+}
+
+// xlsxSheet 生成 sheet 内容，第一行为列名，单元格均使用 inlineStr 避免维护 sharedStrings
+func xlsxSheet(rows [][]string) string {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range append([][]string{xlsxColumns}, rows...) {
+		fmt.Fprintf(&buf, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			if r := []rune(cell); len(r) > xlsxCellLimit {
+				cell = string(r[:xlsxCellLimit])
+			}
+			fmt.Fprintf(&buf, `<c r="%c%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+				'A'+j, i+1, xlsxEscape(cell))
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData></worksheet>`)
+	return buf.String()
+}
+
+func xlsxEscape(s string) string {
+	var buf bytes.Buffer
+	common.LogIfError(xml.EscapeText(&buf, []byte(s)), "")
+	return buf.String()
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advisor
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestFormatXLSX(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	findings := append(NewFindings(map[string]Rule{
+		"CLA.001": HeuristicRules["CLA.001"],
+		"COL.001": HeuristicRules["COL.001"],
+	}, "select * from film", []string{"sakila.film"}, "a.sql", 1),
+		NewFindings(map[string]Rule{"ARG.001": HeuristicRules["ARG.001"]},
+			"select a from b where c like '%x'", nil, "a.sql", 3)...)
+	xlsx := FormatXLSX(findings)
+	r, err := zip.NewReader(bytes.NewReader([]byte(xlsx)), int64(len(xlsx)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(buf)
+		rc.Close()
+	}
+	// CLA.001, ARG.001 为 L4，COL.001 为 L1，L4 排在前面
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="L4" sheetId="1" r:id="rId1"/><sheet name="L1" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("workbook got %s", files["xl/workbook.xml"])
+	}
+	if strings.Count(files["xl/worksheets/sheet1.xml"], "<row ") != 3 ||
+		!strings.Contains(files["xl/worksheets/sheet2.xml"], "sakila.film") ||
+		!strings.Contains(files["xl/worksheets/sheet2.xml"], "select * from film") {
+		t.Errorf("sheets got %s\n%s", files["xl/worksheets/sheet1.xml"], files["xl/worksheets/sheet2.xml"])
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,451 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ClickHouseTable ClickHouse 建表语句中的表定义
+type ClickHouseTable struct {
+	Name    string   // 表名，不含库名
+	Engine  string   // 表引擎，如 MergeTree, ReplacingMergeTree
+	Columns []string // 列名
+	Key     []string // 主键引用的列，未指定 PRIMARY KEY 时为排序键 ORDER BY
+}
+
+// ClickHouseQuery ClickHouse 特有的语法信息
+type ClickHouseQuery struct {
+	SQL      string           // 去除 ClickHouse 特有语法后可由 vitess, TiDB 解析的 SQL，建表语句为空
+	Final    bool             // FROM tbl FINAL
+	Prewhere bool             // 使用了 PREWHERE
+	Table    *ClickHouseTable // CREATE TABLE 的表定义
+}
+
+// chToken ClickHouse 词法单元，Val 为 SQL 中的原始文本
+type chToken struct {
+	Val   string
+	Pos   int
+	Ident bool // 关键字或标识符
+}
+
+// upper 关键字比较时使用大写
+func (t chToken) upper() string {
+	if t.Ident {
+		return strings.ToUpper(t.Val)
+	}
+	return t.Val
+}
+
+// name 去掉引号后的标识符
+func (t chToken) name() string {
+	return strings.Trim(t.Val, "`\"")
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// chTokenize ClickHouse 词法分析，跳过空白及注释
+func chTokenize(sql string) []chToken {
+	var tokens []chToken
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '#' || strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(sql) && sql[j] != c; j++ {
+				if sql[j] == '\\' {
+					j++
+				}
+			}
+			if j < len(sql) {
+				j++
+			} else {
+				j = len(sql)
+			}
+			// ClickHouse 中双引号与反引号均用于引用标识符
+			tokens = append(tokens, chToken{Val: sql[i:j], Pos: i, Ident: c != '\''})
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(sql) && (isIdentChar(sql[j]) || unicode.IsDigit(rune(c)) && sql[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, chToken{Val: sql[i:j], Pos: i, Ident: !unicode.IsDigit(rune(c))})
+			i = j
+		default:
+			tokens = append(tokens, chToken{Val: sql[i : i+1], Pos: i})
+			i++
+		}
+	}
+	return tokens
+}
+
+// chClauses 结束 PREWHERE, LIMIT BY 等子句的关键字
+var chClauses = map[string]bool{
+	"GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true, "WINDOW": true,
+	"SETTINGS": true, "FORMAT": true, "UNION": true, "EXCEPT": true, "INTERSECT": true,
+}
+
+// chJoins JOIN 子句中的关键字，用于识别 ANY, ALL, ASOF, SEMI, ANTI, GLOBAL 等 ClickHouse 特有的 JOIN 修饰
+var chJoins = map[string]bool{
+	"JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "FULL": true, "CROSS": true,
+	"OUTER": true, "ANY": true, "ALL": true, "ASOF": true, "SEMI": true, "ANTI": true,
+}
+
+// chFinalFollows FROM tbl FINAL 之后可能出现的关键字
+var chFinalFollows = map[string]bool{
+	"": true, ",": true, ")": true, ";": true, "WHERE": true, "PREWHERE": true, "SAMPLE": true,
+	"ARRAY": true, "GLOBAL": true,
+}
+
+// ParseClickHouse 解析 ClickHouse SQL，记录 FINAL, PREWHERE 等 ClickHouse 特有的语法，
+// 并去除 FINAL, SAMPLE, SETTINGS, FORMAT, LIMIT BY 等子句，将 PREWHERE 合并至 WHERE，使其余部分可以复用 MySQL 的解析器及规则
+func ParseClickHouse(sql string) *ClickHouseQuery {
+	tokens := chTokenize(sql)
+	q := &ClickHouseQuery{}
+	if q.Table = parseClickHouseCreateTable(tokens); q.Table != nil {
+		return q
+	}
+
+	var buf strings.Builder
+	last := 0
+	// emit 输出 token 及其之前的空白，drop 同时丢弃 token 之前的空白
+	emit := func(t chToken, val string) {
+		if !strings.HasSuffix(buf.String(), "(") {
+			buf.WriteString(sql[last:t.Pos])
+		}
+		buf.WriteString(val)
+		last = t.Pos + len(t.Val)
+	}
+	drop := func(t chToken) {
+		last = t.Pos + len(t.Val)
+	}
+	at := func(i int) string {
+		if i < 0 || i >= len(tokens) {
+			return ""
+		}
+		return tokens[i].upper()
+	}
+
+	depth := 0
+	prewhere := make(map[int]bool)
+	closePrewhere := func(d int) {
+		if prewhere[d] {
+			buf.WriteString(")")
+			delete(prewhere, d)
+		}
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		up := t.upper()
+		switch up {
+		case "(":
+			depth++
+		case ")":
+			closePrewhere(depth)
+			depth--
+		case ";":
+			closePrewhere(depth)
+		}
+		if !t.Ident {
+			emit(t, t.Val)
+			continue
+		}
+		if chClauses[up] {
+			closePrewhere(depth)
+		}
+
+		switch up {
+		case "FINAL":
+			if i > 0 && (tokens[i-1].Ident || at(i-1) == ")") && (chFinalFollows[at(i+1)] || chClauses[at(i+1)] || chJoins[at(i+1)]) {
+				q.Final = true
+				drop(t)
+				continue
+			}
+		case "SAMPLE":
+			// SAMPLE k [OFFSET m]，k, m 可以是小数或分数
+			if j := chSkipRatio(tokens, i+1); j > i+1 {
+				if at(j) == "OFFSET" {
+					j = chSkipRatio(tokens, j+1)
+				}
+				i = j - 1
+				drop(tokens[i])
+				continue
+			}
+		case "PREWHERE":
+			q.Prewhere = true
+			emit(t, "WHERE (")
+			prewhere[depth] = true
+			continue
+		case "WHERE":
+			if prewhere[depth] {
+				buf.WriteString(")")
+				emit(t, "AND (")
+				continue
+			}
+		case "GLOBAL":
+			if at(i+1) == "IN" || at(i+1) == "NOT" || chJoins[at(i+1)] {
+				drop(t)
+				continue
+			}
+		case "ANY", "ALL", "ASOF", "SEMI", "ANTI":
+			// LEFT ANY JOIN, ANY LEFT JOIN 等，ALL 还可能是 UNION ALL, > ALL (...)
+			if chJoins[at(i+1)] && at(i+1) != "ALL" {
+				drop(t)
+				continue
+			}
+		case "SETTINGS", "FORMAT":
+			if depth == 0 {
+				for ; i < len(tokens) && tokens[i].Val != ";"; i++ {
+					drop(tokens[i])
+				}
+				i--
+				continue
+			}
+		case "LIMIT":
+			// LIMIT n [OFFSET m] BY expr
+			j := i + 1
+			if j < len(tokens) && !tokens[j].Ident {
+				j++
+				if at(j) == "," || at(j) == "OFFSET" {
+					j += 2
+				}
+				if at(j) == "BY" {
+					for d := 0; i < len(tokens); i++ {
+						if at(i) == "(" {
+							d++
+						}
+						if d == 0 && i > j && (chClauses[at(i)] || at(i) == ")" || at(i) == ";") {
+							break
+						}
+						if at(i) == ")" {
+							d--
+						}
+						drop(tokens[i])
+					}
+					i--
+					continue
+				}
+			}
+		}
+		emit(t, t.Val)
+	}
+	for d := depth; d >= 0; d-- {
+		closePrewhere(d)
+	}
+	buf.WriteString(sql[last:])
+	q.SQL = buf.String()
+	return q
+}
+
+// chSkipRatio 跳过 SAMPLE 子句中的 k 或 k/n，返回其后的位置
+func chSkipRatio(tokens []chToken, i int) int {
+	if i >= len(tokens) || tokens[i].Ident || !unicode.IsDigit(rune(tokens[i].Val[0])) {
+		return i
+	}
+	if i+2 < len(tokens) && tokens[i+1].Val == "/" {
+		return i + 3
+	}
+	return i + 1
+}
+
+// parseClickHouseCreateTable 解析 CREATE TABLE 语句，不是建表语句时返回 nil
+func parseClickHouseCreateTable(tokens []chToken) *ClickHouseTable {
+	at := func(i int) string {
+		if i >= len(tokens) {
+			return ""
+		}
+		return tokens[i].upper()
+	}
+	if at(0) != "CREATE" {
+		return nil
+	}
+	i := 1
+	if at(i) == "OR" && at(i+1) == "REPLACE" {
+		i += 2
+	}
+	if at(i) == "TEMPORARY" {
+		i++
+	}
+	if at(i) != "TABLE" {
+		return nil
+	}
+	i++
+	if at(i) == "IF" && at(i+1) == "NOT" && at(i+2) == "EXISTS" {
+		i += 3
+	}
+	if i >= len(tokens) || !tokens[i].Ident {
+		return nil
+	}
+	tb := &ClickHouseTable{Name: tokens[i].name()}
+	i++
+	if at(i) == "." && i+1 < len(tokens) {
+		tb.Name = tokens[i+1].name()
+		i += 2
+	}
+	if at(i) == "ON" && at(i+1) == "CLUSTER" {
+		i += 3
+	}
+
+	// 列定义，忽略 INDEX, PROJECTION, CONSTRAINT 及 PRIMARY KEY
+	if at(i) == "(" {
+		for _, def := range chSplitList(tokens, i) {
+			if len(def) == 0 || !def[0].Ident {
+				continue
+			}
+			switch def[0].upper() {
+			case "INDEX", "PROJECTION", "CONSTRAINT", "PRIMARY":
+				if len(def) > 3 && def[0].upper() == "PRIMARY" {
+					tb.Key = chKeyColumns(def[2:])
+				}
+			default:
+				tb.Columns = append(tb.Columns, def[0].name())
+			}
+		}
+		i = chSkipParens(tokens, i)
+	}
+
+	// 表属性
+	var orderBy []string
+	for ; i < len(tokens); i++ {
+		switch at(i) {
+		case "ENGINE":
+			if at(i+1) == "=" {
+				i++
+			}
+			if i+1 < len(tokens) {
+				tb.Engine = tokens[i+1].Val
+			}
+		case "ORDER", "PRIMARY":
+			if at(i+1) != "BY" && at(i+1) != "KEY" {
+				continue
+			}
+			j := i + 2
+			end := j
+			if at(j) == "(" {
+				end = chSkipParens(tokens, j)
+			} else {
+				for end < len(tokens) && !chTableClauses[at(end)] {
+					if at(end) == "(" {
+						end = chSkipParens(tokens, end)
+						continue
+					}
+					end++
+				}
+			}
+			key := chKeyColumns(tokens[j:end])
+			if at(i) == "ORDER" {
+				orderBy = key
+			} else {
+				tb.Key = key
+			}
+			i = end - 1
+		}
+	}
+	if tb.Key == nil {
+		tb.Key = orderBy
+	}
+	return tb
+}
+
+// chTableClauses 建表语句中 ENGINE 之后的子句
+var chTableClauses = map[string]bool{
+	"ENGINE": true, "ORDER": true, "PARTITION": true, "PRIMARY": true, "SAMPLE": true,
+	"TTL": true, "SETTINGS": true, "COMMENT": true, "AS": true, ";": true,
+}
+
+// chSkipParens 返回与 tokens[i] 处的左括号匹配的右括号之后的位置
+func chSkipParens(tokens []chToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// chSplitList 将 tokens[i] 处括号中的内容按顶层的逗号切分
+func chSplitList(tokens []chToken, i int) [][]chToken {
+	end := chSkipParens(tokens, i)
+	if end > i+1 && end <= len(tokens) && tokens[end-1].Val == ")" {
+		end--
+	}
+	var list [][]chToken
+	var item []chToken
+	depth := 0
+	for _, t := range tokens[i+1 : end] {
+		switch t.Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				list = append(list, item)
+				item = nil
+				continue
+			}
+		}
+		item = append(item, t)
+	}
+	return append(list, item)
+}
+
+// chKeyColumns 返回排序键或主键表达式中引用的列，如 (a, toDate(b)) 返回 a, b，tuple() 返回空
+func chKeyColumns(tokens []chToken) []string {
+	var exprs [][]chToken
+	if len(tokens) > 0 && tokens[0].Val == "(" {
+		exprs = chSplitList(tokens, 0)
+	} else {
+		exprs = [][]chToken{tokens}
+	}
+	key := []string{}
+	for _, expr := range exprs {
+		for j, t := range expr {
+			if t.Ident && (j+1 == len(expr) || expr[j+1].Val != "(") {
+				key = append(key, t.name())
+				break
+			}
+		}
+	}
+	return key
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestParseClickHouse(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := []struct {
+		sql      string
+		expect   string
+		final    bool
+		prewhere bool
+	}{
+		{
+			"SELECT a FROM t FINAL PREWHERE a = 1 WHERE b = 'x' OR c = 2 SETTINGS max_threads = 1 FORMAT JSON",
+			"SELECT a FROM t WHERE (a = 1) AND (b = 'x' OR c = 2)",
+			true, true,
+		},
+		{
+			"SELECT a FROM t SAMPLE 1/10 OFFSET 1/2 PREWHERE a = 1 GROUP BY a LIMIT 2 BY a LIMIT 10",
+			"SELECT a FROM t WHERE (a = 1) GROUP BY a LIMIT 10",
+			false, true,
+		},
+		{
+			"SELECT final FROM t1 GLOBAL ANY LEFT JOIN t2 USING (id) WHERE id GLOBAL IN (SELECT id FROM t3 PREWHERE x = 1) UNION ALL SELECT 1",
+			"SELECT final FROM t1 LEFT JOIN t2 USING (id) WHERE id IN (SELECT id FROM t3 WHERE (x = 1)) UNION ALL SELECT 1",
+			false, true,
+		},
+		{
+			"select count(*) from `t` as x final where x.a > 0.5",
+			"select count(*) from `t` as x where x.a > 0.5",
+			true, false,
+		},
+	}
+	for _, c := range cases {
+		q := ParseClickHouse(c.sql)
+		if q.SQL != c.expect || q.Final != c.final || q.Prewhere != c.prewhere || q.Table != nil {
+			t.Errorf("SQL: %s\nwant: %s %v %v\ngot: %s %v %v", c.sql, c.expect, c.final, c.prewhere, q.SQL, q.Final, q.Prewhere)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestParseClickHouseCreateTable(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]ClickHouseTable{
+		"CREATE TABLE IF NOT EXISTS db.hits ON CLUSTER c (`event_date` Date, user_id UInt64, url Nullable(String), INDEX idx_url url TYPE bloom_filter GRANULARITY 4) ENGINE = ReplicatedMergeTree('/ch/{shard}/hits', '{replica}') PARTITION BY toYYYYMM(event_date) ORDER BY (user_id, toStartOfHour(event_date)) SETTINGS index_granularity = 8192": {
+			Name: "hits", Engine: "ReplicatedMergeTree", Columns: []string{"event_date", "user_id", "url"}, Key: []string{"user_id", "event_date"},
+		},
+		"create table t (a UInt8, b String) engine = MergeTree order by a primary key a": {
+			Name: "t", Engine: "MergeTree", Columns: []string{"a", "b"}, Key: []string{"a"},
+		},
+		"CREATE TABLE t (a UInt8) ENGINE = MergeTree() ORDER BY tuple()": {
+			Name: "t", Engine: "MergeTree", Columns: []string{"a"}, Key: []string{},
+		},
+		"CREATE TABLE t (a UInt8, b UInt8, PRIMARY KEY (b)) ENGINE = MergeTree ORDER BY (b, a)": {
+			Name: "t", Engine: "MergeTree", Columns: []string{"a", "b"}, Key: []string{"b"},
+		},
+		"CREATE TABLE t (a UInt8) ENGINE = Memory": {
+			Name: "t", Engine: "Memory", Columns: []string{"a"},
+		},
+	}
+	for sql, expect := range cases {
+		q := ParseClickHouse(sql)
+		if q.Table == nil || q.SQL != "" || !reflect.DeepEqual(*q.Table, expect) {
+			t.Errorf("SQL: %s\nwant: %+v\ngot: %+v", sql, expect, q.Table)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// JoinGraph 最外层 SELECT 中表之间的关联关系，表为节点，关联条件为边
+type JoinGraph struct {
+	Tables []JoinGraphTable
+	Edges  []JoinGraphEdge
+}
+
+// JoinGraphTable 关联图中的一张表，Key 为 SQL 中引用该表使用的名字（别名或表名）
+// Schema, Name 为实际的库表名，子查询的 Name 为空
+type JoinGraphTable struct {
+	Key    string
+	Label  string
+	Schema string
+	Name   string
+}
+
+// JoinGraphEdge 两表之间的关联条件，Missing 表示两表之间没有任何关联条件，会产生笛卡尔积
+type JoinGraphEdge struct {
+	Left      string
+	Right     string
+	Condition string
+	Missing   bool
+}
+
+// NewJoinGraph 解析 SQL 中最外层 SELECT 的 FROM 及 WHERE 子句生成关联图
+// FROM 中的子查询作为一个节点，不展开；不是 SELECT 或只涉及一张表时返回 nil
+func NewJoinGraph(sql string) *JoinGraph {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil
+	}
+
+	g := &JoinGraph{}
+	for _, expr := range sel.From {
+		g.addTableExpr(expr)
+	}
+	if len(g.Tables) < 2 {
+		return nil
+	}
+	if sel.Where != nil {
+		g.addCondition(sel.Where.Expr, g.keys())
+	}
+	g.linkMissing()
+	return g
+}
+
+// keys 当前已收集的所有表
+func (g *JoinGraph) keys() []string {
+	var keys []string
+	for _, t := range g.Tables {
+		keys = append(keys, t.Key)
+	}
+	return keys
+}
+
+// addTableExpr 收集 FROM 中的表，JOIN ... ON/USING 中的条件作为边，返回该表达式中的所有表
+func (g *JoinGraph) addTableExpr(expr sqlparser.TableExpr) []string {
+	switch e := expr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		var t JoinGraphTable
+		switch tb := e.Expr.(type) {
+		case sqlparser.TableName:
+			t.Key = tb.Name.String()
+			t.Label = t.Key
+			t.Schema = tb.Qualifier.String()
+			t.Name = t.Key
+			if !tb.Qualifier.IsEmpty() {
+				t.Label = tb.Qualifier.String() + "." + t.Key
+			}
+		case *sqlparser.Subquery:
+			t.Label = "(subquery)"
+		}
+		if !e.As.IsEmpty() {
+			t.Key = e.As.String()
+			t.Label += " AS " + e.As.String()
+		}
+		if t.Key == "" {
+			return nil
+		}
+		g.Tables = append(g.Tables, t)
+		return []string{t.Key}
+	case *sqlparser.ParenTableExpr:
+		var keys []string
+		for _, sub := range e.Exprs {
+			keys = append(keys, g.addTableExpr(sub)...)
+		}
+		return keys
+	case *sqlparser.JoinTableExpr:
+		left := g.addTableExpr(e.LeftExpr)
+		right := g.addTableExpr(e.RightExpr)
+		keys := append(append([]string{}, left...), right...)
+		if e.Condition.On != nil {
+			g.addCondition(e.Condition.On, keys)
+		}
+		if len(e.Condition.Using) > 0 && len(left) > 0 && len(right) > 0 {
+			g.addEdge(left[len(left)-1], right[0], "USING "+sqlparser.String(e.Condition.Using))
+		}
+		// NATURAL JOIN 按同名列关联，无需显式的关联条件
+		if strings.HasPrefix(e.Join, "natural") && len(left) > 0 && len(right) > 0 {
+			g.addEdge(left[len(left)-1], right[0], e.Join)
+		}
+		return keys
+	}
+	return nil
+}
+
+// addCondition 将条件中 `列 operator 列` 且两列属于不同表的比较作为边，不进入子查询
+func (g *JoinGraph) addCondition(cond sqlparser.Expr, keys []string) {
+	inScope := func(col *sqlparser.ColName) string {
+		name := col.Qualifier.Name.String()
+		for _, k := range keys {
+			if strings.EqualFold(k, name) {
+				return k
+			}
+		}
+		return ""
+	}
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.ComparisonExpr:
+			left, lok := n.Left.(*sqlparser.ColName)
+			right, rok := n.Right.(*sqlparser.ColName)
+			if !lok || !rok {
+				return true, nil
+			}
+			l, r := inScope(left), inScope(right)
+			if l != "" && r != "" && l != r {
+				g.addEdge(l, r, sqlparser.String(n))
+			}
+		}
+		return true, nil
+	}, cond)
+	common.LogIfWarn(err, "")
+}
+
+// addEdge 添加一条边，同一对表之间的多个条件合并到一条边上
+func (g *JoinGraph) addEdge(left, right, condition string) {
+	for i, e := range g.Edges {
+		if (e.Left == left && e.Right == right) || (e.Left == right && e.Right == left) {
+			g.Edges[i].Condition += " AND " + condition
+			return
+		}
+	}
+	g.Edges = append(g.Edges, JoinGraphEdge{Left: left, Right: right, Condition: condition})
+}
+
+// linkMissing 与第一张表不连通的表之间缺少关联条件，用 Missing 边连接
+func (g *JoinGraph) linkMissing() {
+	group := make(map[string]string)
+	var find func(k string) string
+	find = func(k string) string {
+		if p, ok := group[k]; ok && p != k {
+			return find(p)
+		}
+		return k
+	}
+	for _, e := range g.Edges {
+		group[find(e.Left)] = find(e.Right)
+	}
+	for i := 1; i < len(g.Tables); i++ {
+		prev, cur := g.Tables[i-1].Key, g.Tables[i].Key
+		if find(g.Tables[0].Key) != find(cur) {
+			g.Edges = append(g.Edges, JoinGraphEdge{Left: prev, Right: cur, Missing: true})
+			group[find(cur)] = find(g.Tables[0].Key)
+		}
+	}
+}
+
+// Components 按关联条件将表分组，组内的表直接或间接关联，组与组之间缺少关联条件
+func (g *JoinGraph) Components() [][]JoinGraphTable {
+	group := make(map[string]string)
+	var find func(k string) string
+	find = func(k string) string {
+		if p, ok := group[k]; ok && p != k {
+			return find(p)
+		}
+		return k
+	}
+	for _, e := range g.Edges {
+		if !e.Missing {
+			group[find(e.Left)] = find(e.Right)
+		}
+	}
+
+	var components [][]JoinGraphTable
+	index := make(map[string]int)
+	for _, t := range g.Tables {
+		root := find(t.Key)
+		if i, ok := index[root]; ok {
+			components[i] = append(components[i], t)
+			continue
+		}
+		index[root] = len(components)
+		components = append(components, []JoinGraphTable{t})
+	}
+	return components
+}
+
+// HasMissing 是否存在缺少关联条件的表
+func (g *JoinGraph) HasMissing() bool {
+	for _, e := range g.Edges {
+		if e.Missing {
+			return true
+		}
+	}
+	return false
+}
+
+// node 节点在图中的编号，表名中可能包含图描述语言不支持的字符
+func (g *JoinGraph) node(key string) string {
+	for i, t := range g.Tables {
+		if t.Key == key {
+			return fmt.Sprintf("t%d", i)
+		}
+	}
+	return key
+}
+
+// Mermaid 输出 Mermaid flowchart，缺少关联条件的边用红色虚线标出
+func (g *JoinGraph) Mermaid() string {
+	escape := func(s string) string {
+		return strings.Replace(s, `"`, "#quot;", -1)
+	}
+	buf := []string{"graph LR"}
+	for i, t := range g.Tables {
+		buf = append(buf, fmt.Sprintf(`  t%d["%s"]`, i, escape(t.Label)))
+	}
+	for i, e := range g.Edges {
+		if e.Missing {
+			buf = append(buf, fmt.Sprintf(`  %s -.-|"缺少关联条件"| %s`, g.node(e.Left), g.node(e.Right)))
+			buf = append(buf, fmt.Sprintf("  linkStyle %d stroke:#e74c3c,stroke-width:2px", i))
+			continue
+		}
+		buf = append(buf, fmt.Sprintf(`  %s ---|"%s"| %s`, g.node(e.Left), escape(e.Condition), g.node(e.Right)))
+	}
+	return strings.Join(buf, "\n")
+}
+
+// Dot 输出 Graphviz DOT，缺少关联条件的边用红色虚线标出
+func (g *JoinGraph) Dot() string {
+	escape := func(s string) string {
+		return strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1)
+	}
+	buf := []string{"graph join {", "  rankdir=LR;", "  node [shape=box];"}
+	for i, t := range g.Tables {
+		buf = append(buf, fmt.Sprintf(`  t%d [label="%s"];`, i, escape(t.Label)))
+	}
+	for _, e := range g.Edges {
+		if e.Missing {
+			buf = append(buf, fmt.Sprintf(`  %s -- %s [label="缺少关联条件", style=dashed, color=red, fontcolor=red];`,
+				g.node(e.Left), g.node(e.Right)))
+			continue
+		}
+		buf = append(buf, fmt.Sprintf(`  %s -- %s [label="%s"];`, g.node(e.Left), g.node(e.Right), escape(e.Condition)))
+	}
+	buf = append(buf, "}")
+	return strings.Join(buf, "\n")
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestNewJoinGraph(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	sqls := []string{
+		"select * from film",
+		"select * from film f join film_actor fa on f.film_id = fa.film_id join actor a on a.actor_id = fa.actor_id",
+		"select * from film f, film_actor fa, actor a where f.film_id = fa.film_id",
+		"select * from film f join language l using (language_id) join (select 1 as id) t",
+	}
+	var graphs []string
+	for _, sql := range sqls {
+		g := NewJoinGraph(sql)
+		if g == nil {
+			graphs = append(graphs, "<nil>")
+			continue
+		}
+		var edges []string
+		for _, e := range g.Edges {
+			if e.Missing {
+				edges = append(edges, e.Left+" -.- "+e.Right)
+			} else {
+				edges = append(edges, e.Left+" --- "+e.Right+": "+e.Condition)
+			}
+		}
+		graphs = append(graphs, strings.Join(edges, "; "))
+	}
+	expects := []string{
+		"<nil>",
+		"f --- fa: f.film_id = fa.film_id; a --- fa: a.actor_id = fa.actor_id",
+		"f --- fa: f.film_id = fa.film_id; fa -.- a",
+		"f --- l: USING (language_id); l -.- t",
+	}
+	for i := range sqls {
+		if graphs[i] != expects[i] {
+			t.Errorf("NewJoinGraph(%s)\nwant: %s\ngot: %s", sqls[i], expects[i], graphs[i])
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestJoinGraphFormat(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	g := NewJoinGraph(`select * from film f, actor a where f.title = "a"`)
+	mermaid := g.Mermaid()
+	if !strings.Contains(mermaid, `t0["film AS f"]`) || !strings.Contains(mermaid, `t0 -.-|"缺少关联条件"| t1`) ||
+		!strings.Contains(mermaid, "linkStyle 0 ") {
+		t.Errorf("Mermaid got:\n%s", mermaid)
+	}
+	dot := g.Dot()
+	if !strings.HasPrefix(dot, "graph join {") || !strings.Contains(dot, "t0 -- t1 [label=\"缺少关联条件\", style=dashed") {
+		t.Errorf("Dot got:\n%s", dot)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestJoinGraphComponents(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	g := NewJoinGraph("select * from sakila.film f, film_actor fa, actor a, (select 1) t where f.film_id = fa.film_id and t.id = a.actor_id")
+	var groups []string
+	for _, c := range g.Components() {
+		var names []string
+		for _, tb := range c {
+			names = append(names, tb.Key+"="+tb.Schema+"."+tb.Name)
+		}
+		groups = append(groups, strings.Join(names, ","))
+	}
+	expect := "f=sakila.film,fa=.film_actor; a=.actor,t=."
+	if got := strings.Join(groups, "; "); got != expect {
+		t.Errorf("want: %s\ngot: %s", expect, got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -38,6 +38,7 @@ func TestMain(m *testing.M) {
 		common.DevPath, _ = filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	}
 	common.BaseDir = common.DevPath
+	common.RedirectTestLog()
 	err := common.ParseConfig("")
 	common.LogIfError(err, "init ParseConfig")
 	common.Log.Debug("ast_test init")
@@ -248,10 +248,11 @@ func ListRewriteRules(rules []Rule) {
 
 // Rewrite 用于重写SQL
 type Rewrite struct {
-	SQL     string
-	NewSQL  string
-	Stmt    sqlparser.Statement
-	Columns common.TableColumns
+	SQL        string
+	NewSQL     string
+	Stmt       sqlparser.Statement
+	Columns    common.TableColumns
+	UniqueKeys [][]string // 目标表的主键及唯一键，每个元素为按索引顺序排列的列名，dml2select 转换 REPLACE, ON DUPLICATE KEY UPDATE 时使用
 }
 
 // NewRewrite 返回一个*Rewrite对象，如果SQL无法被正常解析，将错误输出到日志中，返回一个nil
@@ -1600,6 +1601,7 @@ func (rw *Rewrite) RewriteTruncate() *Rewrite {
 }
 
 // RewriteDML2Select dml2select: DML 转成 SELECT，兼容低版本的 EXPLAIN
+// 多表 UPDATE, DELETE 转换为对应的 JOIN，REPLACE 及 INSERT ... ON DUPLICATE KEY UPDATE 转换为唯一键冲突检查时的查找
 func (rw *Rewrite) RewriteDML2Select() *Rewrite {
 	if rw.Stmt == nil {
 		return rw
@@ -1608,18 +1610,18 @@ func (rw *Rewrite) RewriteDML2Select() *Rewrite {
 	switch stmt := rw.Stmt.(type) {
 	case *sqlparser.Select:
 		rw.NewSQL = rw.SQL
-	case *sqlparser.Delete: // Multi DELETE not support yet.
+	case *sqlparser.Delete:
 		rw.NewSQL = delete2Select(stmt)
 	case *sqlparser.Insert:
-		rw.NewSQL = insert2Select(stmt)
-	case *sqlparser.Update: // Multi UPDATE not support yet.
+		rw.NewSQL = insert2Select(stmt, rw.UniqueKeys)
+	case *sqlparser.Update:
 		rw.NewSQL = update2Select(stmt)
 	}
 	rw.Stmt, _ = sqlparser.Parse(rw.NewSQL)
 	return rw
 }
 
-// delete2Select 将 Delete 语句改写成 Select
+// delete2Select 将 Delete 语句改写成 Select，多表 DELETE 的 TableExprs 中已包含 JOIN 条件
 func delete2Select(stmt *sqlparser.Delete) string {
 	newSQL := &sqlparser.Select{
 		SelectExprs: []sqlparser.SelectExpr{
@@ -1628,11 +1630,12 @@ func delete2Select(stmt *sqlparser.Delete) string {
 		From:    stmt.TableExprs,
 		Where:   stmt.Where,
 		OrderBy: stmt.OrderBy,
+		Limit:   stmt.Limit,
 	}
 	return sqlparser.String(newSQL)
 }
 
-// update2Select 将 Update 语句改写成 Select
+// update2Select 将 Update 语句改写成 Select，SET 中的子查询作为查询列保留，使 EXPLAIN 中包含子查询的执行计划
 func update2Select(stmt *sqlparser.Update) string {
 	newSQL := &sqlparser.Select{
 		SelectExprs: []sqlparser.SelectExpr{
@@ -1643,20 +1646,137 @@ func update2Select(stmt *sqlparser.Update) string {
 		OrderBy: stmt.OrderBy,
 		Limit:   stmt.Limit,
 	}
+	newSQL.SelectExprs = append(newSQL.SelectExprs, subqueryExprs(sqlparser.UpdateExprs(stmt.Exprs))...)
 	return sqlparser.String(newSQL)
 }
 
-// insert2Select 将 Insert 语句改写成 Select
-func insert2Select(stmt *sqlparser.Insert) string {
+// subqueryExprs 返回 SET 或 ON DUPLICATE KEY UPDATE 中包含子查询的赋值表达式
+func subqueryExprs(exprs sqlparser.UpdateExprs) []sqlparser.SelectExpr {
+	var selectExprs []sqlparser.SelectExpr
+	for _, e := range exprs {
+		hasSubquery := false
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			if _, ok := node.(*sqlparser.Subquery); ok {
+				hasSubquery = true
+				return false, nil
+			}
+			return true, nil
+		}, e.Expr)
+		if hasSubquery {
+			selectExprs = append(selectExprs, &sqlparser.AliasedExpr{Expr: e.Expr})
+		}
+	}
+	return selectExprs
+}
+
+// insert2Select 将 Insert 语句改写成 Select，keys 为目标表的主键及唯一键
+func insert2Select(stmt *sqlparser.Insert, keys [][]string) string {
 	switch row := stmt.Rows.(type) {
 	// 如果insert包含子查询，只需要explain该子树
 	case *sqlparser.Select, *sqlparser.Union, *sqlparser.ParenSelect:
 		return sqlparser.String(row)
+	case sqlparser.Values:
+		// REPLACE 及 ON DUPLICATE KEY UPDATE 写入前需要按写入的值查找冲突的行
+		if stmt.Action == sqlparser.ReplaceStr || len(stmt.OnDup) > 0 {
+			if sql := values2Select(stmt, row, keys); sql != "" {
+				return sql
+			}
+		}
 	}
 
 	return "select 1 from DUAL"
 }
 
+// dml2selectApproximate 未知目标表的唯一键时追加在转换结果后的说明
+const dml2selectApproximate = " /* 唯一键未知，按写入的所有列查找，与实际的冲突检查不同，执行计划仅供参考 */"
+
+// values2Select 将 REPLACE, INSERT ... ON DUPLICATE KEY UPDATE 写入的值转换为按主键及唯一键查找冲突的行
+// 如: 主键为 a 时 REPLACE INTO t (a, b) VALUES (1, 2) 转换为 select * from t where a in (1)
+// 写入的列未包含任何一个唯一键时按写入的所有列查找，并在结果后注明执行计划仅供参考
+// 未指定列名时无法确定写入的列，返回空
+func values2Select(stmt *sqlparser.Insert, rows sqlparser.Values, keys [][]string) string {
+	if len(stmt.Columns) == 0 || len(rows) == 0 {
+		return ""
+	}
+	for _, row := range rows {
+		if len(row) != len(stmt.Columns) {
+			return ""
+		}
+	}
+	var where sqlparser.Expr
+	for _, key := range keys {
+		idx := insertColumnIndex(stmt.Columns, key)
+		if idx == nil {
+			continue
+		}
+		if where == nil {
+			where = valuesIn(stmt.Columns, rows, idx)
+		} else {
+			where = &sqlparser.OrExpr{Left: where, Right: valuesIn(stmt.Columns, rows, idx)}
+		}
+	}
+	approximate := where == nil
+	if approximate {
+		var idx []int
+		for i := range stmt.Columns {
+			idx = append(idx, i)
+		}
+		where = valuesIn(stmt.Columns, rows, idx)
+	}
+
+	newSQL := &sqlparser.Select{
+		SelectExprs: []sqlparser.SelectExpr{
+			new(sqlparser.StarExpr),
+		},
+		From:  sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: stmt.Table}},
+		Where: sqlparser.NewWhere(sqlparser.WhereStr, where),
+	}
+	newSQL.SelectExprs = append(newSQL.SelectExprs, subqueryExprs(sqlparser.UpdateExprs(stmt.OnDup))...)
+	if approximate {
+		return sqlparser.String(newSQL) + dml2selectApproximate
+	}
+	return sqlparser.String(newSQL)
+}
+
+// insertColumnIndex 返回 key 中各列在写入列中的位置，写入的列未包含 key 中所有的列时返回 nil
+func insertColumnIndex(columns sqlparser.Columns, key []string) []int {
+	var idx []int
+	for _, name := range key {
+		i := columns.FindColumn(sqlparser.NewColIdent(name))
+		if i < 0 {
+			return nil
+		}
+		idx = append(idx, i)
+	}
+	return idx
+}
+
+// valuesIn 构造 (列) IN (写入的值) 的条件，idx 为参与比较的列在写入列中的位置
+func valuesIn(columns sqlparser.Columns, rows sqlparser.Values, idx []int) sqlparser.Expr {
+	var left sqlparser.Expr
+	var right sqlparser.ValTuple
+	if len(idx) == 1 {
+		left = &sqlparser.ColName{Name: columns[idx[0]]}
+		for _, row := range rows {
+			right = append(right, row[idx[0]])
+		}
+	} else {
+		var cols sqlparser.ValTuple
+		for _, i := range idx {
+			cols = append(cols, &sqlparser.ColName{Name: columns[i]})
+		}
+		left = cols
+		for _, row := range rows {
+			var vals sqlparser.ValTuple
+			for _, i := range idx {
+				vals = append(vals, row[i])
+			}
+			right = append(right, vals)
+		}
+	}
+	return &sqlparser.ComparisonExpr{Operator: sqlparser.InStr, Left: left, Right: right}
+}
+
 // AlterAffectTable 获取ALTER影响的库表名，返回：`db`.`table`
 func AlterAffectTable(stmt sqlparser.Statement) string {
 	switch n := stmt.(type) {
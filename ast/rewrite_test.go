@@ -575,6 +575,27 @@ func TestRewriteDML2Select(t *testing.T) {
 		}, {
 			"input":  "replace INTO city (country_id) SELECT 10 FROM DUAL;",
 			"output": "select 10 from dual",
+		}, {
+			"input":  "DELETE FROM film WHERE length > 100 ORDER BY film_id LIMIT 10;",
+			"output": "select * from film where length > 100 order by film_id asc limit 10",
+		}, {
+			"input":  "UPDATE film SET length = (SELECT max(length) FROM film_text) WHERE language_id = 20;",
+			"output": "select *, (select max(length) from film_text) from film where language_id = 20",
+		}, {
+			"input":  "REPLACE INTO city (city_id, city) VALUES (1, 'Abha'), (2, 'Acua');",
+			"output": "select * from city where (city_id, city) in ((1, 'Abha'), (2, 'Acua')) /* 唯一键未知，按写入的所有列查找，与实际的冲突检查不同，执行计划仅供参考 */",
+		}, {
+			"input":  "INSERT INTO city (city_id) VALUES (1), (2) ON DUPLICATE KEY UPDATE city = 'Abha';",
+			"output": "select * from city where city_id in (1, 2) /* 唯一键未知，按写入的所有列查找，与实际的冲突检查不同，执行计划仅供参考 */",
+		}, {
+			"input":  "INSERT INTO city (city_id) VALUES (1) ON DUPLICATE KEY UPDATE country_id = (SELECT country_id FROM country LIMIT 1);",
+			"output": "select *, (select country_id from country limit 1) from city where city_id in (1) /* 唯一键未知，按写入的所有列查找，与实际的冲突检查不同，执行计划仅供参考 */",
+		}, {
+			"input":  "REPLACE INTO city VALUES (1, 'Abha');",
+			"output": "select 1 from DUAL",
+		}, {
+			"input":  "INSERT INTO city (country_id) SELECT country_id FROM country ON DUPLICATE KEY UPDATE city = 'Abha';",
+			"output": "select country_id from country",
 		},
 	}
 
@@ -584,6 +605,25 @@ func TestRewriteDML2Select(t *testing.T) {
 			t.Errorf("want: %s\ngot: %s", sql["output"], rw.NewSQL)
 		}
 	}
+
+	// 已知唯一键时按写入的唯一键查找冲突的行，写入的列未包含的唯一键不参与查找
+	keys := [][]string{{"city_id"}, {"country_id", "city"}, {"last_update"}}
+	testSQL = []map[string]string{
+		{
+			"input":  "REPLACE INTO city (city_id, city, country_id) VALUES (1, 'Abha', 82), (2, 'Acua', 60);",
+			"output": "select * from city where city_id in (1, 2) or (country_id, city) in ((82, 'Abha'), (60, 'Acua'))",
+		}, {
+			"input":  "INSERT INTO city (City, country_id) VALUES ('Abha', 82) ON DUPLICATE KEY UPDATE city = 'Abha';",
+			"output": "select * from city where (country_id, City) in ((82, 'Abha'))",
+		},
+	}
+	for _, sql := range testSQL {
+		rw := NewRewrite(sql["input"])
+		rw.UniqueKeys = keys
+		if rw.RewriteDML2Select(); rw.NewSQL != sql["output"] || rw.Stmt == nil {
+			t.Errorf("want: %s\ngot: %s", sql["output"], rw.NewSQL)
+		}
+	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
@@ -37,7 +37,13 @@ import (
 func TiParse(sql, charset, collation string) ([]ast.StmtNode, error) {
 	p := parser.New()
 	sql = removeIncompatibleWords(sql)
+	// 开启窗口函数后 RANK, ROW_NUMBER 等会被当作关键字，MySQL 8.0 之前这些名称可以作为列名，解析失败时关闭后重试
+	p.EnableWindowFunc(true)
 	stmt, warn, err := p.Parse(sql, charset, collation)
+	if err != nil {
+		p.EnableWindowFunc(false)
+		stmt, warn, err = p.Parse(sql, charset, collation)
+	}
 	// TODO: bypass warning info
 	for _, w := range warn {
 		common.Log.Warn(w.Error())
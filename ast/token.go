@@ -17,6 +17,7 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strings"
@@ -639,6 +640,10 @@ func getNextToken(buf string, previous Token) Token {
 			// Comment until end of line
 			last = strings.Index(buf, "\n")
 			typ = TokenTypeComment
+			// 最后一行的注释没有换行符
+			if last < 0 {
+				last = len(buf)
+			}
 		} else {
 			// Comment until closing comment tag
 			last = strings.Index(buf[2:], "*/") + 2
@@ -851,16 +856,55 @@ func Compress(sql string) string {
 }
 
 // SplitStatement SQL切分
+// DELIMITER 命令以换行结束，单独作为一条 SQL 返回，调用方需要使用 ParseDelimiter 切换分隔符
+// /*!50700 ... */, /*+ ... */ 及 $tag$ ... $tag$ 中的分隔符不做切分
 // return original sql, remove comment sql, left over buf
 func SplitStatement(buf []byte, delimiter []byte) (string, string, []byte) {
 	var singleLineComment bool
 	var multiLineComment bool
+	var execComment bool // MySQL 版本注释 /*!50700 ... */ 或优化器提示 /*+ ... */
 	var quoted bool
 	var quoteRune byte
 	var sql string
 
+	// DELIMITER 命令
+	trimmed := bytes.TrimLeft(buf, " \t\r\n")
+	if len(trimmed) > 10 && strings.EqualFold(string(trimmed[:9]), "delimiter") && (trimmed[9] == ' ' || trimmed[9] == '\t') {
+		end := len(buf)
+		if n := bytes.IndexAny(trimmed, "\r\n"); n >= 0 {
+			end = len(buf) - len(trimmed) + n
+		}
+		return string(buf[:end]), strings.TrimSpace(string(buf[:end])), buf[end:]
+	}
+
 	for i := 0; i < len(buf); i++ {
 		b := buf[i]
+
+		// dollar-quoted 函数体 $$ ... $$, $body$ ... $body$，分隔符以 $ 开头时不做处理
+		if b == '$' && !quoted && !singleLineComment && !multiLineComment && !execComment &&
+			!bytes.HasPrefix(delimiter, []byte("$")) &&
+			(i == 0 || unicode.IsSpace(rune(buf[i-1])) || buf[i-1] == '(' || buf[i-1] == ',') {
+			j := i + 1
+			for j < len(buf) && (buf[j] == '_' || unicode.IsLetter(rune(buf[j])) || unicode.IsDigit(rune(buf[j]))) {
+				j++
+			}
+			if j < len(buf) && buf[j] == '$' {
+				tag := buf[i : j+1]
+				n := bytes.Index(buf[j+1:], tag)
+				if n < 0 {
+					// 未闭合，剩余部分都作为同一条 SQL
+					sql = string(buf)
+					break
+				}
+				i = j + n + len(tag)
+				if i >= len(buf)-1 {
+					sql = string(buf)
+					break
+				}
+				continue
+			}
+		}
+
 		// single line comment
 		if b == '-' {
 			if i+2 < len(buf) && buf[i+1] == '-' && buf[i+2] == ' ' {
@@ -901,13 +945,19 @@ func SplitStatement(buf []byte, delimiter []byte) (string, string, []byte) {
 		// https://dev.mysql.com/doc/refman/8.0/en/comments.html
 		// https://dev.mysql.com/doc/refman/8.0/en/optimizer-hints.html
 		if b == '/' && i+1 < len(buf) && buf[i+1] == '*' {
-			if !multiLineComment && !singleLineComment && !quoted &&
-				(buf[i+2] != '!' && buf[i+2] != '+') {
-				i = i + 2
-				multiLineComment = true
-				continue
+			if !multiLineComment && !singleLineComment && !quoted && !execComment {
+				if i+2 < len(buf) && (buf[i+2] == '!' || buf[i+2] == '+') {
+					execComment = true
+				} else {
+					i = i + 2
+					multiLineComment = true
+					continue
+				}
 			}
 		}
+		if b == '*' && i+1 < len(buf) && buf[i+1] == '/' && execComment && !quoted {
+			execComment = false
+		}
 
 		if b == '*' && i+1 < len(buf) && buf[i+1] == '/' {
 			if multiLineComment && !quoted && !singleLineComment {
@@ -943,7 +993,7 @@ func SplitStatement(buf []byte, delimiter []byte) (string, string, []byte) {
 		}
 
 		// delimiter
-		if !quoted && !singleLineComment && !multiLineComment {
+		if !quoted && !singleLineComment && !multiLineComment && !execComment {
 			eof := true
 			for k, c := range delimiter {
 				if len(buf) > i+k && buf[i+k] != c {
@@ -967,6 +1017,15 @@ func SplitStatement(buf []byte, delimiter []byte) (string, string, []byte) {
 	return orgSQL, strings.TrimSuffix(sql, string(delimiter)), buf
 }
 
+// ParseDelimiter 判断 SQL 是否为 DELIMITER 命令，是则返回新的分隔符
+func ParseDelimiter(sql string) (string, bool) {
+	fields := strings.Fields(sql)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "delimiter") {
+		return "", false
+	}
+	return fields[1], true
+}
+
 // LeftNewLines cal left new lines in space
 func LeftNewLines(buf []byte) int {
 	newLines := 0
@@ -17,7 +17,9 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
@@ -205,6 +207,51 @@ select col from tb;
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestSplitStatementDelimiter(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	buf := []byte(`/*!40101 SET NAMES utf8 */;
+DELIMITER ;;
+/*!50003 CREATE*/ /*!50003 TRIGGER trg BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; SET NEW.b = 2; END */;;
+DELIMITER ;
+delimiter $$
+CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END$$
+DELIMITER ;
+CREATE FUNCTION f() RETURNS text AS $body$ SELECT 'a;b'; $body$;
+SELECT /*+ MAX_EXECUTION_TIME(1000); */ a$b FROM t;`)
+	expect := []string{
+		"/*!40101 SET NAMES utf8 */",
+		"DELIMITER ;;",
+		"/*!50003 CREATE*/ /*!50003 TRIGGER trg BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; SET NEW.b = 2; END */",
+		"DELIMITER ;",
+		"delimiter $$",
+		"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END",
+		"DELIMITER ;",
+		"CREATE FUNCTION f() RETURNS text AS $body$ SELECT 'a;b'; $body$",
+		"SELECT /*+ MAX_EXECUTION_TIME(1000); */ a$b FROM t",
+	}
+
+	delimiter := ";"
+	var got []string
+	for len(bytes.TrimSpace(buf)) > 0 {
+		var sql string
+		_, sql, buf = SplitStatement(buf, []byte(delimiter))
+		sql = strings.TrimSpace(sql)
+		if d, ok := ParseDelimiter(sql); ok {
+			delimiter = d
+		}
+		got = append(got, sql)
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("want %d statements, got %d: %q", len(expect), len(got), got)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Errorf("want: %s\ngot: %s", expect[i], got[i])
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestLeftNewLines(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	bufs := [][]byte{
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ast
+
+import (
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/pingcap/parser/ast"
+	tidbformat "github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// maxViewExpand 单条 SQL 中最多展开的视图数，防止视图定义有误时无限展开
+const maxViewExpand = 32
+
+// ViewDefinition 解析 CREATE VIEW 语句，返回视图的库名、视图名以及视图定义的 SELECT 语句
+// CREATE VIEW v (a, b) AS ... 中指定的列名会作为 SELECT 中字段的别名
+func ViewDefinition(sql string) (db, name, def string, ok bool) {
+	stmts, err := TiParse(sql, "", "")
+	if err != nil || len(stmts) != 1 {
+		return "", "", "", false
+	}
+	view, ok := stmts[0].(*ast.CreateViewStmt)
+	if !ok || view.Select == nil {
+		return "", "", "", false
+	}
+	if sel, ok := view.Select.(*ast.SelectStmt); ok && sel.Fields != nil && len(view.Cols) == len(sel.Fields.Fields) {
+		for i, field := range sel.Fields.Fields {
+			field.AsName = model.NewCIStr(view.Cols[i].O)
+		}
+	}
+
+	var sb strings.Builder
+	if err = view.Select.Restore(tidbformat.NewRestoreCtx(tidbformat.DefaultRestoreFlags, &sb)); err != nil {
+		common.Log.Warning("ViewDefinition Restore Error: %v, SQL: %s", err, sql)
+		return "", "", "", false
+	}
+	return view.ViewName.Schema.O, view.ViewName.Name.O, sb.String(), true
+}
+
+// ExpandView 将 SELECT 中引用的视图展开为子查询，使得后续的建议作用于视图引用的基表
+// lookup 根据库名（SQL 中未指定时为空）和表名返回视图定义，不是视图时返回空字符串
+func ExpandView(sql string, lookup func(db, table string) string) (string, bool) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return sql, false
+	}
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Union:
+	default:
+		return sql, false
+	}
+
+	expanded := 0
+	err = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		tbExpr, ok := node.(*sqlparser.AliasedTableExpr)
+		if !ok || expanded >= maxViewExpand {
+			return true, nil
+		}
+		tb, ok := tbExpr.Expr.(sqlparser.TableName)
+		if !ok || tb.Name.IsEmpty() {
+			return true, nil
+		}
+		def := lookup(tb.Qualifier.String(), tb.Name.String())
+		if def == "" {
+			return true, nil
+		}
+		viewStmt, err := sqlparser.Parse(def)
+		if err != nil {
+			common.Log.Warning("ExpandView sqlparser.Parse Error: %v, View: %s", err, tb.Name.String())
+			return true, nil
+		}
+		sel, ok := viewStmt.(sqlparser.SelectStatement)
+		if !ok {
+			return true, nil
+		}
+		// 展开后的子查询继续遍历，视图中引用的视图同样会被展开
+		tbExpr.Expr = &sqlparser.Subquery{Select: sel}
+		if tbExpr.As.IsEmpty() {
+			tbExpr.As = sqlparser.NewTableIdent(tb.Name.String())
+		}
+		// 派生表不支持索引提示及分区选择
+		tbExpr.Hints = nil
+		tbExpr.Partitions = nil
+		expanded++
+		return true, nil
+	}, stmt)
+	if err != nil || expanded == 0 {
+		return sql, false
+	}
+	return sqlparser.String(stmt), true
+}
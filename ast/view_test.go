@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestViewDefinition(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	db, name, def, ok := ViewDefinition("CREATE VIEW sakila.v_film (id, title) AS SELECT film_id, title FROM film WHERE length > 100")
+	if !ok || db != "sakila" || name != "v_film" {
+		t.Fatalf("ViewDefinition not match: %s, %s, %v", db, name, ok)
+	}
+	if def != "SELECT `film_id` AS `id`,`title` AS `title` FROM `film` WHERE `length`>100" {
+		t.Error("view definition not match:", def)
+	}
+	if _, _, _, ok = ViewDefinition("SELECT * FROM film"); ok {
+		t.Error("SELECT should not be a view definition")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestExpandView(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	views := map[string]string{
+		"v_film":   "select film_id, title from film where length > 100",
+		"v_actors": "select a.actor_id, f.title from actor a join v_film f on a.actor_id = f.film_id",
+	}
+	lookup := func(db, table string) string {
+		return views[strings.ToLower(table)]
+	}
+	sqls := [][]string{
+		{
+			"select title from v_film where film_id = 1",
+			"select title from (select film_id, title from film where length > 100) as v_film where film_id = 1",
+		},
+		{
+			"select * from v_actors va join language l using (language_id)",
+			"select * from (select a.actor_id, f.title from actor as a join (select film_id, title from film where length > 100) as f on a.actor_id = f.film_id) as va join `language` as l using (language_id)",
+		},
+		{
+			"select * from film where film_id in (select film_id from v_film)",
+			"select * from film where film_id in (select film_id from (select film_id, title from film where length > 100) as v_film)",
+		},
+	}
+	for _, sql := range sqls {
+		got, ok := ExpandView(sql[0], lookup)
+		if !ok || got != sql[1] {
+			t.Errorf("want: %s\ngot: %s", sql[1], got)
+		}
+	}
+
+	for _, sql := range []string{"select * from film", "update v_film set title = 'a'"} {
+		if got, ok := ExpandView(sql, lookup); ok || got != sql {
+			t.Error("should not expand:", sql, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
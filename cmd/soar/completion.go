@@ -0,0 +1,355 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XiaoMi/soar/advisor"
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+)
+
+// completionFlag 自动补全使用的命令行参数信息
+type completionFlag struct {
+	Name   string
+	Desc   string   // 单行说明
+	Bool   bool     // 开关类参数不需要值
+	Values []string // 可选值
+	List   bool     // 可选值以逗号分隔，可以指定多个
+	File   bool     // 值为文件
+	Dir    bool     // 值为目录
+}
+
+// completionSubcommand 子命令及其下一级参数
+type completionSubcommand struct {
+	Name string
+	Desc string
+	Args []string
+}
+
+// vitessFlags vitess 注册的参数，与 SOAR 无关，不参与补全
+var vitessFlags = map[string]bool{
+	"alsologtostderr":       true,
+	"log_backtrace_at":      true,
+	"log_dir":               true,
+	"logtostderr":           true,
+	"sql-max-length-errors": true,
+	"sql-max-length-ui":     true,
+	"stderrthreshold":       true,
+	"v":                     true,
+	"vmodule":               true,
+}
+
+// completionFileFlags 值为文件或目录的参数，true 表示目录
+var completionFileFlags = map[string]bool{
+	"config":            false,
+	"query":             false,
+	"schema-file":       false,
+	"blacklist":         false,
+	"lang-file":         false,
+	"query-stats":       false,
+	"log-output":        false,
+	"rule-test":         false,
+	"report-css":        false,
+	"report-javascript": false,
+	"diff-base":         false,
+	"report-dir":        true,
+}
+
+// completionUsageNameRe 参数说明开头的参数名，如 "ReportType, "
+var completionUsageNameRe = regexp.MustCompile(`^[A-Za-z0-9]+,\s*`)
+
+// completionUsageValuesRe 参数说明中列出的可选值，如 [percona, tidb]
+var completionUsageValuesRe = regexp.MustCompile(`\[([a-z0-9-]+(?:, [a-z0-9-]+)+)\]`)
+
+// completionSubcommands 支持的子命令，与 initConfig 中的子命令保持一致
+func completionSubcommands() []completionSubcommand {
+	return []completionSubcommand{
+		{Name: "rules", Desc: "查看或测试评审规则", Args: []string{"show", "test"}},
+		{Name: "config", Desc: "查看生效的配置", Args: []string{"show"}},
+		{Name: "doctor", Desc: "检查配置文件及数据库环境"},
+		{Name: "serve", Desc: "启动评审页面"},
+		{Name: "history", Desc: "查看评审记录"},
+		{Name: "schema-audit", Desc: "检查建表语句"},
+		{Name: "lint", Desc: "以 lint 格式输出建议"},
+		{Name: "completion", Desc: "生成 shell 自动补全脚本", Args: []string{"bash", "zsh", "fish"}},
+		{Name: "help", Desc: "查看帮助", Args: []string{"rules"}},
+	}
+}
+
+// completionRuleIDs 所有启发式规则编号
+func completionRuleIDs() []string {
+	var ids []string
+	for _, item := range common.SortedKey(advisor.HeuristicRules) {
+		if item != "OK" {
+			ids = append(ids, item)
+		}
+	}
+	return ids
+}
+
+// completionFlags 从已注册的命令行参数生成补全信息
+func completionFlags() []completionFlag {
+	var reportTypes []string
+	for _, t := range common.ReportTypes {
+		reportTypes = append(reportTypes, t.Name)
+	}
+	var rewriteRules []string
+	for _, r := range ast.RewriteRules {
+		rewriteRules = append(rewriteRules, r.Name)
+	}
+	var langs []string
+	for l := range advisor.RuleLocales {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	values := map[string][]string{
+		"report-type":   reportTypes,
+		"ignore-rules":  completionRuleIDs(),
+		"show-rule":     completionRuleIDs(),
+		"rewrite-rules": rewriteRules,
+		"lang":          langs,
+		"completion":    {"bash", "zsh", "fish"},
+	}
+
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		if vitessFlags[f.Name] || strings.HasPrefix(f.Name, "test.") {
+			return
+		}
+		cf := completionFlag{Name: f.Name, Desc: completionDesc(f.Usage)}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.Bool = true
+		} else if dir, ok := completionFileFlags[f.Name]; ok {
+			cf.File, cf.Dir = !dir, dir
+		} else if v, ok := values[f.Name]; ok {
+			cf.Values = v
+			cf.List = f.Name == "ignore-rules" || f.Name == "rewrite-rules"
+		} else if m := completionUsageValuesRe.FindStringSubmatch(f.Usage); m != nil {
+			cf.Values = strings.Split(m[1], ", ")
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+// completionDesc 参数说明去除开头的参数名后截取第一句
+func completionDesc(usage string) string {
+	desc := completionUsageNameRe.ReplaceAllString(usage, "")
+	for _, sep := range []string{"，", "。", "\n"} {
+		if i := strings.Index(desc, sep); i > 0 {
+			desc = desc[:i]
+		}
+	}
+	if r := []rune(desc); len(r) > 60 {
+		desc = string(r[:60])
+	}
+	return strings.TrimSpace(desc)
+}
+
+// completion for `-completion` flag 及 soar completion 子命令，输出 bash, zsh, fish 的自动补全脚本
+func completion(shell string) int {
+	switch shell {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print(zshCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		fmt.Printf("shell '%s' not support, available: bash, zsh, fish\n", shell)
+		return 1
+	}
+	return 0
+}
+
+// bashCompletion 生成 bash 自动补全脚本
+func bashCompletion() string {
+	var buf strings.Builder
+	flags := completionFlags()
+	var names, subcommands []string
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+	for _, s := range completionSubcommands() {
+		subcommands = append(subcommands, s.Name)
+	}
+
+	buf.WriteString("# soar bash completion\n# 使用方法: soar completion bash > /etc/bash_completion.d/soar\n\n")
+	buf.WriteString("_soar_list() {\n" +
+		"    # 逗号分隔的多个值只补全最后一个\n" +
+		"    if [[ \"$cur\" == *,* ]]; then\n" +
+		"        COMPREPLY=($(compgen -P \"${cur%,*},\" -W \"$1\" -- \"${cur##*,}\"))\n" +
+		"    else\n" +
+		"        COMPREPLY=($(compgen -W \"$1\" -- \"$cur\"))\n" +
+		"    fi\n" +
+		"}\n\n")
+	buf.WriteString("_soar() {\n" +
+		"    local cur prev idx\n" +
+		"    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n" +
+		"    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n" +
+		"    # COMP_WORDBREAKS 中包含 '='，-flag=value 会被拆分为三个词\n" +
+		"    if [[ \"$cur\" == \"=\" ]]; then\n" +
+		"        cur=\"\"\n" +
+		"    elif [[ \"$prev\" == \"=\" ]]; then\n" +
+		"        prev=\"${COMP_WORDS[COMP_CWORD-2]}\"\n" +
+		"    fi\n\n")
+	buf.WriteString("    case \"$prev\" in\n")
+	var files, dirs []string
+	for _, f := range flags {
+		switch {
+		case f.File:
+			files = append(files, "-"+f.Name)
+		case f.Dir:
+			dirs = append(dirs, "-"+f.Name)
+		case f.List:
+			fmt.Fprintf(&buf, "    -%s)\n        _soar_list \"%s\"\n        return ;;\n", f.Name, strings.Join(f.Values, " "))
+		case len(f.Values) > 0:
+			fmt.Fprintf(&buf, "    -%s)\n        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n        return ;;\n", f.Name, strings.Join(f.Values, " "))
+		}
+	}
+	fmt.Fprintf(&buf, "    %s)\n        COMPREPLY=($(compgen -f -- \"$cur\"))\n        return ;;\n", strings.Join(files, "|"))
+	fmt.Fprintf(&buf, "    %s)\n        COMPREPLY=($(compgen -d -- \"$cur\"))\n        return ;;\n", strings.Join(dirs, "|"))
+	buf.WriteString("    esac\n\n")
+
+	buf.WriteString("    # 子命令可以放在 -config 之后\n" +
+		"    idx=1\n" +
+		"    if [[ \"${COMP_WORDS[1]}\" == \"-config\" ]]; then\n" +
+		"        idx=3\n" +
+		"        [[ \"${COMP_WORDS[2]}\" == \"=\" ]] && idx=4\n" +
+		"    fi\n" +
+		"    if [[ $COMP_CWORD -gt $idx ]]; then\n" +
+		"        case \"${COMP_WORDS[idx]} ${COMP_WORDS[idx+1]}\" in\n")
+	fmt.Fprintf(&buf, "        \"rules show\")\n            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n            return ;;\n", strings.Join(completionRuleIDs(), " "))
+	buf.WriteString("        \"rules test\")\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n")
+	buf.WriteString("        \"config show\")\n            COMPREPLY=($(compgen -W \"--resolved\" -- \"$cur\"))\n            return ;;\n")
+	for _, s := range completionSubcommands() {
+		if len(s.Args) > 0 {
+			fmt.Fprintf(&buf, "        \"%s \"*)\n            [[ $COMP_CWORD -eq $((idx+1)) ]] && COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n            return ;;\n",
+				s.Name, strings.Join(s.Args, " "))
+		}
+	}
+	buf.WriteString("        esac\n    fi\n\n")
+	buf.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&buf, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	buf.WriteString("    elif [[ $COMP_CWORD -eq $idx ]]; then\n")
+	fmt.Fprintf(&buf, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(subcommands, " "))
+	buf.WriteString("    fi\n}\n\ncomplete -o default -F _soar soar\n")
+	return buf.String()
+}
+
+// zshEscape 转义 _arguments 说明中的特殊字符
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `:`, `\:`, `'`, `'\''`).Replace(s)
+}
+
+// zshCompletion 生成 zsh 自动补全脚本
+func zshCompletion() string {
+	var buf strings.Builder
+	buf.WriteString("#compdef soar\n# soar zsh completion\n# 使用方法: soar completion zsh > \"${fpath[1]}/_soar\"\n\n")
+	buf.WriteString("_soar() {\n    local -a subcommands\n    subcommands=(\n")
+	for _, s := range completionSubcommands() {
+		fmt.Fprintf(&buf, "        '%s:%s'\n", s.Name, zshEscape(s.Desc))
+	}
+	buf.WriteString("    )\n\n    _arguments \\\n")
+	for _, f := range completionFlags() {
+		desc := zshEscape(f.Desc)
+		switch {
+		case f.Bool:
+			fmt.Fprintf(&buf, "        '-%s[%s]' \\\n", f.Name, desc)
+		case f.File:
+			fmt.Fprintf(&buf, "        '-%s=[%s]:file:_files' \\\n", f.Name, desc)
+		case f.Dir:
+			fmt.Fprintf(&buf, "        '-%s=[%s]:directory:_files -/' \\\n", f.Name, desc)
+		case f.List:
+			fmt.Fprintf(&buf, "        '-%s=[%s]:%s:_sequence compadd - %s' \\\n", f.Name, desc, f.Name, strings.Join(f.Values, " "))
+		case len(f.Values) > 0:
+			fmt.Fprintf(&buf, "        '-%s=[%s]:%s:(%s)' \\\n", f.Name, desc, f.Name, strings.Join(f.Values, " "))
+		default:
+			fmt.Fprintf(&buf, "        '-%s=[%s]:%s:' \\\n", f.Name, desc, f.Name)
+		}
+	}
+	buf.WriteString("        '1: :->command' \\\n        '*:: :->args'\n\n")
+	buf.WriteString("    case $state in\n    command)\n        _describe -t commands 'soar command' subcommands\n        _files\n        ;;\n")
+	buf.WriteString("    args)\n        case \"$words[1] $words[2]\" in\n")
+	fmt.Fprintf(&buf, "        \"rules show\") compadd - %s ;;\n", strings.Join(completionRuleIDs(), " "))
+	buf.WriteString("        \"rules test\") _files ;;\n")
+	buf.WriteString("        \"config show\") compadd - --resolved ;;\n")
+	for _, s := range completionSubcommands() {
+		if len(s.Args) > 0 {
+			fmt.Fprintf(&buf, "        \"%s \"*) (( CURRENT == 2 )) && compadd - %s ;;\n", s.Name, strings.Join(s.Args, " "))
+		}
+	}
+	buf.WriteString("        *) _files ;;\n        esac\n        ;;\n    esac\n}\n\n_soar \"$@\"\n")
+	return buf.String()
+}
+
+// fishEscape 转义 fish 单引号字符串
+func fishEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// fishListFunc 输出逗号分隔参数可选值的 fish 函数名
+func fishListFunc(name string) string {
+	return "__soar_" + strings.Replace(name, "-", "_", -1) + "_values"
+}
+
+// fishCompletion 生成 fish 自动补全脚本
+func fishCompletion() string {
+	var buf strings.Builder
+	buf.WriteString("# soar fish completion\n# 使用方法: soar completion fish > ~/.config/fish/completions/soar.fish\n\n")
+	buf.WriteString("complete -c soar -f\n")
+	flags := completionFlags()
+	for _, f := range flags {
+		if f.List {
+			fmt.Fprintf(&buf, "function %s\n    printf '%%s\\n' %s\nend\n", fishListFunc(f.Name), strings.Join(f.Values, " "))
+		}
+	}
+	for _, s := range completionSubcommands() {
+		fmt.Fprintf(&buf, "complete -c soar -n '__fish_use_subcommand' -a %s -d '%s'\n", s.Name, fishEscape(s.Desc))
+		if len(s.Args) > 0 {
+			fmt.Fprintf(&buf, "complete -c soar -n '__fish_seen_subcommand_from %s; and not __fish_seen_subcommand_from %s' -a '%s'\n",
+				s.Name, strings.Join(s.Args, " "), strings.Join(s.Args, " "))
+		}
+	}
+	fmt.Fprintf(&buf, "complete -c soar -n '__fish_seen_subcommand_from show; and __fish_seen_subcommand_from rules' -a '%s'\n", strings.Join(completionRuleIDs(), " "))
+	buf.WriteString("complete -c soar -n '__fish_seen_subcommand_from show; and __fish_seen_subcommand_from config' -a '--resolved'\n")
+	buf.WriteString("complete -c soar -n '__fish_seen_subcommand_from test; and __fish_seen_subcommand_from rules' -F\n")
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c soar -o %s -d '%s'", f.Name, fishEscape(f.Desc))
+		switch {
+		case f.Bool:
+		case f.File:
+			line += " -r -F"
+		case f.Dir:
+			line += " -r -a '(__fish_complete_directories)'"
+		case f.List:
+			line += fmt.Sprintf(" -x -a '(__fish_complete_list , %s)'", fishListFunc(f.Name))
+		case len(f.Values) > 0:
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.Values, " "))
+		default:
+			line += " -x"
+		}
+		buf.WriteString(line + "\n")
+	}
+	return buf.String()
+}
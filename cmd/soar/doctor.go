@@ -0,0 +1,295 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/advisor"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// doctorMinVersion 低于该版本时 EXPLAIN FORMAT=JSON, Trace 等功能不可用
+const doctorMinVersion = 50600
+
+// doctorResult 单项检查的结果
+type doctorResult struct {
+	Status  string // OK, WARN, FAIL
+	Item    string // 检查项，如 config, test-dsn
+	Message string
+	Hint    string // 修复建议
+}
+
+// doctorReport 收集所有检查结果
+type doctorReport struct {
+	results []doctorResult
+}
+
+func (r *doctorReport) add(status, item, message, hint string) {
+	r.results = append(r.results, doctorResult{Status: status, Item: item, Message: message, Hint: hint})
+}
+
+func (r *doctorReport) ok(item, message string) {
+	r.add("OK", item, message, "")
+}
+
+func (r *doctorReport) warn(item, message, hint string) {
+	r.add("WARN", item, message, hint)
+}
+
+func (r *doctorReport) fail(item, message, hint string) {
+	r.add("FAIL", item, message, hint)
+}
+
+// failed 是否有检查失败
+func (r *doctorReport) failed() bool {
+	for _, res := range r.results {
+		if res.Status == "FAIL" {
+			return true
+		}
+	}
+	return false
+}
+
+// String 每项检查输出一行，有修复建议时在下一行给出
+func (r *doctorReport) String() string {
+	var buf []string
+	for _, res := range r.results {
+		buf = append(buf, fmt.Sprintf("[%s] %s: %s", res.Status, res.Item, res.Message))
+		if res.Hint != "" {
+			buf = append(buf, "       建议: "+res.Hint)
+		}
+	}
+	return strings.Join(buf, "\n")
+}
+
+// doctor for `-doctor` flag 及 soar doctor 子命令，检查配置文件、数据库连接、权限及版本
+// 有检查失败时返回非零
+func doctor() int {
+	// 连接失败时直接报告，不需要重试
+	common.Config.QueryRetry = 0
+	report := &doctorReport{}
+	doctorConfig(report)
+	doctorDSN(report)
+	fmt.Println(report.String())
+	if report.failed() {
+		return 1
+	}
+	return 0
+}
+
+// doctorConfig 检查配置文件格式及配置项的取值
+func doctorConfig(report *doctorReport) {
+	if common.ConfigFile == "" {
+		report.warn("config", "未找到配置文件，使用默认配置", "使用 -config 或 SOAR_CONFIG 指定配置文件，或创建 ./soar.yaml")
+	} else if errs, err := common.ValidateConfigFile(common.ConfigFile); err != nil {
+		report.fail("config", fmt.Sprintf("%s 解析失败: %v", common.ConfigFile, err), "检查 yaml 格式，缩进只能使用空格")
+	} else if len(errs) > 0 {
+		for _, e := range errs {
+			report.fail("config", fmt.Sprintf("%s %s", common.ConfigFile, e), "删除未知的配置项或修正类型，支持的配置项参见 soar -print-config")
+		}
+	} else {
+		report.ok("config", common.ConfigFile)
+	}
+
+	var reportTypes []string
+	valid := false
+	for _, t := range common.ReportTypes {
+		reportTypes = append(reportTypes, t.Name)
+		valid = valid || t.Name == common.Config.ReportType
+	}
+	if !valid {
+		report.fail("report-type", fmt.Sprintf("不支持的报告类型 '%s'", common.Config.ReportType), "可选: "+strings.Join(reportTypes, ", "))
+	}
+
+	switch common.Config.Dialect {
+	case "", "mysql", "tidb", "clickhouse":
+	default:
+		report.fail("dialect", fmt.Sprintf("不支持的 SQL 方言 '%s'", common.Config.Dialect), "可选: mysql, tidb, clickhouse")
+	}
+
+	switch common.Config.Cluster {
+	case "", "group-replication", "galera":
+	default:
+		report.fail("cluster", fmt.Sprintf("不支持的集群类型 '%s'", common.Config.Cluster), "可选: group-replication, galera")
+	}
+
+	if _, err := common.ParseTarget(common.Config.Target); err != nil {
+		report.fail("target", err.Error(), "格式为 flavor[:version]，如 mysql:8.0, mariadb:10.6")
+	}
+
+	for _, ir := range common.Config.IgnoreRules {
+		prefix := strings.Trim(ir, "*")
+		if prefix == "" {
+			continue
+		}
+		known := false
+		for item := range advisor.HeuristicRules {
+			if strings.HasPrefix(item, prefix) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			report.warn("ignore-rules", fmt.Sprintf("'%s' 未匹配任何规则", ir), "使用 soar -list-heuristic-rules 查看规则编号")
+		}
+	}
+
+	files := map[string]string{
+		"blacklist":   common.Config.BlackList,
+		"schema-file": common.Config.SchemaFile,
+		"lang-file":   common.Config.LangFile,
+		"query-stats": common.Config.QueryStats,
+	}
+	for _, item := range common.SortedKey(files) {
+		if files[item] == "" {
+			continue
+		}
+		if _, err := os.Stat(files[item]); err != nil {
+			report.fail(item, err.Error(), "检查文件路径，相对路径以当前工作目录为准")
+		}
+	}
+}
+
+// doctorDSN 检查 test-dsn, online-dsn 的连接、权限及版本
+func doctorDSN(report *doctorReport) {
+	testDSN, onlineDSN := common.Config.TestDSN, common.Config.OnlineDSN
+	if testDSN.Disable {
+		report.warn("test-dsn", "未启用，只给出启发式建议", "配置 test-dsn 后才能给出 EXPLAIN 解读及索引建议")
+		if !onlineDSN.Disable {
+			report.warn("online-dsn", "test-dsn 未启用时不会使用 online-dsn", "同时配置 test-dsn，或设置 allow-online-as-test 将 online-dsn 作为测试环境")
+		}
+		return
+	}
+
+	testVersion, ok := doctorConnect(report, "test-dsn", testDSN, func(conn *database.Connector) {
+		if !conn.HasAllPrivilege() {
+			report.fail("test-dsn", fmt.Sprintf("%s 没有全部权限", conn.User),
+				fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO '%s'@'%%'; 测试环境需要在临时库中建表、导入数据", conn.User))
+		}
+		// 测试环境会创建 optimizer_ 开头的临时库
+		sandbox := fmt.Sprintf("optimizer_doctor_%d", time.Now().Unix())
+		if _, err := conn.Query(fmt.Sprintf("CREATE DATABASE `%s`", sandbox)); err != nil {
+			report.fail("test-dsn", "无法创建临时库: "+err.Error(), "测试环境需要 CREATE, DROP 权限")
+			return
+		}
+		if _, err := conn.Query(fmt.Sprintf("DROP DATABASE `%s`", sandbox)); err != nil {
+			report.warn("test-dsn", "无法删除临时库 "+sandbox+": "+err.Error(), "手工删除或使用 -cleanup-test-database 清理")
+			return
+		}
+		report.ok("test-dsn", "可以创建及删除临时库")
+	})
+
+	if onlineDSN.Disable {
+		report.warn("online-dsn", "未启用，无法获取线上表结构及数据分布", "配置 online-dsn 后才能基于真实表结构给出索引建议")
+		return
+	}
+	if common.FormatDSN(onlineDSN) == common.FormatDSN(testDSN) && !common.Config.AllowOnlineAsTest {
+		report.fail("online-dsn", "与 test-dsn 相同，test-dsn 及 online-dsn 都不会被使用", "使用单独的测试环境，或设置 allow-online-as-test: true")
+	}
+	onlineVersion, _ := doctorConnect(report, "online-dsn", onlineDSN, func(conn *database.Connector) {
+		conn.ReadOnly = true
+		if !conn.HasSelectPrivilege() {
+			report.warn("online-dsn", fmt.Sprintf("%s 没有全局 SELECT 权限", conn.User),
+				fmt.Sprintf("GRANT SELECT ON *.* TO '%s'@'%%'; 仅有库级权限时部分表结构及统计信息无法获取", conn.User))
+		}
+		table, err := doctorTable(conn)
+		if err != nil {
+			report.fail("online-dsn", "无法查询 information_schema: "+err.Error(), "检查 schema 名称及 SELECT 权限")
+			return
+		}
+		if table == "" {
+			report.warn("online-dsn", fmt.Sprintf("%s 中没有可见的表", conn.Database), "检查 schema 名称及 SELECT 权限")
+			return
+		}
+		if _, err = conn.Query(fmt.Sprintf("EXPLAIN SELECT * FROM `%s`.`%s` LIMIT 1", conn.Database, table)); err != nil {
+			report.fail("online-dsn", "无法执行 EXPLAIN: "+err.Error(), "online-allow-list 需要包含 explain，账号需要表的 SELECT 权限")
+			return
+		}
+		report.ok("online-dsn", "可以执行 SELECT, EXPLAIN")
+	})
+
+	if ok && onlineVersion != 0 && testVersion < onlineVersion {
+		report.warn("test-dsn", fmt.Sprintf("版本 %d 低于 online-dsn 版本 %d，test-dsn 不会被使用", testVersion, onlineVersion),
+			"测试环境的版本需要不低于线上环境")
+	}
+}
+
+// doctorConnect 连接数据库并检查版本，连接成功后执行 check，返回数据库版本
+func doctorConnect(report *doctorReport, item string, dsn *common.Dsn, check func(conn *database.Connector)) (int, bool) {
+	conn, err := database.NewConnector(dsn)
+	if err != nil {
+		report.fail(item, err.Error(), "检查 DSN 格式，如 user:password@tcp(127.0.0.1:3306)/sakila")
+		return 0, false
+	}
+	defer conn.Close()
+	version, err := conn.Version()
+	if err != nil {
+		report.fail(item, fmt.Sprintf("无法连接 %s: %v", dsn.Addr, err), doctorHint(err))
+		return 0, false
+	}
+	report.ok(item, fmt.Sprintf("%s@%s/%s, 版本 %d", conn.User, conn.Addr, conn.Database, version))
+	if version < doctorMinVersion {
+		report.warn(item, fmt.Sprintf("版本 %d 低于 5.6", version), "EXPLAIN FORMAT=JSON, Trace 等功能不可用，建议使用 5.6 及以上版本")
+	}
+	check(conn)
+	return version, true
+}
+
+// doctorTable 获取 schema 中的一张表，用于检查 EXPLAIN
+func doctorTable(conn *database.Connector) (string, error) {
+	res, err := conn.Query(fmt.Sprintf("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = '%s' AND TABLE_TYPE = 'BASE TABLE' LIMIT 1",
+		database.Escape(conn.Database, false)))
+	if err != nil {
+		return "", err
+	}
+	defer res.Rows.Close()
+	var table string
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&table)
+	}
+	return table, err
+}
+
+// doctorHint 根据连接错误给出修复建议
+func doctorHint(err error) string {
+	if e, ok := err.(*mysql.MySQLError); ok {
+		switch e.Number {
+		case 1045:
+			return "用户名或密码错误，配置了 password-command, vault-path 时检查获取到的密码"
+		case 1044, 1049:
+			return "schema 不存在或没有访问权限"
+		case 1130:
+			return "数据库不允许从当前主机连接，检查账号的 host"
+		}
+	}
+	if err == driver.ErrBadConn || err == mysql.ErrInvalidConn {
+		return "连接被服务端中断，检查 addr 是否为 MySQL 端口，服务端要求加密连接时配置 tls-ca 等 TLS 参数"
+	}
+	if _, ok := err.(net.Error); ok || strings.Contains(err.Error(), "connection refused") {
+		return "检查 addr 中的主机及端口、网络及防火墙，需要跳板机时配置 ssh-host"
+	}
+	return "检查 DSN 配置"
+}
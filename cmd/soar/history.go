@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/advisor"
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+
+	"github.com/percona/go-mysql/query"
+)
+
+// historyLimit soar history 输出的最近评审记录条数
+const historyLimit = 50
+
+// openHistory 连接 history-dsn，未配置或连接失败时返回 nil，不影响评审
+func openHistory() *database.History {
+	if common.Config.HistoryDSN == "" {
+		return nil
+	}
+	h, err := database.OpenHistory(common.ParseDSN(common.Config.HistoryDSN, nil))
+	if err != nil {
+		common.Log.Warning("openHistory Error: %v", err)
+		return nil
+	}
+	return h
+}
+
+// recordHistory 保存一条 SQL 的评审结果，plan 为执行计划摘要
+func recordHistory(h *database.History, sql, file string, line int, suggest map[string]advisor.Rule, plan string, planRows int64) {
+	if h == nil {
+		return
+	}
+	fingerprint := advisor.Fingerprint(sql)
+	rec := database.AuditRecord{
+		QueryID:     query.Id(fingerprint),
+		Fingerprint: fingerprint,
+		Sample:      sql,
+		File:        file,
+		Line:        line,
+		Score:       advisor.SuggestScore(suggest),
+		Plan:        plan,
+		PlanRows:    planRows,
+	}
+	for _, item := range common.SortedKey(suggest) {
+		if item == "OK" {
+			continue
+		}
+		rec.Findings = append(rec.Findings, database.HistoryFinding{Item: item, Severity: suggest[item].Severity})
+	}
+	common.LogIfWarn(h.Record(rec), "")
+}
+
+// showHistory for `-show-history` flag 及 soar history 子命令，输出 history-dsn 中保存的评审记录
+func showHistory() int {
+	if common.Config.HistoryDSN == "" {
+		fmt.Println("history-dsn is not configured")
+		return 1
+	}
+	h, err := database.OpenHistory(common.ParseDSN(common.Config.HistoryDSN, nil))
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	defer h.Close()
+	queryID := common.ShowHistory
+	if strings.ToLower(queryID) == "all" {
+		queryID = ""
+	}
+	records, err := h.Records(strings.ToUpper(queryID), historyLimit)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	fmt.Println(formatHistory(records))
+	return 0
+}
+
+// formatHistory 按时间倒序输出评审记录，执行计划与该 SQL 上一次评审不同时标记为执行计划变化
+func formatHistory(records []database.AuditRecord) string {
+	if len(records) == 0 {
+		return "未找到评审记录"
+	}
+	buf := []string{
+		"| 时间 | Query ID | 位置 | 得分 | 建议 | 执行计划 | 扫描行数 |",
+		"|---|---|---|---|---|---|---|",
+	}
+	for i, rec := range records {
+		var items []string
+		for _, f := range rec.Findings {
+			items = append(items, f.Item+"("+f.Severity+")")
+		}
+		plan := rec.Plan
+		for _, prev := range records[i+1:] {
+			if prev.QueryID != rec.QueryID {
+				continue
+			}
+			if database.PlanChanged(prev, rec) {
+				plan = fmt.Sprintf("**执行计划变化** %s (之前: %s, %d 行)", rec.Plan, prev.Plan, prev.PlanRows)
+			}
+			break
+		}
+		buf = append(buf, fmt.Sprintf("| %s | %s | %s:%d | %d | %s | %s | %d |",
+			rec.CreatedAt.Format("2006-01-02 15:04:05"), rec.QueryID, rec.File, rec.Line, rec.Score,
+			strings.Join(items, ", "), plan, rec.PlanRows))
+	}
+	return strings.Join(buf, "\n")
+}
+
+// historyStore 保存在 history-dsn 中的评审页面评审记录，配置了 history-dsn 时 soar serve 使用该存储
+type historyStore struct {
+	h *database.History
+}
+
+// add 保存一次评审的结果，返回记录编号
+func (s historyStore) add(e serveEntry) (int, error) {
+	id, err := s.h.SaveReview(database.Review{Target: e.Target, Query: e.Query, Report: e.Report, CreatedAt: e.Time})
+	return int(id), err
+}
+
+// get 按编号查找评审记录
+func (s historyStore) get(id int) (serveEntry, bool, error) {
+	r, err := s.h.Review(int64(id))
+	if err != nil || r == nil {
+		return serveEntry{}, false, err
+	}
+	return reviewEntry(*r), true, nil
+}
+
+// recent 按时间倒序返回最近 limit 条评审记录
+func (s historyStore) recent(limit int) ([]serveEntry, error) {
+	reviews, err := s.h.Reviews(limit)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]serveEntry, 0, len(reviews))
+	for _, r := range reviews {
+		entries = append(entries, reviewEntry(r))
+	}
+	return entries, nil
+}
+
+// reviewEntry 将 history-dsn 中的评审记录转换为评审页面的记录
+func reviewEntry(r database.Review) serveEntry {
+	return serveEntry{ID: int(r.ID), Time: r.CreatedAt, Target: r.Target, Query: r.Query, Report: r.Report}
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/XiaoMi/soar/advisor"
+	"github.com/XiaoMi/soar/common"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// serveReloadInterval 检查配置文件及 lang-file 修改时间的间隔
+const serveReloadInterval = 5 * time.Second
+
+// serveReloader 监视配置文件及 lang-file（自定义的规则文本），修改后或收到 SIGHUP 时重新加载评审页面的配置
+// 每次评审使用独立的进程，会重新读取配置文件，重新加载只影响页面进程中的 target, serve-tokens, serve-rate-limit，
+// 并在加载前校验配置及规则文本，校验失败时页面拒绝评审，直到配置修复
+type serveReloader struct {
+	server *auditServer
+	conf   *common.Configuration // 当前生效的配置
+	mtimes map[string]time.Time  // 监视的文件及修改时间
+
+	// load 读取并校验配置
+	load func() (*common.Configuration, error)
+}
+
+// newServeReloader 创建 serveReloader，当前生效的配置为 common.Config
+func newServeReloader(s *auditServer) *serveReloader {
+	r := &serveReloader{server: s, conf: common.Config, load: loadServeConfig}
+	r.mtimes = r.stat()
+	return r
+}
+
+// stat 返回监视的文件及修改时间，文件不存在时修改时间为零值
+func (r *serveReloader) stat() map[string]time.Time {
+	mtimes := make(map[string]time.Time)
+	for _, file := range []string{common.ConfigFile, r.conf.LangFile} {
+		if file == "" {
+			continue
+		}
+		var mtime time.Time
+		if fi, err := os.Stat(file); err == nil {
+			mtime = fi.ModTime()
+		}
+		mtimes[file] = mtime
+	}
+	return mtimes
+}
+
+// watch 每隔 interval 检查文件的修改时间，有变化或收到 SIGHUP 时重新加载
+func (r *serveReloader) watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hup:
+			r.reload()
+		case <-ticker.C:
+			if !reflect.DeepEqual(r.stat(), r.mtimes) {
+				r.reload()
+			}
+		}
+	}
+}
+
+// reload 重新加载配置及规则文本，在标准输出中记录变更的配置项、规则阈值及规则文本，失败时保留之前的配置并拒绝评审
+func (r *serveReloader) reload() {
+	r.mtimes = r.stat()
+	next, err := r.load()
+	var texts map[string]string
+	if err == nil {
+		texts = ruleTexts()
+		// 页面进程中的规则文本只用于对比变更，评审进程会重新加载
+		err = advisor.LoadRuleLocale(next.Lang, next.LangFile)
+	}
+	if err == nil {
+		err = r.server.apply(next)
+	}
+	if err != nil {
+		r.server.fail(err)
+		serveLog("reload failed, reviews are rejected until the configuration is fixed: %v", err)
+		return
+	}
+
+	changes := diffServeConfig(r.conf, next)
+	current := ruleTexts()
+	for _, item := range common.SortedKey(texts) {
+		if current[item] != texts[item] {
+			changes = append(changes, fmt.Sprintf("rule %s: text changed", item))
+		}
+	}
+	if len(changes) == 0 {
+		changes = []string{"no changes"}
+	}
+	for _, change := range changes {
+		serveLog("reload %s", change)
+	}
+	r.conf = next
+	r.mtimes = r.stat()
+}
+
+// ruleTexts 返回各规则当前的 Summary 及 Content
+func ruleTexts() map[string]string {
+	texts := make(map[string]string)
+	for item, rule := range advisor.HeuristicRules {
+		texts[item] = rule.Summary + "\n" + rule.Content
+	}
+	return texts
+}
+
+// loadServeConfig 严格检查配置文件后，调用当前 soar 二进制输出评审进程使用的配置，即配置文件、环境变量及命令行参数合并后的结果
+// 评审进程加载 lang-file 失败时直接退出，同样作为加载失败处理
+func loadServeConfig() (*common.Configuration, error) {
+	if common.ConfigFile != "" {
+		errs, err := common.ValidateConfigFile(common.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%s: %s", common.ConfigFile, strings.Join(errs, "; "))
+		}
+	}
+	ex, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	// -verbose 时输出的配置中不隐藏密码及访问令牌
+	args := append(serveArgs(os.Args[1:]), "-verbose", "-print-config")
+	out, err := exec.Command(ex, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	conf := new(common.Configuration)
+	if err = yaml.Unmarshal(out, conf); err != nil {
+		return nil, err
+	}
+	conf.Verbose = common.Config.Verbose
+	return conf, nil
+}
+
+// diffServeConfig 对比两次加载的配置，返回变更的配置项
+// rule-thresholds 按规则列出阈值的变化，ignore-rules 列出增加及删除的规则，包含密码及访问令牌的配置项不输出取值
+func diffServeConfig(old, next *common.Configuration) []string {
+	oldItems, nextItems := configItems(old), configItems(next)
+	keys := make(map[string]bool)
+	for k := range oldItems {
+		keys[k] = true
+	}
+	for k := range nextItems {
+		keys[k] = true
+	}
+
+	var changes []string
+	for _, k := range common.SortedKey(keys) {
+		if reflect.DeepEqual(oldItems[k], nextItems[k]) {
+			continue
+		}
+		switch {
+		case k == "rule-thresholds":
+			items := make(map[string]bool)
+			for item := range old.RuleThresholds {
+				items[item] = true
+			}
+			for item := range next.RuleThresholds {
+				items[item] = true
+			}
+			for _, item := range common.SortedKey(items) {
+				o, ok1 := old.RuleThresholds[item]
+				n, ok2 := next.RuleThresholds[item]
+				if o != n || ok1 != ok2 {
+					changes = append(changes, fmt.Sprintf("rule-thresholds %s: %s -> %s", item, threshold(o, ok1), threshold(n, ok2)))
+				}
+			}
+		case k == "ignore-rules":
+			added, removed := sliceDiff(old.IgnoreRules, next.IgnoreRules), sliceDiff(next.IgnoreRules, old.IgnoreRules)
+			changes = append(changes, fmt.Sprintf("ignore-rules: added [%s], removed [%s]", strings.Join(added, ", "), strings.Join(removed, ", ")))
+		case strings.Contains(k, "dsn") || strings.Contains(k, "token") || strings.Contains(k, "secret"):
+			changes = append(changes, fmt.Sprintf("%s: changed", k))
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", k, oldItems[k], nextItems[k]))
+		}
+	}
+	return changes
+}
+
+// configItems 将配置转换为以配置项名称为 key 的 map
+func configItems(conf *common.Configuration) map[string]interface{} {
+	items := make(map[string]interface{})
+	data, err := yaml.Marshal(conf)
+	if err == nil {
+		err = yaml.Unmarshal(data, &items)
+	}
+	common.LogIfWarn(err, "")
+	// -print-config 输出的配置中不包含 profiles，选择的 profile 已合并至顶层配置
+	delete(items, "profiles")
+	return items
+}
+
+// threshold 未设置的阈值显示为 -
+func threshold(v int, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+// sliceDiff 返回在 b 中但不在 a 中的元素
+func sliceDiff(a, b []string) []string {
+	seen := make(map[string]bool)
+	for _, v := range a {
+		seen[v] = true
+	}
+	var res []string
+	for _, v := range b {
+		if !seen[v] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
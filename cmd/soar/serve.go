@@ -0,0 +1,603 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
+)
+
+const (
+	servePageSize     = 100             // 页面左侧列出的最近评审记录条数
+	serveMemorySize   = 100             // 未配置 serve-history 时内存中保留的评审记录条数
+	serveMaxQuerySize = 1 << 20         // 单次提交的 SQL 最大长度
+	serveAuditTimeout = 5 * time.Minute // 单次评审的最长耗时
+)
+
+// serveEntry 一次评审的记录
+type serveEntry struct {
+	ID     int       `json:"id"`
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+	Query  string    `json:"query"`
+	Report string    `json:"report"` // HTML 格式的评审报告
+}
+
+// Title 历史列表中显示的 SQL 摘要
+func (e serveEntry) Title() string {
+	title := strings.Join(strings.Fields(e.Query), " ")
+	if r := []rune(title); len(r) > 80 {
+		title = string(r[:80]) + "..."
+	}
+	return title
+}
+
+// reviewStore 评审页面的评审记录存储
+type reviewStore interface {
+	// add 保存一次评审的结果，返回记录编号
+	add(e serveEntry) (int, error)
+	// get 按编号查找评审记录，记录不存在时返回 false
+	get(id int) (serveEntry, bool, error)
+	// recent 按时间倒序返回最近 limit 条评审记录，不包含评审报告
+	recent(limit int) ([]serveEntry, error)
+}
+
+// fileStore 保存在 serve-history 文件中的评审记录，每行一条 JSON
+// 内存中只保存各条记录在文件中的位置，评审报告在查看时从文件中读取
+// 文件名为空时只保存在内存中，保留最近的 serveMemorySize 条
+type fileStore struct {
+	mu      sync.Mutex
+	file    string
+	entries []serveEntry // 按编号升序，保存在文件中时不包含 Report
+	offsets []int64      // 每条记录在文件中的起始位置
+	sizes   []int        // 每条记录在文件中的长度，不包含换行符
+	nextID  int
+}
+
+// newFileStore 创建 fileStore，加载 serve-history 文件中的所有评审记录
+func newFileStore(file string) *fileStore {
+	s := &fileStore{file: file, nextID: 1}
+	if file == "" {
+		return s
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			common.Log.Warning("newFileStore os.Open Error: %v", err)
+		}
+		return s
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		size := len(line)
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			var e serveEntry
+			if jsonErr := json.Unmarshal(line, &e); jsonErr != nil {
+				common.Log.Warning("newFileStore json.Unmarshal Error: %v", jsonErr)
+			} else {
+				e.Report = ""
+				s.push(e, offset, len(line))
+			}
+		}
+		offset += int64(size)
+		if err != nil {
+			if err != io.EOF {
+				common.Log.Warning("newFileStore ReadBytes Error: %v", err)
+			}
+			break
+		}
+	}
+	return s
+}
+
+// push 在内存中添加一条评审记录
+func (s *fileStore) push(e serveEntry, offset int64, size int) {
+	if e.ID >= s.nextID {
+		s.nextID = e.ID + 1
+	}
+	s.entries = append(s.entries, e)
+	s.offsets = append(s.offsets, offset)
+	s.sizes = append(s.sizes, size)
+	if s.file == "" && len(s.entries) > serveMemorySize {
+		n := len(s.entries) - serveMemorySize
+		s.entries, s.offsets, s.sizes = s.entries[n:], s.offsets[n:], s.sizes[n:]
+	}
+}
+
+// add 保存一次评审的结果，写入 serve-history 文件后只在内存中保留记录的位置
+func (s *fileStore) add(e serveEntry) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = s.nextID
+	if s.file == "" {
+		s.push(e, 0, 0)
+		return e.ID, nil
+	}
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = f.Write(append(buf, '\n')); err != nil {
+		return 0, err
+	}
+	e.Report = ""
+	s.push(e, offset, len(buf))
+	return e.ID, nil
+}
+
+// get 按编号查找评审记录，从 serve-history 文件中读取评审报告
+func (s *fileStore) get(id int) (serveEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].ID >= id })
+	if i == len(s.entries) || s.entries[i].ID != id {
+		return serveEntry{}, false, nil
+	}
+	if s.file == "" {
+		return s.entries[i], true, nil
+	}
+	f, err := os.Open(s.file)
+	if err != nil {
+		return serveEntry{}, false, err
+	}
+	defer f.Close()
+	buf := make([]byte, s.sizes[i])
+	if _, err = f.ReadAt(buf, s.offsets[i]); err != nil {
+		return serveEntry{}, false, err
+	}
+	var e serveEntry
+	if err = json.Unmarshal(buf, &e); err != nil {
+		return serveEntry{}, false, err
+	}
+	return e, true, nil
+}
+
+// recent 按时间倒序返回最近 limit 条评审记录
+func (s *fileStore) recent(limit int) ([]serveEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]serveEntry, 0, limit)
+	for i := len(s.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		e := s.entries[i]
+		e.Report = ""
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// auditServer -serve 启动的 Web 页面，粘贴 SQL 后调用 soar 输出 HTML 报告，并保存评审记录
+type auditServer struct {
+	store reviewStore
+
+	// 以下配置在热加载时更新
+	mu        sync.RWMutex
+	target    string            // 页面默认的目标数据库
+	tokens    map[string]string // 访问令牌对应的名称，为空时不鉴权
+	limiter   *serveLimiter     // 按客户端限制提交评审的频率
+	configErr error             // 热加载失败的原因，配置修复前拒绝评审
+
+	// run 对 query 进行评审，返回 HTML 报告
+	run func(ctx context.Context, query, target string) (string, error)
+}
+
+// newAuditServer 创建 auditServer，评审记录保存在 store 中，访问令牌及限流使用 serve-tokens, serve-rate-limit 配置
+func newAuditServer(store reviewStore) (*auditServer, error) {
+	s := &auditServer{store: store, run: runAudit}
+	if err := s.apply(common.Config); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// apply 使用 conf 中的 target, serve-tokens, serve-rate-limit 更新评审页面的配置，限流的次数不变时保留已有的计数
+func (s *auditServer) apply(conf *common.Configuration) error {
+	tokens, err := parseServeTokens(conf.ServeTokens)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.target = conf.Target
+	s.tokens = tokens
+	if s.limiter == nil || s.limiter.limit != conf.ServeRateLimit {
+		s.limiter = newServeLimiter(conf.ServeRateLimit, time.Minute)
+	}
+	s.configErr = nil
+	return nil
+}
+
+// fail 记录热加载失败的原因，配置修复前拒绝评审
+func (s *auditServer) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configErr = err
+}
+
+// parseServeTokens 解析 serve-tokens 配置，返回令牌对应的名称
+func parseServeTokens(tokens []string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, t := range tokens {
+		i := strings.Index(t, ":")
+		if i <= 0 || i == len(t)-1 {
+			return nil, fmt.Errorf("serve-tokens: %q should be name:token", strings.SplitN(t, ":", 2)[0])
+		}
+		res[t[i+1:]] = t[:i]
+	}
+	return res, nil
+}
+
+// ServeHTTP GET / 评审页面，POST /audit 提交评审，GET /audit?id=N 查看评审记录，GET /report?id=N 评审报告
+func (s *auditServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authorize(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="soar"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.URL.Path == "/" && r.Method == http.MethodGet:
+		s.mu.RLock()
+		target := s.target
+		s.mu.RUnlock()
+		s.page(w, serveEntry{Target: target})
+	case r.URL.Path == "/audit" && r.Method == http.MethodPost:
+		s.audit(w, r, user)
+	case r.URL.Path == "/audit" && r.Method == http.MethodGet:
+		e, ok := s.entry(w, r)
+		if !ok {
+			return
+		}
+		s.page(w, e)
+	case r.URL.Path == "/report" && r.Method == http.MethodGet:
+		e, ok := s.entry(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, e.Report)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize 校验请求中的访问令牌，返回令牌对应的名称，未配置 serve-tokens 时不校验
+// 令牌使用 Authorization: Bearer <令牌> 传递，浏览器访问时使用 HTTP Basic 认证，用户名为名称，密码为令牌
+func (s *auditServer) authorize(r *http.Request) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.tokens) == 0 {
+		return "", true
+	}
+	user, token, basic := r.BasicAuth()
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	} else if !basic {
+		return "", false
+	}
+	for t, name := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 && (!basic || user == name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// entry 按请求参数中的 id 查找评审记录，找不到时输出错误页面
+func (s *auditServer) entry(w http.ResponseWriter, r *http.Request) (serveEntry, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return serveEntry{}, false
+	}
+	e, ok, err := s.store.get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return serveEntry{}, false
+	}
+	if !ok {
+		http.NotFound(w, r)
+	}
+	return e, ok
+}
+
+// audit 评审提交的 SQL，完成后跳转至评审记录，避免刷新页面时重复提交，user 为访问令牌的名称
+func (s *auditServer) audit(w http.ResponseWriter, r *http.Request, user string) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request denied", http.StatusForbidden)
+		return
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	client := user
+	if client == "" {
+		client = host
+	}
+	s.mu.RLock()
+	limiter, configErr := s.limiter, s.configErr
+	s.mu.RUnlock()
+	if configErr != nil {
+		http.Error(w, "invalid configuration: "+configErr.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if !limiter.allow(client, time.Now()) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(limiter.window.Seconds())))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, serveMaxQuerySize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	query := strings.TrimSpace(r.PostForm.Get("query"))
+	target := strings.TrimSpace(r.PostForm.Get("target"))
+	if query == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if _, err := common.ParseTarget(target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), serveAuditTimeout)
+	defer cancel()
+	report, err := s.run(ctx, query, target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id, err := s.store.add(serveEntry{Time: time.Now(), Target: target, Query: query, Report: report})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 审计日志，记录谁提交了哪些 SQL
+	if user == "" {
+		user = "-"
+	}
+	serveLog("review %d user=%s addr=%s target=%s query=%s", id, user, host, target, strings.Join(strings.Fields(query), " "))
+	http.Redirect(w, r, fmt.Sprintf("/audit?id=%d", id), http.StatusSeeOther)
+}
+
+// serveLimiter 按客户端限制每个时间窗口内提交评审的次数
+type serveLimiter struct {
+	limit  int // 每个时间窗口内最多的次数，为 0 时不限制
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[string]serveWindow
+}
+
+// serveWindow 客户端当前时间窗口的开始时间及已提交的次数
+type serveWindow struct {
+	start time.Time
+	count int
+}
+
+// newServeLimiter 创建 serveLimiter，每个客户端在 window 内最多提交 limit 次
+func newServeLimiter(limit int, window time.Duration) *serveLimiter {
+	return &serveLimiter{limit: limit, window: window, clients: make(map[string]serveWindow)}
+}
+
+// allow 记录 client 在 now 的一次提交，超过限制时返回 false
+func (l *serveLimiter) allow(client string, now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for c, w := range l.clients {
+		if now.Sub(w.start) >= l.window {
+			delete(l.clients, c)
+		}
+	}
+	w, ok := l.clients[client]
+	if !ok {
+		w.start = now
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	l.clients[client] = w
+	return true
+}
+
+// serveLog 在标准输出中记录评审页面的提交及配置热加载
+func serveLog(format string, a ...interface{}) {
+	fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, a...))
+}
+
+// sameOrigin 判断提交评审的请求是否来自评审页面本身，避免其他网页通过浏览器向本机的评审页面提交 SQL
+// 浏览器提交表单时会带上 Origin 或 Referer，两者都没有时为 curl 等非浏览器的请求
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host == r.Host
+}
+
+// page 输出评审页面，cur.ID 不为 0 时在下方显示该次评审的报告
+func (s *auditServer) page(w http.ResponseWriter, cur serveEntry) {
+	history, err := s.store.recent(servePageSize)
+	common.LogIfWarn(err, "")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = servePage.Execute(w, struct {
+		Current serveEntry
+		History []serveEntry
+	}{cur, history})
+	common.LogIfWarn(err, "")
+}
+
+// runAudit 将 SQL 写入临时文件后调用当前 soar 二进制以 -report-type html 评审，使用与 -serve 相同的配置文件及命令行参数
+// 每次评审使用独立的进程，避免评审过程中修改的全局配置及测试环境互相影响
+func runAudit(ctx context.Context, query, target string) (string, error) {
+	f, err := ioutil.TempFile("", "soar-serve")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(query)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	args := serveArgs(os.Args[1:])
+	args = append(args, "-report-type=html", "-report-dir=", "-report-storage=", "-target="+target, "-query="+f.Name())
+	ex, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, ex, args...)
+	out, err := cmd.Output()
+	// 语法检查失败时以非零状态退出，但报告已正常输出
+	if len(out) > 0 {
+		return string(out), nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err == nil {
+		err = fmt.Errorf("empty report")
+	}
+	return "", err
+}
+
+// serveArgs 去除命令行参数中的 -serve，其余参数传给评审进程
+func serveArgs(args []string) []string {
+	var res []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-serve" || args[i] == "--serve":
+			i++ // -serve addr
+		case strings.HasPrefix(args[i], "-serve=") || strings.HasPrefix(args[i], "--serve="):
+		default:
+			res = append(res, args[i])
+		}
+	}
+	return res
+}
+
+// serve for `-serve` flag 及 soar serve 子命令，启动评审页面，监听地址默认为 127.0.0.1:5077
+// 配置了 history-dsn 时评审记录保存在 history-dsn 中，否则保存在 serve-history 文件中
+// 配置了 serve-tokens 时请求需要带上访问令牌，每次提交评审时在标准输出中记录提交者及 SQL
+// 配置文件及 lang-file 修改后或收到 SIGHUP 时重新加载配置，见 serveReloader
+func serve() int {
+	var store reviewStore = newFileStore(common.Config.ServeHistory)
+	if common.Config.HistoryDSN != "" {
+		h, err := database.OpenHistory(common.ParseDSN(common.Config.HistoryDSN, nil))
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+		defer h.Close()
+		store = historyStore{h: h}
+	}
+	s, err := newAuditServer(store)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	go newServeReloader(s).watch(serveReloadInterval)
+	fmt.Printf("soar web UI: http://%s/\n", common.Serve)
+	err = http.ListenAndServe(common.Serve, s)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	return 0
+}
+
+// servePage 评审页面，报告使用 iframe 显示，避免报告中的样式影响页面
+var servePage = template.Must(template.New("serve").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>SOAR</title>
+<style>
+body { font-family: sans-serif; margin: 0; display: flex; height: 100vh; }
+nav { width: 280px; overflow-y: auto; border-right: 1px solid #ddd; padding: 8px; font-size: 13px; }
+nav a { display: block; padding: 4px 0; color: #333; text-decoration: none; word-break: break-all; }
+nav a.cur { font-weight: bold; }
+nav span { color: #999; }
+main { flex: 1; display: flex; flex-direction: column; padding: 8px; }
+textarea { width: 100%; height: 160px; font-family: monospace; box-sizing: border-box; }
+iframe { flex: 1; border: 1px solid #ddd; margin-top: 8px; }
+</style>
+</head>
+<body>
+<nav>
+<a href="/">+ 新的评审</a>
+{{range .History}}<a href="/audit?id={{.ID}}"{{if eq .ID $.Current.ID}} class="cur"{{end}}><span>#{{.ID}} {{.Time.Format "01-02 15:04"}} {{.Target}}</span><br>{{.Title}}</a>
+{{end}}</nav>
+<main>
+<form method="post" action="/audit">
+<textarea name="query" placeholder="粘贴需要评审的 SQL，多条 SQL 以分号分隔">{{.Current.Query}}</textarea>
+<div>
+目标数据库 <input name="target" list="targets" value="{{.Current.Target}}" placeholder="如 mysql:8.0">
+<datalist id="targets"><option value="mysql:5.7"><option value="mysql:8.0"><option value="mariadb:10.6"></datalist>
+<button type="submit">评审</button>
+</div>
+</form>
+{{if .Current.ID}}<iframe src="/report?id={{.Current.ID}}"></iframe>{{end}}
+</main>
+</body>
+</html>
+`))
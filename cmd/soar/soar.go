@@ -19,6 +19,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -41,10 +42,19 @@ func main() {
 	sqlCounter := 1                                           // SQL 计数器
 	lineCounter := 1                                          // 行计数器
 	var alterSQLs []string                                    // 待评审的 SQL 中所有 ALTER 请求
-	alterTableTimes := make(map[string]int)                   // 待评审的 SQL 中同一经表 ALTER 请求计数器
 	suggestMerged := make(map[string]map[string]advisor.Rule) // 优化建议去重, key 为 sql 的 fingerprint.ID
 	var suggestStr []string                                   // string 形式格式化之后的优化建议，用于 -report-type json
+	var findings []advisor.Finding                            // 带文件行号的建议，用于 -report-type codequality, rdjson, checkstyle, tap, xlsx, jira, issues 等
+	workload := advisor.NewWorkload()                         // SQL 聚类及反模式统计，用于 -report-type workload
+	shardAdvisor := advisor.NewShardAdvisor()                 // 分片键建议，用于 -report-type shard-advisor
+	var txnChecker *advisor.TransactionChecker                // 显式事务中加锁读的检查，每个输入文件重新计算
+	var alterChecker *advisor.AlterChecker                    // 同一张表多条 ALTER 请求的合并，每个输入文件重新计算
+	var sysChecker *advisor.SysChecker                        // 与 SQL 相关的服务器变量检查，每个输入文件重新计算
+	var buffered *reportBuffer                                // -aggregate-duplicates, -query-stats, -top 缓存的建议，每个输入文件重新计算
 	tables := make(map[string][]string)                       // SQL 使用的库表名
+	syntaxFailed := false                                     // 是否有 SQL 语法检查失败
+	compatFailed := false                                     // -report-type compat-lint 是否发现不兼容的语法
+	views := make(map[string]string)                          // -expand-view 使用的视图定义, key 为小写的 db.view
 
 	// 配置文件&命令行参数解析
 	initConfig()
@@ -85,19 +95,197 @@ func main() {
 		return
 	}
 
-	// 读入待优化 SQL ，当配置文件或命令行参数未指定 SQL 时从管道读取
-	buf := initQuery(common.Config.Query)
-	lineCounter += ast.LeftNewLines([]byte(buf))
+	// 对指定的库给出 utf8 迁移至 utf8mb4 的方案
+	if common.Config.ReportType == "utf8mb4-migration" {
+		migrationSuggest := advisor.UTF8MB4Migration(rEnv)
+		if len(migrationSuggest) == 0 {
+			fmt.Printf("%s/%s 未发现需要迁移至 utf8mb4 的库表\n", common.Config.OnlineDSN.Addr, common.Config.OnlineDSN.Schema)
+			return
+		}
+		_, str := advisor.FormatSuggest("", currentDB, common.Config.ReportType, migrationSuggest)
+		fmt.Println(str)
+		return
+	}
+
+	// 根据表大小的历史快照预测增长趋势，给出容量警告
+	if common.Config.ReportType == "capacity" {
+		history, err := advisor.CapacitySnapshot(rEnv, common.Config.CapacityHistory)
+		if err != nil {
+			common.Log.Error("advisor.CapacitySnapshot Error: %v", err)
+			os.Exit(1)
+		}
+		capacitySuggest := advisor.CapacityAdvisor(history)
+		if len(capacitySuggest) == 0 {
+			fmt.Printf("未发现 %d 天内容量超限的表，预测增长趋势至少需要两个不同时间的快照\n", common.Config.CapacityHorizon)
+			return
+		}
+		_, str := advisor.FormatSuggest("", currentDB, common.Config.ReportType, capacitySuggest)
+		fmt.Println(str)
+		return
+	}
+
+	// 对整个库的建表语句逐表执行启发式规则检查，未指定输入时检查 OnlineDsn 中的所有表
+	if common.Config.ReportType == "schema-audit" {
+		var dump string
+		if common.Config.Query != "" || !stdinIsTerminal() {
+			dump, _ = common.RemoveBOM([]byte(initQuery(common.Config.Query)))
+		}
+		fmt.Println(advisor.FormatSchemaAudit(advisor.SchemaAudit(rEnv, dump)))
+		return
+	}
+
+	// 读入待优化 SQL ，当配置文件或命令行参数未指定 SQL 时从管道读取，可以指定多个文件、目录或通配符
+	inputs := initInputs()
+	buf := joinInputs(inputs)
 	buf = strings.TrimSpace(buf)
 
 	// remove bom from file header
 	var bom []byte
 	buf, bom = common.RemoveBOM([]byte(buf))
 
+	// 根据线上表的大小及业务 SQL 中的时间范围查询给出归档方案
+	if common.Config.ReportType == "archive" {
+		archiveSuggest := advisor.ArchiveAdvisor(rEnv, buf)
+		if len(archiveSuggest) == 0 {
+			fmt.Printf("%s/%s 未发现需要归档的大表\n", common.Config.OnlineDSN.Addr, common.Config.OnlineDSN.Schema)
+			return
+		}
+		_, str := advisor.FormatSuggest("", currentDB, common.Config.ReportType, archiveSuggest)
+		fmt.Println(str)
+		return
+	}
+
+	// 根据线上表的大小、读写频率及采样数据的可压缩性给出压缩及字典编码建议
+	if common.Config.ReportType == "compression" {
+		compressSuggest := advisor.CompressionAdvisor(rEnv, buf)
+		if len(compressSuggest) == 0 {
+			fmt.Printf("%s/%s 未发现需要调整压缩方式的表\n", common.Config.OnlineDSN.Addr, common.Config.OnlineDSN.Schema)
+			return
+		}
+		_, str := advisor.FormatSuggest("", currentDB, common.Config.ReportType, compressSuggest)
+		fmt.Println(str)
+		return
+	}
+
+	// 比较两个 Schema 的差异，生成变更语句并对变更语句进行评审
+	if common.Config.ReportType == "schema-diff" {
+		sqls, err := advisor.SchemaDiff(diffBase(rEnv), advisor.LoadSchema(nil, buf))
+		if err != nil {
+			common.Log.Error("advisor.SchemaDiff Error: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(advisor.FormatSchemaDiff(sqls))
+		return
+	}
+
+	// 在测试环境中按顺序执行变更脚本，检查变更在线上表结构上能否执行成功
+	if common.Config.ReportType == "migration-dry-run" {
+		results, diff, err := advisor.MigrationDryRun(vEnv, rEnv, buf)
+		if err != nil {
+			common.Log.Error("advisor.MigrationDryRun Error: %v", err)
+			os.Exit(1)
+		}
+		fmt.Println(advisor.FormatMigrationDryRun(results, diff))
+		return
+	}
+
 	if isContinue, exitCode := reportTool(buf, bom); !isContinue {
 		os.Exit(exitCode)
 	}
 
+	// 读取离线表结构
+	loadSchemaFile()
+
+	// 将白名单中的安全修复写回文件，之后的评审基于修复后的内容
+	if common.Fix {
+		fixInputs(inputs)
+	}
+
+	// 读取 SQL 执行统计，用于按影响对报告排序
+	stats := loadQueryStats()
+
+	// 指定 -report-storage 未指定 -report-dir 时，报告先写入临时目录，上传后删除
+	var tmpReportDir string
+	if common.Config.ReportStorage != "" && common.Config.ReportDir == "" {
+		tmpReportDir, err = ioutil.TempDir("", "soar-report")
+		if err != nil {
+			common.Log.Critical("ioutil.TempDir Error: %v", err)
+			os.Exit(1)
+		}
+		common.Config.ReportDir = tmpReportDir
+	}
+
+	// 记录线上环境的版本、sql_mode 及相关变量，便于之后复现评审结果
+	serverInfo = captureServerInfo(rEnv)
+	// 开启 -check-sys-variables 时获取线上环境的服务器变量
+	sysVars := advisor.LoadSysVariables(rEnv)
+
+	// 配置了 -history-dsn 时保存每条 SQL 的评审结果
+	history := openHistory()
+	if history != nil {
+		defer history.Close()
+	}
+
+	// 多个输入文件逐个处理，行号、SQL 计数器及建议去重按文件重新计算，-report-dir 不为空时每个文件输出一份报告
+	inputIdx := 0
+	stdout := os.Stdout
+	var output *os.File
+	var delimiter string // 当前分隔符，遇到 DELIMITER 命令时切换
+	startInput := func() {
+		input := inputs[inputIdx]
+		delimiter = common.Config.Delimiter
+		lineCounter = 1 + ast.LeftNewLines([]byte(input.Buf))
+		buf, _ = common.RemoveBOM([]byte(strings.TrimSpace(input.Buf)))
+		suggestMerged = make(map[string]map[string]advisor.Rule)
+		txnChecker = advisor.NewTransactionChecker()
+		alterChecker = advisor.NewAlterChecker()
+		sysChecker = advisor.NewSysChecker(rEnv, sysVars)
+		buffered = newReportBuffer(stats)
+		if common.Config.ReportDir != "" {
+			output = reportOutput(input.Name)
+		}
+		// 每个报告文件的开头输出评审环境，未指定 -report-dir 时只输出一次
+		if output != nil || inputIdx == 0 {
+			printServerInfo()
+		}
+		if output == nil && len(inputs) > 1 && common.Config.ReportType == "markdown" {
+			fmt.Printf("# File: %s\n\n", input.Name)
+		}
+	}
+	finishInput := func() {
+		// 合并、排序后的建议在文件读取完成后才能确定出现次数及顺序
+		if common.Config.ReportType == "json" {
+			suggestStr = append(suggestStr, buffered.format("json")...)
+		} else {
+			for _, str := range buffered.format(common.Config.ReportType) {
+				fmt.Println(str)
+			}
+		}
+		if output == nil {
+			return
+		}
+		if common.Config.ReportType == "json" {
+			fmt.Println("[\n", strings.Join(suggestStr, ",\n"), "\n]")
+			suggestStr = nil
+		}
+		if advisor.FindingsReportType(common.Config.ReportType) {
+			printFindings(findings)
+			findings = nil
+		}
+		if common.Config.ReportType == "workload" {
+			fmt.Println(workload.Format())
+			workload = advisor.NewWorkload()
+		}
+		if common.Config.ReportType == "shard-advisor" {
+			fmt.Println(shardAdvisor.Format())
+			shardAdvisor = advisor.NewShardAdvisor()
+		}
+		common.LogIfWarn(output.Close(), "")
+		os.Stdout = stdout
+		output = nil
+	}
+	startInput()
+
 	// 逐条SQL给出优化建议
 	for ; ; sqlCounter++ {
 		var id string                                     // fingerprint.ID
@@ -107,19 +295,36 @@ func main() {
 		proSuggest := make(map[string]advisor.Rule)       // Profiling 信息
 		traceSuggest := make(map[string]advisor.Rule)     // Trace 信息
 		mysqlSuggest := make(map[string]advisor.Rule)     // MySQL 返回的 ERROR 信息
+		var queryCost advisor.QueryCost                   // 查询代价，用于 -show-last-query-cost
+		var plan string                                   // 执行计划摘要，用于 -history-dsn
+		var planRows int64                                // 执行计划中的扫描行数之和，用于 -history-dsn
 
 		if buf == "" {
 			common.Log.Debug("Ending, buf: '%s', sql: '%s'", buf, sql)
+			finishInput()
+			if inputIdx+1 < len(inputs) {
+				inputIdx++
+				startInput()
+				// 新的文件从第一条 SQL 开始计数，continue 后 sqlCounter 为 1
+				sqlCounter = 0
+				continue
+			}
 			break
 		}
 		// 查询请求切分
-		orgSQL, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(common.Config.Delimiter))
+		orgSQL, sql, bufBytes := ast.SplitStatement([]byte(buf), []byte(delimiter))
 		// lineCounter
 		lc := ast.NewLines([]byte(orgSQL))
 		// leftLineCounter
 		llc := ast.LeftNewLines([]byte(orgSQL))
 		lineCounter += llc
+		line := lineCounter // 当前 SQL 所在行
+		lineCounter += lc - llc
 		buf = string(bufBytes)
+		if d, ok := ast.ParseDelimiter(sql); ok {
+			delimiter = d
+			continue
+		}
 
 		// 去除无用的备注和空格
 		sql = database.RemoveSQLComments(sql)
@@ -130,10 +335,13 @@ func main() {
 		common.Log.Debug("main loop SQL: %s", sql)
 
 		// +++++++++++++++++++++小工具集[开始]+++++++++++++++++++++++{
-		fingerprint := strings.TrimSpace(query.Fingerprint(sql))
+		fingerprint := advisor.Fingerprint(sql)
 		// SQL 签名
 		id = query.Id(fingerprint)
 		currentDB = env.CurrentDB(sql, currentDB)
+		// 事务的检查及 ALTER 合并依赖 SQL 的先后顺序，需要在去重之前进行
+		txnRule := txnChecker.Add(sql, line)
+		alterRule := alterChecker.Add(sql, line)
 		switch common.Config.ReportType {
 		case "fingerprint":
 			// SQL 指纹
@@ -142,6 +350,17 @@ func main() {
 			}
 			fmt.Println(fingerprint)
 			continue
+		case "shard-advisor":
+			// 分片键建议，全部 SQL 读取完成后输出
+			shardAdvisor.Add(sql)
+			continue
+		case "compat-lint":
+			// Oracle, SQL Server 迁移至 MySQL 的兼容性检查，SQL 通常无法通过 MySQL 语法解析，只做词法检查
+			if issues := advisor.CompatLint(sql); len(issues) > 0 {
+				fmt.Println(advisor.FormatCompatLint(sql, fmt.Sprintf("%s:%d", inputs[inputIdx].Name, line), issues))
+				compatFailed = true
+			}
+			continue
 		case "pretty":
 			// SQL 美化
 			fmt.Println(ast.Pretty(sql, "builtin") + common.Config.Delimiter)
@@ -175,8 +394,13 @@ func main() {
 			// 建议去重，减少评审整个文件耗时
 			// TODO: 由于 a = 11 和 a = '11' 的 fingerprint 相同，这里一旦跳过即无法检查有些建议了，如： ARG.003
 			if _, ok := suggestMerged[id]; ok {
-				// `use ?` 不可以去重，去重后将导致无法切换数据库
-				if !strings.HasPrefix(fingerprint, "use") {
+				// `use ?` 不可以去重，去重后将导致无法切换数据库；给出了事务相关或 ALTER 合并的建议时也不去重
+				if !strings.HasPrefix(fingerprint, "use") && txnRule.Item == "" && alterRule.Item == "" {
+					// 重复出现的 SQL 计入负载
+					if common.Config.ReportType == "workload" {
+						workload.Add(sql, tables[id], suggestMerged[id])
+					}
+					buffered.seen(id, inputs[inputIdx].Name, line)
 					continue
 				}
 			}
@@ -188,25 +412,33 @@ func main() {
 				}
 			}
 		}
-		tables[id] = ast.SchemaMetaInfo(sql, currentDB)
+		// 将 SELECT 中引用的视图展开为子查询，对展开后的 SQL 给出建议
+		if common.Config.ExpandView {
+			sql = expandView(sql, currentDB, views, rEnv)
+		}
+		tables[id] = advisor.LogicalTables(ast.SchemaMetaInfo(sql, currentDB))
 		// +++++++++++++++++++++小工具集[结束]+++++++++++++++++++++++}
 
 		// +++++++++++++++++++++语法检查[开始]+++++++++++++++++++++++{
 		q, syntaxErr := advisor.NewQuery4Audit(sql)
-		stmt := q.Stmt
 
-		// 如果语法检查出错则不需要给优化建议
+		// 语法检查出错时继续检查剩余的 SQL，出错的 SQL 仍然给出不依赖 TiDB AST 的建议
 		if syntaxErr != nil {
 			errContent := fmt.Sprintf("At SQL %d : %v", sqlCounter, syntaxErr)
 			common.Log.Warning(errContent)
 			if common.Config.OnlySyntaxCheck || common.Config.ReportType == "rewrite" ||
 				common.Config.ReportType == "query-type" {
+				// 全部 SQL 检查完成后以非零状态退出
 				fmt.Println(errContent)
-				os.Exit(1)
+				syntaxFailed = true
+				continue
 			}
 			// tidb parser 语法检查给出的建议 ERR.000
-			mysqlSuggest["ERR.000"] = advisor.RuleMySQLError("ERR.000", syntaxErr)
+			mysqlSuggest["ERR.000"] = advisor.RuleSyntaxError(syntaxErr, line)
 		}
+		// 记录输入中的建表语句，用于后续 SQL 的离线检查
+		// TiDB parser 不支持空间类型，语法检查出错时仍然记录 vitess 解析出的空间列
+		advisor.AddOfflineSchema(q)
 		// 如果只想检查语法直接跳过后面的步骤
 		if common.Config.OnlySyntaxCheck {
 			continue
@@ -228,12 +460,18 @@ func main() {
 			// 去除忽略的建议检查
 			okFunc := (*advisor.Query4Audit).RuleOK
 			if !advisor.IsIgnoreRule(item) && &rule.Func != &okFunc {
-				r := rule.Func(q)
+				r := checkRule(item, rule, q)
 				if r.Item == item {
 					heuristicSuggest[item] = r
 				}
 			}
 		}
+		if txnRule.Item != "" && !advisor.IsIgnoreRule(txnRule.Item) {
+			heuristicSuggest[txnRule.Item] = txnRule
+		}
+		if alterRule.Item != "" && !advisor.IsIgnoreRule(alterRule.Item) {
+			heuristicSuggest[alterRule.Item] = alterRule
+		}
 		common.Log.Debug("end of heuristic advisor Query: %s", q.Query)
 		// +++++++++++++++++++++启发式规则建议[结束]+++++++++++++++++++++++}
 
@@ -241,7 +479,8 @@ func main() {
 		// 如果配置了索引建议过滤规则，不进行索引优化建议
 		// 在配置文件 ignore-rules 中添加 'IDX.*' 即可屏蔽索引优化建议
 		common.Log.Debug("start of index advisor Query: %s", q.Query)
-		if !advisor.IsIgnoreRule("IDX.") {
+		// ClickHouse 不使用 MySQL 测试环境，不给出索引建议
+		if !advisor.IsIgnoreRule("IDX.") && common.Config.Dialect != "clickhouse" {
 			if vEnv.BuildVirtualEnv(rEnv, q.Query) {
 				idxAdvisor, err := advisor.NewAdvisor(vEnv, *rEnv, *q)
 				if err != nil || (idxAdvisor == nil && vEnv.Error == nil) {
@@ -255,7 +494,18 @@ func main() {
 				} else {
 					// 创建环境时没有出现错误，生成索引建议
 					if vEnv.Error == nil {
-						idxSuggest = idxAdvisor.IndexAdvise().Format()
+						idxAdvises := idxAdvisor.IndexAdvise()
+						// 开启数据采样时测试环境中有数据，添加索引后重新 EXPLAIN 给出扫描行数等变化
+						if common.Config.Sampling {
+							idxAdvisor.MeasureBenefit(q.Query, idxAdvises)
+						}
+						idxSuggest = idxAdvises.Format()
+
+						// 比较添加建议的索引前后的查询代价
+						if common.Config.ShowLastQueryCost && len(idxAdvises) > 0 {
+							queryCost.IndexBefore, queryCost.IndexAfter, err = idxAdvisor.IndexCost(q.Query, idxAdvises)
+							common.LogIfWarn(err, "")
+						}
 
 						// 依赖数据字典的启发式建议
 						for i, r := range idxAdvisor.HeuristicCheck(*q) {
@@ -292,7 +542,7 @@ func main() {
 		common.Log.Debug("start of explain Query: %s", q.Query)
 		if !common.Config.OnlineDSN.Disable && !common.Config.TestDSN.Disable {
 			// 因为 EXPLAIN 依赖数据库环境，所以把这段逻辑放在启发式建议和索引建议后面
-			if common.Config.Explain {
+			if common.Config.Explain && common.Config.Dialect != "clickhouse" {
 				// 执行 EXPLAIN
 				explainInfo, err := rEnv.Explain(q.Query,
 					database.ExplainType[common.Config.ExplainType],
@@ -312,9 +562,39 @@ func main() {
 				// 分析 EXPLAIN 结果
 				if explainInfo != nil {
 					expSuggest = advisor.ExplainAdvisor(explainInfo)
+					queryCost.Cost = explainInfo.Cost()
+					plan, planRows = database.PlanSignature(explainInfo)
+					// 结合线上环境的 sort_buffer_size, tmp_table_size 及表的平均行长度估算排序及临时表是否使用磁盘
+					if info, err := rEnv.SpillInfo(advisor.ExplainTableNames(q)); err == nil {
+						for item, rule := range advisor.ExplainSpillAdvisor(explainInfo, info) {
+							expSuggest[item] = rule
+						}
+						for item, rule := range advisor.ExplainMaterializeAdvisor(explainInfo, info) {
+							expSuggest[item] = rule
+						}
+						// 全表扫描读入的数据量占 Buffer Pool 的比例
+						if rule, ok := advisor.ExplainBufferPoolAdvisor(explainInfo, info); ok {
+							expSuggest[rule.Item] = rule
+						}
+						// 没有 LIMIT 的 SELECT 返回的结果集大小
+						if rule, ok := advisor.ResultSizeAdvisor(q, explainInfo, info); ok {
+							expSuggest[rule.Item] = rule
+						}
+					} else {
+						common.Log.Warn("rEnv.SpillInfo Warn: %v", err)
+					}
 				} else {
 					common.Log.Warn("rEnv&vEnv.Explain explainInfo nil, SQL: %s", q.Query)
 				}
+				// UPDATE, DELETE 影响的行数
+				if rule, ok := advisor.AffectedRowsAdvisor(rEnv, q, explainInfo); ok {
+					expSuggest[rule.Item] = rule
+				}
+			}
+		}
+		if common.Config.ShowLastQueryCost {
+			if rule, ok := advisor.RuleQueryCost(queryCost); ok {
+				expSuggest[rule.Item] = rule
 			}
 		}
 		common.Log.Debug("end of explain Query: %s", q.Query)
@@ -323,13 +603,9 @@ func main() {
 		// +++++++++++++++++++++ Profiling [开始]+++++++++++++++++++++++++{
 		common.Log.Debug("start of profiling Query: %s", q.Query)
 		if common.Config.Profiling {
-			res, err := vEnv.Profiling(q.Query)
+			res, err := vEnv.Profile(q.Query)
 			if err == nil {
-				proSuggest["PRO.001"] = advisor.Rule{
-					Item:     "PRO.001",
-					Severity: "L0",
-					Content:  database.FormatProfiling(res),
-				}
+				proSuggest = advisor.ProfilingAdvisor(res)
 			} else {
 				common.Log.Error("Profiling Error: %v", err)
 			}
@@ -342,10 +618,15 @@ func main() {
 		if common.Config.Trace {
 			res, err := vEnv.Trace(q.Query)
 			if err == nil {
+				// 默认输出整理后的优化器决策过程，verbose 模式下附带原始 Trace
+				content := database.FormatTraceSummary(res)
+				if common.Config.Verbose {
+					content += database.FormatTrace(res)
+				}
 				traceSuggest["TRA.001"] = advisor.Rule{
 					Item:     "TRA.001",
 					Severity: "L0",
-					Content:  database.FormatTrace(res),
+					Content:  content,
 				}
 			} else {
 				common.Log.Error("Trace Error: %v", err)
@@ -361,18 +642,7 @@ func main() {
 				strings.HasPrefix(strings.TrimSpace(strings.ToLower(sql)), "alter") ||
 				strings.HasPrefix(strings.TrimSpace(strings.ToLower(sql)), "rename") {
 				// 依赖上下文件的 SQL 重写，如：多条 ALTER SQL 合并
-				// vitess 对 DDL 语法的支持不好，大部分 DDL 会语法解析出错，但即使出错了还是会生成一个 stmt 而且里面的 db.table 还是准确的。
-
 				alterSQLs = append(alterSQLs, sql)
-				alterTbl := ast.AlterAffectTable(stmt)
-				if alterTbl != "" && alterTbl != "dual" {
-					if _, ok := alterTableTimes[alterTbl]; ok {
-						heuristicSuggest["ALT.002"] = advisor.HeuristicRules["ALT.002"]
-						alterTableTimes[alterTbl] = alterTableTimes[alterTbl] + 1
-					} else {
-						alterTableTimes[alterTbl] = 1
-					}
-				}
 			} else {
 				// 其他不依赖上下文件的 SQL 重写
 				rw := ast.NewRewrite(sql)
@@ -386,6 +656,14 @@ func main() {
 				rw.Columns = vEnv.GenTableColumns(meta)
 				// 执行定义好的 SQL 重写规则
 				rw.Rewrite()
+				// 输出改写前后的查询代价，便于比较改写效果
+				if common.Config.ShowLastQueryCost && !common.Config.OnlineDSN.Disable && rw.NewSQL != sql {
+					before, errBefore := rEnv.QueryCost(sql)
+					after, errAfter := rEnv.QueryCost(rw.NewSQL)
+					if errBefore == nil && errAfter == nil {
+						fmt.Printf("-- Query cost: %.3f -> %.3f\n", before, after)
+					}
+				}
 				fmt.Println(strings.TrimSpace(rw.NewSQL))
 			}
 		}
@@ -397,11 +675,32 @@ func main() {
 		if strings.HasPrefix(fingerprint, "use") {
 			continue
 		}
+		// 根据表实际的存储引擎调整以 InnoDB 为前提的建议
+		advisor.GateEngineRules(q, advisor.TableEngines(rEnv, tables[id]), heuristicSuggest, idxSuggest, expSuggest, mysqlSuggest)
+		// 与 SQL 相关的服务器变量配置问题
+		for item, rule := range sysChecker.Check(q, tables[id]) {
+			heuristicSuggest[item] = rule
+		}
+		// 涉及关键库表的建议提升级别
+		critical := advisor.EscalateCritical(advisor.CriticalTables(tables[id]), heuristicSuggest, idxSuggest, expSuggest, mysqlSuggest)
 		sug, str := advisor.FormatSuggest(q.Query, currentDB, common.Config.ReportType, heuristicSuggest, idxSuggest, expSuggest, proSuggest, traceSuggest, mysqlSuggest)
 		suggestMerged[id] = sug
+		recordHistory(history, q.Query, inputs[inputIdx].Name, line, sug, plan, planRows)
+		if bufferReports() {
+			buffered.add(id, str, inputs[inputIdx].Name, line, sug)
+			if critical {
+				buffered.markCritical(id)
+			}
+			buffered.setOwner(id, advisor.QueryOwner(tables[id]))
+			continue
+		}
 		switch common.Config.ReportType {
 		case "json":
-			suggestStr = append(suggestStr, str)
+			suggestStr = append(suggestStr, jsonWithLocation(str, inputs[inputIdx].Name, line))
+		case "codequality", "rdjson", "checkstyle", "tap", "xlsx", "jira", "issues", "archery", "yearning", "bytebase":
+			findings = append(findings, advisor.NewFindings(sug, q.Query, tables[id], inputs[inputIdx].Name, line)...)
+		case "workload":
+			workload.Add(q.Query, tables[id], sug)
 		case "tables":
 		case "duplicate-key-checker":
 		case "rewrite":
@@ -412,17 +711,8 @@ func main() {
 					continue
 				}
 
-				if common.Config.Query != "" {
-					if _, err = os.Stat(common.Config.Query); err == nil {
-						fmt.Printf("%s:%d:%s\n", common.Config.Query, lineCounter, s)
-					} else {
-						fmt.Printf("null:%d:%s\n", lineCounter, s)
-					}
-				} else {
-					fmt.Printf("stdin:%d:%s\n", lineCounter, s)
-				}
+				fmt.Printf("%s:%d:%s\n", inputs[inputIdx].Name, line, s)
 			}
-			lineCounter += lc - llc
 		case "html":
 			fmt.Println(common.Markdown2HTML(str))
 		default:
@@ -432,6 +722,13 @@ func main() {
 		// +++++++++++++++++++++打印单条 SQL 优化建议[结束]++++++++++++++++++++++++++}
 	}
 
+	// 上传 -report-dir 中的报告，语法检查失败时也需要上传
+	uploadReports(tmpReportDir)
+
+	if syntaxFailed || compatFailed {
+		os.Exit(1)
+	}
+
 	// 同一张表的多条 ALTER 语句合并为一条
 	if ast.RewriteRuleMatch("mergealter") {
 		for _, v := range ast.MergeAlterTables(alterSQLs...) {
@@ -440,11 +737,26 @@ func main() {
 		return
 	}
 
-	// 以 JSON 格式化输出
-	if common.Config.ReportType == "json" {
+	// 以 JSON 格式化输出，-report-dir 不为空时已按文件输出
+	if common.Config.ReportType == "json" && common.Config.ReportDir == "" {
 		fmt.Println("[\n", strings.Join(suggestStr, ",\n"), "\n]")
 	}
 
+	// 以 GitLab Code Quality, reviewdog, Checkstyle, TAP, xlsx, Jira 等格式输出，-report-dir 不为空时已按文件输出
+	if advisor.FindingsReportType(common.Config.ReportType) && common.Config.ReportDir == "" {
+		printFindings(findings)
+	}
+
+	// 输出 SQL 聚类及反模式统计，-report-dir 不为空时已按文件输出
+	if common.Config.ReportType == "workload" && common.Config.ReportDir == "" {
+		fmt.Println(workload.Format())
+	}
+
+	// 输出分片键建议，-report-dir 不为空时已按文件输出
+	if common.Config.ReportType == "shard-advisor" && common.Config.ReportDir == "" {
+		fmt.Println(shardAdvisor.Format())
+	}
+
 	// 以 JSON 格式输出 SQL 影响的库表名
 	if common.Config.ReportType == "tables" {
 		js, err := json.MarshalIndent(tables, "", "  ")
@@ -17,14 +17,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/XiaoMi/soar/advisor"
 	"github.com/XiaoMi/soar/common"
+	"github.com/XiaoMi/soar/database"
 )
 
 var update = flag.Bool("update", false, "update .golden files")
@@ -36,6 +46,7 @@ func TestMain(m *testing.M) {
 		common.DevPath, _ = filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	}
 	common.BaseDir = common.DevPath
+	common.RedirectTestLog()
 	err := common.ParseConfig("")
 	common.LogIfError(err, "init ParseConfig")
 	common.Log.Debug("mysql_test init")
@@ -108,6 +119,212 @@ func Test_Main_initQuery(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func Test_Main_expandInput(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"v1/001.sql", "v1/002.txt", "v2/up/003.sql"} {
+		err = os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, f), []byte("select 1;"), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]int{
+		dir:                     2,
+		dir + "/**/*.sql":       2,
+		dir + "/**/up/*.sql":    1,
+		dir + "/v1/*":           2,
+		dir + "/v1/001.sql":     1,
+		"select * from film":    0,
+		dir + "/not_exists.sql": 0,
+	}
+	for p, n := range cases {
+		if files := expandInput(p); len(files) != n {
+			t.Errorf("%s want %d files, got %v", p, n, files)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_reportBuffer(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgAggregate, orgStats, orgTop := common.Config.AggregateDuplicates, common.Config.QueryStats, common.Config.Top
+	common.Config.AggregateDuplicates = true
+	buf := newReportBuffer(nil)
+	buf.add("A", "# Query: A\n", "a.sql", 1, nil)
+	buf.add("B", "# Query: B\n", "a.sql", 2, nil)
+	buf.seen("A", "a.sql", 5)
+	buf.seen("C", "a.sql", 6)
+
+	md := buf.format("markdown")
+	if len(md) != 2 {
+		t.Fatalf("want 2 reports, got %d", len(md))
+	}
+	if !strings.Contains(md[0], "* **出现次数:**  2") || !strings.Contains(md[0], "a.sql:1, a.sql:5") {
+		t.Errorf("want occurrences and locations, got %s", md[0])
+	}
+	if md[1] != "# Query: B\n" {
+		t.Errorf("want unchanged report, got %s", md[1])
+	}
+
+	buf = newReportBuffer(nil)
+	buf.add("A", `{"ID": "A"}`, "a.sql", 1, nil)
+	buf.seen("A", "a.sql", 5)
+	js := buf.format("json")
+	if len(js) != 1 || !strings.Contains(js[0], `"Occurrences": 2`) || !strings.Contains(js[0], `"a.sql:5"`) {
+		t.Errorf("want occurrences and locations, got %v", js)
+	}
+
+	// 按执行次数 x Severity 排序，只保留前 2 条
+	common.Config.AggregateDuplicates = false
+	common.Config.QueryStats = "stats.csv"
+	common.Config.Top = 2
+	buf = newReportBuffer(map[string]advisor.QueryStats{
+		"A": {Count: 10},
+		"B": {Count: 1000, Latency: 1.5},
+		"C": {Count: 100},
+	})
+	buf.add("A", `{"ID": "A"}`, "a.sql", 1, map[string]advisor.Rule{"CLA.001": {Severity: "L4"}})
+	buf.add("B", `{"ID": "B"}`, "a.sql", 2, map[string]advisor.Rule{"COL.001": {Severity: "L1"}})
+	buf.add("C", `{"ID": "C"}`, "a.sql", 3, map[string]advisor.Rule{"OK": {Severity: "L0"}})
+	js = buf.format("json")
+	if len(js) != 2 || !strings.Contains(js[0], `"ID": "B"`) || !strings.Contains(js[0], `"Impact": 1000`) || !strings.Contains(js[1], `"ID": "A"`) {
+		t.Errorf("want reports sorted by impact, got %v", js)
+	}
+
+	// 涉及关键库表的 SQL 超出 -top 时仍然输出
+	buf.markCritical("C")
+	js = buf.format("json")
+	if len(js) != 3 || !strings.Contains(js[2], `"ID": "C"`) {
+		t.Errorf("want critical report kept, got %v", js)
+	}
+	common.Config.AggregateDuplicates, common.Config.QueryStats, common.Config.Top = orgAggregate, orgStats, orgTop
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_reportBufferOwner(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	f, err := ioutil.TempFile("", "soar-owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("^sakila\\. team-dba\n^payments\\. team-pay\n")
+	common.LogIfError(f.Close(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = advisor.LoadOwnerFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { common.LogIfError(advisor.LoadOwnerFile(""), "") }()
+
+	buf := newReportBuffer(nil)
+	for i, tb := range []string{"`sakila`.`film`", "`test`.`t1`", "`payments`.`orders`", "`sakila`.`actor`"} {
+		id := fmt.Sprint(i)
+		buf.add(id, "# Query: "+id, "a.sql", i+1, nil)
+		buf.setOwner(id, advisor.QueryOwner([]string{tb}))
+	}
+	// 按团队名称分节，未归属的 SQL 排在最后
+	got := strings.Join(buf.format("markdown"), "\n")
+	want := []string{"# Owner: team-dba", "# Query: 0", "# Query: 3", "# Owner: team-pay", "# Query: 2", "# Owner: unowned", "# Query: 1"}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(got, w)
+		if i <= last {
+			t.Fatalf("want %q after previous section, got:\n%s", w, got)
+		}
+		last = i
+	}
+
+	buf = newReportBuffer(nil)
+	buf.add("A", `{"ID": "A"}`, "a.sql", 1, nil)
+	buf.setOwner("A", "team-dba")
+	if js := buf.format("json"); len(js) != 1 || !strings.Contains(js[0], `"Owner": "team-dba"`) {
+		t.Errorf("want Owner in json report, got %v", js)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_serverInfo(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	defer func() { serverInfo = nil }()
+	serverInfo = &database.ServerInfo{
+		Addr:       "127.0.0.1:3306",
+		Version:    "8.0.36",
+		SQLMode:    "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES",
+		Variables:  map[string]string{"sort_buffer_size": "262144"},
+		CapturedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	// JSON 报告中的每条建议都带有评审环境
+	js := jsonWithLocation(`{"ID": "A"}`, "a.sql", 1)
+	for _, want := range []string{`"Version": "8.0.36"`, `"SQLMode": "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES"`, `"sort_buffer_size": "262144"`, `"CapturedAt": "2024-01-02T03:04:05Z"`} {
+		if !strings.Contains(js, want) {
+			t.Errorf("want %s in json report, got %s", want, js)
+		}
+	}
+	buf := newReportBuffer(nil)
+	buf.add("A", `{"ID": "A"}`, "a.sql", 1, nil)
+	if js := buf.format("json"); len(js) != 1 || !strings.Contains(js[0], `"Version": "8.0.36"`) {
+		t.Errorf("want Server in buffered json report, got %v", js)
+	}
+
+	md := database.FormatServerInfo(serverInfo)
+	for _, want := range []string{"# 评审环境", "2024-01-02 03:04:05 +0000", "8.0.36", "* **sort_buffer_size:**  262144 (256KB)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("want %s in markdown header, got %s", want, md)
+		}
+	}
+	if strings.Contains(md, "tmp_table_size") {
+		t.Errorf("variables not captured should not be printed, got %s", md)
+	}
+
+	serverInfo = nil
+	if js := jsonWithLocation(`{"ID": "A"}`, "a.sql", 1); strings.Contains(js, "Server") {
+		t.Errorf("want no Server without online-dsn, got %s", js)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_doctor(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgConfigFile, orgReportType, orgIgnoreRules := common.ConfigFile, common.Config.ReportType, common.Config.IgnoreRules
+	orgTestDisable, orgOnlineDisable := common.Config.TestDSN.Disable, common.Config.OnlineDSN.Disable
+	defer func() {
+		common.ConfigFile, common.Config.ReportType, common.Config.IgnoreRules = orgConfigFile, orgReportType, orgIgnoreRules
+		common.Config.TestDSN.Disable, common.Config.OnlineDSN.Disable = orgTestDisable, orgOnlineDisable
+	}()
+
+	common.ConfigFile = ""
+	common.Config.ReportType = "markdwon"
+	common.Config.IgnoreRules = []string{"COL.*", "XYZ.001"}
+	common.Config.TestDSN.Disable = true
+	common.Config.OnlineDSN.Disable = false
+	report := &doctorReport{}
+	doctorConfig(report)
+	doctorDSN(report)
+	if !report.failed() {
+		t.Error("want report-type check failed")
+	}
+	str := report.String()
+	for _, s := range []string{"[WARN] config:", "[FAIL] report-type:", "'XYZ.001'", "[WARN] test-dsn:", "[WARN] online-dsn:"} {
+		if !strings.Contains(str, s) {
+			t.Errorf("want %s, got:\n%s", s, str)
+		}
+	}
+	if strings.Contains(str, "COL.*") {
+		t.Errorf("COL.* should match rules, got:\n%s", str)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func Test_Main_reportTool(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	orgRerportType := common.Config.ReportType
@@ -189,3 +406,387 @@ func Test_Main_verboseInfo(t *testing.T) {
 	common.Config.Verbose = orgVerbose
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func Test_Main_completion(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	for shell, f := range map[string]func() string{"bash": bashCompletion, "zsh": zshCompletion, "fish": fishCompletion} {
+		str := f()
+		for _, s := range []string{"report-type", "ignore-rules", "ARG.003", "schema-audit", "lint", "markdown", "dml2select"} {
+			if !strings.Contains(str, s) {
+				t.Errorf("%s completion want %s", shell, s)
+			}
+		}
+		for _, s := range []string{"vmodule", "test.run"} {
+			if strings.Contains(str, s) {
+				t.Errorf("%s completion should not contain %s", shell, s)
+			}
+		}
+	}
+	if completion("ksh") == 0 {
+		t.Error("ksh should not be supported")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_fixInputs(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "fix.sql")
+	err = ioutil.WriteFile(file, []byte("-- comment\nselect a b from t where c != 1;\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []inputFile{{Name: file, Buf: initQuery(file)}, {Name: "null", Buf: "select a b from t"}}
+	fixInputs(inputs)
+	want := "-- comment\nselect a AS b from t where c <> 1;\n"
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != want || inputs[0].Buf != want {
+		t.Errorf("want: %s, got: %s", want, string(buf))
+	}
+	if inputs[1].Buf != "select a b from t" {
+		t.Errorf("SQL without file should not be fixed, got: %s", inputs[1].Buf)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_uploadReports(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "soar-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "a.sql.html")
+	if err = ioutil.WriteFile(file, []byte("<html></html>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	orgConfig := *common.Config
+	common.Config.ReportStorage = "s3://reports/ci"
+	common.Config.StorageEndpoint = ts.URL
+	reportFiles = []string{file}
+	uploadReports(dir)
+
+	if len(paths) != 1 || !strings.HasPrefix(paths[0], "PUT /reports/ci/") ||
+		!strings.HasSuffix(paths[0], "/a.sql.html text/html; charset=utf-8") {
+		t.Errorf("unexpected upload: %v", paths)
+	}
+	// 临时目录上传后删除
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s should be removed", dir)
+	}
+	reportFiles = nil
+	common.Config = &orgConfig
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_auditServer(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history.jsonl")
+
+	s, err := newAuditServer(newFileStore(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.run = func(ctx context.Context, query, target string) (string, error) {
+		return "<html>" + target + " " + query + "</html>", nil
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.PostForm(ts.URL+"/audit", url.Values{"query": {"select * from film"}, "target": {"mysql:8.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.RequestURI() != "/audit?id=1" {
+		t.Errorf("should redirect to /audit?id=1, got: %s", resp.Request.URL.RequestURI())
+	}
+	resp, err = http.Get(ts.URL + "/report?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "<html>mysql:8.0 select * from film</html>" {
+		t.Errorf("report got: %s", string(body))
+	}
+
+	// 其他网页提交的评审
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/audit", strings.NewReader("query=select+1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://evil.example.com")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cross-origin post want status 403, got: %d", resp.StatusCode)
+	}
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/audit", strings.NewReader("query=select+1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", ts.URL+"/")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.RequestURI() != "/audit?id=2" {
+		t.Errorf("same-origin post should redirect to /audit?id=2, got: %s", resp.Request.URL.RequestURI())
+	}
+
+	// 不支持的目标数据库
+	resp, err = http.PostForm(ts.URL+"/audit", url.Values{"query": {"select 1"}, "target": {"oracle"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status 400, got: %d", resp.StatusCode)
+	}
+
+	// 重启后从评审记录文件中加载
+	if e, ok, err := newFileStore(file).get(1); !ok || err != nil || e.Query != "select * from film" || e.Target != "mysql:8.0" {
+		t.Errorf("history got: %v, %v", e, err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_auditServerAuth(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgTokens, orgRateLimit := common.Config.ServeTokens, common.Config.ServeRateLimit
+	defer func() { common.Config.ServeTokens, common.Config.ServeRateLimit = orgTokens, orgRateLimit }()
+
+	common.Config.ServeTokens = []string{"dba"}
+	if _, err := newAuditServer(newFileStore("")); err == nil {
+		t.Error("token without name should be rejected")
+	}
+
+	common.Config.ServeTokens = []string{"dba:s3cret", "ci:t0ken"}
+	common.Config.ServeRateLimit = 2
+	s, err := newAuditServer(newFileStore(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.run = func(ctx context.Context, query, target string) (string, error) {
+		return "<html>" + query + "</html>", nil
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	do := func(set func(req *http.Request)) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/audit", strings.NewReader("query=select+1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		set(req)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	cases := []struct {
+		name   string
+		set    func(req *http.Request)
+		status int
+	}{
+		{"no token", func(req *http.Request) {}, http.StatusUnauthorized},
+		{"wrong token", func(req *http.Request) { req.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"wrong basic user", func(req *http.Request) { req.SetBasicAuth("ci", "s3cret") }, http.StatusUnauthorized},
+		{"bearer", func(req *http.Request) { req.Header.Set("Authorization", "Bearer s3cret") }, http.StatusSeeOther},
+		{"basic", func(req *http.Request) { req.SetBasicAuth("dba", "s3cret") }, http.StatusSeeOther},
+		// dba 每分钟最多提交 2 次，ci 单独计数
+		{"rate limited", func(req *http.Request) { req.Header.Set("Authorization", "Bearer s3cret") }, http.StatusTooManyRequests},
+		{"other client", func(req *http.Request) { req.Header.Set("Authorization", "Bearer t0ken") }, http.StatusSeeOther},
+	}
+	for _, c := range cases {
+		if status := do(c.set); status != c.status {
+			t.Errorf("%s: want status %d, got %d", c.name, c.status, status)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_serveLimiter(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	now := time.Now()
+	l := newServeLimiter(1, time.Minute)
+	if !l.allow("a", now) || l.allow("a", now.Add(time.Second)) || !l.allow("b", now) {
+		t.Error("want one request per client in the window")
+	}
+	if !l.allow("a", now.Add(time.Minute)) {
+		t.Error("want a new window after one minute")
+	}
+	if l = newServeLimiter(0, time.Minute); !l.allow("a", now) || !l.allow("a", now) {
+		t.Error("rate limit 0 should not limit")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_serveReloader(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	s, err := newAuditServer(newFileStore(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.run = func(ctx context.Context, query, target string) (string, error) {
+		return "<html>" + query + "</html>", nil
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	post := func(token string) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/audit", strings.NewReader("query=select+1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	next := *common.Config
+	next.ServeTokens = []string{"ci:t0ken"}
+	r := newServeReloader(s)
+	r.load = func() (*common.Configuration, error) { return &next, nil }
+	r.reload()
+	if status := post("nope"); status != http.StatusUnauthorized {
+		t.Errorf("tokens reloaded, want status 401, got %d", status)
+	}
+	if status := post("t0ken"); status != http.StatusSeeOther {
+		t.Errorf("tokens reloaded, want status 303, got %d", status)
+	}
+
+	// 加载失败时拒绝评审，修复后恢复
+	r.load = func() (*common.Configuration, error) { return nil, fmt.Errorf("field bogus not found") }
+	r.reload()
+	if status := post("t0ken"); status != http.StatusServiceUnavailable {
+		t.Errorf("reload failed, want status 503, got %d", status)
+	}
+	r.load = func() (*common.Configuration, error) { return &next, nil }
+	r.reload()
+	if status := post("t0ken"); status != http.StatusSeeOther {
+		t.Errorf("configuration fixed, want status 303, got %d", status)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_diffServeConfig(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	old, next := *common.Config, *common.Config
+	old.RuleThresholds = map[string]int{"ARG.005": 200, "KEY.005": 5}
+	next.RuleThresholds = map[string]int{"ARG.005": 20, "JOI.005": 3}
+	old.IgnoreRules = []string{"COL.011", "ARG.001"}
+	next.IgnoreRules = []string{"ARG.001", "CLA.001"}
+	next.HistoryDSN = "root:pwd-history@127.0.0.1:3306/soar"
+	next.MaxJoinTableCount = old.MaxJoinTableCount + 1
+	got := diffServeConfig(&old, &next)
+	want := []string{
+		"history-dsn: changed",
+		"ignore-rules: added [CLA.001], removed [COL.011]",
+		fmt.Sprintf("max-join-table-count: %d -> %d", old.MaxJoinTableCount, next.MaxJoinTableCount),
+		"rule-thresholds ARG.005: 200 -> 20",
+		"rule-thresholds JOI.005: - -> 3",
+		"rule-thresholds KEY.005: 5 -> -",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if changes := diffServeConfig(&old, &old); len(changes) != 0 {
+		t.Errorf("want no changes, got %q", changes)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_fileStore(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history.jsonl")
+
+	mem := newFileStore("")
+	s := newFileStore(file)
+	for i := 1; i <= servePageSize+50; i++ {
+		e := serveEntry{Query: fmt.Sprintf("select %d", i), Report: fmt.Sprintf("<html>%d</html>", i)}
+		if _, err = mem.add(e); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 文件中的评审记录重启后全部可以查看，页面只列出最近的记录
+	s = newFileStore(file)
+	for _, id := range []int{1, servePageSize + 50} {
+		e, ok, err := s.get(id)
+		if !ok || err != nil || e.Report != fmt.Sprintf("<html>%d</html>", id) {
+			t.Errorf("get(%d) got: %v, %v, %v", id, e, ok, err)
+		}
+	}
+	recent, err := s.recent(servePageSize)
+	if err != nil || len(recent) != servePageSize || recent[0].ID != servePageSize+50 || recent[0].Report != "" {
+		t.Errorf("recent got: %d entries, %v", len(recent), err)
+	}
+	if id, err := s.add(serveEntry{Query: "select 0"}); id != servePageSize+51 || err != nil {
+		t.Errorf("add after reload got: %d, %v", id, err)
+	}
+
+	// 只保存在内存中时保留最近的 serveMemorySize 条
+	if _, ok, _ := mem.get(1); ok {
+		t.Error("memory store should drop old entries")
+	}
+	if e, ok, _ := mem.get(servePageSize + 50); !ok || e.Report == "" {
+		t.Errorf("memory store get got: %v, %v", e, ok)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_serveArgs(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	got := serveArgs([]string{"-config=soar.yaml", "-serve=:5077", "-serve-history=h.jsonl", "--serve", ":5078", "-log-level=3"})
+	want := []string{"-config=soar.yaml", "-serve-history=h.jsonl", "-log-level=3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func Test_Main_formatHistory(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	records := []database.AuditRecord{
+		{QueryID: "A", File: "a.sql", Line: 3, Score: 75, Plan: "film/ALL/", PlanRows: 1000,
+			Findings: []database.HistoryFinding{{Item: "CLA.001", Severity: "L4"}}},
+		{QueryID: "B", File: "b.sql", Line: 1, Score: 100},
+		{QueryID: "A", File: "a.sql", Line: 3, Score: 100, Plan: "film/ref/idx_title", PlanRows: 10},
+	}
+	lines := strings.Split(formatHistory(records), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[2], "| a.sql:3 | 75 | CLA.001(L4) | **执行计划变化** film/ALL/ (之前: film/ref/idx_title, 10 行) | 1000 |") ||
+		strings.Contains(lines[4], "执行计划变化") {
+		t.Errorf("formatHistory got:\n%s", strings.Join(lines, "\n"))
+	}
+	if formatHistory(nil) != "未找到评审记录" {
+		t.Error("formatHistory(nil) should be empty message")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -17,11 +17,15 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/XiaoMi/soar/advisor"
 	"github.com/XiaoMi/soar/ast"
@@ -39,6 +43,78 @@ func initConfig() {
 	}
 	common.BaseDir = filepath.Dir(ex)
 
+	// soar schema-audit, soar lint 子命令等价于 -report-type schema-audit, -report-type lint
+	// -report-type 需要放在待评审的文件名之前，否则不会被解析
+	// soar rules show ARG.003, soar rules test rules_test.yaml 等价于 -show-rule ARG.003, -rule-test rules_test.yaml
+	// soar config show, soar config show --resolved 等价于 -print-config, -print-config-resolved
+	// soar doctor 等价于 -doctor，soar serve [addr] 等价于 -serve=addr，默认监听 127.0.0.1:5077
+	// soar history [QueryID] 等价于 -show-history=QueryID，未指定 Query ID 时输出所有 SQL 最近的评审记录
+	// soar completion bash, soar help rules 等价于 -completion=bash, -list-rule-summaries，soar help 等价于 -help
+	// 子命令可以放在 -config 之前或之后
+	args, rest := []string{os.Args[0]}, os.Args[1:]
+	n := configArgs(rest)
+	args, rest = append(args, rest[:n]...), rest[n:]
+	var subCommand string
+	if len(rest) > 0 {
+		switch rest[0] {
+		case "rules", "config", "doctor", "serve", "history", "schema-audit", "lint", "completion", "help":
+			subCommand, rest = rest[0], rest[1:]
+		}
+	}
+	if n == 0 {
+		n = configArgs(rest)
+		args, rest = append(args, rest[:n]...), rest[n:]
+	}
+	switch subCommand {
+	case "rules":
+		flags := map[string]string{"show": "-show-rule=", "test": "-rule-test="}
+		if len(rest) < 2 || flags[rest[0]] == "" {
+			fmt.Println("usage: soar rules show ARG.003\n       soar rules test rules_test.yaml")
+			os.Exit(1)
+		}
+		args, rest = append(args, flags[rest[0]]+rest[1]), rest[2:]
+	case "config":
+		if len(rest) < 1 || rest[0] != "show" {
+			fmt.Println("usage: soar config show [--resolved]")
+			os.Exit(1)
+		}
+		rest = rest[1:]
+		if len(rest) > 0 && (rest[0] == "--resolved" || rest[0] == "-resolved") {
+			args, rest = append(args, "-print-config-resolved"), rest[1:]
+		} else {
+			args = append(args, "-print-config")
+		}
+	case "doctor":
+		args = append(args, "-doctor")
+	case "serve":
+		addr := "127.0.0.1:5077"
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			addr, rest = rest[0], rest[1:]
+		}
+		args = append(args, "-serve="+addr)
+	case "history":
+		queryID := "all"
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			queryID, rest = rest[0], rest[1:]
+		}
+		args = append(args, "-show-history="+queryID)
+	case "completion":
+		if len(rest) < 1 {
+			fmt.Println("usage: soar completion [bash|zsh|fish]")
+			os.Exit(1)
+		}
+		args, rest = append(args, "-completion="+rest[0]), rest[1:]
+	case "help":
+		if len(rest) > 0 && rest[0] == "rules" {
+			args, rest = append(args, "-list-rule-summaries"), rest[1:]
+		} else {
+			args = append(args, "-help")
+		}
+	case "schema-audit", "lint":
+		args = append(args, "-report-type="+subCommand)
+	}
+	os.Args = append(args, rest...)
+
 	for i, c := range os.Args {
 		// 如果指定了 -config, 它必须是第一个参数
 		if strings.HasPrefix(c, "-config") && i != 1 {
@@ -55,12 +131,33 @@ func initConfig() {
 
 	// 加载配置文件，处理命令行参数
 	err = common.ParseConfig(common.ArgConfig())
-	// 检查配置文件及命令行参数是否正确
-	if common.CheckConfig && err != nil {
+	// 检查配置文件及命令行参数是否正确，指定了 -profile 时配置错误可能导致连接错误的环境，同样直接退出
+	if (common.CheckConfig || common.Profile != "") && err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 	common.LogIfWarn(err, "")
+
+	// 按 -lang, -lang-file 加载评审规则文本
+	err = advisor.LoadRuleLocale(common.Config.Lang, common.Config.LangFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	// 加载库表归属的团队
+	err = advisor.LoadOwnerFile(common.Config.OwnerFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	// ClickHouse 语法与 MySQL 差异较大，使用 ClickHouse 解析器替换默认的 vitess 及 TiDB 解析器
+	if common.Config.Dialect == "clickhouse" {
+		advisor.UnregisterParser("vitess")
+		advisor.UnregisterParser("tidb")
+		advisor.RegisterParser(advisor.ClickHouseParser{})
+	}
 }
 
 // checkConfig for `-check-config` flag
@@ -95,6 +192,7 @@ func checkConfig() int {
 		fmt.Println("test-dsn:", common.Config.OnlineDSN.Addr, err.Error())
 		return 1
 	}
+	connOnline.ReadOnly = true
 	onlineVersion, err := connOnline.Version()
 	if err != nil && !common.Config.OnlineDSN.Disable {
 		fmt.Println("online-dsn:", connOnline, err.Error())
@@ -121,6 +219,18 @@ func helpTools() (isContinue bool, exitCode int) {
 	if common.CheckConfig {
 		return false, checkConfig()
 	}
+	// 检查配置文件、数据库连接、权限及版本，给出修复建议
+	if common.Doctor {
+		return false, doctor()
+	}
+	// 启动评审页面
+	if common.Serve != "" {
+		return false, serve()
+	}
+	// 查看评审记录
+	if common.ShowHistory != "" {
+		return false, showHistory()
+	}
 	// 打印 SOAR 版本信息
 	if common.PrintVersion {
 		common.SoarVersion()
@@ -131,11 +241,37 @@ func helpTools() (isContinue bool, exitCode int) {
 		common.PrintConfiguration()
 		return false, 0
 	}
+	// 打印生效的配置及每个配置项的来源（默认值、配置文件、环境变量、命令行参数）
+	if common.PrintConfigResolved {
+		common.PrintResolvedConfiguration()
+		return false, 0
+	}
+	// 输出 shell 自动补全脚本
+	if common.Completion != "" {
+		return false, completion(common.Completion)
+	}
+	// 打印评审规则编号及一句话说明
+	if common.ListRuleSummaries {
+		advisor.ListRuleSummaries()
+		return false, 0
+	}
 	// 打印支持启发式建议
 	if common.Config.ListHeuristicRules {
 		advisor.ListHeuristicRules(advisor.HeuristicRules)
 		return false, 0
 	}
+	// 打印指定启发式建议的完整文档
+	if common.Config.ShowRule != "" {
+		if err := advisor.ShowHeuristicRule(common.Config.ShowRule); err != nil {
+			fmt.Println(err.Error())
+			return false, 1
+		}
+		return false, 0
+	}
+	// 执行规则单元测试，有用例失败时以非零状态退出，方便在 CI 中使用
+	if common.Config.RuleTest != "" {
+		return false, ruleTest(common.Config.RuleTest)
+	}
 	// 打印支持的 SQL 重写规则
 	if common.Config.ListRewriteRules {
 		ast.ListRewriteRules(ast.RewriteRules)
@@ -172,6 +308,10 @@ func reportTool(sql string, bom []byte) (isContinue bool, exitCode int) {
 		// 注意： 这里只能处理一条 SQL 的 EXPLAIN 信息，用户一次反馈多条 SQL 的 EXPLAIN 信息无法处理
 		advisor.DigestExplainText(sql)
 		return false, 0
+	case "partition":
+		// 输入为建表语句及业务 SQL，给出分区建议
+		fmt.Println(advisor.PartitionAdvise(sql))
+		return false, 0
 	case "chardet":
 		// Get charset of input
 		charset := common.CheckCharsetByBOM(bom)
@@ -189,6 +329,649 @@ func reportTool(sql string, bom []byte) (isContinue bool, exitCode int) {
 }
 
 // initQuery
+// diffBase 获取 schema-diff 的基准 Schema，-diff-base 为文件时读取 mysqldump 导出的内容，否则作为 DSN 连接数据库
+func diffBase(rEnv *database.Connector) []advisor.SchemaTable {
+	if common.Config.DiffBase == "" {
+		return advisor.LoadSchema(rEnv, "")
+	}
+	if _, err := os.Stat(common.Config.DiffBase); err == nil {
+		data, err := ioutil.ReadFile(common.Config.DiffBase)
+		if err != nil {
+			common.Log.Critical("ioutil.ReadFile Error: %v", err)
+			os.Exit(1)
+		}
+		dump, _ := common.RemoveBOM(data)
+		return advisor.LoadSchema(nil, dump)
+	}
+	conn, err := database.NewConnector(common.ParseDSN(common.Config.DiffBase, nil))
+	if err != nil {
+		common.Log.Critical("database.NewConnector Error: %v", err)
+		os.Exit(1)
+	}
+	return advisor.LoadSchema(conn, "")
+}
+
+// loadSchemaFile 读取 -schema-file 指定的 mysqldump 导出文件作为离线表结构
+func loadSchemaFile() {
+	if common.Config.SchemaFile == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(common.Config.SchemaFile)
+	if err != nil {
+		common.Log.Critical("ioutil.ReadFile Error: %v", err)
+		os.Exit(1)
+	}
+	dump, _ := common.RemoveBOM(data)
+	advisor.LoadOfflineSchema(dump)
+}
+
+// expandView 记录输入中 CREATE VIEW 的定义，并将 SQL 中引用的视图展开为子查询
+// 输入中未定义的视图从 OnlineDsn 中获取，查询结果会缓存在 views 中
+func expandView(sql, currentDB string, views map[string]string, rEnv *database.Connector) string {
+	if db, name, def, ok := ast.ViewDefinition(sql); ok {
+		if db == "" {
+			db = currentDB
+		}
+		views[strings.ToLower(db+"."+name)] = def
+		return sql
+	}
+
+	lookup := func(db, table string) string {
+		if db == "" {
+			db = currentDB
+		}
+		key := strings.ToLower(db + "." + table)
+		if def, ok := views[key]; ok {
+			return def
+		}
+		var def string
+		if !common.Config.OnlineDSN.Disable && rEnv != nil {
+			var err error
+			def, err = rEnv.ShowViewDefinition(db, table)
+			common.LogIfWarn(err, "")
+		}
+		views[key] = def
+		return def
+	}
+	expanded, ok := ast.ExpandView(sql, lookup)
+	if ok {
+		common.Log.Debug("expandView: %s => %s", sql, expanded)
+	}
+	return expanded
+}
+
+// inputFile 待评审的输入
+type inputFile struct {
+	Name string // 文件名，从管道读取时为 stdin，-query 直接指定 SQL 时为 null
+	Buf  string
+}
+
+// initInputs 读入待评审的 SQL，-query 及命令行中的其他参数可以是文件、目录或通配符（支持 ** 匹配多级目录）
+// 目录中只读取 .sql 文件，未指定任何输入时从管道读取
+func initInputs() []inputFile {
+	patterns := flag.Args()
+	for _, p := range patterns {
+		// flag 包遇到第一个非参数的值后停止解析，文件名之后的参数不会生效
+		if strings.HasPrefix(p, "-") {
+			fmt.Printf("flag %s must be placed before input files\n", p)
+			os.Exit(1)
+		}
+	}
+	if common.Config.Query != "" {
+		patterns = append([]string{common.Config.Query}, patterns...)
+	}
+
+	var inputs []inputFile
+	for _, p := range patterns {
+		files := expandInput(p)
+		if len(files) == 0 {
+			// 不是文件时作为 SQL 处理
+			inputs = append(inputs, inputFile{Name: "null", Buf: p})
+			continue
+		}
+		for _, f := range files {
+			inputs = append(inputs, inputFile{Name: f, Buf: initQuery(f)})
+		}
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, inputFile{Name: "stdin", Buf: initQuery("")})
+	}
+	return inputs
+}
+
+// expandInput 将目录或通配符展开为文件列表，p 为普通文件时返回其本身，没有匹配的文件时返回空
+func expandInput(p string) []string {
+	if info, err := os.Stat(p); err == nil {
+		if info.IsDir() {
+			return findFiles(p, "*.sql")
+		}
+		return []string{p}
+	}
+	if !strings.ContainsAny(p, "*?[") {
+		return nil
+	}
+
+	// filepath.Glob 不支持 **，这里遍历 ** 之前的目录，用 ** 之后的部分匹配文件路径
+	if i := strings.Index(p, "**"); i >= 0 {
+		root := filepath.Clean(p[:i])
+		pattern := strings.TrimLeft(p[i+2:], `/\`)
+		if pattern == "" {
+			pattern = "*"
+		}
+		return findFiles(root, pattern)
+	}
+
+	var files []string
+	matches, err := filepath.Glob(p)
+	common.LogIfWarn(err, "")
+	for _, f := range matches {
+		if info, err := os.Stat(f); err == nil && !info.IsDir() {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// findFiles 递归查找 root 目录下匹配 pattern 的文件，pattern 中可以包含目录，如 up/*.sql
+func findFiles(root, pattern string) []string {
+	var files []string
+	depth := strings.Count(pattern, "/") + 1
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		parts := strings.Split(filepath.ToSlash(path), "/")
+		if len(parts) < depth {
+			return nil
+		}
+		if ok, _ := filepath.Match(pattern, strings.Join(parts[len(parts)-depth:], "/")); ok {
+			files = append(files, path)
+		}
+		return nil
+	})
+	common.LogIfWarn(err, "")
+	return files
+}
+
+// joinInputs 合并所有输入，用于 pretty, archive 等不区分文件的功能
+func joinInputs(inputs []inputFile) string {
+	if len(inputs) == 1 {
+		return inputs[0].Buf
+	}
+	var buf []string
+	for _, input := range inputs {
+		sql, _ := common.RemoveBOM([]byte(strings.TrimSpace(input.Buf)))
+		if sql == "" {
+			continue
+		}
+		if !strings.HasSuffix(sql, common.Config.Delimiter) {
+			sql += common.Config.Delimiter
+		}
+		buf = append(buf, sql)
+	}
+	return strings.Join(buf, "\n")
+}
+
+// fixInputs 对每个输入文件应用 -fix 的修复并写回原文件，逐条输出已应用的修复
+// 从管道或 -query 直接读入的 SQL 没有可写回的文件，不做修复
+func fixInputs(inputs []inputFile) {
+	for i, input := range inputs {
+		if input.Name == "stdin" || input.Name == "null" {
+			continue
+		}
+		buf, bom := common.RemoveBOM([]byte(input.Buf))
+		fixed, fixes := advisor.FixFile(buf, common.Config.Delimiter)
+		if len(fixes) == 0 {
+			continue
+		}
+		stat, err := os.Stat(input.Name)
+		if err != nil {
+			common.Log.Error("fixInputs os.Stat Error: %v", err)
+			continue
+		}
+		err = ioutil.WriteFile(input.Name, []byte(string(bom)+fixed), stat.Mode())
+		if err != nil {
+			common.Log.Error("fixInputs ioutil.WriteFile Error: %v", err)
+			continue
+		}
+		for _, f := range fixes {
+			fmt.Printf("%s:%d:fixed %s %s -> %s\n", input.Name, f.Line, f.Item, f.Before, f.After)
+		}
+		inputs[i].Buf = string(bom) + fixed
+	}
+}
+
+// printFindings 输出带文件行号的建议，xlsx 为二进制内容，不能在末尾追加换行
+func printFindings(findings []advisor.Finding) {
+	if common.Config.ReportType == "xlsx" {
+		fmt.Print(advisor.FormatFindings(findings))
+		return
+	}
+	fmt.Println(advisor.FormatFindings(findings))
+}
+
+// reportFiles -report-dir 中生成的报告文件，用于上传至 -report-storage
+var reportFiles []string
+
+// serverInfo 评审时线上环境的版本、sql_mode 及相关变量，输出在 markdown, html 报告的开头及 JSON 报告的 Server 中
+var serverInfo *database.ServerInfo
+
+// captureServerInfo 获取线上环境信息，未配置线上环境或获取失败时返回 nil
+func captureServerInfo(rEnv *database.Connector) *database.ServerInfo {
+	if rEnv == nil || common.Config.OnlineDSN.Disable {
+		return nil
+	}
+	switch common.Config.ReportType {
+	case "markdown", "html", "json":
+	default:
+		return nil
+	}
+	info, err := rEnv.ServerInfo()
+	if err != nil {
+		common.Log.Warning("captureServerInfo Error: %v", err)
+		return nil
+	}
+	return info
+}
+
+// printServerInfo 在 markdown, html 报告的开头输出评审环境
+func printServerInfo() {
+	str := database.FormatServerInfo(serverInfo)
+	if str == "" {
+		return
+	}
+	switch common.Config.ReportType {
+	case "markdown":
+		fmt.Println(str)
+	case "html":
+		fmt.Println(common.Markdown2HTML(str))
+	}
+}
+
+// reportOutput 将输出重定向至 -report-dir 中与输入文件对应的报告文件
+func reportOutput(name string) *os.File {
+	ext := map[string]string{
+		"markdown":      ".md",
+		"html":          ".html",
+		"json":          ".json",
+		"codequality":   ".json",
+		"rdjson":        ".json",
+		"checkstyle":    ".xml",
+		"tap":           ".tap",
+		"xlsx":          ".xlsx",
+		"jira":          ".json",
+		"issues":        ".json",
+		"archery":       ".json",
+		"yearning":      ".json",
+		"bytebase":      ".json",
+		"workload":      ".md",
+		"shard-advisor": ".md",
+		"compat-lint":   ".md",
+	}[common.Config.ReportType]
+	if ext == "" {
+		ext = ".txt"
+	}
+	// 不同目录中的同名文件使用完整路径区分
+	base := strings.Trim(filepath.ToSlash(filepath.Clean(name)), "./")
+	base = strings.Replace(base, "/", "_", -1) + ext
+
+	err := os.MkdirAll(common.Config.ReportDir, 0755)
+	if err == nil {
+		var f *os.File
+		f, err = os.Create(filepath.Join(common.Config.ReportDir, base))
+		if err == nil {
+			os.Stdout = f
+			reportFiles = append(reportFiles, f.Name())
+			return f
+		}
+	}
+	common.Log.Critical("reportOutput Error: %v", err)
+	os.Exit(1)
+	return nil
+}
+
+// uploadReports 将 -report-dir 中生成的报告上传至 -report-storage 并输出访问地址，tmpDir 不为空时上传后删除该临时目录
+// 同一次执行的报告使用相同的时间戳作为目录，避免覆盖之前上传的报告
+func uploadReports(tmpDir string) {
+	if common.Config.ReportStorage == "" {
+		return
+	}
+	if tmpDir != "" {
+		defer os.RemoveAll(tmpDir)
+	}
+	storage, err := common.NewObjectStorage(common.Config.ReportStorage)
+	if err != nil {
+		common.Log.Critical("uploadReports Error: %v", err)
+		os.Exit(1)
+	}
+	dir := time.Now().Format("20060102150405")
+	expires := time.Duration(common.Config.ReportURLExpires) * time.Hour
+	failed := false
+	for _, file := range reportFiles {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			common.Log.Error("uploadReports ioutil.ReadFile Error: %v", err)
+			failed = true
+			continue
+		}
+		key := storage.Key(dir + "/" + filepath.Base(file))
+		err = storage.Put(key, body, reportContentType(file), common.Config.ReportRetention)
+		if err != nil {
+			common.Log.Error("uploadReports Put Error: %v", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %s\n", filepath.Base(file), storage.URL(key, expires))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// reportContentType 按报告文件的后缀返回上传时使用的 Content-Type，浏览器打开链接时可以直接显示
+func reportContentType(file string) string {
+	switch filepath.Ext(file) {
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".json":
+		return "application/json; charset=utf-8"
+	case ".xml":
+		return "application/xml; charset=utf-8"
+	case ".xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// jsonWithLocation 在 JSON 格式的建议中添加 SQL 所在的文件名及行号
+func jsonWithLocation(str, file string, line int) string {
+	var sug advisor.JSONSuggest
+	if err := json.Unmarshal([]byte(str), &sug); err != nil {
+		return str
+	}
+	sug.File = file
+	sug.Line = line
+	sug.Server = serverInfo
+	js, err := json.MarshalIndent(sug, "", "  ")
+	if err != nil {
+		return str
+	}
+	return string(js)
+}
+
+// bufferedReport 开启 -aggregate-duplicates 或按影响排序时缓存的单条 SQL 建议
+type bufferedReport struct {
+	id        string                  // fingerprint.ID
+	str       string                  // 首次出现时格式化后的建议
+	suggest   map[string]advisor.Rule // 用于计算影响
+	file      string                  // 首次出现的文件名
+	line      int                     // 首次出现的行号
+	locations []string                // 所有出现的位置，格式为 file:line
+	stats     advisor.QueryStats      // 执行统计，未指定 -query-stats 时执行次数为出现的次数
+	impact    int64                   // 执行次数 x Severity 之和
+	critical  bool                    // 是否涉及 critical-objects 中的关键库表
+	owner     string                  // owner-file 中负责该 SQL 的团队
+}
+
+// reportBuffer 按首次出现的顺序缓存建议，输入文件读取完成后合并、排序输出，每个输入文件重新计算
+type reportBuffer struct {
+	reports []*bufferedReport
+	last    map[string]*bufferedReport    // key 为 fingerprint.ID
+	stats   map[string]advisor.QueryStats // -query-stats 读入的执行统计
+}
+
+func newReportBuffer(stats map[string]advisor.QueryStats) *reportBuffer {
+	return &reportBuffer{last: make(map[string]*bufferedReport), stats: stats}
+}
+
+// bufferReports 判断是否需要缓存建议，仅支持 markdown, html, json 格式
+func bufferReports() bool {
+	switch common.Config.ReportType {
+	case "markdown", "html", "json":
+		return common.Config.AggregateDuplicates || common.Config.QueryStats != "" || common.Config.Top > 0 ||
+			advisor.OwnersEnabled()
+	}
+	return false
+}
+
+// loadQueryStats 读取 -query-stats 指定的 SQL 执行统计
+func loadQueryStats() map[string]advisor.QueryStats {
+	if common.Config.QueryStats == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(common.Config.QueryStats)
+	if err == nil {
+		var stats map[string]advisor.QueryStats
+		stats, err = advisor.LoadQueryStats(common.Config.QueryStats, data)
+		if err == nil {
+			return stats
+		}
+	}
+	common.Log.Critical("loadQueryStats Error: %v", err)
+	os.Exit(1)
+	return nil
+}
+
+// add 记录一条新的建议
+func (b *reportBuffer) add(id, str, file string, line int, suggest map[string]advisor.Rule) {
+	r := &bufferedReport{
+		id:        id,
+		str:       str,
+		suggest:   suggest,
+		file:      file,
+		line:      line,
+		locations: []string{fmt.Sprintf("%s:%d", file, line)},
+	}
+	b.reports = append(b.reports, r)
+	b.last[id] = r
+}
+
+// seen 记录重复出现的 SQL 所在位置
+func (b *reportBuffer) seen(id, file string, line int) {
+	if r, ok := b.last[id]; ok {
+		r.locations = append(r.locations, fmt.Sprintf("%s:%d", file, line))
+	}
+}
+
+// markCritical 标记涉及关键库表的 SQL，使用 -top 时始终输出
+func (b *reportBuffer) markCritical(id string) {
+	if r, ok := b.last[id]; ok {
+		r.critical = true
+	}
+}
+
+// setOwner 记录负责该 SQL 的团队
+func (b *reportBuffer) setOwner(id, owner string) {
+	if r, ok := b.last[id]; ok {
+		r.owner = owner
+	}
+}
+
+// sorted 指定 -query-stats 或 -top 时按影响从大到小排序，并只保留前 -top 条，涉及关键库表的 SQL 始终保留
+func (b *reportBuffer) sorted() []*bufferedReport {
+	reports := b.reports
+	if common.Config.QueryStats == "" && common.Config.Top <= 0 {
+		return reports
+	}
+	for _, r := range reports {
+		if b.stats != nil {
+			r.stats = b.stats[r.id]
+		} else {
+			r.stats = advisor.QueryStats{Count: int64(len(r.locations))}
+		}
+		r.impact = advisor.QueryImpact(r.stats.Count, r.suggest)
+	}
+	reports = append([]*bufferedReport{}, reports...)
+	sort.SliceStable(reports, func(i, j int) bool {
+		if reports[i].impact != reports[j].impact {
+			return reports[i].impact > reports[j].impact
+		}
+		return reports[i].stats.Latency > reports[j].stats.Latency
+	})
+	if common.Config.Top > 0 && len(reports) > common.Config.Top {
+		top := reports[:common.Config.Top]
+		for _, r := range reports[common.Config.Top:] {
+			if r.critical {
+				top = append(top, r)
+			}
+		}
+		reports = top
+	}
+	return reports
+}
+
+// byOwner 配置了 owner-file 时按团队名称分组，未归属的 SQL 排在最后，组内保持原有顺序
+func byOwner(reports []*bufferedReport) [][]*bufferedReport {
+	if !advisor.OwnersEnabled() {
+		return [][]*bufferedReport{reports}
+	}
+	index := make(map[string]int)
+	var groups [][]*bufferedReport
+	for _, r := range reports {
+		i, ok := index[r.owner]
+		if !ok {
+			i = len(groups)
+			index[r.owner] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i][0].owner, groups[j][0].owner
+		if (a == advisor.Unowned) != (b == advisor.Unowned) {
+			return b == advisor.Unowned
+		}
+		return a < b
+	})
+	return groups
+}
+
+// format 按报告类型输出缓存的建议，配置了 owner-file 时 markdown, html 按团队分节输出，json 中添加 Owner
+func (b *reportBuffer) format(reportType string) []string {
+	var ret []string
+	for _, group := range byOwner(b.sorted()) {
+		if advisor.OwnersEnabled() && reportType != "json" {
+			header := fmt.Sprintf("# Owner: %s\n\n* **SQL 数量:**  %d\n", group[0].owner, len(group))
+			if reportType == "html" {
+				header = common.Markdown2HTML(header)
+			}
+			ret = append(ret, header)
+		}
+		ret = append(ret, formatReports(reportType, group)...)
+	}
+	return ret
+}
+
+// formatReports 按报告类型输出一组建议
+func formatReports(reportType string, reports []*bufferedReport) []string {
+	var ret []string
+	for _, r := range reports {
+		switch reportType {
+		case "json":
+			ret = append(ret, r.json())
+		case "html":
+			ret = append(ret, common.Markdown2HTML(r.markdown()))
+		default:
+			ret = append(ret, r.markdown())
+		}
+	}
+	return ret
+}
+
+// markdown 在建议的末尾添加出现次数、位置及执行统计
+func (r *bufferedReport) markdown() string {
+	str := r.str
+	if common.Config.AggregateDuplicates && len(r.locations) > 1 {
+		str = fmt.Sprintf("%s\n\n## 重复出现的 SQL\n\n* **出现次数:**  %d\n\n* **位置:**  %s\n",
+			strings.TrimRight(str, "\n"), len(r.locations), strings.Join(r.locations, ", "))
+	}
+	if common.Config.QueryStats != "" {
+		str = fmt.Sprintf("%s\n\n## 执行统计\n\n* **执行次数:**  %d\n\n* **总耗时:**  %.3fs\n\n* **影响:**  %d\n",
+			strings.TrimRight(str, "\n"), r.stats.Count, r.stats.Latency, r.impact)
+	}
+	return str
+}
+
+// json 在 JSON 格式的建议中添加位置、出现次数及执行统计
+func (r *bufferedReport) json() string {
+	var sug advisor.JSONSuggest
+	if err := json.Unmarshal([]byte(r.str), &sug); err != nil {
+		return r.str
+	}
+	sug.File = r.file
+	sug.Line = r.line
+	if common.Config.AggregateDuplicates {
+		sug.Occurrences = len(r.locations)
+		sug.Locations = r.locations
+	}
+	if common.Config.QueryStats != "" {
+		sug.Executions = r.stats.Count
+		sug.Latency = r.stats.Latency
+		sug.Impact = r.impact
+	}
+	sug.Owner = r.owner
+	sug.Server = serverInfo
+	js, err := json.MarshalIndent(sug, "", "  ")
+	if err != nil {
+		return r.str
+	}
+	return string(js)
+}
+
+// configArgs 返回 args 开头 -config 参数所占的个数，支持 -config=soar.yaml 及 -config soar.yaml 两种写法
+func configArgs(args []string) int {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-config") {
+		return 0
+	}
+	if args[0] == "-config" && len(args) > 1 {
+		return 2
+	}
+	return 1
+}
+
+// ruleTest 执行 -rule-test 指定的规则单元测试用例
+func ruleTest(file string) int {
+	cases, err := advisor.LoadRuleTestCases(file)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	var results []advisor.RuleTestResult
+	exitCode := 0
+	for _, c := range cases {
+		res := advisor.RunRuleTest(c)
+		if !res.Passed() {
+			exitCode = 1
+		}
+		results = append(results, res)
+	}
+	fmt.Println(advisor.FormatRuleTestResults(results))
+	return exitCode
+}
+
+// checkRule 执行单条启发式规则，语法解析失败时 AST 不完整，部分规则可能 panic，这里捕获后忽略该规则
+func checkRule(item string, rule advisor.Rule, q *advisor.Query4Audit) (r advisor.Rule) {
+	defer func() {
+		if err := recover(); err != nil {
+			common.Log.Error("checkRule %s recover: %v, Query: %s", item, err, q.Query)
+			r = advisor.HeuristicRules["OK"]
+		}
+	}()
+	return rule.Func(q)
+}
+
+// stdinIsTerminal 标准输入是否为终端，为 false 时表示可以从管道读取输入
+func stdinIsTerminal() bool {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return true
+	}
+	return (stat.Mode() & os.ModeCharDevice) != 0
+}
+
 func initQuery(query string) string {
 	// 读入待优化 SQL ，当配置文件或命令行参数未指定 SQL 时从管道读取
 	if query == "" {
@@ -231,9 +1014,9 @@ func shutdown(vEnv *env.VirtualEnv, rEnv *database.Connector) {
 	if common.Config.DropTestTemporary {
 		vEnv.CleanUp()
 	}
-	err := vEnv.Conn.Close()
+	err := vEnv.Close()
 	common.LogIfWarn(err, "")
-	err = rEnv.Conn.Close()
+	err = rEnv.Close()
 	common.LogIfWarn(err, "")
 	os.Exit(0)
 }
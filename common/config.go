@@ -28,6 +28,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,10 +41,28 @@ var (
 	BlackList []string
 	// PrintConfig -print-config
 	PrintConfig bool
+	// PrintConfigResolved -print-config-resolved
+	PrintConfigResolved bool
 	// PrintVersion -print-config
 	PrintVersion bool
 	// CheckConfig -check-config
 	CheckConfig bool
+	// Doctor -doctor
+	Doctor bool
+	// Serve -serve 评审页面的监听地址
+	Serve string
+	// ShowHistory -show-history 查看评审记录，值为 Query ID 或 all
+	ShowHistory string
+	// Completion -completion 输出指定 shell 的自动补全脚本
+	Completion string
+	// Fix -fix 将白名单中的安全修复写回待评审的文件
+	Fix bool
+	// ListRuleSummaries -list-rule-summaries
+	ListRuleSummaries bool
+	// ConfigFile 已加载的配置文件，未找到配置文件时为空
+	ConfigFile string
+	// Profile -profile 选择的环境配置
+	Profile string
 	// 防止 readCmdFlags 函数重入
 	hasParsed bool
 )
@@ -51,19 +70,25 @@ var (
 // Configuration 配置文件定义结构体
 type Configuration struct {
 	// +++++++++++++++测试环境+++++++++++++++++
-	OnlineDSN               *Dsn   `yaml:"online-dsn"`                // 线上环境数据库配置
-	TestDSN                 *Dsn   `yaml:"test-dsn"`                  // 测试环境数据库配置
-	AllowOnlineAsTest       bool   `yaml:"allow-online-as-test"`      // 允许 Online 环境也可以当作 Test 环境
-	DropTestTemporary       bool   `yaml:"drop-test-temporary"`       // 是否清理Test环境产生的临时库表
-	CleanupTestDatabase     bool   `yaml:"cleanup-test-database"`     // 清理残余的测试数据库（程序异常退出或未开启drop-test-temporary）  issue #48
-	OnlySyntaxCheck         bool   `yaml:"only-syntax-check"`         // 只做语法检查不输出优化建议
-	SamplingStatisticTarget int    `yaml:"sampling-statistic-target"` // 数据采样因子，对应 PostgreSQL 的 default_statistics_target
-	Sampling                bool   `yaml:"sampling"`                  // 数据采样开关
-	SamplingCondition       string `yaml:"sampling-condition"`        // 指定采样条件，如：WHERE xxx LIMIT xxx;
-	Profiling               bool   `yaml:"profiling"`                 // 在开启数据采样的情况下，在测试环境执行进行profile
-	Trace                   bool   `yaml:"trace"`                     // 在开启数据采样的情况下，在测试环境执行进行Trace
-	Explain                 bool   `yaml:"explain"`                   // Explain开关
-	Delimiter               string `yaml:"delimiter"`                 // SQL分隔符
+	OnlineDSN               *Dsn     `yaml:"online-dsn"`                // 线上环境数据库配置
+	TestDSN                 *Dsn     `yaml:"test-dsn"`                  // 测试环境数据库配置
+	AllowOnlineAsTest       bool     `yaml:"allow-online-as-test"`      // 允许 Online 环境也可以当作 Test 环境
+	OnlineAllowList         []string `yaml:"online-allow-list"`         // Online 环境只允许执行以这些关键字开头的 SQL
+	IKnowWhatIAmDoing       bool     `yaml:"i-know-what-i-am-doing"`    // 关闭 Online 环境的只读保护，允许执行任意 SQL
+	DropTestTemporary       bool     `yaml:"drop-test-temporary"`       // 是否清理Test环境产生的临时库表
+	CleanupTestDatabase     bool     `yaml:"cleanup-test-database"`     // 清理残余的测试数据库（程序异常退出或未开启drop-test-temporary）  issue #48
+	OnlySyntaxCheck         bool     `yaml:"only-syntax-check"`         // 只做语法检查不输出优化建议
+	SamplingStatisticTarget int      `yaml:"sampling-statistic-target"` // 数据采样因子，对应 PostgreSQL 的 default_statistics_target
+	Sampling                bool     `yaml:"sampling"`                  // 数据采样开关
+	SamplingCondition       string   `yaml:"sampling-condition"`        // 指定采样条件，如：WHERE xxx LIMIT xxx;
+	StatisticsTransfer      bool     `yaml:"statistics-transfer"`       // 只复制表的统计信息到测试环境，不泵取数据
+	Profiling               bool     `yaml:"profiling"`                 // 在开启数据采样的情况下，在测试环境执行进行profile
+	Trace                   bool     `yaml:"trace"`                     // 在开启数据采样的情况下，在测试环境执行进行Trace
+	Explain                 bool     `yaml:"explain"`                   // Explain开关
+	Delimiter               string   `yaml:"delimiter"`                 // SQL分隔符
+	QueryRetry              int      `yaml:"query-retry"`               // 只读 SQL 遇到连接中断、死锁等临时错误时的重试次数，其余 SQL 可能已经执行，不重试
+	QueryRetryBackoff       int      `yaml:"query-retry-backoff"`       // 首次重试前的等待时间（毫秒），之后每次翻倍
+	QueryTimeout            int      `yaml:"query-timeout"`             // EXPLAIN、Trace、Profiling、数据采样等单条 SQL 的超时时间（秒），超时后 KILL QUERY，0 表示不限制
 
 	// +++++++++++++++日志相关+++++++++++++++++
 	// 日志级别，这里使用了 beego 的 log 包
@@ -80,12 +105,19 @@ type Configuration struct {
 	ReportJavascript string `yaml:"report-javascript"`
 	// 当ReportType 为 html 格式时，HTML 的 title
 	ReportTitle string `yaml:"report-title"`
+	// markdown, html 报告中为复杂的多表 JOIN 绘制关联图，支持 mermaid, dot，为空时不输出
+	JoinGraph string `yaml:"join-graph"`
+	// html 报告中渲染 mermaid 关系图使用的 mermaid.min.js 地址，为空时不加载外部脚本，直接显示 mermaid 源码
+	MermaidJS string `yaml:"mermaid-js"`
 	// blackfriday markdown2html config
 	MarkdownExtensions int `yaml:"markdown-extensions"` // markdown 转 html 支持的扩展包, 参考blackfriday
 	MarkdownHTMLFlags  int `yaml:"markdown-html-flags"` // markdown 转 html 支持的 flag, 参考blackfriday, default 0
 
 	// ++++++++++++++优化建议相关++++++++++++++
 	IgnoreRules          []string `yaml:"ignore-rules"`              // 忽略的优化建议规则
+	RulePrecedence       []string `yaml:"rule-precedence"`           // 建议间的优先级，如 IDX.001>ARG.003 表示给出 IDX.001 时不再给出 ARG.003
+	Lang                 string   `yaml:"lang"`                      // 评审规则文本的语言，支持 en, zh-CN
+	LangFile             string   `yaml:"lang-file"`                 // 自定义评审规则文本的 YAML 文件，按规则 Item 覆盖 summary, content
 	RewriteRules         []string `yaml:"rewrite-rules"`             // 生效的重写规则
 	BlackList            string   `yaml:"blacklist"`                 // blacklist 中的 SQL 不会被评审，可以是指纹，也可以是正则
 	MaxJoinTableCount    int      `yaml:"max-join-table-count"`      // 单条 SQL 中 JOIN 表的最大数量
@@ -95,6 +127,11 @@ type Configuration struct {
 	MaxTextColsCount     int      `yaml:"max-text-cols-count"`       // 表中含有的 text/blob 列的最大数量
 	MaxTotalRows         uint64   `yaml:"max-total-rows"`            // 计算散粒度时，当数据行数大于 MaxTotalRows 即开启数据库保护模式，散粒度返回结果可信度下降
 	MaxQueryCost         int64    `yaml:"max-query-cost"`            // last_query_cost 超过该值时将给予警告
+	MaxAffectedRows      int64    `yaml:"max-affected-rows"`         // UPDATE, DELETE 预计影响的行数超过该值时给出 EXP.004 警告，为 0 时不检查
+	AffectedRowsCount    bool     `yaml:"affected-rows-count"`       // 在线上环境执行 SELECT COUNT(*) 统计 UPDATE, DELETE 影响的行数，否则使用 EXPLAIN 的预估值
+	MaxResultRows        int64    `yaml:"max-result-rows"`           // 没有 LIMIT 的 SELECT 预计返回的行数超过该值时给出 EXP.005 警告，为 0 时不检查
+	MaxResultSize        int64    `yaml:"max-result-size"`           // 没有 LIMIT 的 SELECT 预计返回的数据量超过该值（MB）时给出 EXP.005 警告，为 0 时不检查
+	MaxScanPoolPercent   int      `yaml:"max-scan-pool-percent"`     // 全表扫描的表大小超过 innodb_buffer_pool_size 的该百分比时给出 EXP.006 警告，为 0 时不检查
 	SpaghettiQueryLength int      `yaml:"spaghetti-query-length"`    // SQL最大长度警告，超过该长度会给警告
 	AllowDropIndex       bool     `yaml:"allow-drop-index"`          // 允许输出删除重复索引的建议
 	MaxInCount           int      `yaml:"max-in-count"`              // IN()最大数量
@@ -106,16 +143,83 @@ type Configuration struct {
 	MaxIdxCount          int      `yaml:"max-index-count"`           // 单张表允许最多索引数
 	MaxColCount          int      `yaml:"max-column-count"`          // 单张表允许最大列数
 	MaxValueCount        int      `yaml:"max-value-count"`           // INSERT/REPLACE 单次允许批量写入的行数
+	InsertBatchSize      int      `yaml:"insert-batch-size"`         // ARG.012 拆分 INSERT/REPLACE 时每条语句写入的行数，为 0 时使用 max-value-count
 	IdxPrefix            string   `yaml:"index-prefix"`              // 普通索引建议使用的前缀
 	UkPrefix             string   `yaml:"unique-key-prefix"`         // 唯一键建议使用的前缀
 	MaxSubqueryDepth     int      `yaml:"max-subquery-depth"`        // 子查询最大尝试
 	MaxVarcharLength     int      `yaml:"max-varchar-length"`        // varchar最大长度
 	ColumnNotAllowType   []string `yaml:"column-not-allow-type"`     // 字段不允许使用的数据类型
 	MinCardinality       float64  `yaml:"min-cardinality"`           // 添加索引散粒度阈值，范围 0~100
+	MaxAutoIncRatio      float64  `yaml:"max-auto-inc-ratio"`        // 自增值占列类型最大值的比例超过该值时给出警告，范围 0~1
+	MaxLockTxnStatements int      `yaml:"max-lock-txn-statements"`   // 事务中加锁读之后到提交前允许执行的语句数
+	MaxRecursionDepth    int      `yaml:"max-recursion-depth"`       // 递归 CTE 允许的最大递归次数，对应 cte_max_recursion_depth
+	MDLHotTableOps       float64  `yaml:"mdl-hot-table-ops"`         // 平均每秒行读写次数超过该值的表视为热点表，对其执行 DDL 时给出元数据锁风险提示
+	MDLLongQueryTime     int      `yaml:"mdl-long-query-time"`       // 执行时间超过该值（秒）的事务及请求可能阻塞 DDL 获取元数据锁
+	ArchiveMinRows       uint64   `yaml:"archive-min-rows"`          // 行数超过该值的表给出归档建议
+	ArchiveMinSize       uint64   `yaml:"archive-min-size"`          // 数据及索引大小超过该值（MB）的表给出归档建议
+	ArchiveKeepDays      int      `yaml:"archive-keep-days"`         // 归档后线上表中保留最近多少天的数据
+	ArchiveChunkSize     int      `yaml:"archive-chunk-size"`        // 归档时每批次处理的行数
+	CompressMinSize      uint64   `yaml:"compress-min-size"`         // 数据及索引大小超过该值（MB）的表给出压缩建议
+	CompressWriteRatio   float64  `yaml:"compress-write-ratio"`      // 写入次数占读写总次数的比例超过该值的表不建议压缩，压缩表超过该值时建议取消压缩
+	CapacityHistory      string   `yaml:"capacity-history"`          // 表大小历史快照的 CSV 文件，每次连接线上环境运行时追加当前快照
+	CapacityHorizon      int      `yaml:"capacity-horizon"`          // 预测未来多少天内的容量
+	CapacityMaxSize      uint64   `yaml:"capacity-max-size"`         // 单表数据及索引大小（MB）的上限
+	CapacityDiskSize     uint64   `yaml:"capacity-disk-size"`        // 整个 database 可用的磁盘空间（MB），为 0 时不检查
+	DiffBase             string   `yaml:"diff-base"`                 // schema-diff 的基准 Schema，mysqldump 导出文件或 DSN，默认为 OnlineDsn
+	SchemaFile           string   `yaml:"schema-file"`               // 离线表结构，mysqldump --no-data 导出的文件，用于不连接数据库时检查隐式类型转换等依赖列类型的规则
+	ExpandView           bool     `yaml:"expand-view"`               // 将 SELECT 中引用的视图展开为子查询后再给出建议
+	ShardTables          []string `yaml:"shard-tables"`              // 分表配置，格式为 [逻辑表名=]分表名模式[:分片键]，如 user=user_%d:uid
+	Target               string   `yaml:"target"`                    // 目标数据库类型及版本，格式为 flavor[:version]，如 mysql:8.0, mariadb:10.6
+	SQLMode              string   `yaml:"sql-mode"`                  // 目标数据库的 sql_mode，用于判断 ONLY_FULL_GROUP_BY 等模式下 SQL 能否正常执行
+	Dialect              string   `yaml:"dialect"`                   // SQL 方言，支持 mysql, tidb, clickhouse，为 tidb, clickhouse 时分别启用 TDB, CKH 类规则
+	Cluster              string   `yaml:"cluster"`                   // 目标数据库的集群类型，支持 group-replication, galera，配置后启用 CLU 类规则
+	ReportDir            string   `yaml:"report-dir"`                // 不为空时每个输入文件的报告分别输出至该目录
+	ReportStorage        string   `yaml:"report-storage"`            // 报告上传的对象存储，格式为 s3://bucket/prefix, gs://bucket/prefix, oss://bucket/prefix
+	StorageEndpoint      string   `yaml:"storage-endpoint"`          // 对象存储的访问地址，用于 MinIO 等 S3 兼容服务，为空时按 scheme 及 region 生成
+	StorageRegion        string   `yaml:"storage-region"`            // 对象存储的区域，如 us-east-1, oss-cn-hangzhou
+	StorageAccessKey     string   `yaml:"storage-access-key"`        // 对象存储的 access key，为空时使用 AWS_ACCESS_KEY_ID 环境变量
+	StorageSecretKey     string   `yaml:"storage-secret-key"`        // 对象存储的 secret key，为空时使用 AWS_SECRET_ACCESS_KEY 环境变量
+	ReportRetention      int      `yaml:"report-retention"`          // 上传报告的保留天数，为对象添加 retention-days 标签（GCS 设置 Custom-Time），配合 bucket 生命周期规则删除，为 0 时不添加
+	ReportURLExpires     int      `yaml:"report-url-expires"`        // 报告预签名 URL 的有效期（小时），最长 168 小时，为 0 时返回不带签名的 URL
+	AggregateDuplicates  bool     `yaml:"aggregate-duplicates"`      // 指纹相同的 SQL 只输出一次建议，并附带出现次数及所在位置
+	QueryStats           string   `yaml:"query-stats"`               // SQL 执行次数及耗时的统计文件，支持慢查询日志及 CSV 格式，用于按影响对报告排序
+	Top                  int      `yaml:"top"`                       // 按影响（执行次数 x Severity）排序后只输出前 N 条 SQL 的建议，为 0 时全部输出
+	IssueMinSeverity     string   `yaml:"issue-min-severity"`        // -report-type jira, issues 只导出不低于该级别的建议，如 L4
+	JiraProject          string   `yaml:"jira-project"`              // -report-type jira 创建 issue 的项目 key，如 DBA
+	JiraIssueType        string   `yaml:"jira-issue-type"`           // -report-type jira 创建 issue 的类型，如 Task, Bug
+	ServeHistory         string   `yaml:"serve-history"`             // -serve 评审页面的评审记录文件，每行一条 JSON，为空时只在内存中保留最近 100 条，配置了 history-dsn 时不使用
+	ServeTokens          []string `yaml:"serve-tokens"`              // -serve 评审页面的访问令牌，每项格式为 `名称:令牌`，为空时不鉴权
+	ServeRateLimit       int      `yaml:"serve-rate-limit"`          // -serve 评审页面每个客户端每分钟最多提交的评审次数，为 0 时不限制
+	HistoryDSN           string   `yaml:"history-dsn"`               // 保存评审记录及 -serve 评审页面历史的 MySQL，格式与 online-dsn 相同，为空时不保存
+	OwnerFile            string   `yaml:"owner-file"`                // 库表归属的团队，每行格式为 `匹配 db.table 的正则表达式 团队`，报告按团队分组
+
+	// 按规则单独设置阈值，如 ARG.005: 20，未设置的规则使用 max-in-count 等全局配置
+	RuleThresholds map[string]int `yaml:"rule-thresholds"`
+
+	// L0-L8 对应的级别名称，支持 info, warning, error, blocker，如 L8: blocker，lint, codequality, rdjson, checkstyle 等输出统一使用
+	SeverityLabels map[string]string `yaml:"severity-labels"`
+
+	// 关键库表，匹配 db.table 的正则表达式，如 ^payments\.，涉及关键库表的建议会提升级别，使用 -top 时始终输出
+	CriticalObjects []string `yaml:"critical-objects"`
+
+	// 涉及关键库表时规则提升后的级别，如 CLA.015: L8，未设置的规则提升一级
+	CriticalSeverity map[string]string `yaml:"critical-severity"`
+
+	// 允许互相 JOIN 的库分组，同组的库使用 | 分隔，如 sakila|sakila_archive，JOI.008 只对跨组的 JOIN 给出建议，为空时所有跨库 JOIN 均给出建议
+	AllowedSchemaGroups []string `yaml:"allowed-schema-groups"`
+
+	// 命名的环境配置，-profile 选择后覆盖顶层的同名配置项，如 prod-audit: {online-dsn: {...}, ignore-rules: [...]}
+	Profiles map[string]interface{} `yaml:"profiles,omitempty"`
+
+	// 指纹计算相关配置，指纹用于 SQL 去重及生成 Query ID
+	FingerprintFunc          string `yaml:"fingerprint-func"`           // 基础指纹算法，支持 percona, tidb
+	FingerprintCollapseIn    bool   `yaml:"fingerprint-collapse-in"`    // 将占位符组成的 IN 列表合并为 in(?+)
+	FingerprintStripComments bool   `yaml:"fingerprint-strip-comments"` // 计算指纹前去除 SQL 中的注释
+	FingerprintShardPattern  string `yaml:"fingerprint-shard-pattern"`  // 表名中匹配该正则的部分替换为 *，如 \d+(_\d+)*$ 将 orders_2024_01 归一化为 orders_*
 
 	// ++++++++++++++EXPLAIN检查项+++++++++++++
 	ExplainSQLReportType   string   `yaml:"explain-sql-report-type"`  // EXPLAIN markdown 格式输出 SQL 样式，支持 sample, fingerprint, pretty 等
-	ExplainType            string   `yaml:"explain-type"`             // EXPLAIN方式 [traditional, extended, partitions]
+	ExplainType            string   `yaml:"explain-type"`             // EXPLAIN方式 [traditional, extended, partitions, analyze]
 	ExplainFormat          string   `yaml:"explain-format"`           // FORMAT=[json, traditional]
 	ExplainWarnSelectType  []string `yaml:"explain-warn-select-type"` // 哪些 select_type 不建议使用
 	ExplainWarnAccessType  []string `yaml:"explain-warn-access-type"` // 哪些 access type 不建议使用
@@ -127,9 +231,12 @@ type Configuration struct {
 	ExplainWarnScalability []string `yaml:"explain-warn-scalability"` // 复杂度警告名单
 	ShowWarnings           bool     `yaml:"show-warnings"`            // explain extended with show warnings
 	ShowLastQueryCost      bool     `yaml:"show-last-query-cost"`     // switch with show status like 'last_query_cost'
+	CheckSysVariables      bool     `yaml:"check-sys-variables"`      // 结合线上环境的服务器变量检查与评审 SQL 相关的配置问题，给出 SYS 类建议
 	// ++++++++++++++其他配置项+++++++++++++++
 	Query              string `yaml:"query"`                 // 需要进行调优的SQL
 	ListHeuristicRules bool   `yaml:"list-heuristic-rules"`  // 打印支持的评审规则列表
+	ShowRule           string `yaml:"show-rule"`             // 打印指定评审规则的完整文档
+	RuleTest           string `yaml:"rule-test"`             // 规则单元测试用例文件，检查各 SQL 给出的建议是否符合预期
 	ListRewriteRules   bool   `yaml:"list-rewrite-rules"`    // 打印重写规则
 	ListTestSqls       bool   `yaml:"list-test-sqls"`        // 打印测试case用于测试
 	ListReportTypes    bool   `yaml:"list-report-types"`     // 打印支持的报告输出类型
@@ -143,16 +250,22 @@ var Config = &Configuration{
 	OnlineDSN:               newDSN(nil),
 	TestDSN:                 newDSN(nil),
 	AllowOnlineAsTest:       false,
+	OnlineAllowList:         []string{"select", "show", "explain", "describe", "desc"},
+	IKnowWhatIAmDoing:       false,
 	DropTestTemporary:       true,
 	CleanupTestDatabase:     false,
 	DryRun:                  true,
 	OnlySyntaxCheck:         false,
 	SamplingStatisticTarget: 100,
 	Sampling:                false,
+	StatisticsTransfer:      false,
 	Profiling:               false,
 	Trace:                   false,
 	Explain:                 true,
 	Delimiter:               ";",
+	QueryRetry:              0,
+	QueryRetryBackoff:       100,
+	QueryTimeout:            0,
 	MinCardinality:          0,
 
 	MaxJoinTableCount:    5,
@@ -164,6 +277,10 @@ var Config = &Configuration{
 	MaxIdxBytes:          3072,
 	MaxTotalRows:         9999999,
 	MaxQueryCost:         9999,
+	MaxAffectedRows:      10000,
+	MaxResultRows:        100000,
+	MaxResultSize:        100,
+	MaxScanPoolPercent:   10,
 	SpaghettiQueryLength: 2048,
 	AllowDropIndex:       false,
 	LogLevel:             3,
@@ -172,6 +289,9 @@ var Config = &Configuration{
 	ReportCSS:            "",
 	ReportJavascript:     "",
 	ReportTitle:          "SQL优化分析报告",
+	JoinGraph:            "mermaid",
+	MermaidJS:            "",
+	Lang:                 "en",
 	BlackList:            "",
 	AllowCharsets:        []string{"utf8", "utf8mb4"},
 	AllowCollates:        []string{},
@@ -185,6 +305,25 @@ var Config = &Configuration{
 	MaxSubqueryDepth:     5,
 	MaxVarcharLength:     1024,
 	ColumnNotAllowType:   []string{"boolean"},
+	MaxAutoIncRatio:      0.8,
+	MaxLockTxnStatements: 5,
+	MaxRecursionDepth:    1000,
+	MDLHotTableOps:       1000,
+	MDLLongQueryTime:     60,
+	ArchiveMinRows:       10000000,
+	ArchiveMinSize:       10240,
+	ArchiveKeepDays:      180,
+	ArchiveChunkSize:     1000,
+	CompressMinSize:      1024,
+	CompressWriteRatio:   0.2,
+	CapacityHorizon:      90,
+	CapacityMaxSize:      102400,
+	FingerprintFunc:      "percona",
+	Dialect:              "mysql",
+	ReportURLExpires:     24,
+	IssueMinSeverity:     "L4",
+	JiraIssueType:        "Task",
+	SQLMode:              "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION",
 
 	MarkdownExtensions: 94,
 	MarkdownHTMLFlags:  0,
@@ -202,10 +341,15 @@ var Config = &Configuration{
 	ExplainWarnScalability: []string{"O(n)"},
 	ShowWarnings:           false,
 	ShowLastQueryCost:      false,
+	CheckSysVariables:      false,
 
 	IgnoreRules: []string{
 		"COL.011",
 	},
+	CriticalSeverity: map[string]string{
+		"CLA.014": "L8",
+		"CLA.015": "L8",
+	},
 	RewriteRules: []string{
 		"delimiter",
 		"orderbynull",
@@ -245,6 +389,23 @@ type Dsn struct {
 	AllowNativePasswords bool `yaml:"allow-native-passwords"` // Allows the native password authentication method
 	AllowOldPasswords    bool `yaml:"allow-old-passwords"`    // Allows the old insecure password method
 
+	SSHHost string `yaml:"ssh-host"` // SSH 跳板机地址 host[:port]，配置后调用系统的 ssh -W 通过跳板机连接数据库
+	SSHUser string `yaml:"ssh-user"` // SSH 跳板机用户名
+	SSHKey  string `yaml:"ssh-key"`  // SSH 私钥文件
+
+	TLSCA         string `yaml:"tls-ca"`          // TLS CA 证书文件
+	TLSCert       string `yaml:"tls-cert"`        // TLS 客户端证书文件
+	TLSKey        string `yaml:"tls-key"`         // TLS 客户端私钥文件
+	TLSSkipVerify bool   `yaml:"tls-skip-verify"` // TLS 不校验服务端证书
+	TLSServerName string `yaml:"tls-server-name"` // TLS 校验服务端证书时使用的主机名
+
+	PasswordCommand string `yaml:"password-command"` // 执行命令获取密码，标准输出作为密码，配置后忽略 password
+	VaultPath       string `yaml:"vault-path"`       // HashiCorp Vault 中保存密码的路径，如 secret/data/mysql，地址及 Token 取自 VAULT_ADDR, VAULT_TOKEN 环境变量
+	VaultField      string `yaml:"vault-field"`      // Vault 中保存密码的字段名，默认为 password
+	SecretTTL       string `yaml:"secret-ttl"`       // 获取的密码缓存时长，默认为 5m，过期或认证失败后重新获取
+
+	Replicas []string `yaml:"replicas"` // 只读从库地址，与主库使用相同的账号及连接参数，EXPLAIN、SHOW、数据采样等只读操作优先在从库执行
+
 	Disable bool `yaml:"disable"`
 	Version int  `yaml:"-"` // 版本自动检查，不可配置
 }
@@ -273,7 +434,43 @@ func newDSN(cfg *mysql.Config) *Dsn {
 	dsn.Schema = cfg.DBName
 	dsn.Params = make(map[string]string)
 	for k, v := range cfg.Params {
-		dsn.Params[k] = v
+		switch k {
+		case "ssh-host":
+			dsn.SSHHost = v
+		case "ssh-user":
+			dsn.SSHUser = v
+		case "ssh-key":
+			dsn.SSHKey = v
+		case "tls-ca":
+			dsn.TLSCA = v
+		case "tls-cert":
+			dsn.TLSCert = v
+		case "tls-key":
+			dsn.TLSKey = v
+		case "tls-skip-verify":
+			dsn.TLSSkipVerify, _ = strconv.ParseBool(v)
+		case "tls-server-name":
+			dsn.TLSServerName = v
+		case "replicas":
+			dsn.Replicas = strings.Split(v, ",")
+		case "password-command":
+			dsn.PasswordCommand = v
+		case "vault-path":
+			dsn.VaultPath = v
+		case "vault-field":
+			dsn.VaultField = v
+		case "secret-ttl":
+			dsn.SecretTTL = v
+		default:
+			dsn.Params[k] = v
+		}
+	}
+	// FormatDSN 生成的 DSN 中 net 为已注册的跳板机名称
+	if tunnel, ok := lookupSSHTunnel(cfg.Net); ok {
+		dsn.Net = "tcp"
+		dsn.SSHHost = tunnel.Host
+		dsn.SSHUser = tunnel.User
+		dsn.SSHKey = tunnel.Key
 	}
 	if _, ok := cfg.Params["charset"]; ok {
 		dsn.Charset = cfg.Params["charset"]
@@ -283,6 +480,15 @@ func newDSN(cfg *mysql.Config) *Dsn {
 	dsn.MaxAllowedPacket = cfg.MaxAllowedPacket
 	dsn.ServerPubKey = cfg.ServerPubKey
 	dsn.TLS = cfg.TLSConfig
+	// FormatDSN 生成的 DSN 中 tls 为已注册的证书配置名称
+	if opt, ok := lookupTLSConfig(cfg.TLSConfig); ok {
+		dsn.TLS = ""
+		dsn.TLSCA = opt.CA
+		dsn.TLSCert = opt.Cert
+		dsn.TLSKey = opt.Key
+		dsn.TLSSkipVerify = opt.SkipVerify
+		dsn.TLSServerName = opt.ServerName
+	}
 	dsn.Timeout = cfg.Timeout.String()
 	dsn.ReadTimeout = cfg.ReadTimeout.String()
 	dsn.WriteTimeout = cfg.WriteTimeout.String()
@@ -299,6 +505,9 @@ func (env *Dsn) newMySQLConfig() (*mysql.Config, error) {
 	dsn.User = env.User
 	dsn.Passwd = env.Password
 	dsn.Net = env.Net
+	if env.SSHHost != "" {
+		dsn.Net = registerSSHTunnel(env)
+	}
 	dsn.Addr = env.Addr
 	dsn.DBName = env.Schema
 	dsn.Params = make(map[string]string)
@@ -306,6 +515,17 @@ func (env *Dsn) newMySQLConfig() (*mysql.Config, error) {
 		dsn.Params[k] = v
 	}
 	dsn.Params["charset"] = env.Charset
+	// 外部密码配置作为参数保留在 DSN 中，建立连接时由 ConnectDSN 去除
+	for k, v := range map[string]string{
+		"password-command": env.PasswordCommand,
+		"vault-path":       env.VaultPath,
+		"vault-field":      env.VaultField,
+		"secret-ttl":       env.SecretTTL,
+	} {
+		if v != "" {
+			dsn.Params[k] = v
+		}
+	}
 	dsn.Collation = env.Collation
 	dsn.Loc, err = time.LoadLocation(env.Loc)
 	if err != nil {
@@ -313,7 +533,10 @@ func (env *Dsn) newMySQLConfig() (*mysql.Config, error) {
 	}
 	dsn.MaxAllowedPacket = env.MaxAllowedPacket
 	dsn.ServerPubKey = env.ServerPubKey
-	dsn.TLSConfig = env.TLS
+	dsn.TLSConfig, err = registerTLSConfig(env)
+	if err != nil {
+		return nil, err
+	}
 	if env.Timeout != "" {
 		dsn.Timeout, err = time.ParseDuration(env.Timeout)
 		LogIfError(err, "timeout: '%s'", env.Timeout)
@@ -335,6 +558,7 @@ func (env *Dsn) newMySQLConfig() (*mysql.Config, error) {
 func parseDSN(odbc string, d *Dsn) *Dsn {
 	dsn := newDSN(nil)
 	var addr, user, password, schema, charset, timeout string
+	var sshHost, sshUser, sshKey string
 	if odbc == FormatDSN(d) {
 		return d
 	}
@@ -347,6 +571,10 @@ func parseDSN(odbc string, d *Dsn) *Dsn {
 		schema = d.Schema
 		charset = d.Charset
 		timeout = d.Timeout
+		sshHost = d.SSHHost
+		sshUser = d.SSHUser
+		sshKey = d.SSHKey
+		dsn.Replicas = d.Replicas
 	}
 
 	// 设置为空表示禁用环境
@@ -411,6 +639,14 @@ func parseDSN(odbc string, d *Dsn) *Dsn {
 					charset = val
 				case "timeout":
 					timeout = val
+				case "ssh-host":
+					sshHost = val
+				case "ssh-user":
+					sshUser = val
+				case "ssh-key":
+					sshKey = val
+				case "replicas":
+					dsn.Replicas = strings.Split(val, ",")
 				default:
 				}
 			}
@@ -438,17 +674,37 @@ func parseDSN(odbc string, d *Dsn) *Dsn {
 	dsn.Schema = schema
 	dsn.Charset = charset
 	dsn.Timeout = timeout
+	dsn.SSHHost = sshHost
+	dsn.SSHUser = sshUser
+	dsn.SSHKey = sshKey
 	return dsn
 }
 
 // ParseDSN compatible with old version soar < 0.11.0
 func ParseDSN(odbc string, d *Dsn) *Dsn {
-	cfg, err := mysql.ParseDSN(odbc)
+	cfg, err := mysql.ParseDSN(escapeDSNParams(odbc))
 	if err != nil {
 		// Log.Debug("go-sql-driver/mysql.ParseDSN Error: %s, DSN: %s, try to use old version parseDSN", err.Error(), odbc)
 		return parseDSN(odbc, d)
 	}
-	return newDSN(cfg)
+	dsn := newDSN(cfg)
+	// 命令行参数未修改时保留配置文件中的从库配置
+	if d != nil && len(dsn.Replicas) == 0 && odbc == FormatDSN(d) {
+		dsn.Replicas = d.Replicas
+	}
+	return dsn
+}
+
+// escapeDSNParams go-sql-driver 以最后一个 '/' 作为库名分隔符，参数中的 '/' 需要转义
+// 如: ssh-key=/path/to/id_rsa, loc=Asia/Shanghai
+func escapeDSNParams(odbc string) string {
+	at := strings.LastIndex(odbc, "@")
+	i := strings.Index(odbc[at+1:], "?")
+	if i < 0 {
+		return odbc
+	}
+	i += at + 1
+	return odbc[:i] + strings.Replace(odbc[i:], "/", "%2F", -1)
 }
 
 // FormatDSN 格式化打印DSN
@@ -458,11 +714,119 @@ func FormatDSN(env *Dsn) string {
 	}
 	dsn, err := env.newMySQLConfig()
 	if err != nil {
+		LogIfError(err, "FormatDSN %s", env.Addr)
 		return ""
 	}
 	return dsn.FormatDSN()
 }
 
+// formatRuleThresholds 将 rule-thresholds 转换为命令行参数格式，如 ARG.005=20,JOI.005=3
+func formatRuleThresholds(thresholds map[string]int) string {
+	var buf []string
+	for _, item := range SortedKey(thresholds) {
+		buf = append(buf, fmt.Sprintf("%s=%d", item, thresholds[item]))
+	}
+	return strings.Join(buf, ",")
+}
+
+// parseRuleThresholds 解析命令行参数 -rule-thresholds，格式错误的配置项会被忽略
+func parseRuleThresholds(str string) map[string]int {
+	thresholds := make(map[string]int)
+	for _, kv := range strings.Split(str, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			Log.Warning("rule-thresholds format error: '%s', e.g. ARG.005=20", kv)
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if err != nil {
+			Log.Warning("rule-thresholds format error: '%s', %v", kv, err)
+			continue
+		}
+		thresholds[strings.TrimSpace(pair[0])] = v
+	}
+	return thresholds
+}
+
+// SeverityLabelNames severity-labels 支持的级别名称
+var SeverityLabelNames = []string{"info", "warning", "error", "blocker"}
+
+// formatSeverityLabels 将 severity-labels 转换为命令行参数格式，如 L0=info,L8=blocker
+func formatSeverityLabels(labels map[string]string) string {
+	var buf []string
+	for _, level := range SortedKey(labels) {
+		buf = append(buf, fmt.Sprintf("%s=%s", level, labels[level]))
+	}
+	return strings.Join(buf, ",")
+}
+
+// parseSeverityLabels 解析命令行参数 -severity-labels，格式错误的配置项会被忽略
+func parseSeverityLabels(str string) map[string]string {
+	labels := make(map[string]string)
+	for _, kv := range strings.Split(str, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			Log.Warning("severity-labels format error: '%s', e.g. L8=blocker", kv)
+			continue
+		}
+		level := strings.ToUpper(strings.TrimSpace(pair[0]))
+		label := strings.ToLower(strings.TrimSpace(pair[1]))
+		if len(level) != 2 || level[0] != 'L' || level[1] < '0' || level[1] > '8' {
+			Log.Warning("severity-labels format error: '%s', level should be L0-L8", kv)
+			continue
+		}
+		valid := false
+		for _, name := range SeverityLabelNames {
+			if label == name {
+				valid = true
+			}
+		}
+		if !valid {
+			Log.Warning("severity-labels format error: '%s', label should be one of %s", kv, strings.Join(SeverityLabelNames, ", "))
+			continue
+		}
+		labels[level] = label
+	}
+	return labels
+}
+
+// formatCriticalSeverity 将 critical-severity 转换为命令行参数格式，如 CLA.014=L8,CLA.015=L8
+func formatCriticalSeverity(levels map[string]string) string {
+	var buf []string
+	for _, item := range SortedKey(levels) {
+		buf = append(buf, fmt.Sprintf("%s=%s", item, levels[item]))
+	}
+	return strings.Join(buf, ",")
+}
+
+// parseCriticalSeverity 解析命令行参数 -critical-severity，格式错误的配置项会被忽略
+func parseCriticalSeverity(str string) map[string]string {
+	levels := make(map[string]string)
+	for _, kv := range strings.Split(str, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			Log.Warning("critical-severity format error: '%s', e.g. CLA.015=L8", kv)
+			continue
+		}
+		level := strings.ToUpper(strings.TrimSpace(pair[1]))
+		if len(level) != 2 || level[0] != 'L' || level[1] < '0' || level[1] > '8' {
+			Log.Warning("critical-severity format error: '%s', level should be L0-L8", kv)
+			continue
+		}
+		levels[strings.TrimSpace(pair[0])] = level
+	}
+	return levels
+}
+
 // SoarVersion soar version information
 func SoarVersion() {
 	fmt.Println("Version:", Version)
@@ -539,15 +903,40 @@ func usage() {
 	}
 }
 
+// maskDSNPassword 隐藏字符串格式 DSN 中的密码，如 user:pwd@127.0.0.1:3306/soar
+func maskDSNPassword(dsn string) string {
+	return regexp.MustCompile(`:[^:@/]*@`).ReplaceAllString(dsn, ":********@")
+}
+
 // PrintConfiguration for `-print-config` flag
 func PrintConfiguration() {
+	fmt.Print(printableConfiguration())
+}
+
+// printableConfiguration 返回用于打印的 yaml 格式配置，-print-config 及 -print-config-resolved 共用
+func printableConfiguration() string {
 	// 打印配置的时候密码不显示
 	if !Config.Verbose {
 		Config.OnlineDSN.Password = "********"
 		Config.TestDSN.Password = "********"
+		if Config.StorageSecretKey != "" {
+			Config.StorageSecretKey = "********"
+		}
+		Config.HistoryDSN = maskDSNPassword(Config.HistoryDSN)
+		tokens := make([]string, 0, len(Config.ServeTokens))
+		for _, token := range Config.ServeTokens {
+			name := ""
+			if i := strings.Index(token, ":"); i >= 0 {
+				name = token[:i+1]
+			}
+			tokens = append(tokens, name+"********")
+		}
+		Config.ServeTokens = tokens
 	}
+	// 选择的 profile 已合并至顶层配置
+	Config.Profiles = nil
 	data, _ := yaml.Marshal(Config)
-	fmt.Print(string(data))
+	return string(data)
 }
 
 // 加载配置文件
@@ -570,9 +959,66 @@ func (conf *Configuration) readConfigFile(path string) error {
 		Log.Warning("readConfigFile(%s) yaml.Unmarshal failed: %v", path, err)
 		return err
 	}
+	recordConfigFile(path, content)
+	return conf.applyProfile(path)
+}
+
+// applyProfile 使用 -profile 选择的环境配置覆盖顶层配置，未在 profile 中出现的配置项保持不变
+func (conf *Configuration) applyProfile(path string) error {
+	if Profile == "" {
+		return nil
+	}
+	profile, ok := Config.Profiles[Profile]
+	if !ok {
+		return fmt.Errorf("profile '%s' not found in %s, available: %s", Profile, path, strings.Join(SortedKey(Config.Profiles), ", "))
+	}
+	content, err := yaml.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	err = yaml.Unmarshal(content, Config)
+	if err != nil {
+		Log.Warning("readConfigFile(%s) profile %s yaml.Unmarshal failed: %v", path, Profile, err)
+		return err
+	}
+	recordConfigFile(fmt.Sprintf("%s (profile: %s)", path, Profile), content)
 	return nil
 }
 
+// ValidateConfigFile 严格检查配置文件，返回未知的配置项及类型错误
+func ValidateConfigFile(path string) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf Configuration
+	var errs []string
+	err = yaml.UnmarshalStrict(content, &conf)
+	if e, ok := err.(*yaml.TypeError); ok {
+		errs = e.Errors
+	} else if err != nil {
+		return nil, err
+	}
+
+	// profile 中的配置项同样严格检查，profile 重新序列化后行号没有意义，错误信息中去除行号
+	for _, name := range SortedKey(conf.Profiles) {
+		data, err := yaml.Marshal(conf.Profiles[name])
+		if err != nil {
+			return nil, err
+		}
+		var pc Configuration
+		if e, ok := yaml.UnmarshalStrict(data, &pc).(*yaml.TypeError); ok {
+			for _, msg := range e.Errors {
+				errs = append(errs, fmt.Sprintf("profiles.%s: %s", name, configLineRe.ReplaceAllString(msg, "")))
+			}
+		}
+	}
+	return errs, nil
+}
+
+// configLineRe yaml 错误信息中的行号
+var configLineRe = regexp.MustCompile(`^line \d+: `)
+
 // 从命令行参数读配置
 func readCmdFlags() error {
 	if hasParsed {
@@ -581,10 +1027,13 @@ func readCmdFlags() error {
 	}
 
 	_ = flag.String("config", "", "Config file path")
+	_ = flag.String("profile", Profile, "Profile, 使用配置文件 profiles 中指定名称的环境配置覆盖顶层配置，与 -config 一样在加载配置文件时生效")
 	// +++++++++++++++测试环境+++++++++++++++++
 	onlineDSN := flag.String("online-dsn", FormatDSN(Config.OnlineDSN), "OnlineDSN, 线上环境数据库配置, username:password@tcp(ip:port)/schema")
 	testDSN := flag.String("test-dsn", FormatDSN(Config.TestDSN), "TestDSN, 测试环境数据库配置, username:password@tcp(ip:port)/schema")
 	allowOnlineAsTest := flag.Bool("allow-online-as-test", Config.AllowOnlineAsTest, "AllowOnlineAsTest, 允许线上环境也可以当作测试环境")
+	onlineAllowList := flag.String("online-allow-list", strings.Join(Config.OnlineAllowList, ","), "OnlineAllowList, Online 环境只允许执行以这些关键字开头的 SQL")
+	iKnowWhatIAmDoing := flag.Bool("i-know-what-i-am-doing", Config.IKnowWhatIAmDoing, "IKnowWhatIAmDoing, 关闭 Online 环境的只读保护，允许执行任意 SQL")
 	dropTestTemporary := flag.Bool("drop-test-temporary", Config.DropTestTemporary, "DropTestTemporary, 是否清理测试环境产生的临时库表")
 	cleanupTestDatabase := flag.Bool("cleanup-test-database", Config.CleanupTestDatabase, "单次运行清理历史1小时前残余的测试库。")
 	onlySyntaxCheck := flag.Bool("only-syntax-check", Config.OnlySyntaxCheck, "OnlySyntaxCheck, 只做语法检查不输出优化建议")
@@ -594,7 +1043,11 @@ func readCmdFlags() error {
 	sampling := flag.Bool("sampling", Config.Sampling, "Sampling, 数据采样开关")
 	samplingStatisticTarget := flag.Int("sampling-statistic-target", Config.SamplingStatisticTarget, "SamplingStatisticTarget, 数据采样因子，对应 PostgreSQL 的 default_statistics_target")
 	samplingCondition := flag.String("sampling-condition", Config.SamplingCondition, "SamplingCondition, 数据采样条件，如： WHERE xxx LIMIT xxx")
+	statisticsTransfer := flag.Bool("statistics-transfer", Config.StatisticsTransfer, "StatisticsTransfer, 只复制线上表的统计信息到测试环境，不泵取数据，优先级高于 -sampling")
 	delimiter := flag.String("delimiter", Config.Delimiter, "Delimiter, SQL分隔符")
+	queryRetry := flag.Int("query-retry", Config.QueryRetry, "QueryRetry, 只读 SQL 遇到连接中断、死锁等临时错误时的重试次数，其余 SQL 不重试")
+	queryRetryBackoff := flag.Int("query-retry-backoff", Config.QueryRetryBackoff, "QueryRetryBackoff, 首次重试前的等待时间（毫秒），之后每次翻倍")
+	queryTimeout := flag.Int("query-timeout", Config.QueryTimeout, "QueryTimeout, 单条 SQL 的超时时间（秒），超时后在服务端 KILL QUERY，0 表示不限制")
 	minCardinality := flag.Float64("min-cardinality", Config.MinCardinality, "MinCardinality，索引列散粒度最低阈值，散粒度低于该值的列不添加索引，建议范围0.0 ~ 100.0")
 	// +++++++++++++++日志相关+++++++++++++++++
 	logLevel := flag.Int("log-level", Config.LogLevel, "LogLevel, 日志级别, [0:Emergency, 1:Alert, 2:Critical, 3:Error, 4:Warning, 5:Notice, 6:Informational, 7:Debug]")
@@ -603,11 +1056,21 @@ func readCmdFlags() error {
 	reportCSS := flag.String("report-css", Config.ReportCSS, "ReportCSS, 当 ReportType 为 html 格式时使用的 css 风格，如不指定会提供一个默认风格。CSS可以是本地文件，也可以是一个URL")
 	reportJavascript := flag.String("report-javascript", Config.ReportJavascript, "ReportJavascript, 当 ReportType 为 html 格式时使用的javascript脚本，如不指定默认会加载SQL pretty 使用的 javascript。像CSS一样可以是本地文件，也可以是一个URL")
 	reportTitle := flag.String("report-title", Config.ReportTitle, "ReportTitle, 当 ReportType 为 html 格式时，HTML 的 title")
+	joinGraph := flag.String("join-graph", Config.JoinGraph, "JoinGraph, markdown, html 报告中为复杂的多表 JOIN 绘制关联图 [mermaid, dot]，为空时不输出")
+	mermaidJS := flag.String("mermaid-js", Config.MermaidJS, "MermaidJS, html 报告中渲染 mermaid 关系图使用的 mermaid.min.js 地址，为空时直接显示 mermaid 源码")
 	// +++++++++++++++markdown+++++++++++++++++
 	markdownExtensions := flag.Int("markdown-extensions", Config.MarkdownExtensions, "MarkdownExtensions, markdown 转 html支持的扩展包, 参考blackfriday")
 	markdownHTMLFlags := flag.Int("markdown-html-flags", Config.MarkdownHTMLFlags, "MarkdownHTMLFlags, markdown 转 html 支持的 flag, 参考blackfriday")
 	// ++++++++++++++优化建议相关++++++++++++++
 	ignoreRules := flag.String("ignore-rules", strings.Join(Config.IgnoreRules, ","), "IgnoreRules, 忽略的优化建议规则")
+	ruleThresholds := flag.String("rule-thresholds", formatRuleThresholds(Config.RuleThresholds), "RuleThresholds, 按规则单独设置阈值，如 ARG.005=20,JOI.005=3，未设置的规则使用 max-in-count 等全局配置")
+	severityLabels := flag.String("severity-labels", formatSeverityLabels(Config.SeverityLabels), "SeverityLabels, L0-L8 对应的级别名称 [info, warning, error, blocker]，如 L0=info,L8=blocker，lint, codequality, rdjson, checkstyle 输出统一使用")
+	criticalObjects := flag.String("critical-objects", strings.Join(Config.CriticalObjects, ","), "CriticalObjects, 关键库表，匹配 db.table 的正则表达式，多个使用逗号分隔，涉及关键库表的建议会提升级别")
+	allowedSchemaGroups := flag.String("allowed-schema-groups", strings.Join(Config.AllowedSchemaGroups, ","), "AllowedSchemaGroups, 允许互相 JOIN 的库分组，同组的库使用 | 分隔，多组使用逗号分隔，如 sakila|sakila_archive,crm|crm_log")
+	criticalSeverity := flag.String("critical-severity", formatCriticalSeverity(Config.CriticalSeverity), "CriticalSeverity, 涉及关键库表时规则提升后的级别，如 CLA.014=L8,CLA.015=L8，未设置的规则提升一级")
+	lang := flag.String("lang", Config.Lang, "Lang, 评审规则文本的语言，支持 en, zh-CN")
+	langFile := flag.String("lang-file", Config.LangFile, "LangFile, 自定义评审规则文本的 YAML 文件，按规则 Item 覆盖 summary, content")
+	rulePrecedence := flag.String("rule-precedence", strings.Join(Config.RulePrecedence, ","), "RulePrecedence, 建议间的优先级，如 IDX.001>ARG.003 表示给出 IDX.001 时不再给出 ARG.003，多条使用逗号分隔")
 	rewriteRules := flag.String("rewrite-rules", strings.Join(Config.RewriteRules, ","), "RewriteRules, 生效的重写规则")
 	blackList := flag.String("blacklist", Config.BlackList, "指定 blacklist 配置文件的位置，文件中的 SQL 不会被评审。一行一条SQL，可以是指纹，也可以是正则")
 	maxJoinTableCount := flag.Int("max-join-table-count", Config.MaxJoinTableCount, "MaxJoinTableCount, 单条 SQL 中 JOIN 表的最大数量")
@@ -617,6 +1080,11 @@ func readCmdFlags() error {
 	maxTextColsCount := flag.Int("max-text-cols-count", Config.MaxTextColsCount, "MaxTextColsCount, 表中含有的 text/blob 列的最大数量")
 	maxTotalRows := flag.Uint64("max-total-rows", Config.MaxTotalRows, "MaxTotalRows, 计算散粒度时，当数据行数大于MaxTotalRows即开启数据库保护模式，不计算散粒度")
 	maxQueryCost := flag.Int64("max-query-cost", Config.MaxQueryCost, "MaxQueryCost, last_query_cost 超过该值时将给予警告")
+	maxAffectedRows := flag.Int64("max-affected-rows", Config.MaxAffectedRows, "MaxAffectedRows, UPDATE, DELETE 预计影响的行数超过该值时给出 EXP.004 警告，为 0 时不检查")
+	affectedRowsCount := flag.Bool("affected-rows-count", Config.AffectedRowsCount, "AffectedRowsCount, 在线上环境执行 SELECT COUNT(*) 统计 UPDATE, DELETE 影响的行数（最多统计 max-affected-rows 行，受 query-timeout 限制），否则使用 EXPLAIN 的预估值")
+	maxResultRows := flag.Int64("max-result-rows", Config.MaxResultRows, "MaxResultRows, 没有 LIMIT 的 SELECT 预计返回的行数超过该值时给出 EXP.005 警告，为 0 时不检查")
+	maxResultSize := flag.Int64("max-result-size", Config.MaxResultSize, "MaxResultSize, 没有 LIMIT 的 SELECT 预计返回的数据量超过该值（MB）时给出 EXP.005 警告，为 0 时不检查")
+	maxScanPoolPercent := flag.Int("max-scan-pool-percent", Config.MaxScanPoolPercent, "MaxScanPoolPercent, 全表扫描的表大小超过 innodb_buffer_pool_size 的该百分比时给出 EXP.006 警告，为 0 时不检查")
 	spaghettiQueryLength := flag.Int("spaghetti-query-length", Config.SpaghettiQueryLength, "SpaghettiQueryLength, SQL最大长度警告，超过该长度会给警告")
 	allowDropIdx := flag.Bool("allow-drop-index", Config.AllowDropIndex, "AllowDropIndex, 允许输出删除重复索引的建议")
 	maxInCount := flag.Int("max-in-count", Config.MaxInCount, "MaxInCount, IN()最大数量")
@@ -628,14 +1096,61 @@ func readCmdFlags() error {
 	maxIdxCount := flag.Int("max-index-count", Config.MaxIdxCount, "MaxIdxCount, 单表最大索引个数")
 	maxColCount := flag.Int("max-column-count", Config.MaxColCount, "MaxColCount, 单表允许的最大列数")
 	maxValueCount := flag.Int("max-value-count", Config.MaxValueCount, "MaxValueCount, INSERT/REPLACE 单次批量写入允许的行数")
+	insertBatchSize := flag.Int("insert-batch-size", Config.InsertBatchSize, "InsertBatchSize, ARG.012 拆分 INSERT/REPLACE 时每条语句写入的行数，为 0 时使用 max-value-count")
 	idxPrefix := flag.String("index-prefix", Config.IdxPrefix, "IdxPrefix")
 	ukPrefix := flag.String("unique-key-prefix", Config.UkPrefix, "UkPrefix")
 	maxSubqueryDepth := flag.Int("max-subquery-depth", Config.MaxSubqueryDepth, "MaxSubqueryDepth")
 	maxVarcharLength := flag.Int("max-varchar-length", Config.MaxVarcharLength, "MaxVarcharLength")
 	columnNotAllowType := flag.String("column-not-allow-type", strings.Join(Config.ColumnNotAllowType, ","), "ColumnNotAllowType")
+	maxAutoIncRatio := flag.Float64("max-auto-inc-ratio", Config.MaxAutoIncRatio, "MaxAutoIncRatio, 自增值占列类型最大值的比例超过该值时给出警告，范围 0~1")
+	maxLockTxnStatements := flag.Int("max-lock-txn-statements", Config.MaxLockTxnStatements, "MaxLockTxnStatements, 事务中加锁读之后到提交前允许执行的语句数")
+	maxRecursionDepth := flag.Int("max-recursion-depth", Config.MaxRecursionDepth, "MaxRecursionDepth, 递归 CTE 允许的最大递归次数，对应 cte_max_recursion_depth")
+	archiveMinRows := flag.Uint64("archive-min-rows", Config.ArchiveMinRows, "ArchiveMinRows, 行数超过该值的表给出归档建议")
+	archiveMinSize := flag.Uint64("archive-min-size", Config.ArchiveMinSize, "ArchiveMinSize, 数据及索引大小超过该值（MB）的表给出归档建议")
+	mdlHotTableOps := flag.Float64("mdl-hot-table-ops", Config.MDLHotTableOps, "MDLHotTableOps, 平均每秒行读写次数超过该值的表视为热点表，对其执行 DDL 时给出元数据锁风险提示")
+	mdlLongQueryTime := flag.Int("mdl-long-query-time", Config.MDLLongQueryTime, "MDLLongQueryTime, 执行时间超过该值（秒）的事务及请求可能阻塞 DDL 获取元数据锁")
+	archiveKeepDays := flag.Int("archive-keep-days", Config.ArchiveKeepDays, "ArchiveKeepDays, 归档后线上表中保留最近多少天的数据")
+	archiveChunkSize := flag.Int("archive-chunk-size", Config.ArchiveChunkSize, "ArchiveChunkSize, 归档时每批次处理的行数")
+	compressMinSize := flag.Uint64("compress-min-size", Config.CompressMinSize, "CompressMinSize, 数据及索引大小超过该值（MB）的表给出压缩建议")
+	capacityHistory := flag.String("capacity-history", Config.CapacityHistory, "CapacityHistory, 表大小历史快照的 CSV 文件，每次连接线上环境运行时追加当前快照")
+	capacityHorizon := flag.Int("capacity-horizon", Config.CapacityHorizon, "CapacityHorizon, 预测未来多少天内的容量")
+	capacityMaxSize := flag.Uint64("capacity-max-size", Config.CapacityMaxSize, "CapacityMaxSize, 单表数据及索引大小（MB）的上限")
+	capacityDiskSize := flag.Uint64("capacity-disk-size", Config.CapacityDiskSize, "CapacityDiskSize, 整个 database 可用的磁盘空间（MB），为 0 时不检查")
+	compressWriteRatio := flag.Float64("compress-write-ratio", Config.CompressWriteRatio, "CompressWriteRatio, 写入次数占读写总次数的比例超过该值的表不建议压缩，压缩表超过该值时建议取消压缩，范围 0~1")
+	expandView := flag.Bool("expand-view", Config.ExpandView, "ExpandView, 将 SELECT 中引用的视图展开为子查询后再给出建议，视图定义来自输入中的 CREATE VIEW 或 OnlineDsn")
+	fingerprintFunc := flag.String("fingerprint-func", Config.FingerprintFunc, "FingerprintFunc, 基础指纹算法 [percona, tidb]")
+	fingerprintCollapseIn := flag.Bool("fingerprint-collapse-in", Config.FingerprintCollapseIn, "FingerprintCollapseIn, 将占位符组成的 IN 列表合并为 in(?+)")
+	fingerprintStripComments := flag.Bool("fingerprint-strip-comments", Config.FingerprintStripComments, "FingerprintStripComments, 计算指纹前去除 SQL 中的注释")
+	fingerprintShardPattern := flag.String("fingerprint-shard-pattern", Config.FingerprintShardPattern, "FingerprintShardPattern, 表名中匹配该正则的部分替换为 *，如 \\d+(_\\d+)*$ 将 orders_2024_01 归一化为 orders_*")
+	shardTables := flag.String("shard-tables", strings.Join(Config.ShardTables, ","), "ShardTables, 分表配置，格式为 [逻辑表名=]分表名模式[:分片键]，如 user=user_%d:uid，多个使用逗号分隔")
+	sqlMode := flag.String("sql-mode", Config.SQLMode, "SQLMode, 目标数据库的 sql_mode，用于判断 ONLY_FULL_GROUP_BY 等模式下 SQL 能否正常执行")
+	target := flag.String("target", Config.Target, "Target, 目标数据库类型及版本 [mysql, mariadb]，格式为 flavor[:version]，如 mariadb:10.6，用于调整依赖版本的建议")
+	dialect := flag.String("dialect", Config.Dialect, "Dialect, SQL 方言 [mysql, tidb, clickhouse]，为 tidb 时启用 TDB 类规则及 TiDB EXPLAIN 解析，为 clickhouse 时使用 ClickHouse 语法解析并启用 CKH 类规则")
+	cluster := flag.String("cluster", Config.Cluster, "Cluster, 目标数据库的集群类型 [group-replication, galera]，配置后启用 CLU 类规则，检查集群中不兼容或危险的语句")
+	reportDir := flag.String("report-dir", Config.ReportDir, "ReportDir, 不为空时每个输入文件的报告分别输出至该目录")
+	reportStorage := flag.String("report-storage", Config.ReportStorage, "ReportStorage, 报告上传的对象存储，格式为 s3://bucket/prefix, gs://bucket/prefix, oss://bucket/prefix")
+	storageEndpoint := flag.String("storage-endpoint", Config.StorageEndpoint, "StorageEndpoint, 对象存储的访问地址，用于 MinIO 等 S3 兼容服务")
+	storageRegion := flag.String("storage-region", Config.StorageRegion, "StorageRegion, 对象存储的区域，如 us-east-1, oss-cn-hangzhou")
+	storageAccessKey := flag.String("storage-access-key", Config.StorageAccessKey, "StorageAccessKey, 对象存储的 access key，为空时使用 AWS_ACCESS_KEY_ID 环境变量")
+	storageSecretKey := flag.String("storage-secret-key", Config.StorageSecretKey, "StorageSecretKey, 对象存储的 secret key，为空时使用 AWS_SECRET_ACCESS_KEY 环境变量")
+	reportRetention := flag.Int("report-retention", Config.ReportRetention, "ReportRetention, 上传报告的保留天数，配合 bucket 生命周期规则删除，为 0 时不限制")
+	reportURLExpires := flag.Int("report-url-expires", Config.ReportURLExpires, "ReportURLExpires, 报告预签名 URL 的有效期（小时），最长 168 小时，为 0 时返回不带签名的 URL")
+	queryStats := flag.String("query-stats", Config.QueryStats, "QueryStats, SQL 执行次数及耗时的统计文件，.csv 后缀按 SQL 或 Query ID,执行次数[,总耗时] 解析，其他按慢查询日志解析，用于按影响对报告排序")
+	top := flag.Int("top", Config.Top, "Top, 按影响（执行次数 x Severity）排序后只输出前 N 条 SQL 的建议，为 0 时全部输出，支持 markdown, html, json 格式")
+	issueMinSeverity := flag.String("issue-min-severity", Config.IssueMinSeverity, "IssueMinSeverity, -report-type jira, issues 只导出不低于该级别的建议，如 L4")
+	jiraProject := flag.String("jira-project", Config.JiraProject, "JiraProject, -report-type jira 创建 issue 的项目 key，如 DBA")
+	jiraIssueType := flag.String("jira-issue-type", Config.JiraIssueType, "JiraIssueType, -report-type jira 创建 issue 的类型，如 Task, Bug")
+	serveHistory := flag.String("serve-history", Config.ServeHistory, "ServeHistory, -serve 评审页面的评审记录文件，每行一条 JSON，为空时只在内存中保留最近 100 条")
+	serveTokens := flag.String("serve-tokens", strings.Join(Config.ServeTokens, ","), "ServeTokens, -serve 评审页面的访问令牌，每项格式为 `名称:令牌`，多个使用逗号分隔，为空时不鉴权")
+	serveRateLimit := flag.Int("serve-rate-limit", Config.ServeRateLimit, "ServeRateLimit, -serve 评审页面每个客户端每分钟最多提交的评审次数，为 0 时不限制")
+	historyDSN := flag.String("history-dsn", Config.HistoryDSN, "HistoryDSN, 保存评审记录的 MySQL，如 user:pwd@127.0.0.1:3306/soar，为空时不保存")
+	ownerFile := flag.String("owner-file", Config.OwnerFile, "OwnerFile, 库表归属的团队，每行格式为 `匹配 db.table 的正则表达式 团队`，报告按团队分组")
+	aggregateDuplicates := flag.Bool("aggregate-duplicates", Config.AggregateDuplicates, "AggregateDuplicates, 指纹相同的 SQL 只输出一次建议，并附带出现次数及所在位置，支持 markdown, html, json 格式")
+	diffBase := flag.String("diff-base", Config.DiffBase, "DiffBase, schema-diff 的基准 Schema，mysqldump 导出文件或 DSN，默认为 OnlineDsn")
+	schemaFile := flag.String("schema-file", Config.SchemaFile, "SchemaFile, 离线表结构，mysqldump --no-data 导出的文件，用于不连接数据库时检查隐式类型转换（ARG.003）")
 	// ++++++++++++++EXPLAIN检查项+++++++++++++
 	explainSQLReportType := flag.String("explain-sql-report-type", strings.ToLower(Config.ExplainSQLReportType), "ExplainSQLReportType [pretty, sample, fingerprint]")
-	explainType := flag.String("explain-type", strings.ToLower(Config.ExplainType), "ExplainType [extended, partitions, traditional]")
+	explainType := flag.String("explain-type", strings.ToLower(Config.ExplainType), "ExplainType [extended, partitions, traditional, analyze]，analyze 仅在 -dialect=tidb 时对 SELECT 生效")
 	explainFormat := flag.String("explain-format", strings.ToLower(Config.ExplainFormat), "ExplainFormat [json, traditional]")
 	explainWarnSelectType := flag.String("explain-warn-select-type", strings.Join(Config.ExplainWarnSelectType, ","), "ExplainWarnSelectType, 哪些select_type不建议使用")
 	explainWarnAccessType := flag.String("explain-warn-access-type", strings.Join(Config.ExplainWarnAccessType, ","), "ExplainWarnAccessType, 哪些access type不建议使用")
@@ -646,13 +1161,23 @@ func readCmdFlags() error {
 	explainMaxFiltered := flag.Float64("explain-max-filtered", Config.ExplainMaxFiltered, "ExplainMaxFiltered, filtered大于该配置给出警告")
 	explainWarnScalability := flag.String("explain-warn-scalability", strings.Join(Config.ExplainWarnScalability, ","), "ExplainWarnScalability, 复杂度警告名单, 支持O(n),O(log n),O(1),O(?)")
 	showWarnings := flag.Bool("show-warnings", Config.ShowWarnings, "ShowWarnings")
-	showLastQueryCost := flag.Bool("show-last-query-cost", Config.ShowLastQueryCost, "ShowLastQueryCost")
+	showLastQueryCost := flag.Bool("show-last-query-cost", Config.ShowLastQueryCost, "ShowLastQueryCost, 输出查询代价 (EXP.003)，并比较添加建议的索引及 SQL 改写前后的代价")
+	checkSysVariables := flag.Bool("check-sys-variables", Config.CheckSysVariables, "CheckSysVariables, 结合线上环境的 innodb_buffer_pool_size, sync_binlog, slow_query_log, max_connections 等变量给出 SYS 类建议")
 	// +++++++++++++++++其他+++++++++++++++++++
 	printConfig := flag.Bool("print-config", false, "Print configs")
+	printConfigResolved := flag.Bool("print-config-resolved", false, "PrintConfigResolved, 打印生效的配置及每个配置项的来源 [default, config, env, flag]")
 	checkConfig := flag.Bool("check-config", false, "Check configs")
+	doctor := flag.Bool("doctor", false, "Doctor, 检查配置文件、online-dsn 及 test-dsn 的连接、权限及版本，给出修复建议")
+	serve := flag.String("serve", "", "Serve, 启动评审页面的监听地址，如 127.0.0.1:5077，配置 serve-tokens 前页面没有鉴权")
+	showHistory := flag.String("show-history", "", "ShowHistory, 查看 history-dsn 中保存的评审记录，值为 Query ID 或 all")
+	completion := flag.String("completion", "", "Completion, 输出 shell 自动补全脚本 [bash, zsh, fish]")
+	fix := flag.Bool("fix", false, "Fix, 将可以安全自动修复的建议 (ALI.001, STA.001, LIT.002, COL.001) 直接写回待评审的文件，如: soar lint --fix a.sql")
+	listRuleSummaries := flag.Bool("list-rule-summaries", false, "ListRuleSummaries, 打印评审规则编号及一句话说明")
 	printVersion := flag.Bool("version", false, "Print version info")
 	query := flag.String("query", Config.Query, "待评审的 SQL 或 SQL 文件，如 SQL 中包含特殊字符建议使用文件名。")
 	listHeuristicRules := flag.Bool("list-heuristic-rules", Config.ListHeuristicRules, "ListHeuristicRules, 打印支持的评审规则列表")
+	showRule := flag.String("show-rule", Config.ShowRule, "ShowRule, 打印指定评审规则的完整文档，如: ARG.003")
+	ruleTest := flag.String("rule-test", Config.RuleTest, "RuleTest, 规则单元测试用例文件，检查各 SQL 给出的建议是否符合预期")
 	listRewriteRules := flag.Bool("list-rewrite-rules", Config.ListRewriteRules, "ListRewriteRules, 打印支持的重写规则列表")
 	listTestSQLs := flag.Bool("list-test-sqls", Config.ListTestSqls, "ListTestSqls, 打印测试case用于测试")
 	listReportTypes := flag.Bool("list-report-types", Config.ListReportTypes, "ListReportTypes, 打印支持的报告输出类型")
@@ -665,10 +1190,16 @@ func readCmdFlags() error {
 		flag.Usage = usage
 	}
 	flag.Parse()
+	// 命令行中未指定的参数使用 SOAR_ 开头的环境变量
+	envErr := applyEnvFlags(flag.CommandLine)
 
 	Config.OnlineDSN = ParseDSN(*onlineDSN, Config.OnlineDSN)
 	Config.TestDSN = ParseDSN(*testDSN, Config.TestDSN)
 	Config.AllowOnlineAsTest = *allowOnlineAsTest
+	if *onlineAllowList != "" {
+		Config.OnlineAllowList = strings.Split(strings.ToLower(*onlineAllowList), ",")
+	}
+	Config.IKnowWhatIAmDoing = *iKnowWhatIAmDoing
 	Config.DropTestTemporary = *dropTestTemporary
 	Config.CleanupTestDatabase = *cleanupTestDatabase
 	Config.OnlySyntaxCheck = *onlySyntaxCheck
@@ -678,6 +1209,7 @@ func readCmdFlags() error {
 	Config.Sampling = *sampling
 	Config.SamplingStatisticTarget = *samplingStatisticTarget
 	Config.SamplingCondition = *samplingCondition
+	Config.StatisticsTransfer = *statisticsTransfer
 
 	Config.LogLevel = *logLevel
 
@@ -694,9 +1226,25 @@ func readCmdFlags() error {
 	Config.ReportCSS = *reportCSS
 	Config.ReportJavascript = *reportJavascript
 	Config.ReportTitle = *reportTitle
+	Config.JoinGraph = strings.ToLower(*joinGraph)
+	Config.MermaidJS = *mermaidJS
 	Config.MarkdownExtensions = *markdownExtensions
 	Config.MarkdownHTMLFlags = *markdownHTMLFlags
 	Config.IgnoreRules = strings.Split(*ignoreRules, ",")
+	Config.RulePrecedence = strings.Split(*rulePrecedence, ",")
+	Config.RuleThresholds = parseRuleThresholds(*ruleThresholds)
+	Config.SeverityLabels = parseSeverityLabels(*severityLabels)
+	Config.CriticalObjects = nil
+	if *criticalObjects != "" {
+		Config.CriticalObjects = strings.Split(*criticalObjects, ",")
+	}
+	Config.CriticalSeverity = parseCriticalSeverity(*criticalSeverity)
+	Config.AllowedSchemaGroups = nil
+	if *allowedSchemaGroups != "" {
+		Config.AllowedSchemaGroups = strings.Split(*allowedSchemaGroups, ",")
+	}
+	Config.Lang = *lang
+	Config.LangFile = *langFile
 	Config.RewriteRules = strings.Split(*rewriteRules, ",")
 	*blackList = strings.TrimSpace(*blackList)
 	Config.MinCardinality = *minCardinality
@@ -732,16 +1280,25 @@ func readCmdFlags() error {
 	Config.MaxIdxCount = *maxIdxCount
 	Config.MaxColCount = *maxColCount
 	Config.MaxValueCount = *maxValueCount
+	Config.InsertBatchSize = *insertBatchSize
 	Config.IdxPrefix = *idxPrefix
 	Config.UkPrefix = *ukPrefix
 	Config.MaxSubqueryDepth = *maxSubqueryDepth
 	Config.MaxTotalRows = *maxTotalRows
 	Config.MaxQueryCost = *maxQueryCost
+	Config.MaxAffectedRows = *maxAffectedRows
+	Config.AffectedRowsCount = *affectedRowsCount
+	Config.MaxResultRows = *maxResultRows
+	Config.MaxResultSize = *maxResultSize
+	Config.MaxScanPoolPercent = *maxScanPoolPercent
 	Config.AllowDropIndex = *allowDropIdx
 	Config.MaxInCount = *maxInCount
 	Config.SpaghettiQueryLength = *spaghettiQueryLength
 	Config.Query = *query
 	Config.Delimiter = *delimiter
+	Config.QueryRetry = *queryRetry
+	Config.QueryRetryBackoff = *queryRetryBackoff
+	Config.QueryTimeout = *queryTimeout
 
 	Config.ExplainSQLReportType = strings.ToLower(*explainSQLReportType)
 	Config.ExplainType = strings.ToLower(*explainType)
@@ -756,7 +1313,10 @@ func readCmdFlags() error {
 	Config.ExplainWarnScalability = strings.Split(*explainWarnScalability, ",")
 	Config.ShowWarnings = *showWarnings
 	Config.ShowLastQueryCost = *showLastQueryCost
+	Config.CheckSysVariables = *checkSysVariables
 	Config.ListHeuristicRules = *listHeuristicRules
+	Config.ShowRule = *showRule
+	Config.RuleTest = *ruleTest
 	Config.ListRewriteRules = *listRewriteRules
 	Config.ListTestSqls = *listTestSQLs
 	Config.ListReportTypes = *listReportTypes
@@ -767,19 +1327,78 @@ func readCmdFlags() error {
 	if *columnNotAllowType != "" {
 		Config.ColumnNotAllowType = strings.Split(strings.ToLower(*columnNotAllowType), ",")
 	}
+	Config.MaxAutoIncRatio = *maxAutoIncRatio
+	Config.MaxLockTxnStatements = *maxLockTxnStatements
+	Config.MaxRecursionDepth = *maxRecursionDepth
+	Config.ArchiveMinRows = *archiveMinRows
+	Config.ArchiveMinSize = *archiveMinSize
+	Config.ArchiveKeepDays = *archiveKeepDays
+	Config.MDLHotTableOps = *mdlHotTableOps
+	Config.MDLLongQueryTime = *mdlLongQueryTime
+	Config.ArchiveChunkSize = *archiveChunkSize
+	Config.CompressMinSize = *compressMinSize
+	Config.CompressWriteRatio = *compressWriteRatio
+	Config.CapacityHistory = *capacityHistory
+	Config.CapacityHorizon = *capacityHorizon
+	Config.CapacityMaxSize = *capacityMaxSize
+	Config.CapacityDiskSize = *capacityDiskSize
+	Config.DiffBase = *diffBase
+	Config.SchemaFile = *schemaFile
+	Config.ExpandView = *expandView
+	Config.ReportDir = *reportDir
+	Config.ReportStorage = *reportStorage
+	Config.StorageEndpoint = *storageEndpoint
+	Config.StorageRegion = *storageRegion
+	Config.StorageAccessKey = *storageAccessKey
+	Config.StorageSecretKey = *storageSecretKey
+	Config.ReportRetention = *reportRetention
+	Config.ReportURLExpires = *reportURLExpires
+	Config.AggregateDuplicates = *aggregateDuplicates
+	Config.QueryStats = *queryStats
+	Config.Top = *top
+	Config.IssueMinSeverity = *issueMinSeverity
+	Config.JiraProject = *jiraProject
+	Config.JiraIssueType = *jiraIssueType
+	Config.ServeHistory = *serveHistory
+	Config.ServeTokens = nil
+	if *serveTokens != "" {
+		Config.ServeTokens = strings.Split(*serveTokens, ",")
+	}
+	Config.ServeRateLimit = *serveRateLimit
+	Config.HistoryDSN = *historyDSN
+	Config.OwnerFile = *ownerFile
+	Config.ShardTables = strings.Split(*shardTables, ",")
+	Config.Dialect = strings.ToLower(*dialect)
+	Config.Cluster = strings.ToLower(*cluster)
+	Config.Target = strings.ToLower(*target)
+	Config.SQLMode = strings.ToUpper(*sqlMode)
+	Config.FingerprintFunc = strings.ToLower(*fingerprintFunc)
+	Config.FingerprintCollapseIn = *fingerprintCollapseIn
+	Config.FingerprintStripComments = *fingerprintStripComments
+	Config.FingerprintShardPattern = *fingerprintShardPattern
 
 	PrintVersion = *printVersion
 	PrintConfig = *printConfig
+	PrintConfigResolved = *printConfigResolved
 	CheckConfig = *checkConfig
+	Doctor = *doctor
+	Serve = *serve
+	ShowHistory = *showHistory
+	Completion = *completion
+	ListRuleSummaries = *listRuleSummaries
+	Fix = *fix
 
 	hasParsed = true
-	return nil
+	return envErr
 }
 
 // ParseConfig 加载配置文件和命令行参数
 func ParseConfig(configFile string) error {
 	var err error
 	var configs []string
+	if Profile == "" {
+		Profile = ArgProfile()
+	}
 	// 指定了配置文件优先读配置文件，未指定配置文件按如下顺序加载，先找到哪个加载哪个
 	if configFile == "" {
 		configs = []string{
@@ -799,16 +1418,26 @@ func ParseConfig(configFile string) error {
 			if err != nil {
 				Log.Error("ParseConfig Config.readConfigFile Error: %v", err)
 			}
+			ConfigFile = config
 			// LogOutput now is "console", if add Log.Debug here will print into stdout anyway.
 			// Log.Debug("ParseConfig use config file: %s", config)
 			break
 		}
 	}
+	// 指定了 profile 但未能生效时，继续执行可能会连接错误的环境，错误在最后返回
+	var profileErr error
+	if Profile != "" {
+		if ConfigFile == "" {
+			profileErr = fmt.Errorf("profile '%s' requires a config file", Profile)
+		} else if err != nil {
+			profileErr = err
+		}
+	}
 
-	err = readCmdFlags()
-	if err != nil {
-		Log.Error("ParseConfig readCmdFlags Error: %v", err)
-		return err
+	// 环境变量格式错误时其他配置仍然生效，错误在最后返回
+	flagErr := readCmdFlags()
+	if flagErr != nil {
+		Log.Error("ParseConfig readCmdFlags Error: %v", flagErr)
 	}
 
 	// parse blacklist & ignore blacklist file parse error
@@ -832,6 +1461,12 @@ func ParseConfig(configFile string) error {
 		defer blFd.Close()
 	}
 	LoggerInit()
+	if profileErr != nil {
+		return profileErr
+	}
+	if flagErr != nil {
+		return flagErr
+	}
 	return err
 }
 
@@ -851,7 +1486,7 @@ var ReportTypes = []ReportType{
 	},
 	{
 		Name:        "markdown",
-		Description: "该格式为默认输出格式，以markdown格式展现，可以用网页浏览器插件直接打开，也可以用markdown编辑器打开",
+		Description: "该格式为默认输出格式，以markdown格式展现，可以用网页浏览器插件直接打开，也可以用markdown编辑器打开，配置了线上环境时报告开头输出评审时间、线上环境的版本、sql_mode 及相关变量",
 		Example:     `echo "select * from film" | soar`,
 	},
 	{
@@ -901,13 +1536,22 @@ var ReportTypes = []ReportType{
 	},
 	{
 		Name:        "explain-digest",
-		Description: "输入为EXPLAIN的表格，JSON 或 Vertical格式，对其进行分析，给出分析结果",
+		Description: "输入为EXPLAIN的表格，JSON 或 Vertical(\\G)格式，自动识别格式，可以直接粘贴命令行客户端中带提示符的输出，对其进行分析，给出分析结果",
 		Example: `soar -report-type explain-digest << EOF
 +----+-------------+-------+------+---------------+------+---------+------+------+-------+
 | id | select_type | table | type | possible_keys | key  | key_len | ref  | rows | Extra |
 +----+-------------+-------+------+---------------+------+---------+------+------+-------+
 |  1 | SIMPLE      | film  | ALL  | NULL          | NULL | NULL    | NULL | 1131 |       |
 +----+-------------+-------+------+---------------+------+---------+------+------+-------+
+EOF`,
+	},
+	{
+		Name:        "partition",
+		Description: "输入建表语句及业务 SQL，给出分区键及分区方式建议，列出哪些 SQL 可以进行分区裁剪，并生成分区表的建表语句",
+		Example: `soar -report-type partition << EOF
+CREATE TABLE orders (id bigint NOT NULL AUTO_INCREMENT, uid int, created_at datetime, PRIMARY KEY (id));
+SELECT * FROM orders WHERE created_at >= '2019-01-01' AND created_at < '2019-02-01';
+SELECT * FROM orders WHERE uid = 1;
 EOF`,
 	},
 	{
@@ -915,6 +1559,41 @@ EOF`,
 		Description: "对 OnlineDsn 中指定的 database 进行索引重复检查",
 		Example:     `soar -report-type duplicate-key-checker -online-dsn user:password@127.0.0.1:3306/db`,
 	},
+	{
+		Name:        "utf8mb4-migration",
+		Description: "对 OnlineDsn 中指定的 database 给出 utf8 迁移至 utf8mb4 的方案，包括受影响的列、索引长度超限、行格式调整及按顺序执行的 ALTER 语句",
+		Example:     `soar -report-type utf8mb4-migration -online-dsn user:password@127.0.0.1:3306/db`,
+	},
+	{
+		Name:        "archive",
+		Description: "对 OnlineDsn 中指定 database 里超过 -archive-min-rows 或 -archive-min-size 的大表，根据输入的业务 SQL 中的时间范围查询给出归档方案",
+		Example:     `soar -report-type archive -online-dsn user:password@127.0.0.1:3306/db -query workload.sql`,
+	},
+	{
+		Name:        "compression",
+		Description: "对 OnlineDsn 中指定 database 里超过 -compress-min-size 且写入较少的 InnoDB 表，根据列类型及采样数据（-sampling）的可压缩性给出 ROW_FORMAT=COMPRESSED、透明页压缩或字典编码建议，对随机写入比例超过 -compress-write-ratio 的压缩表建议取消压缩，读写频率来自 performance_schema 或输入的业务 SQL",
+		Example:     `soar -report-type compression -online-dsn user:password@127.0.0.1:3306/db -sampling -query workload.sql`,
+	},
+	{
+		Name:        "capacity",
+		Description: "根据 -capacity-history 中的历史快照拟合表的增长趋势，对 -capacity-horizon 天内数据大小超过 -capacity-max-size、分区数超过上限或自增值超过 -max-auto-inc-ratio 的表给出警告，指定 OnlineDsn 时每次运行都会将当前快照追加至历史文件",
+		Example:     `soar -report-type capacity -online-dsn user:password@127.0.0.1:3306/db -capacity-history capacity.csv`,
+	},
+	{
+		Name:        "schema-diff",
+		Description: "比较 -diff-base 指定的 Schema（mysqldump 导出文件或 DSN，默认为 OnlineDsn）与输入的建表语句，生成变更语句并对变更语句进行评审",
+		Example:     `soar -report-type schema-diff -diff-base user:password@127.0.0.1:3306/db -query schema.sql`,
+	},
+	{
+		Name:        "migration-dry-run",
+		Description: "将 OnlineDsn 当前库的表结构复制到 TestDsn 后按顺序执行输入的变更脚本，给出每条语句的执行错误、耗时、启发式建议以及执行后的 Schema 变化",
+		Example:     `soar -report-type migration-dry-run -online-dsn user:password@127.0.0.1:3306/db -test-dsn user:password@127.0.0.1:3307/db -query migration.sql`,
+	},
+	{
+		Name:        "schema-audit",
+		Description: "对 mysqldump --no-data 导出的所有建表语句逐表执行启发式规则检查，给出每张表的得分及整个库的汇总信息，未指定输入时检查 OnlineDsn 中的所有表，也可以使用 soar schema-audit 子命令",
+		Example:     `soar schema-audit -query schema.sql 或 soar -report-type schema-audit -online-dsn user:password@127.0.0.1:3306/db`,
+	},
 	{
 		Name:        "html",
 		Description: "以HTML格式输出报表",
@@ -922,9 +1601,74 @@ EOF`,
 	},
 	{
 		Name:        "json",
-		Description: "输出JSON格式报表，方便应用程序处理",
+		Description: "输出JSON格式报表，方便应用程序处理，配置了线上环境时每条建议的 Server 中记录评审时间、线上环境的版本、sql_mode 及相关变量",
 		Example:     `echo "select * from film" | soar -report-type json`,
 	},
+	{
+		Name:        "codequality",
+		Description: "输出 GitLab Code Quality 格式的 JSON 报告，包含文件路径及行号，用于在 Merge Request 中展示评审结果",
+		Example:     `soar -report-type codequality -query query.sql > gl-code-quality-report.json`,
+	},
+	{
+		Name:        "rdjson",
+		Description: "输出 reviewdog 使用的 Diagnostic JSON 格式报告，包含文件路径及行号，用于在 Pull Request 中添加行内评论",
+		Example:     `soar -report-type rdjson -query query.sql | reviewdog -f=rdjson -reporter=github-pr-review`,
+	},
+	{
+		Name:        "checkstyle",
+		Description: "输出 Checkstyle XML 格式报告，包含文件路径及行号，可直接被 Jenkins warnings-ng 等工具解析",
+		Example:     `soar -report-type checkstyle -query query.sql > soar-checkstyle.xml`,
+	},
+	{
+		Name:        "tap",
+		Description: "输出 TAP version 13 格式报告，每条 SQL 为一个测试项，存在优化建议时为 not ok",
+		Example:     `soar -report-type tap -query query.sql`,
+	},
+	{
+		Name:        "xlsx",
+		Description: "输出 Excel 格式报告，每个 Severity 一个 sheet，包含 Fingerprint, Sample, Rule, Severity, Advice, Tables 等列，方便将评审结果交给业务方",
+		Example:     `soar -report-type xlsx -query query.sql > soar-report.xlsx`,
+	},
+	{
+		Name:        "jira",
+		Description: "将不低于 issue-min-severity 的建议转换为 Jira REST API 批量创建 issue 的请求体，同一 SQL 指纹的同一条建议合并为一个 issue，并以指纹+规则生成的去重标签避免重复建单",
+		Example:     `soar -report-type jira -jira-project DBA -query query.sql > issues.json`,
+	},
+	{
+		Name:        "issues",
+		Description: "将不低于 issue-min-severity 的建议输出为通用的 issue JSON，包含基于指纹+规则的 dedup_key 及所有出现位置，用于对接其他缺陷跟踪系统",
+		Example:     `soar -report-type issues -issue-min-severity L2 -query query.sql`,
+	},
+	{
+		Name:        "archery",
+		Description: "输出与 Archery 工单审核结果（ReviewResult）相同结构的 JSON，每条 SQL 一行，errlevel 为该 SQL 所有建议中的最高级别（0 通过，1 警告，2 错误）",
+		Example:     `soar -report-type archery -query query.sql`,
+	},
+	{
+		Name:        "yearning",
+		Description: "输出与 Yearning 工单审核结果相同结构的 JSON，每条 SQL 一行，包含 sql, level, error, affect_rows",
+		Example:     `soar -report-type yearning -query query.sql`,
+	},
+	{
+		Name:        "bytebase",
+		Description: "输出与 Bytebase SQL Review 检查结果相同结构的 JSON，每条建议为一个 advice，status 为 SUCCESS, WARNING 或 ERROR",
+		Example:     `soar -report-type bytebase -query query.sql`,
+	},
+	{
+		Name:        "workload",
+		Description: "按结构相似度对批量输入的 SQL 聚类，统计每类 SQL 的条数及建议，以及各反模式出现的次数，找出最值得修复的 SQL 模板，同一列上多次使用 LIKE '%word%' 时给出全文索引建议",
+		Example:     `soar -report-type workload -query slow.sql`,
+	},
+	{
+		Name:        "shard-advisor",
+		Description: "根据批量输入的 SQL 中的查询条件为每张表推荐分片键，并列出在该分片键下需要发送到所有分片的查询，输入中包含建表语句时只推荐表中存在的列",
+		Example:     `cat schema.sql slow.sql | soar -report-type shard-advisor`,
+	},
+	{
+		Name:        "compat-lint",
+		Description: "迁移兼容性检查，找出为 Oracle, SQL Server 编写的 SQL 中在 MySQL 上无法执行或行为不同的语法（如 ROWNUM, (+) 外连接, NVL, TOP, 序列等）并给出 MySQL 的改写建议，发现问题时以非零状态退出",
+		Example:     `soar -report-type compat-lint -query oracle.sql`,
+	},
 	{
 		Name:        "tokenize",
 		Description: "对SQL进行切词，主要用于测试",
@@ -1001,5 +1745,31 @@ func ArgConfig() string {
 			}
 		}
 	}
+	// 未指定 -config 时使用 SOAR_CONFIG 环境变量
+	if configFile == "" {
+		configFile = os.Getenv(EnvName("config"))
+	}
 	return configFile
 }
+
+// ArgProfile get -profile arg value from cli，未指定时使用 SOAR_PROFILE 环境变量
+// profile 需要在加载配置文件时生效，早于 flag.Parse，所以需要单独解析
+func ArgProfile() string {
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if name == "profile" && i+1 < len(os.Args) {
+			return os.Args[i+1]
+		}
+		if strings.HasPrefix(name, "profile=") {
+			return strings.TrimPrefix(name, "profile=")
+		}
+	}
+	return os.Getenv(EnvName("profile"))
+}
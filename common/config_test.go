@@ -18,9 +18,11 @@ package common
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/kr/pretty"
@@ -35,6 +37,7 @@ func TestMain(m *testing.M) {
 		DevPath, _ = filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	}
 	BaseDir = DevPath
+	RedirectTestLog()
 	err := ParseConfig("")
 	LogIfError(err, "init ParseConfig")
 	Log.Debug("mysql_test init")
@@ -49,7 +52,16 @@ func TestMain(m *testing.M) {
 
 func TestParseConfig(t *testing.T) {
 	Log.Debug("Entering function: %s", GetFunctionName())
-	err := ParseConfig("")
+	// 重复解析时不再读取命令行参数及环境变量，配置文件中相对路径的 log-output 会写入当前目录
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	err = ParseConfig("")
 	if err != nil {
 		t.Error("sqlparser.Parse Error:", err)
 	}
@@ -65,6 +77,100 @@ func TestReadConfigFile(t *testing.T) {
 	Log.Debug("Exiting function: %s", GetFunctionName())
 }
 
+func TestValidateConfigFile(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	errs, err := ValidateConfigFile(filepath.Join(DevPath, "etc/soar.yaml"))
+	if err != nil || len(errs) > 0 {
+		t.Errorf("etc/soar.yaml want valid, got %v, %v", errs, err)
+	}
+
+	f, err := ioutil.TempFile("", "soar*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("online-dsn:\n  addr: 127.0.0.1:3306\n  passwd: xxx\nmax-in-cnt: 10\nmax-join-table-count: abc\n")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err = ValidateConfigFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 3 || !strings.Contains(errs[0], "passwd") || !strings.Contains(errs[1], "max-in-cnt") || !strings.Contains(errs[2], "abc") {
+		t.Errorf("want 3 errors, got %v", errs)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestApplyProfile(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	f, err := ioutil.TempFile("", "soar*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`online-dsn:
+  addr: 10.0.0.1:3306
+  user: audit
+max-in-count: 10
+profiles:
+  prod-audit:
+    online-dsn:
+      addr: 10.0.0.9:3306
+    ignore-rules:
+    - ARG.001
+  staging:
+    max-in-cnt: 3
+`)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgProfile, orgDSN, orgIgnoreRules, orgMaxInCount := Profile, *Config.OnlineDSN, Config.IgnoreRules, Config.MaxInCount
+	defer func() {
+		Profile, *Config.OnlineDSN, Config.IgnoreRules, Config.MaxInCount, Config.Profiles = orgProfile, orgDSN, orgIgnoreRules, orgMaxInCount, nil
+	}()
+
+	// profile 中未出现的配置项保持顶层配置
+	Profile = "prod-audit"
+	if err = Config.readConfigFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if Config.OnlineDSN.Addr != "10.0.0.9:3306" || Config.OnlineDSN.User != "audit" ||
+		len(Config.IgnoreRules) != 1 || Config.IgnoreRules[0] != "ARG.001" || Config.MaxInCount != 10 {
+		t.Errorf("profile not applied, got online-dsn: %s@%s, ignore-rules: %v, max-in-count: %d",
+			Config.OnlineDSN.User, Config.OnlineDSN.Addr, Config.IgnoreRules, Config.MaxInCount)
+	}
+
+	Profile = "prod"
+	if err = Config.readConfigFile(f.Name()); err == nil || !strings.Contains(err.Error(), "available: prod-audit, staging") {
+		t.Errorf("want profile not found error, got %v", err)
+	}
+
+	errs, err := ValidateConfigFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "profiles.staging: field max-in-cnt not found") {
+		t.Errorf("want profile validate error, got %v", errs)
+	}
+
+	orgArgs := os.Args
+	defer func() { os.Args = orgArgs }()
+	os.Args = []string{"soar", "-config=soar.yaml", "-profile", "staging", "-query", "select 1"}
+	if p := ArgProfile(); p != "staging" {
+		t.Errorf("want staging, got %s", p)
+	}
+	os.Args = []string{"soar", "--profile=prod-audit"}
+	if p := ArgProfile(); p != "prod-audit" {
+		t.Errorf("want prod-audit, got %s", p)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
 func TestParseDSN(t *testing.T) {
 	Log.Debug("Entering function: %s", GetFunctionName())
 	var dsns = []string{
@@ -100,6 +206,14 @@ func TestParseDSN(t *testing.T) {
 		"user:password@/dbname",
 		"user:password@/",
 		"user:password@tcp(localhost:3307)/database?charset=utf8&timeout=5s",
+		// ssh tunnel
+		"user:password@hostname:3307/database?ssh-host=bastion:2222&ssh-user=ops&ssh-key=/path/to/id_rsa",
+		"user:password@tcp(10.0.0.1:3306)/dbname?ssh-host=bastion&ssh-user=ops&ssh-key=/path/to/id_rsa",
+		// tls
+		"user:password@tcp(10.0.0.1:3306)/dbname?tls-ca=/path/to/ca.pem&tls-cert=/path/to/client-cert.pem&tls-key=/path/to/client-key.pem&tls-server-name=mysql.example.com",
+		"user:password@tcp(10.0.0.1:3306)/dbname?tls-skip-verify=true",
+		// replicas
+		"user:password@tcp(10.0.0.1:3306)/dbname?replicas=10.0.0.2:3306,10.0.0.3:3306",
 	}
 
 	err := GoldenDiff(func() {
@@ -151,6 +265,42 @@ func TestArgConfig(t *testing.T) {
 	Log.Debug("Exiting function: %s", GetFunctionName())
 }
 
+func TestParseRuleThresholds(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	thresholds := parseRuleThresholds("ARG.005=20, JOI.005 = 3,KEY.005,KEY.006=x,")
+	if len(thresholds) != 2 || thresholds["ARG.005"] != 20 || thresholds["JOI.005"] != 3 {
+		t.Errorf("parseRuleThresholds got: %v", thresholds)
+	}
+	if str := formatRuleThresholds(thresholds); str != "ARG.005=20,JOI.005=3" {
+		t.Errorf("formatRuleThresholds got: %s", str)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestParseSeverityLabels(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	labels := parseSeverityLabels("L0=info, l3 = Error,L8=blocker,L9=info,L5=fatal,L1,")
+	if len(labels) != 3 || labels["L0"] != "info" || labels["L3"] != "error" || labels["L8"] != "blocker" {
+		t.Errorf("parseSeverityLabels got: %v", labels)
+	}
+	if str := formatSeverityLabels(labels); str != "L0=info,L3=error,L8=blocker" {
+		t.Errorf("formatSeverityLabels got: %s", str)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestParseCriticalSeverity(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	levels := parseCriticalSeverity("CLA.015=L8, CLA.014 = l7,KWR.001=L9,COL.001,")
+	if len(levels) != 2 || levels["CLA.015"] != "L8" || levels["CLA.014"] != "L7" {
+		t.Errorf("parseCriticalSeverity got: %v", levels)
+	}
+	if str := formatCriticalSeverity(levels); str != "CLA.014=L7,CLA.015=L8" {
+		t.Errorf("formatCriticalSeverity got: %s", str)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
 func TestPrintConfiguration(t *testing.T) {
 	Log.Debug("Entering function: %s", GetFunctionName())
 	Config.readConfigFile(filepath.Join(DevPath, "etc/soar.yaml"))
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// 配置分层：默认值 < 配置文件 < 环境变量 < 命令行参数
+// 每个命令行参数都可以通过 SOAR_ 开头的环境变量设置，如 -online-dsn 对应 SOAR_ONLINE_DSN，便于容器化部署
+// 配置文件可以通过 SOAR_CONFIG 环境变量指定
+
+// configSource 配置项的来源，key 为配置项名称，未记录的配置项使用默认值
+var configSource = make(map[string]string)
+
+// configKeyRe -print-config-resolved 输出中的一级配置项
+var configKeyRe = regexp.MustCompile(`^([a-z0-9-]+):`)
+
+// EnvName 命令行参数对应的环境变量名，如 online-dsn 对应 SOAR_ONLINE_DSN
+func EnvName(name string) string {
+	return "SOAR_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// recordConfigFile 记录配置文件中设置的配置项
+func recordConfigFile(path string, content []byte) {
+	var keys map[string]interface{}
+	if yaml.Unmarshal(content, &keys) != nil {
+		return
+	}
+	for k := range keys {
+		configSource[k] = "config: " + path
+	}
+}
+
+// applyEnvFlags 在 flag.Parse 之后调用，命令行中未指定的参数使用环境变量中的值
+func applyEnvFlags(fs *flag.FlagSet) error {
+	cli := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		cli[f.Name] = true
+		configSource[f.Name] = "flag: -" + f.Name
+	})
+
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		env := EnvName(f.Name)
+		v, ok := os.LookupEnv(env)
+		if !ok || cli[f.Name] {
+			return
+		}
+		if err := fs.Set(f.Name, v); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", env, err))
+			return
+		}
+		configSource[f.Name] = "env: " + env
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// PrintResolvedConfiguration for `-print-config-resolved` flag，打印生效的配置及每个配置项的来源
+func PrintResolvedConfiguration() {
+	fmt.Print(resolvedConfiguration(printableConfiguration()))
+}
+
+// resolvedConfiguration 在 yaml 格式的配置中为每个一级配置项添加来源注释
+func resolvedConfiguration(data string) string {
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	for i, line := range lines {
+		m := configKeyRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		source, ok := configSource[m[1]]
+		if !ok {
+			source = "default"
+		}
+		lines[i] = line + " # " + source
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	cases := map[string]string{
+		"online-dsn":   "SOAR_ONLINE_DSN",
+		"max-in-count": "SOAR_MAX_IN_COUNT",
+		"config":       "SOAR_CONFIG",
+	}
+	for name, env := range cases {
+		if got := EnvName(name); got != env {
+			t.Errorf("%s want %s, got %s", name, env, got)
+		}
+	}
+}
+
+func TestApplyEnvFlags(t *testing.T) {
+	orgSource := configSource
+	configSource = make(map[string]string)
+	defer func() { configSource = orgSource }()
+
+	fs := flag.NewFlagSet("soar", flag.ContinueOnError)
+	reportType := fs.String("report-type", "markdown", "")
+	maxInCount := fs.Int("max-in-count", 10, "")
+	lang := fs.String("lang", "en", "")
+	verbose := fs.Bool("verbose", false, "")
+	if err := fs.Parse([]string{"-lang", "zh-CN"}); err != nil {
+		t.Fatal(err)
+	}
+
+	recordConfigFile("soar.yaml", []byte("report-type: json\nmax-in-count: 5\n"))
+	for k, v := range map[string]string{"SOAR_MAX_IN_COUNT": "20", "SOAR_LANG": "en", "SOAR_VERBOSE": "true"} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	if err := applyEnvFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	// 命令行参数优先于环境变量
+	if *reportType != "markdown" || *maxInCount != 20 || *lang != "zh-CN" || !*verbose {
+		t.Errorf("got report-type: %s, max-in-count: %d, lang: %s, verbose: %v", *reportType, *maxInCount, *lang, *verbose)
+	}
+	sources := map[string]string{
+		"report-type":  "config: soar.yaml",
+		"max-in-count": "env: SOAR_MAX_IN_COUNT",
+		"lang":         "flag: -lang",
+		"verbose":      "env: SOAR_VERBOSE",
+	}
+	for k, v := range sources {
+		if configSource[k] != v {
+			t.Errorf("%s source want %s, got %s", k, v, configSource[k])
+		}
+	}
+
+	got := resolvedConfiguration("report-type: json\nonline-dsn:\n  addr: 127.0.0.1:3306\nignore-rules:\n- COL.011\nlog-level: 3\n")
+	want := "report-type: json # config: soar.yaml\nonline-dsn: # default\n  addr: 127.0.0.1:3306\nignore-rules: # default\n- COL.011\nlog-level: 3 # default\n"
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	fs = flag.NewFlagSet("soar", flag.ContinueOnError)
+	fs.Int("max-in-count", 10, "")
+	os.Setenv("SOAR_MAX_IN_COUNT", "abc")
+	if err := applyEnvFlags(fs); err == nil || !strings.Contains(err.Error(), "SOAR_MAX_IN_COUNT") {
+		t.Errorf("want SOAR_MAX_IN_COUNT parse error, got %v", err)
+	}
+}
+
+func TestPrintableConfiguration(t *testing.T) {
+	orgConfig, orgOnline, orgTest := *Config, *Config.OnlineDSN, *Config.TestDSN
+	defer func() {
+		*Config = orgConfig
+		*Config.OnlineDSN, *Config.TestDSN = orgOnline, orgTest
+	}()
+
+	Config.Verbose = false
+	Config.OnlineDSN.Password = "pwd-online"
+	Config.TestDSN.Password = "pwd-test"
+	Config.StorageSecretKey = "pwd-storage"
+	Config.HistoryDSN = "root:pwd-history@127.0.0.1:3306/soar"
+	Config.ServeTokens = []string{"dba:pwd-serve", "pwd-noname"}
+	data := printableConfiguration()
+	for _, secret := range []string{"pwd-online", "pwd-test", "pwd-storage", "pwd-history", "pwd-serve", "pwd-noname"} {
+		if strings.Contains(data, secret) {
+			t.Errorf("printableConfiguration should hide %s", secret)
+		}
+	}
+	if !strings.Contains(resolvedConfiguration(data), "history-dsn: root:********@127.0.0.1:3306/soar #") {
+		t.Errorf("resolvedConfiguration got: %s", resolvedConfiguration(data))
+	}
+}
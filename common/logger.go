@@ -19,6 +19,8 @@ package common
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
@@ -56,6 +58,11 @@ func LoggerInit() {
 	}
 }
 
+// RedirectTestLog 各包的 TestMain 在 ParseConfig 前调用，将日志写入系统临时目录，避免测试时在代码目录中生成 soar.log
+func RedirectTestLog() {
+	LogIfWarn(os.Setenv(EnvName("log-output"), filepath.Join(os.TempDir(), "soar_test.log")), "")
+}
+
 // Caller returns the caller of the function that called it :)
 // https://stackoverflow.com/questions/35212985/is-it-possible-get-information-about-caller-function-in-golang
 func Caller() string {
@@ -18,6 +18,7 @@ package common
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -127,14 +128,54 @@ func Markdown2HTML(buf string) string {
 
 	renderer := blackfriday.HtmlRenderer(htmlFlags, "", "")
 	buf = string(blackfriday.Markdown([]byte(buf), renderer, extensions))
+	// JOIN 关系图使用 mermaid 绘制，仅在报告中包含关系图且配置了 mermaid-js 时加载，否则显示 mermaid 源码
+	if Config.MermaidJS != "" && strings.Contains(buf, `<code class="language-mermaid">`) {
+		buf += MermaidLoader(Config.MermaidJS)
+	}
 	return buf
 }
 
+// MermaidLoader 从 src 加载 mermaid 并将 markdown 中的 mermaid 代码块渲染为图，多次出现时只加载一次 mermaid
+func MermaidLoader(src string) string {
+	// json.Marshal 会转义 <, >, &，src 中的内容不会提前结束 script 标签
+	quoted, _ := json.Marshal(src)
+	return strings.Replace(mermaidLoader, "{{src}}", string(quoted), 1)
+}
+
+// mermaidLoader MermaidLoader 的脚本模板，{{src}} 为 mermaid.min.js 的地址
+const mermaidLoader = `<script>
+(function () {
+  function render() {
+    var codes = document.querySelectorAll('code.language-mermaid');
+    for (var i = 0; i < codes.length; i++) {
+      var div = document.createElement('div');
+      div.className = 'mermaid';
+      div.textContent = codes[i].textContent;
+      codes[i].parentNode.replaceWith(div);
+    }
+    window.mermaid.init(undefined, document.querySelectorAll('div.mermaid:not([data-processed])'));
+  }
+  if (window.mermaid) {
+    render();
+  } else if (!window.soarMermaidLoading) {
+    window.soarMermaidLoading = true;
+    var s = document.createElement('script');
+    s.src = {{src}};
+    s.onload = function () {
+      window.mermaid.initialize({startOnLoad: false});
+      render();
+    };
+    document.head.appendChild(s);
+  }
+})();
+</script>
+`
+
 // Score SQL评审打分
 func Score(score int) string {
 	// 不需要打分的功能
 	switch Config.ReportType {
-	case "duplicate-key-checker", "explain-digest":
+	case "duplicate-key-checker", "utf8mb4-migration", "archive", "compression", "capacity", "explain-digest":
 		return ""
 	}
 	s1, s2 := "★ ", "☆ "
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -66,6 +67,24 @@ func TestMarkdown2Html(t *testing.T) {
 	Log.Debug("Exiting function: %s", GetFunctionName())
 }
 
+func TestMarkdown2HTMLMermaid(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	orgMermaidJS := Config.MermaidJS
+	defer func() { Config.MermaidJS = orgMermaidJS }()
+	md := "```mermaid\ngraph LR\n```\n"
+
+	// 未配置 mermaid-js 时不加载外部脚本
+	Config.MermaidJS = ""
+	if html := Markdown2HTML(md); strings.Contains(html, "<script>") || !strings.Contains(html, "graph LR") {
+		t.Errorf("want mermaid source without script, got: %s", html)
+	}
+	Config.MermaidJS = "/static/mermaid.min.js?</script>"
+	if html := Markdown2HTML(md); !strings.Contains(html, `s.src = "/static/mermaid.min.js?\u003c/script\u003e";`) {
+		t.Errorf("want escaped mermaid-js in loader, got: %s", html)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
 func TestScore(t *testing.T) {
 	Log.Debug("Entering function: %s", GetFunctionName())
 	scores := map[int]string{
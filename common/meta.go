@@ -103,6 +103,30 @@ func IsColsPart(a, b []*Column) bool {
 	return true
 }
 
+// IsColsSame 判断两个column队列包含的列是否相同，不考虑列的顺序
+func IsColsSame(a, b []*Column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, colA := range a {
+		found := false
+		for _, colB := range b {
+			if strings.EqualFold(colA.DB, colB.DB) &&
+				strings.EqualFold(colA.Table, colB.Table) &&
+				strings.EqualFold(colA.Name, colB.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
 // JoinColumnsName 将所有的列合并
 func JoinColumnsName(cols []*Column, sep string) string {
 	name := ""
@@ -42,6 +42,18 @@ func TestGetDataTypeLength(t *testing.T) {
 	Log.Debug("Exiting function: %s", GetFunctionName())
 }
 
+func TestIsColsSame(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	a := []*Column{{Name: "a", Table: "t"}, {Name: "b", Table: "t"}}
+	if !IsColsSame(a, []*Column{{Name: "B", Table: "t"}, {Name: "a", Table: "t"}}) {
+		t.Error("(a, b) and (B, a) should be same")
+	}
+	if IsColsSame(a, []*Column{{Name: "a", Table: "t"}}) || IsColsSame(a, []*Column{{Name: "a", Table: "t"}, {Name: "c", Table: "t"}}) {
+		t.Error("(a, b) should not be same as (a) or (a, c)")
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
 func TestGetDataTypeBase(t *testing.T) {
 	Log.Debug("Entering function: %s", GetFunctionName())
 	typeList := map[string]string{
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 外部密码支持：生产环境的密码不以明文保存在配置文件中，建立连接时通过命令或 HashiCorp Vault 获取
+// 获取的密码缓存 secret-ttl 时长，过期后或数据库返回认证失败时重新获取，以支持密码轮换
+// AWS Secrets Manager 等其他密码管理服务可以通过 password-command 调用其命令行工具获取
+
+// defaultSecretTTL 未配置 secret-ttl 时密码的缓存时长
+const defaultSecretTTL = 5 * time.Minute
+
+// defaultVaultField 未配置 vault-field 时 Vault 中保存密码的字段名
+const defaultVaultField = "password"
+
+// cachedSecret 缓存的密码
+type cachedSecret struct {
+	password string
+	expire   time.Time
+}
+
+// secretCache 已获取的密码，key 为密码来源
+var secretCache = struct {
+	sync.Mutex
+	m map[string]cachedSecret
+}{m: make(map[string]cachedSecret)}
+
+// HasSecret 判断是否配置了外部密码
+func (env *Dsn) HasSecret() bool {
+	return env != nil && (env.PasswordCommand != "" || env.VaultPath != "")
+}
+
+// secretKey 密码来源，用作缓存的 key
+func (env *Dsn) secretKey() string {
+	if env.PasswordCommand != "" {
+		return "command:" + env.PasswordCommand
+	}
+	return "vault:" + env.VaultPath + "#" + env.vaultField()
+}
+
+func (env *Dsn) vaultField() string {
+	if env.VaultField == "" {
+		return defaultVaultField
+	}
+	return env.VaultField
+}
+
+// secretTTL 密码的缓存时长，配置错误时使用默认值
+func (env *Dsn) secretTTL() time.Duration {
+	if env.SecretTTL == "" {
+		return defaultSecretTTL
+	}
+	ttl, err := time.ParseDuration(env.SecretTTL)
+	if err != nil {
+		LogIfWarn(err, "secret-ttl: '%s'", env.SecretTTL)
+		return defaultSecretTTL
+	}
+	return ttl
+}
+
+// ResolvePassword 获取数据库密码，未配置外部密码时返回 password，refresh 为 true 时忽略缓存重新获取
+func (env *Dsn) ResolvePassword(refresh bool) (string, error) {
+	if !env.HasSecret() {
+		return env.Password, nil
+	}
+	key := env.secretKey()
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	if s, ok := secretCache.m[key]; ok && !refresh && time.Now().Before(s.expire) {
+		return s.password, nil
+	}
+
+	var password string
+	var err error
+	if env.PasswordCommand != "" {
+		password, err = commandPassword(env.PasswordCommand)
+	} else {
+		password, err = vaultPassword(env.VaultPath, env.vaultField())
+	}
+	if err != nil {
+		return "", err
+	}
+	secretCache.m[key] = cachedSecret{password: password, expire: time.Now().Add(env.secretTTL())}
+	return password, nil
+}
+
+// ConnectDSN 生成建立连接使用的 DSN，配置了外部密码时使用获取到的密码
+func ConnectDSN(env *Dsn, refresh bool) (string, error) {
+	password, err := env.ResolvePassword(refresh)
+	if err != nil {
+		return "", err
+	}
+	dsn := *env
+	dsn.Password = password
+	dsn.PasswordCommand = ""
+	dsn.VaultPath = ""
+	dsn.VaultField = ""
+	dsn.SecretTTL = ""
+	return FormatDSN(&dsn), nil
+}
+
+// commandPassword 执行命令获取密码，标准输出去除首尾空白后作为密码
+func commandPassword(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password-command: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// vaultPassword 从 HashiCorp Vault 读取密码，地址及 Token 取自 VAULT_ADDR, VAULT_TOKEN 环境变量
+// 同时支持 KV v1 及 KV v2（返回值中的 data.data）两种格式
+func vaultPassword(path, field string) (string, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("vault-path: VAULT_ADDR not set")
+	}
+	req, err := http.NewRequest("GET", addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault-path: %s %s", path, resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.Unmarshal(body, &secret); err != nil {
+		return "", err
+	}
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	password, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault-path: %s field '%s' not found", path, field)
+	}
+	return password, nil
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "soar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 每次执行命令都会在 counter 中追加一行，用于检查缓存
+	counter := filepath.Join(dir, "counter")
+	dsn := &Dsn{
+		Password:        "plaintext",
+		PasswordCommand: fmt.Sprintf("echo x >> %s; echo ' s3cret '", counter),
+	}
+	for i := 0; i < 2; i++ {
+		password, err := dsn.ResolvePassword(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != "s3cret" {
+			t.Errorf("want s3cret, got %q", password)
+		}
+	}
+	if _, err = dsn.ResolvePassword(true); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(buf), "x"); n != 2 {
+		t.Errorf("want command executed 2 times, got %d", n)
+	}
+
+	dsn = &Dsn{PasswordCommand: "exit 1"}
+	if _, err = dsn.ResolvePassword(false); err == nil {
+		t.Error("want error for failed password-command")
+	}
+
+	dsn = &Dsn{Password: "plaintext"}
+	if password, _ := dsn.ResolvePassword(false); password != "plaintext" {
+		t.Errorf("want plaintext, got %q", password)
+	}
+}
+
+func TestVaultPassword(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/mysql":
+			fmt.Fprint(w, `{"data": {"data": {"password": "v2"}, "metadata": {"version": 1}}}`)
+		case "/v1/kv/mysql":
+			fmt.Fprint(w, `{"data": {"pass": "v1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	orgAddr, orgToken := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	defer func() {
+		os.Setenv("VAULT_ADDR", orgAddr)
+		os.Setenv("VAULT_TOKEN", orgToken)
+	}()
+	os.Setenv("VAULT_ADDR", ts.URL)
+	os.Setenv("VAULT_TOKEN", "token")
+
+	cases := []struct {
+		path, field, want string
+		fail              bool
+	}{
+		{"secret/data/mysql", "password", "v2", false},
+		{"kv/mysql", "pass", "v1", false},
+		{"kv/mysql", "password", "", true},
+		{"kv/other", "password", "", true},
+	}
+	for _, c := range cases {
+		password, err := vaultPassword(c.path, c.field)
+		if (err != nil) != c.fail || password != c.want {
+			t.Errorf("%s#%s want %q, got %q, err: %v", c.path, c.field, c.want, password, err)
+		}
+	}
+
+	os.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := vaultPassword("secret/data/mysql", "password"); err == nil {
+		t.Error("want error for invalid token")
+	}
+}
+
+func TestConnectDSN(t *testing.T) {
+	dsn := ParseDSN("root@tcp(127.0.0.1:3306)/sakila?password-command=echo+s3cret&secret-ttl=1m", nil)
+	if dsn.PasswordCommand != "echo s3cret" || dsn.SecretTTL != "1m" {
+		t.Fatalf("want password-command parsed, got %+v", dsn)
+	}
+	// FormatDSN 保留外部密码配置，不执行命令
+	if str := FormatDSN(dsn); !strings.Contains(str, "password-command=echo+s3cret") || strings.Contains(str, ":s3cret@") {
+		t.Errorf("FormatDSN got %s", str)
+	}
+	str, err := ConnectDSN(dsn, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(str, "root:s3cret@") || strings.Contains(str, "password-command") || strings.Contains(str, "secret-ttl") {
+		t.Errorf("ConnectDSN got %s", str)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ShardTable 分表配置，多张物理分表在报告、索引建议中作为一张逻辑表处理
+// 配置格式为 [逻辑表名=]分表名模式[:分片键]，如 user=user_%d:uid, log_2024%:created_at
+// 分表名模式中 %d 匹配数字，% 匹配任意字符，未指定逻辑表名时去掉模式中的通配部分作为逻辑表名
+type ShardTable struct {
+	Logical string // 逻辑表名
+	Pattern string // 分表名模式
+	Key     string // 分片键，为空时不检查跨分片查询
+	re      *regexp.Regexp
+}
+
+var shardTables struct {
+	sync.Mutex
+	conf   string
+	tables []ShardTable
+}
+
+// ParseShardTables 解析 -shard-tables 配置
+func ParseShardTables(conf []string) ([]ShardTable, error) {
+	var tables []ShardTable
+	for _, c := range conf {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		var t ShardTable
+		if i := strings.Index(c, "="); i >= 0 {
+			t.Logical, c = strings.TrimSpace(c[:i]), c[i+1:]
+		}
+		if i := strings.LastIndex(c, ":"); i >= 0 {
+			c, t.Key = c[:i], strings.TrimSpace(c[i+1:])
+		}
+		t.Pattern = strings.TrimSpace(c)
+		if !strings.Contains(t.Pattern, "%") {
+			return nil, fmt.Errorf("shard table pattern '%s' should contain %%d or %%", t.Pattern)
+		}
+
+		var expr, logical string
+		for i := 0; i < len(t.Pattern); i++ {
+			switch {
+			case strings.HasPrefix(t.Pattern[i:], "%d"):
+				expr += `\d+`
+				i++
+			case t.Pattern[i] == '%':
+				expr += `.*`
+			default:
+				expr += regexp.QuoteMeta(t.Pattern[i : i+1])
+				logical += t.Pattern[i : i+1]
+			}
+		}
+		t.re = regexp.MustCompile(`(?i)^` + expr + `$`)
+		if t.Logical == "" {
+			t.Logical = strings.Trim(logical, "_")
+		}
+		if t.Logical == "" {
+			return nil, fmt.Errorf("shard table pattern '%s' need a logical table name", t.Pattern)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// ShardTables 当前配置中的分表，配置有误时忽略
+func ShardTables() []ShardTable {
+	shardTables.Lock()
+	defer shardTables.Unlock()
+	conf := strings.Join(Config.ShardTables, ",")
+	if conf != shardTables.conf || shardTables.tables == nil {
+		tables, err := ParseShardTables(Config.ShardTables)
+		LogIfWarn(err, "")
+		shardTables.conf = conf
+		shardTables.tables = append([]ShardTable{}, tables...)
+	}
+	return shardTables.tables
+}
+
+// Match 判断表名是否为该配置中的物理分表
+func (t ShardTable) Match(table string) bool {
+	return t.re.MatchString(table)
+}
+
+// FindShardTable 根据物理分表名或逻辑表名查找分表配置，isLogical 表示 table 为逻辑表名
+func FindShardTable(table string) (shard *ShardTable, isLogical bool) {
+	tables := ShardTables()
+	for i := range tables {
+		if strings.EqualFold(tables[i].Logical, table) {
+			return &tables[i], true
+		}
+	}
+	for i := range tables {
+		if tables[i].Match(table) {
+			return &tables[i], false
+		}
+	}
+	return nil, false
+}
+
+// LogicalTableName 物理分表返回对应的逻辑表名，其他表名原样返回
+func LogicalTableName(table string) string {
+	if shard, isLogical := FindShardTable(table); shard != nil && !isLogical {
+		return shard.Logical
+	}
+	return table
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"testing"
+)
+
+func TestParseShardTables(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	tables, err := ParseShardTables([]string{"user=user_%d:uid", "log_2024%:created_at", "", "order_%d_%d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []ShardTable{
+		{Logical: "user", Pattern: "user_%d", Key: "uid"},
+		{Logical: "log_2024", Pattern: "log_2024%", Key: "created_at"},
+		{Logical: "order", Pattern: "order_%d_%d"},
+	}
+	if len(tables) != len(expects) {
+		t.Fatalf("want %d shard tables, got %d", len(expects), len(tables))
+	}
+	for i, e := range expects {
+		if tables[i].Logical != e.Logical || tables[i].Pattern != e.Pattern || tables[i].Key != e.Key {
+			t.Errorf("want %+v, got %+v", e, tables[i])
+		}
+	}
+	if !tables[0].Match("user_01") || tables[0].Match("user_info") || tables[0].Match("user") ||
+		!tables[1].Match("log_202401") || !tables[2].Match("ORDER_2024_01") {
+		t.Error("shard table match error")
+	}
+
+	for _, conf := range []string{"user", "%d:uid"} {
+		if _, err = ParseShardTables([]string{conf}); err == nil {
+			t.Errorf("%s should be invalid", conf)
+		}
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestLogicalTableName(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	orgShardTables := Config.ShardTables
+	Config.ShardTables = []string{"user=user_%d:uid"}
+	for table, logical := range map[string]string{
+		"user_01":   "user",
+		"user":      "user",
+		"user_info": "user_info",
+	} {
+		if got := LogicalTableName(table); got != logical {
+			t.Errorf("LogicalTableName(%s) want %s, got %s", table, logical, got)
+		}
+	}
+	if shard, isLogical := FindShardTable("USER"); shard == nil || !isLogical {
+		t.Error("USER should be logical table")
+	}
+	Config.ShardTables = orgShardTables
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
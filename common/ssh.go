@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SSH 跳板机支持：通过系统 ssh 客户端的 `-W` 参数将 MySQL 连接转发到只能通过跳板机访问的数据库
+// 需要安装 OpenSSH 客户端，且 ssh 命令在 PATH 中
+// 每个跳板机配置会以 ssh_xxxxxxxx 为名注册到 go-sql-driver，FormatDSN 生成的 DSN 中 net 即为该名称
+
+// sshTunnel 跳板机配置
+type sshTunnel struct {
+	Host    string // 跳板机地址 host[:port]
+	User    string // 跳板机用户名
+	Key     string // 私钥文件
+	Timeout string // 连接超时时间，取自 Dsn.Timeout
+}
+
+// sshTunnels 已注册的跳板机，key 为 DSN 中的 net 名称
+var sshTunnels = struct {
+	sync.Mutex
+	m map[string]sshTunnel
+}{m: make(map[string]sshTunnel)}
+
+// registerSSHTunnel 为 env 中配置的跳板机注册 Dial 函数，返回 DSN 中使用的 net 名称
+func registerSSHTunnel(env *Dsn) string {
+	tunnel := sshTunnel{
+		Host:    env.SSHHost,
+		User:    env.SSHUser,
+		Key:     env.SSHKey,
+		Timeout: env.Timeout,
+	}
+	name := fmt.Sprintf("ssh_%x", md5.Sum([]byte(strings.Join([]string{tunnel.Host, tunnel.User, tunnel.Key, tunnel.Timeout}, "\x00"))))[:12]
+
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	if _, ok := sshTunnels.m[name]; !ok {
+		sshTunnels.m[name] = tunnel
+		mysql.RegisterDial(name, tunnel.dial)
+	}
+	return name
+}
+
+// lookupSSHTunnel 根据 DSN 中的 net 名称查找已注册的跳板机
+func lookupSSHTunnel(name string) (sshTunnel, bool) {
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	tunnel, ok := sshTunnels.m[name]
+	return tunnel, ok
+}
+
+// args 生成 ssh 命令行参数，addr 为数据库地址 host:port
+func (t sshTunnel) args(addr string) []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "ExitOnForwardFailure=yes"}
+	if timeout, err := time.ParseDuration(t.Timeout); err == nil && timeout >= time.Second {
+		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", int(timeout.Seconds())))
+	}
+	host := t.Host
+	if h, port, err := net.SplitHostPort(t.Host); err == nil {
+		host = h
+		args = append(args, "-p", port)
+	}
+	if t.User != "" {
+		args = append(args, "-l", t.User)
+	}
+	if t.Key != "" {
+		args = append(args, "-i", t.Key)
+	}
+	return append(args, "-W", addr, host)
+}
+
+// dial 启动 ssh 进程，将其标准输入输出作为到数据库的连接
+func (t sshTunnel) dial(addr string) (net.Conn, error) {
+	ssh, err := exec.LookPath("ssh")
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel %s requires the ssh client: %v", t.Host, err)
+	}
+	// 使用 os.Pipe 而不是 cmd.StdinPipe/StdoutPipe，保留 *os.File 以支持读写超时
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, err
+	}
+	cmd := exec.Command(ssh, t.args(addr)...)
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	err = cmd.Start()
+	// 子进程已持有管道的另一端，父进程中关闭
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, fmt.Errorf("ssh tunnel %s Error: %v", t.Host, err)
+	}
+	return &sshConn{
+		cmd:    cmd,
+		reader: stdoutR,
+		writer: stdinW,
+		local:  sshAddr(t.Host),
+		remote: sshAddr(addr),
+	}, nil
+}
+
+// sshConn 基于 ssh 子进程的 net.Conn 实现，读写超时作用于与 ssh 进程之间的管道
+type sshConn struct {
+	cmd    *exec.Cmd
+	reader *os.File // ssh 进程的标准输出
+	writer *os.File // ssh 进程的标准输入
+	local  net.Addr
+	remote net.Addr
+	once   sync.Once
+}
+
+func (c *sshConn) Read(b []byte) (int, error)  { return c.reader.Read(b) }
+func (c *sshConn) Write(b []byte) (int, error) { return c.writer.Write(b) }
+
+// Close 关闭连接并回收 ssh 进程
+func (c *sshConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		err = c.writer.Close()
+		// ssh 进程可能已退出，Kill 和 Wait 的错误均可忽略
+		if c.cmd != nil {
+			_ = c.cmd.Process.Kill()
+			_ = c.cmd.Wait()
+		}
+		_ = c.reader.Close()
+	})
+	return err
+}
+
+func (c *sshConn) LocalAddr() net.Addr                { return c.local }
+func (c *sshConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *sshConn) SetReadDeadline(t time.Time) error  { return c.reader.SetReadDeadline(t) }
+func (c *sshConn) SetWriteDeadline(t time.Time) error { return c.writer.SetWriteDeadline(t) }
+
+// SetDeadline 同时设置读写超时
+func (c *sshConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// sshAddr 跳板机连接地址
+type sshAddr string
+
+func (a sshAddr) Network() string { return "ssh" }
+func (a sshAddr) String() string  { return string(a) }
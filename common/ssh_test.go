@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSHTunnelArgs(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	tunnels := map[sshTunnel]string{
+		{Host: "bastion"}: "-o BatchMode=yes -o ExitOnForwardFailure=yes -W 10.0.0.1:3306 bastion",
+		{Host: "bastion:2222", User: "ops", Key: "/path/to/id_rsa", Timeout: "3s"}: "-o BatchMode=yes -o ExitOnForwardFailure=yes -o ConnectTimeout=3 -p 2222 -l ops -i /path/to/id_rsa -W 10.0.0.1:3306 bastion",
+	}
+	for tunnel, args := range tunnels {
+		if got := strings.Join(tunnel.args("10.0.0.1:3306"), " "); got != args {
+			t.Errorf("want: %s, got: %s", args, got)
+		}
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestSSHTunnelFormatDSN(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	dsn := ParseDSN("user:password@tcp(10.0.0.1:3306)/dbname?ssh-host=bastion:2222&ssh-user=ops&ssh-key=/path/to/id_rsa", nil)
+	odbc := FormatDSN(dsn)
+	if !strings.HasPrefix(odbc, "user:password@ssh_") || strings.Contains(odbc, "ssh-host") {
+		t.Errorf("FormatDSN with ssh tunnel got: %s", odbc)
+	}
+
+	// FormatDSN 的结果可以再次解析回原来的跳板机配置
+	got := ParseDSN(odbc, nil)
+	if got.Net != "tcp" || got.Addr != "10.0.0.1:3306" || got.SSHHost != "bastion:2222" ||
+		got.SSHUser != "ops" || got.SSHKey != "/path/to/id_rsa" {
+		t.Errorf("ParseDSN(FormatDSN()) got: %+v", got)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestSSHConnDeadline(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &sshConn{reader: r, writer: w}
+	defer conn.Close()
+
+	// 没有数据可读时，超时后 Read 返回超时错误而不是一直阻塞
+	if err = conn.SetDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Read(make([]byte, 1))
+	if !os.IsTimeout(err) {
+		t.Errorf("Read after deadline want timeout, got: %v", err)
+	}
+
+	// 取消超时后可以正常读写
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("x")); err != nil {
+		t.Error(err)
+	}
+	buf := make([]byte, 1)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "x" {
+		t.Errorf("Read got: %q, %v", buf[:n], err)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
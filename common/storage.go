@@ -0,0 +1,276 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 报告上传至对象存储：S3, GCS（HMAC 密钥的 XML API）, OSS 均兼容 S3 协议，统一使用 AWS Signature Version 4 签名
+// MinIO 等自建的 S3 兼容服务通过 storage-endpoint 指定地址
+
+// maxPresignExpires SigV4 预签名 URL 的最长有效期
+const maxPresignExpires = 7 * 24 * time.Hour
+
+// ObjectStorage S3 兼容的对象存储
+type ObjectStorage struct {
+	Bucket    string
+	Prefix    string // 对象名前缀，不以 / 开头及结尾
+	Endpoint  string // 如 https://s3.us-east-1.amazonaws.com
+	Region    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // 使用 endpoint/bucket/key 形式的地址，否则为 bucket.endpoint/key
+	Scheme    string // report-storage 的 scheme：s3, gs 或 oss
+
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewObjectStorage 根据 report-storage 及相关配置创建对象存储，storage 格式为 s3://bucket/prefix, gs://bucket/prefix 或 oss://bucket/prefix
+// 未配置 access key 时使用 AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY 环境变量
+func NewObjectStorage(storage string) (*ObjectStorage, error) {
+	u, err := url.Parse(storage)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("report-storage format error: '%s', e.g. s3://bucket/prefix", storage)
+	}
+	s := &ObjectStorage{
+		Bucket:    u.Host,
+		Prefix:    strings.Trim(u.Path, "/"),
+		Scheme:    u.Scheme,
+		Region:    Config.StorageRegion,
+		AccessKey: Config.StorageAccessKey,
+		SecretKey: Config.StorageSecretKey,
+		client:    &http.Client{Timeout: 60 * time.Second},
+		now:       time.Now,
+	}
+	if s.AccessKey == "" {
+		s.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if s.SecretKey == "" {
+		s.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	switch u.Scheme {
+	case "s3":
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		s.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.Region)
+	case "gs":
+		// GCS 的 XML API 兼容 S3，区域固定为 auto
+		if s.Region == "" {
+			s.Region = "auto"
+		}
+		s.Endpoint = "https://storage.googleapis.com"
+	case "oss":
+		if s.Region == "" {
+			return nil, fmt.Errorf("storage-region is required for oss, e.g. oss-cn-hangzhou")
+		}
+		s.Endpoint = fmt.Sprintf("https://%s.aliyuncs.com", s.Region)
+	default:
+		return nil, fmt.Errorf("report-storage scheme not support: '%s', should be s3, gs or oss", u.Scheme)
+	}
+	if Config.StorageEndpoint != "" {
+		s.Endpoint = strings.TrimRight(Config.StorageEndpoint, "/")
+		// 自建的 S3 兼容服务一般不支持 bucket 作为子域名
+		s.PathStyle = !strings.HasSuffix(s.host(""), ".amazonaws.com") &&
+			!strings.HasSuffix(s.host(""), ".googleapis.com") &&
+			!strings.HasSuffix(s.host(""), ".aliyuncs.com")
+	}
+	return s, nil
+}
+
+// host 返回访问 bucket 使用的主机名，bucket 为空时返回 endpoint 的主机名
+func (s *ObjectStorage) host(bucket string) string {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return ""
+	}
+	if bucket == "" || s.PathStyle {
+		return u.Host
+	}
+	return bucket + "." + u.Host
+}
+
+// Key 返回带前缀的对象名
+func (s *ObjectStorage) Key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+// objectURL 返回对象的访问地址
+func (s *ObjectStorage) objectURL(key string) *url.URL {
+	u, _ := url.Parse(s.Endpoint)
+	u.Host = s.host(s.Bucket)
+	u.Path = "/" + key
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/" + key
+	}
+	return u
+}
+
+// Put 上传对象，retention 大于 0 时配合 bucket 的生命周期规则在 retention 天后删除
+// GCS 不支持对象标签，将 Custom-Time 设为过期时间，配合 daysSinceCustomTime 为 0 的生命周期规则删除，其他存储添加 retention-days 标签
+func (s *ObjectStorage) Put(key string, body []byte, contentType string, retention int) error {
+	u := s.objectURL(key)
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if retention > 0 {
+		if s.Scheme == "gs" {
+			req.Header.Set("X-Goog-Custom-Time", s.now().UTC().AddDate(0, 0, retention).Format(time.RFC3339))
+		} else {
+			req.Header.Set("X-Amz-Tagging", fmt.Sprintf("retention-days=%d", retention))
+		}
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("put %s: %s %s", u.String(), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// URL 返回对象的访问地址，expires 大于 0 时返回预签名 URL，有效期最长 7 天
+func (s *ObjectStorage) URL(key string, expires time.Duration) string {
+	u := s.objectURL(key)
+	if expires <= 0 {
+		return u.String()
+	}
+	if expires > maxPresignExpires {
+		expires = maxPresignExpires
+	}
+	t := s.now().UTC()
+	scope := s.scope(t)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", fmt.Sprint(int64(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(query)
+
+	canonical := strings.Join([]string{"GET", uriEncode(u.Path, false), u.RawQuery,
+		"host:" + u.Host + "\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(t, canonical)
+	return u.String()
+}
+
+// sign 使用 Authorization 头对请求签名
+func (s *ObjectStorage) sign(req *http.Request, body []byte) {
+	t := s.now().UTC()
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders []string
+	for _, name := range names {
+		canonicalHeaders = append(canonicalHeaders, name+":"+headers[name]+"\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical := strings.Join([]string{req.Method, uriEncode(req.URL.Path, false), canonicalQuery(req.URL.Query()),
+		strings.Join(canonicalHeaders, ""), signed, hex.EncodeToString(payload[:])}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, s.scope(t), signed, s.signature(t, canonical)))
+}
+
+// scope 签名的有效范围
+func (s *ObjectStorage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature 计算 canonical request 的签名
+func (s *ObjectStorage) signature(t time.Time, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", t.Format("20060102T150405Z"), s.scope(t), hex.EncodeToString(hash[:])}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery 按 key 排序并使用 SigV4 规定的编码方式拼接查询参数
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			buf = append(buf, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(buf, "&")
+}
+
+// uriEncode SigV4 规定的 URI 编码，除 A-Z, a-z, 0-9, '-', '.', '_', '~' 外都需要编码，encodeSlash 为 false 时路径中的 '/' 不编码
+func uriEncode(s string, encodeSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
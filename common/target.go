@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Target -target 指定的目标数据库类型及版本，用于调整依赖版本的建议
+type Target struct {
+	Flavor  string // mysql, mariadb
+	Version int    // 与 Dsn.Version 格式相同，如 80023, 100602，未指定版本时为 0
+}
+
+// ParseTarget 解析 -target 配置，格式为 flavor[:version]，如 mysql:8.0.23, mariadb:10.6
+func ParseTarget(target string) (Target, error) {
+	var t Target
+	target = strings.ToLower(strings.TrimSpace(target))
+	if target == "" {
+		return t, nil
+	}
+	flavor, version := target, ""
+	if i := strings.Index(target, ":"); i >= 0 {
+		flavor, version = target[:i], target[i+1:]
+	}
+	switch flavor {
+	case "mysql", "mariadb":
+		t.Flavor = flavor
+	default:
+		return Target{}, fmt.Errorf("target '%s' not support, available: mysql, mariadb", flavor)
+	}
+	if version == "" {
+		return t, nil
+	}
+
+	// 8.0.23 => 80023, 10.6 => 100600
+	seg := strings.Split(version, ".")
+	if len(seg) > 3 {
+		return Target{}, fmt.Errorf("target version '%s' format error, e.g. mariadb:10.6", version)
+	}
+	for i := 0; i < 3; i++ {
+		t.Version *= 100
+		if i >= len(seg) {
+			continue
+		}
+		v, err := strconv.Atoi(seg[i])
+		if err != nil || v < 0 || v > 99 && i > 0 {
+			return Target{}, fmt.Errorf("target version '%s' format error, e.g. mariadb:10.6", version)
+		}
+		t.Version += v
+	}
+	return t, nil
+}
+
+// TargetDB 返回 -target 指定的目标数据库，配置错误时按未指定处理
+func TargetDB() Target {
+	t, err := ParseTarget(Config.Target)
+	LogIfWarn(err, "")
+	return t
+}
+
+// IsMariaDB 目标数据库是否为 MariaDB
+func (t Target) IsMariaDB() bool {
+	return t.Flavor == "mariadb"
+}
+
+// Supports 判断目标数据库是否支持某个特性，mysql, mariadb 分别为支持该特性的最低版本，0 表示不支持
+// 未指定 -target 或版本时无法确定，返回 false，与未区分版本时给出的建议保持一致
+func (t Target) Supports(mysql, mariadb int) bool {
+	since := mysql
+	if t.IsMariaDB() {
+		since = mariadb
+	}
+	return t.Flavor != "" && t.Version > 0 && since > 0 && t.Version >= since
+}
+
+// Unsupported 判断目标数据库是否确定不支持某个特性，参数含义同 Supports
+// 未指定版本时只检查该类型数据库完全不支持的特性
+func (t Target) Unsupported(mysql, mariadb int) bool {
+	since := mysql
+	if t.IsMariaDB() {
+		since = mariadb
+	}
+	return t.Flavor != "" && (since == 0 || t.Version > 0 && t.Version < since)
+}
+
+// HasSQLMode 判断 -sql-mode 中是否包含指定的模式，如 ONLY_FULL_GROUP_BY
+func HasSQLMode(mode string) bool {
+	for _, m := range strings.Split(Config.SQLMode, ",") {
+		if strings.EqualFold(strings.TrimSpace(m), mode) {
+			return true
+		}
+	}
+	return false
+}
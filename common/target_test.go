@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	for conf, expect := range map[string]Target{
+		"":              {},
+		"mysql":         {Flavor: "mysql"},
+		"MySQL:8.0.23":  {Flavor: "mysql", Version: 80023},
+		"mariadb:10.6":  {Flavor: "mariadb", Version: 100600},
+		"mariadb:10.11": {Flavor: "mariadb", Version: 101100},
+		"mysql:5.7":     {Flavor: "mysql", Version: 50700},
+	} {
+		target, err := ParseTarget(conf)
+		if err != nil {
+			t.Error(conf, err)
+		}
+		if target != expect {
+			t.Errorf("%s want %+v, got %+v", conf, expect, target)
+		}
+	}
+
+	for _, conf := range []string{"oracle:19", "mysql:8.x", "mariadb:10.6.1.2", "mysql:8.100"} {
+		if _, err := ParseTarget(conf); err == nil {
+			t.Errorf("%s should be invalid", conf)
+		}
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestTargetSupports(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	cases := []struct {
+		target      Target
+		supports    bool
+		unsupported bool
+	}{
+		{Target{}, false, false},
+		{Target{Flavor: "mysql"}, false, false},
+		{Target{Flavor: "mysql", Version: 50744}, false, true},
+		{Target{Flavor: "mysql", Version: 80023}, true, false},
+		{Target{Flavor: "mariadb"}, false, false},
+		{Target{Flavor: "mariadb", Version: 100100}, false, true},
+		{Target{Flavor: "mariadb", Version: 100600}, true, false},
+	}
+	// 窗口函数：MySQL 8.0, MariaDB 10.2
+	for _, c := range cases {
+		if c.target.Supports(80000, 100200) != c.supports || c.target.Unsupported(80000, 100200) != c.unsupported {
+			t.Errorf("%+v want supports %v, unsupported %v", c.target, c.supports, c.unsupported)
+		}
+	}
+
+	// MariaDB 不支持的特性，未指定版本时也能确定
+	if !(Target{Flavor: "mariadb"}).Unsupported(80000, 0) || (Target{Flavor: "mysql"}).Unsupported(80000, 0) {
+		t.Error("unsupported feature error")
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
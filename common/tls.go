@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS 连接支持：根据 Dsn 中的证书配置生成 tls.Config 并以 tls_xxxxxxxx 为名注册到 go-sql-driver
+// FormatDSN 生成的 DSN 中 tls 参数即为该名称
+
+// tlsOptions TLS 证书配置
+type tlsOptions struct {
+	CA         string // CA 证书文件
+	Cert       string // 客户端证书文件
+	Key        string // 客户端私钥文件
+	SkipVerify bool   // 不校验服务端证书
+	ServerName string // 校验服务端证书时使用的主机名
+}
+
+// tlsConfigs 已注册的 TLS 配置，key 为 DSN 中的 tls 参数
+var tlsConfigs = struct {
+	sync.Mutex
+	m map[string]tlsOptions
+}{m: make(map[string]tlsOptions)}
+
+// tlsOptions 获取 Dsn 中的 TLS 证书配置，未配置证书时返回 false
+func (env *Dsn) tlsOptions() (tlsOptions, bool) {
+	opt := tlsOptions{
+		CA:         env.TLSCA,
+		Cert:       env.TLSCert,
+		Key:        env.TLSKey,
+		SkipVerify: env.TLSSkipVerify,
+		ServerName: env.TLSServerName,
+	}
+	return opt, opt.CA != "" || opt.Cert != "" || opt.Key != "" || opt.ServerName != ""
+}
+
+// registerTLSConfig 为 env 中的证书配置注册 tls.Config，返回 DSN 中使用的 tls 参数
+func registerTLSConfig(env *Dsn) (string, error) {
+	opt, ok := env.tlsOptions()
+	if !ok {
+		if env.TLSSkipVerify {
+			return "skip-verify", nil
+		}
+		return env.TLS, nil
+	}
+	name := fmt.Sprintf("tls_%x", md5.Sum([]byte(strings.Join([]string{opt.CA, opt.Cert, opt.Key,
+		strconv.FormatBool(opt.SkipVerify), opt.ServerName}, "\x00"))))[:12]
+
+	tlsConfigs.Lock()
+	defer tlsConfigs.Unlock()
+	if _, ok := tlsConfigs.m[name]; ok {
+		return name, nil
+	}
+	cfg, err := opt.config()
+	if err != nil {
+		return "", err
+	}
+	err = mysql.RegisterTLSConfig(name, cfg)
+	if err != nil {
+		return "", err
+	}
+	tlsConfigs.m[name] = opt
+	return name, nil
+}
+
+// lookupTLSConfig 根据 DSN 中的 tls 参数查找已注册的证书配置
+func lookupTLSConfig(name string) (tlsOptions, bool) {
+	tlsConfigs.Lock()
+	defer tlsConfigs.Unlock()
+	opt, ok := tlsConfigs.m[name]
+	return opt, ok
+}
+
+// config 读取证书文件生成 tls.Config
+func (opt tlsOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: opt.SkipVerify,
+		ServerName:         opt.ServerName,
+	}
+
+	if opt.CA != "" {
+		pem, err := ioutil.ReadFile(opt.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls-ca: %s no valid PEM certificate", opt.CA)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opt.Cert != "" || opt.Key != "" {
+		cert, err := tls.LoadX509KeyPair(opt.Cert, opt.Key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert 生成自签名证书及私钥文件
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "soar"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	opt := tlsOptions{CA: certFile, Cert: certFile, Key: keyFile, ServerName: "mysql.example.com"}
+	cfg, err := opt.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RootCAs == nil || len(cfg.Certificates) != 1 || cfg.ServerName != "mysql.example.com" {
+		t.Errorf("tls config got: %+v", cfg)
+	}
+
+	// 证书文件不存在
+	opt.CA = filepath.Join(dir, "not-exist.pem")
+	if _, err = opt.config(); err == nil {
+		t.Error("tls config with not exist CA file should return error")
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
+
+func TestTLSFormatDSN(t *testing.T) {
+	Log.Debug("Entering function: %s", GetFunctionName())
+	dir, err := ioutil.TempDir("", "soar-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	dsn := ParseDSN("user:password@tcp(10.0.0.1:3306)/dbname?tls-ca="+certFile+"&tls-cert="+certFile+"&tls-key="+keyFile, nil)
+	odbc := FormatDSN(dsn)
+	if !strings.Contains(odbc, "tls=tls_") || strings.Contains(odbc, "tls-ca") {
+		t.Errorf("FormatDSN with tls got: %s", odbc)
+	}
+
+	// FormatDSN 的结果可以再次解析回原来的证书配置
+	got := ParseDSN(odbc, nil)
+	if got.TLS != "" || got.TLSCA != certFile || got.TLSCert != certFile || got.TLSKey != keyFile {
+		t.Errorf("ParseDSN(FormatDSN()) got: %+v", got)
+	}
+
+	// 只配置 tls-skip-verify 时使用 go-sql-driver 内置的 skip-verify
+	dsn = ParseDSN("user:password@tcp(10.0.0.1:3306)/dbname?tls-skip-verify=true", nil)
+	if odbc = FormatDSN(dsn); !strings.Contains(odbc, "tls=skip-verify") {
+		t.Errorf("FormatDSN with tls-skip-verify got: %s", odbc)
+	}
+	Log.Debug("Exiting function: %s", GetFunctionName())
+}
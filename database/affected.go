@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/XiaoMi/soar/ast"
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// AffectedRowsSQL 将 UPDATE, DELETE 转换为统计影响行数的 SELECT COUNT(*)，不是 UPDATE, DELETE 时返回空
+// 内层查询最多读取 limit 行，避免在线上环境为统计行数扫描整张表，结果等于 limit 时表示至少影响 limit 行
+func AffectedRowsSQL(sql string, limit int64) string {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return ""
+	}
+	switch stmt.(type) {
+	case *sqlparser.Update, *sqlparser.Delete:
+	default:
+		return ""
+	}
+	rw := ast.NewRewrite(sql)
+	if rw == nil {
+		return ""
+	}
+	sel, ok := rw.RewriteDML2Select().Stmt.(*sqlparser.Select)
+	if !ok {
+		return ""
+	}
+	sel.SelectExprs = sqlparser.SelectExprs{&sqlparser.AliasedExpr{Expr: sqlparser.NewIntVal([]byte("1"))}}
+	// 带 LIMIT 的 UPDATE, DELETE 最多影响 LIMIT 行，统计行数时与 ORDER BY 无关
+	sel.OrderBy = nil
+	if !limitWithin(sel.Limit, limit) {
+		sel.Limit = &sqlparser.Limit{Rowcount: sqlparser.NewIntVal([]byte(strconv.FormatInt(limit, 10)))}
+	}
+	return "select count(*) from (" + sqlparser.String(sel) + ") as affected_rows"
+}
+
+// limitWithin 判断 LIMIT 是否不超过 max
+func limitWithin(limit *sqlparser.Limit, max int64) bool {
+	if limit == nil || limit.Offset != nil {
+		return false
+	}
+	val, ok := limit.Rowcount.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return false
+	}
+	n, err := strconv.ParseInt(string(val.Val), 10, 64)
+	return err == nil && n <= max
+}
+
+// AffectedRows 执行 AffectedRowsSQL 统计 UPDATE, DELETE 影响的行数，返回值等于 limit 时表示至少影响 limit 行
+// 执行时间受 query-timeout 限制
+func (db *Connector) AffectedRows(sql string, limit int64) (int64, error) {
+	countSQL := AffectedRowsSQL(sql, limit)
+	if countSQL == "" {
+		return 0, errors.New("not UPDATE or DELETE statement")
+	}
+	res, err := db.Query(countSQL)
+	if err != nil {
+		return 0, err
+	}
+	var rows int64
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&rows)
+	}
+	if err := res.Rows.Close(); err != nil {
+		common.Log.Error(err.Error())
+	}
+	return rows, err
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestAffectedRowsSQL(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	for sql, expect := range map[string]string{
+		"UPDATE film SET length = 10 WHERE language_id = 20":                    "select count(*) from (select 1 from film where language_id = 20 limit 1000) as affected_rows",
+		"DELETE FROM film WHERE length > 100 ORDER BY film_id LIMIT 10":         "select count(*) from (select 1 from film where length > 100 limit 10) as affected_rows",
+		"DELETE FROM film WHERE length > 100 LIMIT 5000":                        "select count(*) from (select 1 from film where length > 100 limit 1000) as affected_rows",
+		"DELETE city FROM city JOIN country USING (country_id) WHERE city_id=1": "select count(*) from (select 1 from city join country using (country_id) where city_id = 1 limit 1000) as affected_rows",
+		"SELECT * FROM film":                    "",
+		"INSERT INTO film (film_id) VALUES (1)": "",
+	} {
+		if got := AffectedRowsSQL(sql, 1000); got != expect {
+			t.Errorf("AffectedRowsSQL(%q)\nwant: %s\ngot: %s", sql, expect, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// TableSnapshot 某一时刻表的大小、行数、自增值及分区数，用于预测表的增长趋势
+type TableSnapshot struct {
+	Time              time.Time
+	Database          string
+	Table             string
+	Rows              uint64 // information_schema.TABLES.TABLE_ROWS，InnoDB 中为预估值
+	DataLength        uint64
+	IndexLength       uint64
+	AutoIncrement     uint64 // 下一个自增值，没有自增列时为 0
+	AutoIncrementCol  string // 自增列名
+	AutoIncrementType string // 自增列的类型，如 int(10) unsigned
+	Partitions        int    // 分区数，非分区表为 0
+}
+
+// TableSnapshots 从 information_schema 获取当前 database 中所有表的大小快照
+// MySQL 8.0 中 information_schema.TABLES 的统计信息有缓存（information_schema_stats_expiry），快照可能滞后于实际值
+func (db *Connector) TableSnapshots() ([]TableSnapshot, error) {
+	var snaps []TableSnapshot
+	res, err := db.Query(fmt.Sprintf(`SELECT T.TABLE_NAME, IFNULL(T.TABLE_ROWS, 0), IFNULL(T.DATA_LENGTH, 0), IFNULL(T.INDEX_LENGTH, 0), IFNULL(T.AUTO_INCREMENT, 0),
+		IFNULL(C.COLUMN_NAME, ''), IFNULL(C.COLUMN_TYPE, ''),
+		(SELECT COUNT(*) FROM INFORMATION_SCHEMA.PARTITIONS P WHERE P.TABLE_SCHEMA = T.TABLE_SCHEMA AND P.TABLE_NAME = T.TABLE_NAME AND P.PARTITION_NAME IS NOT NULL)
+		FROM INFORMATION_SCHEMA.TABLES T LEFT JOIN INFORMATION_SCHEMA.COLUMNS C
+		ON T.TABLE_SCHEMA = C.TABLE_SCHEMA AND T.TABLE_NAME = C.TABLE_NAME AND C.EXTRA LIKE '%%auto_increment%%'
+		WHERE T.TABLE_SCHEMA = '%s' AND T.TABLE_TYPE = 'BASE TABLE' ORDER BY T.TABLE_NAME`, Escape(db.Database, false)))
+	if err != nil {
+		return snaps, err
+	}
+	defer res.Rows.Close()
+
+	now := time.Now()
+	for res.Rows.Next() {
+		snap := TableSnapshot{Time: now, Database: db.Database}
+		err = res.Rows.Scan(&snap.Table, &snap.Rows, &snap.DataLength, &snap.IndexLength, &snap.AutoIncrement,
+			&snap.AutoIncrementCol, &snap.AutoIncrementType, &snap.Partitions)
+		if err != nil {
+			return snaps, err
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, res.Rows.Err()
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestTableSnapshots(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	snaps, err := connTest.TableSnapshots()
+	if err != nil {
+		t.Error(err)
+	}
+	found := false
+	for _, snap := range snaps {
+		if snap.Table == "film" {
+			found = true
+			if snap.AutoIncrement == 0 || snap.AutoIncrementCol != "film_id" || snap.AutoIncrementType != "smallint(5) unsigned" || snap.Partitions != 0 {
+				t.Errorf("unexpected sakila.film snapshot: %+v", snap)
+			}
+		}
+		if snap.Table == "film_list" {
+			t.Error("views should be skipped")
+		}
+	}
+	if !found {
+		t.Errorf("sakila.film not found: %v", snaps)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
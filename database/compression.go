@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TableIOStat performance_schema.table_io_waits_summary_by_table 中单表的读写次数，实例重启后清零
+type TableIOStat struct {
+	Read   uint64 // COUNT_READ
+	Insert uint64 // COUNT_INSERT
+	Update uint64 // COUNT_UPDATE
+	Delete uint64 // COUNT_DELETE
+}
+
+// Writes 写入次数
+func (s TableIOStat) Writes() uint64 {
+	return s.Insert + s.Update + s.Delete
+}
+
+// TableIOStat 从 performance_schema 中获取表的读写次数，performance_schema 未开启或没有统计时返回 nil
+func (db *Connector) TableIOStat(tb string) (*TableIOStat, error) {
+	if ps, err := db.SingleIntValue("performance_schema"); err != nil || ps != 1 {
+		return nil, err
+	}
+	res, err := db.Query(fmt.Sprintf(`select count_read, count_insert, count_update, count_delete
+		from performance_schema.table_io_waits_summary_by_table where object_schema = '%s' and object_name = '%s'`,
+		Escape(db.Database, false), Escape(tb, false)))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Rows.Close()
+
+	if !res.Rows.Next() {
+		return nil, res.Rows.Err()
+	}
+	stat := &TableIOStat{}
+	err = res.Rows.Scan(&stat.Read, &stat.Insert, &stat.Update, &stat.Delete)
+	if err != nil {
+		return nil, err
+	}
+	return stat, nil
+}
+
+// SampleRows 采样表中前 limit 行数据，返回列名及各行的值，NULL 值以空字符串表示
+func (db *Connector) SampleRows(tb string, limit int) ([]string, [][]string, error) {
+	res, err := db.Query(fmt.Sprintf("select * from `%s`.`%s` limit %d",
+		Escape(db.Database, false), Escape(tb, false), limit))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Rows.Close()
+
+	columns, err := res.Rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows [][]string
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for res.Rows.Next() {
+		if err = res.Rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(columns))
+		for i, v := range values {
+			row[i] = string(v)
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, res.Rows.Err()
+}
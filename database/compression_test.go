@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestSampleRows(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	columns, rows, err := connTest.SampleRows("language", 3)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(columns) != 3 || columns[1] != "name" {
+		t.Errorf("sakila.language columns: %v", columns)
+	}
+	if len(rows) != 3 || rows[0][1] != "English" {
+		t.Errorf("sakila.language rows: %v", rows)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestTableIOStat(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	stat, err := connTest.TableIOStat("film")
+	if err != nil {
+		t.Error(err)
+	}
+	if stat != nil && stat.Writes() != stat.Insert+stat.Update+stat.Delete {
+		t.Errorf("sakila.film io stat: %v", stat)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -52,6 +52,7 @@ const (
 	TraditionalExplainType = iota // 默认转出
 	ExtendedExplainType           // EXTENDED输出
 	PartitionsExplainType         // PARTITIONS输出
+	AnalyzeExplainType            // ANALYZE输出，仅 TiDB 方言使用
 )
 
 // ExplainType EXPLAIN命令支持的参数
@@ -59,6 +60,7 @@ var ExplainType = map[string]int{
 	"traditional": 0,
 	"extended":    1,
 	"partitions":  2,
+	"analyze":     3,
 }
 
 // 为TraditionalFormatExplain准备的结构体 { start
@@ -71,6 +73,7 @@ type ExplainInfo struct {
 	ExplainJSON   *ExplainJSON
 	Warnings      []ExplainWarning
 	QueryCost     float64
+	TiDBRows      []TiDBExplainRow // -dialect=tidb 时的执行计划
 }
 
 // ExplainRow 单行Explain
@@ -547,6 +550,7 @@ func (db *Connector) explainAbleSQL(sql string) (string, error) {
 		if need {
 			rw := ast.NewRewrite(sql)
 			if rw != nil {
+				rw.UniqueKeys = db.insertUniqueKeys(stmt)
 				return rw.RewriteDML2Select().NewSQL, nil
 			}
 		}
@@ -559,6 +563,24 @@ func (db *Connector) explainAbleSQL(sql string) (string, error) {
 	return "", nil
 }
 
+// insertUniqueKeys 获取 REPLACE, INSERT ... ON DUPLICATE KEY UPDATE 目标表的主键及唯一键，用于 dml2select 按唯一键查找冲突的行
+// 目标表不在当前库中或获取失败时返回空，dml2select 按写入的所有列查找
+func (db *Connector) insertUniqueKeys(stmt sqlparser.Statement) [][]string {
+	ins, ok := stmt.(*sqlparser.Insert)
+	if !ok || (ins.Action != sqlparser.ReplaceStr && len(ins.OnDup) == 0) {
+		return nil
+	}
+	if !ins.Table.Qualifier.IsEmpty() && !strings.EqualFold(ins.Table.Qualifier.String(), db.Database) {
+		return nil
+	}
+	index, err := db.ShowIndex(ins.Table.Name.String())
+	if err != nil {
+		common.Log.Debug("insertUniqueKeys ShowIndex Error: %v", err)
+		return nil
+	}
+	return index.UniqueKeys()
+}
+
 // explainQuery 生成可执行的 explain 查询请求
 func (db *Connector) explainQuery(sql string, explainType int, formatType int) string {
 	var err error
@@ -609,18 +631,49 @@ func MySQLExplainWarnings(exp *ExplainInfo) string {
 	return content
 }
 
+// Cost 返回查询代价，优先使用 last_query_cost，没有时使用 EXPLAIN FORMAT=JSON 中的 query_cost
+func (exp *ExplainInfo) Cost() float64 {
+	if exp == nil {
+		return 0
+	}
+	if exp.QueryCost > 0 {
+		return exp.QueryCost
+	}
+	if exp.ExplainJSON != nil {
+		cost, err := strconv.ParseFloat(exp.ExplainJSON.QueryBlock.CostInfo.QueryCost, 64)
+		if err == nil {
+			return cost
+		}
+	}
+	return 0
+}
+
+// QueryCost 通过 EXPLAIN FORMAT=JSON 获取 SQL 的查询代价，用于比较改写或添加索引前后的变化
+// 不依赖 last_query_cost，避免连接池中 SHOW STATUS 与 EXPLAIN 不在同一个连接上执行
+func (db *Connector) QueryCost(sql string) (float64, error) {
+	exp, err := db.Explain(sql, TraditionalExplainType, JSONFormatExplain)
+	if err != nil {
+		return 0, err
+	}
+	cost := exp.Cost()
+	if cost <= 0 {
+		return 0, fmt.Errorf("query cost not available: %s", sql)
+	}
+	return cost, nil
+}
+
 // MySQLExplainQueryCost 将last_query_cost信息补充到评审结果中
 func MySQLExplainQueryCost(exp *ExplainInfo) string {
 	var content string
 	if exp == nil {
 		return content
 	}
-	if exp.QueryCost > 0 {
+	if cost := exp.Cost(); cost > 0 {
 
-		tmp := fmt.Sprintf("%.3f\n", exp.QueryCost)
+		tmp := fmt.Sprintf("%.3f\n", cost)
 
 		content = "Query cost: "
-		if exp.QueryCost > float64(common.Config.MaxQueryCost) {
+		if cost > float64(common.Config.MaxQueryCost) {
 			content += fmt.Sprintf("☠️ **%s**", tmp)
 		} else {
 			content += tmp
@@ -734,32 +787,128 @@ func ExplainInfoTranslator(exp *ExplainInfo) string {
 	return strings.Join(buf, "\n")
 }
 
+// explainConsoleNoise 从命令行客户端复制 EXPLAIN 结果时一并复制的提示符、续行符及结果统计行
+var explainConsoleNoise = regexp.MustCompile(`(?i)^\s*(mysql|mariadb( \[.*\])?)>|^\s*->|^\s*(\d+ rows? in set|empty set)\b`)
+
+// trimExplainConsole 去除命令行客户端的提示符及结果统计行
+func trimExplainConsole(content string) string {
+	var lines []string
+	for _, l := range strings.Split(content, "\n") {
+		if explainConsoleNoise.MatchString(l) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(l, "\r"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
 // ParseExplainText 解析explain文本信息（很可能是用户复制粘贴得到），返回格式化数据
+// 自动识别表格形式、\G 纵向形式及 FORMAT=JSON 的输出，JSON 可以是纯文本，也可以是表格或 \G 输出中的 EXPLAIN 列
 func ParseExplainText(content string) (exp *ExplainInfo, err error) {
 	exp = &ExplainInfo{ExplainFormat: TraditionalFormatExplain}
 
-	content = strings.TrimSpace(content)
-	verticalFormat := strings.HasPrefix(content, "*")
-	jsonFormat := strings.HasPrefix(content, "{")
-	traditionalFormat := strings.HasPrefix(content, "+")
+	content = trimExplainConsole(content)
+	switch {
+	case strings.HasPrefix(content, "{"):
+		exp.ExplainFormat = JSONFormatExplain
+		exp.ExplainJSON, err = parseJSONExplainText(content)
+	case strings.HasPrefix(content, "*"):
+		if js := verticalExplainJSON(content); js != "" {
+			exp.ExplainFormat = JSONFormatExplain
+			exp.ExplainJSON, err = parseJSONExplainText(js)
+			return exp, err
+		}
+		exp.ExplainRows, err = parseVerticalExplainText(content)
+	case strings.HasPrefix(content, "+"):
+		lines := strings.SplitN(content, "\n", 3)
+		if len(lines) > 1 && isTiDBExplainHeader(strings.Split(strings.Trim(lines[1], "| "), "|")) {
+			exp.TiDBRows, err = parseTiDBExplainText(content)
+			return exp, err
+		}
+		if js := tableExplainJSON(content); js != "" {
+			exp.ExplainFormat = JSONFormatExplain
+			exp.ExplainJSON, err = parseJSONExplainText(js)
+			return exp, err
+		}
+		exp.ExplainRows, err = parseTraditionalExplainText(content)
+	default:
+		return nil, errors.New("not supported explain format")
+	}
+	return exp, err
+}
+
+// verticalExplainJSON 提取 EXPLAIN FORMAT=JSON ... \G 输出中的 JSON，不是 JSON 格式时返回空
+func verticalExplainJSON(content string) string {
+	lines := strings.SplitN(content, "\n", 2)
+	if len(lines) < 2 {
+		return ""
+	}
+	body := strings.TrimSpace(lines[1])
+	if !strings.HasPrefix(strings.ToUpper(body), "EXPLAIN:") {
+		return ""
+	}
+	return strings.TrimSpace(body[len("EXPLAIN:"):])
+}
 
-	if verticalFormat && traditionalFormat && jsonFormat {
-		return nil, errors.New("not supported explain type")
+// tableExplainJSON 提取表格形式 EXPLAIN FORMAT=JSON 输出中的 JSON，不是 JSON 格式时返回空
+// 命令行客户端不会对单元格中的换行进行处理，JSON 夹在表头之后的分隔线和最后一条分隔线之间
+func tableExplainJSON(content string) string {
+	lines := strings.Split(content, "\n")
+	if len(lines) < 4 || !strings.EqualFold(strings.TrimSpace(strings.Trim(lines[1], "|")), "EXPLAIN") {
+		return ""
 	}
+	body := lines[3:]
+	if last := len(body) - 1; strings.HasPrefix(body[last], "+") {
+		body = body[:last]
+	}
+	js := strings.TrimSpace(strings.Join(body, "\n"))
+	js = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(js, "|"), "|"))
+	return js
+}
 
-	if verticalFormat {
-		exp.ExplainRows, err = parseVerticalExplainText(content)
+// newExplainRow 将表格或 \G 形式中一行 EXPLAIN 的各列转换为 ExplainRow，cols 的 key 为小写的列名
+func newExplainRow(cols map[string]string) (ExplainRow, error) {
+	id, err := strconv.Atoi(cols["id"])
+	if err != nil {
+		return ExplainRow{}, err
 	}
 
-	if jsonFormat {
-		exp.ExplainFormat = JSONFormatExplain
-		exp.ExplainJSON, err = parseJSONExplainText(content)
+	// 不存在字段给默认值
+	partitions := cols["partitions"]
+	if partitions == "" {
+		partitions = "NULL"
 	}
 
-	if traditionalFormat {
-		exp.ExplainRows, err = parseTraditionalExplainText(content)
+	rows, err := strconv.ParseInt(cols["rows"], 10, 64)
+	if err != nil {
+		rows = 0
 	}
-	return exp, err
+
+	filtered, err := strconv.ParseFloat(cols["filtered"], 64)
+	if err != nil {
+		filtered = 0.00
+	}
+	// filtered may larger than 100.00
+	// https://bugs.mysql.com/bug.php?id=34124
+	if filtered >= 100.00 {
+		filtered = 100.00
+	}
+
+	return ExplainRow{
+		ID:           id,
+		SelectType:   cols["select_type"],
+		TableName:    cols["table"],
+		Partitions:   partitions,
+		AccessType:   cols["type"],
+		PossibleKeys: strings.Split(cols["possible_keys"], ","),
+		Key:          cols["key"],
+		KeyLen:       cols["key_len"],
+		Ref:          strings.Split(cols["ref"], ","),
+		Rows:         rows,
+		Filtered:     filtered,
+		Scalability:  ExplainScalability[cols["type"]],
+		Extra:        cols["extra"],
+	}, nil
 }
 
 // 解析文本形式传统形式Explain信息
@@ -782,18 +931,9 @@ func parseTraditionalExplainText(content string) (explainRows []ExplainRow, err
 		colIdx[strings.ToLower(item)] = i
 	}
 
-	// explain format=json未把外面的框去了
-	if strings.ToLower(header[0]) == "explain" {
-		return nil, errors.New("json format explain need remove")
-	}
-
 	// 将每一列填充至ExplainRow结构体
 	colsMap := make(map[string]string)
 	for _, l := range lines[3:] {
-		var keylen string
-		var rows int64
-		var filtered float64
-		var partitions string
 		// 跳过分割线
 		if LS.MatchString(l) || strings.TrimSpace(l) == "" {
 			continue
@@ -804,135 +944,63 @@ func parseTraditionalExplainText(content string) (explainRows []ExplainRow, err
 		for _, c := range strings.Split(strings.Trim(l, "|"), "|") {
 			cols = append(cols, strings.TrimSpace(c))
 		}
+		if len(cols) < len(header) {
+			return nil, fmt.Errorf("explain row column count not match: %s", l)
+		}
 		for item, i := range colIdx {
 			colsMap[item] = cols[i]
 		}
 
-		// 值类型转换
-		id, err := strconv.Atoi(colsMap["id"])
+		row, err := newExplainRow(colsMap)
 		if err != nil {
 			return nil, err
 		}
-
-		// 不存在字段给默认值
-		if colsMap["partitions"] == "" {
-			partitions = "NULL"
-		} else {
-			partitions = colsMap["partitions"]
-		}
-
-		keylen = colsMap["key_len"]
-
-		rows, err = strconv.ParseInt(colsMap["Rows"], 10, 64)
-		if err != nil {
-			rows = 0
-		}
-
-		filtered, err = strconv.ParseFloat(colsMap["filtered"], 64)
-		if err != nil {
-			filtered = 0.00
-		}
-		// filtered may larger than 100.00
-		// https://bugs.mysql.com/bug.php?id=34124
-		if filtered >= 100.00 {
-			filtered = 100.00
-		}
-
-		// 拼接结构体
-		explainRows = append(explainRows, ExplainRow{
-			ID:           id,
-			SelectType:   colsMap["select_type"],
-			TableName:    colsMap["table"],
-			Partitions:   partitions,
-			AccessType:   colsMap["type"],
-			PossibleKeys: strings.Split(colsMap["possible_keys"], ","),
-			Key:          colsMap["key"],
-			KeyLen:       keylen,
-			Ref:          strings.Split(colsMap["ref"], ","),
-			Rows:         rows,
-			Filtered:     filtered,
-			Scalability:  ExplainScalability[colsMap["type"]],
-			Extra:        colsMap["extra"],
-		})
+		explainRows = append(explainRows, row)
 	}
 	return explainRows, nil
 }
 
-// 解析文本形式竖排版 Explain信息
+// 解析文本形式竖排版 Explain信息，即 EXPLAIN ... \G 的输出
 func parseVerticalExplainText(content string) (explainRows []ExplainRow, err error) {
-	var lines []string
-	explainRow := ExplainRow{
-		Partitions: "NULL",
-		Filtered:   0.00,
-	}
 	LS := regexp.MustCompile(`^\*.*\*$`) // 华丽的分隔线:)
 
-	// 格式正确性检查
-	for _, l := range strings.Split(content, "\n") {
-		lines = append(lines, strings.TrimSpace(l))
-	}
-	if len(lines) < 11 {
-		return nil, errors.New("explain rows less than 11")
+	colsMap := make(map[string]string)
+	appendRow := func() error {
+		if len(colsMap) == 0 {
+			return nil
+		}
+		row, err := newExplainRow(colsMap)
+		if err != nil {
+			return err
+		}
+		explainRows = append(explainRows, row)
+		colsMap = make(map[string]string)
+		return nil
 	}
 
-	// 将每一行填充至ExplainRow结构体
-	for _, l := range lines {
-		if LS.MatchString(l) || strings.TrimSpace(l) == "" {
+	// 每条分隔线开始一行 EXPLAIN 信息，每行为 "列名: 值"
+	for _, l := range strings.Split(content, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
 			continue
 		}
-		if strings.HasPrefix(l, "id:") {
-			id := strings.TrimPrefix(l, "id: ")
-			explainRow.ID, err = strconv.Atoi(id)
-			if err != nil {
-				return nil, err
-			}
-		}
-		if strings.HasPrefix(l, "select_type:") {
-			explainRow.SelectType = strings.TrimPrefix(l, "select_type: ")
-		}
-		if strings.HasPrefix(l, "table:") {
-			explainRow.TableName = strings.TrimPrefix(l, "table: ")
-		}
-		if strings.HasPrefix(l, "partitions:") {
-			explainRow.AccessType = strings.TrimPrefix(l, "partitions: ")
-		}
-		if strings.HasPrefix(l, "type:") {
-			explainRow.AccessType = strings.TrimPrefix(l, "type: ")
-			explainRow.Scalability = ExplainScalability[explainRow.AccessType]
-		}
-		if strings.HasPrefix(l, "possible_keys:") {
-			explainRow.PossibleKeys = strings.Split(strings.TrimPrefix(l, "possible_keys: "), ",")
-		}
-		if strings.HasPrefix(l, "key:") {
-			explainRow.Key = strings.TrimPrefix(l, "key: ")
-		}
-		if strings.HasPrefix(l, "key_len:") {
-			keyLen := strings.TrimPrefix(l, "key_len: ")
-			explainRow.KeyLen = keyLen
-		}
-		if strings.HasPrefix(l, "ref:") {
-			explainRow.Ref = strings.Split(strings.TrimPrefix(l, "ref: "), ",")
-		}
-		if strings.HasPrefix(l, "Rows:") {
-			rows := strings.TrimPrefix(l, "Rows: ")
-			explainRow.Rows, err = strconv.ParseInt(rows, 10, 64)
-			if err != nil {
-				explainRow.Rows = 0
-			}
-		}
-		if strings.HasPrefix(l, "filtered:") {
-			filtered := strings.TrimPrefix(l, "filtered: ")
-			explainRow.Filtered, err = strconv.ParseFloat(filtered, 64)
-			if err != nil {
+		if LS.MatchString(l) {
+			if err = appendRow(); err != nil {
 				return nil, err
-			} else if explainRow.Filtered > 100.00 {
-				explainRow.Filtered = 100.00
 			}
+			continue
 		}
-		if strings.HasPrefix(l, "Extra:") {
-			explainRow.Extra = strings.TrimPrefix(l, "Extra: ")
-			explainRows = append(explainRows, explainRow)
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 {
+			continue
 		}
+		colsMap[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+	if err = appendRow(); err != nil {
+		return nil, err
+	}
+	if len(explainRows) == 0 {
+		return nil, errors.New("explain rows not found")
 	}
 	return explainRows, err
 }
@@ -1077,6 +1145,20 @@ func (db *Connector) Explain(sql string, explainType int, formatType int) (exp *
 		}
 	}()
 
+	// TiDB 的执行计划格式与 MySQL 不同，单独解析
+	if common.Config.Dialect == "tidb" {
+		exp.SQL = db.explainTiDBQuery(sql, explainType)
+		if exp.SQL == "" {
+			return exp, nil
+		}
+		res, err := db.Query(exp.SQL)
+		if err != nil {
+			return exp, err
+		}
+		exp, err = ParseTiDBExplainResult(res)
+		return exp, err
+	}
+
 	// 执行EXPLAIN请求
 	exp.SQL = db.explainQuery(sql, explainType, formatType)
 	if exp.SQL == "" {
@@ -2372,6 +2372,81 @@ func TestParseExplainText(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestParseExplainTextFormats(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	vertical := `mysql> EXPLAIN SELECT * FROM film f JOIN language l USING (language_id)\G
+*************************** 1. row ***************************
+           id: 1
+  select_type: SIMPLE
+        table: l
+   partitions: NULL
+         type: ALL
+possible_keys: PRIMARY
+          key: NULL
+      key_len: NULL
+          ref: NULL
+         rows: 6
+     filtered: 100.00
+        Extra: NULL
+*************************** 2. row ***************************
+           id: 1
+  select_type: SIMPLE
+        table: f
+   partitions: NULL
+         type: ref
+possible_keys: idx_fk_language_id
+          key: idx_fk_language_id
+      key_len: 1
+          ref: sakila.l.language_id
+         rows: 500
+     filtered: 100.00
+        Extra: NULL
+2 rows in set, 1 warning (0.00 sec)`
+	exp, err := ParseExplainText(vertical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.ExplainFormat != TraditionalFormatExplain || len(exp.ExplainRows) != 2 ||
+		exp.ExplainRows[0].TableName != "l" || exp.ExplainRows[1].AccessType != "ref" ||
+		exp.ExplainRows[1].Rows != 500 || exp.ExplainRows[1].Ref[0] != "sakila.l.language_id" {
+		t.Errorf("vertical explain parse error: %+v", exp.ExplainRows)
+	}
+
+	js := `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {
+      "query_cost": "103.00"
+    },
+    "table": {
+      "table_name": "film",
+      "access_type": "ALL",
+      "rows_examined_per_scan": 1000,
+      "filtered": "33.33"
+    }
+  }
+}`
+	for _, content := range []string{
+		js,
+		"mysql> EXPLAIN FORMAT=JSON SELECT * FROM film\\G\n*************************** 1. row ***************************\nEXPLAIN: " + js + "\n1 row in set, 1 warning (0.00 sec)",
+		"+---------+\n| EXPLAIN |\n+---------+\n| " + js + " |\n+---------+\n1 row in set (0.00 sec)",
+	} {
+		exp, err = ParseExplainText(content)
+		if err != nil {
+			t.Error(err, content)
+			continue
+		}
+		if exp.ExplainFormat != JSONFormatExplain || exp.ExplainJSON.QueryBlock.Table.TableName != "film" || exp.Cost() != 103 {
+			t.Errorf("json explain parse error: %s", content)
+		}
+	}
+
+	if _, err = ParseExplainText("id: 1"); err == nil {
+		t.Error("unknown explain format should return error")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestFindTablesInJson(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	idx := 9
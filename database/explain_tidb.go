@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/XiaoMi/soar/common"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// TiDBExplainRow TiDB EXPLAIN [ANALYZE] 输出的单个算子
+// https://docs.pingcap.com/tidb/stable/explain-overview
+type TiDBExplainRow struct {
+	ID            string  // 算子 ID，如 TableFullScan_4
+	Operator      string  // 算子类型，如 TableFullScan
+	Depth         int     // 算子在执行计划树中的深度
+	EstRows       float64 // 优化器估算行数，TiDB 4.0 之前的版本为 count 列
+	ActRows       int64   // 实际返回行数，仅 EXPLAIN ANALYZE 输出，-1 表示未知
+	Task          string  // root, cop[tikv], cop[tiflash], mpp[tiflash] 等
+	AccessObject  string  // 访问对象，如 table:t, index:idx_a(a)
+	ExecutionInfo string  // 仅 EXPLAIN ANALYZE 输出
+	OperatorInfo  string
+	Memory        string // 仅 EXPLAIN ANALYZE 输出
+	Disk          string // 仅 EXPLAIN ANALYZE 输出
+}
+
+// 执行计划树的缩进符号
+var tidbExplainTreeChars = "└├│─ "
+
+// 算子 ID 后的编号
+var tidbOperatorIDRe = regexp.MustCompile(`_\d+$`)
+
+// 老版本 TiDB 没有 access object 列，表名在 operator info 中
+var tidbTableInfoRe = regexp.MustCompile(`table:([^,\s]+)`)
+
+// isTiDBExplainHeader 根据表头判断是否为 TiDB 的执行计划
+func isTiDBExplainHeader(header []string) bool {
+	var hasID, hasTask bool
+	for _, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "id":
+			hasID = true
+		case "task":
+			hasTask = true
+		}
+	}
+	return hasID && hasTask
+}
+
+// newTiDBExplainRow 将列名到值的映射转换为 TiDBExplainRow，列名均为小写
+func newTiDBExplainRow(cols map[string]string) TiDBExplainRow {
+	row := TiDBExplainRow{
+		ActRows:       -1,
+		Task:          cols["task"],
+		AccessObject:  cols["access object"],
+		ExecutionInfo: cols["execution info"],
+		OperatorInfo:  cols["operator info"],
+		Memory:        cols["memory"],
+		Disk:          cols["disk"],
+	}
+
+	// 去掉执行计划树的缩进，每层缩进占两个字符
+	id := strings.TrimRightFunc(cols["id"], unicode.IsSpace)
+	row.ID = strings.TrimLeft(id, tidbExplainTreeChars)
+	row.Depth = len([]rune(id)) - len([]rune(row.ID))
+	row.Depth /= 2
+	row.Operator = tidbOperatorIDRe.ReplaceAllString(row.ID, "")
+
+	est, ok := cols["estrows"]
+	if !ok {
+		est = cols["count"]
+	}
+	row.EstRows, _ = strconv.ParseFloat(est, 64)
+	if act, ok := cols["actrows"]; ok {
+		if n, err := strconv.ParseInt(act, 10, 64); err == nil {
+			row.ActRows = n
+		}
+	}
+	if row.AccessObject == "" {
+		if m := tidbTableInfoRe.FindStringSubmatch(row.OperatorInfo); m != nil {
+			row.AccessObject = "table:" + m[1]
+		}
+	}
+	return row
+}
+
+// parseTiDBExplainText 解析文本形式的 TiDB 执行计划
+func parseTiDBExplainText(content string) (explainRows []TiDBExplainRow, err error) {
+	lines := strings.Split(content, "\n")
+	if len(lines) < 3 {
+		return nil, errors.New("explain Rows less than 3")
+	}
+
+	var header []string
+	for _, h := range strings.Split(strings.Trim(strings.TrimSpace(lines[1]), "|"), "|") {
+		header = append(header, strings.ToLower(strings.TrimSpace(h)))
+	}
+
+	for _, l := range lines[3:] {
+		l = strings.TrimSpace(l)
+		// 跳过分割线
+		if strings.HasPrefix(l, "+") || l == "" {
+			continue
+		}
+		cols := strings.Split(strings.Trim(l, "|"), "|")
+		if len(cols) != len(header) {
+			return nil, fmt.Errorf("explain columns count mismatch: %s", l)
+		}
+		colsMap := make(map[string]string)
+		for i, h := range header {
+			// id 列保留左侧缩进，用于计算算子深度
+			if h == "id" {
+				colsMap[h] = strings.TrimPrefix(cols[i], " ")
+			} else {
+				colsMap[h] = strings.TrimSpace(cols[i])
+			}
+		}
+		explainRows = append(explainRows, newTiDBExplainRow(colsMap))
+	}
+	return explainRows, nil
+}
+
+// ParseTiDBExplainResult 分析 TiDB 执行 EXPLAIN [ANALYZE] 的结果
+func ParseTiDBExplainResult(res QueryResult) (exp *ExplainInfo, err error) {
+	exp = &ExplainInfo{ExplainFormat: TraditionalFormatExplain}
+	defer res.Rows.Close()
+
+	cols, err := res.Rows.Columns()
+	if err != nil {
+		return exp, err
+	}
+	vals := make([][]byte, len(cols))
+	fields := make([]interface{}, len(cols))
+	for i := range vals {
+		fields[i] = &vals[i]
+	}
+	for res.Rows.Next() {
+		if err = res.Rows.Scan(fields...); err != nil {
+			common.Log.Warn(err.Error())
+			continue
+		}
+		colsMap := make(map[string]string)
+		for i, col := range cols {
+			colsMap[strings.ToLower(col)] = string(vals[i])
+		}
+		exp.TiDBRows = append(exp.TiDBRows, newTiDBExplainRow(colsMap))
+	}
+	return exp, res.Rows.Err()
+}
+
+// explainTiDBQuery 生成 TiDB 可执行的 explain 查询请求
+func (db *Connector) explainTiDBQuery(sql string, explainType int) string {
+	sql, err := db.explainAbleSQL(sql)
+	if sql == "" || err != nil {
+		return sql
+	}
+
+	// EXPLAIN ANALYZE 会真正执行 SQL，只对 SELECT 使用
+	if explainType == AnalyzeExplainType && sqlparser.Preview(sql) == sqlparser.StmtSelect {
+		return fmt.Sprintf("explain analyze %s", sql)
+	}
+	return fmt.Sprintf("explain %s", sql)
+}
+
+// PrintMarkdownTiDBExplainTable 打印 markdown 格式的 TiDB 执行计划
+func PrintMarkdownTiDBExplainTable(exp *ExplainInfo) string {
+	rows := exp.TiDBRows
+	if len(rows) == 0 {
+		return ""
+	}
+
+	var analyze bool
+	for _, row := range rows {
+		if row.ActRows >= 0 {
+			analyze = true
+			break
+		}
+	}
+
+	var buf []string
+	if analyze {
+		buf = append(buf, "| id | estRows | actRows | task | access object | execution info | operator info | memory | disk |\n")
+		buf = append(buf, "|---|---|---|---|---|---|---|---|---|\n")
+	} else {
+		buf = append(buf, "| id | estRows | task | access object | operator info |\n")
+		buf = append(buf, "|---|---|---|---|---|\n")
+	}
+	for _, row := range rows {
+		// 用全角空格表示算子层级，markdown 表格会忽略普通空格
+		id := strings.Repeat("　", row.Depth) + common.MarkdownEscape(row.ID)
+		estRows := fmt.Sprintf("%.2f", row.EstRows)
+		if int64(row.EstRows) >= common.Config.ExplainMaxRows {
+			estRows = "☠️ **" + estRows + "**"
+		}
+		if analyze {
+			buf = append(buf, fmt.Sprintln("|", id, "|", estRows, "|", row.ActRows, "|",
+				common.MarkdownEscape(row.Task), "|", common.MarkdownEscape(row.AccessObject), "|",
+				common.MarkdownEscape(row.ExecutionInfo), "|", common.MarkdownEscape(row.OperatorInfo), "|",
+				row.Memory, "|", row.Disk, "|"))
+		} else {
+			buf = append(buf, fmt.Sprintln("|", id, "|", estRows, "|",
+				common.MarkdownEscape(row.Task), "|", common.MarkdownEscape(row.AccessObject), "|",
+				common.MarkdownEscape(row.OperatorInfo), "|"))
+		}
+	}
+	buf = append(buf, "\n")
+	return strings.Join(buf, "")
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestParseTiDBExplainText(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	explain := `
++-----------------------------+----------+-----------+---------------+--------------------------------+
+| id                          | estRows  | task      | access object | operator info                  |
++-----------------------------+----------+-----------+---------------+--------------------------------+
+| HashJoin_8                  | 12487.50 | root      |               | inner join, equal:[eq(a.id, b.id)] |
+| ├─TableReader_15(Build)     | 9990.00  | root      |               | data:Selection_14              |
+| │ └─Selection_14            | 9990.00  | cop[tikv] |               | not(isnull(b.id))              |
+| │   └─TableFullScan_13      | 10000.00 | cop[tikv] | table:b       | keep order:false, stats:pseudo |
+| └─TableReader_12(Probe)     | 9990.00  | root      |               | data:Selection_11              |
++-----------------------------+----------+-----------+---------------+--------------------------------+`
+	exp, err := ParseExplainText(explain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp.TiDBRows) != 5 || len(exp.ExplainRows) != 0 {
+		t.Fatalf("want 5 TiDB rows, got %d", len(exp.TiDBRows))
+	}
+	scan := exp.TiDBRows[3]
+	if scan.ID != "TableFullScan_13" || scan.Operator != "TableFullScan" || scan.Depth != 3 ||
+		scan.EstRows != 10000 || scan.ActRows != -1 || scan.Task != "cop[tikv]" || scan.AccessObject != "table:b" {
+		t.Errorf("unexpected row: %+v", scan)
+	}
+	if exp.TiDBRows[1].Operator != "TableReader_15(Build)" && exp.TiDBRows[1].Depth != 1 {
+		t.Errorf("unexpected row: %+v", exp.TiDBRows[1])
+	}
+
+	// EXPLAIN ANALYZE 及 TiDB 4.0 之前的格式
+	analyze := `
++-------------------+---------+---------+-----------+---------------+----------------------------+-----------------------------+-----------+------+
+| id                | estRows | actRows | task      | access object | execution info             | operator info               | memory    | disk |
++-------------------+---------+---------+-----------+---------------+----------------------------+-----------------------------+-----------+------+
+| TableReader_5     | 10.00   | 3000    | root      |               | time:1ms, loops:2          | data:TableFullScan_4        | 285 Bytes | N/A  |
+| └─TableFullScan_4 | 10.00   | 3000    | cop[tikv] | table:t       | tikv_task:{time:0s}        | keep order:false            | N/A       | N/A  |
++-------------------+---------+---------+-----------+---------------+----------------------------+-----------------------------+-----------+------+`
+	old := `
++---------------------+----------+------+-------------------------------------------------------------+
+| id                  | count    | task | operator info                                               |
++---------------------+----------+------+-------------------------------------------------------------+
+| TableReader_5       | 10000.00 | root | data:TableScan_4                                            |
+| └─TableScan_4       | 10000.00 | cop  | table:t, range:[-inf,+inf], keep order:false, stats:pseudo |
++---------------------+----------+------+-------------------------------------------------------------+`
+	exp, err = ParseExplainText(analyze)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp.TiDBRows) != 2 || exp.TiDBRows[1].ActRows != 3000 || exp.TiDBRows[0].Memory != "285 Bytes" {
+		t.Errorf("unexpected rows: %+v", exp.TiDBRows)
+	}
+	exp, err = ParseExplainText(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp.TiDBRows) != 2 || exp.TiDBRows[1].EstRows != 10000 || exp.TiDBRows[1].AccessObject != "table:t" {
+		t.Errorf("unexpected rows: %+v", exp.TiDBRows)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestPrintMarkdownTiDBExplainTable(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	exp := &ExplainInfo{TiDBRows: []TiDBExplainRow{
+		{ID: "TableReader_5", EstRows: 10, ActRows: -1, Task: "root", OperatorInfo: "data:TableFullScan_4"},
+		{ID: "TableFullScan_4", Depth: 1, EstRows: 10, ActRows: -1, Task: "cop[tikv]", AccessObject: "table:t"},
+	}}
+	expect := `| id | estRows | task | access object | operator info |
+|---|---|---|---|---|
+| TableReader\_5 | 10.00 | root |  | data:TableFullScan\_4 |
+| 　TableFullScan\_4 | 10.00 | cop[tikv] | table:t |  |
+
+`
+	if got := PrintMarkdownTiDBExplainTable(exp); got != expect {
+		t.Errorf("want:\n%s\ngot:\n%s", expect, got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+const (
+	historyTable      = "soar_audit_history"  // 评审记录表，不存在时自动创建
+	reviewTable       = "soar_serve_review"   // soar serve 评审页面的评审记录表，不存在时自动创建
+	historyTimeFormat = "2006-01-02 15:04:05" // created_at 按本地时间保存，不受 DSN 中 loc 参数的影响
+)
+
+// HistoryFinding 评审记录中的一条建议
+type HistoryFinding struct {
+	Item     string `json:"item"`
+	Severity string `json:"severity"`
+}
+
+// AuditRecord 一条 SQL 的一次评审记录
+type AuditRecord struct {
+	ID          int64
+	QueryID     string
+	Fingerprint string
+	Sample      string
+	File        string
+	Line        int
+	Score       int
+	Findings    []HistoryFinding
+	Plan        string // 执行计划摘要，见 PlanSignature
+	PlanRows    int64  // 执行计划中各表扫描行数之和
+	CreatedAt   time.Time
+}
+
+// Review soar serve 评审页面的一次评审记录
+type Review struct {
+	ID        int64
+	Target    string
+	Query     string
+	Report    string // HTML 格式的评审报告
+	CreatedAt time.Time
+}
+
+// History 保存在 MySQL 中的评审记录，用于比较同一 SQL 多次评审的得分及执行计划的变化，以及 soar serve 评审页面的历史
+type History struct {
+	conn *sql.DB
+}
+
+// OpenHistory 连接 history-dsn 指定的库，评审记录表不存在时创建
+func OpenHistory(dsn *common.Dsn) (*History, error) {
+	conn, err := openDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	h := &History{conn: conn}
+	_, err = conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (", historyTable) + `
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  query_id varchar(16) NOT NULL,
+  fingerprint text NOT NULL,
+  sample text NOT NULL,
+  file varchar(512) NOT NULL DEFAULT '',
+  line int NOT NULL DEFAULT 0,
+  score int NOT NULL DEFAULT 0,
+  findings text NOT NULL,
+  plan text NOT NULL,
+  plan_rows bigint NOT NULL DEFAULT 0,
+  created_at datetime NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_query_id_created_at (query_id, created_at),
+  KEY idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='SOAR 评审记录'`)
+	if err == nil {
+		_, err = conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (", reviewTable) + `
+  id bigint unsigned NOT NULL AUTO_INCREMENT,
+  target varchar(64) NOT NULL DEFAULT '',
+  query mediumtext NOT NULL,
+  report longtext NOT NULL,
+  created_at datetime NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='SOAR 评审页面的评审记录'`)
+	}
+	if err != nil {
+		common.LogIfWarn(conn.Close(), "")
+		return nil, err
+	}
+	return h, nil
+}
+
+// Close 关闭连接
+func (h *History) Close() error {
+	return h.conn.Close()
+}
+
+// Record 保存一条评审记录，CreatedAt 为空时使用当前时间
+func (h *History) Record(rec AuditRecord) error {
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	findings, err := json.Marshal(rec.Findings)
+	if err != nil {
+		return err
+	}
+	_, err = h.conn.Exec(fmt.Sprintf("INSERT INTO `%s` ", historyTable)+
+		"(query_id, fingerprint, sample, file, line, score, findings, plan, plan_rows, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		rec.QueryID, rec.Fingerprint, rec.Sample, rec.File, rec.Line, rec.Score, string(findings), rec.Plan, rec.PlanRows, rec.CreatedAt.Format(historyTimeFormat))
+	return err
+}
+
+// Records 按时间倒序返回评审记录，queryID 为空时返回所有 SQL 的记录，limit 为 0 时不限制条数
+func (h *History) Records(queryID string, limit int) ([]AuditRecord, error) {
+	query := fmt.Sprintf("SELECT id, query_id, fingerprint, sample, file, line, score, findings, plan, plan_rows, created_at FROM `%s`", historyTable)
+	var args []interface{}
+	if queryID != "" {
+		query += " WHERE query_id = ?"
+		args = append(args, queryID)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := h.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []AuditRecord
+	for rows.Next() {
+		var rec AuditRecord
+		var findings, created string
+		err = rows.Scan(&rec.ID, &rec.QueryID, &rec.Fingerprint, &rec.Sample, &rec.File, &rec.Line,
+			&rec.Score, &findings, &rec.Plan, &rec.PlanRows, &created)
+		if err != nil {
+			return nil, err
+		}
+		common.LogIfWarn(json.Unmarshal([]byte(findings), &rec.Findings), "")
+		rec.CreatedAt = parseHistoryTime(created)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// SaveReview 保存一条评审页面的评审记录，返回记录编号，CreatedAt 为空时使用当前时间
+func (h *History) SaveReview(r Review) (int64, error) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	res, err := h.conn.Exec(fmt.Sprintf("INSERT INTO `%s` (target, query, report, created_at) VALUES (?, ?, ?, ?)", reviewTable),
+		r.Target, r.Query, r.Report, r.CreatedAt.Format(historyTimeFormat))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Review 按编号返回评审页面的评审记录，没有记录时返回 nil
+func (h *History) Review(id int64) (*Review, error) {
+	reviews, err := h.reviews(fmt.Sprintf("SELECT id, target, query, report, created_at FROM `%s` WHERE id = ?", reviewTable), id)
+	if err != nil || len(reviews) == 0 {
+		return nil, err
+	}
+	return &reviews[0], nil
+}
+
+// Reviews 按时间倒序返回最近 limit 条评审页面的评审记录，不包含评审报告
+func (h *History) Reviews(limit int) ([]Review, error) {
+	return h.reviews(fmt.Sprintf("SELECT id, target, query, '', created_at FROM `%s` ORDER BY id DESC LIMIT %d", reviewTable, limit))
+}
+
+// reviews 查询评审页面的评审记录
+func (h *History) reviews(query string, args ...interface{}) ([]Review, error) {
+	rows, err := h.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []Review
+	for rows.Next() {
+		var r Review
+		var created string
+		if err = rows.Scan(&r.ID, &r.Target, &r.Query, &r.Report, &created); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = parseHistoryTime(created)
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
+
+// parseHistoryTime 解析 created_at，兼容 DSN 中指定了 parseTime=true 的情况
+func parseHistoryTime(created string) time.Time {
+	t, err := time.ParseInLocation(historyTimeFormat, created, time.Local)
+	if err != nil {
+		t, _ = time.Parse(time.RFC3339Nano, created)
+	}
+	return t
+}
+
+// Last 返回该 SQL 最近一次的评审记录，没有记录时返回 nil
+func (h *History) Last(queryID string) (*AuditRecord, error) {
+	records, err := h.Records(queryID, 1)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+// PlanSignature 执行计划摘要，每张表的 表名/访问类型/使用的索引，以逗号分隔，同时返回扫描行数之和
+// 摘要不包含行数，统计信息的正常波动不会被当作执行计划变化
+func PlanSignature(exp *ExplainInfo) (string, int64) {
+	if exp == nil {
+		return "", 0
+	}
+	rows := exp.ExplainRows
+	if exp.ExplainFormat == JSONFormatExplain && exp.ExplainJSON != nil {
+		rows = ConvertExplainJSON2Row(exp.ExplainJSON)
+	}
+	var buf []string
+	var total int64
+	for _, row := range rows {
+		buf = append(buf, fmt.Sprintf("%s/%s/%s", row.TableName, row.AccessType, row.Key))
+		total += row.Rows
+	}
+	return strings.Join(buf, ", "), total
+}
+
+// PlanChanged 与上一次评审相比执行计划是否发生变化，两次评审中任意一次没有执行计划时不比较
+func PlanChanged(prev, cur AuditRecord) bool {
+	return prev.Plan != "" && cur.Plan != "" && prev.Plan != cur.Plan
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestPlanSignature(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	exp := &ExplainInfo{ExplainRows: []ExplainRow{
+		{TableName: "film", AccessType: "ALL", Rows: 1000},
+		{TableName: "film_actor", AccessType: "ref", Key: "idx_fk_film_id", Rows: 5},
+	}}
+	plan, rows := PlanSignature(exp)
+	if plan != "film/ALL/, film_actor/ref/idx_fk_film_id" || rows != 1005 {
+		t.Errorf("PlanSignature got: %s, %d", plan, rows)
+	}
+	if plan, rows = PlanSignature(nil); plan != "" || rows != 0 {
+		t.Errorf("PlanSignature(nil) got: %s, %d", plan, rows)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestPlanChanged(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	prev := AuditRecord{Plan: "film/ref/idx_title", PlanRows: 10}
+	if !PlanChanged(prev, AuditRecord{Plan: "film/ALL/", PlanRows: 1000}) {
+		t.Error("index -> full scan should be a plan change")
+	}
+	if PlanChanged(prev, AuditRecord{Plan: "film/ref/idx_title", PlanRows: 20}) {
+		t.Error("rows change only should not be a plan change")
+	}
+	if PlanChanged(prev, AuditRecord{}) {
+		t.Error("record without plan should not be compared")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestHistoryReview(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	h, err := OpenHistory(common.Config.TestDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	id, err := h.SaveReview(Review{Target: "mysql:8.0", Query: "select * from film", Report: "<html></html>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := h.Review(id)
+	if err != nil || r == nil || r.Query != "select * from film" || r.Report != "<html></html>" {
+		t.Errorf("Review got: %v, %v", r, err)
+	}
+	reviews, err := h.Reviews(1)
+	if err != nil || len(reviews) != 1 || reviews[0].ID != id || reviews[0].Report != "" {
+		t.Errorf("Reviews got: %v, %v", reviews, err)
+	}
+	if r, err = h.Review(-1); r != nil || err != nil {
+		t.Errorf("Review(-1) got: %v, %v", r, err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
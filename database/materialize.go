@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaterializedTable EXPLAIN 中被物化为临时表的派生表
+type MaterializedTable struct {
+	Name      string   // EXPLAIN 中的表名，TRADITIONAL 格式为 <derivedN>，JSON 格式为派生表的别名
+	Rows      int64    // 物化后的预估行数
+	RowBytes  int64    // 物化后的平均行长度，只有 JSON 格式能从 data_read_per_join 中估算，无法估算时为 0
+	Dependent bool     // 依赖外层查询（LATERAL），外层的每一行都需要重新物化
+	AutoKey   bool     // 外层查询使用了自动创建的索引 <auto_keyN> 访问物化表
+	Tables    []string // 物化查询中访问的表名（或别名）
+}
+
+// MaterializedTables 从 EXPLAIN 结果中找出所有需要物化的派生表
+func MaterializedTables(exp *ExplainInfo) []MaterializedTable {
+	var tables []MaterializedTable
+	if exp == nil {
+		return tables
+	}
+
+	if exp.ExplainFormat == JSONFormatExplain {
+		buf, err := json.Marshal(exp.ExplainJSON)
+		if err != nil {
+			return tables
+		}
+		// findTablesInJSON 的结果存放在全局变量中，解析物化查询之前需要先复制一份
+		explainJSONTables = []*ExplainJSONTable{}
+		findTablesInJSON(string(buf), 0)
+		all := append([]*ExplainJSONTable{}, explainJSONTables...)
+		for _, table := range all {
+			sub := table.MaterializedFromSubquery
+			if sub.QueryBlock == nil {
+				continue
+			}
+			mt := MaterializedTable{
+				Name:      table.TableName,
+				Rows:      table.RowsExaminedPerScan,
+				Dependent: sub.Dependent,
+				AutoKey:   strings.HasPrefix(table.Key, "<auto_key"),
+			}
+			if read, err := ParseReadBytes(table.CostInfo.DataReadPerJoin); err == nil && table.RowsProducedPerJoin > 0 {
+				mt.RowBytes = read / int64(table.RowsProducedPerJoin)
+			}
+			if block, err := json.Marshal(sub.QueryBlock); err == nil {
+				for _, row := range FormatJSONIntoTraditional(string(block)) {
+					mt.Tables = append(mt.Tables, row.TableName)
+				}
+			}
+			tables = append(tables, mt)
+		}
+		return tables
+	}
+
+	for _, row := range exp.ExplainRows {
+		if !strings.HasPrefix(row.TableName, "<derived") {
+			continue
+		}
+		mt := MaterializedTable{
+			Name:    row.TableName,
+			Rows:    row.Rows,
+			AutoKey: strings.HasPrefix(row.Key, "<auto_key"),
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(row.TableName, "<derived"), ">"))
+		if err != nil {
+			continue
+		}
+		for _, r := range exp.ExplainRows {
+			if r.ID != id {
+				continue
+			}
+			if r.SelectType == "DEPENDENT DERIVED" {
+				mt.Dependent = true
+			}
+			mt.Tables = append(mt.Tables, r.TableName)
+		}
+		tables = append(tables, mt)
+	}
+	return tables
+}
+
+// ParseReadBytes 解析 EXPLAIN FORMAT=JSON 中 data_read_per_join 的值，如 480, 1K, 24M
+func ParseReadBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty data_read_per_join")
+	}
+	var shift uint
+	switch s[len(s)-1] {
+	case 'K', 'k':
+		shift = 10
+	case 'M', 'm':
+		shift = 20
+	case 'G', 'g':
+		shift = 30
+	case 'T', 't':
+		shift = 40
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(v * float64(int64(1)<<shift)), nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestMaterializedTables(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	exp := &ExplainInfo{
+		ExplainRows: []ExplainRow{
+			{ID: 1, SelectType: "PRIMARY", TableName: "c", Rows: 599},
+			{ID: 1, SelectType: "PRIMARY", TableName: "<derived2>", Key: "<auto_key0>", Rows: 10},
+			{ID: 2, SelectType: "DERIVED", TableName: "p", Rows: 16086},
+		},
+	}
+	want := []MaterializedTable{{Name: "<derived2>", Rows: 10, AutoKey: true, Tables: []string{"p"}}}
+	if got := MaterializedTables(exp); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+
+	exp, err := ParseExplainText(`{
+  "query_block": {
+    "select_id": 1,
+    "table": {
+      "table_name": "p",
+      "access_type": "ALL",
+      "rows_examined_per_scan": 599,
+      "rows_produced_per_join": 599,
+      "filtered": "100.00",
+      "cost_info": {
+        "data_read_per_join": "9K"
+      },
+      "materialized_from_subquery": {
+        "using_temporary_table": true,
+        "dependent": false,
+        "cacheable": true,
+        "query_block": {
+          "select_id": 2,
+          "grouping_operation": {
+            "using_filesort": false,
+            "table": {
+              "table_name": "payment",
+              "access_type": "index",
+              "key": "idx_fk_customer_id",
+              "rows_examined_per_scan": 16086,
+              "rows_produced_per_join": 16086,
+              "filtered": "100.00"
+            }
+          }
+        }
+      }
+    }
+  }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []MaterializedTable{{Name: "p", Rows: 599, RowBytes: 15, Tables: []string{"payment"}}}
+	if got := MaterializedTables(exp); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestParseReadBytes(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string]int64{
+		"480":  480,
+		"1K":   1024,
+		"1.5M": 3 << 19,
+		"2G":   2 << 30,
+	}
+	for s, want := range cases {
+		if got, err := ParseReadBytes(s); err != nil || got != want {
+			t.Errorf("%s want: %d, got: %d, %v", s, want, got, err)
+		}
+	}
+	if _, err := ParseReadBytes(""); err == nil {
+		t.Error("empty string should return error")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TableActivity 表当前的访问情况，用于评估 DDL 等待元数据锁时阻塞后续请求的风险
+type TableActivity struct {
+	RowOps      float64 // 实例启动以来平均每秒的行读写次数，performance_schema 未开启时为 0
+	LongTrx     int     // 执行时间超过阈值的事务数，这些事务可能持有表的元数据锁
+	LongQueries int     // 访问该表且执行时间超过阈值的请求数
+	MDLWaits    int     // 访问该表且正在等待元数据锁的请求数
+}
+
+// likeEscape 转义 LIKE 中的通配符
+var likeEscape = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// TableActivity 获取表的访问频率、长事务及 processlist 中访问该表的请求，longTime 为长事务、慢请求的阈值（秒）
+// processlist 中通过 SQL 文本匹配表名，只能作为参考
+func (db *Connector) TableActivity(tb string, longTime int) (*TableActivity, error) {
+	activity := &TableActivity{}
+	stat, err := db.TableIOStat(tb)
+	if err != nil {
+		return nil, err
+	}
+	if stat != nil {
+		res, err := db.Query("show global status like 'Uptime'")
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		var uptime float64
+		if res.Rows.Next() {
+			err = res.Rows.Scan(&name, &uptime)
+		}
+		res.Rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		if uptime > 0 {
+			activity.RowOps = float64(stat.Read+stat.Writes()) / uptime
+		}
+	}
+
+	trxSQL, processSQL := tableActivitySQL(tb, longTime)
+	res, err := db.Query(trxSQL)
+	if err != nil {
+		return nil, err
+	}
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&activity.LongTrx)
+	}
+	res.Rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = db.Query(processSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Rows.Close()
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&activity.LongQueries, &activity.MDLWaits)
+	}
+	return activity, err
+}
+
+// tableActivitySQL 生成 TableActivity 中查询长事务数及 processlist 中访问该表的请求数的 SQL
+func tableActivitySQL(tb string, longTime int) (string, string) {
+	trx := fmt.Sprintf("select count(*) from information_schema.innodb_trx where trx_started < now() - interval %d second", longTime)
+	process := fmt.Sprintf(`select ifnull(sum(time >= %d), 0), ifnull(sum(state = 'Waiting for table metadata lock'), 0)
+		from information_schema.processlist where id <> connection_id() and command <> 'Sleep' and info like '%s'`,
+		longTime, Escape("%"+likeEscape.Replace(tb)+"%", false))
+	return trx, process
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestTableActivity(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	activity, err := connTest.TableActivity("film", 60)
+	if err != nil {
+		t.Error(err)
+	}
+	if activity != nil && (activity.RowOps < 0 || activity.MDLWaits != 0) {
+		t.Errorf("sakila.film activity: %+v", activity)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestTableActivitySQL(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	trx, process := tableActivitySQL("film_text", 60)
+	if trx != "select count(*) from information_schema.innodb_trx where trx_started < now() - interval 60 second" {
+		t.Errorf("long trx SQL got: %s", trx)
+	}
+	// 表名中的 LIKE 通配符需要转义，反斜杠在字符串中再转义一次
+	if !strings.Contains(process, "sum(time >= 60)") || !strings.HasSuffix(process, `info like '%film\\_text%'`) {
+		t.Errorf("processlist SQL got: %s", process)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -42,6 +42,9 @@ type Connector struct {
 	Database string
 	Charset  string
 	Conn     *sql.DB
+	ReadOnly bool      // Online 环境连接，只允许执行白名单中的SQL
+	replicas []replica // 只读从库
+	next     uint32    // 从库轮询计数
 }
 
 // QueryResult 数据库查询返回值
@@ -54,7 +57,11 @@ type QueryResult struct {
 
 // NewConnector 创建新连接
 func NewConnector(dsn *common.Dsn) (*Connector, error) {
-	conn, err := sql.Open("mysql", common.FormatDSN(dsn))
+	conn, err := openDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := openReplicas(dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +72,7 @@ func NewConnector(dsn *common.Dsn) (*Connector, error) {
 		Database: dsn.Schema,
 		Charset:  dsn.Charset,
 		Conn:     conn,
+		replicas: replicas,
 	}
 	return connector, err
 }
@@ -77,34 +85,56 @@ func (db *Connector) Query(sql string, params ...interface{}) (QueryResult, erro
 	if common.Config.TestDSN.Disable {
 		return res, errors.New("dsn is disable")
 	}
-	// 数据库安全性检查：如果 Connector 的 IP 端口与 TEST 环境不一致，则启用SQL白名单
-	// 不在白名单中的SQL不允许执行
-	// 执行环境与test环境不相同
-	if db.Addr != common.Config.TestDSN.Addr && db.dangerousQuery(sql) {
-		return res, fmt.Errorf("query execution deny: execute SQL with DSN(%s/%s) '%s'",
-			db.Addr, db.Database, fmt.Sprintf(sql, params...))
+	// 数据库安全性检查：Online 环境只允许执行白名单中的SQL
+	err = db.guard(sql, params...)
+	if err != nil {
+		return res, err
 	}
 
 	if db.Database == "" {
 		db.Database = "information_schema"
 	}
 
-	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", db.Addr, db.Database, fmt.Sprintf(sql, params...))
-	_, err = db.Conn.Exec("USE " + db.Database)
-	if err != nil {
-		common.Log.Error(err.Error())
-		return res, err
+	// 只读 SQL 的临时错误按退避时间重试，在从库失败时依次切换到其他从库及主库
+	for retry := 0; retry <= queryRetries(sql); retry++ {
+		if retry > 0 {
+			common.Log.Warn("(db *Connector) Query() retry %d, Error: %v", retry, err)
+			time.Sleep(retryBackoff(retry - 1))
+		}
+		for _, endpoint := range db.endpoints(sql) {
+			res, err = db.query(endpoint, sql, params...)
+			if !isTransientError(err) {
+				return res, err
+			}
+		}
+	}
+	return res, err
+}
+
+// query 在指定的连接池上执行SQL
+func (db *Connector) query(endpoint replica, sql string, params ...interface{}) (QueryResult, error) {
+	var res QueryResult
+	var err error
+	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", endpoint.Addr, db.Database, fmt.Sprintf(sql, params...))
+	if queryTimeout() > 0 {
+		res.Rows, res.Error = db.queryWithTimeout(endpoint.Conn, sql, params...)
+	} else {
+		_, err = endpoint.Conn.Exec("USE " + db.Database)
+		if err != nil {
+			common.Log.Error(err.Error())
+			return res, err
+		}
+		res.Rows, res.Error = endpoint.Conn.Query(sql, params...)
 	}
-	res.Rows, res.Error = db.Conn.Query(sql, params...)
 
 	if common.Config.ShowWarnings {
-		res.Warning, err = db.Conn.Query("SHOW WARNINGS")
+		res.Warning, err = endpoint.Conn.Query("SHOW WARNINGS")
 		common.LogIfError(err, "")
 	}
 
 	// SHOW WARNINGS 并不会影响 last_query_cost
 	if common.Config.ShowLastQueryCost {
-		cost, err := db.Conn.Query("SHOW SESSION STATUS LIKE 'last_query_cost'")
+		cost, err := endpoint.Conn.Query("SHOW SESSION STATUS LIKE 'last_query_cost'")
 		if err == nil {
 			var varName string
 			if cost.Next() {
@@ -202,6 +232,14 @@ func (db *Connector) ColumnCardinality(tb, col string) float64 {
 		return 0.5
 	}
 
+	// 只复制了统计信息的测试环境中没有数据，只能通过索引统计信息估算散粒度
+	if common.Config.StatisticsTransfer {
+		if cardinality := db.IndexCardinality(tb, col, rowTotal); cardinality >= 0 {
+			return cardinality
+		}
+		return 0.5
+	}
+
 	// 计算该列散粒度
 	db.Conn.Stats()
 	res, err := db.Query(fmt.Sprintf("select count(distinct `%s`) from `%s`.`%s`",
@@ -231,6 +269,88 @@ func (db *Connector) ColumnCardinality(tb, col string) float64 {
 	return colNum / float64(rowTotal)
 }
 
+// PrefixCardinality 计算列取不同长度前缀时的散粒度，返回值中前 len(lengths) 个元素与 lengths 一一对应，最后一个元素为整列的散粒度
+// 表中无数据时返回 nil
+func (db *Connector) PrefixCardinality(tb, col string, lengths []int) ([]float64, error) {
+	var fields []string
+	for _, length := range lengths {
+		fields = append(fields, fmt.Sprintf("count(distinct left(`%s`, %d))", Escape(col, false), length))
+	}
+	fields = append(fields, fmt.Sprintf("count(distinct `%s`)", Escape(col, false)), "count(*)")
+	res, err := db.Query(fmt.Sprintf("select %s from `%s`.`%s`", strings.Join(fields, ", "),
+		Escape(db.Database, false), Escape(tb, false)))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]float64, len(fields))
+	dest := make([]interface{}, len(fields))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if res.Rows.Next() {
+		err = res.Rows.Scan(dest...)
+	}
+	res.Rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	total := counts[len(counts)-1]
+	if total == 0 {
+		return nil, nil
+	}
+	cardinality := counts[:len(counts)-1]
+	for i := range cardinality {
+		cardinality[i] /= total
+	}
+	return cardinality, nil
+}
+
+// NumericDigits 采样列中不同的非空值，返回整数部分及小数部分的最大位数，表中无数据时均返回 -1
+func (db *Connector) NumericDigits(tb, col string, limit int) (int, int, error) {
+	res, err := db.Query(fmt.Sprintf("select distinct cast(`%s` as char) from `%s`.`%s` where `%s` is not null limit %d",
+		Escape(col, false), Escape(db.Database, false), Escape(tb, false), Escape(col, false), limit))
+	if err != nil {
+		return -1, -1, err
+	}
+	defer res.Rows.Close()
+
+	intDigits, scale := -1, -1
+	for res.Rows.Next() {
+		var val string
+		if err = res.Rows.Scan(&val); err != nil {
+			return -1, -1, err
+		}
+		i, s, ok := numericDigits(val)
+		if !ok {
+			continue
+		}
+		if i > intDigits {
+			intDigits = i
+		}
+		if s > scale {
+			scale = s
+		}
+	}
+	return intDigits, scale, res.Rows.Err()
+}
+
+// numericDigits 数值字符串整数部分及小数部分的位数，科学计数法会先展开
+func numericDigits(val string) (int, int, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	str := strings.TrimPrefix(strconv.FormatFloat(f, 'f', -1, 64), "-")
+	parts := strings.SplitN(str, ".", 2)
+	intDigits := len(strings.TrimLeft(parts[0], "0"))
+	if len(parts) == 1 {
+		return intDigits, 0, true
+	}
+	return intDigits, len(parts[1]), true
+}
+
 // IsView 判断表是否是视图
 func (db *Connector) IsView(tbName string) bool {
 	common.Log.Debug("IsView, ShowTableStatus check if `%s` is view", tbName)
@@ -265,36 +385,78 @@ func RemoveSQLComments(sql string) string {
 	return strings.TrimSpace(string(res))
 }
 
+// guard Online 环境只读保护，ReadOnly 或与 Test 环境地址不同的连接只允许执行白名单中的 SQL
+// 配置 -i-know-what-i-am-doing 后不做检查
+func (db *Connector) guard(sql string, params ...interface{}) error {
+	if common.Config.IKnowWhatIAmDoing {
+		return nil
+	}
+	if !db.ReadOnly && db.Addr == common.Config.TestDSN.Addr {
+		return nil
+	}
+	if db.dangerousQuery(sql) {
+		return fmt.Errorf("query execution deny: execute SQL with DSN(%s/%s) '%s'",
+			db.Addr, db.Database, fmt.Sprintf(sql, params...))
+	}
+	return nil
+}
+
 // 为了防止在 Online 环境进行误操作，通过 dangerousQuery 来判断能否在 Online 执行
 func (db *Connector) dangerousQuery(query string) bool {
+	return !allowedQuery(query, common.Config.OnlineAllowList)
+}
+
+// readOnlyPrefix 只读 SQL 的前缀
+var readOnlyPrefix = []string{"select", "show", "explain", "describe", "desc"}
+
+// lockingRead SELECT 中会加锁或写文件的子句
+var lockingRead = regexp.MustCompile(`\bfor\s+(update|share)\b|\block\s+in\s+share\s+mode\b|\binto\s+(outfile|dumpfile)\b`)
+
+// sideEffectFunc SELECT 中会阻塞连接或持有用户锁的函数
+var sideEffectFunc = regexp.MustCompile(`\b(sleep|benchmark|get_lock|release_lock|release_all_locks)\s*\(`)
+
+// explainPrefix EXPLAIN 及其选项，其后为 EXPLAIN 包装的 SQL
+var explainPrefix = regexp.MustCompile(`^(explain|describe|desc)\s+((analyze|extended|partitions|format\s*=\s*\w+)\s+)*`)
+
+// allowedQuery 判断 query 中的每一条 SQL 是否都以 whiteList 中的关键字开头
+func allowedQuery(query string, whiteList []string) bool {
 	queries, err := sqlparser.SplitStatementToPieces(strings.TrimSpace(strings.ToLower(query)))
 	if err != nil {
-		return true
+		return false
 	}
 
 	for _, query := range queries {
-		dangerous := true
-		whiteList := []string{
-			"select",
-			"show",
-			"explain",
-			"describe",
-			"desc",
-		}
-
+		allowed := false
 		for _, prefix := range whiteList {
-			if strings.HasPrefix(query, prefix) {
-				dangerous = false
+			if strings.HasPrefix(query, strings.TrimSpace(prefix)) {
+				allowed = true
 				break
 			}
 		}
 
-		if dangerous {
-			return true
+		if !allowed {
+			return false
+		}
+
+		// 普通 EXPLAIN 不执行其包装的 SQL，EXPLAIN ANALYZE 会真正执行，只允许用于 SELECT
+		stmt := query
+		if explain := explainPrefix.FindString(query); explain != "" {
+			stmt = query[len(explain):]
+			if !strings.Contains(explain, "analyze") {
+				continue
+			}
+			if !strings.HasPrefix(stmt, "select") {
+				return false
+			}
+		}
+
+		// SELECT ... FOR UPDATE, SELECT ... INTO OUTFILE, SELECT SLEEP(), SELECT GET_LOCK() 不是只读操作
+		if strings.HasPrefix(stmt, "select") && (lockingRead.MatchString(stmt) || sideEffectFunc.MatchString(stmt)) {
+			return false
 		}
 	}
 
-	return false
+	return true
 }
 
 // TimeFormat standard MySQL datetime format
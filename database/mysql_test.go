@@ -39,6 +39,7 @@ func TestMain(m *testing.M) {
 		common.DevPath, _ = filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	}
 	common.BaseDir = common.DevPath
+	common.RedirectTestLog()
 	err := common.ParseConfig("")
 	common.LogIfError(err, "init ParseConfig")
 	common.Log.Debug("mysql_test init")
@@ -94,13 +95,79 @@ func TestColumnCardinality(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestPrefixCardinality(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	cardinality, err := connTest.PrefixCardinality("film", "title", []int{1, 8})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(cardinality) != 3 || cardinality[0] > cardinality[1] || cardinality[1] > cardinality[2] || cardinality[2] > 1 {
+		t.Error("sakila.film.title prefix cardinality should increase with length, now it's", cardinality)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestNumericDigits(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	intDigits, scale, err := connTest.NumericDigits("payment", "amount", 1000)
+	if err != nil {
+		t.Error(err)
+	}
+	if intDigits < 1 || intDigits > 3 || scale != 2 {
+		t.Error("sakila.payment.amount is decimal(5,2), got", intDigits, scale)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestNumericDigitsValue(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	cases := map[string][2]int{
+		"19.99":    {2, 2},
+		"-1200":    {4, 0},
+		"0.005":    {0, 3},
+		"1.5e+06":  {7, 0},
+		"1.25e-03": {0, 5},
+	}
+	for val, want := range cases {
+		i, s, ok := numericDigits(val)
+		if !ok || i != want[0] || s != want[1] {
+			t.Errorf("%s want: %v, got: %d %d", val, want, i, s)
+		}
+	}
+	if _, _, ok := numericDigits("abc"); ok {
+		t.Error("abc is not a number")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestDangerousSQL(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	testCase := map[string]bool{
-		"select * from tb;delete from tb;": true,
-		"show database;":                   false,
-		"select * from t;":                 false,
-		"explain delete from t;":           false,
+		"select * from tb;delete from tb;":             true,
+		"show database;":                               false,
+		"select * from t;":                             false,
+		"explain delete from t;":                       false,
+		"select * from t for update;":                  true,
+		"select * from t lock in share mode;":          true,
+		"select * from t into outfile '/tmp/t.csv';":   true,
+		"explain select * from t for update;":          false,
+		"explain select * from t for share;":           false,
+		"select sleep(10);":                            true,
+		"select get_lock('a', 10), release_lock('a');": true,
+		"explain select sleep(10);":                    false,
+		"explain analyze select benchmark(1, 1);":      true,
+		"explain analyze select * from t;":             false,
+		"explain format=tree select * from t;":         false,
+		"explain analyze delete from t;":               true,
+		"explain analyze update t set c = 1;":          true,
+		"explain analyze select * from t for share;":   true,
+		"describe select * from t;":                    false,
 	}
 
 	db := Connector{}
@@ -113,6 +180,43 @@ func TestDangerousSQL(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestGuard(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgAllowList := common.Config.OnlineAllowList
+	orgIKnowWhatIAmDoing := common.Config.IKnowWhatIAmDoing
+	defer func() {
+		common.Config.OnlineAllowList = orgAllowList
+		common.Config.IKnowWhatIAmDoing = orgIKnowWhatIAmDoing
+	}()
+
+	online := Connector{Addr: common.Config.TestDSN.Addr, ReadOnly: true}
+	if err := online.guard("delete from t"); err == nil {
+		t.Error("ReadOnly connector should deny delete")
+	}
+	if err := online.guard("select * from t"); err != nil {
+		t.Error(err)
+	}
+
+	// 测试环境不做检查
+	test := Connector{Addr: common.Config.TestDSN.Addr}
+	if err := test.guard("delete from t"); err != nil {
+		t.Error(err)
+	}
+
+	// 自定义白名单
+	common.Config.OnlineAllowList = []string{"explain"}
+	if err := online.guard("select * from t"); err == nil {
+		t.Error("select should be denied when allow list only contains explain")
+	}
+
+	// 关闭只读保护
+	common.Config.IKnowWhatIAmDoing = true
+	if err := online.guard("delete from t"); err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestWarningsAndQueryCost(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	common.Config.ShowWarnings = true
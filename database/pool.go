@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// replica 只读从库连接池
+type replica struct {
+	Addr string
+	Conn *sql.DB
+}
+
+// secretConnector 配置了外部密码时使用，每次建立新连接时获取密码，认证失败时重新获取一次以支持密码轮换
+type secretConnector struct {
+	dsn *common.Dsn
+}
+
+// Connect 实现 driver.Connector
+func (c secretConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.open(false)
+	if isAccessDenied(err) {
+		common.Log.Warn("access denied for %s, refresh password and retry", c.dsn.Addr)
+		conn, err = c.open(true)
+	}
+	return conn, err
+}
+
+// Driver 实现 driver.Connector
+func (c secretConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+func (c secretConnector) open(refresh bool) (driver.Conn, error) {
+	dsn, err := common.ConnectDSN(c.dsn, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.MySQLDriver{}.Open(dsn)
+}
+
+// isAccessDenied 判断是否为认证失败，密码轮换后缓存的旧密码会导致认证失败
+func isAccessDenied(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && e.Number == 1045 // ER_ACCESS_DENIED_ERROR
+}
+
+// openDB 按 DSN 创建连接池，sql.Open 并不会真正建立连接
+func openDB(dsn *common.Dsn) (*sql.DB, error) {
+	var conn *sql.DB
+	var err error
+	if dsn.HasSecret() {
+		conn = sql.OpenDB(secretConnector{dsn: dsn})
+	} else {
+		conn, err = sql.Open("mysql", common.FormatDSN(dsn))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 空闲连接定期回收，防止被服务端 wait_timeout 断开后复用失效连接
+	conn.SetConnMaxLifetime(time.Hour)
+	return conn, nil
+}
+
+// openReplicas 为 dsn.Replicas 中的每个从库创建连接池，从库与主库使用相同的账号和连接参数
+func openReplicas(dsn *common.Dsn) ([]replica, error) {
+	var replicas []replica
+	for _, addr := range dsn.Replicas {
+		r := *dsn
+		r.Addr = addr
+		r.Replicas = nil
+		conn, err := openDB(&r)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, replica{Addr: addr, Conn: conn})
+	}
+	return replicas, nil
+}
+
+// endpoints 获取执行 SQL 的候选连接池，按尝试顺序排列
+// 只读 SQL(EXPLAIN, SHOW, SELECT) 优先轮询从库，从库全部失败后回退到主库；其余 SQL 只在主库执行
+func (db *Connector) endpoints(sql string) []replica {
+	primary := replica{Addr: db.Addr, Conn: db.Conn}
+	if len(db.replicas) == 0 || !allowedQuery(sql, readOnlyPrefix) {
+		return []replica{primary}
+	}
+
+	start := int(atomic.AddUint32(&db.next, 1)) % len(db.replicas)
+	var endpoints []replica
+	for i := range db.replicas {
+		endpoints = append(endpoints, db.replicas[(start+i)%len(db.replicas)])
+	}
+	return append(endpoints, primary)
+}
+
+// readConn 获取只读操作（如数据采样）使用的连接池，配置了从库时轮询从库
+func (db *Connector) readConn() *sql.DB {
+	if len(db.replicas) == 0 {
+		return db.Conn
+	}
+	return db.replicas[int(atomic.AddUint32(&db.next, 1))%len(db.replicas)].Conn
+}
+
+// Close 关闭主库及所有从库的连接池
+func (db *Connector) Close() error {
+	err := db.Conn.Close()
+	for _, r := range db.replicas {
+		common.LogIfWarn(r.Conn.Close(), "close replica %s", r.Addr)
+	}
+	return err
+}
+
+// isTransientError 判断是否为可以通过重试解决的临时错误，如连接中断、死锁、锁等待超时等
+func isTransientError(err error) bool {
+	// context.DeadlineExceeded 也实现了 net.Error，超时的 SQL 不能重试
+	if err == nil || err == context.DeadlineExceeded || err == context.Canceled {
+		return false
+	}
+	if err == driver.ErrBadConn || err == mysql.ErrInvalidConn {
+		return true
+	}
+	switch e := err.(type) {
+	case net.Error:
+		return true
+	case *mysql.MySQLError:
+		switch e.Number {
+		case 1040, // ER_CON_COUNT_ERROR: Too many connections
+			1053, // ER_SERVER_SHUTDOWN
+			1205, // ER_LOCK_WAIT_TIMEOUT
+			1213: // ER_LOCK_DEADLOCK
+			return true
+		}
+	}
+	return false
+}
+
+// queryRetries SQL 遇到临时错误时的重试次数，连接中断时服务端可能已经执行了 SQL，DDL 及写入的 SQL 不重试
+func queryRetries(sql string) int {
+	if !allowedQuery(sql, readOnlyPrefix) {
+		return 0
+	}
+	return common.Config.QueryRetry
+}
+
+// retryBackoff 第 n 次重试前的等待时间，指数退避
+func retryBackoff(n int) time.Duration {
+	return time.Duration(common.Config.QueryRetryBackoff) * time.Millisecond << uint(n)
+}
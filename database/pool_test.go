@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestEndpoints(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	dsn := *common.Config.TestDSN
+	dsn.Addr = "127.0.0.1:3306"
+	dsn.Replicas = []string{"127.0.0.1:3307", "127.0.0.1:3308"}
+	db, err := NewConnector(&dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 只读 SQL 轮询从库，最后回退到主库
+	for i := 0; i < 2; i++ {
+		endpoints := db.endpoints("explain select 1")
+		if len(endpoints) != 3 || endpoints[2].Addr != dsn.Addr || endpoints[0].Addr == dsn.Addr {
+			t.Errorf("read-only endpoints got: %v", endpoints)
+		}
+	}
+	if db.endpoints("select 1")[0].Addr == db.endpoints("select 1")[0].Addr {
+		t.Error("read-only endpoints should round robin replicas")
+	}
+
+	// 写操作只在主库执行
+	endpoints := db.endpoints("create table t (id int)")
+	if len(endpoints) != 1 || endpoints[0].Addr != dsn.Addr {
+		t.Errorf("write endpoints got: %v", endpoints)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestIsTransientError(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	errs := map[error]bool{
+		nil:                  false,
+		driver.ErrBadConn:    true,
+		mysql.ErrInvalidConn: true,
+		&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}: true,
+		&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}:                    false,
+		errors.New("query execution deny"):                                                 false,
+		context.DeadlineExceeded:                                                           false,
+	}
+	for err, transient := range errs {
+		if isTransientError(err) != transient {
+			t.Errorf("isTransientError(%v) want: %v", err, transient)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestQueryRetries(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgRetry := common.Config.QueryRetry
+	defer func() { common.Config.QueryRetry = orgRetry }()
+	common.Config.QueryRetry = 2
+	for sql, want := range map[string]int{
+		"select 1":                      2,
+		"explain select * from film":    2,
+		"create table t (id int)":       0,
+		"insert into t values (1)":      0,
+		"update t set id = 2":           0,
+		"select * from t for update":    0,
+		"explain analyze delete from t": 0,
+	} {
+		if got := queryRetries(sql); got != want {
+			t.Errorf("queryRetries(%s) want: %d, got: %d", sql, want, got)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestSecretConnector(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	if !isAccessDenied(&mysql.MySQLError{Number: 1045, Message: "Access denied for user 'root'@'localhost'"}) ||
+		isAccessDenied(&mysql.MySQLError{Number: 1146}) || isAccessDenied(nil) {
+		t.Error("isAccessDenied only matches ER_ACCESS_DENIED_ERROR")
+	}
+
+	// 获取密码失败时不会尝试建立连接
+	dsn := *common.Config.TestDSN
+	dsn.PasswordCommand = "exit 1"
+	conn, err := openDB(&dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.Ping(); err == nil || !strings.Contains(err.Error(), "password-command") {
+		t.Errorf("want password-command error, got: %v", err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -38,7 +38,139 @@ type ProfilingRow struct {
 	// TODO: 支持show profile all, 不过目前看所有的信息过多有点眼花缭乱
 }
 
+// Profile 单条 SQL 的执行统计，performance_schema 可用时包含语句级别的计数器，否则只有 SHOW PROFILE 的各阶段耗时
+type Profile struct {
+	Source    string         // performance_schema 或 show profile
+	Stages    []ProfilingRow // 各阶段耗时，单位秒
+	Statement *StatementStat // 语句级别的计数器，SHOW PROFILE 时为 nil
+}
+
+// StatementStat performance_schema.events_statements_history 中的语句统计
+type StatementStat struct {
+	Duration             float64 // 单位秒
+	RowsExamined         int64
+	RowsSent             int64
+	RowsAffected         int64
+	CreatedTmpTables     int64
+	CreatedTmpDiskTables int64
+	SortMergePasses      int64
+	SortRows             int64
+	SelectFullJoin       int64
+	NoIndexUsed          int64
+}
+
+// psTimerUnit performance_schema 中 TIMER_WAIT 的单位为皮秒
+const psTimerUnit = 1e12
+
+// supportPerformanceSchema 检查 performance_schema 是否开启，且记录语句历史的 consumer 已启用
+// consumer 是全局配置，SOAR 不主动修改，未启用时退回 SHOW PROFILE
+// events_stages_history_long 默认不启用，未启用时只有语句统计没有各阶段耗时
+func (db *Connector) supportPerformanceSchema() bool {
+	if ps, err := db.SingleIntValue("performance_schema"); err != nil || ps != 1 {
+		return false
+	}
+	res, err := db.Query("select count(*) from performance_schema.setup_consumers where enabled = 'YES' and name in " +
+		"('events_statements_history', 'thread_instrumentation')")
+	if err != nil {
+		return false
+	}
+	var enabled int
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&enabled)
+	}
+	common.LogIfWarn(res.Rows.Close(), "")
+	return err == nil && enabled == 2
+}
+
+// Profile 执行 SQL 并获取执行统计，performance_schema 可用时使用语句及阶段事件，否则使用已废弃的 SHOW PROFILE
+func (db *Connector) Profile(sql string, params ...interface{}) (*Profile, error) {
+	if db.supportPerformanceSchema() {
+		p, err := db.psProfiling(sql, params...)
+		if err == nil {
+			return p, nil
+		}
+		common.Log.Warning("performance_schema profiling Error: %v, fallback to show profile", err)
+	}
+	rows, err := db.Profiling(sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{Source: "show profile", Stages: rows}, nil
+}
+
+// psProfiling 在同一个连接上执行 SQL，再从 performance_schema 中读取该线程最近一条语句及其各阶段的统计
+func (db *Connector) psProfiling(sql string, params ...interface{}) (*Profile, error) {
+	// 过滤不需要 profiling 的 SQL
+	switch sqlparser.Preview(sql) {
+	case sqlparser.StmtSelect, sqlparser.StmtUpdate, sqlparser.StmtDelete:
+	default:
+		return nil, errors.New("no need profiling")
+	}
+	if common.Config.TestDSN.Disable {
+		return nil, errors.New("dsn is disable")
+	}
+	if err := db.guard(sql, params...); err != nil {
+		return nil, err
+	}
+
+	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", db.Addr, db.Database, sql)
+	// 使用事务保持在同一个连接上，执行后回滚
+	trx, err := db.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if trxErr := trx.Rollback(); trxErr != nil {
+			common.Log.Debug(trxErr.Error())
+		}
+	}()
+
+	var threadID int64
+	err = trx.QueryRow("select thread_id from performance_schema.threads where processlist_id = connection_id()").Scan(&threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 执行 SQL，抛弃返回结果
+	if err = db.txQueryWithTimeout(trx, sql, params...); err != nil {
+		return nil, err
+	}
+
+	p := &Profile{Source: "performance_schema", Statement: &StatementStat{}}
+	var eventID int64
+	var timerWait float64
+	st := p.Statement
+	err = trx.QueryRow(`select event_id, timer_wait, rows_examined, rows_sent, rows_affected,
+		created_tmp_tables, created_tmp_disk_tables, sort_merge_passes, sort_rows, select_full_join, no_index_used
+		from performance_schema.events_statements_history where thread_id = ? order by event_id desc limit 1`, threadID).Scan(
+		&eventID, &timerWait, &st.RowsExamined, &st.RowsSent, &st.RowsAffected,
+		&st.CreatedTmpTables, &st.CreatedTmpDiskTables, &st.SortMergePasses, &st.SortRows, &st.SelectFullJoin, &st.NoIndexUsed)
+	if err != nil {
+		return nil, err
+	}
+	st.Duration = timerWait / psTimerUnit
+
+	res, err := trx.Query(`select event_name, timer_wait from performance_schema.events_stages_history_long
+		where thread_id = ? and nesting_event_id = ? order by event_id`, threadID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	for res.Next() {
+		var row ProfilingRow
+		if err = res.Scan(&row.Status, &timerWait); err != nil {
+			break
+		}
+		// stage/sql/Sending data => Sending data
+		row.Status = strings.TrimPrefix(row.Status, "stage/sql/")
+		row.Duration = timerWait / psTimerUnit
+		p.Stages = append(p.Stages, row)
+	}
+	common.LogIfWarn(res.Close(), "")
+	return p, err
+}
+
 // Profiling 执行SQL，并对其 Profile
+// SHOW PROFILE 已被废弃，建议使用 Profile，performance_schema 不可用时会退回到该方法
 func (db *Connector) Profiling(sql string, params ...interface{}) ([]ProfilingRow, error) {
 	var rows []ProfilingRow
 	// 过滤不需要 profiling 的 SQL
@@ -53,12 +185,9 @@ func (db *Connector) Profiling(sql string, params ...interface{}) ([]ProfilingRo
 		return rows, errors.New("dsn is disable")
 	}
 
-	// 数据库安全性检查：如果 Connector 的 IP 端口与 TEST 环境不一致，则启用 SQL 白名单
-	// 不在白名单中的 SQL 不允许执行
-	// 执行环境与 test 环境不相同
-	if db.Addr != common.Config.TestDSN.Addr && db.dangerousQuery(sql) {
-		return rows, fmt.Errorf("query execution deny: Execute SQL with DSN(%s/%s) '%s'",
-			db.Addr, db.Database, fmt.Sprintf(sql, params...))
+	// 数据库安全性检查：Online 环境只允许执行白名单中的SQL
+	if err := db.guard(sql, params...); err != nil {
+		return rows, err
 	}
 
 	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", db.Addr, db.Database, sql)
@@ -80,13 +209,10 @@ func (db *Connector) Profiling(sql string, params ...interface{}) ([]ProfilingRo
 	common.LogIfError(err, "")
 
 	// 执行 SQL，抛弃返回结果
-	tmpRes, err := trx.Query(sql, params...)
+	err = db.txQueryWithTimeout(trx, sql, params...)
 	if err != nil {
 		return rows, err
 	}
-	for tmpRes.Next() {
-		continue
-	}
 
 	// 返回 Profiling 结果
 	res, err := trx.Query("show profile")
@@ -123,3 +249,23 @@ func FormatProfiling(rows []ProfilingRow) string {
 	}
 	return strings.Join(str, "\n")
 }
+
+// FormatProfile 格式化输出 Profile 信息，包括各阶段耗时及 performance_schema 中的语句统计
+func FormatProfile(p *Profile) string {
+	if p == nil {
+		return ""
+	}
+	var str []string
+	if len(p.Stages) > 0 || p.Statement == nil {
+		str = append(str, FormatProfiling(p.Stages), "")
+	}
+	if st := p.Statement; st != nil {
+		str = append(str,
+			"| Duration | Rows_examined | Rows_sent | Rows_affected | Created_tmp_tables | Created_tmp_disk_tables | Sort_merge_passes | Sort_rows | Select_full_join | No_index_used |",
+			"| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |",
+			fmt.Sprintf("| %f | %d | %d | %d | %d | %d | %d | %d | %d | %d |", st.Duration, st.RowsExamined, st.RowsSent,
+				st.RowsAffected, st.CreatedTmpTables, st.CreatedTmpDiskTables, st.SortMergePasses, st.SortRows,
+				st.SelectFullJoin, st.NoIndexUsed))
+	}
+	return strings.TrimSpace(strings.Join(str, "\n"))
+}
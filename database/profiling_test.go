@@ -17,6 +17,7 @@
 package database
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
@@ -47,3 +48,19 @@ func TestFormatProfiling(t *testing.T) {
 	pretty.Println(FormatProfiling(res))
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestFormatProfile(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	if FormatProfile(nil) != "" {
+		t.Error("nil profile should be empty")
+	}
+	str := FormatProfile(&Profile{
+		Source:    "performance_schema",
+		Stages:    []ProfilingRow{{Status: "executing", Duration: 0.5}},
+		Statement: &StatementStat{RowsExamined: 10, RowsSent: 1},
+	})
+	if !strings.Contains(str, "executing") || !strings.Contains(str, "| 0.000000 | 10 | 1 |") {
+		t.Errorf("got: %s", str)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -89,19 +89,29 @@ func (db *Connector) SamplingData(onlineConn *Connector, tables ...string) error
 		} else {
 			where = common.Config.SamplingCondition
 		}
-		err = db.startSampling(onlineConn.Conn, onlineConn.Database, table, where)
+		err = db.startSampling(onlineConn, table, where)
 	}
 	return err
 }
 
 // startSampling sampling data from OnlineDSN to TestDSN
-func (db *Connector) startSampling(onlineConn *sql.DB, database, table string, where string) error {
+func (db *Connector) startSampling(onlineConn *Connector, table string, where string) error {
 	samplingQuery := fmt.Sprintf("select * from `%s`.`%s` %s",
-		Escape(database, false),
+		Escape(onlineConn.Database, false),
 		Escape(table, false),
 		Escape(where, false))
 	common.Log.Debug("startSampling with Query: %s", samplingQuery)
-	res, err := onlineConn.Query(samplingQuery)
+	// 采样直接使用连接池流式读取，不经过 Query，需要单独做只读检查
+	err := onlineConn.guard(samplingQuery)
+	if err != nil {
+		return err
+	}
+	var res *sql.Rows
+	if queryTimeout() > 0 {
+		res, err = onlineConn.queryWithTimeout(onlineConn.readConn(), samplingQuery)
+	} else {
+		res, err = onlineConn.readConn().Query(samplingQuery)
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// ServerVariables 评审报告中记录的线上环境变量，这些变量会影响排序、临时表及索引等建议
+var ServerVariables = []string{"innodb_buffer_pool_size", "sort_buffer_size", "tmp_table_size"}
+
+// ServerInfo 评审时线上环境的版本、sql_mode 及相关变量，记录在报告中便于之后复现及理解建议
+type ServerInfo struct {
+	Addr       string            `json:"Addr"`
+	Version    string            `json:"Version"`
+	SQLMode    string            `json:"SQLMode"`
+	Variables  map[string]string `json:"Variables,omitempty"`
+	CapturedAt time.Time         `json:"CapturedAt"`
+}
+
+// ServerInfo 获取线上环境的版本、全局 sql_mode 及 ServerVariables 中的变量，不存在的变量不记录
+func (db *Connector) ServerInfo() (*ServerInfo, error) {
+	vars, err := db.GlobalVariables(append([]string{"version", "sql_mode"}, ServerVariables...)...)
+	if err != nil {
+		return nil, err
+	}
+	info := &ServerInfo{Addr: db.Addr, Version: vars["version"], SQLMode: vars["sql_mode"], Variables: make(map[string]string), CapturedAt: time.Now()}
+	for _, name := range ServerVariables {
+		if value, ok := vars[name]; ok {
+			info.Variables[name] = value
+		}
+	}
+	return info, nil
+}
+
+// GlobalVariables 获取全局变量的值，返回值的 key 为小写的变量名，不存在的变量不返回
+func (db *Connector) GlobalVariables(names ...string) (map[string]string, error) {
+	res, err := db.Query(fmt.Sprintf("SHOW GLOBAL VARIABLES WHERE Variable_name IN ('%s')", strings.Join(names, "', '")))
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for res.Rows.Next() {
+		var name, value string
+		if err = res.Rows.Scan(&name, &value); err != nil {
+			break
+		}
+		vars[strings.ToLower(name)] = value
+	}
+	if cErr := res.Rows.Close(); cErr != nil {
+		common.Log.Error(cErr.Error())
+	}
+	return vars, err
+}
+
+// FormatServerInfo 以 Markdown 格式输出评审环境，作为报告的开头，info 为 nil 时返回空
+func FormatServerInfo(info *ServerInfo) string {
+	if info == nil {
+		return ""
+	}
+	buf := []string{
+		"# 评审环境",
+		"",
+		fmt.Sprintf("* **评审时间:**  %s", info.CapturedAt.Format("2006-01-02 15:04:05 -0700")),
+		"",
+		fmt.Sprintf("* **线上环境:**  %s", info.Addr),
+		"",
+		fmt.Sprintf("* **版本:**  %s", info.Version),
+		"",
+		fmt.Sprintf("* **sql_mode:**  %s", info.SQLMode),
+	}
+	for _, name := range ServerVariables {
+		value, ok := info.Variables[name]
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			value = fmt.Sprintf("%s (%s)", value, FormatBytes(n))
+		}
+		buf = append(buf, "", fmt.Sprintf("* **%s:**  %s", name, value))
+	}
+	return strings.Join(buf, "\n") + "\n"
+}
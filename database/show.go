@@ -217,6 +217,8 @@ func (db *Connector) ShowIndex(tableName string) (*TableIndexInfo, error) {
 
 	// columns info
 	ti := TableIndexRow{}
+	// Collation, Cardinality, Sub_part, Packed 可能为 NULL，直接 Scan 会报错并导致后续列取值错误
+	var collation, cardinality, subPart, packed []byte
 	indexFields := make([]interface{}, 0)
 	fields := map[string]interface{}{
 		"Table":         &ti.Table,
@@ -224,10 +226,10 @@ func (db *Connector) ShowIndex(tableName string) (*TableIndexInfo, error) {
 		"Key_name":      &ti.KeyName,
 		"Seq_in_index":  &ti.SeqInIndex,
 		"Column_name":   &ti.ColumnName,
-		"Collation":     &ti.Collation,
-		"Cardinality":   &ti.Cardinality,
-		"Sub_part":      &ti.SubPart,
-		"Packed":        &ti.Packed,
+		"Collation":     &collation,
+		"Cardinality":   &cardinality,
+		"Sub_part":      &subPart,
+		"Packed":        &packed,
 		"Null":          &ti.Null,
 		"Index_type":    &ti.IndexType,
 		"Comment":       &ti.Comment,
@@ -252,6 +254,10 @@ func (db *Connector) ShowIndex(tableName string) (*TableIndexInfo, error) {
 		if err != nil {
 			common.Log.Debug(err.Error())
 		}
+		ti.Collation = string(collation)
+		ti.Cardinality, _ = strconv.Atoi(string(cardinality))
+		ti.SubPart, _ = strconv.Atoi(string(subPart))
+		ti.Packed, _ = strconv.Atoi(string(packed))
 		tbIndex.Rows = append(tbIndex.Rows, ti)
 	}
 	res.Rows.Close()
@@ -269,6 +275,37 @@ const (
 	IndexNonUnique  = IndexSelectKey("NonUnique")  // 唯一索引
 )
 
+// UniqueKeys 返回主键及唯一键，每个元素为按索引顺序排列的列名，包含表达式的唯一键不返回
+func (tbIndex *TableIndexInfo) UniqueKeys() [][]string {
+	var keys [][]string
+	pos := make(map[string]int)
+	skip := make(map[string]bool)
+	for _, row := range tbIndex.Rows {
+		if row.NonUnique != 0 {
+			continue
+		}
+		if row.ColumnName == "" {
+			skip[row.KeyName] = true
+			continue
+		}
+		i, ok := pos[row.KeyName]
+		if !ok {
+			i = len(keys)
+			pos[row.KeyName] = i
+			keys = append(keys, nil)
+		}
+		keys[i] = append(keys[i], row.ColumnName)
+	}
+	var res [][]string
+	for _, row := range tbIndex.Rows {
+		if i, ok := pos[row.KeyName]; ok && !skip[row.KeyName] {
+			res = append(res, keys[i])
+			delete(pos, row.KeyName)
+		}
+	}
+	return res
+}
+
 // FindIndex 获取 TableIndexInfo 中需要的索引
 func (tbIndex *TableIndexInfo) FindIndex(arg IndexSelectKey, value string) []TableIndexRow {
 	var result []TableIndexRow
@@ -496,12 +533,42 @@ func (db *Connector) ShowCreateTable(tableName string) (string, error) {
 	return ddl, err
 }
 
+// ShowCreateTableFull show create table，与 ShowCreateTable 不同，保留外键约束
+func (db *Connector) ShowCreateTableFull(tableName string) (string, error) {
+	defer func() {
+		err := recover()
+		if err != nil {
+			common.Log.Error("recover ShowCreateTableFull()", err)
+		}
+	}()
+	return db.showCreate("TABLE", tableName)
+}
+
+// ShowViewDefinition 从 information_schema.VIEWS 获取视图定义，不是视图时返回空字符串
+func (db *Connector) ShowViewDefinition(dbName, viewName string) (string, error) {
+	if dbName == "" {
+		dbName = db.Database
+	}
+	res, err := db.Query(fmt.Sprintf("SELECT VIEW_DEFINITION FROM information_schema.VIEWS WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s'",
+		Escape(dbName, false), Escape(viewName, false)))
+	if err != nil {
+		return "", err
+	}
+	var def string
+	if res.Rows.Next() {
+		err = res.Rows.Scan(&def)
+	}
+	res.Rows.Close()
+	return def, err
+}
+
 // FindColumn find column
 func (db *Connector) FindColumn(name, dbName string, tables ...string) ([]*common.Column, error) {
 	// 执行 show create table
 	var columns []*common.Column
 	sql := fmt.Sprintf("SELECT "+
-		"c.TABLE_NAME,c.TABLE_SCHEMA,c.COLUMN_TYPE,c.CHARACTER_SET_NAME, c.COLLATION_NAME "+
+		"c.TABLE_NAME,c.TABLE_SCHEMA,c.COLUMN_TYPE,c.CHARACTER_SET_NAME, c.COLLATION_NAME, "+
+		"c.IS_NULLABLE, c.COLUMN_DEFAULT, c.COLUMN_COMMENT "+
 		"FROM `INFORMATION_SCHEMA`.`COLUMNS` as c where c.COLUMN_NAME = '%s' ", Escape(name, false))
 
 	if dbName != "" {
@@ -525,14 +592,16 @@ func (db *Connector) FindColumn(name, dbName string, tables ...string) ([]*commo
 
 	var col common.Column
 	for res.Rows.Next() {
-		var character, collation []byte
-		err = res.Rows.Scan(&col.Table, &col.DB, &col.DataType, &character, &collation)
+		var character, collation, colDefault []byte
+		err = res.Rows.Scan(&col.Table, &col.DB, &col.DataType, &character, &collation,
+			&col.Null, &colDefault, &col.Comment)
 		if err != nil {
 			break
 		}
 		col.Name = name
 		col.Character = string(character)
 		col.Collation = string(collation)
+		col.Default = string(colDefault)
 		// 填充字符集和排序规则
 		if col.Character == "" {
 			// 当从 `INFORMATION_SCHEMA`.`COLUMNS` 表中查询不到相关列的 character 和 collation 的信息时
@@ -569,6 +638,45 @@ func (db *Connector) FindColumn(name, dbName string, tables ...string) ([]*commo
 	return columns, err
 }
 
+// AutoIncrementInfo 自增列信息
+type AutoIncrementInfo struct {
+	Table         string
+	Column        string
+	ColumnType    string // 列类型，如 int(10) unsigned
+	Nullable      string // 是否可以为 NULL（NO、YES）
+	AutoIncrement uint64 // 下一个自增值
+}
+
+// ShowAutoIncrement 获取表中自增列的类型及当前 AUTO_INCREMENT 值
+// MySQL 8.0 中 information_schema.TABLES 的统计信息有缓存，AUTO_INCREMENT 可能滞后于实际值
+func (db *Connector) ShowAutoIncrement(tableName string) ([]AutoIncrementInfo, error) {
+	var incs []AutoIncrementInfo
+	sql := fmt.Sprintf("SELECT C.COLUMN_NAME, C.COLUMN_TYPE, C.IS_NULLABLE, T.AUTO_INCREMENT FROM INFORMATION_SCHEMA.TABLES T "+
+		"JOIN INFORMATION_SCHEMA.COLUMNS C ON T.TABLE_SCHEMA = C.TABLE_SCHEMA AND T.TABLE_NAME = C.TABLE_NAME "+
+		"WHERE T.TABLE_SCHEMA = '%s' AND T.TABLE_NAME = '%s' AND C.EXTRA LIKE '%%auto_increment%%'",
+		Escape(db.Database, false), Escape(tableName, false))
+
+	common.Log.Debug("ShowAutoIncrement, execute SQL: %s", sql)
+	res, err := db.Query(sql)
+	if err != nil {
+		return incs, err
+	}
+
+	// 获取值，表中没有数据时 AUTO_INCREMENT 可能为 NULL
+	for res.Rows.Next() {
+		inc := AutoIncrementInfo{Table: tableName}
+		var autoIncrement []byte
+		err = res.Rows.Scan(&inc.Column, &inc.ColumnType, &inc.Nullable, &autoIncrement)
+		if err != nil {
+			break
+		}
+		inc.AutoIncrement, _ = strconv.ParseUint(string(autoIncrement), 10, 64)
+		incs = append(incs, inc)
+	}
+	res.Rows.Close()
+	return incs, err
+}
+
 // IsForeignKey 判断列是否是外键
 func (db *Connector) IsForeignKey(dbName, tbName, column string) bool {
 	sql := fmt.Sprintf("SELECT REFERENCED_COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE C "+
@@ -636,3 +744,38 @@ func (db *Connector) ShowReference(dbName string, tbName ...string) ([]Reference
 	res.Rows.Close()
 	return referenceValues, err
 }
+
+// ForeignKeyColumn 外键中的一列，多列外键按列的顺序对应多条记录
+type ForeignKeyColumn struct {
+	ConstraintName       string // 外键名称
+	TableName            string // 子表
+	ColumnName           string // 子表中的列
+	ReferencedTableName  string // 父表
+	ReferencedColumnName string // 父表中被引用的列
+}
+
+// ShowForeignKeys 获取表上定义的外键及对应的列
+func (db *Connector) ShowForeignKeys(tableName string) ([]ForeignKeyColumn, error) {
+	var fks []ForeignKeyColumn
+	sql := fmt.Sprintf("SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME "+
+		"FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s' "+
+		"AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION",
+		Escape(db.Database, false), Escape(tableName, false))
+
+	common.Log.Debug("ShowForeignKeys, execute SQL: %s", sql)
+	res, err := db.Query(sql)
+	if err != nil {
+		return fks, err
+	}
+
+	for res.Rows.Next() {
+		var fk ForeignKeyColumn
+		err = res.Rows.Scan(&fk.ConstraintName, &fk.TableName, &fk.ColumnName, &fk.ReferencedTableName, &fk.ReferencedColumnName)
+		if err != nil {
+			break
+		}
+		fks = append(fks, fk)
+	}
+	res.Rows.Close()
+	return fks, err
+}
@@ -129,6 +129,22 @@ func TestShowIndex(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestUniqueKeys(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	ti := &TableIndexInfo{TableName: "city", Rows: []TableIndexRow{
+		{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "city_id"},
+		{KeyName: "idx_fk_country_id", NonUnique: 1, SeqInIndex: 1, ColumnName: "country_id"},
+		{KeyName: "uk_country_city", SeqInIndex: 1, ColumnName: "country_id"},
+		{KeyName: "uk_country_city", SeqInIndex: 2, ColumnName: "city"},
+		{KeyName: "uk_expr", SeqInIndex: 1, Expression: []byte("lower(`city`)")},
+	}}
+	got := fmt.Sprint(ti.UniqueKeys())
+	if got != "[[city_id] [country_id city]]" {
+		t.Errorf("UniqueKeys got: %s", got)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestShowColumns(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	orgDatabase := connTest.Database
@@ -172,6 +188,27 @@ func TestIsFKey(t *testing.T) {
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
 
+func TestShowForeignKeys(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgDatabase := connTest.Database
+	connTest.Database = "sakila"
+	fks, err := connTest.ShowForeignKeys("film")
+	if err != nil {
+		t.Error("ShowForeignKeys Error: ", err)
+	}
+	var found bool
+	for _, fk := range fks {
+		if fk.ColumnName == "language_id" && fk.ReferencedTableName == "language" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("film.language_id should reference language, got: %v", fks)
+	}
+	connTest.Database = orgDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
 func TestShowReference(t *testing.T) {
 	common.Log.Debug("Entering function: %s", common.GetFunctionName())
 	rv, err := connTest.ShowReference("sakila", "film")
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// SpillInfo 估算 filesort 及内部临时表是否使用磁盘所需的配置及表信息
+type SpillInfo struct {
+	SortBufferSize int64            // sort_buffer_size，排序数据超过该值时需要借助磁盘临时文件归并排序
+	TmpTableSize   int64            // tmp_table_size 与 max_heap_table_size 中较小的值，内存临时表超过该值时转为磁盘临时表
+	RowLength      map[string]int64 // EXPLAIN 中的表名（或别名）-> SHOW TABLE STATUS 中的 Avg_row_length
+	BufferPoolSize int64            // innodb_buffer_pool_size，获取失败时为 0
+	DataLength     map[string]int64 // EXPLAIN 中的表名（或别名）-> InnoDB 表的 Data_length，即聚簇索引的大小
+}
+
+// SpillInfo 获取估算排序及临时表是否使用磁盘所需的配置，tables 为 EXPLAIN 中的表名（或别名）到实际表名的映射
+func (db *Connector) SpillInfo(tables map[string]string) (*SpillInfo, error) {
+	info := &SpillInfo{RowLength: make(map[string]int64), DataLength: make(map[string]int64)}
+	sortBuffer, err := db.SingleIntValue("sort_buffer_size")
+	if err != nil {
+		return nil, err
+	}
+	tmpTable, err := db.SingleIntValue("tmp_table_size")
+	if err != nil {
+		return nil, err
+	}
+	maxHeap, err := db.SingleIntValue("max_heap_table_size")
+	if err != nil {
+		return nil, err
+	}
+	if maxHeap < tmpTable {
+		tmpTable = maxHeap
+	}
+	info.SortBufferSize, info.TmpTableSize = int64(sortBuffer), int64(tmpTable)
+	// TiDB 等不使用 InnoDB Buffer Pool 的环境中该变量可能不存在，不影响排序及临时表的估算
+	if pool, err := db.SingleIntValue("innodb_buffer_pool_size"); err == nil {
+		info.BufferPoolSize = int64(pool)
+	}
+
+	for alias, table := range tables {
+		status, err := db.ShowTableStatus(table)
+		if err != nil || len(status.Rows) == 0 {
+			common.Log.Debug("SpillInfo ShowTableStatus %s Error: %v", table, err)
+			continue
+		}
+		info.RowLength[alias] = int64(status.Rows[0].AvgRowLength)
+		if strings.EqualFold(string(status.Rows[0].Engine), "InnoDB") {
+			info.DataLength[alias] = int64(status.Rows[0].DataLength)
+		}
+	}
+	return info, nil
+}
+
+// FormatBytes 将字节数格式化为便于阅读的形式，如 256KB, 1.5MB
+func FormatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	s := strings.TrimSuffix(strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), "0"), ".")
+	return s + units[i]
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestFormatBytes(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	for n, expect := range map[int64]string{
+		0:               "0B",
+		512:             "512B",
+		256 << 10:       "256KB",
+		3 << 19:         "1.5MB",
+		16 << 20:        "16MB",
+		5 << 30:         "5GB",
+		(1 << 40) * 100: "100TB",
+	} {
+		if s := FormatBytes(n); s != expect {
+			t.Errorf("FormatBytes(%d) want %s, got %s", n, expect, s)
+		}
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// 统计信息迁移：不从线上拉取任何数据，只将 InnoDB 持久化统计信息复制到测试环境
+// https://dev.mysql.com/doc/refman/8.0/en/innodb-persistent-stats.html
+
+// InnoDBTableStat mysql.innodb_table_stats 中的一行
+type InnoDBTableStat struct {
+	NRows                uint64
+	ClusteredIndexSize   uint64
+	SumOfOtherIndexSizes uint64
+	LastUpdate           string
+	Estimated            bool // 线上未开启持久化统计信息时，由 SHOW TABLE STATUS 估算得到
+}
+
+// InnoDBIndexStat mysql.innodb_index_stats 中的一行
+type InnoDBIndexStat struct {
+	IndexName       string
+	StatName        string
+	StatValue       uint64
+	SampleSize      []byte
+	StatDescription string
+}
+
+// ColumnHistogram information_schema.COLUMN_STATISTICS 中的一行，MySQL 8.0 才有直方图
+type ColumnHistogram struct {
+	ColumnName string
+	Histogram  string
+}
+
+// TransferStatistics 将 onlineConn 中表的统计信息复制到 db 中，用于代替数据采样
+// db.Database 为测试环境中 hash 过的库名
+func (db *Connector) TransferStatistics(onlineConn *Connector, tables ...string) error {
+	if onlineConn.Database == db.Database {
+		return fmt.Errorf("TransferStatistics the same database, From: %s/%s, To: %s/%s", onlineConn.Addr, onlineConn.Database, db.Addr, db.Database)
+	}
+
+	for _, table := range tables {
+		// 视图没有统计信息
+		if onlineConn.IsView(table) {
+			continue
+		}
+
+		tableStat, err := onlineConn.innodbTableStats(table)
+		if err != nil {
+			return err
+		}
+
+		indexStats, err := onlineConn.innodbIndexStats(table)
+		if err != nil {
+			return err
+		}
+
+		// 关闭统计信息自动更新，防止测试环境空表的统计信息覆盖复制过来的值
+		err = db.execStatistics(fmt.Sprintf("alter table `%s`.`%s` stats_persistent = 1, stats_auto_recalc = 0",
+			Escape(db.Database, false), Escape(table, false)))
+		if err != nil {
+			return err
+		}
+
+		err = db.writeTableStats(table, tableStat)
+		if err != nil {
+			return err
+		}
+
+		err = db.writeIndexStats(table, indexStats)
+		if err != nil {
+			return err
+		}
+
+		// FLUSH TABLE 后 InnoDB 会重新从 mysql.innodb_*_stats 中加载统计信息
+		err = db.execStatistics(fmt.Sprintf("flush table `%s`.`%s`", Escape(db.Database, false), Escape(table, false)))
+		if err != nil {
+			return err
+		}
+
+		// 直方图复制失败不影响执行计划的主体，只记录日志
+		common.LogIfWarn(db.transferHistograms(onlineConn, table), "TransferStatistics histogram")
+	}
+	return nil
+}
+
+// innodbTableStats 读取 mysql.innodb_table_stats，未开启持久化统计信息时使用 SHOW TABLE STATUS 估算
+func (db *Connector) innodbTableStats(table string) (*InnoDBTableStat, error) {
+	stat := &InnoDBTableStat{}
+	res, err := db.Query(fmt.Sprintf("select n_rows, clustered_index_size, sum_of_other_index_sizes, last_update from mysql.innodb_table_stats where database_name = '%s' and table_name = '%s'",
+		Escape(db.Database, false), Escape(table, false)))
+	if err == nil {
+		found := false
+		for res.Rows.Next() {
+			err = res.Rows.Scan(&stat.NRows, &stat.ClusteredIndexSize, &stat.SumOfOtherIndexSizes, &stat.LastUpdate)
+			found = true
+		}
+		res.Rows.Close()
+		if found {
+			return stat, err
+		}
+	}
+	common.Log.Debug("innodbTableStats, %s.%s no persistent stats, Error: %v, use table status instead", db.Database, table, err)
+
+	tbStatus, err := db.ShowTableStatus(table)
+	if err != nil {
+		return stat, err
+	}
+	if len(tbStatus.Rows) > 0 {
+		// InnoDB 默认页大小 16KB
+		stat.NRows = tbStatus.Rows[0].Rows
+		stat.ClusteredIndexSize = tbStatus.Rows[0].DataLength / 16384
+		stat.SumOfOtherIndexSizes = tbStatus.Rows[0].IndexLength / 16384
+	}
+	stat.Estimated = true
+	return stat, nil
+}
+
+// innodbIndexStats 读取 mysql.innodb_index_stats
+func (db *Connector) innodbIndexStats(table string) ([]InnoDBIndexStat, error) {
+	var stats []InnoDBIndexStat
+	res, err := db.Query(fmt.Sprintf("select index_name, stat_name, stat_value, sample_size, stat_description from mysql.innodb_index_stats where database_name = '%s' and table_name = '%s'",
+		Escape(db.Database, false), Escape(table, false)))
+	if err != nil {
+		// 没有权限读取 mysql 库时退化为只复制表级统计信息
+		common.Log.Warn("innodbIndexStats, %s.%s Error: %v", db.Database, table, err)
+		return stats, nil
+	}
+	for res.Rows.Next() {
+		var s InnoDBIndexStat
+		err = res.Rows.Scan(&s.IndexName, &s.StatName, &s.StatValue, &s.SampleSize, &s.StatDescription)
+		if err != nil {
+			break
+		}
+		stats = append(stats, s)
+	}
+	res.Rows.Close()
+	return stats, err
+}
+
+// writeTableStats 将表级统计信息写入测试环境
+func (db *Connector) writeTableStats(table string, stat *InnoDBTableStat) error {
+	// 测试环境的表是空表，clustered_index_size 至少为 1
+	if stat.ClusteredIndexSize == 0 {
+		stat.ClusteredIndexSize = 1
+	}
+	return db.execStatistics(fmt.Sprintf("replace into mysql.innodb_table_stats (database_name, table_name, last_update, n_rows, clustered_index_size, sum_of_other_index_sizes) values ('%s', '%s', now(), %d, %d, %d)",
+		Escape(db.Database, false), Escape(table, false),
+		stat.NRows, stat.ClusteredIndexSize, stat.SumOfOtherIndexSizes))
+}
+
+// writeIndexStats 将索引级统计信息写入测试环境
+func (db *Connector) writeIndexStats(table string, stats []InnoDBIndexStat) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	var values []string
+	for _, s := range stats {
+		sampleSize := "NULL"
+		if s.SampleSize != nil {
+			sampleSize = string(s.SampleSize)
+		}
+		values = append(values, fmt.Sprintf("('%s', '%s', '%s', now(), '%s', %d, %s, '%s')",
+			Escape(db.Database, false), Escape(table, false), Escape(s.IndexName, false),
+			Escape(s.StatName, false), s.StatValue, sampleSize, Escape(s.StatDescription, false)))
+	}
+	return db.execStatistics(fmt.Sprintf("replace into mysql.innodb_index_stats (database_name, table_name, index_name, last_update, stat_name, stat_value, sample_size, stat_description) values %s",
+		strings.Join(values, ",")))
+}
+
+// execStatistics 在测试环境执行统计信息相关的写操作
+func (db *Connector) execStatistics(query string) error {
+	res, err := db.Query(query)
+	if res.Rows != nil {
+		res.Rows.Close()
+	}
+	return err
+}
+
+// transferHistograms 复制 MySQL 8.0 的直方图，UPDATE HISTOGRAM ... USING DATA 需要 8.0.31 及以上版本
+func (db *Connector) transferHistograms(onlineConn *Connector, table string) error {
+	version, err := db.Version()
+	if err != nil || version < 80031 {
+		return err
+	}
+
+	res, err := onlineConn.Query(fmt.Sprintf("select column_name, histogram from information_schema.column_statistics where schema_name = '%s' and table_name = '%s'",
+		Escape(onlineConn.Database, false), Escape(table, false)))
+	if err != nil {
+		return err
+	}
+	var histograms []ColumnHistogram
+	for res.Rows.Next() {
+		var h ColumnHistogram
+		err = res.Rows.Scan(&h.ColumnName, &h.Histogram)
+		if err != nil {
+			break
+		}
+		histograms = append(histograms, h)
+	}
+	res.Rows.Close()
+
+	for _, h := range histograms {
+		err = db.execStatistics(fmt.Sprintf("analyze table `%s`.`%s` update histogram on `%s` using data '%s'",
+			Escape(db.Database, false), Escape(table, false), Escape(h.ColumnName, false), Escape(h.Histogram, false)))
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// IndexCardinality 根据索引统计信息估算列的散粒度，在测试环境中没有数据时使用
+// 只有当列是某个索引的第一列时才能估算，否则返回 -1
+func (db *Connector) IndexCardinality(tb, col string, rowTotal uint64) float64 {
+	if rowTotal == 0 {
+		return -1
+	}
+	idxInfo, err := db.ShowIndex(tb)
+	if err != nil {
+		common.Log.Warn("(db *Connector) IndexCardinality() ShowIndex Error: %v", err)
+		return -1
+	}
+	cardinality := -1.0
+	for _, row := range idxInfo.Rows {
+		if row.SeqInIndex == 1 && strings.EqualFold(row.ColumnName, col) {
+			c := float64(row.Cardinality) / float64(rowTotal)
+			if c > cardinality {
+				cardinality = c
+			}
+		}
+	}
+	if cardinality > 1 {
+		cardinality = 1
+	}
+	return cardinality
+}
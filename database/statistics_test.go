@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+
+	"github.com/XiaoMi/soar/common"
+
+	"github.com/kr/pretty"
+)
+
+func TestTransferStatistics(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	// 同一个库不允许迁移统计信息
+	err := connTest.TransferStatistics(connTest, "film")
+	if err == nil {
+		t.Error("TransferStatistics to the same database should return error")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestInnodbTableStats(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	originalDatabase := connTest.Database
+	connTest.Database = "sakila"
+	stat, err := connTest.innodbTableStats("film")
+	if err != nil {
+		t.Error(err)
+	}
+	if stat.NRows == 0 {
+		t.Error("sakila.film should not be empty")
+	}
+	indexStats, err := connTest.innodbIndexStats("film")
+	if err != nil {
+		t.Error(err)
+	}
+	pretty.Println(indexStats)
+	connTest.Database = originalDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
+
+func TestIndexCardinality(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	originalDatabase := connTest.Database
+	connTest.Database = "sakila"
+	if c := connTest.IndexCardinality("film", "film_id", 1000); c <= 0 || c > 1 {
+		t.Errorf("film.film_id cardinality should in (0, 1], got %f", c)
+	}
+	if c := connTest.IndexCardinality("film", "description", 1000); c != -1 {
+		t.Errorf("film.description is not indexed, want -1, got %f", c)
+	}
+	if c := connTest.IndexCardinality("film", "film_id", 0); c != -1 {
+		t.Errorf("empty table want -1, got %f", c)
+	}
+	connTest.Database = originalDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
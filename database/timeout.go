@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+// queryTimeout 单条 SQL 的超时时间，0 表示不限制
+func queryTimeout() time.Duration {
+	return time.Duration(common.Config.QueryTimeout) * time.Second
+}
+
+// killOnTimeout 返回带超时的 context，超时后通过 pool 中的其他连接在服务端 KILL QUERY connID
+// 仅关闭客户端连接并不能终止服务端正在执行的 SQL
+func (db *Connector) killOnTimeout(pool *sql.DB, connID int64) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout())
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		common.Log.Warn("query timeout after %s, KILL QUERY %d with DSN(%s/%s)", queryTimeout(), connID, db.Addr, db.Database)
+		_, err := pool.Exec(fmt.Sprintf("KILL QUERY %d", connID))
+		common.LogIfWarn(err, "")
+	}()
+	return ctx, cancel
+}
+
+// timeoutError 超时后驱动返回的可能是连接中断等错误，统一转换为超时错误，避免被当作临时错误重试
+func (db *Connector) timeoutError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("query timeout after %s with DSN(%s/%s)", queryTimeout(), db.Addr, db.Database)
+	}
+	return err
+}
+
+// queryWithTimeout 在独占的连接上执行 SQL，超过 query-timeout 后在服务端 KILL QUERY
+// 返回的 Rows 关闭后连接才会归还连接池
+func (db *Connector) queryWithTimeout(pool *sql.DB, query string, params ...interface{}) (*sql.Rows, error) {
+	conn, err := pool.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var connID int64
+	err = conn.QueryRowContext(context.Background(), "select connection_id()").Scan(&connID)
+	if err == nil && db.Database != "" {
+		_, err = conn.ExecContext(context.Background(), "USE "+db.Database)
+	}
+	if err != nil {
+		common.LogIfWarn(conn.Close(), "")
+		return nil, err
+	}
+
+	ctx, cancel := db.killOnTimeout(pool, connID)
+	rows, err := conn.QueryContext(ctx, query, params...)
+	if err != nil {
+		err = db.timeoutError(ctx, err)
+		cancel()
+		common.LogIfWarn(conn.Close(), "")
+		return nil, err
+	}
+
+	// Conn.Close 会等待 Rows 关闭，因此在后台归还连接
+	go func() {
+		common.LogIfWarn(conn.Close(), "")
+		cancel()
+	}()
+	return rows, nil
+}
+
+// txQueryWithTimeout 在事务中执行 SQL 并丢弃结果，超过 query-timeout 后在服务端 KILL QUERY
+// 用于 Profiling、Trace 等需要保持同一个连接的场景
+func (db *Connector) txQueryWithTimeout(trx *sql.Tx, query string, params ...interface{}) error {
+	if queryTimeout() <= 0 {
+		return discardRows(trx.Query(query, params...))
+	}
+
+	var connID int64
+	err := trx.QueryRow("select connection_id()").Scan(&connID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := db.killOnTimeout(db.Conn, connID)
+	defer cancel()
+	return db.timeoutError(ctx, discardRows(trx.QueryContext(ctx, query, params...)))
+}
+
+// discardRows 读取并丢弃全部返回结果
+func discardRows(res *sql.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	for res.Next() {
+		continue
+	}
+	err = res.Err()
+	common.LogIfWarn(res.Close(), "")
+	return err
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 Xiaomi, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XiaoMi/soar/common"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgQueryTimeout := common.Config.QueryTimeout
+	defer func() {
+		common.Config.QueryTimeout = orgQueryTimeout
+	}()
+	common.Config.QueryTimeout = 1
+
+	start := time.Now()
+	res, err := connTest.Query("select sleep(10)")
+	if err == nil {
+		// 服务端 KILL QUERY 后 sleep 提前返回
+		for res.Rows.Next() {
+			continue
+		}
+		res.Rows.Close()
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("query timeout not work, cost: %s", time.Since(start))
+	}
+
+	// 超时后连接仍然可用
+	common.Config.QueryTimeout = 0
+	if _, err = connTest.Version(); err != nil {
+		t.Error(err)
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
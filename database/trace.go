@@ -20,10 +20,12 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/XiaoMi/soar/common"
 
+	"github.com/tidwall/gjson"
 	"vitess.io/vitess/go/vt/sqlparser"
 )
 
@@ -62,12 +64,9 @@ func (db *Connector) Trace(sql string, params ...interface{}) ([]TraceRow, error
 		return rows, errors.New("dsn is disable")
 	}
 
-	// 数据库安全性检查：如果 Connector 的 IP 端口与 TEST 环境不一致，则启用SQL白名单
-	// 不在白名单中的SQL不允许执行
-	// 执行环境与test环境不相同
-	if db.Addr != common.Config.TestDSN.Addr && db.dangerousQuery(sql) {
-		return rows, fmt.Errorf("query Execution Deny: Execute SQL with DSN(%s/%s) '%s'",
-			db.Addr, db.Database, fmt.Sprintf(sql, params...))
+	// 数据库安全性检查：Online 环境只允许执行白名单中的SQL
+	if err := db.guard(sql, params...); err != nil {
+		return rows, err
 	}
 
 	common.Log.Debug("Execute SQL with DSN(%s/%s) : %s", db.Addr, db.Database, sql)
@@ -87,13 +86,10 @@ func (db *Connector) Trace(sql string, params ...interface{}) ([]TraceRow, error
 	common.LogIfError(err, "")
 
 	// 执行SQL，抛弃返回结果
-	tmpRes, err := trx.Query(sql, params...)
+	err = db.txQueryWithTimeout(trx, sql, params...)
 	if err != nil {
 		return rows, err
 	}
-	for tmpRes.Next() {
-		continue
-	}
 
 	// 返回Trace结果
 	res, err := trx.Query("SELECT * FROM information_schema.OPTIMIZER_TRACE")
@@ -137,3 +133,253 @@ func FormatTrace(rows []TraceRow) string {
 	}
 	return strings.Join(str, "\n")
 }
+
+// TraceSummary 从 optimizer_trace 中提取的优化器决策过程
+type TraceSummary struct {
+	Ranges []TraceRange  // rows_estimation 中各表的 range 分析
+	Paths  []TraceAccess // considered_execution_plans 中考虑过的访问方式
+}
+
+// TraceRange 单表的 range 分析
+type TraceRange struct {
+	Select   int64
+	Table    string
+	ScanRows float64 // 全表扫描的行数
+	ScanCost float64 // 全表扫描的代价
+	Indexes  []TraceAccess
+}
+
+// TraceAccess 优化器考虑过的一种访问方式
+type TraceAccess struct {
+	Select int64
+	Prefix []string // 连接顺序中已经确定的表
+	Table  string
+	Type   string // 访问方式，如 ref, range, scan
+	Index  string
+	Rows   float64
+	Cost   float64
+	Chosen bool
+	Cause  string // 未被选择的原因
+}
+
+// traceCauses 常见的未选择原因
+var traceCauses = map[string]string{
+	"cost":                     "代价高于已选择的访问方式",
+	"not_applicable":           "查询条件无法使用该索引",
+	"heuristic_index_cheaper":  "已有代价更低的 ref 访问",
+	"range_uses_more_keyparts": "range 访问能够使用更多的索引列",
+	"pruned_by_cost":           "连接顺序的代价过高，被剪枝",
+	"pruned_by_heuristic":      "连接顺序被启发式规则剪枝",
+	"unknown":                  "原因未知",
+}
+
+// ParseTrace 解析 optimizer_trace 中的 range 分析及执行计划选择过程
+func ParseTrace(trace string) (*TraceSummary, error) {
+	if !gjson.Valid(trace) {
+		return nil, errors.New("invalid optimizer trace json")
+	}
+	s := &TraceSummary{}
+	s.walk(gjson.Parse(trace), 0)
+	return s, nil
+}
+
+// walk 遍历 Trace，子查询、派生表的 join_optimization 会嵌套出现
+func (s *TraceSummary) walk(res gjson.Result, sel int64) {
+	if res.IsArray() {
+		for _, r := range res.Array() {
+			s.walk(r, sel)
+		}
+		return
+	}
+	if !res.IsObject() {
+		return
+	}
+	if v := res.Get("select#"); v.Exists() {
+		sel = v.Int()
+	}
+	res.ForEach(func(key, value gjson.Result) bool {
+		switch key.String() {
+		case "rows_estimation":
+			for _, t := range value.Array() {
+				if ra := t.Get("range_analysis"); ra.Exists() {
+					s.addRange(sel, traceTable(t.Get("table").String()), ra)
+				}
+			}
+		case "considered_execution_plans":
+			for _, p := range value.Array() {
+				s.addPlan(sel, p)
+			}
+		default:
+			s.walk(value, sel)
+		}
+		return true
+	})
+}
+
+func (s *TraceSummary) addRange(sel int64, table string, ra gjson.Result) {
+	r := TraceRange{
+		Select:   sel,
+		Table:    table,
+		ScanRows: ra.Get("table_scan.rows").Float(),
+		ScanCost: ra.Get("table_scan.cost").Float(),
+	}
+	for _, idx := range ra.Get("potential_range_indexes").Array() {
+		if idx.Get("usable").Bool() {
+			continue
+		}
+		r.Indexes = append(r.Indexes, TraceAccess{
+			Select: sel,
+			Table:  table,
+			Type:   "range",
+			Index:  idx.Get("index").String(),
+			Cause:  idx.Get("cause").String(),
+		})
+	}
+	// range_scan_alternatives 中的 chosen 只表示比当时的最优方案好，最终结果以 chosen_range_access_summary 为准
+	summary := ra.Get("chosen_range_access_summary")
+	chosen := summary.Get("range_access_plan.index").String()
+	if summary.Get("chosen").Exists() && !summary.Get("chosen").Bool() {
+		chosen = ""
+	}
+	for _, alt := range ra.Get("analyzing_range_alternatives.range_scan_alternatives").Array() {
+		a := TraceAccess{
+			Select: sel,
+			Table:  table,
+			Type:   "range",
+			Index:  alt.Get("index").String(),
+			Rows:   alt.Get("rows").Float(),
+			Cost:   alt.Get("cost").Float(),
+			Cause:  alt.Get("cause").String(),
+		}
+		a.Chosen = a.Index == chosen
+		if !a.Chosen && a.Cause == "" {
+			a.Cause = "cost"
+		}
+		r.Indexes = append(r.Indexes, a)
+	}
+	s.Ranges = append(s.Ranges, r)
+}
+
+func (s *TraceSummary) addPlan(sel int64, p gjson.Result) {
+	var prefix []string
+	for _, t := range p.Get("plan_prefix").Array() {
+		prefix = append(prefix, traceTable(t.String()))
+	}
+	pruned := ""
+	if p.Get("pruned_by_cost").Bool() {
+		pruned = "pruned_by_cost"
+	} else if p.Get("pruned_by_heuristic").Bool() {
+		pruned = "pruned_by_heuristic"
+	}
+	table := traceTable(p.Get("table").String())
+	for _, path := range p.Get("best_access_path.considered_access_paths").Array() {
+		rows := path.Get("rows")
+		if !rows.Exists() {
+			rows = path.Get("rows_to_scan")
+		}
+		a := TraceAccess{
+			Select: sel,
+			Prefix: prefix,
+			Table:  table,
+			Type:   path.Get("access_type").String(),
+			Index:  path.Get("index").String(),
+			Rows:   rows.Float(),
+			Cost:   path.Get("cost").Float(),
+			Chosen: path.Get("chosen").Bool(),
+			Cause:  path.Get("cause").String(),
+		}
+		if a.Chosen && pruned != "" {
+			a.Chosen = false
+			a.Cause = pruned
+		}
+		s.Paths = append(s.Paths, a)
+	}
+	for _, rest := range p.Get("rest_of_plan").Array() {
+		s.addPlan(sel, rest)
+	}
+}
+
+// traceTable 去掉表名中的反引号，如 `film` `f` 转换为 film f
+func traceTable(table string) string {
+	return strings.TrimSpace(strings.Replace(table, "`", "", -1))
+}
+
+// traceCause 未选择原因的说明
+func traceCause(cause string) string {
+	if desc, ok := traceCauses[cause]; ok {
+		return fmt.Sprintf("%s (%s)", desc, cause)
+	}
+	return cause
+}
+
+// traceDecision 访问方式的结论
+func traceDecision(a TraceAccess) string {
+	if a.Chosen {
+		return "选择"
+	}
+	return "放弃：" + traceCause(a.Cause)
+}
+
+func traceNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Format 以 Markdown 表格输出优化器的决策过程
+func (s *TraceSummary) Format() string {
+	var str []string
+	for _, r := range s.Ranges {
+		str = append(str, fmt.Sprintf("### select#%d %s 的索引选择", r.Select, r.Table), "")
+		if r.ScanRows > 0 || r.ScanCost > 0 {
+			str = append(str, fmt.Sprintf("全表扫描 rows: %s, cost: %s", traceNumber(r.ScanRows), traceNumber(r.ScanCost)), "")
+		}
+		if len(r.Indexes) == 0 {
+			str = append(str, "没有可用于 range 访问的索引", "")
+			continue
+		}
+		str = append(str, "| 索引 | rows | cost | 结论 |", "| --- | --- | --- | --- |")
+		for _, idx := range r.Indexes {
+			if idx.Rows == 0 && idx.Cost == 0 && !idx.Chosen {
+				str = append(str, fmt.Sprintf("| %s | | | 不可用：%s |", idx.Index, traceCause(idx.Cause)))
+				continue
+			}
+			str = append(str, fmt.Sprintf("| %s | %s | %s | %s |", idx.Index, traceNumber(idx.Rows), traceNumber(idx.Cost), traceDecision(idx)))
+		}
+		str = append(str, "")
+	}
+
+	var sel int64 = -1
+	for _, a := range s.Paths {
+		if a.Select != sel {
+			sel = a.Select
+			str = append(str, fmt.Sprintf("### select#%d 的连接顺序及访问方式", sel), "",
+				"| 已连接的表 | 表 | 访问方式 | 索引 | rows | cost | 结论 |",
+				"| --- | --- | --- | --- | --- | --- | --- |")
+		}
+		str = append(str, fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |", strings.Join(a.Prefix, ", "),
+			a.Table, a.Type, a.Index, traceNumber(a.Rows), traceNumber(a.Cost), traceDecision(a)))
+	}
+	return strings.TrimSpace(strings.Join(str, "\n"))
+}
+
+// FormatTraceSummary 整理 Trace 中优化器选择索引及执行计划的过程，无法解析的 Trace 按原样输出
+func FormatTraceSummary(rows []TraceRow) string {
+	explainReg := regexp.MustCompile(`(?i)^explain\s+`)
+	str := []string{""}
+	for _, row := range rows {
+		str = append(str, "```sql", explainReg.ReplaceAllString(row.Query, ""), "```\n")
+		if row.MissingBytesBeyondMaxMemSize > 0 {
+			str = append(str, fmt.Sprintf("Trace 超出 optimizer_trace_max_mem_size 被截断 %d 字节，请调大该配置后重试。\n", row.MissingBytesBeyondMaxMemSize))
+		}
+		summary, err := ParseTrace(row.Trace)
+		if err != nil {
+			str = append(str, "```json", row.Trace, "```\n")
+			continue
+		}
+		if len(summary.Ranges) == 0 && len(summary.Paths) == 0 {
+			str = append(str, "优化器不需要选择索引或连接顺序。\n")
+			continue
+		}
+		str = append(str, summary.Format(), "")
+	}
+	return strings.Join(str, "\n")
+}
@@ -17,6 +17,7 @@
 package database
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/XiaoMi/soar/common"
@@ -58,3 +59,60 @@ func TestFormatTrace(t *testing.T) {
 	}
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestParseTrace(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	trace := `{"steps": [{"join_optimization": {"select#": 1, "steps": [
+  {"rows_estimation": [{"table": "` + "`film`" + `", "range_analysis": {
+    "table_scan": {"rows": 1000, "cost": 204.1},
+    "potential_range_indexes": [
+      {"index": "PRIMARY", "usable": false, "cause": "not_applicable"},
+      {"index": "idx_title", "usable": true, "key_parts": ["title"]},
+      {"index": "idx_language_id", "usable": true, "key_parts": ["language_id"]}
+    ],
+    "analyzing_range_alternatives": {"range_scan_alternatives": [
+      {"index": "idx_title", "rows": 10, "cost": 13.01, "chosen": true},
+      {"index": "idx_language_id", "rows": 500, "cost": 601, "chosen": false, "cause": "cost"}
+    ]},
+    "chosen_range_access_summary": {"range_access_plan": {"type": "range_scan", "index": "idx_title", "rows": 10}, "rows_for_plan": 10, "cost_for_plan": 13.01, "chosen": true}
+  }}]},
+  {"considered_execution_plans": [{"plan_prefix": [], "table": "` + "`film`" + `",
+    "best_access_path": {"considered_access_paths": [
+      {"access_type": "ref", "index": "idx_language_id", "rows": 500, "cost": 120, "chosen": false, "cause": "heuristic_index_cheaper"},
+      {"rows_to_scan": 10, "access_type": "range", "cost": 15.01, "chosen": true}
+    ]},
+    "rows_for_plan": 10, "cost_for_plan": 15.01, "chosen": true}]}
+]}}]}`
+
+	s, err := ParseTrace(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Ranges) != 1 || len(s.Ranges[0].Indexes) != 3 || len(s.Paths) != 2 {
+		t.Fatalf("unexpected summary: %v", s)
+	}
+	r := s.Ranges[0]
+	if r.Table != "film" || r.ScanRows != 1000 || !r.Indexes[1].Chosen || r.Indexes[2].Chosen {
+		t.Errorf("unexpected range analysis: %v", r)
+	}
+	if s.Paths[0].Chosen || !s.Paths[1].Chosen || s.Paths[1].Rows != 10 {
+		t.Errorf("unexpected access paths: %v", s.Paths)
+	}
+
+	str := FormatTraceSummary([]TraceRow{{Query: "explain select * from film", Trace: trace}})
+	for _, want := range []string{
+		"| PRIMARY | | | 不可用：查询条件无法使用该索引 (not_applicable) |",
+		"| idx_title | 10 | 13.01 | 选择 |",
+		"| idx_language_id | 500 | 601 | 放弃：代价高于已选择的访问方式 (cost) |",
+		"|  | film | range |  | 10 | 15.01 | 选择 |",
+	} {
+		if !strings.Contains(str, want) {
+			t.Errorf("want: %s\ngot: %s", want, str)
+		}
+	}
+
+	if _, err = ParseTrace("{"); err == nil {
+		t.Error("want error for invalid trace")
+	}
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}
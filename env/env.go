@@ -80,6 +80,8 @@ func BuildEnv() (*VirtualEnv, *database.Connector) {
 	}
 	connOnline, err := database.NewConnector(common.Config.OnlineDSN)
 	common.LogIfError(err, "")
+	// 线上环境只允许执行白名单中的 SQL
+	connOnline.ReadOnly = true
 
 	// 检查线上环境可用性版本
 	rEnvVersion, err := connOnline.Version()
@@ -473,6 +475,16 @@ func (vEnv *VirtualEnv) createTable(rEnv *database.Connector, tbName string) err
 	// 生成建表语句
 	common.Log.Debug("createTable DSN(%s/%s): generate ddl", rEnv.Addr, rEnv.Database)
 	ddl, err := rEnv.ShowCreateTable(tbName)
+	// SQL 中使用的是分表的逻辑表名时，使用任意一张物理分表的表结构
+	logical := false
+	if err != nil {
+		if physical := shardPhysicalTable(rEnv, tbName); physical != "" {
+			common.Log.Debug("createTable, logical table %s use structure of %s", tbName, physical)
+			ddl, err = rEnv.ShowCreateTable(physical)
+			ddl = strings.Replace(ddl, "`"+physical+"`", "`"+tbName+"`", 1)
+			logical = true
+		}
+	}
 	if err != nil {
 		// 有可能是用户新建表，因此线上环境查不到
 		common.Log.Error("createTable, %s DDL Error : %v", tbName, err)
@@ -490,14 +502,39 @@ func (vEnv *VirtualEnv) createTable(rEnv *database.Connector, tbName string) err
 	err = res.Rows.Close()
 	common.LogIfWarn(err, "")
 
-	// 泵取数据
-	if common.Config.Sampling {
+	// 泵取数据，只复制统计信息时不泵取数据，逻辑表在线上环境中没有数据
+	if logical {
+		return nil
+	}
+	if common.Config.StatisticsTransfer {
+		common.Log.Debug("createTable, Start transfer statistics from %s.%s to %s.%s ...", rEnv.Database, tbName, vEnv.DBRef[rEnv.Database], tbName)
+		err = vEnv.TransferStatistics(rEnv, tbName)
+	} else if common.Config.Sampling {
 		common.Log.Debug("createTable, Start Sampling data from %s.%s to %s.%s ...", rEnv.Database, tbName, vEnv.DBRef[rEnv.Database], tbName)
 		err = vEnv.SamplingData(rEnv, tbName)
 	}
 	return err
 }
 
+// shardPhysicalTable 逻辑表对应的任意一张物理分表，不是逻辑表或线上环境中没有分表时返回空字符串
+func shardPhysicalTable(rEnv *database.Connector, tbName string) string {
+	shard, isLogical := common.FindShardTable(tbName)
+	if shard == nil || !isLogical {
+		return ""
+	}
+	tables, err := rEnv.ShowTables()
+	if err != nil {
+		common.Log.Warning("shardPhysicalTable, ShowTables Error: %v", err)
+		return ""
+	}
+	for _, tb := range tables {
+		if shard.Match(tb) {
+			return tb
+		}
+	}
+	return ""
+}
+
 // GenTableColumns 为 Rewrite 提供的结构体初始化
 func (vEnv *VirtualEnv) GenTableColumns(meta common.Meta) common.TableColumns {
 	tableColumns := make(common.TableColumns)
@@ -577,3 +614,43 @@ func (vEnv *VirtualEnv) GenTableColumns(meta common.Meta) common.TableColumns {
 	}
 	return tableColumns
 }
+
+// BuildSchemaEnv 将 rEnv 当前库中的所有表结构复制到测试环境，视图不会复制
+func (vEnv *VirtualEnv) BuildSchemaEnv(rEnv *database.Connector) error {
+	err := vEnv.createDatabase(rEnv)
+	if err != nil {
+		return err
+	}
+	tables, err := rEnv.ShowTables()
+	if err != nil {
+		return err
+	}
+	for _, tb := range tables {
+		tbStatus, err := rEnv.ShowTableStatus(tb)
+		if err == nil && len(tbStatus.Rows) > 0 && string(tbStatus.Rows[0].Comment) == "VIEW" {
+			continue
+		}
+		// 单表复制失败不影响其他表，执行相关语句时会报错
+		common.LogIfWarn(vEnv.createTable(rEnv, tb), "")
+	}
+	return nil
+}
+
+// Execute 在测试环境中执行 SQL，返回执行耗时
+// USE 语句会切换 rEnv 的数据库，并将该库的表结构复制到测试环境
+func (vEnv *VirtualEnv) Execute(rEnv *database.Connector, sql string) (time.Duration, error) {
+	if stmt, err := sqlparser.Parse(sql); err == nil {
+		if use, ok := stmt.(*sqlparser.Use); ok {
+			rEnv.Database = use.DBName.String()
+			return 0, vEnv.BuildSchemaEnv(rEnv)
+		}
+	}
+	vEnv.Database = vEnv.DBRef[rEnv.Database]
+	start := time.Now()
+	res, err := vEnv.Query(sql)
+	duration := time.Since(start)
+	if err == nil && res.Rows != nil {
+		common.LogIfWarn(res.Rows.Close(), "")
+	}
+	return duration, err
+}
@@ -42,6 +42,7 @@ func TestMain(m *testing.M) {
 		common.DevPath, _ = filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	}
 	common.BaseDir = common.DevPath
+	common.RedirectTestLog()
 	err := common.ParseConfig("")
 	common.LogIfError(err, "init ParseConfig")
 	common.Log.Debug("env_test init")
@@ -297,3 +298,21 @@ func TestCreateDatabase(t *testing.T) {
 	rEnv.Database = orgREnvDatabase
 	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
 }
+
+func TestExecute(t *testing.T) {
+	common.Log.Debug("Entering function: %s", common.GetFunctionName())
+	orgREnvDatabase := rEnv.Database
+	rEnv.Database = "sakila"
+	if err := vEnv.BuildSchemaEnv(rEnv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vEnv.Execute(rEnv, "ALTER TABLE film ADD COLUMN dry_run_col INT"); err != nil {
+		t.Error(err)
+	}
+	// 列已存在，重复添加报错
+	if _, err := vEnv.Execute(rEnv, "ALTER TABLE film ADD COLUMN dry_run_col INT"); err == nil {
+		t.Error("want duplicate column error, got nil")
+	}
+	rEnv.Database = orgREnvDatabase
+	common.Log.Debug("Exiting function: %s", common.GetFunctionName())
+}